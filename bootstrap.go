@@ -2,7 +2,10 @@ package main
 
 import (
 	"github.com/imulab/go-scim/cmd/api"
+	"github.com/imulab/go-scim/cmd/codegen"
 	"github.com/imulab/go-scim/cmd/groupsync"
+	"github.com/imulab/go-scim/cmd/mongo"
+	"github.com/imulab/go-scim/cmd/schema"
 	"github.com/urfave/cli/v2"
 	"log"
 	"os"
@@ -15,6 +18,9 @@ func main() {
 		Commands: []*cli.Command{
 			api.Command(),
 			groupsync.Command(),
+			codegen.Command(),
+			schema.Command(),
+			mongo.Command(),
 		},
 		HideVersion: true,
 		Authors: []*cli.Author{
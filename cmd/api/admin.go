@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"github.com/imulab/go-scim/cmd/schema"
+	scimmongo "github.com/imulab/go-scim/mongo/v2"
+	"github.com/imulab/go-scim/pkg/v2/admin"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Name of the MongoDB collection persisting schemas and resource types managed at runtime.
+const definitionCollection = "scim_definitions"
+
+// AdminService returns the service managing schemas and resource types at runtime, or nil if the admin API is not
+// enabled. The resource types loaded at startup are put under management, and definitions persisted previously are
+// applied, when the service is initialized.
+func (ctx *applicationContext) AdminService() *admin.Service {
+	if len(ctx.args.adminToken) == 0 {
+		return nil
+	}
+	if ctx.adminService == nil {
+		var store admin.Store
+		if ctx.args.UseMemoryDB {
+			store = admin.MemoryStore()
+		} else {
+			store = scimmongo.DefinitionStore(ctx.MongoClient().
+				Database(ctx.args.MongoDB.Database, options.Database()).
+				Collection(definitionCollection, options.Collection()))
+		}
+
+		svc := admin.New(ctx.args.SchemaRegistry(), store).
+			Reserve("/admin", "/Events", "/health", "/openapi.json").
+			Linter(schema.NewLinter())
+		if err := svc.Adopt(ctx.ResourceTypes()...); err != nil {
+			ctx.logInitFailure("admin service", err)
+			panic(err)
+		}
+
+		loadCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancelFunc()
+		if err := svc.Load(loadCtx); err != nil {
+			ctx.logInitFailure("admin service", err)
+			panic(err)
+		}
+
+		ctx.adminService = svc
+		ctx.logInitialized("admin service")
+	}
+	return ctx.adminService
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/cmd/internal/args"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	s := new(AdminTestSuite)
+	suite.Run(t, s)
+}
+
+type AdminTestSuite struct {
+	suite.Suite
+	app    *applicationContext
+	router http.Handler
+}
+
+const (
+	testAdminToken = "s3cr3t"
+	testDevice     = `{
+		"id": "urn:imulab:scim:schemas:core:2.0:Device",
+		"name": "Device",
+		"attributes": [
+			{
+				"id": "urn:imulab:scim:schemas:core:2.0:Device:serialNumber",
+				"name": "serialNumber",
+				"type": "string",
+				"required": true,
+				"_index": 0,
+				"_path": "serialNumber"
+			}
+		]
+	}`
+	testDeviceResourceType = `{
+		"id": "Device",
+		"name": "Device",
+		"endpoint": "/Devices",
+		"schema": "urn:imulab:scim:schemas:core:2.0:Device"
+	}`
+)
+
+func (s *AdminTestSuite) SetupTest() {
+	abs := func(path string) string {
+		p, err := filepath.Abs(path)
+		if err != nil {
+			s.FailNow(err.Error())
+		}
+		return p
+	}
+
+	s.app = &applicationContext{args: &arguments{
+		Scim: &args.Scim{
+			ServiceProviderConfigPath: abs("../../public/service_provider_config.json"),
+			UserResourceTypePath:      abs("../../public/resource_types/user_resource_type.json"),
+			GroupResourceTypePath:     abs("../../public/resource_types/group_resource_type.json"),
+			SchemasDirectory:          abs("../../public/schemas"),
+		},
+		MemoryDB:  &args.MemoryDB{UseMemoryDB: true},
+		MongoDB:   new(args.MongoDB),
+		RabbitMQ:  new(args.RabbitMQ),
+		Logging:   &args.Logging{Level: "ERROR"},
+		Event:     new(args.Event),
+		Connector: new(args.Connector),
+	}}
+
+	// serve through a tenant, which does not depend on RabbitMQ and MongoDB
+	s.router = s.app.Tenant(&tenantConfig{ID: "acme", PathPrefix: "/acme", AdminToken: testAdminToken}).Router()
+}
+
+func (s *AdminTestSuite) TearDownTest() {
+	s.app.Close()
+}
+
+func (s *AdminTestSuite) TestAuth() {
+	for _, token := range []string{"", "wrong"} {
+		r := httptest.NewRequest(http.MethodPost, "/admin/Schemas", strings.NewReader(testDevice))
+		if len(token) > 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rw := httptest.NewRecorder()
+		s.router.ServeHTTP(rw, r)
+		assert.Equal(s.T(), http.StatusUnauthorized, rw.Code)
+	}
+}
+
+func (s *AdminTestSuite) TestManageResourceType() {
+	assert.Equal(s.T(), http.StatusNotFound, s.do(http.MethodGet, "/Devices", "", false).Code)
+
+	assert.Equal(s.T(), http.StatusCreated, s.do(http.MethodPost, "/admin/Schemas", testDevice, true).Code)
+	assert.Equal(s.T(), http.StatusOK, s.do(http.MethodGet, "/Schemas/urn:imulab:scim:schemas:core:2.0:Device", "", false).Code)
+
+	rw := s.do(http.MethodPost, "/admin/ResourceTypes", testDeviceResourceType, true)
+	assert.Equal(s.T(), http.StatusCreated, rw.Code)
+	assert.Contains(s.T(), rw.Body.String(), `"endpoint":"/Devices"`)
+	assert.Equal(s.T(), 3, s.totalResults("/ResourceTypes"))
+
+	rw = s.do(http.MethodPost, "/Devices", `{
+		"schemas": ["urn:imulab:scim:schemas:core:2.0:Device"],
+		"serialNumber": "SN-001"
+	}`, false)
+	assert.Equal(s.T(), http.StatusCreated, rw.Code)
+	assert.Equal(s.T(), 1, s.totalResults("/Devices"))
+	assert.Equal(s.T(), 0, s.totalResults("/Users"))
+
+	// id in path must match the definition
+	rw = s.do(http.MethodPut, "/admin/ResourceTypes/Other", testDeviceResourceType, true)
+	assert.Equal(s.T(), http.StatusBadRequest, rw.Code)
+
+	// resources survive compatible updates
+	rw = s.do(http.MethodPut, "/admin/ResourceTypes/Device", strings.Replace(testDeviceResourceType, `"name": "Device"`, `"name": "Appliance"`, 1), true)
+	assert.Equal(s.T(), http.StatusOK, rw.Code)
+	assert.Equal(s.T(), 1, s.totalResults("/Devices"))
+
+	assert.Equal(s.T(), http.StatusPreconditionFailed, s.do(http.MethodDelete, "/admin/Schemas/urn:imulab:scim:schemas:core:2.0:Device", "", true).Code)
+	assert.Equal(s.T(), http.StatusNoContent, s.do(http.MethodDelete, "/admin/ResourceTypes/Device", "", true).Code)
+	assert.Equal(s.T(), http.StatusNoContent, s.do(http.MethodDelete, "/admin/Schemas/urn:imulab:scim:schemas:core:2.0:Device", "", true).Code)
+
+	assert.Equal(s.T(), http.StatusNotFound, s.do(http.MethodGet, "/Devices", "", false).Code)
+	assert.Equal(s.T(), http.StatusNotFound, s.do(http.MethodGet, "/Schemas/urn:imulab:scim:schemas:core:2.0:Device", "", false).Code)
+	assert.Equal(s.T(), 2, s.totalResults("/ResourceTypes"))
+}
+
+func (s *AdminTestSuite) do(method string, path string, body string, auth bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if auth {
+		r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	}
+	rw := httptest.NewRecorder()
+	s.router.ServeHTTP(rw, r)
+	return rw
+}
+
+func (s *AdminTestSuite) totalResults(path string) int {
+	rw := s.do(http.MethodGet, path, "", false)
+	if !assert.Equal(s.T(), http.StatusOK, rw.Code, path) {
+		return -1
+	}
+
+	var result struct {
+		TotalResults int `json:"totalResults"`
+	}
+	assert.Nil(s.T(), json.Unmarshal(rw.Body.Bytes(), &result))
+	return result.TotalResults
+}
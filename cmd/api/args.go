@@ -7,11 +7,14 @@ import (
 
 func newArgs() *arguments {
 	return &arguments{
-		Scim:     new(args.Scim),
-		MemoryDB: new(args.MemoryDB),
-		MongoDB:  new(args.MongoDB),
-		RabbitMQ: new(args.RabbitMQ),
-		Logging:  new(args.Logging),
+		Scim:       new(args.Scim),
+		MemoryDB:   new(args.MemoryDB),
+		MongoDB:    new(args.MongoDB),
+		RabbitMQ:   new(args.RabbitMQ),
+		Logging:    new(args.Logging),
+		Event:      new(args.Event),
+		Connector:  new(args.Connector),
+		Encryption: new(args.Encryption),
 	}
 }
 
@@ -21,7 +24,14 @@ type arguments struct {
 	*args.MongoDB
 	*args.RabbitMQ
 	*args.Logging
-	httpPort int
+	*args.Event
+	*args.Connector
+	*args.Encryption
+	httpPort         int
+	tenantsPath      string
+	adminToken       string
+	resourceTypesDir string
+	groupSyncWorkers int
 }
 
 func (arg *arguments) Flags() []cli.Flag {
@@ -34,12 +44,40 @@ func (arg *arguments) Flags() []cli.Flag {
 			Value:       8080,
 			Destination: &arg.httpPort,
 		},
+		&cli.StringFlag{
+			Name:        "tenants",
+			Usage:       "Absolute file path to the JSON definition of tenants served alongside the default application, without events, connector and group sync messages",
+			EnvVars:     []string{"TENANTS"},
+			Destination: &arg.tenantsPath,
+		},
+		&cli.StringFlag{
+			Name:        "resource-types-dir",
+			Usage:       "Absolute path to the directory containing JSON definitions of resource types served in addition to users and groups",
+			EnvVars:     []string{"RESOURCE_TYPES_DIR"},
+			Destination: &arg.resourceTypesDir,
+		},
+		&cli.StringFlag{
+			Name:        "admin-token",
+			Usage:       "Bearer token to access the API managing schemas and resource types at runtime, which is disabled if empty",
+			EnvVars:     []string{"ADMIN_TOKEN"},
+			Destination: &arg.adminToken,
+		},
+		&cli.IntFlag{
+			Name:        "group-sync-workers",
+			Usage:       "Number of workers synchronizing group membership of user resources in process, when using in-memory databases",
+			EnvVars:     []string{"GROUP_SYNC_WORKERS"},
+			Value:       4,
+			Destination: &arg.groupSyncWorkers,
+		},
 	}
 	flags = append(flags, arg.Scim.Flags()...)
 	flags = append(flags, arg.MemoryDB.Flags()...)
 	flags = append(flags, arg.MongoDB.Flags()...)
 	flags = append(flags, arg.RabbitMQ.Flags()...)
 	flags = append(flags, arg.Logging.Flags()...)
+	flags = append(flags, arg.Event.Flags()...)
+	flags = append(flags, arg.Connector.Flags()...)
+	flags = append(flags, arg.Encryption.Flags()...)
 	return flags
 }
 
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/julienschmidt/httprouter"
 	"github.com/urfave/cli/v2"
 	"net/http"
+	"sync/atomic"
 )
 
 // Command returns a cli.Command that starts an HTTP router to serve the SCIM API.
@@ -20,29 +23,24 @@ func Command() *cli.Command {
 
 			app.ensureSchemaRegistered()
 
-			var router = httprouter.New()
-			{
-				router.GET("/ServiceProviderConfig", ServiceProviderConfigHandler(app.ServiceProviderConfig()))
-				router.GET("/Schemas", SchemasHandler())
-				router.GET("/Schemas/:id", SchemaByIdHandler())
-				router.GET("/ResourceTypes", ResourceTypesHandler(app.UserResourceType(), app.GroupResourceType()))
-				router.GET("/ResourceTypes/:id", ResourceTypeByIdHandler(app.userResourceType, app.GroupResourceType()))
-
-				router.GET("/Users/:id", GetHandler(app.UserGetService(), app.Logger()))
-				router.GET("/Users", SearchHandler(app.UserQueryService(), app.Logger()))
-				router.POST("/Users", CreateHandler(app.UserCreateService(), app.Logger()))
-				router.PUT("/Users/:id", ReplaceHandler(app.UserReplaceService(), app.Logger()))
-				router.PATCH("/Users/:id", PatchHandler(app.UserPatchService(), app.Logger()))
-				router.DELETE("/Users/:id", DeleteHandler(app.UserDeleteService(), app.Logger()))
-
-				router.GET("/Groups/:id", GetHandler(app.GroupGetService(), app.Logger()))
-				router.GET("/Groups", SearchHandler(app.GroupQueryService(), app.Logger()))
-				router.POST("/Groups", CreateHandler(app.GroupCreateService(), app.Logger()))
-				router.PUT("/Groups/:id", ReplaceHandler(app.GroupReplaceService(), app.Logger()))
-				router.PATCH("/Groups/:id", PatchHandler(app.GroupPatchService(), app.Logger()))
-				router.DELETE("/Groups/:id", DeleteHandler(app.GroupDeleteService(), app.Logger()))
-
-				router.GET("/health", HealthHandler(app.MongoClient(), app.RabbitMQConnection()))
+			reconcileCtx, cancelReconcile := context.WithCancel(context.Background())
+			defer cancelReconcile()
+			app.StartReconciliation(reconcileCtx)
+			app.StartKeyRotation(reconcileCtx)
+			app.StartGroupSync(reconcileCtx)
+
+			var router http.Handler = app.Router()
+			if len(args.tenantsPath) > 0 {
+				tenants, err := parseTenants(args.tenantsPath)
+				if err != nil {
+					return err
+				}
+				tr := newTenantRouter(router)
+				for _, each := range tenants {
+					tenant := app.Tenant(each)
+					tr.Register(each, tenant.args.SchemaRegistry(), tenant.Router())
+				}
+				router = tr
 			}
 
 			app.Logger().Info().Fields(map[string]interface{}{
@@ -53,3 +51,78 @@ func Command() *cli.Command {
 		},
 	}
 }
+
+// Router returns a handler serving the SCIM API of the application. Tenants are served without the events and health
+// endpoints, which belong to the default application. If the admin API is enabled, the routes are rebuilt whenever
+// schemas or resource types change, so endpoints of new resource types are served and the Schemas and ResourceTypes
+// endpoints are up to date.
+func (app *applicationContext) Router() http.Handler {
+	adminService := app.AdminService()
+	if adminService == nil {
+		return app.routes(app.ResourceTypes())
+	}
+
+	dr := new(dynamicRouter)
+	dr.Store(app.routes(adminService.ResourceTypes()))
+	adminService.Subscribe(func(resourceTypes []*spec.ResourceType) {
+		dr.Store(app.routes(resourceTypes))
+	})
+	return dr
+}
+
+// routes returns a router serving the resource types at their endpoints.
+func (app *applicationContext) routes(resourceTypes []*spec.ResourceType) *httprouter.Router {
+	router := httprouter.New()
+
+	router.GET("/ServiceProviderConfig", ServiceProviderConfigHandler(app.ServiceProviderConfig()))
+	router.GET("/Schemas", SchemasHandler(app.args.SchemaRegistry()))
+	router.GET("/Schemas/:id", SchemaByIdHandler(app.args.SchemaRegistry()))
+	router.GET("/ResourceTypes", ResourceTypesHandler(resourceTypes...))
+	router.GET("/ResourceTypes/:id", ResourceTypeByIdHandler(resourceTypes...))
+	router.GET("/openapi.json", OpenAPIHandler(resourceTypes...))
+
+	for _, resourceType := range resourceTypes {
+		svc := app.ResourceServices(resourceType)
+		router.GET(resourceType.Endpoint()+"/:id", GetHandler(svc.get, app.Logger()))
+		router.GET(resourceType.Endpoint(), SearchHandler(svc.query, app.Logger()))
+		router.POST(resourceType.Endpoint(), CreateHandler(svc.create, svc.clientOptions, app.Logger()))
+		router.PUT(resourceType.Endpoint()+"/:id", ReplaceHandler(svc.replace, svc.clientOptions, app.Logger()))
+		router.PATCH(resourceType.Endpoint()+"/:id", PatchHandler(svc.patch, svc.clientOptions, app.Logger()))
+		router.DELETE(resourceType.Endpoint()+"/:id", DeleteHandler(svc.delete, app.Logger()))
+		if resourceType.ID() == app.GroupResourceType().ID() {
+			router.GET(resourceType.Endpoint()+"/:id/effectiveMembers", EffectiveMembersHandler(app.ServiceProviderConfig(), app.UserDatabase(), app.MembershipGraph(), app.Logger()))
+		}
+	}
+
+	if adminService := app.AdminService(); adminService != nil {
+		token := app.args.adminToken
+		router.POST("/admin/Schemas", AdminAuth(token, SchemaAdminHandler(adminService.RegisterSchema, http.StatusCreated, app.Logger())))
+		router.PUT("/admin/Schemas/:id", AdminAuth(token, SchemaAdminHandler(adminService.UpdateSchema, http.StatusOK, app.Logger())))
+		router.DELETE("/admin/Schemas/:id", AdminAuth(token, RetireAdminHandler(adminService.RetireSchema, app.Logger())))
+		router.POST("/admin/ResourceTypes", AdminAuth(token, ResourceTypeAdminHandler(adminService.RegisterResourceType, http.StatusCreated, app.Logger())))
+		router.PUT("/admin/ResourceTypes/:id", AdminAuth(token, ResourceTypeAdminHandler(adminService.UpdateResourceType, http.StatusOK, app.Logger())))
+		router.DELETE("/admin/ResourceTypes/:id", AdminAuth(token, RetireAdminHandler(adminService.RetireResourceType, app.Logger())))
+	}
+
+	if app.parent == nil {
+		if app.args.ReceiveEnabled() {
+			router.POST("/Events", EventReceiverHandler(app.EventReceiver(), app.Logger()))
+		}
+		if app.args.UseMemoryDB {
+			router.GET("/health", HealthHandler(nil, nil))
+		} else {
+			router.GET("/health", HealthHandler(app.MongoClient(), app.RabbitMQConnection()))
+		}
+	}
+
+	return router
+}
+
+// dynamicRouter serves requests with the latest stored router.
+type dynamicRouter struct {
+	atomic.Value
+}
+
+func (r *dynamicRouter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.Load().(*httprouter.Router).ServeHTTP(rw, req)
+}
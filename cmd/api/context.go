@@ -2,22 +2,42 @@ package api
 
 import (
 	"context"
-	"github.com/imulab/go-scim/cmd/internal/groupsync"
+	gs "github.com/imulab/go-scim/cmd/internal/groupsync"
 	scimmongo "github.com/imulab/go-scim/mongo/v2"
+	"github.com/imulab/go-scim/pkg/v2/admin"
+	"github.com/imulab/go-scim/pkg/v2/connector"
 	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/encryption"
+	"github.com/imulab/go-scim/pkg/v2/event"
+	"github.com/imulab/go-scim/pkg/v2/groupsync"
+	"github.com/imulab/go-scim/pkg/v2/integrity"
 	"github.com/imulab/go-scim/pkg/v2/service"
-	"github.com/imulab/go-scim/pkg/v2/service/filter"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/rs/zerolog"
 	"github.com/streadway/amqp"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	// MongoDB collection of the group sync outbox
+	groupSyncOutboxCollection = "group_sync_outbox"
+	// interval to relay group sync jobs left in the outbox, i.e. after failures to submit them
+	groupSyncRelayInterval = 10 * time.Second
+	// age after which the membership graph is reloaded with MongoDB, to pick up changes made by other processes
+	membershipGraphMaxAge = time.Minute
+	// prefix of the MongoDB collections of the remote ids of the connectors, followed by the resource type name
+	connectorIDMapCollection = "connector_ids"
+)
+
 type applicationContext struct {
 	args                      *arguments
+	tenant                    string              // id of the tenant, empty for the default application
+	parent                    *applicationContext // application sharing its connections with the tenant, if tenant
+	tenants                   []*applicationContext
 	logger                    *zerolog.Logger
 	serviceProviderConfig     *spec.ServiceProviderConfig
 	registerSchemaOnce        sync.Once
@@ -29,23 +49,35 @@ type applicationContext struct {
 	registerMongoMetadataOnce sync.Once
 	rabbitMqConn              *amqp.Connection
 	rabbitMqChannel           *amqp.Channel
-	userCreateService         service.Create
-	groupCreateService        service.Create
-	userReplaceService        service.Replace
-	groupReplaceService       service.Replace
-	userPatchService          service.Patch
-	groupPatchService         service.Patch
-	userDeleteService         service.Delete
-	groupDeleteService        service.Delete
-	userGetService            service.Get
-	groupGetService           service.Get
-	userQueryService          service.Query
-	groupQueryService         service.Query
+	groupSyncOutbox           groupsync.Outbox
+	groupSyncRelay            *groupsync.Relay
+	groupSyncQueue            groupsync.Queue
+	membershipGraph           *groupsync.MembershipGraph
+	eventPublisher            *event.Publisher
+	eventReceiver             *event.Receiver
+	remote                    *connector.Remote
+	userConnector             *connector.Connector
+	groupConnector            *connector.Connector
+	adminService              *admin.Service
+	resourceTypes             []*spec.ResourceType
+	pipelines                 map[string]*pipelineConfig
+	resourceServicesLock      sync.Mutex
+	resourceServices          map[*spec.ResourceType]*resourceServices
+	memoryDatabases           map[string]db.DB
+	cipher                    *encryption.Cipher
+	rotationsLock             sync.Mutex
+	rotations                 map[rotationKey]*rotation
+	references                *integrity.Registry
 }
 
 func (ctx *applicationContext) Logger() *zerolog.Logger {
 	if ctx.logger == nil {
-		ctx.logger = ctx.args.Logger()
+		if ctx.parent != nil {
+			logger := ctx.parent.Logger().With().Str("tenant", ctx.tenant).Logger()
+			ctx.logger = &logger
+		} else {
+			ctx.logger = ctx.args.Logger()
+		}
 		ctx.logger.Info().Msg("logger initialized")
 	}
 	return ctx.logger
@@ -103,6 +135,9 @@ func (ctx *applicationContext) ensureSchemaRegistered() {
 }
 
 func (ctx *applicationContext) MongoClient() *mongo.Client {
+	if ctx.parent != nil {
+		return ctx.parent.MongoClient()
+	}
 	if ctx.mongoClient == nil {
 		connectCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancelFunc()
@@ -122,7 +157,7 @@ func (ctx *applicationContext) MongoClient() *mongo.Client {
 func (ctx *applicationContext) UserDatabase() db.DB {
 	if ctx.userDatabase == nil {
 		if ctx.args.UseMemoryDB {
-			ctx.userDatabase = db.Memory()
+			ctx.userDatabase = ctx.encryptedDatabase(ctx.UserResourceType(), db.Memory())
 			ctx.logInitialized("in-memory user database")
 		} else {
 			ctx.ensureMongoMetadata()
@@ -130,7 +165,11 @@ func (ctx *applicationContext) UserDatabase() db.DB {
 			collection := ctx.MongoClient().
 				Database(ctx.args.MongoDB.Database, options.Database()).
 				Collection(resourceType.Name(), options.Collection())
-			ctx.userDatabase = scimmongo.DB(resourceType, collection, scimmongo.Options().IgnoreProjection())
+			if err := ctx.verifyMongoIndexes(resourceType, collection); err != nil {
+				ctx.logInitFailure("mongo user indexes", err)
+				panic(err)
+			}
+			ctx.userDatabase = ctx.encryptedDatabase(resourceType, scimmongo.DB(resourceType, collection, scimmongo.Options().Metadata(ctx.args.MongoDB.MetadataRegistry())))
 			ctx.logInitialized("mongo user database")
 		}
 	}
@@ -140,7 +179,7 @@ func (ctx *applicationContext) UserDatabase() db.DB {
 func (ctx *applicationContext) GroupDatabase() db.DB {
 	if ctx.groupDatabase == nil {
 		if ctx.args.UseMemoryDB {
-			ctx.groupDatabase = db.Memory()
+			ctx.groupDatabase = ctx.encryptedDatabase(ctx.GroupResourceType(), db.Memory())
 			ctx.logInitialized("in-memory group database")
 		} else {
 			ctx.ensureMongoMetadata()
@@ -148,7 +187,11 @@ func (ctx *applicationContext) GroupDatabase() db.DB {
 			collection := ctx.MongoClient().
 				Database(ctx.args.MongoDB.Database, options.Database()).
 				Collection(resourceType.Name(), options.Collection())
-			ctx.groupDatabase = scimmongo.DB(resourceType, collection, scimmongo.Options().IgnoreProjection())
+			if err := ctx.verifyMongoIndexes(resourceType, collection); err != nil {
+				ctx.logInitFailure("mongo group indexes", err)
+				panic(err)
+			}
+			ctx.groupDatabase = ctx.encryptedDatabase(resourceType, scimmongo.DB(resourceType, collection, scimmongo.Options().Metadata(ctx.args.MongoDB.MetadataRegistry())))
 			ctx.logInitialized("mongo group database")
 		}
 	}
@@ -165,168 +208,295 @@ func (ctx *applicationContext) ensureMongoMetadata() {
 	})
 }
 
-func (ctx *applicationContext) UserCreateService() service.Create {
-	if ctx.userCreateService == nil {
-		ctx.userCreateService = service.CreateService(ctx.UserResourceType(), ctx.UserDatabase(), []filter.ByResource{
-			filter.ByPropertyToByResource(
-				filter.ReadOnlyFilter(),
-				filter.UUIDFilter(),
-				filter.BCryptFilter(),
-			),
-			filter.MetaFilter(),
-			filter.ByPropertyToByResource(filter.ValidationFilter(ctx.UserDatabase())),
-		})
-		ctx.logInitialized("user create service")
-	}
-	return ctx.userCreateService
-}
-
-func (ctx *applicationContext) GroupCreateService() service.Create {
-	if ctx.groupCreateService == nil {
-		ctx.groupCreateService = &groupCreated{
-			service: service.CreateService(ctx.GroupResourceType(), ctx.GroupDatabase(), []filter.ByResource{
-				filter.ByPropertyToByResource(
-					filter.ReadOnlyFilter(),
-					filter.UUIDFilter(),
-				),
-				filter.MetaFilter(),
-				filter.ByPropertyToByResource(filter.ValidationFilter(ctx.GroupDatabase())),
-			}),
-			sender: &groupSyncSender{
-				channel: ctx.RabbitMQChannel(),
-				logger:  ctx.Logger(),
-			},
+// verifyMongoIndexes creates the missing indexes of the collection of the resource type, unless disabled, and warns
+// about the indexes that differ from the plan, which are left for the mongo migrate command to resolve. Unique indexes
+// that are not in place fail the verification.
+func (ctx *applicationContext) verifyMongoIndexes(resourceType *spec.ResourceType, collection *mongo.Collection) error {
+	verifyCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelFunc()
+
+	diff, err := ctx.args.MongoDB.VerifyIndexes(verifyCtx, resourceType, collection)
+	if err != nil {
+		return err
+	}
+	if !diff.Empty() {
+		ctx.Logger().
+			Warn().
+			Fields(map[string]interface{}{
+				"collection": collection.Name(),
+				"indexes":    strings.Split(strings.TrimSpace(diff.String()), "\n"),
+			}).
+			Msg("Indexes differ from schema, run 'scim mongo migrate' to migrate them")
+	}
+	return nil
+}
+
+// groupSyncDatabase returns the database storing groups in the database, and applying their membership changes to the
+// membership graph, along with the group sync jobs for the changes, except for tenants, whose groups are not
+// synchronized.
+func (ctx *applicationContext) groupSyncDatabase(database db.DB) db.DB {
+	database = groupsync.GraphDB(database, ctx.MembershipGraph())
+	if ctx.parent != nil {
+		return database
+	}
+	return groupsync.OutboxDB(database, ctx.GroupSyncOutbox(), ctx.GroupSyncRelay())
+}
+
+// MembershipGraph returns the graph of group memberships, to resolve the effective members of groups. With MongoDB,
+// where groups may also be changed by other processes, the graph is reloaded periodically.
+func (ctx *applicationContext) MembershipGraph() *groupsync.MembershipGraph {
+	if ctx.membershipGraph == nil {
+		ctx.membershipGraph = groupsync.NewMembershipGraph(ctx.GroupDatabase())
+		if !ctx.args.UseMemoryDB {
+			ctx.membershipGraph.MaxAge(membershipGraphMaxAge)
 		}
-		ctx.logInitialized("group create service")
+		ctx.logInitialized("membership graph")
 	}
-	return ctx.groupCreateService
+	return ctx.membershipGraph
 }
 
-func (ctx *applicationContext) UserReplaceService() service.Replace {
-	if ctx.userReplaceService == nil {
-		ctx.userReplaceService = service.ReplaceService(ctx.ServiceProviderConfig(), ctx.UserResourceType(), ctx.UserDatabase(), []filter.ByResource{
-			filter.ByPropertyToByResource(
-				filter.ReadOnlyFilter(),
-				filter.BCryptFilter(),
-			),
-			filter.ByPropertyToByResource(filter.ValidationFilter(ctx.UserDatabase())),
-			filter.MetaFilter(),
-		})
-		ctx.logInitialized("user replace service")
-	}
-	return ctx.userReplaceService
-}
-
-func (ctx *applicationContext) GroupReplaceService() service.Replace {
-	if ctx.groupReplaceService == nil {
-		ctx.groupReplaceService = &groupReplaced{
-			service: service.ReplaceService(ctx.ServiceProviderConfig(), ctx.GroupResourceType(), ctx.GroupDatabase(), []filter.ByResource{
-				filter.ByPropertyToByResource(
-					filter.ReadOnlyFilter(),
-				),
-				filter.ByPropertyToByResource(filter.ValidationFilter(ctx.UserDatabase())),
-				filter.MetaFilter(),
-			}),
-			sender: &groupSyncSender{
-				channel: ctx.RabbitMQChannel(),
-				logger:  ctx.Logger(),
-			},
+// GroupSyncOutbox returns the outbox storing group sync jobs, until they are relayed to the group sync queue. The
+// outbox is stored in the database of the groups.
+func (ctx *applicationContext) GroupSyncOutbox() groupsync.Outbox {
+	if ctx.groupSyncOutbox == nil {
+		if ctx.args.UseMemoryDB {
+			ctx.groupSyncOutbox = groupsync.MemoryOutbox()
+			ctx.logInitialized("in-memory group sync outbox")
+		} else {
+			collection := ctx.MongoClient().
+				Database(ctx.args.MongoDB.Database, options.Database()).
+				Collection(groupSyncOutboxCollection, options.Collection())
+			indexCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
+			err := scimmongo.EnsureOutboxIndexes(indexCtx, collection)
+			cancelFunc()
+			if err != nil {
+				ctx.logInitFailure("mongo group sync outbox", err)
+				panic(err)
+			}
+			ctx.groupSyncOutbox = scimmongo.Outbox(collection)
+			ctx.logInitialized("mongo group sync outbox")
 		}
-		ctx.logInitialized("group replace service")
 	}
-	return ctx.groupReplaceService
+	return ctx.groupSyncOutbox
 }
 
-func (ctx *applicationContext) UserPatchService() service.Patch {
-	if ctx.userPatchService == nil {
-		ctx.userPatchService = service.PatchService(ctx.ServiceProviderConfig(), ctx.UserDatabase(), []filter.ByResource{}, []filter.ByResource{
-			filter.ByPropertyToByResource(
-				filter.ReadOnlyFilter(),
-				filter.BCryptFilter(),
-			),
-			filter.ByPropertyToByResource(filter.ValidationFilter(ctx.UserDatabase())),
-			filter.MetaFilter(),
-		})
-		ctx.logInitialized("user patch service")
-	}
-	return ctx.userPatchService
-}
-
-func (ctx *applicationContext) GroupPatchService() service.Patch {
-	if ctx.groupPatchService == nil {
-		ctx.groupPatchService = &groupPatched{
-			service: service.PatchService(ctx.ServiceProviderConfig(), ctx.GroupDatabase(), []filter.ByResource{}, []filter.ByResource{
-				filter.ByPropertyToByResource(
-					filter.ReadOnlyFilter(),
-				),
-				filter.ByPropertyToByResource(filter.ValidationFilter(ctx.GroupDatabase())),
-				filter.MetaFilter(),
-			}),
-			sender: &groupSyncSender{
-				channel: ctx.RabbitMQChannel(),
-				logger:  ctx.Logger(),
-			},
+// GroupSyncRelay returns the relay submitting the jobs in the group sync outbox to the group sync queue, once
+// StartGroupSync is called.
+func (ctx *applicationContext) GroupSyncRelay() *groupsync.Relay {
+	if ctx.groupSyncRelay == nil {
+		ctx.groupSyncRelay = groupsync.NewRelay(ctx.GroupSyncOutbox(), ctx.GroupSyncQueue())
+		ctx.logInitialized("group sync relay")
+	}
+	return ctx.groupSyncRelay
+}
+
+// GroupSyncQueue returns the queue of group sync jobs. With in memory databases, which the groupsync command cannot
+// reach, jobs are handled in process by a worker pool once StartGroupSync is called; otherwise, they are sent through
+// RabbitMQ to the groupsync command.
+func (ctx *applicationContext) GroupSyncQueue() groupsync.Queue {
+	if ctx.groupSyncQueue == nil {
+		if ctx.args.UseMemoryDB {
+			ctx.groupSyncQueue = groupsync.NewWorkerPool(ctx.args.groupSyncWorkers).OnError(func(job *groupsync.Job, err error) {
+				ctx.Logger().Err(err).Fields(job.Fields()).Msg("Group sync job had exceeded trial limit")
+			})
+			ctx.logInitialized("in-process group sync queue")
+		} else {
+			ctx.groupSyncQueue = gs.RabbitQueue(ctx.RabbitMQChannel(), ctx.Logger())
+			ctx.logInitialized("rabbit group sync queue")
+		}
+	}
+	return ctx.groupSyncQueue
+}
+
+// StartGroupSync starts to relay the jobs in the group sync outbox to the group sync queue, until the context is
+// cancelled. With in memory databases, the jobs are also handled in process.
+func (ctx *applicationContext) StartGroupSync(c context.Context) {
+	if pool, ok := ctx.GroupSyncQueue().(*groupsync.WorkerPool); ok {
+		if _, err := pool.Consume(c, groupsync.NewGraphJobHandler(ctx.UserDatabase(), ctx.MembershipGraph())); err != nil {
+			ctx.logInitFailure("group sync workers", err)
 		}
-		ctx.logInitialized("group patch service")
 	}
-	return ctx.groupPatchService
+	go ctx.GroupSyncRelay().Run(c, groupSyncRelayInterval, func(n int, err error) {
+		if err != nil {
+			ctx.Logger().Err(err).Int("relayed", n).Msg("Failed to relay group sync jobs")
+			return
+		}
+		ctx.Logger().Info().Int("relayed", n).Msg("Relayed group sync jobs")
+	})
 }
 
-func (ctx *applicationContext) UserDeleteService() service.Delete {
-	if ctx.userDeleteService == nil {
-		ctx.userDeleteService = service.DeleteService(ctx.ServiceProviderConfig(), ctx.UserDatabase())
-		ctx.logInitialized("user delete service")
+func (ctx *applicationContext) EventPublisher() *event.Publisher {
+	if ctx.eventPublisher == nil {
+		publisher, err := ctx.args.EventPublisher()
+		if err != nil {
+			ctx.logInitFailure("event publisher", err)
+			panic(err)
+		}
+		ctx.eventPublisher = publisher.OnError(func(token *event.SecurityEventToken, err error) {
+			fields := map[string]interface{}{}
+			if token != nil {
+				fields["jti"] = token.ID
+			}
+			ctx.Logger().Err(err).Fields(fields).Msg("Failed to publish provisioning event")
+		})
+		ctx.logInitialized("event publisher")
 	}
-	return ctx.userDeleteService
+	return ctx.eventPublisher
 }
 
-func (ctx *applicationContext) GroupDeleteService() service.Delete {
-	if ctx.groupDeleteService == nil {
-		ctx.groupDeleteService = &groupDeleted{
-			service: service.DeleteService(ctx.ServiceProviderConfig(), ctx.GroupDatabase()),
-			sender: &groupSyncSender{
-				channel: ctx.RabbitMQChannel(),
-				logger:  ctx.Logger(),
-			},
+func (ctx *applicationContext) EventReceiver() *event.Receiver {
+	if ctx.eventReceiver == nil {
+		receiver, err := ctx.args.EventReceiver()
+		if err != nil {
+			ctx.logInitFailure("event receiver", err)
+			panic(err)
+		}
+		for _, resourceType := range ctx.ResourceTypes() {
+			svc := ctx.ResourceServices(resourceType)
+			receiver.Register(resourceType.Endpoint(), &event.Target{
+				Create:  svc.create,
+				Replace: svc.replace,
+				Patch:   svc.patch,
+				Delete:  svc.delete,
+			})
 		}
-		ctx.logInitialized("group delete service")
+		ctx.eventReceiver = receiver
+		ctx.logInitialized("event receiver")
+	}
+	return ctx.eventReceiver
+}
+
+// decorateCreate decorates the service to push changes through the connector, if not nil, and to publish provisioning
+// events, if enabled.
+func (ctx *applicationContext) decorateCreate(svc service.Create, conn *connector.Connector) service.Create {
+	if conn != nil {
+		svc = connector.ConnectedCreate(svc, conn)
+	}
+	if ctx.args.PublishEnabled() {
+		svc = event.PublishingCreate(svc, ctx.EventPublisher())
+	}
+	return svc
+}
+
+// decorateReplace decorates the service to push changes through the connector, if not nil, and to publish provisioning
+// events, if enabled.
+func (ctx *applicationContext) decorateReplace(svc service.Replace, conn *connector.Connector) service.Replace {
+	if conn != nil {
+		svc = connector.ConnectedReplace(svc, conn)
+	}
+	if ctx.args.PublishEnabled() {
+		svc = event.PublishingReplace(svc, ctx.EventPublisher())
+	}
+	return svc
+}
+
+// decoratePatch decorates the service to push changes through the connector, if not nil, and to publish provisioning
+// events, if enabled.
+func (ctx *applicationContext) decoratePatch(svc service.Patch, conn *connector.Connector) service.Patch {
+	if conn != nil {
+		svc = connector.ConnectedPatch(svc, conn)
+	}
+	if ctx.args.PublishEnabled() {
+		svc = event.PublishingPatch(svc, ctx.EventPublisher())
+	}
+	return svc
+}
+
+// decorateDelete decorates the service to push changes through the connector, if not nil, and to publish provisioning
+// events, if enabled.
+func (ctx *applicationContext) decorateDelete(svc service.Delete, conn *connector.Connector) service.Delete {
+	if conn != nil {
+		svc = connector.ConnectedDelete(svc, conn)
 	}
-	return ctx.groupDeleteService
+	if ctx.args.PublishEnabled() {
+		svc = event.PublishingDelete(svc, ctx.EventPublisher())
+	}
+	return svc
 }
 
-func (ctx *applicationContext) UserGetService() service.Get {
-	if ctx.userGetService == nil {
-		ctx.userGetService = service.GetService(ctx.UserDatabase())
-		ctx.logInitialized("user get service")
+// UserConnector returns the connector that pushes User resources to the remote, or nil if not configured.
+func (ctx *applicationContext) UserConnector() *connector.Connector {
+	if ctx.userConnector == nil && ctx.args.Connector.Enabled() && len(ctx.args.UserMappingPath) > 0 {
+		mapping, err := ctx.args.ParseUserMapping()
+		if err != nil {
+			ctx.logInitFailure("user connector", err)
+			panic(err)
+		}
+		ctx.userConnector = ctx.newConnector(ctx.UserResourceType(), mapping)
+		ctx.logInitialized("user connector")
 	}
-	return ctx.userGetService
+	return ctx.userConnector
 }
 
-func (ctx *applicationContext) GroupGetService() service.Get {
-	if ctx.groupGetService == nil {
-		ctx.groupGetService = service.GetService(ctx.GroupDatabase())
-		ctx.logInitialized("group get service")
+// GroupConnector returns the connector that pushes Group resources to the remote, or nil if not configured. Members
+// are translated to the remote ids of the users and groups pushed to the remote.
+func (ctx *applicationContext) GroupConnector() *connector.Connector {
+	if ctx.groupConnector == nil && ctx.args.Connector.Enabled() && len(ctx.args.GroupMappingPath) > 0 {
+		mapping, err := ctx.args.ParseGroupMapping()
+		if err != nil {
+			ctx.logInitFailure("group connector", err)
+			panic(err)
+		}
+		ctx.groupConnector = ctx.newConnector(ctx.GroupResourceType(), mapping).ReferencesTo(ctx.UserConnector())
+		ctx.logInitialized("group connector")
 	}
-	return ctx.groupGetService
+	return ctx.groupConnector
 }
 
-func (ctx *applicationContext) UserQueryService() service.Query {
-	if ctx.userQueryService == nil {
-		ctx.userQueryService = service.QueryService(ctx.ServiceProviderConfig(), ctx.UserDatabase())
-		ctx.logInitialized("user query service")
+// newConnector returns a connector pushing the resources of the resource type according to the mapping. With MongoDB,
+// the ids of the remote resources are kept in a collection of the resource type, so that they survive restarts.
+func (ctx *applicationContext) newConnector(resourceType *spec.ResourceType, mapping *connector.Mapping) *connector.Connector {
+	if ctx.remote == nil {
+		ctx.remote = ctx.args.Connector.Remote()
 	}
-	return ctx.userQueryService
+	ids := connector.MemoryIDMap()
+	if !ctx.args.UseMemoryDB {
+		ids = scimmongo.IDMap(ctx.MongoClient().
+			Database(ctx.args.MongoDB.Database, options.Database()).
+			Collection(connectorIDMapCollection+"."+resourceType.Name(), options.Collection()))
+	}
+	return connector.New(ctx.remote, mapping, ids).
+		OnError(func(localID string, err error) {
+			ctx.Logger().Err(err).Fields(map[string]interface{}{
+				"localId":  localID,
+				"endpoint": mapping.Endpoint,
+			}).Msg("Failed to push change to remote")
+		})
 }
 
-func (ctx *applicationContext) GroupQueryService() service.Query {
-	if ctx.groupQueryService == nil {
-		ctx.groupQueryService = service.QueryService(ctx.ServiceProviderConfig(), ctx.GroupDatabase())
-		ctx.logInitialized("group query service")
+// StartReconciliation starts reconciling the configured connectors periodically, until the context is cancelled.
+func (ctx *applicationContext) StartReconciliation(c context.Context) {
+	if ctx.args.ReconcileInterval <= 0 {
+		return
+	}
+	for _, each := range []struct {
+		conn     *connector.Connector
+		database func() db.DB
+	}{
+		{conn: ctx.UserConnector(), database: ctx.UserDatabase},
+		{conn: ctx.GroupConnector(), database: ctx.GroupDatabase},
+	} {
+		if each.conn == nil {
+			continue
+		}
+		go each.conn.Run(c, each.database(), ctx.args.ReconcileInterval, func(report *connector.Report, err error) {
+			if err != nil {
+				ctx.Logger().Err(err).Msg("Failed to reconcile with remote")
+				return
+			}
+			ctx.Logger().Info().Fields(map[string]interface{}{
+				"upserted": report.Upserted,
+				"deleted":  report.Deleted,
+				"failed":   len(report.Failed),
+			}).Msg("Reconciled with remote")
+		})
 	}
-	return ctx.groupQueryService
 }
 
 func (ctx *applicationContext) RabbitMQConnection() *amqp.Connection {
+	if ctx.parent != nil {
+		return ctx.parent.RabbitMQConnection()
+	}
 	if ctx.rabbitMqConn == nil {
 		connectCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancelFunc()
@@ -343,13 +513,16 @@ func (ctx *applicationContext) RabbitMQConnection() *amqp.Connection {
 }
 
 func (ctx *applicationContext) RabbitMQChannel() *amqp.Channel {
+	if ctx.parent != nil {
+		return ctx.parent.RabbitMQChannel()
+	}
 	if ctx.rabbitMqChannel == nil {
 		c, err := ctx.RabbitMQConnection().Channel()
 		if err != nil {
 			ctx.logInitFailure("rabbit channel", err)
 			panic(err)
 		}
-		if err := groupsync.DeclareQueue(c); err != nil {
+		if err := gs.DeclareQueue(c); err != nil {
 			ctx.logInitFailure("rabbit channel", err)
 			panic(err)
 		}
@@ -360,6 +533,18 @@ func (ctx *applicationContext) RabbitMQChannel() *amqp.Channel {
 }
 
 func (ctx *applicationContext) Close() {
+	if ctx.userConnector != nil {
+		ctx.userConnector.Close()
+	}
+	if ctx.groupConnector != nil {
+		ctx.groupConnector.Close()
+	}
+	if ctx.eventPublisher != nil {
+		ctx.eventPublisher.Close()
+	}
+	for _, t := range ctx.tenants {
+		t.Close()
+	}
 	if ctx.mongoClient != nil {
 		_ = ctx.mongoClient.Disconnect(context.Background())
 	}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/encryption"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"time"
+)
+
+// Cipher returns the cipher encrypting attributes annotated with @Encrypted, with the keys from the key file. Each
+// tenant has its own cipher, with the keys from the key file of its definition.
+func (ctx *applicationContext) Cipher() *encryption.Cipher {
+	if ctx.cipher == nil {
+		keys, err := ctx.args.Encryption.KeyProvider()
+		if err != nil {
+			ctx.logInitFailure("encryption cipher", err)
+			panic(err)
+		}
+		ctx.cipher = encryption.NewCipher(keys)
+		ctx.logInitialized("encryption cipher")
+	}
+	return ctx.cipher
+}
+
+// encryptedDatabase returns a database encrypting the attributes of the resource type annotated with @Encrypted, and
+// rotates the keys of the resources stored in the database. If the resource type has no such attributes, the database
+// is returned as is.
+func (ctx *applicationContext) encryptedDatabase(resourceType *spec.ResourceType, database db.DB) db.DB {
+	if !encryption.Encrypts(resourceType) {
+		return database
+	}
+	ctx.rotateKeys(ctx.tenant, resourceType, database, ctx.Cipher())
+	return encryption.DB(database, resourceType, ctx.Cipher())
+}
+
+// rotateKeys registers the database of the resource type of the tenant for key rotation with the cipher of the tenant,
+// replacing the database previously registered, as resource types may be updated through the admin API. Rotations of
+// all tenants are run by the default application.
+func (ctx *applicationContext) rotateKeys(tenant string, resourceType *spec.ResourceType, database db.DB, cipher *encryption.Cipher) {
+	if ctx.parent != nil {
+		ctx.parent.rotateKeys(tenant, resourceType, database, cipher)
+		return
+	}
+
+	ctx.rotationsLock.Lock()
+	defer ctx.rotationsLock.Unlock()
+
+	if ctx.rotations == nil {
+		ctx.rotations = map[rotationKey]*rotation{}
+	}
+	ctx.rotations[rotationKey{tenant: tenant, resourceType: resourceType.ID()}] = &rotation{database: database, cipher: cipher}
+}
+
+type rotationKey struct {
+	tenant       string
+	resourceType string
+}
+
+type rotation struct {
+	database db.DB
+	cipher   *encryption.Cipher
+}
+
+// StartKeyRotation starts re-encrypting resources whose encrypted attributes were not encrypted with the current key
+// periodically, until the context is cancelled. Databases registered after the start are rotated from the next round.
+func (ctx *applicationContext) StartKeyRotation(c context.Context) {
+	if ctx.args.Encryption.RotateInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(ctx.args.Encryption.RotateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-ticker.C:
+				ctx.rotateAll(c)
+			}
+		}
+	}()
+}
+
+func (ctx *applicationContext) rotateAll(c context.Context) {
+	ctx.rotationsLock.Lock()
+	rotations := make(map[rotationKey]*rotation, len(ctx.rotations))
+	for k, v := range ctx.rotations {
+		rotations[k] = v
+	}
+	ctx.rotationsLock.Unlock()
+
+	for key, each := range rotations {
+		logger := ctx.Logger().With().Str("tenant", key.tenant).Str("resourceType", key.resourceType).Logger()
+		report, err := encryption.Rotate(c, each.database, each.cipher)
+		if err != nil {
+			logger.Err(err).Msg("Failed to rotate encryption keys")
+			continue
+		}
+		if report.Rotated == 0 && len(report.Failed) == 0 {
+			continue
+		}
+		logger.Info().Fields(map[string]interface{}{
+			"rotated": report.Rotated,
+			"failed":  len(report.Failed),
+		}).Msg("Rotated encryption keys")
+	}
+}
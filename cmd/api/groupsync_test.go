@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imulab/go-scim/cmd/internal/args"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMemoryApplication returns an application with in memory databases.
+func newMemoryApplication(t *testing.T) *applicationContext {
+	abs := func(path string) string {
+		p, err := filepath.Abs(path)
+		require.Nil(t, err)
+		return p
+	}
+	return &applicationContext{args: &arguments{
+		Scim: &args.Scim{
+			ServiceProviderConfigPath: abs("../../public/service_provider_config.json"),
+			UserResourceTypePath:      abs("../../public/resource_types/user_resource_type.json"),
+			GroupResourceTypePath:     abs("../../public/resource_types/group_resource_type.json"),
+			SchemasDirectory:          abs("../../public/schemas"),
+		},
+		MemoryDB:         &args.MemoryDB{UseMemoryDB: true},
+		MongoDB:          new(args.MongoDB),
+		RabbitMQ:         new(args.RabbitMQ),
+		Logging:          &args.Logging{Level: "ERROR"},
+		Event:            new(args.Event),
+		Connector:        new(args.Connector),
+		Encryption:       new(args.Encryption),
+		groupSyncWorkers: 2,
+	}}
+}
+
+// Group membership is synchronized in process with in memory databases, without RabbitMQ.
+func TestInProcessGroupSync(t *testing.T) {
+	app := newMemoryApplication(t)
+	defer app.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app.StartGroupSync(ctx)
+
+	user, err := app.ResourceServices(app.UserResourceType()).create.Do(ctx, &service.CreateRequest{
+		PayloadSource: strings.NewReader(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"alice","emails":[{"value":"alice@example.com"}]}`),
+	})
+	require.Nil(t, err)
+	userID := user.Resource.IdOrEmpty()
+
+	group, err := app.ResourceServices(app.GroupResourceType()).create.Do(ctx, &service.CreateRequest{
+		PayloadSource: strings.NewReader(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"displayName":"Admins","members":[{"value":"` + userID + `"}]}`),
+	})
+	require.Nil(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		synced, err := app.UserDatabase().Get(ctx, userID, nil)
+		require.Nil(t, err)
+		if groups := synced.Navigator().Dot("groups").Current(); groups.CountChildren() > 0 {
+			element, err := groups.ChildAtIndex(0)
+			require.Nil(t, err)
+			value, err := element.ChildAtIndex("value")
+			require.Nil(t, err)
+			assert.Equal(t, group.Resource.IdOrEmpty(), value.Raw())
+			break
+		}
+		if time.Now().After(deadline) {
+			require.FailNow(t, "groups of the user were never synchronized")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Effective members of groups include the members of nested groups, and can be searched.
+func TestEffectiveMembers(t *testing.T) {
+	app := newMemoryApplication(t)
+	defer app.Close()
+	router := app.Router()
+
+	create := func(endpoint string, body string) string {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(body)))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var created struct {
+			ID string `json:"id"`
+		}
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		return created.ID
+	}
+	search := func(path string) (int, []string) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		var result struct {
+			TotalResults int `json:"totalResults"`
+			Resources    []struct {
+				UserName string `json:"userName"`
+			} `json:"Resources"`
+		}
+		if rr.Code == http.StatusOK {
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &result))
+		}
+		var userNames []string
+		for _, each := range result.Resources {
+			userNames = append(userNames, each.UserName)
+		}
+		return rr.Code, userNames
+	}
+
+	var users []string
+	for _, userName := range []string{"alice", "bob", "carol"} {
+		users = append(users, create("/Users", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"`+userName+`","emails":[{"value":"`+userName+`@example.com"}]}`))
+	}
+	inner := create("/Groups", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"displayName":"Inner","members":[{"value":"`+users[1]+`"}]}`)
+	outer := create("/Groups", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"displayName":"Outer","members":[{"value":"`+users[0]+`"},{"value":"`+inner+`"}]}`)
+
+	code, userNames := search("/Groups/" + outer + "/effectiveMembers?sortBy=userName")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"alice", "bob"}, userNames)
+
+	code, userNames = search("/Groups/" + outer + "/effectiveMembers?filter=" + url.QueryEscape(`userName sw "b"`))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"bob"}, userNames)
+
+	// changes to nested groups are applied to the graph
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/Groups/"+inner, strings.NewReader(
+		`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"displayName":"Inner","members":[{"value":"`+users[1]+`"},{"value":"`+users[2]+`"}]}`)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	code, userNames = search("/Groups/" + outer + "/effectiveMembers?sortBy=userName")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"alice", "bob", "carol"}, userNames)
+
+	code, _ = search("/Groups/foo/effectiveMembers")
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
 	gojson "encoding/json"
 	"errors"
 	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/event"
+	"github.com/imulab/go-scim/pkg/v2/groupsync"
 	"github.com/imulab/go-scim/pkg/v2/handlerutil"
 	"github.com/imulab/go-scim/pkg/v2/json"
+	"github.com/imulab/go-scim/pkg/v2/openapi"
 	"github.com/imulab/go-scim/pkg/v2/service"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/julienschmidt/httprouter"
@@ -13,15 +19,24 @@ import (
 	"github.com/streadway/amqp"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 )
 
-// CreateHandler returns a route handler function for creating SCIM resources.
-func CreateHandler(svc service.Create, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+// ClientOptions returns the options to deserialize the payload of the request, selected for the client sending it.
+type ClientOptions func(r *http.Request) []json.DeserializeOptions
+
+// CreateHandler returns a route handler function for creating SCIM resources. The client options, if not nil, are
+// applied to the deserialization of the payload.
+func CreateHandler(svc service.Create, clientOptions ClientOptions, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	return func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		cr, closer := handlerutil.CreateRequest(r)
 		defer closer()
+		if clientOptions != nil {
+			cr.DeserializeOptions = clientOptions(r)
+		}
 
 		resp, err := svc.Do(r.Context(), cr)
 		if err != nil {
@@ -32,6 +47,7 @@ func CreateHandler(svc service.Create, log *zerolog.Logger) func(rw http.Respons
 			return
 		}
 
+		logWarnings(log, resp.Warnings)
 		log.Info().Msg("resource created")
 		rw.WriteHeader(201)
 		_ = handlerutil.WriteResourceToResponse(rw, resp.Resource)
@@ -60,9 +76,10 @@ func GetHandler(svc service.Get, log *zerolog.Logger) func(rw http.ResponseWrite
 			return
 		}
 
+		// The projection only applies to serialization: the complete resource is fetched, so that the ETag and
+		// Location headers are set regardless of the requested attributes.
 		resp, err := svc.Do(r.Context(), &service.GetRequest{
 			ResourceID: id,
-			Projection: projection,
 		})
 		if err != nil {
 			log.
@@ -112,8 +129,9 @@ func DeleteHandler(svc service.Delete, log *zerolog.Logger) func(rw http.Respons
 	}
 }
 
-// ReplaceHandler returns a route handler function for replacing SCIM resource.
-func ReplaceHandler(svc service.Replace, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+// ReplaceHandler returns a route handler function for replacing SCIM resource. The client options, if not nil, are
+// applied to the deserialization of the payload.
+func ReplaceHandler(svc service.Replace, clientOptions ClientOptions, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 		id := params.ByName("id")
 		if len(id) == 0 {
@@ -128,7 +146,12 @@ func ReplaceHandler(svc service.Replace, log *zerolog.Logger) func(rw http.Respo
 		reqFunc, closer := handlerutil.ReplaceRequest(r)
 		defer closer()
 
-		resp, err := svc.Do(r.Context(), reqFunc(id))
+		req := reqFunc(id)
+		if clientOptions != nil {
+			req.DeserializeOptions = clientOptions(r)
+		}
+
+		resp, err := svc.Do(r.Context(), req)
 		if err != nil {
 			log.
 				Err(err).
@@ -136,6 +159,7 @@ func ReplaceHandler(svc service.Replace, log *zerolog.Logger) func(rw http.Respo
 			_ = handlerutil.WriteError(rw, err)
 			return
 		}
+		logWarnings(log, resp.Warnings)
 
 		if !resp.Replaced {
 			rw.WriteHeader(204)
@@ -146,8 +170,9 @@ func ReplaceHandler(svc service.Replace, log *zerolog.Logger) func(rw http.Respo
 	}
 }
 
-// PatchHandler returns a route handler function for patching SCIM resource.
-func PatchHandler(svc service.Patch, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+// PatchHandler returns a route handler function for patching SCIM resource. The client options, if not nil, are
+// applied to the deserialization of the payload.
+func PatchHandler(svc service.Patch, clientOptions ClientOptions, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 		id := params.ByName("id")
 		if len(id) == 0 {
@@ -162,7 +187,12 @@ func PatchHandler(svc service.Patch, log *zerolog.Logger) func(rw http.ResponseW
 		reqFunc, closer := handlerutil.PatchRequest(r)
 		defer closer()
 
-		resp, err := svc.Do(r.Context(), reqFunc(id))
+		req := reqFunc(id)
+		if clientOptions != nil {
+			req.DeserializeOptions = clientOptions(r)
+		}
+
+		resp, err := svc.Do(r.Context(), req)
 		if err != nil {
 			log.
 				Err(err).
@@ -170,6 +200,7 @@ func PatchHandler(svc service.Patch, log *zerolog.Logger) func(rw http.ResponseW
 			_ = handlerutil.WriteError(rw, err)
 			return
 		}
+		logWarnings(log, resp.Warnings)
 
 		if !resp.Patched {
 			rw.WriteHeader(204)
@@ -180,6 +211,13 @@ func PatchHandler(svc service.Patch, log *zerolog.Logger) func(rw http.ResponseW
 	}
 }
 
+// logWarnings logs the problems in the request payload tolerated by deserialization.
+func logWarnings(log *zerolog.Logger, warnings []*json.Warning) {
+	for _, each := range warnings {
+		log.Warn().Str("path", each.Path).Msg(each.Message)
+	}
+}
+
 // SearchHandler returns a route handler function for searching SCIM resources. This handler could be used in HTTP GET and
 // HTTP POST scenarios, as defined in the SCIM specification.
 func SearchHandler(svc service.Query, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
@@ -234,6 +272,15 @@ func SearchHandler(svc service.Query, log *zerolog.Logger) func(rw http.Response
 	}
 }
 
+// EffectiveMembersHandler returns a route handler function for searching the users that are effective members of the
+// group, directly or through nested groups. The search accepts the same parameters as SearchHandler, over HTTP GET.
+func EffectiveMembersHandler(config *spec.ServiceProviderConfig, userDB db.DB, graph *groupsync.MembershipGraph, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		svc := service.QueryService(config, groupsync.EffectiveMembersDB(userDB, graph, params.ByName("id")))
+		SearchHandler(svc, log)(rw, r, params)
+	}
+}
+
 // ServiceProviderConfigHandler returns a http route handler to write service provider config info.
 func ServiceProviderConfigHandler(config *spec.ServiceProviderConfig) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	raw, err := gojson.Marshal(config)
@@ -271,6 +318,39 @@ func ResourceTypesHandler(resourceTypes ...*spec.ResourceType) func(rw http.Resp
 	}
 }
 
+// OpenAPIHandler returns a route handler function for getting the OpenAPI document describing the resource types. The
+// server of the document is the path the document is requested from, without the trailing /openapi.json, so that
+// paths resolve against the path prefix of the tenant serving the document.
+func OpenAPIHandler(resourceTypes ...*spec.ResourceType) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	doc := openapi.Document(openapi.Info{
+		Title:   "SCIM",
+		Version: "2.0",
+	}, resourceTypes...)
+
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		// the request URI keeps the path prefix stripped by the tenant router
+		server := strings.TrimSuffix(strings.SplitN(r.RequestURI, "?", 2)[0], "/openapi.json")
+		if len(server) == 0 {
+			server = "/"
+		}
+
+		withServer := make(map[string]interface{}, len(doc)+1)
+		for k, v := range doc {
+			withServer[k] = v
+		}
+		withServer["servers"] = []interface{}{map[string]interface{}{"url": server}}
+
+		raw, err := gojson.Marshal(withServer)
+		if err != nil {
+			_ = handlerutil.WriteError(rw, fmt.Errorf("%w: %s", spec.ErrInternal, err.Error()))
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(raw)
+	}
+}
+
 // ResourceTypeByIdHandler returns a route handler function get ResourceType by its id.
 func ResourceTypeByIdHandler(resourceTypes ...*spec.ResourceType) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	cache := map[string]gojson.RawMessage{}
@@ -294,10 +374,10 @@ func ResourceTypeByIdHandler(resourceTypes ...*spec.ResourceType) func(rw http.R
 	}
 }
 
-// SchemasHandler returns a route handler function for getting all defined Schema.
-func SchemasHandler() func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+// SchemasHandler returns a route handler function for getting all Schema defined in the registry.
+func SchemasHandler(registry *spec.SchemaRegistry) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	result := &service.QueryResponse{StartIndex: 1, Resources: []json.Serializable{}}
-	if err := spec.Schemas().ForEachSchema(func(schema *spec.Schema) error {
+	if err := registry.ForEachSchema(func(schema *spec.Schema) error {
 		if schema.ID() == spec.CoreSchemaId {
 			return nil
 		}
@@ -321,10 +401,10 @@ func SchemasHandler() func(rw http.ResponseWriter, r *http.Request, params httpr
 	}
 }
 
-// SchemaByIdHandler returns a route handler function get Schema by its id.
-func SchemaByIdHandler() func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+// SchemaByIdHandler returns a route handler function get Schema defined in the registry by its id.
+func SchemaByIdHandler(registry *spec.SchemaRegistry) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	cache := map[string]gojson.RawMessage{}
-	if err := spec.Schemas().ForEachSchema(func(schema *spec.Schema) error {
+	if err := registry.ForEachSchema(func(schema *spec.Schema) error {
 		if schema.ID() == spec.CoreSchemaId {
 			return nil
 		}
@@ -352,17 +432,50 @@ func SchemaByIdHandler() func(rw http.ResponseWriter, r *http.Request, params ht
 	}
 }
 
-// HealthHandler returns a http handler to report service health status.
+// EventReceiverHandler returns a route handler function for receiving SCIM provisioning events delivered as Security
+// Event Tokens using push-based delivery (RFC8935).
+func EventReceiverHandler(receiver *event.Receiver, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	return func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		raw, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.
+				Err(err).
+				Msg("error reading provisioning event")
+			_ = event.WriteDeliveryError(rw, fmt.Errorf("%w: %s", event.ErrInvalidRequest, err.Error()))
+			return
+		}
+
+		token, err := receiver.Receive(r.Context(), string(raw))
+		if err != nil {
+			log.
+				Err(err).
+				Msg("error when receiving provisioning event")
+			_ = event.WriteDeliveryError(rw, err)
+			return
+		}
+
+		log.Info().Fields(map[string]interface{}{
+			"jti": token.ID,
+			"iss": token.Issuer,
+		}).Msg("provisioning event received")
+		rw.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// HealthHandler returns a http handler to report service health status. A nil MongoDB client or RabbitMQ connection
+// is not in use, as with in memory databases, and is reported as such.
 func HealthHandler(mongoClient *mongo.Client, rabbitConn *amqp.Connection) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
 		var (
-			mongoUp  = mongoClient.Ping(r.Context(), readpref.Primary()) == nil
-			rabbitUp = !rabbitConn.IsClosed()
+			mongoUp  = mongoClient == nil || mongoClient.Ping(r.Context(), readpref.Primary()) == nil
+			rabbitUp = rabbitConn == nil || !rabbitConn.IsClosed()
 			overalUp = mongoUp && rabbitUp
 		)
 
-		status := func(r bool) string {
-			if r {
+		status := func(inUse bool, r bool) string {
+			if !inUse {
+				return "unused"
+			} else if r {
 				return "up"
 			} else {
 				return "down"
@@ -375,9 +488,111 @@ func HealthHandler(mongoClient *mongo.Client, rabbitConn *amqp.Connection) func(
 			rw.WriteHeader(500)
 		}
 		_ = gojson.NewEncoder(rw).Encode(map[string]string{
-			"service_status":      status(overalUp),
-			"mongodb_connection":  status(mongoUp),
-			"rabbitmq_connection": status(rabbitUp),
+			"service_status":      status(true, overalUp),
+			"mongodb_connection":  status(mongoClient != nil, mongoUp),
+			"rabbitmq_connection": status(rabbitConn != nil, rabbitUp),
 		})
 	}
 }
+
+// AdminAuth returns a route handler function that only passes requests bearing the token to the handler, and responds
+// 401 otherwise.
+func AdminAuth(token string, handler httprouter.Handle) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler(rw, r, params)
+	}
+}
+
+// SchemaAdminHandler returns a route handler function that registers or updates the Schema defined in the request body,
+// and responds the Schema with the status. On update, the id in path must match the id of the definition.
+func SchemaAdminHandler(apply func(ctx context.Context, raw []byte) (*spec.Schema, error), status int, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	return definitionAdminHandler(func(ctx context.Context, raw []byte) (json.Serializable, error) {
+		schema, err := apply(ctx, raw)
+		if err != nil {
+			return nil, err
+		}
+		return json.SchemaToSerializable(schema), nil
+	}, status, log)
+}
+
+// ResourceTypeAdminHandler returns a route handler function that registers or updates the ResourceType defined in the
+// request body, and responds the ResourceType with the status. On update, the id in path must match the id of the
+// definition.
+func ResourceTypeAdminHandler(apply func(ctx context.Context, raw []byte) (*spec.ResourceType, error), status int, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	return definitionAdminHandler(func(ctx context.Context, raw []byte) (json.Serializable, error) {
+		resourceType, err := apply(ctx, raw)
+		if err != nil {
+			return nil, err
+		}
+		return json.ResourceTypeToSerializable(resourceType), nil
+	}, status, log)
+}
+
+func definitionAdminHandler(apply func(ctx context.Context, raw []byte) (json.Serializable, error), status int, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		raw, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.
+				Err(err).
+				Msg("error reading definition")
+			_ = handlerutil.WriteError(rw, fmt.Errorf("%w: %s", spec.ErrInvalidSyntax, err.Error()))
+			return
+		}
+
+		if id := params.ByName("id"); len(id) > 0 {
+			var probe struct {
+				ID string `json:"id"`
+			}
+			if err := gojson.Unmarshal(raw, &probe); err != nil {
+				_ = handlerutil.WriteError(rw, fmt.Errorf("%w: malformed definition", spec.ErrInvalidSyntax))
+				return
+			}
+			if probe.ID != id {
+				_ = handlerutil.WriteError(rw, fmt.Errorf("%w: id '%s' does not match the path", spec.ErrInvalidValue, probe.ID))
+				return
+			}
+		}
+
+		result, err := apply(r.Context(), raw)
+		if err != nil {
+			log.
+				Err(err).
+				Msg("error when applying definition")
+			_ = handlerutil.WriteError(rw, err)
+			return
+		}
+
+		out, err := json.Serialize(result)
+		if err != nil {
+			_ = handlerutil.WriteError(rw, err)
+			return
+		}
+
+		log.Info().Msg("definition applied")
+		rw.Header().Set("Content-Type", spec.ApplicationScimJson)
+		rw.WriteHeader(status)
+		_, _ = rw.Write(out)
+	}
+}
+
+// RetireAdminHandler returns a route handler function that retires the Schema or ResourceType by the id in path.
+func RetireAdminHandler(retire func(ctx context.Context, id string) error, log *zerolog.Logger) func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if err := retire(r.Context(), params.ByName("id")); err != nil {
+			log.
+				Err(err).
+				Msg("error when retiring definition")
+			_ = handlerutil.WriteError(rw, err)
+			return
+		}
+
+		log.Info().Msg("definition retired")
+		rw.WriteHeader(204)
+	}
+}
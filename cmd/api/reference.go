@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/imulab/go-scim/pkg/v2/integrity"
+)
+
+// References returns the registry of the resource types that can be referenced by the resources of the application,
+// or the tenant. Resource types are registered as their services are created, including the new instances produced by
+// the admin API.
+func (ctx *applicationContext) References() *integrity.Registry {
+	if ctx.references == nil {
+		// The registry is set before resource types are registered, as the services registered with it filter
+		// references against it.
+		ctx.references = integrity.NewRegistry()
+		for _, each := range ctx.ResourceTypes() {
+			ctx.ResourceServices(each)
+		}
+		ctx.logInitialized("reference registry")
+	}
+	return ctx.references
+}
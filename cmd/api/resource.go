@@ -0,0 +1,524 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	scimmongo "github.com/imulab/go-scim/mongo/v2"
+	"github.com/imulab/go-scim/pkg/v2/connector"
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/integrity"
+	scimjson "github.com/imulab/go-scim/pkg/v2/json"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Names of filters available to pipelines.
+const (
+	filterReadOnly     = "readOnly"
+	filterUUID         = "uuid"
+	filterDefault      = "default"
+	filterNormalize    = "normalize"
+	filterBCrypt       = "bcrypt"
+	filterPasswordHash = "passwordHash"
+	filterComputed     = "computed"
+	filterReference    = "reference"
+	filterMeta         = "meta"
+	filterValidation   = "validation"
+)
+
+// Names of coercions available to pipelines.
+var coercions = map[string]scimjson.Coercion{
+	"booleanString": scimjson.CoerceBooleanString,
+	"numberString":  scimjson.CoerceNumberString,
+	"singleValue":   scimjson.CoerceSingleValue,
+	"schemaCase":    scimjson.CoerceSchemaCase,
+}
+
+// Names of databases available to pipelines.
+const (
+	databaseMemory  = "memory"
+	databaseMongoDB = "mongodb"
+)
+
+// pipelineConfig is the declarative configuration of how resources of a resource type are served. It is read from the
+// "_pipeline" field of the resource type JSON definition, including those of the user and group resource types, for
+// example:
+//	{
+//		"id": "Device",
+//		"name": "Device",
+//		"endpoint": "/Devices",
+//		"schema": "urn:imulab:scim:schemas:core:2.0:Device",
+//		"_pipeline": {
+//			"database": "mongodb",
+//			"create": ["readOnly", "uuid", "default", "normalize", "computed", "reference", "meta", "validation"],
+//			"replace": ["readOnly", "normalize", "computed", "reference", "validation", "meta"],
+//			"patch": ["readOnly", "normalize", "computed", "reference", "validation", "meta"],
+//			"lenient": true,
+//			"coerce": ["booleanString", "singleValue"],
+//			"clients": [
+//				{"userAgent": "Okta", "coerce": ["booleanString"]},
+//				{"userAgent": "Azure", "lenient": false}
+//			]
+//		}
+//	}
+//
+// Filters are chosen among readOnly, uuid, default, normalize, bcrypt, passwordHash, computed, reference, meta and
+// validation, and run in the listed order.
+// Database is either memory or mongodb, and defaults to the database of the application. Omitted fields take the
+// default values, which are the ones shown above, save for the database, lenient, coerce and clients. User resource
+// types additionally default to the bcrypt and passwordHash filters. Lenient drops unknown attributes from payloads
+// instead of rejecting them, and coerce is chosen among booleanString, numberString, singleValue and schemaCase, to
+// accept values known to be sent by some identity providers. See json.Lenient and json.Coerce.
+// Clients select options for the requests whose User-Agent header contains the userAgent, ignoring case. The first
+// matching client applies: its lenient, if set, overrides the one of the resource type, and its coercions are added to
+// those of the resource type.
+type pipelineConfig struct {
+	Database string          `json:"database"`
+	Create   []string        `json:"create"`
+	Replace  []string        `json:"replace"`
+	Patch    []string        `json:"patch"`
+	Lenient  bool            `json:"lenient"`
+	Coerce   []string        `json:"coerce"`
+	Clients  []*clientConfig `json:"clients"`
+}
+
+// clientConfig is the configuration of the deserialization options for the requests of a client.
+type clientConfig struct {
+	UserAgent string   `json:"userAgent"`
+	Lenient   *bool    `json:"lenient"`
+	Coerce    []string `json:"coerce"`
+}
+
+// defaultPipeline returns the pipeline for resource types without a pipeline configuration. User resource types
+// additionally hash passwords annotated with @BCrypt or @PasswordHash.
+func defaultPipeline(isUser bool) *pipelineConfig {
+	p := &pipelineConfig{
+		Create:  []string{filterReadOnly, filterUUID, filterDefault, filterNormalize, filterComputed, filterReference, filterMeta, filterValidation},
+		Replace: []string{filterReadOnly, filterNormalize, filterComputed, filterReference, filterValidation, filterMeta},
+		Patch:   []string{filterReadOnly, filterNormalize, filterComputed, filterReference, filterValidation, filterMeta},
+	}
+	if isUser {
+		p.Create = []string{filterReadOnly, filterUUID, filterDefault, filterNormalize, filterBCrypt, filterPasswordHash, filterComputed, filterReference, filterMeta, filterValidation}
+		p.Replace = []string{filterReadOnly, filterNormalize, filterBCrypt, filterPasswordHash, filterComputed, filterReference, filterValidation, filterMeta}
+		p.Patch = []string{filterReadOnly, filterNormalize, filterBCrypt, filterPasswordHash, filterComputed, filterReference, filterValidation, filterMeta}
+	}
+	return p
+}
+
+// parsePipeline returns the pipeline in the "_pipeline" field of the raw resource type JSON definition, validated and
+// filled with the default values for the resource type, or the default pipeline if there is no such field.
+func parsePipeline(raw []byte, resourceTypeId string, isUser bool) (*pipelineConfig, error) {
+	p := new(struct {
+		Pipeline *pipelineConfig `json:"_pipeline"`
+	})
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, err
+	}
+	if p.Pipeline == nil {
+		return defaultPipeline(isUser), nil
+	}
+	if err := p.Pipeline.validate(resourceTypeId, defaultPipeline(isUser)); err != nil {
+		return nil, err
+	}
+	return p.Pipeline, nil
+}
+
+// readPipeline returns the pipeline of the resource type JSON definition in the file, see parsePipeline.
+func readPipeline(path string, resourceTypeId string, isUser bool) (*pipelineConfig, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePipeline(raw, resourceTypeId, isUser)
+}
+
+// validate checks the names of the database, filters and coercions, and fills omitted fields with the default values.
+func (p *pipelineConfig) validate(resourceTypeId string, defaults *pipelineConfig) error {
+	switch p.Database {
+	case "", databaseMemory, databaseMongoDB:
+	default:
+		return fmt.Errorf("unknown database '%s' in pipeline of resource type '%s'", p.Database, resourceTypeId)
+	}
+
+	for _, each := range []struct {
+		filters      *[]string
+		defaultValue []string
+	}{
+		{filters: &p.Create, defaultValue: defaults.Create},
+		{filters: &p.Replace, defaultValue: defaults.Replace},
+		{filters: &p.Patch, defaultValue: defaults.Patch},
+	} {
+		if *each.filters == nil {
+			*each.filters = each.defaultValue
+			continue
+		}
+		for _, name := range *each.filters {
+			switch name {
+			case filterReadOnly, filterUUID, filterDefault, filterNormalize, filterBCrypt, filterPasswordHash, filterComputed,
+				filterReference, filterMeta, filterValidation:
+			default:
+				return fmt.Errorf("unknown filter '%s' in pipeline of resource type '%s'", name, resourceTypeId)
+			}
+		}
+	}
+
+	coerce := append([]string{}, p.Coerce...)
+	for _, client := range p.Clients {
+		if client == nil || len(client.UserAgent) == 0 {
+			return fmt.Errorf("client without userAgent in pipeline of resource type '%s'", resourceTypeId)
+		}
+		coerce = append(coerce, client.Coerce...)
+	}
+	for _, name := range coerce {
+		if _, ok := coercions[name]; !ok {
+			return fmt.Errorf("unknown coercion '%s' in pipeline of resource type '%s'", name, resourceTypeId)
+		}
+	}
+	return nil
+}
+
+// deserializeOptions returns the options to deserialize payloads of create, replace and patch requests.
+func (p *pipelineConfig) deserializeOptions() []scimjson.DeserializeOptions {
+	var options []scimjson.DeserializeOptions
+	if p.Lenient {
+		options = append(options, scimjson.Lenient())
+	}
+	return append(options, coerceOptions(p.Coerce)...)
+}
+
+// clientOptions returns the options to deserialize payloads of create, replace and patch requests from the client
+// sending the request, which apply after those of the resource type, or nil if no client matches.
+func (p *pipelineConfig) clientOptions(r *http.Request) []scimjson.DeserializeOptions {
+	userAgent := strings.ToLower(r.UserAgent())
+	for _, client := range p.Clients {
+		if !strings.Contains(userAgent, strings.ToLower(client.UserAgent)) {
+			continue
+		}
+		var options []scimjson.DeserializeOptions
+		if client.Lenient != nil {
+			if *client.Lenient {
+				options = append(options, scimjson.Lenient())
+			} else {
+				options = append(options, scimjson.Strict())
+			}
+		}
+		return append(options, coerceOptions(client.Coerce)...)
+	}
+	return nil
+}
+
+// coerceOptions returns the option to apply the coercions by the names, if any.
+func coerceOptions(names []string) []scimjson.DeserializeOptions {
+	if len(names) == 0 {
+		return nil
+	}
+	var rules []scimjson.Coercion
+	for _, name := range names {
+		rules = append(rules, coercions[name])
+	}
+	return []scimjson.DeserializeOptions{scimjson.Coerce(rules...)}
+}
+
+// buildFilters returns the filters by the names, against the database and the registry of referenced resource types.
+// Consecutive readOnly, uuid, default, normalize, bcrypt and passwordHash filters are run in a single traversal of the
+// resource. The computed filter runs in a traversal of its own, so templates see the values left by the filters
+// before it, and so does the reference filter, which looks up the referenced resources.
+func buildFilters(names []string, database db.DB, references *integrity.Registry) []filter.ByResource {
+	var (
+		filters    = make([]filter.ByResource, 0)
+		byProperty []filter.ByProperty
+	)
+	flush := func() {
+		if len(byProperty) > 0 {
+			filters = append(filters, filter.ByPropertyToByResource(byProperty...))
+			byProperty = nil
+		}
+	}
+
+	for _, name := range names {
+		switch name {
+		case filterReadOnly:
+			byProperty = append(byProperty, filter.ReadOnlyFilter())
+		case filterUUID:
+			byProperty = append(byProperty, filter.UUIDFilter())
+		case filterDefault:
+			byProperty = append(byProperty, filter.DefaultFilter())
+		case filterNormalize:
+			byProperty = append(byProperty, filter.NormalizeFilter())
+		case filterBCrypt:
+			byProperty = append(byProperty, filter.BCryptFilter())
+		case filterPasswordHash:
+			byProperty = append(byProperty, filter.PasswordHashFilter())
+		case filterComputed:
+			flush()
+			filters = append(filters, filter.ByPropertyToByResource(filter.ComputedFilter()))
+		case filterReference:
+			flush()
+			filters = append(filters, filter.ByPropertyToByResource(integrity.ReferenceFilter(references)))
+		case filterMeta:
+			flush()
+			filters = append(filters, filter.MetaFilter())
+		case filterValidation:
+			flush()
+			filters = append(filters, filter.ByPropertyToByResource(filter.ValidationFilter(database)))
+		}
+	}
+	flush()
+
+	return filters
+}
+
+// configuredResourceType is a resource type read from the resource types directory, with its pipeline.
+type configuredResourceType struct {
+	resourceType *spec.ResourceType
+	pipeline     *pipelineConfig
+}
+
+// parseResourceTypesDir reads all JSON resource type definitions in the directory, sorted by file name, and parses
+// them against the registry. Resource types must have unique ids and endpoints, including the existing ones.
+func parseResourceTypesDir(dir string, registry *spec.SchemaRegistry, existing ...*spec.ResourceType) ([]*configuredResourceType, error) {
+	var paths []string
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+			paths = append(paths, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var (
+		result    []*configuredResourceType
+		ids       = map[string]bool{}
+		endpoints = map[string]bool{}
+	)
+	for _, each := range existing {
+		ids[each.ID()] = true
+		endpoints[strings.ToLower(each.Endpoint())] = true
+	}
+	for _, path := range paths {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		resourceType, err := registry.ParseResourceType(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource type in '%s': %s", path, err.Error())
+		}
+		if ids[resourceType.ID()] {
+			return nil, fmt.Errorf("duplicate resource type id '%s' in '%s'", resourceType.ID(), path)
+		}
+		ids[resourceType.ID()] = true
+		if endpoints[strings.ToLower(resourceType.Endpoint())] {
+			return nil, fmt.Errorf("duplicate endpoint '%s' in '%s'", resourceType.Endpoint(), path)
+		}
+		endpoints[strings.ToLower(resourceType.Endpoint())] = true
+
+		pipeline, err := parsePipeline(raw, resourceType.ID(), false)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &configuredResourceType{resourceType: resourceType, pipeline: pipeline})
+	}
+
+	return result, nil
+}
+
+// ResourceTypes returns the user and group resource types, followed by the resource types in the resource types
+// directory, if configured. The pipelines in their definitions are kept for pipelineFor.
+func (ctx *applicationContext) ResourceTypes() []*spec.ResourceType {
+	if ctx.resourceTypes == nil {
+		resourceTypes := []*spec.ResourceType{ctx.UserResourceType(), ctx.GroupResourceType()}
+		ctx.pipelines = map[string]*pipelineConfig{}
+		for _, each := range []struct {
+			resourceType *spec.ResourceType
+			path         string
+		}{
+			{resourceType: ctx.UserResourceType(), path: ctx.args.UserResourceTypePath},
+			{resourceType: ctx.GroupResourceType(), path: ctx.args.GroupResourceTypePath},
+		} {
+			pipeline, err := readPipeline(each.path, each.resourceType.ID(), each.resourceType == ctx.UserResourceType())
+			if err != nil {
+				ctx.logInitFailure("resource types", err)
+				panic(err)
+			}
+			ctx.pipelines[each.resourceType.ID()] = pipeline
+		}
+		if len(ctx.args.resourceTypesDir) > 0 {
+			configured, err := parseResourceTypesDir(ctx.args.resourceTypesDir, ctx.args.SchemaRegistry(), resourceTypes...)
+			if err != nil {
+				ctx.logInitFailure("resource types", err)
+				panic(err)
+			}
+			for _, each := range configured {
+				resourceTypes = append(resourceTypes, each.resourceType)
+				ctx.pipelines[each.resourceType.ID()] = each.pipeline
+			}
+		}
+		for _, each := range resourceTypes {
+			crud.Register(each)
+		}
+		ctx.resourceTypes = resourceTypes
+		ctx.logInitialized("resource types")
+	}
+	return ctx.resourceTypes
+}
+
+// isStartupResourceType returns true if the resource type is one of the instances loaded at startup.
+func (ctx *applicationContext) isStartupResourceType(resourceType *spec.ResourceType) bool {
+	for _, each := range ctx.ResourceTypes() {
+		if each == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceServices are the services serving the endpoint of a resource type.
+type resourceServices struct {
+	// options to deserialize the payloads of the client sending the request
+	clientOptions ClientOptions
+	create        service.Create
+	replace       service.Replace
+	patch         service.Patch
+	delete        service.Delete
+	get           service.Get
+	query         service.Query
+}
+
+// ResourceServices returns the services for the resource type, created for it according to its pipeline on first use.
+// This includes the user and group resource types loaded at startup, and the new instances of resource types produced
+// by the admin API.
+func (ctx *applicationContext) ResourceServices(resourceType *spec.ResourceType) *resourceServices {
+	// Resource services filter references against the registry, whose initialization creates resource services.
+	references := ctx.References()
+
+	ctx.resourceServicesLock.Lock()
+	defer ctx.resourceServicesLock.Unlock()
+
+	if ctx.resourceServices == nil {
+		ctx.resourceServices = map[*spec.ResourceType]*resourceServices{}
+	}
+	if svc, ok := ctx.resourceServices[resourceType]; ok {
+		return svc
+	}
+
+	svc := ctx.newResourceServices(resourceType, references)
+	ctx.resourceServices[resourceType] = svc
+	ctx.Logger().Info().Fields(map[string]interface{}{
+		"resourceType": resourceType.ID(),
+		"endpoint":     resourceType.Endpoint(),
+	}).Msg("resource type services initialized")
+	return svc
+}
+
+// newResourceServices creates services for the resource type according to its pipeline, and registers the resource
+// type to the registry of referenced resource types. Resource types sharing the id of the group resource type also
+// put group sync jobs in the outbox, and those sharing the id of the user or group resource type push changes through
+// the user or group connector.
+func (ctx *applicationContext) newResourceServices(resourceType *spec.ResourceType, references *integrity.Registry) *resourceServices {
+	var (
+		pipeline = ctx.pipelineFor(resourceType)
+		database = ctx.resourceDatabase(resourceType, pipeline.Database)
+		options  = pipeline.deserializeOptions()
+		writes   = database
+		conn     *connector.Connector
+	)
+	switch resourceType.ID() {
+	case ctx.UserResourceType().ID():
+		conn = ctx.UserConnector()
+	case ctx.GroupResourceType().ID():
+		conn = ctx.GroupConnector()
+		writes = ctx.groupSyncDatabase(database)
+	}
+
+	var (
+		create  service.Create  = service.CreateService(resourceType, writes, buildFilters(pipeline.Create, database, references), options...)
+		replace service.Replace = service.ReplaceService(ctx.ServiceProviderConfig(), resourceType, writes, buildFilters(pipeline.Replace, database, references), options...)
+		patch   service.Patch   = service.PatchService(ctx.ServiceProviderConfig(), writes, []filter.ByResource{}, buildFilters(pipeline.Patch, database, references), options...)
+		del     service.Delete  = integrity.Delete(ctx.ServiceProviderConfig(), resourceType, database, references, service.DeleteService(ctx.ServiceProviderConfig(), writes))
+	)
+
+	svc := &resourceServices{
+		clientOptions: pipeline.clientOptions,
+		create:        ctx.decorateCreate(create, conn),
+		replace:       ctx.decorateReplace(replace, conn),
+		patch:         ctx.decoratePatch(patch, conn),
+		delete:        ctx.decorateDelete(del, conn),
+		get:           service.GetService(database),
+		query:         service.QueryService(ctx.ServiceProviderConfig(), database),
+	}
+	references.Register(resourceType, database, svc.patch)
+	return svc
+}
+
+// pipelineFor returns the pipeline configured in the definition of the resource type loaded at startup with the same
+// id, or the default pipeline.
+func (ctx *applicationContext) pipelineFor(resourceType *spec.ResourceType) *pipelineConfig {
+	ctx.ResourceTypes()
+	if p, ok := ctx.pipelines[resourceType.ID()]; ok {
+		return p
+	}
+	return defaultPipeline(resourceType.ID() == ctx.UserResourceType().ID())
+}
+
+// resourceDatabase returns the database of the kind for the resource type, where an empty kind selects the database
+// of the application. In memory databases are shared by all instances of resource types with the same id, so
+// resources survive updates to the resource type. MongoDB databases are bound to the resource type instance, and store
+// resources in the collection named after the resource type, save for the user and group resource types loaded at
+// startup, which are stored in the user and group databases. Failing to verify the indexes of the collection fails the
+// startup for the resource types loaded at startup, and is logged for the instances produced by the admin API.
+func (ctx *applicationContext) resourceDatabase(resourceType *spec.ResourceType, kind string) db.DB {
+	if kind == databaseMemory || (len(kind) == 0 && ctx.args.UseMemoryDB) {
+		if ctx.args.UseMemoryDB {
+			switch resourceType.ID() {
+			case ctx.UserResourceType().ID():
+				return ctx.UserDatabase()
+			case ctx.GroupResourceType().ID():
+				return ctx.GroupDatabase()
+			}
+		}
+		if ctx.memoryDatabases == nil {
+			ctx.memoryDatabases = map[string]db.DB{}
+		}
+		if _, ok := ctx.memoryDatabases[resourceType.ID()]; !ok {
+			ctx.memoryDatabases[resourceType.ID()] = db.Memory()
+		}
+		return ctx.encryptedDatabase(resourceType, ctx.memoryDatabases[resourceType.ID()])
+	}
+
+	switch resourceType {
+	case ctx.UserResourceType():
+		return ctx.UserDatabase()
+	case ctx.GroupResourceType():
+		return ctx.GroupDatabase()
+	}
+
+	ctx.ensureMongoMetadata()
+	collection := ctx.MongoClient().
+		Database(ctx.args.MongoDB.Database, options.Database()).
+		Collection(resourceType.Name(), options.Collection())
+	if err := ctx.verifyMongoIndexes(resourceType, collection); err != nil {
+		if ctx.isStartupResourceType(resourceType) {
+			ctx.logInitFailure("mongo "+resourceType.ID()+" indexes", err)
+			panic(err)
+		}
+		ctx.Logger().Err(err).Str("resourceType", resourceType.ID()).Msg("Failed to verify indexes")
+	}
+	return ctx.encryptedDatabase(resourceType, scimmongo.DB(resourceType, collection, scimmongo.Options().Metadata(ctx.args.MongoDB.MetadataRegistry())))
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/cmd/internal/args"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResourceTypes(t *testing.T) {
+	s := new(ResourceTypesTestSuite)
+	suite.Run(t, s)
+}
+
+type ResourceTypesTestSuite struct {
+	suite.Suite
+	dir      string
+	registry *spec.SchemaRegistry
+}
+
+const testApplication = `{
+	"id": "urn:imulab:scim:schemas:core:2.0:Application",
+	"name": "Application",
+	"attributes": [
+		{
+			"id": "urn:imulab:scim:schemas:core:2.0:Application:displayName",
+			"name": "displayName",
+			"type": "string",
+			"required": true,
+			"uniqueness": "server",
+			"_index": 0,
+			"_path": "displayName"
+		},
+		{
+			"id": "urn:imulab:scim:schemas:core:2.0:Application:password",
+			"name": "password",
+			"type": "string",
+			"returned": "never",
+			"mutability": "writeOnly",
+			"_index": 1,
+			"_path": "password",
+			"_annotations": {
+				"@BCrypt": {}
+			}
+		}
+	]
+}`
+
+func (s *ResourceTypesTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "resource_types")
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+	s.dir = dir
+
+	s.registry = spec.NewSchemaRegistry()
+	for _, raw := range []string{testDevice, testApplication} {
+		schema := new(spec.Schema)
+		if err := json.Unmarshal([]byte(raw), schema); err != nil {
+			s.FailNow(err.Error())
+		}
+		s.registry.Register(schema)
+	}
+}
+
+func (s *ResourceTypesTestSuite) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *ResourceTypesTestSuite) TestParseResourceTypesDir() {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		expect func(t *testing.T, configured []*configuredResourceType, err error)
+	}{
+		{
+			name: "default and configured pipelines",
+			files: map[string]string{
+				"b_application.json": `{"id":"Application","name":"Application","endpoint":"/Applications",
+					"schema":"urn:imulab:scim:schemas:core:2.0:Application",
+					"_pipeline":{"database":"memory","create":["readOnly","uuid","bcrypt","meta","validation"],"lenient":true,"coerce":["singleValue"],
+						"clients":[{"userAgent":"okta","coerce":["booleanString"]}]}}`,
+				"a_device.json": testDeviceResourceType,
+				"readme.txt":    "not a resource type",
+			},
+			expect: func(t *testing.T, configured []*configuredResourceType, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, configured, 2)
+				assert.Equal(t, "Device", configured[0].resourceType.ID())
+				assert.Equal(t, defaultPipeline(false), configured[0].pipeline)
+				assert.Equal(t, "Application", configured[1].resourceType.ID())
+				assert.Equal(t, databaseMemory, configured[1].pipeline.Database)
+				assert.Equal(t, []string{"readOnly", "uuid", "bcrypt", "meta", "validation"}, configured[1].pipeline.Create)
+				assert.Equal(t, defaultPipeline(false).Patch, configured[1].pipeline.Patch)
+				assert.Len(t, configured[0].pipeline.deserializeOptions(), 0)
+				assert.Len(t, configured[1].pipeline.deserializeOptions(), 2)
+
+				r := httptest.NewRequest(http.MethodPost, "/Applications", nil)
+				r.Header.Set("User-Agent", "Okta SCIM Client 1.0.0")
+				assert.Len(t, configured[1].pipeline.clientOptions(r), 1)
+				r.Header.Set("User-Agent", "curl/7.64.1")
+				assert.Len(t, configured[1].pipeline.clientOptions(r), 0)
+			},
+		},
+		{
+			name: "unknown filter",
+			files: map[string]string{
+				"device.json": `{"id":"Device","name":"Device","endpoint":"/Devices",
+					"schema":"urn:imulab:scim:schemas:core:2.0:Device","_pipeline":{"create":["audit"]}}`,
+			},
+			expect: func(t *testing.T, configured []*configuredResourceType, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name: "unknown coercion",
+			files: map[string]string{
+				"device.json": `{"id":"Device","name":"Device","endpoint":"/Devices",
+					"schema":"urn:imulab:scim:schemas:core:2.0:Device","_pipeline":{"coerce":["dateString"]}}`,
+			},
+			expect: func(t *testing.T, configured []*configuredResourceType, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name: "client without user agent",
+			files: map[string]string{
+				"device.json": `{"id":"Device","name":"Device","endpoint":"/Devices",
+					"schema":"urn:imulab:scim:schemas:core:2.0:Device","_pipeline":{"clients":[{"lenient":true}]}}`,
+			},
+			expect: func(t *testing.T, configured []*configuredResourceType, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name: "unknown database",
+			files: map[string]string{
+				"device.json": `{"id":"Device","name":"Device","endpoint":"/Devices",
+					"schema":"urn:imulab:scim:schemas:core:2.0:Device","_pipeline":{"database":"redis"}}`,
+			},
+			expect: func(t *testing.T, configured []*configuredResourceType, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name: "unknown schema",
+			files: map[string]string{
+				"entitlement.json": `{"id":"Entitlement","name":"Entitlement","endpoint":"/Entitlements",
+					"schema":"urn:imulab:scim:schemas:core:2.0:Entitlement"}`,
+			},
+			expect: func(t *testing.T, configured []*configuredResourceType, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name: "duplicate endpoint",
+			files: map[string]string{
+				"a_device.json": testDeviceResourceType,
+				"b_device.json": `{"id":"Appliance","name":"Appliance","endpoint":"/devices",
+					"schema":"urn:imulab:scim:schemas:core:2.0:Device"}`,
+			},
+			expect: func(t *testing.T, configured []*configuredResourceType, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(s.dir, "case")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range test.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			configured, err := parseResourceTypesDir(dir, s.registry)
+			test.expect(t, configured, err)
+		})
+	}
+}
+
+func (s *ResourceTypesTestSuite) TestServe() {
+	for name, content := range map[string]string{
+		"device.json": testDeviceResourceType,
+		"application.json": `{"id":"Application","name":"Application","endpoint":"/Applications",
+			"schema":"urn:imulab:scim:schemas:core:2.0:Application",
+			"_pipeline":{"create":["readOnly","uuid","bcrypt","meta","validation"],"lenient":true,
+				"clients":[{"userAgent":"strict-client","lenient":false}]}}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(s.dir, name), []byte(content), 0644); err != nil {
+			s.FailNow(err.Error())
+		}
+	}
+
+	abs := func(path string) string {
+		p, err := filepath.Abs(path)
+		if err != nil {
+			s.FailNow(err.Error())
+		}
+		return p
+	}
+	// the pipeline of the user resource type is read from its definition too
+	userDir, err := ioutil.TempDir("", "user_resource_type")
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(userDir)
+	userResourceTypePath := filepath.Join(userDir, "user_resource_type.json")
+	if err := ioutil.WriteFile(userResourceTypePath, []byte(`{"id":"User","name":"User","endpoint":"/Users",
+		"schema":"urn:ietf:params:scim:schemas:core:2.0:User","_pipeline":{"lenient":true}}`), 0644); err != nil {
+		s.FailNow(err.Error())
+	}
+
+	app := &applicationContext{args: &arguments{
+		Scim: &args.Scim{
+			ServiceProviderConfigPath: abs("../../public/service_provider_config.json"),
+			UserResourceTypePath:      userResourceTypePath,
+			GroupResourceTypePath:     abs("../../public/resource_types/group_resource_type.json"),
+			SchemasDirectory:          abs("../../public/schemas"),
+		},
+		MemoryDB:         &args.MemoryDB{UseMemoryDB: true},
+		MongoDB:          new(args.MongoDB),
+		RabbitMQ:         new(args.RabbitMQ),
+		Logging:          &args.Logging{Level: "ERROR"},
+		Event:            new(args.Event),
+		Connector:        new(args.Connector),
+		resourceTypesDir: s.dir,
+	}}
+	defer app.Close()
+
+	// serve through a tenant, which does not depend on RabbitMQ and MongoDB
+	tenant := app.Tenant(&tenantConfig{ID: "acme", PathPrefix: "/acme"})
+	tenant.ensureSchemaRegistered()
+	_ = s.registry.ForEachSchema(func(schema *spec.Schema) error {
+		tenant.args.SchemaRegistry().Register(schema)
+		return nil
+	})
+	router := tenant.Router()
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rw
+	}
+
+	assert.Equal(s.T(), http.StatusOK, do(http.MethodGet, "/ResourceTypes/Application", "").Code)
+	assert.Equal(s.T(), http.StatusOK, do(http.MethodGet, "/Users", "").Code)
+
+	// OpenAPI document describes the resource types from the directory
+	rw := do(http.MethodGet, "/openapi.json", "")
+	assert.Equal(s.T(), http.StatusOK, rw.Code)
+	var doc struct {
+		Paths      map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.Nil(s.T(), json.Unmarshal(rw.Body.Bytes(), &doc))
+	assert.Contains(s.T(), doc.Paths, "/Devices/{id}")
+	assert.Contains(s.T(), doc.Paths, "/Applications")
+	assert.Contains(s.T(), doc.Components.Schemas, "Application")
+
+	rw = do(http.MethodPost, "/Devices", `{"schemas":["urn:imulab:scim:schemas:core:2.0:Device"],"serialNumber":"SN-001"}`)
+	assert.Equal(s.T(), http.StatusCreated, rw.Code)
+	assert.Equal(s.T(), http.StatusOK, do(http.MethodGet, "/Devices?filter=serialNumber+eq+%22SN-001%22", "").Code)
+
+	rw = do(http.MethodPost, "/Applications", `{"schemas":["urn:imulab:scim:schemas:core:2.0:Application"],"displayName":"Payroll","password":"s3cr3t"}`)
+	assert.Equal(s.T(), http.StatusCreated, rw.Code)
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	assert.Nil(s.T(), json.Unmarshal(rw.Body.Bytes(), &created))
+	assert.NotEmpty(s.T(), created.ID)
+
+	// validation filter enforces uniqueness against the database of the resource type
+	rw = do(http.MethodPost, "/Applications", `{"schemas":["urn:imulab:scim:schemas:core:2.0:Application"],"displayName":"Payroll"}`)
+	assert.Equal(s.T(), http.StatusBadRequest, rw.Code)
+	assert.Contains(s.T(), rw.Body.String(), "not unique")
+
+	// unknown attributes are dropped by the lenient pipeline, but rejected by default
+	rw = do(http.MethodPost, "/Applications", `{"schemas":["urn:imulab:scim:schemas:core:2.0:Application"],"displayName":"Ledger","owner":"finance"}`)
+	assert.Equal(s.T(), http.StatusCreated, rw.Code)
+	rw = do(http.MethodPost, "/Devices", `{"schemas":["urn:imulab:scim:schemas:core:2.0:Device"],"serialNumber":"SN-002","vendor":"acme"}`)
+	assert.Equal(s.T(), http.StatusBadRequest, rw.Code)
+	assert.Contains(s.T(), rw.Body.String(), "invalidSyntax")
+
+	// unless the client selects the strict mode
+	rw = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/Applications", strings.NewReader(`{"schemas":["urn:imulab:scim:schemas:core:2.0:Application"],"displayName":"Billing","owner":"finance"}`))
+	r.Header.Set("User-Agent", "Strict-Client/1.0")
+	router.ServeHTTP(rw, r)
+	assert.Equal(s.T(), http.StatusBadRequest, rw.Code)
+	assert.Contains(s.T(), rw.Body.String(), "invalidSyntax")
+
+	// the user resource type is lenient as well
+	rw = do(http.MethodPost, "/Users", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"alice",
+		"emails":[{"value":"alice@example.com"}],"nickname2":"al"}`)
+	assert.Equal(s.T(), http.StatusCreated, rw.Code)
+
+	// bcrypt filter hashed the password
+	var application *spec.ResourceType
+	for _, each := range tenant.ResourceTypes() {
+		if each.ID() == "Application" {
+			application = each
+		}
+	}
+	resp, err := tenant.ResourceServices(application).get.Do(context.Background(), &service.GetRequest{ResourceID: created.ID})
+	assert.Nil(s.T(), err)
+	password := resp.Resource.Navigator().Dot("password").Current().Raw()
+	assert.True(s.T(), strings.HasPrefix(password.(string), "$2a$"), password)
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/cmd/internal/args"
+	scimmongo "github.com/imulab/go-scim/mongo/v2"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// tenantConfig is the JSON definition of a tenant. Each tenant is served with its own schemas, resource types, MongoDB
+// metadata and databases, isolated from the default application and other tenants. Requests are routed to the tenant
+// if their host matches Host, or their path starts with PathPrefix. Empty file paths inherit the values of the default
+// application, and an empty MongoDatabase defaults to the tenant id.
+//
+// Secrets are never inherited. Attributes annotated with @Encrypted are encrypted with the keys from EncryptionKeyFile,
+// which is required if the tenant has such attributes, and the admin API of the tenant is served only if AdminToken is
+// set. Hence, the keys and the admin token of one tenant give no access to the data or schemas of another.
+//
+// Tenants do not publish or receive provisioning events, push to remote service providers, or send group sync messages:
+// changes to their groups only update their membership graph. Definitions are rejected if they set any field other
+// than the ones below, so that a tenant cannot be configured for these features and have them silently ignored. The
+// subscribers attached to properties by annotations (see prop.SubscriberFactory) are process-wide, and shared by the
+// default application and all tenants.
+//
+// To define tenants, compose a file similar to:
+//	{
+//		"tenants": [
+//			{
+//				"id": "acme",
+//				"pathPrefix": "/acme",
+//				"schemasDir": "/etc/scim/acme/schemas"
+//			},
+//			{
+//				"id": "globex",
+//				"host": "scim.globex.com",
+//				"mongoDatabase": "globex_scim",
+//				"encryptionKeyFile": "/etc/scim/globex/keys.json",
+//				"adminToken": "s3cr3t"
+//			}
+//		]
+//	}
+type tenantConfig struct {
+	ID                    string `json:"id"`
+	Host                  string `json:"host"`
+	PathPrefix            string `json:"pathPrefix"`
+	ServiceProviderConfig string `json:"serviceProviderConfig"`
+	UserResourceType      string `json:"userResourceType"`
+	GroupResourceType     string `json:"groupResourceType"`
+	SchemasDir            string `json:"schemasDir"`
+	ResourceTypesDir      string `json:"resourceTypesDir"`
+	MongoDatabase         string `json:"mongoDatabase"`
+	MongoMetadataDir      string `json:"mongoMetadataDir"`
+	EncryptionKeyFile     string `json:"encryptionKeyFile"`
+	AdminToken            string `json:"adminToken"`
+}
+
+// parseTenants reads and validates the tenant definitions from the JSON file at path.
+func parseTenants(path string) ([]*tenantConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := new(struct {
+		Tenants []*tenantConfig `json:"tenants"`
+	})
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("invalid tenant definitions: %s", err.Error())
+	}
+
+	var (
+		ids      = map[string]bool{}
+		hosts    = map[string]bool{}
+		prefixes = map[string]bool{}
+	)
+	for _, t := range p.Tenants {
+		if len(t.ID) == 0 {
+			return nil, fmt.Errorf("tenant id is required")
+		}
+		if ids[t.ID] {
+			return nil, fmt.Errorf("duplicate tenant id '%s'", t.ID)
+		}
+		ids[t.ID] = true
+
+		if len(t.Host) == 0 && len(t.PathPrefix) == 0 {
+			return nil, fmt.Errorf("tenant '%s' requires host or pathPrefix", t.ID)
+		}
+		if len(t.Host) > 0 {
+			t.Host = strings.ToLower(t.Host)
+			if hosts[t.Host] {
+				return nil, fmt.Errorf("duplicate host '%s' in tenant '%s'", t.Host, t.ID)
+			}
+			hosts[t.Host] = true
+		}
+		if len(t.PathPrefix) > 0 {
+			t.PathPrefix = "/" + strings.Trim(t.PathPrefix, "/")
+			if t.PathPrefix == "/" {
+				return nil, fmt.Errorf("path prefix of tenant '%s' cannot be root", t.ID)
+			}
+			if prefixes[t.PathPrefix] {
+				return nil, fmt.Errorf("duplicate path prefix '%s' in tenant '%s'", t.PathPrefix, t.ID)
+			}
+			prefixes[t.PathPrefix] = true
+		}
+		if len(t.MongoDatabase) == 0 {
+			t.MongoDatabase = t.ID
+		}
+	}
+
+	return p.Tenants, nil
+}
+
+// forTenant returns a copy of the arguments with the tenant definition applied. The copy registers schemas to a new
+// schema registry and reads MongoDB metadata into a new metadata registry, has events and connector disabled, and takes
+// the encryption key file and admin token from the tenant definition only.
+func (arg *arguments) forTenant(t *tenantConfig) *arguments {
+	orDefault := func(value string, defaultValue string) string {
+		if len(value) > 0 {
+			return value
+		}
+		return defaultValue
+	}
+
+	scim := &args.Scim{
+		ServiceProviderConfigPath: orDefault(t.ServiceProviderConfig, arg.ServiceProviderConfigPath),
+		UserResourceTypePath:      orDefault(t.UserResourceType, arg.UserResourceTypePath),
+		GroupResourceTypePath:     orDefault(t.GroupResourceType, arg.GroupResourceTypePath),
+		SchemasDirectory:          orDefault(t.SchemasDir, arg.SchemasDirectory),
+		Registry:                  spec.NewSchemaRegistry(),
+	}
+
+	mongoDB := *arg.MongoDB
+	mongoDB.Database = t.MongoDatabase
+	mongoDB.MetadataDir = orDefault(t.MongoMetadataDir, arg.MongoDB.MetadataDir)
+	mongoDB.Metadata = scimmongo.NewMetadataRegistry()
+
+	return &arguments{
+		Scim:             scim,
+		MemoryDB:         arg.MemoryDB,
+		MongoDB:          &mongoDB,
+		RabbitMQ:         arg.RabbitMQ,
+		Logging:          arg.Logging,
+		Event:            new(args.Event),
+		Connector:        new(args.Connector),
+		Encryption:       &args.Encryption{KeyFile: t.EncryptionKeyFile},
+		httpPort:         arg.httpPort,
+		adminToken:       t.AdminToken,
+		resourceTypesDir: orDefault(t.ResourceTypesDir, arg.resourceTypesDir),
+	}
+}
+
+// Tenant returns the application context serving the tenant. The tenant shares the logger, MongoDB client and RabbitMQ
+// connection with this application, and is closed along with it.
+func (ctx *applicationContext) Tenant(t *tenantConfig) *applicationContext {
+	tenant := &applicationContext{
+		args:   ctx.args.forTenant(t),
+		tenant: t.ID,
+		parent: ctx,
+	}
+	ctx.tenants = append(ctx.tenants, tenant)
+	return tenant
+}
+
+// tenantRouter dispatches requests to the handler of the tenant whose host matches the request host, or whose path
+// prefix is the longest one matching the request path. The path prefix is stripped before dispatching. Requests that
+// match no tenant are handled by the fallback handler.
+type tenantRouter struct {
+	hosts    map[string]http.Handler
+	prefixes []string
+	byPrefix map[string]http.Handler
+	fallback http.Handler
+}
+
+func newTenantRouter(fallback http.Handler) *tenantRouter {
+	return &tenantRouter{
+		hosts:    map[string]http.Handler{},
+		byPrefix: map[string]http.Handler{},
+		fallback: fallback,
+	}
+}
+
+// Register routes requests of the tenant to the handler. The handler is served with the schema registry of the tenant
+// in the request context.
+func (tr *tenantRouter) Register(t *tenantConfig, registry *spec.SchemaRegistry, handler http.Handler) {
+	handler = withRegistry(registry, handler)
+	if len(t.Host) > 0 {
+		tr.hosts[t.Host] = handler
+	}
+	if len(t.PathPrefix) > 0 {
+		tr.byPrefix[t.PathPrefix] = http.StripPrefix(t.PathPrefix, handler)
+		tr.prefixes = append(tr.prefixes, t.PathPrefix)
+		sort.Slice(tr.prefixes, func(i, j int) bool {
+			return len(tr.prefixes[i]) > len(tr.prefixes[j])
+		})
+	}
+}
+
+func (tr *tenantRouter) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if handler, ok := tr.hosts[strings.ToLower(host)]; ok {
+		handler.ServeHTTP(rw, r)
+		return
+	}
+
+	for _, prefix := range tr.prefixes {
+		if r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/") {
+			tr.byPrefix[prefix].ServeHTTP(rw, r)
+			return
+		}
+	}
+
+	tr.fallback.ServeHTTP(rw, r)
+}
+
+func withRegistry(registry *spec.SchemaRegistry, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(rw, r.WithContext(spec.WithRegistry(r.Context(), registry)))
+	})
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/cmd/internal/args"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTenant(t *testing.T) {
+	s := new(TenantTestSuite)
+	suite.Run(t, s)
+}
+
+type TenantTestSuite struct {
+	suite.Suite
+}
+
+func (s *TenantTestSuite) TestParseTenants() {
+	tests := []struct {
+		name   string
+		config string
+		expect func(t *testing.T, tenants []*tenantConfig, err error)
+	}{
+		{
+			name:   "valid tenants",
+			config: `{"tenants":[{"id":"acme","pathPrefix":"acme/"},{"id":"globex","host":"SCIM.globex.com","mongoDatabase":"globex_scim"}]}`,
+			expect: func(t *testing.T, tenants []*tenantConfig, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, tenants, 2)
+				assert.Equal(t, "/acme", tenants[0].PathPrefix)
+				assert.Equal(t, "acme", tenants[0].MongoDatabase)
+				assert.Equal(t, "scim.globex.com", tenants[1].Host)
+				assert.Equal(t, "globex_scim", tenants[1].MongoDatabase)
+			},
+		},
+		{
+			name:   "missing id",
+			config: `{"tenants":[{"pathPrefix":"/acme"}]}`,
+			expect: func(t *testing.T, tenants []*tenantConfig, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "duplicate id",
+			config: `{"tenants":[{"id":"acme","pathPrefix":"/a"},{"id":"acme","pathPrefix":"/b"}]}`,
+			expect: func(t *testing.T, tenants []*tenantConfig, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "no route",
+			config: `{"tenants":[{"id":"acme"}]}`,
+			expect: func(t *testing.T, tenants []*tenantConfig, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "root prefix",
+			config: `{"tenants":[{"id":"acme","pathPrefix":"/"}]}`,
+			expect: func(t *testing.T, tenants []*tenantConfig, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "unsupported feature",
+			config: `{"tenants":[{"id":"acme","pathPrefix":"/acme","connector":{"url":"https://scim.example.com"}}]}`,
+			expect: func(t *testing.T, tenants []*tenantConfig, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "duplicate host",
+			config: `{"tenants":[{"id":"acme","host":"a.com"},{"id":"globex","host":"A.com"}]}`,
+			expect: func(t *testing.T, tenants []*tenantConfig, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "tenants*.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, _ = f.WriteString(test.config)
+			_ = f.Close()
+
+			tenants, err := parseTenants(f.Name())
+			test.expect(t, tenants, err)
+		})
+	}
+}
+
+func (s *TenantTestSuite) TestTenantSecrets() {
+	parent := &arguments{
+		Scim:       new(args.Scim),
+		MongoDB:    new(args.MongoDB),
+		Encryption: &args.Encryption{KeyFile: "/etc/scim/keys.json"},
+		adminToken: "root",
+	}
+
+	tenant := parent.forTenant(&tenantConfig{ID: "acme", PathPrefix: "/acme"})
+	assert.Empty(s.T(), tenant.Encryption.KeyFile)
+	assert.Empty(s.T(), tenant.adminToken)
+
+	tenant = parent.forTenant(&tenantConfig{ID: "acme", PathPrefix: "/acme", EncryptionKeyFile: "/etc/scim/acme/keys.json", AdminToken: "acme"})
+	assert.Equal(s.T(), "/etc/scim/acme/keys.json", tenant.Encryption.KeyFile)
+	assert.Equal(s.T(), "acme", tenant.adminToken)
+}
+
+func (s *TenantTestSuite) TestTenantRouter() {
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte(name + ":" + r.URL.Path))
+		})
+	}
+	acme := spec.NewSchemaRegistry()
+
+	tr := newTenantRouter(handler("default"))
+	tr.Register(&tenantConfig{ID: "acme", PathPrefix: "/acme"}, acme, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(s.T(), acme, spec.RegistryFrom(r.Context()))
+		_, _ = rw.Write([]byte("acme:" + r.URL.Path))
+	}))
+	tr.Register(&tenantConfig{ID: "acme-eu", PathPrefix: "/acme/eu"}, spec.NewSchemaRegistry(), handler("acme-eu"))
+	tr.Register(&tenantConfig{ID: "globex", Host: "scim.globex.com"}, spec.NewSchemaRegistry(), handler("globex"))
+
+	for _, test := range []struct {
+		host   string
+		path   string
+		expect string
+	}{
+		{host: "localhost", path: "/Users", expect: "default:/Users"},
+		{host: "localhost", path: "/acme/Users", expect: "acme:/Users"},
+		{host: "localhost", path: "/acme/eu/Users", expect: "acme-eu:/Users"},
+		{host: "localhost", path: "/acmeUsers", expect: "default:/acmeUsers"},
+		{host: "scim.globex.com:8080", path: "/Users", expect: "globex:/Users"},
+		{host: "SCIM.globex.com", path: "/acme/Users", expect: "globex:/acme/Users"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://"+test.host+test.path, nil)
+		rw := httptest.NewRecorder()
+		tr.ServeHTTP(rw, r)
+		assert.Equal(s.T(), test.expect, rw.Body.String())
+	}
+}
+
+func (s *TenantTestSuite) TestTenantIsolation() {
+	app := &applicationContext{args: &arguments{
+		Scim: &args.Scim{
+			ServiceProviderConfigPath: s.mustAbs("../../public/service_provider_config.json"),
+			UserResourceTypePath:      s.mustAbs("../../public/resource_types/user_resource_type.json"),
+			GroupResourceTypePath:     s.mustAbs("../../public/resource_types/group_resource_type.json"),
+			SchemasDirectory:          s.mustAbs("../../public/schemas"),
+		},
+		MemoryDB:  &args.MemoryDB{UseMemoryDB: true},
+		MongoDB:   new(args.MongoDB),
+		RabbitMQ:  new(args.RabbitMQ),
+		Logging:   &args.Logging{Level: "ERROR"},
+		Event:     new(args.Event),
+		Connector: new(args.Connector),
+	}}
+	defer app.Close()
+
+	tr := newTenantRouter(http.NotFoundHandler())
+	var registries []*spec.SchemaRegistry
+	for _, each := range []*tenantConfig{
+		{ID: "acme", PathPrefix: "/acme"},
+		{ID: "globex", PathPrefix: "/globex"},
+	} {
+		tenant := app.Tenant(each)
+		tr.Register(each, tenant.args.SchemaRegistry(), tenant.Router())
+		registries = append(registries, tenant.args.SchemaRegistry())
+		assert.Equal(s.T(), tenant.args.SchemaRegistry(), tenant.UserResourceType().Registry())
+	}
+	assert.True(s.T(), registries[0] != registries[1])
+	assert.True(s.T(), spec.Schemas() != registries[0])
+
+	rw := httptest.NewRecorder()
+	tr.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/acme/Users", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "imulab",
+		"emails": [{"value": "imulab@foo.com", "primary": true}]
+	}`)))
+	assert.Equal(s.T(), http.StatusCreated, rw.Code)
+
+	for prefix, total := range map[string]int{"/acme": 1, "/globex": 0} {
+		rw := httptest.NewRecorder()
+		tr.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, prefix+"/Users", nil))
+		assert.Equal(s.T(), http.StatusOK, rw.Code)
+
+		var result struct {
+			TotalResults int `json:"totalResults"`
+		}
+		assert.Nil(s.T(), json.Unmarshal(rw.Body.Bytes(), &result))
+		assert.Equal(s.T(), total, result.TotalResults, prefix)
+	}
+
+	// OpenAPI document resolves paths against the tenant's path prefix
+	rw = httptest.NewRecorder()
+	tr.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/globex/openapi.json", nil))
+	assert.Equal(s.T(), http.StatusOK, rw.Code)
+	var doc struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+	}
+	assert.Nil(s.T(), json.Unmarshal(rw.Body.Bytes(), &doc))
+	if assert.Len(s.T(), doc.Servers, 1) {
+		assert.Equal(s.T(), "/globex", doc.Servers[0].URL)
+	}
+}
+
+func (s *TenantTestSuite) mustAbs(path string) string {
+	p, err := filepath.Abs(path)
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+	return p
+}
@@ -0,0 +1,89 @@
+package codegen
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/imulab/go-scim/cmd/internal/args"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/urfave/cli/v2"
+)
+
+func newArgs() *arguments {
+	return &arguments{}
+}
+
+type arguments struct {
+	// Path to the directory containing all schema JSON file
+	SchemasDirectory string
+	// Comma separated paths to the resource type JSON files
+	ResourceTypePaths string
+	// Name of the package of the generated source
+	PackageName string
+	// Path to the generated source file, or "-" for standard output
+	OutputPath string
+}
+
+// ParseResourceTypes registers all schemas in SchemasDirectory, and returns the parsed resource types from the
+// JSON definitions at ResourceTypePaths.
+func (arg *arguments) ParseResourceTypes() ([]*spec.ResourceType, error) {
+	if err := (&args.Scim{SchemasDirectory: arg.SchemasDirectory}).RegisterSchemas(); err != nil {
+		return nil, err
+	}
+
+	var resourceTypes []*spec.ResourceType
+	for _, path := range strings.Split(arg.ResourceTypePaths, ",") {
+		if path = strings.TrimSpace(path); len(path) == 0 {
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		rt := new(spec.ResourceType)
+		err = json.NewDecoder(f).Decode(rt)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		resourceTypes = append(resourceTypes, rt)
+	}
+
+	return resourceTypes, nil
+}
+
+func (arg *arguments) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "schemas-dir",
+			Usage:       "Absolute path to the directory containing all schema JSON definitions",
+			EnvVars:     []string{"SCHEMAS_DIR"},
+			Required:    true,
+			Destination: &arg.SchemasDirectory,
+		},
+		&cli.StringFlag{
+			Name:        "resource-types",
+			Usage:       "Comma separated file paths to the resource type JSON definitions to generate types for",
+			EnvVars:     []string{"RESOURCE_TYPES"},
+			Required:    true,
+			Destination: &arg.ResourceTypePaths,
+		},
+		&cli.StringFlag{
+			Name:        "package",
+			Usage:       "Name of the package of the generated source",
+			Value:       "scim",
+			Destination: &arg.PackageName,
+		},
+		&cli.StringFlag{
+			Name:        "output",
+			Aliases:     []string{"o"},
+			Usage:       "File path of the generated source, or '-' for standard output",
+			Value:       "-",
+			Destination: &arg.OutputPath,
+		},
+	}
+}
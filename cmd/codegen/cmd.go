@@ -0,0 +1,36 @@
+package codegen
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command returns a cli.Command that generates Go types from SCIM schema and resource type definitions.
+func Command() *cli.Command {
+	args := newArgs()
+	return &cli.Command{
+		Name:        "codegen",
+		Aliases:     []string{"gen"},
+		Description: "Generate Go structs, attribute path constants and resource converters from resource types",
+		Flags:       args.Flags(),
+		Action: func(_ *cli.Context) error {
+			resourceTypes, err := args.ParseResourceTypes()
+			if err != nil {
+				return err
+			}
+
+			source, err := Generate(args.PackageName, resourceTypes...)
+			if err != nil {
+				return err
+			}
+
+			if args.OutputPath == "-" {
+				_, err = os.Stdout.Write(source)
+				return err
+			}
+			return ioutil.WriteFile(args.OutputPath, source, 0644)
+		},
+	}
+}
@@ -0,0 +1,370 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Generate returns the formatted Go source of package pkg, which contains, for each of the resource types:
+//
+//   - a struct bound to the resource type with "scim" tags, as well as structs for its complex attributes;
+//   - a constant for the path of every attribute;
+//   - functions to convert between the struct and *prop.Resource, by the means of the binding package.
+//
+// Schemas of the resource types must have been registered.
+func Generate(pkg string, resourceTypes ...*spec.ResourceType) ([]byte, error) {
+	g := &generator{names: map[string]string{}}
+	for _, resourceType := range resourceTypes {
+		if err := g.resourceType(resourceType); err != nil {
+			return nil, err
+		}
+	}
+
+	var source bytes.Buffer
+	source.WriteString("// Code generated by \"scim codegen\". DO NOT EDIT.\n\n")
+	fmt.Fprintf(&source, "package %s\n\n", pkg)
+	source.WriteString("import (\n")
+	source.WriteString("\t\"sync\"\n")
+	if g.usesTime {
+		source.WriteString("\t\"time\"\n")
+	}
+	source.WriteString("\n")
+	source.WriteString("\t\"github.com/imulab/go-scim/pkg/v2/binding\"\n")
+	source.WriteString("\t\"github.com/imulab/go-scim/pkg/v2/prop\"\n")
+	source.WriteString("\t\"github.com/imulab/go-scim/pkg/v2/spec\"\n")
+	source.WriteString(")\n")
+	source.Write(g.body.Bytes())
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated source is invalid: %s", err.Error())
+	}
+	return formatted, nil
+}
+
+type generator struct {
+	body     bytes.Buffer
+	usesTime bool
+	names    map[string]string // generated identifiers to the path they were generated from, to detect collisions
+}
+
+type (
+	structDef struct {
+		name   string
+		doc    string
+		fields []fieldDef
+	}
+	fieldDef struct {
+		name string
+		typ  string
+		tag  string
+		doc  string
+	}
+)
+
+func (g *generator) resourceType(resourceType *spec.ResourceType) error {
+	typeName := exportedName(resourceType.Name())
+
+	extensionNames := map[string]string{}
+	_ = resourceType.ForEachExtension(func(extension *spec.Schema, _ bool) error {
+		extensionNames[extension.ID()] = exportedName(extension.Name())
+		return nil
+	})
+
+	var (
+		structs   []*structDef
+		constants []fieldDef
+	)
+
+	main := &structDef{
+		name: typeName,
+		doc: fmt.Sprintf("%s is bound to the resource type '%s' with schema '%s'.",
+			typeName, resourceType.Name(), resourceType.Schema().ID()),
+	}
+	structs = append(structs, main)
+
+	err := resourceType.SuperAttribute(true).ForEachSubAttribute(func(attr *spec.Attribute) error {
+		if extensionName, ok := extensionNames[attr.ID()]; ok {
+			// extension attribute is a complex attribute named after the schema id.
+			name := typeName + extensionName
+			constants = append(constants, fieldDef{name: "Path" + name, tag: attr.ID()})
+			if err := attr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+				return walkPaths(subAttr, "", func(path string) {
+					constants = append(constants, fieldDef{
+						name: "Path" + name + pathName(path),
+						tag:  attr.ID() + ":" + path,
+					})
+				})
+			}); err != nil {
+				return err
+			}
+
+			ext := &structDef{
+				name: name,
+				doc:  fmt.Sprintf("%s is bound to the extension schema '%s' of %s.", name, attr.ID(), typeName),
+			}
+			structs = append(structs, ext)
+			if err := attr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+				f, nested := g.field(name, subAttr)
+				ext.fields = append(ext.fields, f)
+				structs = append(structs, nested...)
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			main.fields = append(main.fields, fieldDef{
+				name: extensionName,
+				typ:  "*" + name,
+				tag:  attr.ID(),
+				doc:  attr.Description(),
+			})
+			return nil
+		}
+
+		if err := walkPaths(attr, "", func(path string) {
+			constants = append(constants, fieldDef{
+				name: "Path" + typeName + pathName(path),
+				tag:  path,
+			})
+		}); err != nil {
+			return err
+		}
+
+		f, nested := g.field(typeName, attr)
+		main.fields = append(main.fields, f)
+		structs = append(structs, nested...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(&g.body, "\n// Attribute paths of the resource type '%s'.\nconst (\n", resourceType.Name())
+	for _, c := range constants {
+		if err := g.claim(c.name, c.tag); err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.body, "\t%s = %q\n", c.name, c.tag)
+	}
+	g.body.WriteString(")\n")
+
+	for _, s := range structs {
+		if err := g.claim(s.name, s.doc); err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.body, "\n// %s\ntype %s struct {\n", s.doc, s.name)
+		for _, f := range s.fields {
+			if len(f.doc) > 0 {
+				fmt.Fprintf(&g.body, "\t// %s\n", f.doc)
+			}
+			fmt.Fprintf(&g.body, "\t%s %s `scim:%q`\n", f.name, f.typ, f.tag)
+		}
+		g.body.WriteString("}\n")
+	}
+
+	g.converters(typeName, resourceType)
+	return nil
+}
+
+// walkPaths invokes callback with the path of the attribute and all its sub attributes, depth first. The path is
+// derived from the names of the attributes, and an error is returned if it differs from the path in the definition.
+func walkPaths(attr *spec.Attribute, parentPath string, callback func(path string)) error {
+	path := attr.Name()
+	if len(parentPath) > 0 {
+		path = parentPath + "." + path
+	}
+	if attr.Path() != path {
+		return fmt.Errorf("%w: attribute '%s' is defined with path '%s', but is addressed by '%s'",
+			spec.ErrInvalidPath, attr.ID(), attr.Path(), path)
+	}
+
+	callback(path)
+	return attr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+		return walkPaths(subAttr, path, callback)
+	})
+}
+
+// field returns the definition of the field bound to the attribute, as a field of the struct named parent, and the
+// definitions of the structs generated for the complex attribute, if any.
+func (g *generator) field(parent string, attr *spec.Attribute) (fieldDef, []*structDef) {
+	f := fieldDef{
+		name: exportedName(attr.Name()),
+		tag:  attr.Name(),
+		doc:  firstSentence(attr.Description()),
+	}
+
+	var nested []*structDef
+	var typ string
+	switch attr.Type() {
+	case spec.TypeComplex:
+		typ = parent + f.name
+		s := &structDef{
+			name: typ,
+			doc:  fmt.Sprintf("%s is bound to the complex attribute '%s' of %s.", typ, attr.Path(), parent),
+		}
+		nested = append(nested, s)
+		_ = attr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+			subField, subNested := g.field(typ, subAttr)
+			s.fields = append(s.fields, subField)
+			nested = append(nested, subNested...)
+			return nil
+		})
+		if !attr.MultiValued() {
+			typ = "*" + typ
+		}
+	case spec.TypeString, spec.TypeReference, spec.TypeBinary:
+		typ = "string"
+	case spec.TypeDateTime:
+		g.usesTime = true
+		typ = "time.Time"
+	case spec.TypeInteger:
+		typ = "int64"
+	case spec.TypeDecimal:
+		typ = "float64"
+	case spec.TypeBoolean:
+		typ = "bool"
+	}
+
+	// zero values of integer, decimal and boolean are meaningful, hence pointers represent unassigned values.
+	switch {
+	case attr.MultiValued():
+		typ = "[]" + typ
+	case attr.Type() == spec.TypeInteger, attr.Type() == spec.TypeDecimal, attr.Type() == spec.TypeBoolean:
+		typ = "*" + typ
+	}
+
+	f.typ = typ
+	return f, nested
+}
+
+func (g *generator) converters(typeName string, resourceType *spec.ResourceType) {
+	bindings := unexportedName(typeName) + "Bindings"
+	fmt.Fprintf(&g.body, `
+var %[2]s sync.Map
+
+func %[3]sBinding(resourceType *spec.ResourceType) (*binding.Binding, error) {
+	if b, ok := %[2]s.Load(resourceType); ok {
+		return b.(*binding.Binding), nil
+	}
+	b, err := binding.New(resourceType, %[1]s{})
+	if err != nil {
+		return nil, err
+	}
+	%[2]s.Store(resourceType, b)
+	return b, nil
+}
+
+// %[1]sFromResource returns a new %[1]s loaded from the resource, which must be of the resource type '%[4]s'.
+func %[1]sFromResource(resource *prop.Resource) (*%[1]s, error) {
+	b, err := %[3]sBinding(resource.ResourceType())
+	if err != nil {
+		return nil, err
+	}
+	v := new(%[1]s)
+	if err := b.Load(resource, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Resource returns a new resource of the resource type, which must be compatible with '%[4]s', with the values of %[1]s.
+func (v *%[1]s) Resource(resourceType *spec.ResourceType) (*prop.Resource, error) {
+	b, err := %[3]sBinding(resourceType)
+	if err != nil {
+		return nil, err
+	}
+	return b.NewResource(v)
+}
+`, typeName, bindings, unexportedName(typeName), resourceType.Name())
+}
+
+// claim records the identifier as generated from the origin, and returns an error if it was already generated from
+// a different origin.
+func (g *generator) claim(identifier string, origin string) error {
+	if previous, ok := g.names[identifier]; ok && previous != origin {
+		return fmt.Errorf("%w: generated identifier '%s' collides between '%s' and '%s'",
+			spec.ErrInvalidValue, identifier, previous, origin)
+	}
+	g.names[identifier] = origin
+	return nil
+}
+
+// commonInitialisms are capitalized as a whole in generated identifiers, following Go conventions.
+var commonInitialisms = map[string]string{
+	"id":   "ID",
+	"url":  "URL",
+	"uri":  "URI",
+	"ip":   "IP",
+	"json": "JSON",
+	"http": "HTTP",
+}
+
+// exportedName converts the attribute or schema name to an exported Go identifier, i.e. externalId to ExternalID, and
+// $ref to Ref.
+func exportedName(name string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range name {
+		switch {
+		case unicode.IsUpper(r):
+			flush()
+			word = append(word, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	var sb strings.Builder
+	for _, w := range words {
+		if initialism, ok := commonInitialisms[strings.ToLower(w)]; ok {
+			sb.WriteString(initialism)
+			continue
+		}
+		sb.WriteString(strings.ToUpper(w[:1]))
+		sb.WriteString(w[1:])
+	}
+
+	identifier := sb.String()
+	if len(identifier) == 0 || unicode.IsDigit(rune(identifier[0])) {
+		identifier = "X" + identifier
+	}
+	return identifier
+}
+
+// unexportedName converts the exported identifier to an unexported one.
+func unexportedName(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// pathName converts the dot separated attribute path to an exported Go identifier, i.e. name.givenName to NameGivenName.
+func pathName(path string) string {
+	var sb strings.Builder
+	for _, segment := range strings.Split(path, ".") {
+		sb.WriteString(exportedName(segment))
+	}
+	return sb.String()
+}
+
+// firstSentence returns the first sentence of the description, to be used as doc comment.
+func firstSentence(description string) string {
+	description = strings.Join(strings.Fields(description), " ")
+	if i := strings.Index(description, ". "); i >= 0 {
+		return description[:i+1]
+	}
+	return description
+}
@@ -0,0 +1,195 @@
+package codegen
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	s := new(GenerateTestSuite)
+	suite.Run(t, s)
+}
+
+type GenerateTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+}
+
+func (s *GenerateTestSuite) TestGenerate() {
+	source, err := Generate("scim", s.resourceType)
+	require.Nil(s.T(), err)
+
+	file, err := parser.ParseFile(token.NewFileSet(), "scim.go", source, 0)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "scim", file.Name.Name)
+
+	var (
+		constants = map[string]string{}
+		types     = map[string]map[string]string{} // type name -> field name -> tag
+		funcs     = map[string]bool{}
+	)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			for _, each := range d.Specs {
+				switch sp := each.(type) {
+				case *ast.ValueSpec:
+					if len(sp.Values) == 0 {
+						continue
+					}
+					if lit, ok := sp.Values[0].(*ast.BasicLit); ok {
+						constants[sp.Names[0].Name] = lit.Value
+					}
+				case *ast.TypeSpec:
+					fields := map[string]string{}
+					for _, f := range sp.Type.(*ast.StructType).Fields.List {
+						fields[f.Names[0].Name] = f.Tag.Value
+					}
+					types[sp.Name.Name] = fields
+				}
+			}
+		case *ast.FuncDecl:
+			funcs[d.Name.Name] = true
+		}
+	}
+
+	assert.Equal(s.T(), `"id"`, constants["PathTestUserID"])
+	assert.Equal(s.T(), `"name.givenName"`, constants["PathTestUserNameGivenName"])
+	assert.Equal(s.T(), `"emails.value"`, constants["PathTestUserEmailsValue"])
+	assert.Equal(s.T(), `"urn:test:Extension:manager.value"`, constants["PathTestUserTestExtensionManagerValue"])
+
+	assert.Equal(s.T(), "`scim:\"externalId\"`", types["TestUser"]["ExternalID"])
+	assert.Equal(s.T(), "`scim:\"urn:test:Extension\"`", types["TestUser"]["TestExtension"])
+	assert.Equal(s.T(), "`scim:\"givenName\"`", types["TestUserName"]["GivenName"])
+	assert.Equal(s.T(), "`scim:\"value\"`", types["TestUserTestExtensionManager"]["Value"])
+	assert.Contains(s.T(), types, "TestUserEmails")
+	assert.Contains(s.T(), types, "TestUserMeta")
+
+	assert.True(s.T(), funcs["TestUserFromResource"])
+	assert.True(s.T(), funcs["Resource"])
+}
+
+func (s *GenerateTestSuite) TestGenerateInconsistentPath() {
+	schema := new(spec.Schema)
+	require.Nil(s.T(), json.Unmarshal([]byte(`
+{
+  "id": "urn:test:Inconsistent",
+  "name": "Inconsistent",
+  "attributes": [
+    {
+      "id": "urn:test:Inconsistent:addresses",
+      "name": "addresses",
+      "type": "complex",
+      "multiValued": true,
+      "_index": 0,
+      "_path": "addresses",
+      "subAttributes": [
+        {"id": "urn:test:Inconsistent:addresses.country", "name": "country", "type": "string", "_index": 0, "_path": "photos.country"}
+      ]
+    }
+  ]
+}
+`), schema))
+	spec.Schemas().Register(schema)
+
+	resourceType := new(spec.ResourceType)
+	require.Nil(s.T(), json.Unmarshal([]byte(`{"id": "Inconsistent", "name": "Inconsistent", "endpoint": "/Inconsistent", "schema": "urn:test:Inconsistent"}`), resourceType))
+
+	_, err := Generate("scim", resourceType)
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidPath))
+}
+
+func (s *GenerateTestSuite) TestExportedName() {
+	for _, each := range []struct {
+		name   string
+		expect string
+	}{
+		{name: "id", expect: "ID"},
+		{name: "externalId", expect: "ExternalID"},
+		{name: "profileUrl", expect: "ProfileURL"},
+		{name: "$ref", expect: "Ref"},
+		{name: "x509Certificates", expect: "X509Certificates"},
+		{name: "Enterprise User", expect: "EnterpriseUser"},
+		{name: "2fa", expect: "X2fa"},
+	} {
+		assert.Equal(s.T(), each.expect, exportedName(each.name))
+	}
+}
+
+func (s *GenerateTestSuite) SetupSuite() {
+	for _, each := range []string{
+		`
+{
+  "id": "core",
+  "name": "Core",
+  "attributes": [
+    {"id": "schemas", "name": "schemas", "type": "reference", "multiValued": true, "_index": 0, "_path": "schemas"},
+    {"id": "id", "name": "id", "type": "string", "_index": 1, "_path": "id"},
+    {"id": "externalId", "name": "externalId", "type": "string", "_index": 2, "_path": "externalId"},
+    {
+      "id": "meta", "name": "meta", "type": "complex", "_index": 3, "_path": "meta",
+      "subAttributes": [
+        {"id": "meta.created", "name": "created", "type": "dateTime", "_index": 0, "_path": "meta.created"}
+      ]
+    }
+  ]
+}`,
+		`
+{
+  "id": "urn:test:User",
+  "name": "TestUser",
+  "attributes": [
+    {"id": "urn:test:User:userName", "name": "userName", "type": "string", "_index": 0, "_path": "userName"},
+    {
+      "id": "urn:test:User:name", "name": "name", "type": "complex", "_index": 1, "_path": "name",
+      "subAttributes": [
+        {"id": "urn:test:User:name.givenName", "name": "givenName", "type": "string", "_index": 0, "_path": "name.givenName"}
+      ]
+    },
+    {
+      "id": "urn:test:User:emails", "name": "emails", "type": "complex", "multiValued": true, "_index": 2, "_path": "emails",
+      "subAttributes": [
+        {"id": "urn:test:User:emails.value", "name": "value", "type": "string", "_index": 0, "_path": "emails.value"},
+        {"id": "urn:test:User:emails.primary", "name": "primary", "type": "boolean", "_index": 1, "_path": "emails.primary"}
+      ]
+    },
+    {"id": "urn:test:User:age", "name": "age", "type": "integer", "_index": 3, "_path": "age"}
+  ]
+}`,
+		`
+{
+  "id": "urn:test:Extension",
+  "name": "TestExtension",
+  "attributes": [
+    {
+      "id": "urn:test:Extension:manager", "name": "manager", "type": "complex", "_index": 0, "_path": "manager",
+      "subAttributes": [
+        {"id": "urn:test:Extension:manager.value", "name": "value", "type": "string", "_index": 0, "_path": "manager.value"}
+      ]
+    }
+  ]
+}`,
+	} {
+		schema := new(spec.Schema)
+		require.Nil(s.T(), json.Unmarshal([]byte(each), schema))
+		spec.Schemas().Register(schema)
+	}
+
+	s.resourceType = new(spec.ResourceType)
+	require.Nil(s.T(), json.Unmarshal([]byte(`
+{
+  "id": "TestUser",
+  "name": "TestUser",
+  "endpoint": "/TestUsers",
+  "schema": "urn:test:User",
+  "schemaExtensions": [{"schema": "urn:test:Extension", "required": false}]
+}`), s.resourceType))
+}
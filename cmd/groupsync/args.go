@@ -3,6 +3,7 @@ package groupsync
 import (
 	"github.com/imulab/go-scim/cmd/internal/args"
 	"github.com/urfave/cli/v2"
+	"time"
 )
 
 func newArgs() *arguments {
@@ -21,17 +22,34 @@ type arguments struct {
 	*args.MongoDB
 	*args.RabbitMQ
 	*args.Logging
-	requeueLimit int
+	requeueLimit     int
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
 }
 
 func (arg *arguments) Flags() []cli.Flag {
 	flags := []cli.Flag{
 		&cli.IntFlag{
 			Name:        "requeue-limit",
-			Usage:       "Limit for message re-queues. Messages that has been re-queued more than this limit will be dropped (0 for unlimited).",
+			Usage:       "Limit for message re-queues. Messages that has been re-queued more than this limit will be dead-lettered (0 for unlimited).",
 			EnvVars:     []string{"REQUEUE_LIMIT"},
+			Value:       5,
 			Destination: &arg.requeueLimit,
 		},
+		&cli.DurationFlag{
+			Name:        "retry-interval",
+			Usage:       "Delay before a failed message is re-queued the first time, doubling after each failure",
+			EnvVars:     []string{"RETRY_INTERVAL"},
+			Value:       time.Second,
+			Destination: &arg.retryInterval,
+		},
+		&cli.DurationFlag{
+			Name:        "max-retry-interval",
+			Usage:       "Maximum delay before a failed message is re-queued",
+			EnvVars:     []string{"MAX_RETRY_INTERVAL"},
+			Value:       5 * time.Minute,
+			Destination: &arg.maxRetryInterval,
+		},
 	}
 	flags = append(flags, arg.Scim.Flags()...)
 	flags = append(flags, arg.MemoryDB.Flags()...)
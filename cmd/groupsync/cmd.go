@@ -8,20 +8,26 @@ import (
 	"syscall"
 )
 
-// Command returns a cli.Command that starts a process to synchronize group membership of user resources.
+// Command returns a cli.Command that starts a process to synchronize group membership of user resources. The
+// dead-letters sub command inspects and replays the messages that failed to be processed, and the reconcile sub command
+// fixes the group membership of all user resources at once.
 func Command() *cli.Command {
 	args := newArgs()
 	return &cli.Command{
 		Name:        "group-sync",
-		Aliases:     []string{"gs", "sync"},
+		Aliases:     []string{"groupsync", "gs", "sync"},
 		Description: "Asynchronously refresh user resource for group membership changes",
 		Flags:       args.Flags(),
+		Subcommands: []*cli.Command{
+			deadLettersCommand(),
+			reconcileCommand(args),
+		},
 		Action: func(_ *cli.Context) error {
 			app := args.Initialize()
 			defer app.Close()
 
 			ctx, cancelFunc := context.WithCancel(context.Background())
-			safeExit, err := app.MessageConsumer().Start(ctx)
+			safeExit, err := app.Queue().Consume(ctx, app.JobHandler())
 			if err != nil {
 				return err
 			}
@@ -6,16 +6,19 @@ import (
 	scimmongo "github.com/imulab/go-scim/mongo/v2"
 	"github.com/imulab/go-scim/pkg/v2/db"
 	"github.com/imulab/go-scim/pkg/v2/groupsync"
-	"github.com/imulab/go-scim/pkg/v2/service/filter"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/rs/zerolog"
 	"github.com/streadway/amqp"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
 	"sync"
 	"time"
 )
 
+// age after which the membership graph is reloaded, to pick up changes made to the groups by the api
+const membershipGraphMaxAge = time.Minute
+
 type applicationContext struct {
 	args                      *arguments
 	logger                    *zerolog.Logger
@@ -29,8 +32,9 @@ type applicationContext struct {
 	registerMongoMetadataOnce sync.Once
 	rabbitMqConn              *amqp.Connection
 	rabbitMqChannel           *amqp.Channel
-	userSyncService           *groupsync.SyncService
-	messageConsumer           *consumer
+	queue                     *gs.Rabbit
+	membershipGraph           *groupsync.MembershipGraph
+	jobHandler                groupsync.Handler
 }
 
 func (ctx *applicationContext) Logger() *zerolog.Logger {
@@ -120,7 +124,11 @@ func (ctx *applicationContext) UserDatabase() db.DB {
 			collection := ctx.MongoClient().
 				Database(ctx.args.MongoDB.Database, options.Database()).
 				Collection(resourceType.Name(), options.Collection())
-			ctx.userDatabase = scimmongo.DB(resourceType, collection, scimmongo.Options().IgnoreProjection())
+			if err := ctx.verifyMongoIndexes(resourceType, collection); err != nil {
+				ctx.logInitFailure("mongo user indexes", err)
+				panic(err)
+			}
+			ctx.userDatabase = scimmongo.DB(resourceType, collection, scimmongo.Options().Metadata(ctx.args.MongoDB.MetadataRegistry()))
 			ctx.logInitialized("mongo user database")
 		}
 	}
@@ -138,7 +146,11 @@ func (ctx *applicationContext) GroupDatabase() db.DB {
 			collection := ctx.MongoClient().
 				Database(ctx.args.MongoDB.Database, options.Database()).
 				Collection(resourceType.Name(), options.Collection())
-			ctx.groupDatabase = scimmongo.DB(resourceType, collection, scimmongo.Options().IgnoreProjection())
+			if err := ctx.verifyMongoIndexes(resourceType, collection); err != nil {
+				ctx.logInitFailure("mongo group indexes", err)
+				panic(err)
+			}
+			ctx.groupDatabase = scimmongo.DB(resourceType, collection, scimmongo.Options().Metadata(ctx.args.MongoDB.MetadataRegistry()))
 			ctx.logInitialized("mongo group database")
 		}
 	}
@@ -155,6 +167,29 @@ func (ctx *applicationContext) ensureMongoMetadata() {
 	})
 }
 
+// verifyMongoIndexes creates the missing indexes of the collection of the resource type, unless disabled, and warns
+// about the indexes that differ from the plan, which are left for the mongo migrate command to resolve. Unique indexes
+// that are not in place fail the verification.
+func (ctx *applicationContext) verifyMongoIndexes(resourceType *spec.ResourceType, collection *mongo.Collection) error {
+	verifyCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelFunc()
+
+	diff, err := ctx.args.MongoDB.VerifyIndexes(verifyCtx, resourceType, collection)
+	if err != nil {
+		return err
+	}
+	if !diff.Empty() {
+		ctx.Logger().
+			Warn().
+			Fields(map[string]interface{}{
+				"collection": collection.Name(),
+				"indexes":    strings.Split(strings.TrimSpace(diff.String()), "\n"),
+			}).
+			Msg("Indexes differ from schema, run 'scim mongo migrate' to migrate them")
+	}
+	return nil
+}
+
 func (ctx *applicationContext) RabbitMQConnection() *amqp.Connection {
 	if ctx.rabbitMqConn == nil {
 		connectCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
@@ -188,28 +223,32 @@ func (ctx *applicationContext) RabbitMQChannel() *amqp.Channel {
 	return ctx.rabbitMqChannel
 }
 
-func (ctx *applicationContext) UserSyncService() *groupsync.SyncService {
-	if ctx.userSyncService == nil {
-		ctx.userSyncService = groupsync.NewSyncService(ctx.GroupDatabase())
-		ctx.logInitialized("user sync service")
+// Queue returns the queue to consume group sync jobs from.
+func (ctx *applicationContext) Queue() *gs.Rabbit {
+	if ctx.queue == nil {
+		ctx.queue = gs.RabbitQueue(ctx.RabbitMQChannel(), ctx.Logger()).
+			Retry(ctx.args.requeueLimit, ctx.args.retryInterval, ctx.args.maxRetryInterval)
+		ctx.logInitialized("group sync queue")
 	}
-	return ctx.userSyncService
+	return ctx.queue
 }
 
-func (ctx *applicationContext) MessageConsumer() *consumer {
-	if ctx.messageConsumer == nil {
-		ctx.messageConsumer = &consumer{
-			rabbitCh:        ctx.RabbitMQChannel(),
-			userDatabase:    ctx.UserDatabase(),
-			groupDatabase:   ctx.GroupDatabase(),
-			userSyncService: ctx.UserSyncService(),
-			metaFilter:      filter.MetaFilter(),
-			logger:          ctx.Logger(),
-			trialLimit:      ctx.args.requeueLimit,
-		}
-		ctx.logInitialized("message consumer")
+// MembershipGraph returns the graph of group memberships, reloaded periodically as groups are changed by the api.
+func (ctx *applicationContext) MembershipGraph() *groupsync.MembershipGraph {
+	if ctx.membershipGraph == nil {
+		ctx.membershipGraph = groupsync.NewMembershipGraph(ctx.GroupDatabase()).MaxAge(membershipGraphMaxAge)
+		ctx.logInitialized("membership graph")
+	}
+	return ctx.membershipGraph
+}
+
+// JobHandler returns the handler refreshing the "groups" property of users for group sync jobs.
+func (ctx *applicationContext) JobHandler() groupsync.Handler {
+	if ctx.jobHandler == nil {
+		ctx.jobHandler = groupsync.NewGraphJobHandler(ctx.UserDatabase(), ctx.MembershipGraph())
+		ctx.logInitialized("group sync job handler")
 	}
-	return ctx.messageConsumer
+	return ctx.jobHandler
 }
 
 func (ctx *applicationContext) Close() {
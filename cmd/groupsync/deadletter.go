@@ -0,0 +1,65 @@
+package groupsync
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// deadLettersCommand returns a cli.Command to inspect the group sync messages in the dead letter queue, and to replay
+// them once the cause of their failure is resolved.
+func deadLettersCommand() *cli.Command {
+	args := newArgs()
+	flags := []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "Maximum number of dead letters to visit, oldest first (0 for all)",
+		},
+	}
+	flags = append(flags, args.RabbitMQ.Flags()...)
+	flags = append(flags, args.Logging.Flags()...)
+
+	return &cli.Command{
+		Name:        "dead-letters",
+		Aliases:     []string{"dlq"},
+		Description: "Inspect and replay group sync messages that failed within the requeue limit",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "list",
+				Description: "Print the dead letters as lines of JSON, leaving them in the dead letter queue",
+				Flags:       flags,
+				Action: func(c *cli.Context) error {
+					app := args.Initialize()
+					defer app.Close()
+
+					letters, err := app.Queue().DeadLetters(c.Int("limit"))
+					if err != nil {
+						return err
+					}
+					for _, letter := range letters {
+						if _, err := fmt.Fprintln(os.Stdout, letter.String()); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+			{
+				Name:        "replay",
+				Description: "Move the dead letters back to the group sync queue, with their trials reset",
+				Flags:       flags,
+				Action: func(c *cli.Context) error {
+					app := args.Initialize()
+					defer app.Close()
+
+					n, err := app.Queue().Replay(c.Int("limit"))
+					if _, printErr := fmt.Fprintf(os.Stdout, "replayed %d dead letters\n", n); printErr != nil && err == nil {
+						err = printErr
+					}
+					return err
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,161 @@
+package groupsync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/imulab/go-scim/pkg/v2/groupsync"
+	"github.com/urfave/cli/v2"
+)
+
+// reconcileCommand returns a cli.Command that recomputes the "groups" property of every user, to fix the drift left
+// by lost group sync jobs. The progress is saved to the state file after each batch, so that an interrupted
+// reconciliation resumes where it stopped; the state file is removed once all users are checked. The databases are
+// configured by the flags of the parent command, which are required anyway.
+func reconcileCommand(args *arguments) *cli.Command {
+	var (
+		batchSize int
+		rate      float64
+		dryRun    bool
+		state     string
+	)
+	flags := []cli.Flag{
+		&cli.IntFlag{
+			Name:        "batch-size",
+			Usage:       "Number of users queried at a time",
+			Value:       100,
+			Destination: &batchSize,
+		},
+		&cli.Float64Flag{
+			Name:        "rate",
+			Usage:       "Maximum number of users checked per second (0 for unlimited)",
+			Destination: &rate,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Report the fixes without saving them",
+			Destination: &dryRun,
+		},
+		&cli.StringFlag{
+			Name:        "state",
+			Usage:       "Path to the file to resume from, and to save the progress to",
+			Destination: &state,
+		},
+	}
+
+	return &cli.Command{
+		Name:        "reconcile",
+		Description: "Recompute the groups of every user, including indirect memberships, and print the fixes as JSON",
+		Flags:       flags,
+		Action: func(_ *cli.Context) error {
+			app := args.Initialize()
+			defer app.Close()
+
+			cursor, err := readCursor(state)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			go func() {
+				term := make(chan os.Signal, 1)
+				signal.Notify(term, syscall.SIGINT, syscall.SIGTERM)
+				select {
+				case <-term:
+					app.Logger().Info().Msg("received terminate signal, saving progress")
+					cancelFunc()
+				case <-ctx.Done():
+				}
+			}()
+
+			report, err := groupsync.NewReconciler(app.UserDatabase(), app.GroupDatabase()).
+				BatchSize(batchSize).
+				RateLimit(rate).
+				DryRun(dryRun).
+				Resume(cursor).
+				OnBatch(func(report *groupsync.ReconcileReport) {
+					if err := writeCursor(state, report.Cursor); err != nil {
+						app.Logger().Err(err).Msg("failed to save reconciliation progress")
+					}
+					app.Logger().Info().Fields(map[string]interface{}{
+						"checked": report.Checked,
+						"fixed":   len(report.Fixes),
+						"failed":  len(report.Failed),
+						"cursor":  report.Cursor,
+					}).Msg("reconciled batch of users")
+				}).
+				Reconcile(ctx)
+			if err == nil && len(state) > 0 {
+				if removeErr := os.Remove(state); removeErr != nil && !os.IsNotExist(removeErr) {
+					err = removeErr
+				}
+			} else if err != nil && len(state) > 0 {
+				_ = writeCursor(state, report.Cursor)
+			}
+
+			if printErr := printReport(report, dryRun); printErr != nil && err == nil {
+				err = printErr
+			}
+			return err
+		},
+	}
+}
+
+// readCursor returns the cursor saved in the state file, or empty if the file does not exist.
+func readCursor(state string) (string, error) {
+	if len(state) == 0 {
+		return "", nil
+	}
+	raw, err := ioutil.ReadFile(state)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read state file: %v", err)
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// writeCursor saves the cursor to the state file, if any.
+func writeCursor(state string, cursor string) error {
+	if len(state) == 0 {
+		return nil
+	}
+	return ioutil.WriteFile(state, []byte(cursor+"\n"), 0644)
+}
+
+// printReport prints the report as JSON to the standard output.
+func printReport(report *groupsync.ReconcileReport, dryRun bool) error {
+	failed := make(map[string]string, len(report.Failed))
+	for id, err := range report.Failed {
+		failed[id] = err.Error()
+	}
+	fixes := report.Fixes
+	if fixes == nil {
+		fixes = []*groupsync.Fix{}
+	}
+	cycles := report.Cycles
+	if cycles == nil {
+		cycles = [][]string{}
+	}
+	raw, err := json.MarshalIndent(map[string]interface{}{
+		"dryRun":  dryRun,
+		"cycles":  cycles,
+		"checked": report.Checked,
+		"fixes":   fixes,
+		"failed":  failed,
+		"cursor":  report.Cursor,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(raw))
+	return err
+}
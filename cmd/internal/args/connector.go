@@ -0,0 +1,90 @@
+package args
+
+import (
+	"github.com/imulab/go-scim/pkg/v2/connector"
+	"github.com/urfave/cli/v2"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Connector is the configuration options related to pushing local changes to a remote SCIM service provider.
+type Connector struct {
+	RemoteURL         string
+	BearerToken       string
+	UserMappingPath   string
+	GroupMappingPath  string
+	ReconcileInterval time.Duration
+}
+
+// Enabled returns true if a remote SCIM service provider is configured.
+func (arg *Connector) Enabled() bool {
+	return len(arg.RemoteURL) > 0
+}
+
+// Remote returns a client to the remote SCIM service provider.
+func (arg *Connector) Remote() *connector.Remote {
+	remote := connector.NewRemote(arg.RemoteURL, &http.Client{Timeout: 30 * time.Second})
+	if len(arg.BearerToken) > 0 {
+		remote.BearerToken(arg.BearerToken)
+	}
+	return remote
+}
+
+// ParseUserMapping parses the mapping for User resources, or returns nil if none is configured.
+func (arg *Connector) ParseUserMapping() (*connector.Mapping, error) {
+	return arg.parseMapping(arg.UserMappingPath)
+}
+
+// ParseGroupMapping parses the mapping for Group resources, or returns nil if none is configured.
+func (arg *Connector) ParseGroupMapping() (*connector.Mapping, error) {
+	return arg.parseMapping(arg.GroupMappingPath)
+}
+
+func (arg *Connector) parseMapping(path string) (*connector.Mapping, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return connector.ParseMapping(f)
+}
+
+func (arg *Connector) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "connector-url",
+			Usage:       "Base URL of the remote SCIM service provider to push changes to. If empty, changes are not pushed",
+			EnvVars:     []string{"CONNECTOR_URL"},
+			Destination: &arg.RemoteURL,
+		},
+		&cli.StringFlag{
+			Name:        "connector-token",
+			Usage:       "Bearer token to authorize requests to the remote SCIM service provider",
+			EnvVars:     []string{"CONNECTOR_TOKEN"},
+			Destination: &arg.BearerToken,
+		},
+		&cli.StringFlag{
+			Name:        "connector-user-mapping",
+			Usage:       "Absolute path to the JSON file mapping User resources to the remote. If empty, users are not pushed",
+			EnvVars:     []string{"CONNECTOR_USER_MAPPING"},
+			Destination: &arg.UserMappingPath,
+		},
+		&cli.StringFlag{
+			Name:        "connector-group-mapping",
+			Usage:       "Absolute path to the JSON file mapping Group resources to the remote. If empty, groups are not pushed",
+			EnvVars:     []string{"CONNECTOR_GROUP_MAPPING"},
+			Destination: &arg.GroupMappingPath,
+		},
+		&cli.DurationFlag{
+			Name:        "connector-reconcile-interval",
+			Usage:       "Interval between reconciliations with the remote SCIM service provider. Zero disables reconciliation",
+			EnvVars:     []string{"CONNECTOR_RECONCILE_INTERVAL"},
+			Value:       time.Hour,
+			Destination: &arg.ReconcileInterval,
+		},
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/cenkalti/backoff/v4"
 	scimmongo "github.com/imulab/go-scim/mongo/v2"
+	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/urfave/cli/v2"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -38,6 +39,10 @@ type MongoDB struct {
 	Database    string
 	Options     string
 	MetadataDir string
+	// Whether to create the missing indexes of collections when verifying their indexes
+	CreateIndexes bool
+	// Registry to read metadata into, nil means the default registry
+	Metadata *scimmongo.MetadataRegistry
 }
 
 // Url returns the MongoDB connection URL created using the set options.
@@ -68,7 +73,8 @@ func (arg *MongoDB) Connect(ctx context.Context) (client *mongo.Client, err erro
 	return
 }
 
-// RegisterMetadata iterates all JSON files in the MetadataDir and registers its content as SCIM MongoDB metadata.
+// RegisterMetadata iterates all JSON files in the MetadataDir and registers its content as SCIM MongoDB metadata to
+// the Metadata registry.
 func (arg *MongoDB) RegisterMetadata() error {
 	if len(arg.MetadataDir) == 0 {
 		return nil
@@ -88,10 +94,54 @@ func (arg *MongoDB) RegisterMetadata() error {
 			return err
 		}
 
-		return scimmongo.ReadMetadataFromReader(f)
+		return arg.MetadataRegistry().ReadFromReader(f)
 	})
 }
 
+// MetadataRegistry returns the Metadata registry, or the default metadata registry if Metadata is not set.
+func (arg *MongoDB) MetadataRegistry() *scimmongo.MetadataRegistry {
+	if arg.Metadata == nil {
+		return scimmongo.DefaultMetadataRegistry()
+	}
+	return arg.Metadata
+}
+
+// VerifyIndexes compares the indexes of the collection with the indexes planned for the resource type (see
+// scimmongo.PlanIndexes), and creates the missing ones if CreateIndexes is set. It returns the remaining difference,
+// which can only be resolved by migrating the collection, or an error if the plan is invalid, the indexes cannot be
+// listed or created, or a unique index is not in place, as the uniqueness of attributes would not be enforced. Caller
+// must make sure RegisterMetadata was invoked first.
+func (arg *MongoDB) VerifyIndexes(ctx context.Context, resourceType *spec.ResourceType, coll *mongo.Collection) (*scimmongo.IndexDiff, error) {
+	plan, err := scimmongo.PlanIndexes(resourceType, arg.MetadataRegistry())
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := scimmongo.DiffIndexes(ctx, coll, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	if arg.CreateIndexes {
+		if err := diff.CreateMissing(ctx, coll); err != nil {
+			return nil, err
+		}
+		diff.Missing = nil
+	}
+
+	var unique []string
+	for _, idx := range append(append([]*scimmongo.Index{}, diff.Missing...), diff.Changed...) {
+		if idx.Unique {
+			unique = append(unique, idx.Name)
+		}
+	}
+	if len(unique) > 0 {
+		return nil, fmt.Errorf("unique indexes %s of '%s' are missing or differ, run 'scim mongo migrate' to migrate them",
+			strings.Join(unique, ", "), coll.Name())
+	}
+	return diff, nil
+}
+
 func (arg *MongoDB) Flags() []cli.Flag {
 	return []cli.Flag{
 		&cli.StringFlag{
@@ -138,5 +188,12 @@ func (arg *MongoDB) Flags() []cli.Flag {
 			EnvVars:     []string{"MONGO_METADATA_DIR"},
 			Destination: &arg.MetadataDir,
 		},
+		&cli.BoolFlag{
+			Name:        "mongo-create-indexes",
+			Usage:       "Create the missing indexes of MongoDB collections on startup, changed and stale indexes are left to 'scim mongo migrate'. Startup fails while unique indexes are missing or changed",
+			EnvVars:     []string{"MONGO_CREATE_INDEXES"},
+			Value:       true,
+			Destination: &arg.CreateIndexes,
+		},
 	}
 }
@@ -0,0 +1,40 @@
+package args
+
+import (
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/encryption"
+	"github.com/urfave/cli/v2"
+	"time"
+)
+
+// Encryption is the configuration options related to encrypting attributes annotated with @Encrypted at rest.
+type Encryption struct {
+	KeyFile        string
+	RotateInterval time.Duration
+}
+
+// KeyProvider returns the provider of the keys in the key file.
+func (arg *Encryption) KeyProvider() (encryption.KeyProvider, error) {
+	if len(arg.KeyFile) == 0 {
+		return nil, errors.New("encryption key file is required by attributes annotated with @Encrypted")
+	}
+	return encryption.LocalKeyFile(arg.KeyFile)
+}
+
+func (arg *Encryption) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "encryption-key-file",
+			Usage:       "Absolute path to the JSON file of keys to encrypt attributes annotated with @Encrypted",
+			EnvVars:     []string{"ENCRYPTION_KEY_FILE"},
+			Destination: &arg.KeyFile,
+		},
+		&cli.DurationFlag{
+			Name:        "encryption-rotate-interval",
+			Usage:       "Interval between re-encryptions of stored values with the current key. Zero disables rotation",
+			EnvVars:     []string{"ENCRYPTION_ROTATE_INTERVAL"},
+			Value:       time.Hour,
+			Destination: &arg.RotateInterval,
+		},
+	}
+}
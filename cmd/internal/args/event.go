@@ -0,0 +1,102 @@
+package args
+
+import (
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/event"
+	"github.com/urfave/cli/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Event is the configuration options related to publishing and receiving SCIM provisioning events as Security Event
+// Tokens. Tokens are signed and verified with HS256 using the shared secret.
+type Event struct {
+	PushEndpoint   string
+	Secret         string
+	Issuer         string
+	Audience       string
+	Receive        bool
+	ReceiverIssuer string
+}
+
+// PublishEnabled returns true if the provisioning events should be published.
+func (arg *Event) PublishEnabled() bool {
+	return len(arg.PushEndpoint) > 0
+}
+
+// ReceiveEnabled returns true if the provisioning events receiver endpoint should be served.
+func (arg *Event) ReceiveEnabled() bool {
+	return arg.Receive
+}
+
+// EventPublisher returns an event.Publisher that pushes events to the push endpoint, or an error if the secret is empty.
+func (arg *Event) EventPublisher() (*event.Publisher, error) {
+	if len(arg.Secret) == 0 {
+		return nil, errors.New("event-secret is required to publish provisioning events")
+	}
+	transport := event.HTTPPush(arg.PushEndpoint, &http.Client{Timeout: 10 * time.Second})
+	return event.NewPublisher(arg.Issuer, event.HS256Signer([]byte(arg.Secret)), transport).
+		Audience(arg.audience()...), nil
+}
+
+// EventReceiver returns an event.Receiver that accepts events issued by the receiver issuer (if set), and addressed to the
+// issuer of this server, or an error if the secret is empty, as anyone could then forge events.
+func (arg *Event) EventReceiver() (*event.Receiver, error) {
+	if len(arg.Secret) == 0 {
+		return nil, errors.New("event-secret is required to receive provisioning events")
+	}
+	return event.NewReceiver(event.HS256Verifier([]byte(arg.Secret)), arg.ReceiverIssuer, arg.Issuer), nil
+}
+
+func (arg *Event) audience() []string {
+	audience := make([]string, 0)
+	for _, each := range strings.Split(arg.Audience, ",") {
+		if each = strings.TrimSpace(each); len(each) > 0 {
+			audience = append(audience, each)
+		}
+	}
+	return audience
+}
+
+func (arg *Event) Flags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "event-push-endpoint",
+			Usage:       "URL to push provisioning events to. If empty, events are not published",
+			EnvVars:     []string{"EVENT_PUSH_ENDPOINT"},
+			Destination: &arg.PushEndpoint,
+		},
+		&cli.StringFlag{
+			Name:        "event-secret",
+			Usage:       "Shared secret to sign and verify provisioning events with, required to publish or receive events",
+			EnvVars:     []string{"EVENT_SECRET"},
+			Destination: &arg.Secret,
+		},
+		&cli.StringFlag{
+			Name:        "event-issuer",
+			Usage:       "Issuer of the published provisioning events, also the expected audience of the received events",
+			EnvVars:     []string{"EVENT_ISSUER"},
+			Destination: &arg.Issuer,
+		},
+		&cli.StringFlag{
+			Name:        "event-audience",
+			Usage:       "Comma separated audience of the published provisioning events",
+			EnvVars:     []string{"EVENT_AUDIENCE"},
+			Destination: &arg.Audience,
+		},
+		&cli.BoolFlag{
+			Name:        "event-receiver",
+			Usage:       "Serve the endpoint that receives provisioning events and applies them",
+			EnvVars:     []string{"EVENT_RECEIVER"},
+			Value:       false,
+			Destination: &arg.Receive,
+		},
+		&cli.StringFlag{
+			Name:        "event-receiver-issuer",
+			Usage:       "Expected issuer of the received provisioning events. If empty, any issuer is accepted",
+			EnvVars:     []string{"EVENT_RECEIVER_ISSUER"},
+			Destination: &arg.ReceiverIssuer,
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/urfave/cli/v2"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,6 +20,8 @@ type Scim struct {
 	GroupResourceTypePath string
 	// Path to the directory containing all schema JSON file
 	SchemasDirectory string
+	// Registry to register schemas to and parse resource types against, nil means the default registry
+	Registry *spec.SchemaRegistry
 }
 
 // ParseServiceProviderConfig returns an instance of spec.ServiceProviderConfig from the JSON definition at
@@ -39,7 +42,7 @@ func (arg *Scim) ParseServiceProviderConfig() (*spec.ServiceProviderConfig, erro
 }
 
 // RegisterSchemas iterates through all JSON files in the SchemasDirectory directory and registers all of them as
-// schema files.
+// schema files to the Registry.
 func (arg *Scim) RegisterSchemas() error {
 	return filepath.Walk(arg.SchemasDirectory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -61,7 +64,7 @@ func (arg *Scim) RegisterSchemas() error {
 			return err
 		}
 
-		spec.Schemas().Register(schema)
+		arg.SchemaRegistry().Register(schema)
 		return nil
 	})
 }
@@ -84,13 +87,20 @@ func (arg *Scim) parseResourceType(path string) (*spec.ResourceType, error) {
 		return nil, err
 	}
 
-	rt := new(spec.ResourceType)
-	err = json.NewDecoder(f).Decode(rt)
+	raw, err := ioutil.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
 
-	return rt, nil
+	return arg.SchemaRegistry().ParseResourceType(raw)
+}
+
+// SchemaRegistry returns the Registry, or the default schema registry if Registry is not set.
+func (arg *Scim) SchemaRegistry() *spec.SchemaRegistry {
+	if arg.Registry == nil {
+		return spec.Schemas()
+	}
+	return arg.Registry
 }
 
 func (arg *Scim) Flags() []cli.Flag {
@@ -0,0 +1,110 @@
+package groupsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/groupsync"
+	"github.com/streadway/amqp"
+)
+
+// DeadLetter is a message in the dead letter queue.
+type DeadLetter struct {
+	MessageID string         `json:"message_id"`
+	Timestamp time.Time      `json:"timestamp"`
+	Error     string         `json:"error,omitempty"`
+	Job       *groupsync.Job `json:"job,omitempty"`
+	// Body of the message, when it is not a job.
+	Body string `json:"body,omitempty"`
+}
+
+// String returns the dead letter in a line of JSON.
+func (d *DeadLetter) String() string {
+	raw, _ := json.Marshal(d)
+	return string(raw)
+}
+
+func newDeadLetter(message amqp.Delivery) *DeadLetter {
+	d := &DeadLetter{
+		MessageID: message.MessageId,
+		Timestamp: message.Timestamp,
+	}
+	if cause, ok := message.Headers[RabbitErrorHeader].(string); ok {
+		d.Error = cause
+	}
+	job := new(groupsync.Job)
+	if err := json.Unmarshal(message.Body, job); err == nil && len(job.MemberID) > 0 {
+		d.Job = job
+	} else {
+		d.Body = string(message.Body)
+	}
+	return d
+}
+
+// DeadLetters returns at most limit (0 for all) messages in the dead letter queue, oldest first. The messages are left
+// in the queue.
+func (q *Rabbit) DeadLetters(limit int) ([]*DeadLetter, error) {
+	var letters []*DeadLetter
+	err := q.drain(limit, func(message amqp.Delivery) (bool, error) {
+		letters = append(letters, newDeadLetter(message))
+		return false, nil
+	})
+	return letters, err
+}
+
+// Replay moves at most limit (0 for all) jobs in the dead letter queue back to RabbitQueueName, with their trial
+// reset, and returns the number of jobs replayed. Messages that are not jobs are left in the dead letter queue.
+func (q *Rabbit) Replay(limit int) (int, error) {
+	n := 0
+	err := q.drain(limit, func(message amqp.Delivery) (bool, error) {
+		letter := newDeadLetter(message)
+		if letter.Job == nil {
+			return false, nil
+		}
+		letter.Job.Trial = 1
+		if err := q.publish(RabbitQueueName, letter.Job, nil); err != nil {
+			return false, err
+		}
+		if err := message.Ack(false); err != nil {
+			return false, err
+		}
+		n++
+		return true, nil
+	})
+	return n, err
+}
+
+// drain gets at most limit (0 for all) messages from the dead letter queue, and invokes the callback for each of them,
+// which reports whether it acknowledged the message. Other messages are held unacknowledged until the end, so that
+// each message is visited once, and then requeued, which puts them back at their original position in the queue.
+func (q *Rabbit) drain(limit int, callback func(message amqp.Delivery) (bool, error)) (err error) {
+	// delivery tag of the last message not acknowledged, which requeues all unacknowledged messages before it
+	var unacked uint64
+	defer func() {
+		if unacked == 0 {
+			return
+		}
+		if nackErr := q.ch.Nack(unacked, true, true); nackErr != nil && err == nil {
+			err = fmt.Errorf("failed to requeue dead letters: %w", nackErr)
+		}
+	}()
+
+	for i := 0; limit <= 0 || i < limit; i++ {
+		message, ok, err := q.ch.Get(RabbitDeadLetterQueueName, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		acked, err := callback(message)
+		if !acked {
+			unacked = message.DeliveryTag
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,22 +1,299 @@
 package groupsync
 
-import "github.com/streadway/amqp"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/groupsync"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+	"github.com/streadway/amqp"
+)
 
 // Queue name in RabbitMQ, used by consumer and producer to exchange message.
 const RabbitQueueName = "group_sync"
 
+// Queue name in RabbitMQ, where messages failed within the trial limit are dead-lettered.
+const RabbitDeadLetterQueueName = "group_sync.dead"
+
 // Exchange name in RabbitMQ
 const RabbitExchangeName = ""
 
-// Declare a queue in RabbitMQ named RabbitQueueName.
+// Header of dead-lettered messages, holding the error of their last trial.
+const RabbitErrorHeader = "x-group-sync-error"
+
+// Declare the queues in RabbitMQ named RabbitQueueName and RabbitDeadLetterQueueName.
 func DeclareQueue(ch *amqp.Channel) error {
+	for _, name := range []string{RabbitQueueName, RabbitDeadLetterQueueName} {
+		if _, err := ch.QueueDeclare(
+			name,
+			true,
+			false,
+			false,
+			false,
+			nil,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// declareRetryQueue declares the queue in RabbitMQ holding messages for the delay, before they are dead-lettered back
+// to RabbitQueueName, and returns its name. Delays are served by different queues, as messages only expire at the
+// head of a queue.
+func declareRetryQueue(ch *amqp.Channel, delay time.Duration) (string, error) {
+	name := fmt.Sprintf("%s.retry.%d", RabbitQueueName, delay.Milliseconds())
 	_, err := ch.QueueDeclare(
-		RabbitQueueName,
+		name,
 		true,
 		false,
 		false,
 		false,
+		amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    RabbitExchangeName,
+			"x-dead-letter-routing-key": RabbitQueueName,
+		},
+	)
+	return name, err
+}
+
+// RabbitQueue returns a new Rabbit queue on the channel. By default, each job is tried 3 times, with an initial
+// interval of 1 second between the trials, which doubles after each failure up to 5 minutes.
+func RabbitQueue(ch *amqp.Channel, logger *zerolog.Logger) *Rabbit {
+	return &Rabbit{
+		ch:          ch,
+		trialLimit:  3,
+		interval:    time.Second,
+		maxInterval: 5 * time.Minute,
+		retryQueues: map[time.Duration]string{},
+		logger:      logger,
+	}
+}
+
+// Rabbit is a groupsync.Queue that exchanges jobs as JSON messages through the RabbitQueueName queue. Messages are
+// acknowledged only once their jobs are handled, retried, or dead-lettered, so that jobs survive the crash of the
+// consumer. Failed jobs are retried after a delay, by publishing them to a retry queue whose messages expire back to
+// RabbitQueueName. Jobs failed within the trial limit are dead-lettered to RabbitDeadLetterQueueName, to be inspected
+// and replayed (see DeadLetters and Replay).
+//
+// The channel is put in confirm mode: jobs are submitted, and messages acknowledged after publishing their jobs again,
+// only once the broker confirmed the publishing, so that jobs are not lost in between.
+type Rabbit struct {
+	ch          *amqp.Channel
+	trialLimit  int
+	interval    time.Duration
+	maxInterval time.Duration
+	mu          sync.Mutex
+	retryQueues map[time.Duration]string
+	publishMu   sync.Mutex             // held while publishing a message and waiting for its confirmation
+	confirms    chan amqp.Confirmation // confirmations of the published messages, nil until in confirm mode
+	logger      *zerolog.Logger
+}
+
+// Retry sets the number of times each job is tried (0 for unlimited), and the initial interval before a failed job is
+// tried again, which doubles after each failure up to maxInterval.
+func (q *Rabbit) Retry(trialLimit int, interval time.Duration, maxInterval time.Duration) *Rabbit {
+	q.trialLimit = trialLimit
+	q.interval = interval
+	q.maxInterval = maxInterval
+	return q
+}
+
+func (q *Rabbit) Submit(_ context.Context, job *groupsync.Job) error {
+	return q.publish(RabbitQueueName, job, nil)
+}
+
+func (q *Rabbit) Consume(ctx context.Context, handler groupsync.Handler) (<-chan struct{}, error) {
+	if err := q.ch.Qos(1, 0, false); err != nil {
+		q.logger.Err(err).Msg("failed to consume message")
+		return nil, err
+	}
+	messages, err := q.ch.Consume(
+		RabbitQueueName,
+		"",
+		false,
+		false,
+		false,
+		false,
 		nil,
 	)
-	return err
+	if err != nil {
+		q.logger.Err(err).Msg("failed to consume message")
+		return nil, err
+	}
+
+	q.logger.Info().Msg("group sync consumer starts to listen for messages")
+
+	safeExit := make(chan struct{})
+	go func() {
+		defer close(safeExit)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				q.handle(ctx, message, handler)
+			}
+		}
+	}()
+
+	return safeExit, nil
+}
+
+// handle handles the job of the message, and acknowledges the message once the job is handled, or has been published
+// again for retry or to the dead letter queue. Otherwise, the message is requeued.
+func (q *Rabbit) handle(ctx context.Context, message amqp.Delivery, handler groupsync.Handler) {
+	fields := map[string]interface{}{"messageId": message.MessageId}
+
+	job := new(groupsync.Job)
+	if err := json.Unmarshal(message.Body, job); err != nil {
+		q.logger.Err(err).Fields(fields).Msg("Failed to unmarshal message payload, dead-lettering")
+		q.settle(message, q.deadLetter(message.Body, err))
+		return
+	}
+
+	if job.ExceededTrialLimit(q.trialLimit) {
+		err := fmt.Errorf("message had exceeded trial limit %d", q.trialLimit)
+		q.logger.Error().Fields(fields).Fields(job.Fields()).Msg("Message had exceeded trial limit, dead-lettering")
+		q.settle(message, q.publish(RabbitDeadLetterQueueName, job, err))
+		return
+	}
+
+	err := handler.Handle(ctx, job)
+	if err == nil {
+		q.logger.Info().Fields(fields).Fields(job.Fields()).Msg("group property was successfully synced")
+		q.settle(message, nil)
+		return
+	}
+
+	job.Retry()
+	if job.ExceededTrialLimit(q.trialLimit) {
+		q.logger.Err(err).Fields(fields).Fields(job.Fields()).Msg("encountered error when syncing group property, dead-lettering")
+		q.settle(message, q.publish(RabbitDeadLetterQueueName, job, err))
+		return
+	}
+
+	q.logger.Err(err).Fields(fields).Fields(job.Fields()).Msg("encountered error when syncing group property, will retry")
+	q.settle(message, q.retry(job))
+}
+
+// settle acknowledges the message if the publishing succeeded, or requeues it.
+func (q *Rabbit) settle(message amqp.Delivery, publishErr error) {
+	var err error
+	if publishErr == nil {
+		err = message.Ack(false)
+	} else {
+		err = message.Nack(false, true)
+	}
+	if err != nil {
+		q.logger.Err(err).Fields(map[string]interface{}{"messageId": message.MessageId}).Msg("Failed to settle message")
+	}
+}
+
+// retry publishes the job to the retry queue of its delay.
+func (q *Rabbit) retry(job *groupsync.Job) error {
+	delay := groupsync.Backoff(job.Trial, q.interval, q.maxInterval)
+
+	q.mu.Lock()
+	name, ok := q.retryQueues[delay]
+	if !ok {
+		var err error
+		if name, err = declareRetryQueue(q.ch, delay); err != nil {
+			q.mu.Unlock()
+			return err
+		}
+		q.retryQueues[delay] = name
+	}
+	q.mu.Unlock()
+
+	return q.publish(name, job, nil)
+}
+
+// deadLetter publishes the raw body of a message that cannot be parsed to the dead letter queue.
+func (q *Rabbit) deadLetter(body []byte, cause error) error {
+	return q.send(RabbitDeadLetterQueueName, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    uuid.NewV4().String(),
+		Timestamp:    time.Now(),
+		Headers:      amqp.Table{RabbitErrorHeader: cause.Error()},
+		Body:         body,
+	})
+}
+
+// send publishes the message to the queue, and waits for the broker to confirm it. The channel is put in confirm mode
+// on first use. Messages are published one at a time, so that each confirmation is the one of the last message.
+func (q *Rabbit) send(queueName string, publishing amqp.Publishing) error {
+	q.publishMu.Lock()
+	defer q.publishMu.Unlock()
+
+	if q.confirms == nil {
+		if err := q.ch.Confirm(false); err != nil {
+			return fmt.Errorf("failed to put channel in confirm mode: %w", err)
+		}
+		q.confirms = q.ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	}
+
+	if err := q.ch.Publish(RabbitExchangeName, queueName, false, false, publishing); err != nil {
+		return err
+	}
+	confirmation, ok := <-q.confirms
+	if !ok {
+		return errors.New("channel closed before the message was confirmed")
+	}
+	if !confirmation.Ack {
+		return errors.New("message was rejected by the broker")
+	}
+	return nil
+}
+
+// publish publishes the job to the queue, with the cause if not nil.
+func (q *Rabbit) publish(queueName string, job *groupsync.Job, cause error) error {
+	messageId := uuid.NewV4().String()
+
+	raw, err := json.Marshal(job)
+	if err != nil {
+		q.logger.
+			Err(err).
+			Fields(map[string]interface{}{"messageId": messageId}).
+			Fields(job.Fields()).
+			Msg("Failed to send group sync message")
+		return err
+	}
+
+	publishing := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageId,
+		Timestamp:    time.Now(),
+		Body:         raw,
+	}
+	if cause != nil {
+		publishing.Headers = amqp.Table{RabbitErrorHeader: cause.Error()}
+	}
+
+	if err := q.send(queueName, publishing); err != nil {
+		q.logger.
+			Err(err).
+			Fields(map[string]interface{}{"messageId": messageId, "queue": queueName}).
+			Fields(job.Fields()).
+			Msg("Failed to send group sync message")
+		return err
+	}
+
+	q.logger.
+		Info().
+		Fields(map[string]interface{}{"messageId": messageId, "queue": queueName}).
+		Fields(job.Fields()).
+		Msg("Sent group sync message")
+	return nil
 }
@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"github.com/imulab/go-scim/cmd/internal/args"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/urfave/cli/v2"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"time"
+)
+
+// Command returns a cli.Command that manages the MongoDB database. The migrate sub command brings the indexes of the
+// user and group collections in line with the indexes planned from their schemas and metadata.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:        "mongo",
+		Description: "Manage the MongoDB database backing the SCIM resources",
+		Subcommands: []*cli.Command{
+			migrateCommand(),
+		},
+	}
+}
+
+func migrateCommand() *cli.Command {
+	var (
+		scim    = new(args.Scim)
+		mongoDB = new(args.MongoDB)
+		dryRun  bool
+	)
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Only print the difference between the planned and the actual indexes",
+			Destination: &dryRun,
+		},
+	}
+	flags = append(flags, scim.Flags()...)
+	flags = append(flags, mongoDB.Flags()...)
+
+	return &cli.Command{
+		Name:        "migrate",
+		Description: "Print the indexes to create (+), recreate (~) and drop (-) in each collection, and apply the changes",
+		Flags:       flags,
+		Action: func(_ *cli.Context) error {
+			if err := scim.RegisterSchemas(); err != nil {
+				return err
+			}
+			if err := mongoDB.RegisterMetadata(); err != nil {
+				return err
+			}
+
+			var resourceTypes []*spec.ResourceType
+			for _, parse := range []func() (*spec.ResourceType, error){scim.ParseUserResourceType, scim.ParseGroupResourceType} {
+				resourceType, err := parse()
+				if err != nil {
+					return err
+				}
+				resourceTypes = append(resourceTypes, resourceType)
+			}
+
+			connectCtx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancelFunc()
+			client, err := mongoDB.Connect(connectCtx)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = client.Disconnect(context.Background())
+			}()
+
+			database := client.Database(mongoDB.Database, options.Database())
+			if err := Migrate(context.Background(), os.Stdout, database, mongoDB.MetadataRegistry(), resourceTypes, dryRun); err != nil {
+				return fmt.Errorf("failed to migrate indexes: %s", err)
+			}
+			return nil
+		},
+	}
+}
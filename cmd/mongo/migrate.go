@@ -0,0 +1,47 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	scimmongo "github.com/imulab/go-scim/mongo/v2"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+)
+
+// Migrate plans the indexes of each resource type, and compares them with the indexes of its collection, named after
+// the resource type, in the database. The difference is written to out, and applied unless dryRun is set. All plans are
+// checked before any collection is touched, so that an invalid @MongoIndex annotation leaves the database unchanged.
+func Migrate(ctx context.Context, out io.Writer, database *mongo.Database, metadata *scimmongo.MetadataRegistry, resourceTypes []*spec.ResourceType, dryRun bool) error {
+	plans := make([][]*scimmongo.Index, 0, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		plan, err := scimmongo.PlanIndexes(resourceType, metadata)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+
+	for i, resourceType := range resourceTypes {
+		coll := database.Collection(resourceType.Name(), options.Collection())
+		diff, err := scimmongo.DiffIndexes(ctx, coll, plans[i])
+		if err != nil {
+			return err
+		}
+
+		if diff.Empty() {
+			_, _ = fmt.Fprintf(out, "%s: up to date\n", coll.Name())
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "%s:\n%s", coll.Name(), diff.String())
+		if dryRun {
+			continue
+		}
+		if err := diff.Apply(ctx, coll); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "%s: migrated\n", coll.Name())
+	}
+	return nil
+}
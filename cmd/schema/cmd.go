@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Command returns a cli.Command that checks schema definitions. The lint sub command validates schema JSON definitions
+// before they are used by other commands, and the diff sub command reports changes between two versions of a schema.
+func Command() *cli.Command {
+	allowAnnotation := &cli.StringSliceFlag{
+		Name:  "allow-annotation",
+		Usage: "Name of a custom annotation (i.e. @Audited) to accept on any attribute",
+	}
+	return &cli.Command{
+		Name:        "schema",
+		Description: "Validate schema definitions and check compatibility between schema versions",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "lint",
+				Usage:       "lint [files or directories...]",
+				Description: "Check schema JSON definitions for invalid attributes and misapplied annotations",
+				Flags:       []cli.Flag{allowAnnotation},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("at least one schema file or directory is required")
+					}
+					errs, err := Lint(os.Stdout, NewLinter(c.StringSlice("allow-annotation")...), c.Args().Slice()...)
+					if err != nil {
+						return err
+					}
+					if errs > 0 {
+						return fmt.Errorf("found %d errors in schema definitions", errs)
+					}
+					return nil
+				},
+			},
+			{
+				Name:        "diff",
+				Usage:       "diff <old.json> <new.json>",
+				Description: "Report changes from the old version of a schema definition to the new one, and fail on breaking changes",
+				Flags:       []cli.Flag{allowAnnotation},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 2 {
+						return fmt.Errorf("exactly two schema files are required")
+					}
+					breaking, err := Diff(os.Stdout, NewLinter(c.StringSlice("allow-annotation")...), c.Args().Get(0), c.Args().Get(1))
+					if err != nil {
+						return err
+					}
+					if breaking > 0 {
+						return fmt.Errorf("found %d breaking changes", breaking)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
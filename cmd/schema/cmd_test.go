@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	s := new(SchemaTestSuite)
+	suite.Run(t, s)
+}
+
+type SchemaTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+const (
+	testDeviceV1 = `{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[
+		{"id":"urn:imulab:scim:schemas:core:2.0:Device:serialNumber","name":"serialNumber","_path":"serialNumber",
+			"type":"string","uniqueness":"server","_annotations":{"@MongoIndex":{}}},
+		{"id":"urn:imulab:scim:schemas:core:2.0:Device:model","name":"model","_path":"model",
+			"type":"string","_annotations":{"@MongoIndex":{},"@Audited":{}}}]}`
+	testDeviceV2 = `{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[
+		{"id":"urn:imulab:scim:schemas:core:2.0:Device:serialNumber","name":"serialNumber","_path":"serialNumber",
+			"type":"string","uniqueness":"server","mutability":"immutable"},
+		{"id":"urn:imulab:scim:schemas:core:2.0:Device:model","name":"model","_path":"model","type":"string"}]}`
+	testDeviceInvalid = `{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[
+		{"id":"urn:imulab:scim:schemas:core:2.0:Device:serialNumber","name":"serialNumber","_path":"serialNumber",
+			"type":"text"}]}`
+)
+
+func (s *SchemaTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+	s.dir = dir
+
+	for name, content := range map[string]string{
+		"device_v1.json": testDeviceV1,
+		"device_v2.json": testDeviceV2,
+		"readme.txt":     "not a schema",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			s.FailNow(err.Error())
+		}
+	}
+}
+
+func (s *SchemaTestSuite) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *SchemaTestSuite) TestLint() {
+	out := new(bytes.Buffer)
+	errs, err := Lint(out, NewLinter(), s.dir)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, errs)
+	assert.Equal(s.T(), filepath.Join(s.dir, "device_v1.json")+": error: model: unknown annotation @Audited\n"+
+		filepath.Join(s.dir, "device_v1.json")+": warning: serialNumber: @MongoIndex is redundant with the unique index of the attribute\n", out.String())
+
+	out.Reset()
+	errs, err = Lint(out, NewLinter("@Audited"), filepath.Join(s.dir, "device_v1.json"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, errs)
+	assert.Contains(s.T(), out.String(), "warning")
+
+	_, err = Lint(out, NewLinter(), filepath.Join(s.dir, "missing.json"))
+	assert.NotNil(s.T(), err)
+}
+
+func (s *SchemaTestSuite) TestLintMongoIndex() {
+	schema := `{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[
+		{"id":"urn:imulab:scim:schemas:core:2.0:Device:model","name":"model","_path":"model",
+			"type":"string","_annotations":{"@MongoIndex":{"expireAfterSeconds":60}}}]}`
+	problems := NewLinter().Lint([]byte(schema))
+	if assert.Len(s.T(), problems, 1) {
+		assert.Equal(s.T(), `error: model: @MongoIndex parameter "expireAfterSeconds" must be set on a dateTime attribute`, problems[0].String())
+	}
+}
+
+func (s *SchemaTestSuite) TestDiff() {
+	out := new(bytes.Buffer)
+	breaking, err := Diff(out, NewLinter("@Audited"), filepath.Join(s.dir, "device_v1.json"), filepath.Join(s.dir, "device_v2.json"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, breaking)
+	assert.Equal(s.T(), "breaking: mutability of attribute 'serialNumber' tightened from readWrite to immutable\n", out.String())
+
+	invalid := filepath.Join(s.dir, "invalid.json")
+	if err := ioutil.WriteFile(invalid, []byte(testDeviceInvalid), 0644); err != nil {
+		s.FailNow(err.Error())
+	}
+	_, err = Diff(out, NewLinter(), filepath.Join(s.dir, "device_v2.json"), invalid)
+	assert.NotNil(s.T(), err)
+}
@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Diff writes the changes from the schema definition in the old file to the one in the new file to out, one per line,
+// and returns the number of breaking changes. Both definitions must pass the linter without errors, or an error is
+// returned.
+func Diff(out io.Writer, linter *spec.Linter, oldPath string, newPath string) (int, error) {
+	old, err := readSchema(linter, oldPath)
+	if err != nil {
+		return 0, err
+	}
+	new, err := readSchema(linter, newPath)
+	if err != nil {
+		return 0, err
+	}
+
+	breaking := 0
+	for _, change := range spec.DiffSchemas(old, new) {
+		if change.Breaking {
+			breaking++
+		}
+		if _, err := fmt.Fprintln(out, change.String()); err != nil {
+			return breaking, err
+		}
+	}
+	return breaking, nil
+}
+
+// readSchema parses the schema definition in the file, after checking it for errors so that parsing does not panic.
+func readSchema(linter *spec.Linter, path string) (*spec.Schema, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, problem := range linter.Lint(raw) {
+		if !problem.Warning {
+			return nil, fmt.Errorf("%s: %s", path, problem.String())
+		}
+	}
+
+	schema := new(spec.Schema)
+	if err := schema.UnmarshalJSON(raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return schema, nil
+}
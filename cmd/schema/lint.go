@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	scimmongo "github.com/imulab/go-scim/mongo/v2"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// NewLinter returns a spec.Linter that also knows about the annotations defined by the MongoDB module, and accepts
+// the allowed custom annotations anywhere.
+func NewLinter(allowedAnnotations ...string) *spec.Linter {
+	linter := spec.NewLinter().Rule(scimmongo.AnnotationMongoIndex, lintMongoIndex)
+	for _, each := range allowedAnnotations {
+		linter.Rule(each, nil)
+	}
+	return linter
+}
+
+// lintMongoIndex reports invalid @MongoIndex parameters, and warns about @MongoIndex on unique attributes, which are
+// already indexed by their unique index unless the annotation names a compound index.
+func lintMongoIndex(attr *spec.Attribute, _ *spec.Attribute, params map[string]interface{}) []*spec.LintProblem {
+	if err := scimmongo.CheckIndexAnnotation(attr, params); err != nil {
+		return []*spec.LintProblem{{Message: err.Error()}}
+	}
+	if _, named := params["name"]; !named && attr.Uniqueness() != spec.UniquenessNone {
+		return []*spec.LintProblem{{
+			Message: "is redundant with the unique index of the attribute",
+			Warning: true,
+		}}
+	}
+	return nil
+}
+
+// Lint checks all schema JSON definitions found at the paths with the linter, and writes the problems to out, one per
+// line, prefixed with the file name. Directories are searched for .json files, but not recursively. It returns the
+// number of errors found, not counting warnings.
+func Lint(out io.Writer, linter *spec.Linter, paths ...string) (int, error) {
+	files, err := schemaFiles(paths...)
+	if err != nil {
+		return 0, err
+	}
+
+	errs := 0
+	for _, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return errs, err
+		}
+		for _, problem := range linter.Lint(raw) {
+			if !problem.Warning {
+				errs++
+			}
+			if _, err := fmt.Fprintf(out, "%s: %s\n", file, problem.String()); err != nil {
+				return errs, err
+			}
+		}
+	}
+	return errs, nil
+}
+
+func schemaFiles(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var found []string
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+				found = append(found, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
@@ -17,27 +17,30 @@ import (
 // Create a db.DB implementation that persists data in MongoDB. This implementation supports one-to-one correspondence
 // of a SCIM resource type to a MongoDB collection.
 //
-// The database will attempt to create MongoDB indexes on attributes whose uniqueness is global or server, or that has
-// been annotated with "@MongoIndex". For unique attributes, a unique MongoDB index will be created, otherwise, it is
-// just an ordinary index. Any index creation error are treated as non-error and simply ignored.
+// The database does not create MongoDB indexes. The indexes a resource type requires are planned by PlanIndexes, from
+// the uniqueness of its attributes and their "@MongoIndex" annotations, and are compared to the indexes of the
+// collection by DiffIndexes; the resulting IndexDiff creates the missing indexes, or migrates the collection to the
+// plan. Writes violating the planned unique indexes are reported as spec.ErrUniqueness with the path of the offending
+// attribute.
 //
-// This implementation has limited capability of correctly performing field projection according to the specification.
-// It dumbly treats the *crud.Projection parameter as it is without performing any sanitation. As a result, if any
-// returned=always field is excluded, it will not be returned; similarly, if any returned=never field is included,
-// it will be returned. It is expected by downstream calls to perform a pre-sanitation on the parameters or perform
-// a post-guard operation to ensure no sensitive information is leaked.
+// Filters honor caseExact: attributes that are not caseExact are compared regardless of case, and caseExact ones are
+// not. Queries and counts comparing or sorting on attributes that are not caseExact run with the case insensitive
+// collation, so that they can use the indexes above, unless they also sort on, or apply gt, ge, lt or le to, caseExact
+// attributes; such queries, as well as Get, Replace and Delete, compare attributes that are not caseExact with case
+// insensitive regular expressions instead.
 //
-// The "github.com/imulab/go-scim/pkg/v2/json" provides a post-guard operation in its serialization function to ensure
-// returned=never parameters are never leaked. When used with this database, the only situation needs to be worried
-// about is that returned=always parameter may not be returned at all when included intentionally in the "attributes"
-// parameter list. This behaviour might be acceptable. If not, pre-sanitation of the projection list is required.
+// Field projection is resolved by crud.ResolveProjection before being handed to MongoDB: "id" and returned=always
+// fields are always fetched, returned=never fields are never fetched when a projection is specified, and paths are
+// translated to mongo paths (see below). Hence, resources fetched with a projection carry everything the
+// "github.com/imulab/go-scim/pkg/v2/json" serialization would return with the same attributes or excludedAttributes,
+// and no more than necessary. Without projection, the complete document is fetched.
 //
 // If so desired, use Options().IgnoreProjection() to ignore projection altogether and return a complete version of
-// the result every time.
+// the result every time, i.e. when the downstream services need attributes not requested by the client.
 //
-// This implementation also has limited capability to correctly performing sorting according to the specification. The
-// control is not as fine grained as in crud.SeekSortTarget. It can sort on singular type, but may fail if asked to sort
-// on multiValued type, or a singular type within a multiValued type.
+// Sorting follows crud.SeekSortTarget. Sorting on a singular attribute uses the sort option of find, while sorting on a
+// multiValued attribute, or on a sub attribute of one, runs an aggregation pipeline computing the same sort target (see
+// sort.go), so that both return the same order as the in-memory database.
 //
 // This implementation do not directly use the SCIM attribute path to persist into MongoDB. Instead, it uses a concept
 // of MongoDB persistence paths (or mongo paths). These mongo paths are introduced to provide an alternative name to
@@ -61,10 +64,17 @@ func DB(resourceType *spec.ResourceType, coll *mongo.Collection, opt *DBOptions)
 		resourceType: resourceType,
 		superAttr:    resourceType.SuperAttribute(true),
 		coll:         coll,
-		t:            newTransformer(resourceType),
+		t:            newTransformer(resourceType, opt.metadataRegistry()),
 		opt:          opt,
 	}
-	d.ensureIndex()
+	// errors in the plan are left to be reported by the callers of PlanIndexes that verify or migrate the indexes
+	plan, _ := PlanIndexes(resourceType, opt.metadataRegistry())
+	d.uniqueIndexes = make(map[string]*spec.Attribute)
+	for _, idx := range plan {
+		if idx.Unique && len(idx.attributes) == 1 {
+			d.uniqueIndexes[idx.Name] = idx.attributes[0]
+		}
+	}
 	return d
 }
 
@@ -74,23 +84,30 @@ type mongoDB struct {
 	coll         *mongo.Collection
 	t            *transformer
 	opt          *DBOptions
+	// unique attributes by the name of their unique index
+	uniqueIndexes map[string]*spec.Attribute
 }
 
 func (d *mongoDB) Insert(ctx context.Context, resource *prop.Resource) error {
-	_, err := d.coll.InsertOne(ctx, newBsonAdapter(resource), options.InsertOne())
+	_, err := d.coll.InsertOne(ctx, newBsonAdapter(resource, d.opt.metadataRegistry()), options.InsertOne())
 	if err != nil {
-		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+		return d.mongoError(err)
 	}
 	return nil
 }
 
 func (d *mongoDB) Count(ctx context.Context, filter string) (int, error) {
-	tf, err := d.mongoFilter(filter)
+	opt := options.Count()
+
+	tf, collation, err := d.mongoQuery(filter, nil)
 	if err != nil {
 		return 0, err
 	}
+	if collation {
+		opt.SetCollation(caseInsensitiveCollation)
+	}
 
-	n, err := d.coll.CountDocuments(ctx, tf, options.Count())
+	n, err := d.coll.CountDocuments(ctx, tf, opt)
 	if err != nil {
 		return 0, fmt.Errorf("%w: %v", spec.ErrInternal, err)
 	}
@@ -101,7 +118,13 @@ func (d *mongoDB) Count(ctx context.Context, filter string) (int, error) {
 func (d *mongoDB) Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error) {
 	opt := options.FindOne()
 	if !d.opt.ignoreProjection && projection != nil {
-		opt = opt.SetProjection(d.mongoProjection(projection))
+		doc, err := d.mongoProjection(projection)
+		if err != nil {
+			return nil, err
+		}
+		if len(doc) > 0 {
+			opt.SetProjection(doc)
+		}
 	}
 
 	tf, err := d.mongoFilter(fmt.Sprintf("id eq %s", strconv.Quote(id)))
@@ -117,7 +140,7 @@ func (d *mongoDB) Get(ctx context.Context, id string, projection *crud.Projectio
 		return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
 	}
 
-	w := newResourceUnmarshaler(d.resourceType)
+	w := newResourceUnmarshaler(d.resourceType, d.opt.metadataRegistry())
 	if err := sr.Decode(w); err != nil {
 		return nil, err
 	}
@@ -135,12 +158,12 @@ func (d *mongoDB) Replace(ctx context.Context, ref *prop.Resource, resource *pro
 		return err
 	}
 
-	sr := d.coll.FindOneAndReplace(ctx, tf, newBsonAdapter(resource), options.FindOneAndReplace())
+	sr := d.coll.FindOneAndReplace(ctx, tf, newBsonAdapter(resource, d.opt.metadataRegistry()), options.FindOneAndReplace())
 	if err := sr.Err(); err != nil {
 		if err == mongo.ErrNoDocuments {
 			return d.errNotFoundOrModified(id)
 		}
-		return err
+		return d.mongoError(err)
 	}
 
 	return nil
@@ -170,10 +193,26 @@ func (d *mongoDB) Delete(ctx context.Context, resource *prop.Resource) error {
 func (d *mongoDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error) {
 	opt := options.Find()
 
-	tf, err := d.mongoFilter(filter)
+	tf, collation, err := d.mongoQuery(filter, sort)
 	if err != nil {
 		return nil, err
 	}
+	if collation {
+		opt.SetCollation(caseInsensitiveCollation)
+	}
+
+	var project bson.D
+	if !d.opt.ignoreProjection && projection != nil {
+		if project, err = d.mongoProjection(projection); err != nil {
+			return nil, err
+		}
+	}
+
+	if sort != nil && len(sort.By) > 0 {
+		if key, ok := d.mongoSortKey(sort.By); ok {
+			return d.aggregate(ctx, tf, collation, key, sort.Order, pagination, project)
+		}
+	}
 
 	if sort != nil {
 		opt.SetSort(d.mongoSort(sort))
@@ -183,22 +222,26 @@ func (d *mongoDB) Query(ctx context.Context, filter string, sort *crud.Sort, pag
 		opt.SetSkip(skip)
 		opt.SetLimit(limit)
 	}
-	if !d.opt.ignoreProjection && projection != nil {
-		opt.SetProjection(d.mongoProjection(projection))
+	if len(project) > 0 {
+		opt.SetProjection(project)
 	}
 
 	cursor, err := d.coll.Find(ctx, tf, opt)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
 	}
+	return d.decodeAll(ctx, cursor)
+}
 
+// Decode all documents from the cursor to resources, and close the cursor.
+func (d *mongoDB) decodeAll(ctx context.Context, cursor *mongo.Cursor) ([]*prop.Resource, error) {
 	defer func() {
 		_ = cursor.Close(ctx)
 	}()
 
 	results := make([]*prop.Resource, 0)
 	for cursor.Next(ctx) {
-		w := newResourceUnmarshaler(d.resourceType)
+		w := newResourceUnmarshaler(d.resourceType, d.opt.metadataRegistry())
 		if err := cursor.Decode(w); err != nil {
 			return nil, err
 		}
@@ -221,10 +264,26 @@ func (d *mongoDB) Query(ctx context.Context, filter string, sort *crud.Sort, pag
 //
 // If this method is unable to find a path, or encounters any error, an empty string is returned.
 func (d *mongoDB) mongoPathFor(path string) string {
+	curAttr := d.attributeFor(path)
+	if curAttr == nil {
+		return ""
+	}
+
+	mp := curAttr.Path()
+	if md, ok := d.opt.metadataRegistry().Get(curAttr.ID()); ok {
+		mp = md.MongoPath
+	}
+
+	return mp
+}
+
+// Traverse the attributes structure along the tokens in the given path and return the attribute, or nil if the path
+// cannot be resolved.
+func (d *mongoDB) attributeFor(path string) *spec.Attribute {
 	curAttr := d.superAttr
-	cursor, err := expr.CompilePath(path)
+	cursor, err := expr.CompilePathWith(d.resourceType.Registry(), path)
 	if err != nil {
-		return ""
+		return nil
 	}
 
 	// skip the first token in the path starts with the id of the resource type's default schema.
@@ -233,23 +292,18 @@ func (d *mongoDB) mongoPathFor(path string) string {
 		cursor = cursor.Next()
 	}
 	if cursor == nil {
-		return ""
+		return nil
 	}
 
 	for cursor != nil {
 		curAttr = curAttr.SubAttributeForName(cursor.Token())
 		if curAttr == nil {
-			return ""
+			return nil
 		}
 		cursor = cursor.Next()
 	}
 
-	mp := curAttr.Path()
-	if md, ok := metadataHub[curAttr.ID()]; ok {
-		mp = md.MongoPath
-	}
-
-	return mp
+	return curAttr
 }
 
 // Convert the crud.Sort structure to MongoDB driver compatible bson.D structure, so that it can be serialized by the
@@ -284,38 +338,29 @@ func (d *mongoDB) mongoPagination(pagination *crud.Pagination) (skip int64, limi
 	return
 }
 
-// Convert the crud.Projection parameter to Mongo driver compatible bson.D structure. The supplied projection
-// parameter must not be nil and should conform to the constraint that only one of "attributes" and "excludedAttributes"
-// shall be used. This method does not further check for that constraint. If a given path cannot resolve its MongoDB
-// persistence path, it will be skipped.
-func (d *mongoDB) mongoProjection(projection *crud.Projection) bson.D {
-	if len(projection.Attributes) > 0 {
-		include := bson.D{}
-		for _, p := range projection.Attributes {
-			if mp := d.mongoPathFor(p); len(mp) > 0 {
-				include = append(include, bson.E{Key: mp, Value: 1})
-			}
-		}
-		return include
+// Convert the crud.Projection parameter to Mongo driver compatible bson.D structure. The projection is resolved by
+// crud.ResolveProjection, so that "id" and returned=always attributes are always fetched, and returned=never attributes
+// are never fetched. An empty bson.D is returned when the complete document shall be fetched.
+func (d *mongoDB) mongoProjection(projection *crud.Projection) (bson.D, error) {
+	resolved, err := crud.ResolveProjection(d.resourceType, projection)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(projection.ExcludedAttributes) > 0 {
-		exclude := bson.D{}
-		for _, p := range projection.Attributes {
-			if mp := d.mongoPathFor(p); len(mp) > 0 {
-				exclude = append(exclude, bson.E{Key: mp, Value: 0})
-			}
-		}
-		return exclude
+	doc := bson.D{}
+	for _, a := range resolved.Included {
+		doc = append(doc, bson.E{Key: d.mongoPathOf(a), Value: 1})
 	}
-
-	return bson.D{}
+	for _, a := range resolved.Excluded {
+		doc = append(doc, bson.E{Key: d.mongoPathOf(a), Value: 0})
+	}
+	return doc, nil
 }
 
 // Convert the SCIM filter to MongoDB driver compatible bson.D structure. This method uses transformer (see filter.go)
 // to transform the compiled abstract syntax tree of the filter to bson.D containing MongoDB filter directives.
 func (d *mongoDB) mongoFilter(filter string) (bson.D, error) {
-	cf, err := expr.CompileFilter(filter)
+	cf, err := expr.CompileFilterWith(d.resourceType.Registry(), filter)
 	if err != nil {
 		return nil, err
 	}
@@ -326,6 +371,32 @@ func (d *mongoDB) mongoFilter(filter string) (bson.D, error) {
 	return tf, nil
 }
 
+// Convert the SCIM filter of a query, sorted by the optional sort parameter, to MongoDB driver compatible bson.D
+// structure, and report whether the query shall run with caseInsensitiveCollation. The collation is used when the
+// query compares or sorts on attributes that are not caseExact, so that case insensitive indexes can be used, unless
+// it also orders on caseExact attributes; otherwise, case insensitive comparisons are made with regular expressions.
+func (d *mongoDB) mongoQuery(filter string, sort *crud.Sort) (bson.D, bool, error) {
+	cf, err := expr.CompileFilterWith(d.resourceType.Registry(), filter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var sortAttr *spec.Attribute
+	if sort != nil && len(sort.By) > 0 {
+		sortAttr = d.attributeFor(sort.By)
+	}
+
+	t := d.t
+	if t.prefersCollation(cf, sortAttr) {
+		t = t.withCollation()
+	}
+	tf, err := t.transform(cf)
+	if err != nil {
+		return nil, false, err
+	}
+	return tf, t.collation, nil
+}
+
 func (d *mongoDB) errNotFoundOrModified(id string) error {
 	return fmt.Errorf("%w: resource by id '%s' was not found or was modified since by another request", spec.ErrConflict, id)
 }
@@ -337,6 +408,7 @@ func Options() *DBOptions {
 
 type DBOptions struct {
 	ignoreProjection bool
+	metadata         *MetadataRegistry
 }
 
 // Ask the database to ignore any projection parameters. This might be reasonable when the downstream services
@@ -346,6 +418,20 @@ func (opt *DBOptions) IgnoreProjection() *DBOptions {
 	return opt
 }
 
+// Use the given metadata registry to look up MongoDB aliases of attributes, instead of the default registry. This allows
+// databases of different tenants to maintain different metadata.
+func (opt *DBOptions) Metadata(registry *MetadataRegistry) *DBOptions {
+	opt.metadata = registry
+	return opt
+}
+
+func (opt *DBOptions) metadataRegistry() *MetadataRegistry {
+	if opt.metadata == nil {
+		return DefaultMetadataRegistry()
+	}
+	return opt.metadata
+}
+
 var (
 	_ db.DB = (*mongoDB)(nil)
 )
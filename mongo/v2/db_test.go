@@ -3,9 +3,12 @@ package v2
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/admin"
 	"github.com/imulab/go-scim/pkg/v2/crud"
 	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/groupsync"
 	scimjson "github.com/imulab/go-scim/pkg/v2/json"
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/spec"
@@ -13,6 +16,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -20,8 +24,10 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
+	"time"
 )
 
 var (
@@ -489,6 +495,77 @@ func (s *MongoDatabaseTestSuite) TestQuery() {
 				assert.Equal(t, "user005", results[1].Navigator().Dot("userName").Current().Raw())
 			},
 		},
+		{
+			name: "sort by multiValued sub attribute",
+			description: `
+		Tests sorting on a sub attribute of a multiValued attribute, which follows crud.SeekSortTarget.
+		-----------------------------------------------------------------------------------------------
+		Four resources are inserted into to the database:
+		- user001 has emails "c@x.com" and "A@x.com" (primary)
+		- user002 has emails "b@x.com" and "z@x.com"
+		- user003 has emails "d@x.com" and "0@x.com"
+		- user004 has no emails
+		-----------------------------------------------------------------------------------------------
+		Sorting by emails.value in ascending order uses the primary or first email, and expects
+		user001, user002, user003, and then user004 without emails.
+		`,
+			prepare: func(t *testing.T, database db.DB) {
+				for _, f := range []string{
+					`
+{
+  "schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+  "id": "user001",
+  "userName": "user001",
+  "emails": [{"value": "c@x.com"}, {"value": "A@x.com", "primary": true}]
+}
+`,
+					`
+{
+  "schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+  "id": "user002",
+  "userName": "user002",
+  "emails": [{"value": "b@x.com"}, {"value": "z@x.com"}]
+}
+`,
+					`
+{
+  "schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+  "id": "user003",
+  "userName": "user003",
+  "emails": [{"value": "d@x.com"}, {"value": "0@x.com"}]
+}
+`,
+					`
+{
+  "schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+  "id": "user004",
+  "userName": "user004"
+}
+`,
+				} {
+					r := prop.NewResource(s.resourceType)
+					assert.Nil(t, scimjson.Deserialize([]byte(f), r))
+					assert.Nil(t, database.Insert(context.Background(), r))
+				}
+			},
+			filter: "id pr",
+			sort: &crud.Sort{
+				By:    "emails.value",
+				Order: crud.SortAsc,
+			},
+			pagination: nil,
+			projection: &crud.Projection{
+				Attributes: []string{"id"},
+			},
+			expect: func(t *testing.T, results []*prop.Resource, err error) {
+				assert.Nil(t, err)
+				var ids []string
+				for _, r := range results {
+					ids = append(ids, r.IdOrEmpty())
+				}
+				assert.Equal(t, []string{"user001", "user002", "user003", "user004"}, ids)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -504,6 +581,59 @@ func (s *MongoDatabaseTestSuite) TestQuery() {
 	}
 }
 
+func (s *TransformFilterTestSuite) TestMongoProjection() {
+	d := &mongoDB{
+		resourceType: s.resourceType,
+		superAttr:    s.resourceType.SuperAttribute(true),
+		opt:          Options(),
+	}
+
+	tests := []struct {
+		name       string
+		projection *crud.Projection
+		expect     func(t *testing.T, doc bson.D, err error)
+	}{
+		{
+			name:       "attributes",
+			projection: &crud.Projection{Attributes: []string{"userName", "password", "name.givenName"}},
+			expect: func(t *testing.T, doc bson.D, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, bson.D{
+					{Key: "schemas", Value: 1},
+					{Key: "id", Value: 1},
+					{Key: "userName", Value: 1},
+					{Key: "name.givenName", Value: 1},
+				}, doc)
+			},
+		},
+		{
+			name:       "excluded attributes",
+			projection: &crud.Projection{ExcludedAttributes: []string{"id", "emails"}},
+			expect: func(t *testing.T, doc bson.D, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, bson.D{
+					{Key: "password", Value: 0},
+					{Key: "emails", Value: 0},
+				}, doc)
+			},
+		},
+		{
+			name:       "invalid path",
+			projection: &crud.Projection{Attributes: []string{"foo"}},
+			expect: func(t *testing.T, doc bson.D, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidPath))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			doc, err := d.mongoProjection(test.projection)
+			test.expect(t, doc, err)
+		})
+	}
+}
+
 func (s *MongoDatabaseTestSuite) TestSaveGetDeleteCount() {
 	resource := prop.NewResource(s.resourceType)
 	assert.Nil(s.T(), scimjson.Deserialize([]byte(`
@@ -555,6 +685,153 @@ func (s *MongoDatabaseTestSuite) TestSaveGetDeleteCount() {
 	assert.Equal(s.T(), 0, n)
 }
 
+func (s *MongoDatabaseTestSuite) TestIndexes() {
+	client, err := s.newClient()
+	s.Require().Nil(err)
+	coll := client.Database(testMongoDatabaseName).Collection(s.T().Name())
+
+	plan, err := PlanIndexes(s.resourceType, DefaultMetadataRegistry())
+	s.Require().Nil(err)
+
+	diff, err := DiffIndexes(context.Background(), coll, plan)
+	s.Require().Nil(err)
+	assert.Len(s.T(), diff.Missing, len(plan))
+	assert.Nil(s.T(), diff.Apply(context.Background(), coll))
+
+	_, err = coll.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "userName", Value: 1}},
+		Options: options.Index().SetName("idx_stale"),
+	})
+	s.Require().Nil(err)
+
+	diff, err = DiffIndexes(context.Background(), coll, plan)
+	s.Require().Nil(err)
+	assert.Equal(s.T(), []string{"idx_stale"}, diff.Stale)
+	assert.Empty(s.T(), diff.Missing)
+	assert.Empty(s.T(), diff.Changed)
+	assert.Nil(s.T(), diff.Apply(context.Background(), coll))
+
+	diff, err = DiffIndexes(context.Background(), coll, plan)
+	s.Require().Nil(err)
+	assert.True(s.T(), diff.Empty())
+
+	// a changed unique index that cannot be built leaves the existing index in place
+	coll = client.Database(testMongoDatabaseName).Collection(s.T().Name() + "_duplicates")
+	var unique *Index
+	for _, idx := range plan {
+		if idx.Unique && len(idx.Keys) == 1 && !strings.Contains(idx.Keys[0].Key, ".") {
+			unique = idx
+			break
+		}
+	}
+	s.Require().NotNil(unique)
+	_, err = coll.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    unique.Keys,
+		Options: options.Index().SetName(unique.Name),
+	})
+	s.Require().Nil(err)
+	duplicate := bson.D{}
+	for _, k := range unique.Keys {
+		duplicate = append(duplicate, bson.E{Key: k.Key, Value: "duplicate"})
+	}
+	_, err = coll.InsertMany(context.Background(), []interface{}{duplicate, duplicate})
+	s.Require().Nil(err)
+
+	diff, err = DiffIndexes(context.Background(), coll, plan)
+	s.Require().Nil(err)
+	assert.Len(s.T(), diff.Changed, 1)
+	assert.NotNil(s.T(), diff.Apply(context.Background(), coll))
+	_, err = listedIndex(context.Background(), coll, unique.Name)
+	assert.Nil(s.T(), err)
+}
+
+func (s *MongoDatabaseTestSuite) TestDefinitionStore() {
+	client, err := s.newClient()
+	s.Require().Nil(err)
+	store := DefinitionStore(client.Database(testMongoDatabaseName).Collection(s.T().Name()))
+
+	raw := []byte(`{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[]}`)
+	assert.Nil(s.T(), store.Save(context.Background(), &admin.Definition{Kind: admin.KindSchema, ID: "urn:imulab:scim:schemas:core:2.0:Device", Raw: raw}))
+	assert.Nil(s.T(), store.Save(context.Background(), &admin.Definition{Kind: admin.KindSchema, ID: "urn:imulab:scim:schemas:core:2.0:Device", Raw: raw}))
+
+	definitions, err := store.Load(context.Background())
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), definitions, 1)
+	assert.Equal(s.T(), admin.KindSchema, definitions[0].Kind)
+	assert.JSONEq(s.T(), string(raw), string(definitions[0].Raw))
+
+	assert.Nil(s.T(), store.Delete(context.Background(), admin.KindSchema, "urn:imulab:scim:schemas:core:2.0:Device"))
+	definitions, err = store.Load(context.Background())
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), definitions, 0)
+}
+
+func (s *MongoDatabaseTestSuite) TestIDMap() {
+	client, err := s.newClient()
+	s.Require().Nil(err)
+	ids := IDMap(client.Database(testMongoDatabaseName).Collection(s.T().Name()))
+
+	_, ok, err := ids.Get(context.Background(), "l1")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), ok)
+
+	assert.Nil(s.T(), ids.Put(context.Background(), "l1", "r1"))
+	assert.Nil(s.T(), ids.Put(context.Background(), "l2", "r2"))
+	assert.Nil(s.T(), ids.Put(context.Background(), "l1", "r3"))
+	remoteID, ok, err := ids.Get(context.Background(), "l1")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), "r3", remoteID)
+
+	assert.Nil(s.T(), ids.Delete(context.Background(), "l2"))
+	mappings := map[string]string{}
+	assert.Nil(s.T(), ids.ForEach(context.Background(), func(localID string, remoteID string) error {
+		mappings[localID] = remoteID
+		return nil
+	}))
+	assert.Equal(s.T(), map[string]string{"l1": "r3"}, mappings)
+}
+
+func (s *MongoDatabaseTestSuite) TestOutbox() {
+	client, err := s.newClient()
+	s.Require().Nil(err)
+	coll := client.Database(testMongoDatabaseName).Collection(s.T().Name())
+	outbox := Outbox(coll)
+
+	s.Require().Nil(EnsureOutboxIndexes(context.Background(), coll))
+	diff, err := DiffIndexes(context.Background(), coll, OutboxIndexes())
+	s.Require().Nil(err)
+	assert.True(s.T(), diff.Empty())
+
+	entries := groupsync.NewOutboxEntries([]*groupsync.Job{
+		{GroupID: "g1", MemberID: "u1", Trial: 1},
+		{GroupID: "g1", MemberID: "u2", Trial: 1},
+	})
+	assert.Nil(s.T(), outbox.Put(context.Background(), entries))
+
+	// MongoDB stores time in milliseconds, compare with a margin
+	pending, err := outbox.Pending(context.Background(), 10, entries[0].Created.Add(-time.Second))
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), pending, 0)
+
+	assert.Nil(s.T(), outbox.Confirm(context.Background(), entries[1].ID))
+	pending, err = outbox.Pending(context.Background(), 10, entries[0].Created.Add(-time.Second))
+	assert.Nil(s.T(), err)
+	if assert.Len(s.T(), pending, 1) {
+		assert.Equal(s.T(), entries[1].ID, pending[0].ID)
+		assert.Equal(s.T(), "u2", pending[0].Job.MemberID)
+	}
+
+	pending, err = outbox.Pending(context.Background(), 10, entries[0].Created.Add(time.Second))
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), pending, 2)
+
+	assert.Nil(s.T(), outbox.Remove(context.Background(), entries[0].ID, entries[1].ID))
+	pending, err = outbox.Pending(context.Background(), 10, entries[0].Created.Add(time.Second))
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), pending, 0)
+}
+
 // connect to MongoDB docker container before the suite
 func (s *MongoDatabaseTestSuite) SetupSuite() {
 	s.parseResourceType()
@@ -0,0 +1,85 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/admin"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefinitionStore returns an admin.Store that persists the definitions of schemas and resource types in the MongoDB
+// collection. Each definition is saved as a document keyed by its kind and id, with the raw JSON definition saved
+// as a string, because schema ids and attribute names may not be legal MongoDB field names.
+func DefinitionStore(coll *mongo.Collection) admin.Store {
+	return &definitionStore{coll: coll}
+}
+
+type definitionStore struct {
+	coll *mongo.Collection
+}
+
+type definitionDocument struct {
+	Key  string `bson:"_id"`
+	Kind string `bson:"kind"`
+	ID   string `bson:"id"`
+	Raw  string `bson:"raw"`
+}
+
+func (s *definitionStore) Load(ctx context.Context) ([]*admin.Definition, error) {
+	cursor, err := s.coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	defer cursor.Close(ctx)
+
+	definitions := make([]*admin.Definition, 0)
+	for cursor.Next(ctx) {
+		var doc definitionDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+		}
+		definitions = append(definitions, &admin.Definition{
+			Kind: admin.Kind(doc.Kind),
+			ID:   doc.ID,
+			Raw:  []byte(doc.Raw),
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+
+	return definitions, nil
+}
+
+func (s *definitionStore) Save(ctx context.Context, definition *admin.Definition) error {
+	doc := definitionDocument{
+		Key:  definitionKey(definition.Kind, definition.ID),
+		Kind: string(definition.Kind),
+		ID:   definition.ID,
+		Raw:  string(definition.Raw),
+	}
+	_, err := s.coll.ReplaceOne(ctx, bson.D{{Key: "_id", Value: doc.Key}}, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
+
+func (s *definitionStore) Delete(ctx context.Context, kind admin.Kind, id string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: definitionKey(kind, id)}}, options.Delete())
+	if err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
+
+func definitionKey(kind admin.Kind, id string) string {
+	return string(kind) + "/" + id
+}
+
+var (
+	_ admin.Store = (*definitionStore)(nil)
+)
@@ -11,18 +11,20 @@ import (
 )
 
 // Construct a new resource unmarshaler, which could be feed to the unmarshal mechanism of the mongo driver.
-func newResourceUnmarshaler(resourceType *spec.ResourceType) *deserializer {
+func newResourceUnmarshaler(resourceType *spec.ResourceType, metadata *MetadataRegistry) *deserializer {
 	resource := prop.NewResource(resourceType)
 	navigator := resource.Navigator()
 	return &deserializer{
 		resource:  resource,
 		navigator: navigator,
+		metadata:  metadata,
 	}
 }
 
 type deserializer struct {
 	resource  *prop.Resource
 	navigator prop.Navigator
+	metadata  *MetadataRegistry
 }
 
 // Get the de-serialized resource. This should only be called after UnmarshalBSON has been called.
@@ -92,7 +94,7 @@ func (d *deserializer) deserializeComplex(vr bsonrw.ValueReader, isTopLevel bool
 				// if failed, try to find a sub attribute who has a registered MongoDB attribute extension
 				// that matches the name from MongoDB, and focus using the name of that sub attribute.
 				if subAttr := p.Attribute().FindSubAttribute(func(subAttr *spec.Attribute) bool {
-					if md, ok := metadataHub[subAttr.ID()]; !ok {
+					if md, ok := d.metadata.Get(subAttr.ID()); !ok {
 						return false
 					} else {
 						return md.MongoName == name
@@ -118,9 +118,9 @@ func (s *MongoDeserializerTestSuite) TestDeserialize() {
 	for _, test := range tests {
 		s.T().Run(test.name, func(t *testing.T) {
 			resource := test.getResource(t)
-			raw, err := newBsonAdapter(resource).MarshalBSON()
+			raw, err := newBsonAdapter(resource, DefaultMetadataRegistry()).MarshalBSON()
 			assert.Nil(t, err)
-			um := newResourceUnmarshaler(resource.ResourceType())
+			um := newResourceUnmarshaler(resource.ResourceType(), DefaultMetadataRegistry())
 			err = um.UnmarshalBSON(raw)
 			test.expect(t, um.Resource(), err)
 		})
@@ -6,6 +6,7 @@ import (
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -21,9 +22,10 @@ import (
 // is less achievable when directly appending BSON bytes to a buffer.
 
 // Compile and transform a SCIM filter string to a bsonx.Val that contains the original
-// filter in MongoDB compatible format.
+// filter in MongoDB compatible format. The filter is compiled against the schema registry of the resource type, and
+// MongoDB aliases are looked up from the default metadata registry.
 func TransformFilter(scimFilter string, resourceType *spec.ResourceType) (bson.D, error) {
-	root, err := expr.CompileFilter(scimFilter)
+	root, err := expr.CompileFilterWith(resourceType.Registry(), scimFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -34,17 +36,80 @@ func TransformFilter(scimFilter string, resourceType *spec.ResourceType) (bson.D
 // filter in MongoDB compatible format. This slight optimization allow the caller to pre-compile
 // frequently used queries and save the trip to the filter parser and compiler.
 func TransformCompiledFilter(root *expr.Expression, resourceType *spec.ResourceType) (bson.D, error) {
-	return newTransformer(resourceType).transform(root)
+	return newTransformer(resourceType, DefaultMetadataRegistry()).transform(root)
 }
 
-func newTransformer(resourceType *spec.ResourceType) *transformer {
+func newTransformer(resourceType *spec.ResourceType, metadata *MetadataRegistry) *transformer {
 	return &transformer{
 		superAttr: resourceType.SuperAttribute(true),
+		metadata:  metadata,
 	}
 }
 
 type transformer struct {
 	superAttr *spec.Attribute
+	metadata  *MetadataRegistry
+	collation bool
+}
+
+// Returns a copy of the transformer for queries run with caseInsensitiveCollation, which applies to all string
+// comparisons but regular expressions. Case insensitive attributes are then compared with $eq and $ne, which can use
+// the case insensitive indexes, and caseExact attributes with case sensitive regular expressions.
+func (t *transformer) withCollation() *transformer {
+	c := *t
+	c.collation = true
+	return &c
+}
+
+// Returns whether the query of the compiled filter, sorted by the attribute (which may be nil), would benefit from
+// caseInsensitiveCollation. That is the case when it sorts on, or compares, case insensitive attributes, but not when
+// it sorts on, or orders, caseExact attributes, since $gt, $gte, $lt, $lte and sorting would then ignore case.
+func (t *transformer) prefersCollation(root *expr.Expression, sortAttr *spec.Attribute) bool {
+	var insensitive, exact bool
+	mark := func(attr *spec.Attribute, ordering bool) {
+		switch {
+		case attr == nil || !isText(attr):
+		case caseInsensitive(attr):
+			insensitive = true
+		case ordering:
+			exact = true
+		}
+	}
+
+	mark(sortAttr, true)
+	var walk func(node *expr.Expression)
+	walk = func(node *expr.Expression) {
+		switch node.Token() {
+		case expr.And, expr.Or:
+			walk(node.Left())
+			walk(node.Right())
+		case expr.Not:
+			walk(node.Left())
+		case expr.Eq, expr.Ne:
+			mark(t.attributeAt(node.Left()), false)
+		case expr.Gt, expr.Ge, expr.Lt, expr.Le:
+			mark(t.attributeAt(node.Left()), true)
+		}
+	}
+	if root != nil {
+		walk(root)
+	}
+
+	return insensitive && !exact
+}
+
+// Returns the attribute at the compiled path, or nil if the path cannot be resolved.
+func (t *transformer) attributeAt(path *expr.Expression) *spec.Attribute {
+	attr := t.superAttr
+	for ; path != nil; path = path.Next() {
+		if attr.MultiValued() {
+			attr = attr.DeriveElementAttribute()
+		}
+		if attr = attr.SubAttributeForName(path.Token()); attr == nil {
+			return nil
+		}
+	}
+	return attr
 }
 
 // Transform the filter which is represented by the root to bsonx.Val.
@@ -112,7 +177,7 @@ func (t *transformer) transformRelational(containerAttr *spec.Attribute, path *e
 			}
 
 			pathName := cursorAttr.Name()
-			if md, ok := metadataHub[cursorAttr.ID()]; ok {
+			if md, ok := t.metadata.Get(cursorAttr.ID()); ok {
 				pathName = md.MongoName
 			}
 			pathNames = append(pathNames, pathName)
@@ -186,69 +251,56 @@ func (t *transformer) rearrangeForPr(doc bson.D) bson.D {
 	return bson.D{{Key: mongoAnd, Value: newCriterion}}
 }
 
-func (t *transformer) eqValue(attr *spec.Attribute, value *expr.Expression) interface{} {
-	if attr.Type() != spec.TypeString && attr.CaseExact() {
-		return bson.D{
-			{Key: mongoEq, Value: unquote(value.Token())},
-		}
-	} else {
-		return primitive.Regex{
-			Pattern: fmt.Sprintf("^%s$", unquote(value.Token())),
-			Options: "i",
+func (t *transformer) eqValue(attr *spec.Attribute, value *expr.Expression) (interface{}, error) {
+	if !isText(attr) {
+		v, err := t.parseValue(value.Token(), attr)
+		if err != nil {
+			return nil, err
 		}
+		return bson.D{{Key: mongoEq, Value: v}}, nil
+	}
+	// $eq follows the collation: it is used when the collation compares the attribute the way it should be, otherwise
+	// an anchored regular expression, to which the collation never applies, is used instead.
+	if caseInsensitive(attr) == t.collation {
+		return bson.D{{Key: mongoEq, Value: unquote(value.Token())}}, nil
 	}
+	return t.regex(attr, "^%s$", value), nil
 }
 
-func (t *transformer) neValue(attr *spec.Attribute, value *expr.Expression) interface{} {
-	if attr.Type() != spec.TypeString || attr.CaseExact() {
-		return bson.D{
-			{Key: mongoNe, Value: unquote(value.Token())},
-		}
-	} else {
-		return primitive.Regex{
-			Pattern: fmt.Sprintf("^((?!%s$).)", unquote(value.Token())),
-			Options: "i",
+func (t *transformer) neValue(attr *spec.Attribute, value *expr.Expression) (interface{}, error) {
+	if !isText(attr) {
+		v, err := t.parseValue(value.Token(), attr)
+		if err != nil {
+			return nil, err
 		}
+		return bson.D{{Key: mongoNe, Value: v}}, nil
+	}
+	if caseInsensitive(attr) == t.collation {
+		return bson.D{{Key: mongoNe, Value: unquote(value.Token())}}, nil
 	}
+	return t.regex(attr, "^((?!%s$).)", value), nil
 }
 
 func (t *transformer) swValue(attr *spec.Attribute, value *expr.Expression) primitive.Regex {
-	if attr.CaseExact() {
-		return primitive.Regex{
-			Pattern: fmt.Sprintf("^%s", unquote(value.Token())),
-		}
-	} else {
-		return primitive.Regex{
-			Pattern: fmt.Sprintf("^%s", unquote(value.Token())),
-			Options: "i",
-		}
-	}
+	return t.regex(attr, "^%s", value)
 }
 
 func (t *transformer) ewValue(attr *spec.Attribute, value *expr.Expression) primitive.Regex {
-	if attr.CaseExact() {
-		return primitive.Regex{
-			Pattern: fmt.Sprintf("%s$", unquote(value.Token())),
-		}
-	} else {
-		return primitive.Regex{
-			Pattern: fmt.Sprintf("%s$", unquote(value.Token())),
-			Options: "i",
-		}
-	}
+	return t.regex(attr, "%s$", value)
 }
 
 func (t *transformer) coValue(attr *spec.Attribute, value *expr.Expression) primitive.Regex {
-	if attr.CaseExact() {
-		return primitive.Regex{
-			Pattern: unquote(value.Token()),
-		}
-	} else {
-		return primitive.Regex{
-			Pattern: "unquote(value.Token())",
-			Options: "i",
-		}
+	return t.regex(attr, "%s", value)
+}
+
+// Returns a regular expression matching the literal value in the format, which is case insensitive when the attribute
+// is not caseExact.
+func (t *transformer) regex(attr *spec.Attribute, format string, value *expr.Expression) primitive.Regex {
+	r := primitive.Regex{Pattern: fmt.Sprintf(format, regexp.QuoteMeta(unquote(value.Token())))}
+	if caseInsensitive(attr) {
+		r.Options = "i"
 	}
+	return r
 }
 
 func (t *transformer) gtValue(attr *spec.Attribute, value *expr.Expression) (bson.D, error) {
@@ -310,9 +362,9 @@ func (t *transformer) prDoc(attr *spec.Attribute) bson.D {
 func (t *transformer) transformValue(attr *spec.Attribute, op *expr.Expression, value *expr.Expression) (interface{}, error) {
 	switch op.Token() {
 	case expr.Eq:
-		return t.eqValue(attr, value), nil
+		return t.eqValue(attr, value)
 	case expr.Ne:
-		return t.neValue(attr, value), nil
+		return t.neValue(attr, value)
 	case expr.Sw:
 		return t.swValue(attr, value), nil
 	case expr.Ew:
@@ -376,6 +428,22 @@ func (t transformer) parseValue(raw string, attr *spec.Attribute) (interface{},
 	}
 }
 
+// Returns whether the attribute holds text compared by string operators.
+func isText(attr *spec.Attribute) bool {
+	switch attr.Type() {
+	case spec.TypeString, spec.TypeReference, spec.TypeBinary:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns whether the attribute holds text compared regardless of case, i.e. a string or reference that is not
+// caseExact.
+func caseInsensitive(attr *spec.Attribute) bool {
+	return (attr.Type() == spec.TypeString || attr.Type() == spec.TypeReference) && !attr.CaseExact()
+}
+
 func unquote(raw string) string {
 	uq, err := strconv.Unquote(raw)
 	if err != nil {
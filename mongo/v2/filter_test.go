@@ -2,6 +2,7 @@ package v2
 
 import (
 	"encoding/json"
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -78,7 +79,7 @@ func (s *TransformFilterTestSuite) TestTransform() {
 			filter: "emails.value eq \"foo@bar.com\"",
 			expect: func(t *testing.T, extJson string, err error) {
 				assert.Nil(t, err)
-				expect := `{"emails":{"$elemMatch":{"value":{"$regularExpression":{"pattern":"^foo@bar.com$","options":"i"}}}}}`
+				expect := `{"emails":{"$elemMatch":{"value":{"$regularExpression":{"pattern":"^foo@bar\\.com$","options":"i"}}}}}`
 				assert.JSONEq(t, expect, extJson)
 			},
 		},
@@ -114,7 +115,43 @@ func (s *TransformFilterTestSuite) TestTransform() {
 			filter: "emails.value ne \"foo@bar.com\"",
 			expect: func(t *testing.T, extJson string, err error) {
 				assert.Nil(t, err)
-				expect := `{"emails":{"$elemMatch":{"value":{"$regularExpression":{"pattern":"^((?!foo@bar.com$).)","options":"i"}}}}}`
+				expect := `{"emails":{"$elemMatch":{"value":{"$regularExpression":{"pattern":"^((?!foo@bar\\.com$).)","options":"i"}}}}}`
+				assert.JSONEq(t, expect, extJson)
+			},
+		},
+		{
+			name:   "caseExact eq",
+			filter: "id eq \"Foo\"",
+			expect: func(t *testing.T, extJson string, err error) {
+				assert.Nil(t, err)
+				expect := `{"id":{"$eq":"Foo"}}`
+				assert.JSONEq(t, expect, extJson)
+			},
+		},
+		{
+			name:   "boolean eq",
+			filter: "active eq true",
+			expect: func(t *testing.T, extJson string, err error) {
+				assert.Nil(t, err)
+				expect := `{"active":{"$eq":true}}`
+				assert.JSONEq(t, expect, extJson)
+			},
+		},
+		{
+			name:   "top level co",
+			filter: "userName co \"a.b\"",
+			expect: func(t *testing.T, extJson string, err error) {
+				assert.Nil(t, err)
+				expect := `{"userName":{"$regularExpression":{"pattern":"a\\.b","options":"i"}}}`
+				assert.JSONEq(t, expect, extJson)
+			},
+		},
+		{
+			name:   "caseExact sw",
+			filter: "id sw \"Foo\"",
+			expect: func(t *testing.T, extJson string, err error) {
+				assert.Nil(t, err)
+				expect := `{"id":{"$regularExpression":{"pattern":"^Foo","options":""}}}`
 				assert.JSONEq(t, expect, extJson)
 			},
 		},
@@ -149,6 +186,85 @@ func (s *TransformFilterTestSuite) TestTransform() {
 	}
 }
 
+func (s *TransformFilterTestSuite) TestTransformWithCollation() {
+	tests := []struct {
+		name   string
+		filter string
+		expect string
+	}{
+		{
+			name:   "case insensitive eq",
+			filter: "userName eq \"Bob\"",
+			expect: `{"userName":{"$eq":"Bob"}}`,
+		},
+		{
+			name:   "case insensitive ne",
+			filter: "emails.value ne \"foo@bar.com\"",
+			expect: `{"emails":{"$elemMatch":{"value":{"$ne":"foo@bar.com"}}}}`,
+		},
+		{
+			name:   "caseExact eq",
+			filter: "id eq \"Foo\"",
+			expect: `{"id":{"$regularExpression":{"pattern":"^Foo$","options":""}}}`,
+		},
+		{
+			name:   "caseExact multiValued eq",
+			filter: "schemas eq \"foobar\"",
+			expect: `{"schemas":{"$elemMatch":{"$regularExpression":{"pattern":"^foobar$","options":""}}}}`,
+		},
+		{
+			name:   "case insensitive sw",
+			filter: "userName sw \"B\"",
+			expect: `{"userName":{"$regularExpression":{"pattern":"^B","options":"i"}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			cf, err := expr.CompileFilterWith(s.resourceType.Registry(), test.filter)
+			require.Nil(t, err)
+			v, err := newTransformer(s.resourceType, DefaultMetadataRegistry()).withCollation().transform(cf)
+			require.Nil(t, err)
+			raw, err := bson.MarshalExtJSON(v, true, false)
+			require.Nil(t, err)
+			assert.JSONEq(t, test.expect, string(raw))
+		})
+	}
+}
+
+func (s *TransformFilterTestSuite) TestPrefersCollation() {
+	tests := []struct {
+		name   string
+		filter string
+		sortBy string
+		expect bool
+	}{
+		{name: "case insensitive eq", filter: "userName eq \"Bob\"", expect: true},
+		{name: "case insensitive eq within or", filter: "(id eq \"1\") or (emails.value eq \"foo@bar.com\")", expect: true},
+		{name: "caseExact eq", filter: "id eq \"1\"", expect: false},
+		{name: "regular expressions only", filter: "userName sw \"B\"", expect: false},
+		{name: "case insensitive sort", filter: "id pr", sortBy: "userName", expect: true},
+		{name: "caseExact sort", filter: "userName eq \"Bob\"", sortBy: "id", expect: false},
+		{name: "non string sort", filter: "userName eq \"Bob\"", sortBy: "meta.created", expect: true},
+		{name: "caseExact ordering", filter: "(userName eq \"Bob\") and (id gt \"1\")", expect: false},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			cf, err := expr.CompileFilterWith(s.resourceType.Registry(), test.filter)
+			require.Nil(t, err)
+			var sortAttr *spec.Attribute
+			if len(test.sortBy) > 0 {
+				d := &mongoDB{resourceType: s.resourceType, superAttr: s.resourceType.SuperAttribute(true)}
+				sortAttr = d.attributeFor(test.sortBy)
+				require.NotNil(t, sortAttr)
+			}
+			tr := newTransformer(s.resourceType, DefaultMetadataRegistry())
+			assert.Equal(t, test.expect, tr.prefersCollation(cf, sortAttr))
+		})
+	}
+}
+
 func (s *TransformFilterTestSuite) SetupSuite() {
 	for _, each := range []struct {
 		filepath  string
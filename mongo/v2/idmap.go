@@ -0,0 +1,82 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/imulab/go-scim/pkg/v2/connector"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IDMap returns a connector.IDMap storing the mappings as documents in the MongoDB collection, so that they survive
+// restarts and the remote resources are not created again. Each connector needs its own collection, as Reconcile
+// treats every mapping as a resource of the connector.
+func IDMap(collection *mongo.Collection) connector.IDMap {
+	return &idMap{coll: collection}
+}
+
+type idMap struct {
+	coll *mongo.Collection
+}
+
+// idMapDocument is the MongoDB document of a mapping, keyed by the local id.
+type idMapDocument struct {
+	LocalID  string `bson:"_id"`
+	RemoteID string `bson:"remoteId"`
+}
+
+func (m *idMap) Get(ctx context.Context, localID string) (string, bool, error) {
+	document := new(idMapDocument)
+	err := m.coll.FindOne(ctx, bson.M{"_id": localID}, options.FindOne()).Decode(document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return document.RemoteID, true, nil
+}
+
+func (m *idMap) Put(ctx context.Context, localID string, remoteID string) error {
+	_, err := m.coll.ReplaceOne(ctx,
+		bson.M{"_id": localID},
+		&idMapDocument{LocalID: localID, RemoteID: remoteID},
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
+
+func (m *idMap) Delete(ctx context.Context, localID string) error {
+	if _, err := m.coll.DeleteOne(ctx, bson.M{"_id": localID}, options.Delete()); err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
+
+func (m *idMap) ForEach(ctx context.Context, callback func(localID string, remoteID string) error) error {
+	cursor, err := m.coll.Find(ctx, bson.M{}, options.Find())
+	if err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		document := new(idMapDocument)
+		if err := cursor.Decode(document); err != nil {
+			return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+		}
+		if err := callback(document.LocalID, document.RemoteID); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
@@ -1,58 +1,622 @@
 package v2
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/db"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
 	"strings"
 )
 
 const (
-	// @MongoIndex annotates a field so that a corresponding index is generated in MongoDB. If uniqueness=server on
-	// the annotated field, a unique index is generated. Otherwise, an ordinary index is generated.
+	// @MongoIndex annotates a field so that a corresponding index is planned for MongoDB (see PlanIndexes). Unique
+	// attributes (see db.UniqueAttributes) always have a unique index, regardless of this annotation. Indexes are case
+	// insensitive when any of their fields is a string or reference that is not caseExact.
+	//
+	// The annotation accepts the following optional parameters:
+	//	- "name": name of the index, after the "idx_" prefix. Fields annotated with the same name form a compound index.
+	//	- "order": position of the field in the compound index, fields are otherwise ordered as in the schema.
+	//	- "descending": true to index the field in descending order.
+	//	- "unique": true for a unique index.
+	//	- "partial": true to only index documents having the field.
+	//	- "expireAfterSeconds": lifetime of documents after the date of the field, for a TTL index on a dateTime field.
+	// For instance:
+	//	"_annotations": {
+	//		"@MongoIndex": {"name": "name", "order": 1, "partial": true}
+	//	}
 	AnnotationMongoIndex = "@MongoIndex"
 )
 
-func (d *mongoDB) ensureIndex() {
-	d.superAttr.DFS(func(a *spec.Attribute) {
-		if a.Uniqueness() == spec.UniquenessNone {
+// Collation comparing strings regardless of case, as SCIM compares attributes that are not caseExact. Indexes on such
+// attributes use it, and so do queries meant to use these indexes.
+var caseInsensitiveCollation = &options.Collation{Locale: "en", Strength: 2}
+
+const (
+	// MongoDB error code of duplicate key errors
+	codeDuplicateKey = 11000
+	// MongoDB error codes of indexes conflicting with an existing index on the same keys
+	codeIndexOptionsConflict  = 85
+	codeIndexKeySpecsConflict = 86
+	// Prefix of the names of the indexes managed by this package
+	indexPrefix = "idx_"
+	// https://docs.mongodb.com/manual/reference/command/createIndexes/
+	// For MongoDB 4.0 and earlier, the index name has a limit of 127 bytes, here we still adhere to this constraint
+	// without checking for server version.
+	maxIndexNameLength = 127
+)
+
+// Index is an index planned for the MongoDB collection of a resource type.
+type Index struct {
+	// Name of the index, starting with "idx_"
+	Name string
+	// Mongo paths of the indexed fields, with 1 for ascending order, or -1 for descending order
+	Keys bson.D
+	// Whether the index is unique
+	Unique bool
+	// Filter of the documents to index, nil to index all documents
+	PartialFilter bson.D
+	// Lifetime of the documents in seconds for a TTL index, 0 otherwise
+	ExpireAfterSeconds int32
+	// Collation of the index, nil for simple binary comparison
+	Collation *options.Collation
+	// Attributes of the indexed fields
+	attributes []*spec.Attribute
+}
+
+// Model returns the index model to create the index with.
+func (idx *Index) Model() mongo.IndexModel {
+	opt := options.Index().SetName(idx.Name)
+	if idx.Unique {
+		opt.SetUnique(true)
+	}
+	if idx.PartialFilter != nil {
+		opt.SetPartialFilterExpression(idx.PartialFilter)
+	}
+	if idx.ExpireAfterSeconds > 0 {
+		opt.SetExpireAfterSeconds(idx.ExpireAfterSeconds)
+	}
+	if idx.Collation != nil {
+		opt.SetCollation(idx.Collation)
+	}
+	return mongo.IndexModel{Keys: idx.Keys, Options: opt}
+}
+
+// String describes the index in a single line, i.e. "idx_userName (userName: 1) unique partial collation=en/2".
+func (idx *Index) String() string {
+	keys := make([]string, 0, len(idx.Keys))
+	for _, k := range idx.Keys {
+		keys = append(keys, fmt.Sprintf("%s: %v", k.Key, k.Value))
+	}
+	sb := new(strings.Builder)
+	sb.WriteString(fmt.Sprintf("%s (%s)", idx.Name, strings.Join(keys, ", ")))
+	if idx.Unique {
+		sb.WriteString(" unique")
+	}
+	if idx.PartialFilter != nil {
+		sb.WriteString(" partial")
+	}
+	if idx.ExpireAfterSeconds > 0 {
+		sb.WriteString(fmt.Sprintf(" expireAfterSeconds=%d", idx.ExpireAfterSeconds))
+	}
+	if idx.Collation != nil {
+		sb.WriteString(fmt.Sprintf(" collation=%s/%d", idx.Collation.Locale, idx.Collation.Strength))
+	}
+	return sb.String()
+}
+
+// PlanIndexes returns the indexes required by the resource type, derived from its schemas and the mongo paths in the
+// metadata registry:
+//   - a unique index for each unique attribute (see db.UniqueAttributes), only applying to documents having the field,
+//     so that multiple resources can leave the attribute unassigned;
+//   - an index for each group of attributes annotated with @MongoIndex (see AnnotationMongoIndex).
+//
+// Indexes are returned sorted by name. An error is returned if any @MongoIndex annotation is invalid.
+func PlanIndexes(resourceType *spec.ResourceType, metadata *MetadataRegistry) ([]*Index, error) {
+	superAttr := resourceType.SuperAttribute(true)
+	pathOf := func(a *spec.Attribute) string {
+		if md, ok := metadata.Get(a.ID()); ok {
+			return md.MongoPath
+		}
+		return a.Path()
+	}
+
+	var (
+		plan   []*Index
+		unique = make(map[string]struct{})
+	)
+	for _, a := range db.UniqueAttributes(resourceType) {
+		unique[a.ID()] = struct{}{}
+		path := pathOf(a)
+		idx := &Index{
+			Name:          indexPrefix + strings.Replace(path, ".", "_", -1),
+			Keys:          bson.D{{Key: path, Value: 1}},
+			Unique:        true,
+			PartialFilter: bson.D{{Key: path, Value: bson.D{{Key: "$exists", Value: true}}}},
+			attributes:    []*spec.Attribute{a},
+		}
+		plan = append(plan, idx)
+	}
+
+	type field struct {
+		attr   *spec.Attribute
+		params *indexParams
+		pos    int
+	}
+	var (
+		groups = make(map[string][]*field)
+		names  []string
+		errs   []string
+		pos    int
+	)
+	superAttr.DFS(func(a *spec.Attribute) {
+		params, ok := a.Annotation(AnnotationMongoIndex)
+		if !ok {
+			return
+		}
+		p, err := parseIndexParams(a, params)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", a.Path(), err))
 			return
 		}
-		if _, ok := a.Annotation(AnnotationMongoIndex); !ok {
+		if _, isUnique := unique[a.ID()]; isUnique && len(p.name) == 0 {
+			// already indexed by its unique index
 			return
 		}
 
-		path := a.Path()
-		if md, ok := metadataHub[a.ID()]; ok {
-			path = md.MongoPath
+		name := p.name
+		if len(name) == 0 {
+			name = strings.Replace(pathOf(a), ".", "_", -1)
+		}
+		name = indexPrefix + name
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
 		}
+		groups[name] = append(groups[name], &field{attr: a, params: p, pos: pos})
+		pos++
+	})
 
-		idm := mongo.IndexModel{
-			Keys:    bson.D{{Key: path, Value: 1}},
-			Options: options.Index(),
+	for _, name := range names {
+		fields := groups[name]
+		sort.SliceStable(fields, func(i, j int) bool {
+			if fields[i].params.order != fields[j].params.order {
+				return fields[i].params.order < fields[j].params.order
+			}
+			return fields[i].pos < fields[j].pos
+		})
+
+		idx := &Index{Name: name, Keys: bson.D{}}
+		partial := false
+		for _, f := range fields {
+			direction := 1
+			if f.params.descending {
+				direction = -1
+			}
+			idx.Keys = append(idx.Keys, bson.E{Key: pathOf(f.attr), Value: direction})
+			idx.Unique = idx.Unique || f.params.unique
+			partial = partial || f.params.partial
+			if f.params.expireAfterSeconds > 0 {
+				idx.ExpireAfterSeconds = f.params.expireAfterSeconds
+			}
+			idx.attributes = append(idx.attributes, f.attr)
 		}
-		if a.Uniqueness() == spec.UniquenessServer || a.Uniqueness() == spec.UniquenessGlobal {
-			idm.Options.SetUnique(true)
+		if partial {
+			idx.PartialFilter = bson.D{}
+			for _, k := range idx.Keys {
+				idx.PartialFilter = append(idx.PartialFilter, bson.E{Key: k.Key, Value: bson.D{{Key: "$exists", Value: true}}})
+			}
 		}
-		if name := fmt.Sprintf("idx_%s", strings.Replace(path, ".", "_", -1)); len(name) < 127 {
-			// https://docs.mongodb.com/manual/reference/command/createIndexes/
-			// For MongoDB 4.0 and earlier, the index name has a limit of 127 bytes, here we still adhere to this
-			// constraint without checking for server version. If the formed name is greater than 127 bytes, we will
-			// just let MongoDB choose a random name.
-			idm.Options.SetName(name)
+		if idx.ExpireAfterSeconds > 0 && len(idx.Keys) > 1 {
+			errs = append(errs, fmt.Sprintf("TTL index '%s' cannot be compound", name))
 		}
+		plan = append(plan, idx)
+	}
 
-		_, err := d.coll.Indexes().CreateOne(context.Background(), idm, options.CreateIndexes())
-		if err != nil {
-			// https://docs.mongodb.com/manual/reference/command/createIndexes/
-			// Starting from MongoDB 4.2, MongoDB will return error if the index was already created. Previous
-			// version will return ok to indicate implicit success. Here, we regard any error as "not really an
-			// error" and only display warning information to logger.
-			return
+	for _, idx := range plan {
+		for _, a := range idx.attributes {
+			if caseInsensitive(a) {
+				idx.Collation = caseInsensitiveCollation
+			}
 		}
-		return
+		if len(idx.Name) >= maxIndexNameLength {
+			errs = append(errs, fmt.Sprintf("index name '%s' is too long, name it with the \"name\" parameter of %s", idx.Name, AnnotationMongoIndex))
+		}
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Name < plan[j].Name
 	})
+
+	if len(errs) > 0 {
+		return plan, fmt.Errorf("%w: invalid indexes for resource type '%s': %s", spec.ErrInvalidValue, resourceType.ID(), strings.Join(errs, "; "))
+	}
+	return plan, nil
+}
+
+// parameters of @MongoIndex
+type indexParams struct {
+	name               string
+	order              float64
+	descending         bool
+	unique             bool
+	partial            bool
+	expireAfterSeconds int32
+}
+
+// CheckIndexAnnotation returns an error describing the problem if the parameters of the @MongoIndex annotation on the
+// attribute are invalid, i.e. for schema linters.
+func CheckIndexAnnotation(attr *spec.Attribute, params map[string]interface{}) error {
+	_, err := parseIndexParams(attr, params)
+	return err
+}
+
+func parseIndexParams(attr *spec.Attribute, params map[string]interface{}) (*indexParams, error) {
+	p := new(indexParams)
+	invalid := func(key string, expect string) error {
+		return fmt.Errorf("%s parameter \"%s\" must be %s", AnnotationMongoIndex, key, expect)
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var ok bool
+		switch value := params[key]; key {
+		case "name":
+			p.name, ok = value.(string)
+			if !ok || len(p.name) == 0 {
+				return nil, invalid(key, "a non-empty string")
+			}
+		case "order":
+			if p.order, ok = value.(float64); !ok {
+				return nil, invalid(key, "a number")
+			}
+		case "descending":
+			if p.descending, ok = value.(bool); !ok {
+				return nil, invalid(key, "a boolean")
+			}
+		case "unique":
+			if p.unique, ok = value.(bool); !ok {
+				return nil, invalid(key, "a boolean")
+			}
+		case "partial":
+			if p.partial, ok = value.(bool); !ok {
+				return nil, invalid(key, "a boolean")
+			}
+		case "expireAfterSeconds":
+			seconds, ok := value.(float64)
+			if !ok || seconds < 1 || seconds != float64(int32(seconds)) {
+				return nil, invalid(key, "a positive integer")
+			}
+			if attr.Type() != spec.TypeDateTime {
+				return nil, invalid(key, "set on a dateTime attribute")
+			}
+			p.expireAfterSeconds = int32(seconds)
+		default:
+			return nil, fmt.Errorf("%s has unknown parameter \"%s\"", AnnotationMongoIndex, key)
+		}
+	}
+
+	if attr.Type() == spec.TypeComplex {
+		return nil, fmt.Errorf("%s cannot be applied to complex attributes", AnnotationMongoIndex)
+	}
+	return p, nil
+}
+
+// IndexDiff is the difference between the planned indexes of a collection and its actual indexes. Only the indexes
+// named with the "idx_" prefix are managed, other indexes of the collection are left alone.
+type IndexDiff struct {
+	// Planned indexes that do not exist
+	Missing []*Index
+	// Planned indexes that exist with different keys or options
+	Changed []*Index
+	// Names of managed indexes that exist but are not planned
+	Stale []string
+}
+
+// DiffIndexes lists the indexes of the collection, and returns their difference with the plan.
+func DiffIndexes(ctx context.Context, coll *mongo.Collection, plan []*Index) (*IndexDiff, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list indexes of '%s': %v", spec.ErrInternal, coll.Name(), err)
+	}
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	var existing []bson.Raw
+	for cursor.Next(ctx) {
+		existing = append(existing, append(bson.Raw{}, cursor.Current...))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%w: failed to list indexes of '%s': %v", spec.ErrInternal, coll.Name(), err)
+	}
+
+	return diffIndexes(existing, plan), nil
+}
+
+// diffIndexes compares the specifications of existing indexes, as listed by MongoDB, with the plan.
+func diffIndexes(existing []bson.Raw, plan []*Index) *IndexDiff {
+	diff := new(IndexDiff)
+
+	byName := make(map[string]bson.Raw)
+	for _, listed := range existing {
+		if name, ok := listed.Lookup("name").StringValueOK(); ok {
+			byName[name] = listed
+		}
+	}
+
+	planned := make(map[string]struct{})
+	for _, idx := range plan {
+		planned[idx.Name] = struct{}{}
+		if listed, ok := byName[idx.Name]; !ok {
+			diff.Missing = append(diff.Missing, idx)
+		} else if !idx.matches(listed) {
+			diff.Changed = append(diff.Changed, idx)
+		}
+	}
+	for name := range byName {
+		if _, ok := planned[name]; !ok && strings.HasPrefix(name, indexPrefix) {
+			diff.Stale = append(diff.Stale, name)
+		}
+	}
+	sort.Strings(diff.Stale)
+
+	return diff
+}
+
+// matches returns whether the index specification listed by MongoDB is the planned index.
+func (idx *Index) matches(listed bson.Raw) bool {
+	keys, ok := listed.Lookup("key").DocumentOK()
+	if !ok {
+		return false
+	}
+	elements, err := keys.Elements()
+	if err != nil || len(elements) != len(idx.Keys) {
+		return false
+	}
+	for i, e := range elements {
+		direction, ok := rawInt(e.Value())
+		if !ok || e.Key() != idx.Keys[i].Key || direction != int64(idx.Keys[i].Value.(int)) {
+			return false
+		}
+	}
+
+	if unique, _ := listed.Lookup("unique").BooleanOK(); unique != idx.Unique {
+		return false
+	}
+
+	partial, hasPartial := listed.Lookup("partialFilterExpression").DocumentOK()
+	if hasPartial != (idx.PartialFilter != nil) {
+		return false
+	} else if hasPartial {
+		planned, err := bson.Marshal(idx.PartialFilter)
+		if err != nil || !bytes.Equal(planned, partial) {
+			return false
+		}
+	}
+
+	if seconds, _ := rawInt(listed.Lookup("expireAfterSeconds")); seconds != int64(idx.ExpireAfterSeconds) {
+		return false
+	}
+
+	collation, hasCollation := listed.Lookup("collation").DocumentOK()
+	if hasCollation != (idx.Collation != nil) {
+		return false
+	} else if hasCollation {
+		locale, _ := collation.Lookup("locale").StringValueOK()
+		strength, _ := rawInt(collation.Lookup("strength"))
+		if locale != idx.Collation.Locale || strength != int64(idx.Collation.Strength) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the integer value of a numeric bson value.
+func rawInt(v bson.RawValue) (int64, bool) {
+	switch v.Type {
+	case bsontype.Int32:
+		return int64(v.Int32()), true
+	case bsontype.Int64:
+		return v.Int64(), true
+	case bsontype.Double:
+		return int64(v.Double()), true
+	default:
+		return 0, false
+	}
+}
+
+// Empty returns true if the collection has the planned indexes.
+func (diff *IndexDiff) Empty() bool {
+	return len(diff.Missing) == 0 && len(diff.Changed) == 0 && len(diff.Stale) == 0
+}
+
+// String describes the difference, one index per line prefixed by "+" for missing indexes, "~" for changed indexes
+// and "-" for stale indexes.
+func (diff *IndexDiff) String() string {
+	sb := new(strings.Builder)
+	for _, idx := range diff.Missing {
+		sb.WriteString("+ " + idx.String() + "\n")
+	}
+	for _, idx := range diff.Changed {
+		sb.WriteString("~ " + idx.String() + "\n")
+	}
+	for _, name := range diff.Stale {
+		sb.WriteString("- " + name + "\n")
+	}
+	return sb.String()
+}
+
+// CreateMissing creates the missing indexes in the collection, leaving changed and stale indexes alone. It stops at
+// the first failure.
+func (diff *IndexDiff) CreateMissing(ctx context.Context, coll *mongo.Collection) error {
+	for _, idx := range diff.Missing {
+		if _, err := coll.Indexes().CreateOne(ctx, idx.Model()); err != nil {
+			return fmt.Errorf("%w: failed to create index '%s' of '%s': %v", spec.ErrInternal, idx.Name, coll.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Apply creates the missing indexes of the collection, replaces the changed ones, and drops the stale ones last. It
+// stops at the first failure. A changed index is first built under a temporary name, to make sure it can be built,
+// i.e. that the collection has no duplicate for a unique index, before the existing index is dropped. When MongoDB
+// refuses the temporary index as it conflicts with the existing index on the same keys, the existing index is dropped
+// first, and restored if its replacement cannot be built.
+func (diff *IndexDiff) Apply(ctx context.Context, coll *mongo.Collection) error {
+	if err := diff.CreateMissing(ctx, coll); err != nil {
+		return err
+	}
+	for _, idx := range diff.Changed {
+		if err := replaceIndex(ctx, coll, idx); err != nil {
+			return err
+		}
+	}
+	for _, name := range diff.Stale {
+		if _, err := coll.Indexes().DropOne(ctx, name); err != nil {
+			return fmt.Errorf("%w: failed to drop index '%s' of '%s': %v", spec.ErrInternal, name, coll.Name(), err)
+		}
+	}
+	return nil
+}
+
+// replaceIndex replaces the existing index of the same name with the planned index, see Apply.
+func replaceIndex(ctx context.Context, coll *mongo.Collection, idx *Index) error {
+	temporary := *idx
+	temporary.Name = temporaryIndexName(idx.Name)
+	if _, err := coll.Indexes().CreateOne(ctx, temporary.Model()); err == nil {
+		if _, err := coll.Indexes().DropOne(ctx, temporary.Name); err != nil {
+			return fmt.Errorf("%w: failed to drop index '%s' of '%s': %v", spec.ErrInternal, temporary.Name, coll.Name(), err)
+		}
+	} else if code := commandErrorCode(err); code != codeIndexOptionsConflict && code != codeIndexKeySpecsConflict {
+		return fmt.Errorf("%w: failed to build index '%s' of '%s', existing index is left unchanged: %v", spec.ErrInternal, idx.Name, coll.Name(), err)
+	}
+
+	existing, err := listedIndex(ctx, coll, idx.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := coll.Indexes().DropOne(ctx, idx.Name); err != nil {
+		return fmt.Errorf("%w: failed to drop index '%s' of '%s': %v", spec.ErrInternal, idx.Name, coll.Name(), err)
+	}
+	if _, err := coll.Indexes().CreateOne(ctx, idx.Model()); err != nil {
+		if restoreErr := restoreIndex(ctx, coll, existing); restoreErr != nil {
+			return fmt.Errorf("%w: failed to create index '%s' of '%s': %v, and failed to restore the existing index: %v",
+				spec.ErrInternal, idx.Name, coll.Name(), err, restoreErr)
+		}
+		return fmt.Errorf("%w: failed to create index '%s' of '%s', existing index is restored: %v", spec.ErrInternal, idx.Name, coll.Name(), err)
+	}
+	return nil
+}
+
+// temporaryIndexName returns the name of the index built to check that the index of the name can be built.
+func temporaryIndexName(name string) string {
+	const suffix = "_tmp"
+	if len(name)+len(suffix) > maxIndexNameLength {
+		name = name[:maxIndexNameLength-len(suffix)]
+	}
+	return name + suffix
+}
+
+// listedIndex returns the specification of the index of the collection, as listed by MongoDB.
+func listedIndex(ctx context.Context, coll *mongo.Collection, name string) (bson.D, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to list indexes of '%s': %v", spec.ErrInternal, coll.Name(), err)
+	}
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	for cursor.Next(ctx) {
+		if listedName, _ := cursor.Current.Lookup("name").StringValueOK(); listedName != name {
+			continue
+		}
+		var listed bson.D
+		if err := bson.Unmarshal(cursor.Current, &listed); err != nil {
+			return nil, fmt.Errorf("%w: failed to read index '%s' of '%s': %v", spec.ErrInternal, name, coll.Name(), err)
+		}
+		return listed, nil
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%w: failed to list indexes of '%s': %v", spec.ErrInternal, coll.Name(), err)
+	}
+	return nil, fmt.Errorf("%w: index '%s' of '%s' is not found", spec.ErrInternal, name, coll.Name())
+}
+
+// restoreIndex creates the index from its specification, as listed by MongoDB.
+func restoreIndex(ctx context.Context, coll *mongo.Collection, listed bson.D) error {
+	index := bson.D{}
+	for _, e := range listed {
+		// version and namespace are set by MongoDB
+		if e.Key != "v" && e.Key != "ns" {
+			index = append(index, e)
+		}
+	}
+	return coll.Database().RunCommand(ctx, bson.D{
+		{Key: "createIndexes", Value: coll.Name()},
+		{Key: "indexes", Value: bson.A{index}},
+	}).Err()
+}
+
+// commandErrorCode returns the code of the MongoDB command error, or 0.
+func commandErrorCode(err error) int {
+	var ce mongo.CommandError
+	if errors.As(err, &ce) {
+		return int(ce.Code)
+	}
+	return 0
+}
+
+func (d *mongoDB) mongoPathOf(a *spec.Attribute) string {
+	if md, ok := d.opt.metadataRegistry().Get(a.ID()); ok {
+		return md.MongoPath
+	}
+	return a.Path()
+}
+
+func (d *mongoDB) mongoNameOf(a *spec.Attribute) string {
+	if md, ok := d.opt.metadataRegistry().Get(a.ID()); ok {
+		return md.MongoName
+	}
+	return a.Name()
+}
+
+// Convert the error returned by MongoDB to a SCIM error. Duplicate key errors are violations of the unique indexes,
+// and are reported as uniqueness errors with the path of the attribute, when the index can be identified.
+func (d *mongoDB) mongoError(err error) error {
+	var (
+		code    int
+		message string
+	)
+	{
+		var we mongo.WriteException
+		var ce mongo.CommandError
+		switch {
+		case errors.As(err, &we) && len(we.WriteErrors) > 0:
+			code, message = we.WriteErrors[0].Code, we.WriteErrors[0].Message
+		case errors.As(err, &ce):
+			code, message = int(ce.Code), ce.Message
+		}
+	}
+
+	if code != codeDuplicateKey {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	for name, attr := range d.uniqueIndexes {
+		if strings.Contains(message, "index: "+name+" ") {
+			return db.UniquenessError(attr)
+		}
+	}
+	return fmt.Errorf("%w: %s", spec.ErrUniqueness, message)
 }
@@ -0,0 +1,300 @@
+package v2
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"os"
+	"testing"
+)
+
+func TestMongoError(t *testing.T) {
+	attr := new(spec.Attribute)
+	require.Nil(t, attr.UnmarshalJSON([]byte(`{
+		"id": "urn:ietf:params:scim:schemas:core:2.0:User:userName",
+		"name": "userName",
+		"type": "string",
+		"uniqueness": "server",
+		"_path": "userName"
+	}`)))
+	d := &mongoDB{uniqueIndexes: map[string]*spec.Attribute{"idx_userName": attr}}
+
+	tests := []struct {
+		name   string
+		err    error
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name: "duplicate key on insert reports the attribute",
+			err: mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: scim.users index: idx_userName dup key: { userName: "imulab" }`,
+			}}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, spec.ErrUniqueness))
+				assert.Contains(t, err.Error(), "'userName'")
+			},
+		},
+		{
+			name: "duplicate key on replace reports the attribute",
+			err: mongo.CommandError{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: scim.users index: idx_userName dup key: { userName: "imulab" }`,
+			},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, spec.ErrUniqueness))
+				assert.Contains(t, err.Error(), "'userName'")
+			},
+		},
+		{
+			name: "duplicate key on unknown index is still a uniqueness error",
+			err: mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: scim.users index: _id_ dup key: { _id: "1" }`,
+			}}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, spec.ErrUniqueness))
+			},
+		},
+		{
+			name: "other errors are internal errors",
+			err:  mongo.CommandError{Code: 2, Message: "bad value"},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInternal))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.expect(t, d.mongoError(test.err))
+		})
+	}
+}
+
+func TestPlanIndexes(t *testing.T) {
+	s := new(PlanIndexesTestSuite)
+	suite.Run(t, s)
+}
+
+type PlanIndexesTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+	metadata     *MetadataRegistry
+}
+
+func (s *PlanIndexesTestSuite) TestPlanIndexes() {
+	plan, err := PlanIndexes(s.resourceType, s.metadata)
+	require.Nil(s.T(), err)
+
+	var described []string
+	for _, idx := range plan {
+		described = append(described, idx.String())
+	}
+	assert.Equal(s.T(), []string{
+		"idx_expiresAt (expiresAt: 1) expireAfterSeconds=3600",
+		"idx_id (id: 1) unique partial",
+		"idx_model (vendor: -1, model: 1) partial collation=en/2",
+		"idx_owner_ref (owner_ref: 1) unique",
+		"idx_serialNumber (serialNumber: 1) unique partial collation=en/2",
+	}, described)
+
+	raw, err := bson.MarshalExtJSON(plan[2].PartialFilter, false, false)
+	require.Nil(s.T(), err)
+	assert.JSONEq(s.T(), `{"vendor":{"$exists":true},"model":{"$exists":true}}`, string(raw))
+}
+
+func (s *PlanIndexesTestSuite) TestCheckIndexAnnotation() {
+	attr := func(typ string) *spec.Attribute {
+		a := new(spec.Attribute)
+		require.Nil(s.T(), a.UnmarshalJSON([]byte(`{"id":"urn:test:Device:foo","name":"foo","type":"`+typ+`","_path":"foo"}`)))
+		return a
+	}
+
+	tests := []struct {
+		name   string
+		attr   *spec.Attribute
+		params map[string]interface{}
+		expect string // empty if valid
+	}{
+		{
+			name:   "valid",
+			attr:   attr("dateTime"),
+			params: map[string]interface{}{"name": "foo", "order": float64(1), "expireAfterSeconds": float64(60)},
+		},
+		{
+			name:   "unknown parameter",
+			attr:   attr("string"),
+			params: map[string]interface{}{"sparse": true},
+			expect: `@MongoIndex has unknown parameter "sparse"`,
+		},
+		{
+			name:   "wrong type",
+			attr:   attr("string"),
+			params: map[string]interface{}{"unique": "yes"},
+			expect: `@MongoIndex parameter "unique" must be a boolean`,
+		},
+		{
+			name:   "ttl on string",
+			attr:   attr("string"),
+			params: map[string]interface{}{"expireAfterSeconds": float64(60)},
+			expect: `@MongoIndex parameter "expireAfterSeconds" must be set on a dateTime attribute`,
+		},
+		{
+			name:   "fractional ttl",
+			attr:   attr("dateTime"),
+			params: map[string]interface{}{"expireAfterSeconds": 1.5},
+			expect: `@MongoIndex parameter "expireAfterSeconds" must be a positive integer`,
+		},
+		{
+			name:   "complex",
+			attr:   attr("complex"),
+			params: map[string]interface{}{},
+			expect: "@MongoIndex cannot be applied to complex attributes",
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			err := CheckIndexAnnotation(test.attr, test.params)
+			if len(test.expect) == 0 {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, test.expect, err.Error())
+			}
+		})
+	}
+}
+
+func (s *PlanIndexesTestSuite) TestDiffIndexes() {
+	plan, err := PlanIndexes(s.resourceType, s.metadata)
+	require.Nil(s.T(), err)
+
+	listed := func(idx *Index) bson.Raw {
+		d := bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: idx.Keys}, {Key: "name", Value: idx.Name}}
+		if idx.Unique {
+			d = append(d, bson.E{Key: "unique", Value: true})
+		}
+		if idx.PartialFilter != nil {
+			d = append(d, bson.E{Key: "partialFilterExpression", Value: idx.PartialFilter})
+		}
+		if idx.ExpireAfterSeconds > 0 {
+			d = append(d, bson.E{Key: "expireAfterSeconds", Value: int64(idx.ExpireAfterSeconds)})
+		}
+		if idx.Collation != nil {
+			d = append(d, bson.E{Key: "collation", Value: bson.D{
+				{Key: "locale", Value: idx.Collation.Locale},
+				{Key: "caseLevel", Value: false},
+				{Key: "strength", Value: int32(idx.Collation.Strength)},
+			}})
+		}
+		raw, err := bson.Marshal(d)
+		require.Nil(s.T(), err)
+		return raw
+	}
+	raw := func(d bson.D) bson.Raw {
+		raw, err := bson.Marshal(d)
+		require.Nil(s.T(), err)
+		return raw
+	}
+
+	var existing []bson.Raw
+	existing = append(existing, raw(bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}}))
+	for _, idx := range plan[1:] {
+		existing = append(existing, listed(idx))
+	}
+	assert.True(s.T(), diffIndexes(existing, plan[1:]).Empty())
+
+	changed := *plan[4]
+	changed.Collation = nil
+	existing = append(existing[:4], listed(&changed), raw(bson.D{
+		{Key: "key", Value: bson.D{{Key: "model", Value: int32(1)}}},
+		{Key: "name", Value: "idx_model_old"},
+	}), raw(bson.D{
+		{Key: "key", Value: bson.D{{Key: "vendor", Value: int32(1)}}},
+		{Key: "name", Value: "vendor_1"},
+	}))
+
+	diff := diffIndexes(existing, plan)
+	assert.False(s.T(), diff.Empty())
+	assert.Equal(s.T(), "+ idx_expiresAt (expiresAt: 1) expireAfterSeconds=3600\n"+
+		"~ idx_serialNumber (serialNumber: 1) unique partial collation=en/2\n"+
+		"- idx_model_old\n", diff.String())
+}
+
+func (s *PlanIndexesTestSuite) SetupSuite() {
+	f, err := os.Open("../../public/schemas/core_schema.json")
+	require.Nil(s.T(), err)
+	defer f.Close()
+	core := new(spec.Schema)
+	require.Nil(s.T(), json.NewDecoder(f).Decode(core))
+	spec.Schemas().Register(core)
+
+	schema := new(spec.Schema)
+	require.Nil(s.T(), json.Unmarshal([]byte(testIndexSchema), schema))
+	spec.Schemas().Register(schema)
+
+	s.resourceType = new(spec.ResourceType)
+	require.Nil(s.T(), json.Unmarshal([]byte(`{"id":"Device","name":"Device","schema":"urn:test:Device"}`), s.resourceType))
+
+	s.metadata = NewMetadataRegistry()
+	require.Nil(s.T(), s.metadata.Read([]byte(`{"metadata":[
+		{"id":"urn:test:Device:ownerRef","mongoName":"owner_ref","mongoPath":"owner_ref"}
+	]}`)))
+}
+
+const testIndexSchema = `
+{
+  "id": "urn:test:Device",
+  "name": "Device",
+  "attributes": [
+    {
+      "id": "urn:test:Device:serialNumber",
+      "name": "serialNumber",
+      "type": "string",
+      "uniqueness": "server",
+      "_index": 100,
+      "_path": "serialNumber",
+      "_annotations": {"@MongoIndex": {}}
+    },
+    {
+      "id": "urn:test:Device:model",
+      "name": "model",
+      "type": "string",
+      "_index": 101,
+      "_path": "model",
+      "_annotations": {"@MongoIndex": {"name": "model", "order": 2}}
+    },
+    {
+      "id": "urn:test:Device:vendor",
+      "name": "vendor",
+      "type": "string",
+      "_index": 102,
+      "_path": "vendor",
+      "_annotations": {"@MongoIndex": {"name": "model", "order": 1, "descending": true, "partial": true}}
+    },
+    {
+      "id": "urn:test:Device:expiresAt",
+      "name": "expiresAt",
+      "type": "dateTime",
+      "_index": 103,
+      "_path": "expiresAt",
+      "_annotations": {"@MongoIndex": {"expireAfterSeconds": 3600}}
+    },
+    {
+      "id": "urn:test:Device:ownerRef",
+      "name": "ownerRef",
+      "type": "string",
+      "caseExact": true,
+      "_index": 104,
+      "_path": "ownerRef",
+      "_annotations": {"@MongoIndex": {"unique": true}}
+    }
+  ]
+}
+`
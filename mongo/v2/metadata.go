@@ -4,34 +4,75 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"sync"
 )
 
-// a local cache of all metadata
-var metadataHub map[string]*Metadata
+var (
+	defaultMetadata     *MetadataRegistry
+	defaultMetadataOnce sync.Once
+)
 
-func init() {
-	metadataHub = make(map[string]*Metadata)
+// DefaultMetadataRegistry returns the metadata registry shared by all databases that were not given a registry
+// explicitly via DBOptions.Metadata. ReadMetadata and ReadMetadataFromReader register into this registry.
+func DefaultMetadataRegistry() *MetadataRegistry {
+	defaultMetadataOnce.Do(func() {
+		defaultMetadata = NewMetadataRegistry()
+	})
+	return defaultMetadata
 }
 
-// Read metadata and add all metadata to the hub
+// Read metadata and add all metadata to the default registry
 func ReadMetadata(raw []byte) error {
-	return ReadMetadataFromReader(bytes.NewReader(raw))
+	return DefaultMetadataRegistry().Read(raw)
 }
 
-// ReadMetadataFromReader reads and registered the JSON encoded metadata from reader.
+// ReadMetadataFromReader reads and registered the JSON encoded metadata from reader into the default registry.
 func ReadMetadataFromReader(reader io.Reader) error {
+	return DefaultMetadataRegistry().ReadFromReader(reader)
+}
+
+// NewMetadataRegistry returns a new and empty metadata registry. Applications serving multiple tenants may keep one
+// registry per tenant and supply it to DB using DBOptions.Metadata, so that metadata of one tenant's schemas never
+// affects another.
+func NewMetadataRegistry() *MetadataRegistry {
+	return &MetadataRegistry{db: map[string]*Metadata{}}
+}
+
+// MetadataRegistry is a cache of Metadata by their attribute id. It is safe for concurrent use.
+type MetadataRegistry struct {
+	sync.RWMutex
+	db map[string]*Metadata
+}
+
+// Read metadata and add all metadata to the registry
+func (r *MetadataRegistry) Read(raw []byte) error {
+	return r.ReadFromReader(bytes.NewReader(raw))
+}
+
+// ReadFromReader reads and registered the JSON encoded metadata from reader.
+func (r *MetadataRegistry) ReadFromReader(reader io.Reader) error {
 	p := new(struct {
 		Metadata []*Metadata `json:"metadata"`
 	})
 	if err := json.NewDecoder(reader).Decode(p); err != nil {
 		return err
 	}
+	r.Lock()
+	defer r.Unlock()
 	for _, md := range p.Metadata {
-		metadataHub[md.Id] = md
+		r.db[md.Id] = md
 	}
 	return nil
 }
 
+// Get returns the metadata registered for the attribute id, and a boolean indicating whether it exists.
+func (r *MetadataRegistry) Get(attrId string) (md *Metadata, ok bool) {
+	r.RLock()
+	defer r.RUnlock()
+	md, ok = r.db[attrId]
+	return
+}
+
 // Mongo package extension to spec.Attribute. Here we define a MongoDB property alias
 // to override the attribute name when saving to or reading from MongoDB. This is necessary because
 // some valid SCIM field names are not valid in MongoDB.
@@ -0,0 +1,45 @@
+package v2
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+func TestMetadataRegistry(t *testing.T) {
+	s := new(MetadataRegistryTestSuite)
+	suite.Run(t, s)
+}
+
+type MetadataRegistryTestSuite struct {
+	suite.Suite
+}
+
+func (s *MetadataRegistryTestSuite) TestRead() {
+	registry := NewMetadataRegistry()
+	err := registry.Read([]byte(`{
+		"metadata": [
+			{
+				"id": "urn:imulab:scim:2.0:Tenant:$ref",
+				"mongoName": "ref",
+				"mongoPath": "ref"
+			}
+		]
+	}`))
+	assert.Nil(s.T(), err)
+
+	md, ok := registry.Get("urn:imulab:scim:2.0:Tenant:$ref")
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), "ref", md.MongoName)
+
+	_, ok = DefaultMetadataRegistry().Get("urn:imulab:scim:2.0:Tenant:$ref")
+	assert.False(s.T(), ok)
+	_, ok = NewMetadataRegistry().Get("urn:imulab:scim:2.0:Tenant:$ref")
+	assert.False(s.T(), ok)
+}
+
+func (s *MetadataRegistryTestSuite) TestOptions() {
+	registry := NewMetadataRegistry()
+	assert.True(s.T(), DefaultMetadataRegistry() == Options().metadataRegistry())
+	assert.True(s.T(), registry == Options().Metadata(registry).metadataRegistry())
+}
@@ -0,0 +1,131 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/groupsync"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Outbox returns a groupsync.Outbox storing its entries as documents in the MongoDB collection. The collection is
+// expected to live in the same database as the groups, so the jobs are as durable as the changes of the groups.
+func Outbox(collection *mongo.Collection) groupsync.Outbox {
+	return &outbox{coll: collection}
+}
+
+// OutboxIndexes returns the indexes of the outbox collection, serving the query of pending entries: entries confirmed,
+// or created before a time, oldest first.
+func OutboxIndexes() []*Index {
+	return []*Index{
+		{Name: indexPrefix + "created", Keys: bson.D{{Key: "created", Value: 1}, {Key: "_id", Value: 1}}},
+		{Name: indexPrefix + "confirmed_created", Keys: bson.D{{Key: "confirmed", Value: 1}, {Key: "created", Value: 1}, {Key: "_id", Value: 1}}},
+	}
+}
+
+// EnsureOutboxIndexes creates the missing indexes of the outbox collection (see OutboxIndexes).
+func EnsureOutboxIndexes(ctx context.Context, collection *mongo.Collection) error {
+	diff, err := DiffIndexes(ctx, collection, OutboxIndexes())
+	if err != nil {
+		return err
+	}
+	return diff.CreateMissing(ctx, collection)
+}
+
+type outbox struct {
+	coll *mongo.Collection
+}
+
+// outboxDocument is the MongoDB document of groupsync.OutboxEntry.
+type outboxDocument struct {
+	ID        string    `bson:"_id"`
+	GroupID   string    `bson:"groupId"`
+	MemberID  string    `bson:"memberId"`
+	Trial     int       `bson:"trial"`
+	Created   time.Time `bson:"created"`
+	Confirmed bool      `bson:"confirmed"`
+}
+
+func (o *outbox) Put(ctx context.Context, entries []*groupsync.OutboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		documents = append(documents, &outboxDocument{
+			ID:        entry.ID,
+			GroupID:   entry.Job.GroupID,
+			MemberID:  entry.Job.MemberID,
+			Trial:     entry.Job.Trial,
+			Created:   entry.Created,
+			Confirmed: entry.Confirmed,
+		})
+	}
+	if _, err := o.coll.InsertMany(ctx, documents, options.InsertMany()); err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
+
+func (o *outbox) Confirm(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := o.coll.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"confirmed": true}},
+		options.Update())
+	if err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
+
+func (o *outbox) Pending(ctx context.Context, limit int, createdBefore time.Time) ([]*groupsync.OutboxEntry, error) {
+	cursor, err := o.coll.Find(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"confirmed": true},
+			bson.M{"created": bson.M{"$lt": createdBefore}},
+		}},
+		options.Find().SetSort(bson.D{{Key: "created", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	defer cursor.Close(ctx)
+
+	var entries []*groupsync.OutboxEntry
+	for cursor.Next(ctx) {
+		document := new(outboxDocument)
+		if err := cursor.Decode(document); err != nil {
+			return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+		}
+		entries = append(entries, &groupsync.OutboxEntry{
+			ID: document.ID,
+			Job: &groupsync.Job{
+				GroupID:  document.GroupID,
+				MemberID: document.MemberID,
+				Trial:    document.Trial,
+			},
+			Created:   document.Created,
+			Confirmed: document.Confirmed,
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return entries, nil
+}
+
+func (o *outbox) Remove(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := o.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Delete()); err != nil {
+		return fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return nil
+}
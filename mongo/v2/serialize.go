@@ -11,19 +11,21 @@ import (
 
 // Create an adapter to BSON that implements the bson.Marshaler interface so it can be directly
 // feed to MongoDB driver methods.
-func newBsonAdapter(resource *prop.Resource) bson.Marshaler {
-	return &bsonAdapter{resource: resource}
+func newBsonAdapter(resource *prop.Resource, metadata *MetadataRegistry) bson.Marshaler {
+	return &bsonAdapter{resource: resource, metadata: metadata}
 }
 
 // Adapter of resource to bson.Marshaler
 type bsonAdapter struct {
 	resource *prop.Resource
+	metadata *MetadataRegistry
 }
 
 func (d *bsonAdapter) MarshalBSON() ([]byte, error) {
 	visitor := &serializer{
-		buf:   make([]byte, 0),
-		stack: make([]*frame, 0),
+		buf:      make([]byte, 0),
+		stack:    make([]*frame, 0),
+		metadata: d.metadata,
 	}
 
 	err := d.resource.Visit(visitor)
@@ -40,6 +42,8 @@ type serializer struct {
 	// Stack to keep track of traversal context.
 	// Context is pushed and popped only for container properties.
 	stack []*frame
+	// Registry to look up MongoDB aliases
+	metadata *MetadataRegistry
 }
 
 func (s *serializer) ShouldVisit(property prop.Property) bool {
@@ -212,7 +216,7 @@ func (s *serializer) addName(kind byte, attr *spec.Attribute) {
 			name = strconv.Itoa(s.current().index)
 		case mObject, mTop:
 			name = attr.Name()
-			if md, ok := metadataHub[attr.ID()]; ok {
+			if md, ok := s.metadata.Get(attr.ID()); ok {
 				name = md.MongoName
 			}
 		}
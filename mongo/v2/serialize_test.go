@@ -71,7 +71,7 @@ func (s *MongoSerializerTestSuite) TestSerialize() {
 
 	for _, test := range tests {
 		s.T().Run(test.name, func(t *testing.T) {
-			raw, err := newBsonAdapter(test.getResource(t)).MarshalBSON()
+			raw, err := newBsonAdapter(test.getResource(t), DefaultMetadataRegistry()).MarshalBSON()
 			assert.Nil(t, err)
 			assert.Nil(t, bson.Raw(raw).Validate())
 		})
@@ -0,0 +1,149 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+// Sorting on a multiValued attribute, or on a sub attribute of one, cannot be expressed with the sort option of find,
+// which sorts arrays by their smallest element in ascending order, and by their largest element in descending order.
+// Instead, such queries run an aggregation pipeline that computes the same sort target as crud.SeekSortTarget, into a
+// temporary field:
+//	- a sub attribute of a multiValued complex attribute is taken from the element whose primary attribute is true,
+//	  or from the first element;
+//	- a multiValued simple attribute is represented by its first element.
+// Resources without a sort target are sorted last in ascending order, and first in descending order, as
+// crud.Sort.Sort does.
+
+const (
+	// temporary fields holding the sort target, and whether it is missing
+	fieldSortKey     = "__scimSortKey"
+	fieldSortMissing = "__scimSortMissing"
+)
+
+// Returns the aggregation expression of the sort target of the given sortBy path, and true, if the path crosses a
+// multiValued attribute; otherwise, or if the path is invalid, returns false, and the sort option of find shall be used.
+func (d *mongoDB) mongoSortKey(by string) (interface{}, bool) {
+	path, err := expr.CompilePathWith(d.resourceType.Registry(), by)
+	if err != nil || path.ContainsFilter() {
+		return nil, false
+	}
+	if path.Token() == d.resourceType.Schema().ID() {
+		path = path.Next()
+	}
+	if path == nil {
+		return nil, false
+	}
+
+	key, multiValued := d.sortKeyExpr("$", d.superAttr, path, 0)
+	if key == nil || !multiValued {
+		return nil, false
+	}
+	return key, true
+}
+
+// Returns the aggregation expression of the sort target of the path, relative to the field path prefix, along with
+// whether a multiValued attribute was crossed. The expression is nil if the path is invalid.
+func (d *mongoDB) sortKeyExpr(prefix string, attr *spec.Attribute, path *expr.Expression, depth int) (interface{}, bool) {
+	names := make([]string, 0)
+	for path != nil && !attr.MultiValued() {
+		if attr = attr.SubAttributeForName(path.Token()); attr == nil {
+			return nil, false
+		}
+		names = append(names, d.mongoNameOf(attr))
+		path = path.Next()
+	}
+	field := prefix + strings.Join(names, ".")
+
+	switch {
+	case !attr.MultiValued():
+		return field, false
+	case path == nil && attr.Type() == spec.TypeComplex:
+		return nil, true
+	case path == nil:
+		return bson.D{{Key: "$arrayElemAt", Value: bson.A{field, 0}}}, true
+	}
+
+	// select the primary or first element, and resolve the rest of the path within it
+	elements := bson.D{{Key: "$ifNull", Value: bson.A{field, bson.A{}}}}
+	selected := elements
+	if primary := attr.FindSubAttribute(func(subAttr *spec.Attribute) bool {
+		_, ok := subAttr.Annotation(annotation.Primary)
+		return ok && subAttr.Type() == spec.TypeBoolean
+	}); primary != nil {
+		selected = bson.D{{Key: "$concatArrays", Value: bson.A{
+			bson.D{{Key: "$filter", Value: bson.D{
+				{Key: "input", Value: elements},
+				{Key: "cond", Value: bson.D{{Key: "$eq", Value: bson.A{"$$this." + d.mongoNameOf(primary), true}}}},
+			}}},
+			elements,
+		}}}
+	}
+
+	variable := fmt.Sprintf("e%d", depth)
+	inner, _ := d.sortKeyExpr("$$"+variable+".", attr.DeriveElementAttribute(), path, depth+1)
+	if inner == nil {
+		return nil, true
+	}
+	return bson.D{{Key: "$let", Value: bson.D{
+		{Key: "vars", Value: bson.D{{Key: variable, Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{selected, 0}}}}}},
+		{Key: "in", Value: inner},
+	}}}, true
+}
+
+// Returns the aggregation pipeline of a query sorted by the sort target expression. The project parameter is the
+// converted projection (see mongoProjection), empty to fetch the complete documents.
+func (d *mongoDB) sortPipeline(filter bson.D, key interface{}, order crud.SortOrder, pagination *crud.Pagination, project bson.D) bson.A {
+	dir := 1
+	switch order {
+	case crud.SortAsc, crud.SortDefault:
+	case crud.SortDesc:
+		dir = -1
+	default:
+		panic("invalid sort order")
+	}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: filter}},
+		bson.D{{Key: "$addFields", Value: bson.D{{Key: fieldSortKey, Value: key}}}},
+		bson.D{{Key: "$addFields", Value: bson.D{
+			{Key: fieldSortMissing, Value: bson.D{{Key: "$lte", Value: bson.A{"$" + fieldSortKey, nil}}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: fieldSortMissing, Value: dir}, {Key: fieldSortKey, Value: dir}}}},
+	}
+	if pagination != nil {
+		skip, limit := d.mongoPagination(pagination)
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: skip}})
+		if limit > 0 {
+			pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+		}
+	}
+
+	// an inclusion projection leaves out the temporary fields, otherwise, they are excluded explicitly
+	if len(project) == 0 || project[0].Value == 0 {
+		project = append(append(bson.D{}, project...), bson.E{Key: fieldSortKey, Value: 0}, bson.E{Key: fieldSortMissing, Value: 0})
+	}
+	return append(pipeline, bson.D{{Key: "$project", Value: project}})
+}
+
+// Run the query as an aggregation pipeline sorted by the sort target expression.
+func (d *mongoDB) aggregate(ctx context.Context, filter bson.D, collation bool, key interface{}, order crud.SortOrder, pagination *crud.Pagination, project bson.D) ([]*prop.Resource, error) {
+	opt := options.Aggregate()
+	if collation {
+		opt.SetCollation(caseInsensitiveCollation)
+	}
+
+	cursor, err := d.coll.Aggregate(ctx, d.sortPipeline(filter, key, order, pagination, project), opt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", spec.ErrInternal, err)
+	}
+	return d.decodeAll(ctx, cursor)
+}
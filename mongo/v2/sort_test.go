@@ -0,0 +1,118 @@
+package v2
+
+import (
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func (s *TransformFilterTestSuite) TestMongoSortKey() {
+	d := &mongoDB{
+		resourceType: s.resourceType,
+		superAttr:    s.resourceType.SuperAttribute(true),
+		opt:          Options(),
+	}
+
+	tests := []struct {
+		name   string
+		by     string
+		expect string // empty if find shall be used
+	}{
+		{
+			name: "singular",
+			by:   "name.familyName",
+		},
+		{
+			name: "invalid",
+			by:   "foo.bar",
+		},
+		{
+			name:   "multiValued simple",
+			by:     "schemas",
+			expect: `{"$arrayElemAt":["$schemas",{"$numberInt":"0"}]}`,
+		},
+		{
+			name: "sub attribute of multiValued complex",
+			by:   "emails.value",
+			expect: `{"$let":{"vars":{"e0":{"$arrayElemAt":[{"$concatArrays":[
+				{"$filter":{"input":{"$ifNull":["$emails",[]]},"cond":{"$eq":["$$this.primary",true]}}},
+				{"$ifNull":["$emails",[]]}
+			]},{"$numberInt":"0"}]}},"in":"$$e0.value"}}`,
+		},
+		{
+			name:   "multiValued complex",
+			by:     "emails",
+			expect: "",
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			key, ok := d.mongoSortKey(test.by)
+			if len(test.expect) == 0 {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			raw, err := bson.MarshalExtJSON(bson.D{{Key: "key", Value: key}}, true, false)
+			require.Nil(t, err)
+			assert.JSONEq(t, `{"key":`+test.expect+`}`, string(raw))
+		})
+	}
+}
+
+func (s *TransformFilterTestSuite) TestSortPipeline() {
+	d := &mongoDB{
+		resourceType: s.resourceType,
+		superAttr:    s.resourceType.SuperAttribute(true),
+		opt:          Options(),
+	}
+	key, ok := d.mongoSortKey("schemas")
+	require.True(s.T(), ok)
+
+	tests := []struct {
+		name       string
+		order      crud.SortOrder
+		pagination *crud.Pagination
+		project    bson.D
+		expect     string
+	}{
+		{
+			name:  "descending",
+			order: crud.SortDesc,
+			expect: `[
+				{"$match":{}},
+				{"$addFields":{"__scimSortKey":{"$arrayElemAt":["$schemas",{"$numberInt":"0"}]}}},
+				{"$addFields":{"__scimSortMissing":{"$lte":["$__scimSortKey",null]}}},
+				{"$sort":{"__scimSortMissing":{"$numberInt":"-1"},"__scimSortKey":{"$numberInt":"-1"}}},
+				{"$project":{"__scimSortKey":{"$numberInt":"0"},"__scimSortMissing":{"$numberInt":"0"}}}
+			]`,
+		},
+		{
+			name:       "paginated and projected",
+			order:      crud.SortAsc,
+			pagination: &crud.Pagination{StartIndex: 11, Count: 10},
+			project:    bson.D{{Key: "userName", Value: 1}},
+			expect: `[
+				{"$match":{}},
+				{"$addFields":{"__scimSortKey":{"$arrayElemAt":["$schemas",{"$numberInt":"0"}]}}},
+				{"$addFields":{"__scimSortMissing":{"$lte":["$__scimSortKey",null]}}},
+				{"$sort":{"__scimSortMissing":{"$numberInt":"1"},"__scimSortKey":{"$numberInt":"1"}}},
+				{"$skip":{"$numberLong":"10"}},
+				{"$limit":{"$numberLong":"10"}},
+				{"$project":{"userName":{"$numberInt":"1"}}}
+			]`,
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			pipeline := d.sortPipeline(bson.D{}, key, test.order, test.pagination, test.project)
+			raw, err := bson.MarshalExtJSON(bson.D{{Key: "pipeline", Value: pipeline}}, true, false)
+			require.Nil(t, err)
+			assert.JSONEq(t, `{"pipeline":`+test.expect+`}`, string(raw))
+		})
+	}
+}
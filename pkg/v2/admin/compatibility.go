@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"strings"
+)
+
+// CheckSchema returns an error describing all changes from the old schema to the new one that are incompatible with
+// resources persisted under the old schema, or nil if the new schema is compatible. See spec.DiffSchemas for which
+// changes are incompatible.
+func CheckSchema(old *spec.Schema, new *spec.Schema) error {
+	var problems []string
+	for _, each := range spec.DiffSchemas(old, new) {
+		if each.Breaking {
+			problems = append(problems, each.Message)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: incompatible change to schema '%s': %s", spec.ErrInvalidValue, new.ID(), strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// CheckResourceType returns an error describing all changes from the old resource type to the new one that are
+// incompatible with resources persisted under the old resource type, or nil if the new resource type is compatible.
+// A change is incompatible if it changes the main schema, removes a schema extension, or adds a required schema
+// extension or makes an existing one required. Name, description and endpoint may change freely.
+func CheckResourceType(old *spec.ResourceType, new *spec.ResourceType) error {
+	if old.ID() != new.ID() {
+		return fmt.Errorf("%w: resource type id cannot change from '%s' to '%s'", spec.ErrInvalidValue, old.ID(), new.ID())
+	}
+
+	var problems []string
+
+	if old.Schema().ID() != new.Schema().ID() {
+		problems = append(problems, fmt.Sprintf("main schema changed from '%s' to '%s'", old.Schema().ID(), new.Schema().ID()))
+	}
+
+	oldExtensions := extensions(old)
+	newExtensions := extensions(new)
+	_ = old.ForEachExtension(func(extension *spec.Schema, required bool) error {
+		if _, ok := newExtensions[extension.ID()]; !ok {
+			problems = append(problems, fmt.Sprintf("schema extension '%s' was removed", extension.ID()))
+		}
+		return nil
+	})
+	_ = new.ForEachExtension(func(extension *spec.Schema, required bool) error {
+		wasRequired, ok := oldExtensions[extension.ID()]
+		switch {
+		case !ok && required:
+			problems = append(problems, fmt.Sprintf("new schema extension '%s' is required", extension.ID()))
+		case ok && !wasRequired && required:
+			problems = append(problems, fmt.Sprintf("schema extension '%s' became required", extension.ID()))
+		}
+		return nil
+	})
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: incompatible change to resource type '%s': %s", spec.ErrInvalidValue, new.ID(), strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func extensions(resourceType *spec.ResourceType) map[string]bool {
+	m := map[string]bool{}
+	_ = resourceType.ForEachExtension(func(extension *spec.Schema, required bool) error {
+		m[extension.ID()] = required
+		return nil
+	})
+	return m
+}
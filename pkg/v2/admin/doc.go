@@ -0,0 +1,3 @@
+// Package admin provides a service to manage schemas and resource types at runtime, together with the compatibility
+// checks guarding their updates and the store interface to persist them.
+package admin
@@ -0,0 +1,455 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Endpoints reserved by the specification, which resource types cannot be served at.
+var reservedEndpoints = []string{"/ServiceProviderConfig", "/Schemas", "/ResourceTypes", "/Bulk", "/Me", "/.search"}
+
+// New returns a Service that manages the schemas in the registry, and persists changes to the store. Resource types
+// loaded by other means should be put under management using Adopt, before persisted definitions are applied using Load.
+func New(registry *spec.SchemaRegistry, store Store) *Service {
+	s := &Service{
+		registry:      registry,
+		store:         store,
+		linter:        spec.NewLinter(),
+		reserved:      map[string]bool{},
+		resourceTypes: map[string]*managedResourceType{},
+	}
+	s.Reserve(reservedEndpoints...)
+	return s
+}
+
+// Service registers, updates and retires schemas and resource types at runtime. Schema definitions are checked by the
+// linter, and rejected if it reports errors. Updates are checked for compatibility with the previous definition (see
+// CheckSchema and CheckResourceType), so that resources persisted under the previous definition remain valid. Every change is persisted to the Store before it takes effect, and listeners registered with
+// Subscribe are notified of the resulting set of resource types.
+//
+// Updating a schema re-parses all resource types that use it, so listeners will receive new resource type instances for
+// them. Resource types not affected by a change keep their instances.
+//
+// Retiring a definition that was not registered through the Service (i.e. loaded from files at startup) only lasts
+// until the next restart, unless the source of that definition is removed as well.
+type Service struct {
+	sync.RWMutex
+	registry      *spec.SchemaRegistry
+	store         Store
+	linter        *spec.Linter
+	reserved      map[string]bool
+	resourceTypes map[string]*managedResourceType
+	listeners     []func(resourceTypes []*spec.ResourceType)
+}
+
+type managedResourceType struct {
+	raw          []byte
+	resourceType *spec.ResourceType
+}
+
+// Reserve reserves the endpoints so that resource types cannot be served at them, in addition to the endpoints
+// reserved by the specification.
+func (s *Service) Reserve(endpoints ...string) *Service {
+	s.Lock()
+	defer s.Unlock()
+	for _, each := range endpoints {
+		s.reserved[strings.ToLower(each)] = true
+	}
+	return s
+}
+
+// Linter sets the linter checking schema definitions, which defaults to spec.NewLinter. Schemas using annotations
+// defined outside the annotation package require a linter that knows about them.
+func (s *Service) Linter(linter *spec.Linter) *Service {
+	s.Lock()
+	defer s.Unlock()
+	s.linter = linter
+	return s
+}
+
+// Subscribe registers the listener to be called with all resource types after each change. Listeners are called
+// sequentially and must not call methods of the Service that make changes.
+func (s *Service) Subscribe(listener func(resourceTypes []*spec.ResourceType)) {
+	s.Lock()
+	defer s.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Adopt puts resource types that were loaded by other means under management. Adopted resource types are not
+// persisted until they are updated through the Service.
+func (s *Service) Adopt(resourceTypes ...*spec.ResourceType) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, each := range resourceTypes {
+		raw, err := json.Marshal(each)
+		if err != nil {
+			return err
+		}
+		crud.Register(each)
+		s.resourceTypes[each.ID()] = &managedResourceType{raw: raw, resourceType: each}
+	}
+	return nil
+}
+
+// Load applies all definitions persisted in the store on top of the ones already known to the Service, and notifies
+// the listeners.
+func (s *Service) Load(ctx context.Context) error {
+	definitions, err := s.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	changed := map[string]bool{}
+	for _, each := range definitions {
+		if each.Kind != KindSchema {
+			continue
+		}
+		schema, err := s.parseSchema(each.Raw)
+		if err != nil {
+			return err
+		}
+		s.registry.Register(schema)
+		changed[schema.ID()] = true
+	}
+	if err := s.reparse(changed); err != nil {
+		return err
+	}
+
+	for _, each := range definitions {
+		if each.Kind != KindResourceType {
+			continue
+		}
+		resourceType, err := s.parseResourceType(each.Raw)
+		if err != nil {
+			return err
+		}
+		s.resourceTypes[resourceType.ID()] = &managedResourceType{raw: each.Raw, resourceType: resourceType}
+	}
+
+	s.notify()
+	return nil
+}
+
+// Schema returns the schema by the id, and a boolean indicating whether it exists.
+func (s *Service) Schema(id string) (*spec.Schema, bool) {
+	return s.registry.Get(id)
+}
+
+// ResourceType returns the resource type by the id, and a boolean indicating whether it exists.
+func (s *Service) ResourceType(id string) (*spec.ResourceType, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if m, ok := s.resourceTypes[id]; ok {
+		return m.resourceType, true
+	}
+	return nil, false
+}
+
+// ResourceTypes returns all managed resource types, sorted by their id.
+func (s *Service) ResourceTypes() []*spec.ResourceType {
+	s.RLock()
+	defer s.RUnlock()
+	return s.list()
+}
+
+// RegisterSchema registers a new schema from its JSON definition. The definition must be in the same format as the
+// schema files loaded at startup.
+func (s *Service) RegisterSchema(ctx context.Context, raw []byte) (*spec.Schema, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	schema, err := s.parseSchema(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.registry.Get(schema.ID()); ok {
+		return nil, fmt.Errorf("%w: schema '%s' already exists", spec.ErrUniqueness, schema.ID())
+	}
+	if err := s.store.Save(ctx, &Definition{Kind: KindSchema, ID: schema.ID(), Raw: raw}); err != nil {
+		return nil, err
+	}
+
+	s.registry.Register(schema)
+	s.notify()
+	return schema, nil
+}
+
+// UpdateSchema replaces an existing schema with the JSON definition, if compatible. All resource types using the
+// schema are re-parsed.
+func (s *Service) UpdateSchema(ctx context.Context, raw []byte) (*spec.Schema, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	schema, err := s.parseSchema(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	old, ok := s.registry.Get(schema.ID())
+	if !ok {
+		return nil, fmt.Errorf("%w: schema '%s' does not exist", spec.ErrNotFound, schema.ID())
+	}
+	if err := CheckSchema(old, schema); err != nil {
+		return nil, err
+	}
+	if err := s.store.Save(ctx, &Definition{Kind: KindSchema, ID: schema.ID(), Raw: raw}); err != nil {
+		return nil, err
+	}
+
+	s.registry.Register(schema)
+	if err := s.reparse(map[string]bool{schema.ID(): true}); err != nil {
+		// compatible schemas do not fail to parse resource types, this only happens if the registry was tampered with.
+		return nil, err
+	}
+	s.notify()
+	return schema, nil
+}
+
+// RetireSchema removes the schema by the id. The core schema, and schemas used by any resource type, cannot be retired.
+func (s *Service) RetireSchema(ctx context.Context, id string) error {
+	if id == spec.CoreSchemaId {
+		return fmt.Errorf("%w: core schema cannot be retired", spec.ErrInvalidValue)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.registry.Get(id); !ok {
+		return fmt.Errorf("%w: schema '%s' does not exist", spec.ErrNotFound, id)
+	}
+	for _, m := range s.resourceTypes {
+		if usesSchema(m.resourceType, id) {
+			return fmt.Errorf("%w: schema '%s' is used by resource type '%s'", spec.ErrConflict, id, m.resourceType.ID())
+		}
+	}
+	if err := s.store.Delete(ctx, KindSchema, id); err != nil {
+		return err
+	}
+
+	s.registry.Unregister(id)
+	s.notify()
+	return nil
+}
+
+// RegisterResourceType registers a new resource type from its JSON definition. All schemas used by the resource type
+// must have been registered.
+func (s *Service) RegisterResourceType(ctx context.Context, raw []byte) (*spec.ResourceType, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	resourceType, err := s.parseResourceType(raw)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.resourceTypes[resourceType.ID()]; ok {
+		return nil, fmt.Errorf("%w: resource type '%s' already exists", spec.ErrUniqueness, resourceType.ID())
+	}
+	if err := s.checkEndpoint(resourceType); err != nil {
+		return nil, err
+	}
+	if err := s.store.Save(ctx, &Definition{Kind: KindResourceType, ID: resourceType.ID(), Raw: raw}); err != nil {
+		return nil, err
+	}
+
+	s.resourceTypes[resourceType.ID()] = &managedResourceType{raw: raw, resourceType: resourceType}
+	s.notify()
+	return resourceType, nil
+}
+
+// UpdateResourceType replaces an existing resource type with the JSON definition, if compatible.
+func (s *Service) UpdateResourceType(ctx context.Context, raw []byte) (*spec.ResourceType, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	resourceType, err := s.parseResourceType(raw)
+	if err != nil {
+		return nil, err
+	}
+	old, ok := s.resourceTypes[resourceType.ID()]
+	if !ok {
+		return nil, fmt.Errorf("%w: resource type '%s' does not exist", spec.ErrNotFound, resourceType.ID())
+	}
+	if err := CheckResourceType(old.resourceType, resourceType); err != nil {
+		return nil, err
+	}
+	if err := s.checkEndpoint(resourceType); err != nil {
+		return nil, err
+	}
+	if err := s.store.Save(ctx, &Definition{Kind: KindResourceType, ID: resourceType.ID(), Raw: raw}); err != nil {
+		return nil, err
+	}
+
+	s.resourceTypes[resourceType.ID()] = &managedResourceType{raw: raw, resourceType: resourceType}
+	s.notify()
+	return resourceType, nil
+}
+
+// RetireResourceType removes the resource type by the id. Resources of the retired resource type are left in the
+// database untouched.
+func (s *Service) RetireResourceType(ctx context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.resourceTypes[id]; !ok {
+		return fmt.Errorf("%w: resource type '%s' does not exist", spec.ErrNotFound, id)
+	}
+	if err := s.store.Delete(ctx, KindResourceType, id); err != nil {
+		return err
+	}
+
+	delete(s.resourceTypes, id)
+	s.notify()
+	return nil
+}
+
+// reparse re-parses the resource types using any of the schemas, so they pick up the latest registered schemas.
+// Caller must hold the lock.
+func (s *Service) reparse(schemaIds map[string]bool) error {
+	for id, m := range s.resourceTypes {
+		affected := false
+		for schemaId := range schemaIds {
+			if usesSchema(m.resourceType, schemaId) {
+				affected = true
+				break
+			}
+		}
+		if !affected {
+			continue
+		}
+
+		resourceType, err := s.parseResourceType(m.raw)
+		if err != nil {
+			return err
+		}
+		s.resourceTypes[id] = &managedResourceType{raw: m.raw, resourceType: resourceType}
+	}
+	return nil
+}
+
+// parseResourceType parses and validates the resource type against the registry, and registers its schema ids as
+// URN prefixes.
+func (s *Service) parseResourceType(raw []byte) (*spec.ResourceType, error) {
+	var probe struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Endpoint string `json:"endpoint"`
+		Schema   string `json:"schema"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, fmt.Errorf("%w: malformed resource type definition", spec.ErrInvalidSyntax)
+	}
+	if len(probe.ID) == 0 || len(probe.Name) == 0 || len(probe.Schema) == 0 {
+		return nil, fmt.Errorf("%w: resource type requires id, name and schema", spec.ErrInvalidValue)
+	}
+	if !strings.HasPrefix(probe.Endpoint, "/") || len(probe.Endpoint) < 2 || strings.Contains(probe.Endpoint[1:], "/") {
+		return nil, fmt.Errorf("%w: endpoint of resource type '%s' must be a single path segment such as '/Users'", spec.ErrInvalidValue, probe.ID)
+	}
+
+	resourceType, err := s.registry.ParseResourceType(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: resource type '%s' uses a schema that does not exist", spec.ErrInvalidValue, probe.ID)
+	}
+	crud.Register(resourceType)
+	return resourceType, nil
+}
+
+// checkEndpoint checks that the endpoint of the resource type is neither reserved nor used by another resource type.
+// Caller must hold the lock.
+func (s *Service) checkEndpoint(resourceType *spec.ResourceType) error {
+	endpoint := strings.ToLower(resourceType.Endpoint())
+	if s.reserved[endpoint] {
+		return fmt.Errorf("%w: endpoint '%s' is reserved", spec.ErrInvalidValue, resourceType.Endpoint())
+	}
+	for id, m := range s.resourceTypes {
+		if id != resourceType.ID() && strings.ToLower(m.resourceType.Endpoint()) == endpoint {
+			return fmt.Errorf("%w: endpoint '%s' is used by resource type '%s'", spec.ErrUniqueness, resourceType.Endpoint(), id)
+		}
+	}
+	return nil
+}
+
+// Caller must hold the lock.
+func (s *Service) notify() {
+	if len(s.listeners) == 0 {
+		return
+	}
+	resourceTypes := s.list()
+	for _, listener := range s.listeners {
+		listener(resourceTypes)
+	}
+}
+
+// Caller must hold the lock.
+func (s *Service) list() []*spec.ResourceType {
+	resourceTypes := make([]*spec.ResourceType, 0, len(s.resourceTypes))
+	for _, m := range s.resourceTypes {
+		resourceTypes = append(resourceTypes, m.resourceType)
+	}
+	sort.Slice(resourceTypes, func(i, j int) bool {
+		return resourceTypes[i].ID() < resourceTypes[j].ID()
+	})
+	return resourceTypes
+}
+
+// parseSchema parses the JSON definition of a schema, after checking it with the linter, so that parsing does not panic
+// on invalid characteristics. Warnings of the linter are ignored.
+func (s *Service) parseSchema(raw []byte) (*spec.Schema, error) {
+	if !json.Valid(raw) {
+		return nil, fmt.Errorf("%w: malformed schema definition", spec.ErrInvalidSyntax)
+	}
+
+	var problems []string
+	for _, each := range s.linter.Lint(raw) {
+		if !each.Warning {
+			problems = append(problems, each.String())
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: invalid schema definition: %s", spec.ErrInvalidValue, strings.Join(problems, "; "))
+	}
+
+	schema := new(spec.Schema)
+	if err := json.Unmarshal(raw, schema); err != nil {
+		return nil, fmt.Errorf("%w: malformed schema definition", spec.ErrInvalidSyntax)
+	}
+
+	if len(schema.ID()) == 0 {
+		return nil, fmt.Errorf("%w: schema requires id", spec.ErrInvalidValue)
+	} else if schema.ID() == spec.CoreSchemaId {
+		return nil, fmt.Errorf("%w: core schema cannot be managed", spec.ErrInvalidValue)
+	}
+
+	err := schema.ForEachAttribute(func(attr *spec.Attribute) (err error) {
+		attr.DFS(func(a *spec.Attribute) {
+			if err == nil && (len(a.Name()) == 0 || len(a.ID()) == 0 || len(a.Path()) == 0) {
+				err = fmt.Errorf("%w: attributes of schema '%s' require name, id and _path", spec.ErrInvalidValue, schema.ID())
+			}
+		})
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func usesSchema(resourceType *spec.ResourceType, schemaId string) bool {
+	used := resourceType.Schema().ID() == schemaId
+	_ = resourceType.ForEachExtension(func(extension *spec.Schema, required bool) error {
+		used = used || extension.ID() == schemaId
+		return nil
+	})
+	return used
+}
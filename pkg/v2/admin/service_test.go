@@ -0,0 +1,288 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"testing"
+)
+
+func TestService(t *testing.T) {
+	s := new(ServiceTestSuite)
+	suite.Run(t, s)
+}
+
+type ServiceTestSuite struct {
+	suite.Suite
+	registry *spec.SchemaRegistry
+	store    Store
+	service  *Service
+	notified [][]*spec.ResourceType
+}
+
+const (
+	testExtensionId = "urn:imulab:scim:schemas:extension:test:2.0:Group"
+	testDeviceId    = "urn:imulab:scim:schemas:core:2.0:Device"
+)
+
+const testExtension = `
+{
+  "id": "urn:imulab:scim:schemas:extension:test:2.0:Group",
+  "name": "TestGroup",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:schemas:extension:test:2.0:Group:costCenter",
+      "name": "costCenter",
+      "type": "string",
+      "_index": 0,
+      "_path": "costCenter"
+    }
+  ]
+}
+`
+
+const testDevice = `
+{
+  "id": "urn:imulab:scim:schemas:core:2.0:Device",
+  "name": "Device",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:schemas:core:2.0:Device:serialNumber",
+      "name": "serialNumber",
+      "type": "string",
+      "required": true,
+      "_index": 0,
+      "_path": "serialNumber"
+    },
+    {
+      "id": "urn:imulab:scim:schemas:core:2.0:Device:owner",
+      "name": "owner",
+      "type": "complex",
+      "_index": 1,
+      "_path": "owner",
+      "subAttributes": [
+        {
+          "id": "urn:imulab:scim:schemas:core:2.0:Device:owner.value",
+          "name": "value",
+          "type": "string",
+          "_index": 0,
+          "_path": "owner.value"
+        }
+      ]
+    }
+  ]
+}
+`
+
+const testDeviceResourceType = `
+{
+  "id": "Device",
+  "name": "Device",
+  "endpoint": "/Devices",
+  "schema": "urn:imulab:scim:schemas:core:2.0:Device"
+}
+`
+
+func (s *ServiceTestSuite) SetupTest() {
+	s.registry = spec.NewSchemaRegistry()
+	for _, path := range []string{
+		"../../../public/schemas/core_schema.json",
+		"../../../public/schemas/group_schema.json",
+	} {
+		schema, err := parseSchemaFile(path)
+		if err != nil {
+			s.FailNow(err.Error())
+		}
+		s.registry.Register(schema)
+	}
+
+	raw, err := ioutil.ReadFile("../../../public/resource_types/group_resource_type.json")
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+	group, err := s.registry.ParseResourceType(raw)
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+
+	s.store = MemoryStore()
+	s.service = New(s.registry, s.store).Reserve("/health")
+	s.notified = nil
+	s.service.Subscribe(func(resourceTypes []*spec.ResourceType) {
+		s.notified = append(s.notified, resourceTypes)
+	})
+	assert.Nil(s.T(), s.service.Adopt(group))
+}
+
+func (s *ServiceTestSuite) TestRegisterResourceType() {
+	ctx := context.Background()
+
+	_, err := s.service.RegisterResourceType(ctx, []byte(testDeviceResourceType))
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue), "schema is not registered yet")
+
+	schema, err := s.service.RegisterSchema(ctx, []byte(testDevice))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), testDeviceId, schema.ID())
+
+	_, err = s.service.RegisterSchema(ctx, []byte(testDevice))
+	assert.True(s.T(), errors.Is(err, spec.ErrUniqueness))
+
+	device, err := s.service.RegisterResourceType(ctx, []byte(testDeviceResourceType))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), s.registry, device.Registry())
+
+	_, err = s.service.RegisterResourceType(ctx, []byte(testDeviceResourceType))
+	assert.True(s.T(), errors.Is(err, spec.ErrUniqueness))
+
+	for _, raw := range []string{
+		`{"id":"Other","name":"Other","endpoint":"/devices","schema":"urn:imulab:scim:schemas:core:2.0:Device"}`,
+		`{"id":"Other","name":"Other","endpoint":"/Schemas","schema":"urn:imulab:scim:schemas:core:2.0:Device"}`,
+		`{"id":"Other","name":"Other","endpoint":"/health","schema":"urn:imulab:scim:schemas:core:2.0:Device"}`,
+		`{"id":"Other","name":"Other","endpoint":"/a/b","schema":"urn:imulab:scim:schemas:core:2.0:Device"}`,
+		`{"id":"Other","name":"Other","endpoint":"/Others"}`,
+	} {
+		_, err = s.service.RegisterResourceType(ctx, []byte(raw))
+		assert.NotNil(s.T(), err, raw)
+	}
+
+	assert.Len(s.T(), s.notified, 2)
+	assert.Len(s.T(), s.notified[1], 2)
+	assert.Equal(s.T(), "Device", s.notified[1][0].ID())
+	assert.Equal(s.T(), "Group", s.notified[1][1].ID())
+
+	definitions, err := s.store.Load(ctx)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), definitions, 2)
+}
+
+func (s *ServiceTestSuite) TestUpdateSchema() {
+	ctx := context.Background()
+
+	group, _ := s.service.ResourceType("Group")
+	_, err := s.service.RegisterSchema(ctx, []byte(testExtension))
+	assert.Nil(s.T(), err)
+
+	_, err = s.service.UpdateResourceType(ctx, []byte(`{"id":"Group","name":"Group","endpoint":"/Groups",
+		"schema":"urn:ietf:params:scim:schemas:core:2.0:Group",
+		"schemaExtensions":[{"schema":"urn:imulab:scim:schemas:extension:test:2.0:Group","required":true}]}`))
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue), "new extension cannot be required")
+
+	updated, err := s.service.UpdateResourceType(ctx, []byte(`{"id":"Group","name":"Group","endpoint":"/Groups",
+		"schema":"urn:ietf:params:scim:schemas:core:2.0:Group",
+		"schemaExtensions":[{"schema":"urn:imulab:scim:schemas:extension:test:2.0:Group","required":false}]}`))
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), group != updated)
+	assert.Equal(s.T(), 1, updated.CountExtensions())
+
+	// compatible: new optional attribute
+	_, err = s.service.UpdateSchema(ctx, []byte(`{"id":"urn:imulab:scim:schemas:extension:test:2.0:Group","name":"TestGroup","attributes":[
+		{"id":"urn:imulab:scim:schemas:extension:test:2.0:Group:costCenter","name":"costCenter","type":"string","_index":0,"_path":"costCenter"},
+		{"id":"urn:imulab:scim:schemas:extension:test:2.0:Group:division","name":"division","type":"string","_index":1,"_path":"division"}]}`))
+	assert.Nil(s.T(), err)
+
+	reparsed, _ := s.service.ResourceType("Group")
+	assert.True(s.T(), reparsed != updated, "resource type using the schema is re-parsed")
+	assert.NotNil(s.T(), reparsed.SuperAttribute(false).SubAttributeForName(testExtensionId).SubAttributeForName("division"))
+
+	// incompatible: removed and retyped attributes
+	_, err = s.service.UpdateSchema(ctx, []byte(`{"id":"urn:imulab:scim:schemas:extension:test:2.0:Group","name":"TestGroup","attributes":[
+		{"id":"urn:imulab:scim:schemas:extension:test:2.0:Group:costCenter","name":"costCenter","type":"integer","_index":0,"_path":"costCenter"}]}`))
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue))
+	assert.Contains(s.T(), err.Error(), "attribute 'division' was removed")
+	assert.Contains(s.T(), err.Error(), "type of attribute 'costCenter' changed from string to integer")
+
+	_, err = s.service.UpdateSchema(ctx, []byte(`{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[]}`))
+	assert.True(s.T(), errors.Is(err, spec.ErrNotFound))
+
+	_, err = s.service.UpdateSchema(ctx, []byte(`{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[{"name":"x","type":"unknown"}]}`))
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue))
+}
+
+func (s *ServiceTestSuite) TestLintSchema() {
+	ctx := context.Background()
+	audited := `{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[
+		{"id":"urn:imulab:scim:schemas:core:2.0:Device:serialNumber","name":"serialNumber","type":"string","_index":0,"_path":"serialNumber",
+		"_annotations":{"@Audited":{}}}]}`
+
+	for _, raw := range []string{
+		`{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[
+			{"id":"urn:imulab:scim:schemas:core:2.0:Device:serialNumber","name":"serialNumber","type":"strin","_index":0,"_path":"serialNumber"}]}`,
+		`{"id":"urn:imulab:scim:schemas:core:2.0:Device","name":"Device","attributes":[
+			{"id":"urn:imulab:scim:schemas:core:2.0:Device:serialNumber","name":"serialNumber","type":"string","_index":0,"_path":"serialNumber",
+			"_annotations":{"@Enum":{}}}]}`,
+		audited,
+	} {
+		_, err := s.service.RegisterSchema(ctx, []byte(raw))
+		assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue), raw)
+	}
+
+	_, err := s.service.RegisterSchema(ctx, []byte(`{"id":`))
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidSyntax))
+
+	_, err = s.service.Linter(spec.NewLinter().Rule("@Audited", nil)).RegisterSchema(ctx, []byte(audited))
+	assert.Nil(s.T(), err)
+}
+
+func (s *ServiceTestSuite) TestRetire() {
+	ctx := context.Background()
+
+	assert.True(s.T(), errors.Is(s.service.RetireSchema(ctx, spec.CoreSchemaId), spec.ErrInvalidValue))
+	assert.True(s.T(), errors.Is(s.service.RetireSchema(ctx, "urn:ietf:params:scim:schemas:core:2.0:Group"), spec.ErrConflict))
+	assert.True(s.T(), errors.Is(s.service.RetireSchema(ctx, testDeviceId), spec.ErrNotFound))
+
+	_, _ = s.service.RegisterSchema(ctx, []byte(testDevice))
+	_, _ = s.service.RegisterResourceType(ctx, []byte(testDeviceResourceType))
+
+	assert.True(s.T(), errors.Is(s.service.RetireSchema(ctx, testDeviceId), spec.ErrConflict))
+	assert.Nil(s.T(), s.service.RetireResourceType(ctx, "Device"))
+	assert.True(s.T(), errors.Is(s.service.RetireResourceType(ctx, "Device"), spec.ErrNotFound))
+	assert.Nil(s.T(), s.service.RetireSchema(ctx, testDeviceId))
+
+	_, ok := s.registry.Get(testDeviceId)
+	assert.False(s.T(), ok)
+	assert.Len(s.T(), s.service.ResourceTypes(), 1)
+
+	definitions, err := s.store.Load(ctx)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), definitions, 0)
+}
+
+func (s *ServiceTestSuite) TestLoad() {
+	ctx := context.Background()
+	_, _ = s.service.RegisterSchema(ctx, []byte(testDevice))
+	_, _ = s.service.RegisterResourceType(ctx, []byte(testDeviceResourceType))
+
+	// simulate a restart with the same store
+	registry := spec.NewSchemaRegistry()
+	core, _ := s.registry.Get(spec.CoreSchemaId)
+	registry.Register(core)
+
+	service := New(registry, s.store)
+	var notified []*spec.ResourceType
+	service.Subscribe(func(resourceTypes []*spec.ResourceType) {
+		notified = resourceTypes
+	})
+	assert.Nil(s.T(), service.Load(ctx))
+
+	_, ok := registry.Get(testDeviceId)
+	assert.True(s.T(), ok)
+	device, ok := service.ResourceType("Device")
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), "/Devices", device.Endpoint())
+	assert.Len(s.T(), notified, 1)
+}
+
+func parseSchemaFile(path string) (*spec.Schema, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := new(spec.Schema)
+	if err := schema.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Kind of definitions managed by the Service.
+type Kind string
+
+const (
+	KindSchema       Kind = "Schema"
+	KindResourceType Kind = "ResourceType"
+)
+
+// Definition is the persisted JSON definition of a schema or a resource type, in the same format as the files
+// loaded at startup.
+type Definition struct {
+	Kind Kind            `json:"kind"`
+	ID   string          `json:"id"`
+	Raw  json.RawMessage `json:"raw"`
+}
+
+// Store persists the definitions managed by the Service, so they survive restarts.
+type Store interface {
+	// Load returns all persisted definitions.
+	Load(ctx context.Context) ([]*Definition, error)
+	// Save persists the definition, overwriting any definition of the same kind and id.
+	Save(ctx context.Context, definition *Definition) error
+	// Delete removes the definition of the kind and id. Deleting a definition that does not exist is not an error.
+	Delete(ctx context.Context, kind Kind, id string) error
+}
+
+// MemoryStore returns a Store that keeps definitions in memory. It is intended for testing and for servers that do
+// not need to keep definitions across restarts.
+func MemoryStore() Store {
+	return &memoryStore{db: map[Kind]map[string]*Definition{}}
+}
+
+type memoryStore struct {
+	sync.RWMutex
+	db map[Kind]map[string]*Definition
+}
+
+func (m *memoryStore) Load(_ context.Context) ([]*Definition, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	definitions := make([]*Definition, 0)
+	for _, byId := range m.db {
+		for _, each := range byId {
+			definitions = append(definitions, each)
+		}
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		if definitions[i].Kind != definitions[j].Kind {
+			return definitions[i].Kind < definitions[j].Kind
+		}
+		return definitions[i].ID < definitions[j].ID
+	})
+	return definitions, nil
+}
+
+func (m *memoryStore) Save(_ context.Context, definition *Definition) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.db[definition.Kind] == nil {
+		m.db[definition.Kind] = map[string]*Definition{}
+	}
+	m.db[definition.Kind][definition.ID] = definition
+	return nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, kind Kind, id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.db[kind], id)
+	return nil
+}
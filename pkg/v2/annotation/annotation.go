@@ -40,6 +40,11 @@ const (
 	// a integer parameter named "cost". This will determine the strength of the bCrypt hashing. If omitted, default
 	// cost is 10. The value replacement does not trigger event propagation, it is strictly local.
 	BCrypt = "@BCrypt"
+	// @PasswordHash annotates a string property or a binary property. The value of the property will be hashed and
+	// replace the original value, in the PHC string format. The annotation takes a string parameter named "algorithm",
+	// which defaults to "argon2id"; "scrypt" and "bcrypt" are also available. Other parameters are passed to the
+	// algorithm. Passwords hashed by other algorithms, or with other parameters, are rehashed when verified.
+	PasswordHash = "@PasswordHash"
 	// @ReadOnly annotates a readOnly property and indicates how filters should handle its value. Two options are
 	// available. The first a boolean named "reset": if true, filters shall delete the property value; The second
 	// is a boolean named "copy": if true, filters shall copy value from the reference property, if available.
@@ -48,4 +53,32 @@ const (
 	// canonicalValues. The defined values will be treated as strings and compared with respect to the caseExact
 	// setting.
 	Enum = "@Enum"
+	// @Encrypted annotates a singular string, reference or binary property whose value is encrypted in the database.
+	// The value is encrypted before it is written, and decrypted after it is read, hence it appears in plaintext to
+	// API callers. The annotation takes a boolean parameter named "blindIndex": if true, a deterministic blind index of
+	// the value is stored along with the encrypted value so the property can be filtered with the eq operator. Blind
+	// indexes are not available to binary properties. Encrypted properties cannot be filtered otherwise, or sorted,
+	// and their uniqueness cannot be enforced, as every encryption of the same value is different.
+	Encrypted = "@Encrypted"
+	// @Default annotates a property whose value is supplied by the annotation parameter named "value" when it is
+	// unassigned on creation. The value must be compatible with the attribute: a JSON array for multiValued attributes.
+	Default = "@Default"
+	// @Normalize annotates a singular string property whose value is normalized before it is validated and stored. The
+	// annotation takes boolean parameters that enable the steps to apply, in the following order: "nfc" applies
+	// Unicode normalization form C, "trim" removes leading and trailing white spaces, "lowercase" converts to lower
+	// case, and "e164" formats phone numbers in E.164. Phone numbers without the international prefix are prefixed
+	// with the string parameter "countryCode", if present, or rejected otherwise.
+	Normalize = "@Normalize"
+	// @Computed annotates a singular string property whose value is derived from other properties on every write. The
+	// annotation takes a string parameter named "template", where paths enclosed in curly braces are replaced by the
+	// values of the properties, for instance "{name.givenName} {name.familyName}". Paths are resolved from the root
+	// of the resource, and multiValued properties contribute their primary, or first, element. Consecutive white spaces
+	// in the result are collapsed, and an empty result unassigns the property.
+	Computed = "@Computed"
+	// @Reference annotates a complex property referring to other resources by their id in its "value" sub property,
+	// and whose "$ref" sub property lists the names of the referenced resource types in referenceTypes. The string
+	// parameter "onDelete" decides what happens to the property when the referenced resource is deleted: "remove"
+	// (default) removes the element from a multiValued property, or unassigns a singular property, "restrict" blocks
+	// the deletion, and "ignore" leaves the property as it is. Such properties are checked without this annotation.
+	Reference = "@Reference"
 )
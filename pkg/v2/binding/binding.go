@@ -0,0 +1,181 @@
+package binding
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// New creates a Binding between the resource type and the struct type of the prototype, which can be either a struct
+// or a pointer to struct, i.e. User{} or (*User)(nil). All tagged fields are validated against the resource type. An
+// error wrapping spec.ErrInvalidPath is returned if a tag does not address an attribute, and an error wrapping
+// spec.ErrInvalidValue is returned if the type of the field is incompatible with the attribute.
+func New(resourceType *spec.ResourceType, prototype interface{}) (*Binding, error) {
+	typ := reflect.TypeOf(prototype)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: binding prototype must be a struct or a pointer to struct", spec.ErrInvalidValue)
+	}
+
+	b := &Binding{
+		resourceType: resourceType,
+		typ:          typ,
+	}
+
+	fields, err := compileFields(typ, typ.Name(), func(tag string) ([]string, *spec.Attribute, error) {
+		return resolve(resourceType, tag)
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.fields = fields
+
+	return b, nil
+}
+
+// Binding loads and stores resources of a resource type from and to a struct type.
+type Binding struct {
+	resourceType *spec.ResourceType
+	typ          reflect.Type
+	fields       []*field
+}
+
+// ResourceType returns the resource type of the binding.
+func (b *Binding) ResourceType() *spec.ResourceType {
+	return b.resourceType
+}
+
+// Load copies the attribute values of the resource into the bound fields of the target, which must be a pointer to
+// the struct type of the binding. Fields whose attribute is unassigned are set to their zero value.
+func (b *Binding) Load(resource *prop.Resource, target interface{}) error {
+	if err := b.checkResource(resource); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != b.typ {
+		return fmt.Errorf("%w: load target must be a non-nil *%s", spec.ErrInvalidValue, b.typ)
+	}
+	v = v.Elem()
+
+	for _, f := range b.fields {
+		nav := resource.Navigator()
+		for _, segment := range f.segments {
+			if nav.Dot(segment).HasError() {
+				return nav.Error()
+			}
+		}
+
+		var raw interface{}
+		if !nav.Current().IsUnassigned() {
+			raw = nav.Current().Raw()
+		}
+
+		if err := f.node.load(raw, v.FieldByIndex(f.index), b.typ.Name()+"."+f.name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Store copies the bound fields of the source, which can be the struct type of the binding or a pointer to it, into
+// the resource. Attributes of fields representing unassigned values are deleted from the resource.
+func (b *Binding) Store(source interface{}, resource *prop.Resource) error {
+	if err := b.checkResource(resource); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(source)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Type() != b.typ {
+		return fmt.Errorf("%w: store source must be %s or *%s", spec.ErrInvalidValue, b.typ, b.typ)
+	}
+
+	for _, f := range b.fields {
+		raw, err := f.node.store(v.FieldByIndex(f.index))
+		if err != nil {
+			return err
+		}
+
+		nav := resource.Navigator()
+		for _, segment := range f.segments {
+			if nav.Dot(segment).HasError() {
+				return nav.Error()
+			}
+		}
+
+		if raw == nil {
+			nav.Delete()
+		} else {
+			nav.Replace(raw)
+		}
+		if nav.HasError() {
+			return nav.Error()
+		}
+	}
+
+	return nil
+}
+
+// NewResource creates a new resource of the resource type of the binding, and stores the source into it.
+func (b *Binding) NewResource(source interface{}) (*prop.Resource, error) {
+	resource := prop.NewResource(b.resourceType)
+	if err := b.Store(source, resource); err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+func (b *Binding) checkResource(resource *prop.Resource) error {
+	if resource == nil || resource.ResourceType().ID() != b.resourceType.ID() {
+		return fmt.Errorf("%w: resource is not of resource type '%s'", spec.ErrInvalidValue, b.resourceType.Name())
+	}
+	return nil
+}
+
+// resolve resolves the path in the tag of a top level field to the segments to navigate to the attribute, and the
+// attribute itself. Schema URN prefix of the main schema is dropped, and that of extension schemas is kept as the
+// first segment, as extension attributes are nested in a complex attribute named after the schema id.
+func resolve(resourceType *spec.ResourceType, path string) ([]string, *spec.Attribute, error) {
+	var (
+		super    = resourceType.SuperAttribute(true)
+		segments []string
+	)
+
+	lowerPath := strings.ToLower(path)
+	_ = resourceType.ForEachExtension(func(extension *spec.Schema, _ bool) error {
+		if lowerPath == strings.ToLower(extension.ID()) {
+			segments = []string{extension.ID()}
+		} else if prefix := strings.ToLower(extension.ID()) + ":"; strings.HasPrefix(lowerPath, prefix) {
+			segments = append([]string{extension.ID()}, strings.Split(path[len(prefix):], ".")...)
+		}
+		return nil
+	})
+	if segments == nil {
+		if prefix := strings.ToLower(resourceType.Schema().ID()) + ":"; strings.HasPrefix(lowerPath, prefix) {
+			path = path[len(prefix):]
+		}
+		segments = strings.Split(path, ".")
+	}
+
+	attr := super
+	for i, segment := range segments {
+		if attr.MultiValued() {
+			return nil, nil, fmt.Errorf("%w: path '%s' traverses multiValued attribute '%s'", spec.ErrInvalidPath, path, attr.Path())
+		}
+		if attr = attr.SubAttributeForName(segment); attr == nil {
+			return nil, nil, fmt.Errorf("%w: path '%s' does not address an attribute of resource type '%s'", spec.ErrInvalidPath, path, resourceType.Name())
+		}
+		segments[i] = attr.Name()
+	}
+
+	return segments, attr, nil
+}
@@ -0,0 +1,371 @@
+package binding
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBinding(t *testing.T) {
+	s := new(BindingTestSuite)
+	suite.Run(t, s)
+}
+
+type BindingTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+}
+
+type (
+	testUser struct {
+		testResource
+		UserName       string       `scim:"userName"`
+		GivenName      *string      `scim:"urn:ietf:params:scim:schemas:core:2.0:User:name.givenName"`
+		Active         *bool        `scim:"active"`
+		Emails         []testEmail  `scim:"emails"`
+		EmployeeNumber string       `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber"`
+		Level          int64        `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:level"`
+		Manager        *testManager `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager"`
+		Ignored        string       `scim:"-"`
+	}
+	testResource struct {
+		Schemas []string  `scim:"schemas"`
+		ID      string    `scim:"id"`
+		Created time.Time `scim:"meta.created"`
+	}
+	testEmail struct {
+		Value   string `scim:"value"`
+		Type    string `scim:"type"`
+		Primary bool   `scim:"primary"`
+	}
+	testManager struct {
+		Value       string `scim:"value"`
+		DisplayName string `scim:"displayName"`
+	}
+)
+
+func (s *BindingTestSuite) TestNew() {
+	tests := []struct {
+		name      string
+		prototype interface{}
+		expect    func(t *testing.T, b *Binding, err error)
+	}{
+		{
+			name:      "valid",
+			prototype: (*testUser)(nil),
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, b.fields, 10)
+			},
+		},
+		{
+			name: "extension",
+			prototype: struct {
+				Enterprise *struct {
+					EmployeeNumber string `scim:"employeeNumber"`
+				} `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:      "not a struct",
+			prototype: "foo",
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+			},
+		},
+		{
+			name: "unknown attribute",
+			prototype: struct {
+				Foo string `scim:"foo"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidPath))
+			},
+		},
+		{
+			name: "traverse multiValued attribute",
+			prototype: struct {
+				Email string `scim:"emails.value"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidPath))
+			},
+		},
+		{
+			name: "unknown sub attribute",
+			prototype: struct {
+				Emails []struct {
+					Foo string `scim:"foo"`
+				} `scim:"emails"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidPath))
+			},
+		},
+		{
+			name: "incompatible type",
+			prototype: struct {
+				UserName int `scim:"userName"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+				assert.Contains(t, err.Error(), "field '.UserName' of type int is incompatible with string attribute 'userName'")
+			},
+		},
+		{
+			name: "slice for singular attribute",
+			prototype: struct {
+				UserName []string `scim:"userName"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+			},
+		},
+		{
+			name: "singular for multiValued attribute",
+			prototype: struct {
+				Emails testEmail `scim:"emails"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+				assert.Contains(t, err.Error(), "multiValued complex attribute 'emails'")
+			},
+		},
+		{
+			name: "incompatible sub attribute type",
+			prototype: struct {
+				Emails []struct {
+					Primary string `scim:"primary"`
+				} `scim:"emails"`
+			}{},
+			expect: func(t *testing.T, b *Binding, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+				assert.Contains(t, err.Error(), "field '.Emails[].Primary' of type string is incompatible with boolean attribute 'emails.primary'")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			b, err := New(s.resourceType, test.prototype)
+			test.expect(t, b, err)
+		})
+	}
+}
+
+func (s *BindingTestSuite) TestStoreAndLoad() {
+	b, err := New(s.resourceType, testUser{})
+	require.Nil(s.T(), err)
+
+	givenName, active := "Foo", false
+	user := &testUser{
+		testResource: testResource{
+			Schemas: []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+			ID:      "foo",
+			Created: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		UserName:  "foo",
+		GivenName: &givenName,
+		Active:    &active,
+		Emails: []testEmail{
+			{Value: "foo@bar.com", Type: "work", Primary: true},
+			{Value: "foo@home.com"},
+		},
+		EmployeeNumber: "123",
+		Level:          3,
+		Manager:        &testManager{Value: "bar"},
+		Ignored:        "ignored",
+	}
+
+	resource, err := b.NewResource(user)
+	require.Nil(s.T(), err)
+
+	raw, err := json.Marshal(resource.Navigator().Current().Raw())
+	require.Nil(s.T(), err)
+	assert.JSONEq(s.T(), `
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"],
+	"id": "foo",
+	"externalId": null,
+	"meta": {"created": "2020-01-01T00:00:00", "lastModified": null, "location": null, "resourceType": null, "version": null},
+	"userName": "foo",
+	"name": {"formatted": null, "familyName": null, "givenName": "Foo", "middleName": null, "honorificPrefix": null, "honorificSuffix": null},
+	"displayName": null,
+	"nickName": null,
+	"profileUrl": null,
+	"title": null,
+	"userType": null,
+	"preferredLanguage": null,
+	"locale": null,
+	"timezone": null,
+	"active": false,
+	"password": null,
+	"emails": [
+		{"value": "foo@bar.com", "type": "work", "primary": true, "display": null},
+		{"value": "foo@home.com", "type": null, "primary": null, "display": null}
+	],
+	"phoneNumbers": null,
+	"ims": null,
+	"photos": null,
+	"addresses": null,
+	"groups": null,
+	"entitlements": null,
+	"roles": null,
+	"x509Certificates": null,
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {
+		"employeeNumber": "123",
+		"level": 3,
+		"manager": {"value": "bar", "displayName": null}
+	}
+}
+`, string(raw))
+
+	loaded := new(testUser)
+	require.Nil(s.T(), b.Load(resource, loaded))
+	// schemas is synchronized with the extension in use
+	user.Schemas = append(user.Schemas, "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User")
+	user.Ignored = ""
+	assert.Equal(s.T(), user, loaded)
+
+	// nil pointers delete the attribute
+	user.GivenName = nil
+	user.Manager = nil
+	require.Nil(s.T(), b.Store(user, resource))
+	assert.True(s.T(), resource.Navigator().Dot("name").Dot("givenName").Current().IsUnassigned())
+	assert.True(s.T(), resource.Navigator().
+		Dot("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User").
+		Dot("manager").
+		Current().
+		IsUnassigned())
+}
+
+func (s *BindingTestSuite) TestLoadUnassigned() {
+	b, err := New(s.resourceType, testUser{})
+	require.Nil(s.T(), err)
+
+	resource := prop.NewResource(s.resourceType)
+	require.False(s.T(), resource.Navigator().Dot("userName").Replace("foo").HasError())
+
+	loaded := &testUser{Active: new(bool), Emails: []testEmail{{Value: "stale"}}}
+	require.Nil(s.T(), b.Load(resource, loaded))
+	assert.Equal(s.T(), &testUser{UserName: "foo"}, loaded)
+}
+
+func (s *BindingTestSuite) TestLoadErrors() {
+	b, err := New(s.resourceType, struct {
+		Level int8 `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:level"`
+	}{})
+	require.Nil(s.T(), err)
+
+	resource := prop.NewResource(s.resourceType)
+	require.False(s.T(), resource.Navigator().
+		Dot("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User").
+		Dot("level").
+		Replace(int64(1024)).
+		HasError())
+
+	err = b.Load(resource, &struct {
+		Level int8 `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:level"`
+	}{})
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue))
+	assert.Contains(s.T(), err.Error(), "value 1024 of attribute 'level' overflows field '.Level' of type int8")
+
+	err = b.Load(resource, new(testUser))
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue))
+}
+
+func (s *BindingTestSuite) SetupSuite() {
+	for _, each := range []struct {
+		filepath  string
+		structure interface{}
+	}{
+		{filepath: "../../../public/schemas/core_schema.json", structure: new(spec.Schema)},
+		{filepath: "../../../public/schemas/user_schema.json", structure: new(spec.Schema)},
+	} {
+		f, err := os.Open(each.filepath)
+		require.Nil(s.T(), err)
+
+		raw, err := ioutil.ReadAll(f)
+		require.Nil(s.T(), err)
+
+		err = json.Unmarshal(raw, each.structure)
+		require.Nil(s.T(), err)
+
+		spec.Schemas().Register(each.structure.(*spec.Schema))
+	}
+
+	extension := new(spec.Schema)
+	require.Nil(s.T(), json.Unmarshal([]byte(`
+{
+  "id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+  "name": "EnterpriseUser",
+  "attributes": [
+    {
+      "id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber",
+      "name": "employeeNumber",
+      "type": "string",
+      "_index": 0,
+      "_path": "employeeNumber"
+    },
+    {
+      "id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:level",
+      "name": "level",
+      "type": "integer",
+      "_index": 1,
+      "_path": "level"
+    },
+    {
+      "id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager",
+      "name": "manager",
+      "type": "complex",
+      "_index": 2,
+      "_path": "manager",
+      "subAttributes": [
+        {
+          "id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value",
+          "name": "value",
+          "type": "string",
+          "_index": 0,
+          "_path": "manager.value"
+        },
+        {
+          "id": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.displayName",
+          "name": "displayName",
+          "type": "string",
+          "_index": 1,
+          "_path": "manager.displayName"
+        }
+      ]
+    }
+  ]
+}
+`), extension))
+	spec.Schemas().Register(extension)
+
+	s.resourceType = new(spec.ResourceType)
+	require.Nil(s.T(), json.Unmarshal([]byte(`
+{
+  "id": "User",
+  "name": "User",
+  "endpoint": "/Users",
+  "schema": "urn:ietf:params:scim:schemas:core:2.0:User",
+  "schemaExtensions": [
+    {
+      "schema": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+      "required": false
+    }
+  ]
+}
+`), s.resourceType))
+}
@@ -0,0 +1,22 @@
+// This package binds SCIM resources to annotated Go structs.
+//
+// Fields are mapped to attributes with the "scim" struct tag, whose value is the path of the attribute. Top level
+// fields use the full path, which may be prefixed by the schema URN to address extension attributes:
+//
+//	type User struct {
+//		ID             string     `scim:"id"`
+//		UserName       string     `scim:"userName"`
+//		GivenName      *string    `scim:"name.givenName"`
+//		Emails         []Email    `scim:"emails"`
+//		LastModified   time.Time  `scim:"meta.lastModified"`
+//		EmployeeNumber string     `scim:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber"`
+//	}
+//
+// Fields of structs bound to complex attributes, like Email above, use the name of the sub attribute instead. Fields
+// without the tag, or tagged with "-", are ignored; untagged embedded structs are flattened.
+//
+// The struct is validated against the resource type when the Binding is created with New, so that type mismatches are
+// reported once and precisely, instead of every time a resource is loaded or stored. Nil pointers and slices, as well
+// as zero values of non-pointer fields, represent unassigned attributes. Use a pointer when the zero value is meaningful,
+// i.e. *bool for "active".
+package binding
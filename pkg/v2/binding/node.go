@@ -0,0 +1,392 @@
+package binding
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+const tagName = "scim"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field is a struct field bound to an attribute.
+type field struct {
+	name     string   // name of the Go field, used in error messages
+	index    []int    // index of the field, as in reflect.Value.FieldByIndex
+	segments []string // names to navigate from the parent property to the bound property
+	node     *node
+}
+
+// node describes how values of a Go type are converted from and to the raw values of an attribute.
+type node struct {
+	attr   *spec.Attribute
+	typ    reflect.Type // the Go type, with pointer removed
+	ptr    bool         // whether the Go type is a pointer to typ
+	elem   *node        // node of the elements, for multiValued attributes
+	fields []*field     // bound fields, for complex attributes
+}
+
+// compileFields compiles all tagged fields of the struct type, using resolve to look up the attribute by tag. Where is
+// the name of the struct, for error messages.
+func compileFields(typ reflect.Type, where string, resolve func(tag string) ([]string, *spec.Attribute, error)) ([]*field, error) {
+	var fields []*field
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+
+		tag, ok := sf.Tag.Lookup(tagName)
+		if tag == "-" {
+			continue
+		}
+		if !ok {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				embedded, err := compileFields(sf.Type, where, resolve)
+				if err != nil {
+					return nil, err
+				}
+				for _, each := range embedded {
+					each.index = append([]int{i}, each.index...)
+					fields = append(fields, each)
+				}
+			}
+			continue
+		}
+		if len(sf.PkgPath) > 0 {
+			return nil, fmt.Errorf("%w: field '%s.%s' is tagged but not exported", spec.ErrInvalidValue, where, sf.Name)
+		}
+
+		segments, attr, err := resolve(tag)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := compile(sf.Type, attr, where+"."+sf.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, &field{
+			name:     sf.Name,
+			index:    []int{i},
+			segments: segments,
+			node:     n,
+		})
+	}
+	return fields, nil
+}
+
+// compile compiles the node for the Go type bound to the attribute. Where is the name of the field, for error messages.
+func compile(typ reflect.Type, attr *spec.Attribute, where string) (*node, error) {
+	n := &node{attr: attr, typ: typ}
+
+	if attr.MultiValued() {
+		if typ.Kind() != reflect.Slice || (attr.Type() != spec.TypeBinary && typ.Elem().Kind() == reflect.Uint8) {
+			return nil, errIncompatible(where, typ, attr)
+		}
+		elem, err := compile(typ.Elem(), attr.DeriveElementAttribute(), where+"[]")
+		if err != nil {
+			return nil, err
+		}
+		n.elem = elem
+		return n, nil
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		n.ptr = true
+		n.typ = typ.Elem()
+	}
+
+	if attr.Type() == spec.TypeComplex {
+		if n.typ.Kind() != reflect.Struct || n.typ == timeType {
+			return nil, errIncompatible(where, typ, attr)
+		}
+		fields, err := compileFields(n.typ, where, func(tag string) ([]string, *spec.Attribute, error) {
+			subAttr := attr.SubAttributeForName(tag)
+			if subAttr == nil {
+				return nil, nil, fmt.Errorf("%w: '%s' is not a sub attribute of '%s'", spec.ErrInvalidPath, tag, attr.Path())
+			}
+			return []string{subAttr.Name()}, subAttr, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		n.fields = fields
+		return n, nil
+	}
+
+	if !compatible(attr.Type(), n.typ) {
+		return nil, errIncompatible(where, typ, attr)
+	}
+	return n, nil
+}
+
+// compatible returns true if the Go type can hold the value of the non-complex attribute type.
+func compatible(attrType spec.Type, typ reflect.Type) bool {
+	switch attrType {
+	case spec.TypeString, spec.TypeReference:
+		return typ.Kind() == reflect.String
+	case spec.TypeBinary:
+		return typ.Kind() == reflect.String || (typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8)
+	case spec.TypeInteger:
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		}
+		return false
+	case spec.TypeDecimal:
+		return typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64
+	case spec.TypeBoolean:
+		return typ.Kind() == reflect.Bool
+	case spec.TypeDateTime:
+		return typ == timeType || typ.Kind() == reflect.String
+	default:
+		return false
+	}
+}
+
+// store converts the Go value to the raw value of the attribute, or nil if the value represents an unassigned attribute.
+func (n *node) store(v reflect.Value) (interface{}, error) {
+	if n.elem != nil {
+		if v.Len() == 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			raw, err := n.elem.store(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if raw != nil {
+				values = append(values, raw)
+			}
+		}
+		return values, nil
+	}
+
+	if n.ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	} else if n.attr.Type() != spec.TypeComplex && isZero(v) {
+		return nil, nil
+	}
+
+	switch n.attr.Type() {
+	case spec.TypeComplex:
+		values := map[string]interface{}{}
+		for _, f := range n.fields {
+			raw, err := f.node.store(v.FieldByIndex(f.index))
+			if err != nil {
+				return nil, err
+			}
+			if raw != nil {
+				values[f.segments[0]] = raw
+			}
+		}
+		if len(values) == 0 && !n.ptr {
+			return nil, nil
+		}
+		return values, nil
+	case spec.TypeString, spec.TypeReference:
+		return v.String(), nil
+	case spec.TypeBinary:
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+		return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+	case spec.TypeInteger:
+		if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+			return int64(v.Uint()), nil
+		}
+		return v.Int(), nil
+	case spec.TypeDecimal:
+		return v.Float(), nil
+	case spec.TypeBoolean:
+		return v.Bool(), nil
+	case spec.TypeDateTime:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).Format(spec.ISO8601), nil
+		}
+		return v.String(), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported attribute type", spec.ErrInternal)
+	}
+}
+
+// load converts the raw value of the attribute to the Go value, and sets it to v. Nil raw values set v to zero value.
+// Where is the name of the field, for error messages.
+func (n *node) load(raw interface{}, v reflect.Value, where string) error {
+	if raw == nil || isEmptyComplex(raw) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if n.elem != nil {
+		values, ok := raw.([]interface{})
+		if !ok {
+			return errMismatch(where, raw, v.Type(), n.attr)
+		}
+		slice := reflect.MakeSlice(v.Type(), 0, len(values))
+		for i, each := range values {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := n.elem.load(each, elem, fmt.Sprintf("%s[%d]", where, i)); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	if n.ptr {
+		target := reflect.New(n.typ)
+		if err := n.loadValue(raw, target.Elem(), where); err != nil {
+			return err
+		}
+		v.Set(target)
+		return nil
+	}
+
+	return n.loadValue(raw, v, where)
+}
+
+func (n *node) loadValue(raw interface{}, v reflect.Value, where string) error {
+	mismatch := func() error {
+		return errMismatch(where, raw, v.Type(), n.attr)
+	}
+
+	switch n.attr.Type() {
+	case spec.TypeComplex:
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for _, f := range n.fields {
+			if err := f.node.load(values[f.segments[0]], v.FieldByIndex(f.index), where+"."+f.name); err != nil {
+				return err
+			}
+		}
+	case spec.TypeString, spec.TypeReference:
+		s, ok := raw.(string)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s)
+	case spec.TypeBinary:
+		s, ok := raw.(string)
+		if !ok {
+			return mismatch()
+		}
+		if v.Kind() == reflect.String {
+			v.SetString(s)
+			return nil
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("%w: value of '%s' is not base64 encoded", spec.ErrInvalidValue, n.attr.Path())
+		}
+		v.SetBytes(b)
+	case spec.TypeInteger:
+		i, ok := raw.(int64)
+		if !ok {
+			return mismatch()
+		}
+		if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+			if i < 0 || v.OverflowUint(uint64(i)) {
+				return errOverflow(where, raw, v.Type(), n.attr)
+			}
+			v.SetUint(uint64(i))
+		} else {
+			if v.OverflowInt(i) {
+				return errOverflow(where, raw, v.Type(), n.attr)
+			}
+			v.SetInt(i)
+		}
+	case spec.TypeDecimal:
+		f, ok := raw.(float64)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowFloat(f) {
+			return errOverflow(where, raw, v.Type(), n.attr)
+		}
+		v.SetFloat(f)
+	case spec.TypeBoolean:
+		b, ok := raw.(bool)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b)
+	case spec.TypeDateTime:
+		s, ok := raw.(string)
+		if !ok {
+			return mismatch()
+		}
+		if v.Kind() == reflect.String {
+			v.SetString(s)
+			return nil
+		}
+		t, err := time.Parse(spec.ISO8601, s)
+		if err != nil {
+			return fmt.Errorf("%w: value of '%s' does not conform to ISO8601", spec.ErrInvalidValue, n.attr.Path())
+		}
+		v.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("%w: unsupported attribute type", spec.ErrInternal)
+	}
+	return nil
+}
+
+// isZero returns true if v is the zero value of its type. time.Time is zero when its IsZero method returns true.
+func isZero(v reflect.Value) bool {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).IsZero()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// isEmptyComplex returns true if raw is the value of a complex property with all sub properties unassigned.
+func isEmptyComplex(raw interface{}) bool {
+	values, ok := raw.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, each := range values {
+		if each != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(attr *spec.Attribute) string {
+	if attr.MultiValued() {
+		return "multiValued " + attr.Type().String()
+	}
+	return attr.Type().String()
+}
+
+func errIncompatible(where string, typ reflect.Type, attr *spec.Attribute) error {
+	return fmt.Errorf("%w: field '%s' of type %s is incompatible with %s attribute '%s'",
+		spec.ErrInvalidValue, where, typ, describe(attr), attr.Path())
+}
+
+func errMismatch(where string, raw interface{}, typ reflect.Type, attr *spec.Attribute) error {
+	return fmt.Errorf("%w: value of type %T from %s attribute '%s' cannot be loaded into field '%s' of type %s",
+		spec.ErrInvalidValue, raw, describe(attr), attr.Path(), where, typ)
+}
+
+func errOverflow(where string, raw interface{}, typ reflect.Type, attr *spec.Attribute) error {
+	return fmt.Errorf("%w: value %v of attribute '%s' overflows field '%s' of type %s",
+		spec.ErrInvalidValue, raw, attr.Path(), where, typ)
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+type (
+	// BulkOperation is a single operation in a bulk request, as defined in RFC7644 Section 3.7.
+	BulkOperation struct {
+		Method  string          `json:"method"`
+		BulkID  string          `json:"bulkId,omitempty"`
+		Version string          `json:"version,omitempty"`
+		Path    string          `json:"path"`
+		Data    json.RawMessage `json:"data,omitempty"`
+	}
+	// BulkOperationResult is the result of a single operation in a bulk response.
+	BulkOperationResult struct {
+		Method   string          `json:"method"`
+		BulkID   string          `json:"bulkId,omitempty"`
+		Version  string          `json:"version,omitempty"`
+		Location string          `json:"location,omitempty"`
+		Status   string          `json:"status"`
+		Response json.RawMessage `json:"response,omitempty"`
+	}
+	// BulkResponse is the response to a bulk request.
+	BulkResponse struct {
+		Operations []BulkOperationResult `json:"Operations"`
+	}
+)
+
+// Bulk sends the operations as a single bulk request. FailOnErrors is the number of errors after which the service
+// provider stops processing, zero means no limit. Errors of individual operations are reported in the response.
+func (c *Client) Bulk(ctx context.Context, operations []BulkOperation, failOnErrors int) (*BulkResponse, error) {
+	if err := c.bootstrapped(); err != nil {
+		return nil, err
+	}
+	if !c.config.Bulk.Supported {
+		return nil, fmt.Errorf("%w: bulk is not supported by the service provider", spec.ErrInvalidSyntax)
+	}
+	if c.config.Bulk.MaxOp > 0 && len(operations) > c.config.Bulk.MaxOp {
+		return nil, fmt.Errorf("%w: %d operations exceeds the maximum of %d", spec.ErrInvalidValue, len(operations), c.config.Bulk.MaxOp)
+	}
+
+	payload := struct {
+		Schemas      []string        `json:"schemas"`
+		FailOnErrors int             `json:"failOnErrors,omitempty"`
+		Operations   []BulkOperation `json:"Operations"`
+	}{
+		Schemas:      []string{"urn:ietf:params:scim:api:messages:2.0:BulkRequest"},
+		FailOnErrors: failOnErrors,
+		Operations:   operations,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.Bulk.MaxPayload > 0 && len(body) > c.config.Bulk.MaxPayload {
+		return nil, fmt.Errorf("%w: payload of %d bytes exceeds the maximum of %d", spec.ErrInvalidValue, len(body), c.config.Bulk.MaxPayload)
+	}
+
+	resp, err := c.do(ctx, &request{
+		method: http.MethodPost,
+		path:   "/Bulk",
+		body:   body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bulkResp := new(BulkResponse)
+	if err := json.Unmarshal(resp.body, bulkResp); err != nil {
+		return nil, fmt.Errorf("%w: malformed bulk response", spec.ErrInternal)
+	}
+	return bulkResp, nil
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/handlerutil"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// New returns a new Client to the SCIM service provider at baseURL. If httpClient is nil, http.DefaultClient is used.
+// The client must be bootstrapped with Bootstrap before use.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		httpClient:    httpClient,
+		authorize:     func(_ *http.Request) {},
+		registry:      spec.NewSchemaRegistry(),
+		linter:        spec.NewLinter(),
+		resourceTypes: map[string]*spec.ResourceType{},
+	}
+}
+
+// Client is a typed client to a SCIM service provider.
+type Client struct {
+	baseURL       string
+	httpClient    *http.Client
+	authorize     func(r *http.Request)
+	registry      *spec.SchemaRegistry
+	linter        *spec.Linter
+	config        *spec.ServiceProviderConfig
+	resourceTypes map[string]*spec.ResourceType
+}
+
+// BearerToken makes the client authorize its requests with the bearer token.
+func (c *Client) BearerToken(token string) *Client {
+	c.authorize = func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c
+}
+
+// Registry makes the client register the schemas of the service provider with the registry, instead of a registry of
+// its own, so that schemas already registered, which may carry additional annotations, take precedence.
+func (c *Client) Registry(registry *spec.SchemaRegistry) *Client {
+	c.registry = registry
+	return c
+}
+
+// Linter sets the linter checking the schemas fetched from the service provider, which defaults to spec.NewLinter. Use
+// it to make annotations defined outside the annotation package known to the client.
+func (c *Client) Linter(linter *spec.Linter) *Client {
+	c.linter = linter
+	return c
+}
+
+// Bootstrap fetches the service provider config, schemas and resource types from the service provider. Schemas are
+// registered with the registry of the client, unless already registered, after they are checked by the linter of the
+// client; resource types must only use registered schemas. The client can be used once Bootstrap succeeded.
+func (c *Client) Bootstrap(ctx context.Context) (err error) {
+	config := new(spec.ServiceProviderConfig)
+	if err = c.getJSON(ctx, "/ServiceProviderConfig", config); err != nil {
+		return
+	}
+
+	registerCoreSchema(c.registry)
+
+	var schemas listResponse
+	if err = c.getJSON(ctx, "/Schemas", &schemas); err != nil {
+		return
+	}
+	for _, raw := range schemas.Resources {
+		var schema *spec.Schema
+		if schema, err = c.parseSchema(raw); err != nil {
+			return
+		}
+		if _, ok := c.registry.Get(schema.ID()); !ok {
+			c.registry.Register(schema)
+		}
+	}
+
+	var resourceTypes listResponse
+	if err = c.getJSON(ctx, "/ResourceTypes", &resourceTypes); err != nil {
+		return
+	}
+	parsed := map[string]*spec.ResourceType{}
+	for _, raw := range resourceTypes.Resources {
+		var resourceType *spec.ResourceType
+		if resourceType, err = c.registry.ParseResourceType(raw); err != nil {
+			return
+		}
+		parsed[resourceType.Name()] = resourceType
+	}
+
+	c.config = config
+	c.resourceTypes = parsed
+	return nil
+}
+
+// bootstrapped returns an error if the client has not been bootstrapped yet.
+func (c *Client) bootstrapped() error {
+	if c.config == nil {
+		return fmt.Errorf("%w: client is not bootstrapped", spec.ErrInternal)
+	}
+	return nil
+}
+
+// ServiceProviderConfig returns the service provider config fetched during Bootstrap, or nil before Bootstrap.
+func (c *Client) ServiceProviderConfig() *spec.ServiceProviderConfig {
+	return c.config
+}
+
+// ResourceType returns the resource type by its name (i.e. User), as fetched during Bootstrap.
+func (c *Client) ResourceType(name string) (*spec.ResourceType, bool) {
+	resourceType, ok := c.resourceTypes[name]
+	return resourceType, ok
+}
+
+// Endpoint returns the Endpoint to operate on the resources of the named resource type.
+func (c *Client) Endpoint(resourceTypeName string) (*Endpoint, error) {
+	resourceType, ok := c.ResourceType(resourceTypeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown resource type '%s'", spec.ErrNotFound, resourceTypeName)
+	}
+	return &Endpoint{client: c, resourceType: resourceType}, nil
+}
+
+// listResponse is the ListResponse message with resources left raw.
+type listResponse struct {
+	TotalResults int               `json:"totalResults"`
+	StartIndex   int               `json:"startIndex"`
+	ItemsPerPage int               `json:"itemsPerPage"`
+	Resources    []json.RawMessage `json:"Resources"`
+}
+
+// request describes a HTTP request to the service provider.
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	body    []byte
+	ifMatch string
+}
+
+// response is the successful HTTP response from the service provider.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends the request to the service provider. Non-2xx responses are returned as errors wrapping *spec.Error.
+func (c *Client) do(ctx context.Context, req *request) (*response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+
+	httpReq, err := http.NewRequest(req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Accept", spec.ApplicationScimJson)
+	if req.body != nil {
+		httpReq.Header.Set("Content-Type", spec.ApplicationScimJson)
+	}
+	if len(req.ifMatch) > 0 {
+		httpReq.Header.Set("If-Match", req.ifMatch)
+	}
+	c.authorize(httpReq)
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return nil, handlerutil.ReadError(httpResp)
+	}
+
+	raw, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{
+		status: httpResp.StatusCode,
+		header: httpResp.Header,
+		body:   raw,
+	}, nil
+}
+
+// getJSON sends a GET request to the path and decodes the response body into out.
+func (c *Client) getJSON(ctx context.Context, path string, out interface{}) error {
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: path})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.body, out); err != nil {
+		return fmt.Errorf("%w: malformed response from %s", spec.ErrInternal, path)
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/handlerutil"
+	scimjson "github.com/imulab/go-scim/pkg/v2/json"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	s := new(ClientTestSuite)
+	suite.Run(t, s)
+}
+
+type ClientTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+	userSchema   *spec.Schema
+	serverLock   sync.Mutex
+}
+
+func (s *ClientTestSuite) TestBootstrap() {
+	server := s.server(db.Memory(), s.config())
+	defer server.Close()
+
+	client := New(server.URL, nil)
+	require.Nil(s.T(), client.Bootstrap(context.Background()))
+
+	assert.True(s.T(), client.ServiceProviderConfig().ETag.Supported)
+
+	resourceType, ok := client.ResourceType("User")
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "/Users", resourceType.Endpoint())
+	assert.Equal(s.T(), "urn:ietf:params:scim:schemas:core:2.0:User", resourceType.Schema().ID())
+
+	_, err := client.Endpoint("Group")
+	assert.True(s.T(), errors.Is(err, spec.ErrNotFound))
+
+	// schemas are registered with the registry of the client, where local definitions take precedence
+	assert.True(s.T(), resourceType.Registry() != spec.Schemas())
+	registry := spec.NewSchemaRegistry()
+	registry.Register(s.userSchema)
+	client = New(server.URL, nil).Registry(registry)
+	require.Nil(s.T(), client.Bootstrap(context.Background()))
+	resourceType, ok = client.ResourceType("User")
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), registry, resourceType.Registry())
+	assert.Equal(s.T(), s.userSchema, resourceType.Schema())
+}
+
+func (s *ClientTestSuite) TestNotBootstrapped() {
+	client := New("http://localhost", nil)
+	users := &Endpoint{client: client, resourceType: s.resourceType}
+
+	_, err := users.Patch(context.Background(), "foo", "", []service.PatchOperation{
+		{Op: "add", Path: "nickName", Value: json.RawMessage(`"bar"`)},
+	})
+	assert.True(s.T(), errors.Is(err, spec.ErrInternal))
+	assert.True(s.T(), errors.Is(users.Delete(context.Background(), "foo", ""), spec.ErrInternal))
+	_, err = client.Bulk(context.Background(), []BulkOperation{{Method: http.MethodDelete, Path: "/Users/foo"}}, 0)
+	assert.True(s.T(), errors.Is(err, spec.ErrInternal))
+}
+
+func (s *ClientTestSuite) TestParseSchema() {
+	raw, err := scimjson.Serialize(scimjson.SchemaToSerializable(s.userSchema))
+	require.Nil(s.T(), err)
+
+	schema, err := New("http://localhost", nil).parseSchema(raw)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), s.userSchema.ID(), schema.ID())
+
+	for _, path := range []string{"userName", "name.givenName", "emails.value"} {
+		var expect, actual *spec.Attribute
+		_ = s.userSchema.ForEachAttribute(func(attr *spec.Attribute) error {
+			attr.DFS(func(a *spec.Attribute) {
+				if a.Path() == path {
+					expect = a
+				}
+			})
+			return nil
+		})
+		_ = schema.ForEachAttribute(func(attr *spec.Attribute) error {
+			attr.DFS(func(a *spec.Attribute) {
+				if a.Path() == path {
+					actual = a
+				}
+			})
+			return nil
+		})
+		require.NotNil(s.T(), expect, path)
+		require.NotNil(s.T(), actual, path)
+		assert.Equal(s.T(), expect.ID(), actual.ID())
+		assert.Equal(s.T(), expect.Type(), actual.Type())
+		assert.Equal(s.T(), expect.MultiValued(), actual.MultiValued())
+	}
+
+	// invalid definitions are reported instead of panicking when parsed
+	_, err = New("http://localhost", nil).parseSchema([]byte(`{"id":"urn:imulab:Invalid","attributes":[{"name":"level","type":"decimal128"}]}`))
+	assert.True(s.T(), errors.Is(err, spec.ErrInternal))
+}
+
+func (s *ClientTestSuite) TestCRUD() {
+	server := s.server(db.Memory(), s.config())
+	defer server.Close()
+
+	client := New(server.URL, nil)
+	require.Nil(s.T(), client.Bootstrap(context.Background()))
+	users, err := client.Endpoint("User")
+	require.Nil(s.T(), err)
+
+	created, err := users.Create(context.Background(), s.resourceOf(map[string]interface{}{
+		"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"userName": "foo",
+		"password": "s3cret",
+	}))
+	require.Nil(s.T(), err)
+	id := created.IdOrEmpty()
+	assert.NotEmpty(s.T(), id)
+	assert.NotEmpty(s.T(), created.MetaVersionOrEmpty())
+
+	fetched, err := users.Get(context.Background(), id, nil)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "foo", fetched.Navigator().Dot("userName").Current().Raw())
+
+	require.False(s.T(), fetched.Navigator().Dot("displayName").Replace("Foo").HasError())
+	replaced, err := users.Replace(context.Background(), fetched)
+	require.Nil(s.T(), err)
+	require.NotNil(s.T(), replaced)
+	assert.Equal(s.T(), "Foo", replaced.Navigator().Dot("displayName").Current().Raw())
+	assert.NotEqual(s.T(), fetched.MetaVersionOrEmpty(), replaced.MetaVersionOrEmpty())
+
+	// fetched carries the stale version
+	_, err = users.Replace(context.Background(), fetched)
+	assert.True(s.T(), errors.Is(err, spec.ErrConflict))
+
+	err = users.Delete(context.Background(), id, fetched.MetaVersionOrEmpty())
+	assert.True(s.T(), errors.Is(err, spec.ErrConflict))
+	require.Nil(s.T(), users.Delete(context.Background(), id, replaced.MetaVersionOrEmpty()))
+
+	_, err = users.Get(context.Background(), id, nil)
+	assert.True(s.T(), errors.Is(err, spec.ErrNotFound))
+}
+
+func (s *ClientTestSuite) TestPatch() {
+	// resource is inserted without meta, as memory database returns the stored instance, whose version changes
+	// in place during the patch.
+	database := db.Memory()
+	require.Nil(s.T(), database.Insert(context.Background(), s.resourceOf(map[string]interface{}{
+		"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"id":       "foo",
+		"userName": "foo",
+	})))
+
+	server := s.server(database, s.config())
+	defer server.Close()
+
+	client := New(server.URL, nil)
+	require.Nil(s.T(), client.Bootstrap(context.Background()))
+	users, err := client.Endpoint("User")
+	require.Nil(s.T(), err)
+
+	patched, err := users.Patch(context.Background(), "foo", "", []service.PatchOperation{
+		{Op: "add", Path: "nickName", Value: json.RawMessage(`"bar"`)},
+	})
+	require.Nil(s.T(), err)
+	require.NotNil(s.T(), patched)
+	assert.Equal(s.T(), "bar", patched.Navigator().Dot("nickName").Current().Raw())
+	assert.NotEmpty(s.T(), patched.MetaVersionOrEmpty())
+}
+
+func (s *ClientTestSuite) TestIterate() {
+	server := s.server(db.Memory(), s.config())
+	defer server.Close()
+
+	client := New(server.URL, nil)
+	require.Nil(s.T(), client.Bootstrap(context.Background()))
+	users, err := client.Endpoint("User")
+	require.Nil(s.T(), err)
+
+	for _, userName := range []string{"a", "b", "c", "d", "e"} {
+		_, err := users.Create(context.Background(), s.resourceOf(map[string]interface{}{
+			"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+			"userName": userName,
+		}))
+		require.Nil(s.T(), err)
+	}
+
+	page, err := users.Query(context.Background(), &service.QueryRequest{
+		Filter:     "userName pr",
+		Sort:       &crud.Sort{By: "userName", Order: crud.SortDesc},
+		Pagination: &crud.Pagination{StartIndex: 1, Count: 2},
+	})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 5, page.TotalResults)
+	require.Len(s.T(), page.Resources, 2)
+	assert.Equal(s.T(), "e", page.Resources[0].Navigator().Dot("userName").Current().Raw())
+
+	// a zero count is left to the service provider
+	page, err = users.Query(context.Background(), &service.QueryRequest{
+		Filter:     "userName pr",
+		Pagination: &crud.Pagination{},
+	})
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), page.Resources, 5)
+
+	var userNames []string
+	err = users.Iterate(context.Background(), &service.QueryRequest{
+		Filter: "userName pr",
+		Sort:   &crud.Sort{By: "userName", Order: crud.SortAsc},
+	}, 2, func(resource *prop.Resource) error {
+		userNames = append(userNames, resource.Navigator().Dot("userName").Current().Raw().(string))
+		return nil
+	})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"a", "b", "c", "d", "e"}, userNames)
+}
+
+func (s *ClientTestSuite) TestBulk() {
+	config := s.config()
+	server := s.server(db.Memory(), config)
+	defer server.Close()
+
+	client := New(server.URL, nil)
+	require.Nil(s.T(), client.Bootstrap(context.Background()))
+
+	_, err := client.Bulk(context.Background(), []BulkOperation{{Method: http.MethodDelete, Path: "/Users/foo"}}, 0)
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidSyntax))
+
+	client.config.Bulk.Supported = true
+	client.config.Bulk.MaxOp = 1
+	_, err = client.Bulk(context.Background(), []BulkOperation{
+		{Method: http.MethodDelete, Path: "/Users/foo"},
+		{Method: http.MethodDelete, Path: "/Users/bar"},
+	}, 0)
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue))
+
+	resp, err := client.Bulk(context.Background(), []BulkOperation{
+		{Method: http.MethodPost, BulkID: "qwerty", Path: "/Users", Data: json.RawMessage(`{"userName":"foo"}`)},
+	}, 1)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), resp.Operations, 1)
+	assert.Equal(s.T(), "qwerty", resp.Operations[0].BulkID)
+	assert.Equal(s.T(), "201", resp.Operations[0].Status)
+}
+
+func (s *ClientTestSuite) TestBearerToken() {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(rw).Encode(s.config())
+	}))
+	defer server.Close()
+
+	var config spec.ServiceProviderConfig
+	assert.NotNil(s.T(), New(server.URL, nil).getJSON(context.Background(), "/ServiceProviderConfig", &config))
+	assert.Nil(s.T(), New(server.URL, nil).BearerToken("t0ken").getJSON(context.Background(), "/ServiceProviderConfig", &config))
+}
+
+func (s *ClientTestSuite) config() *spec.ServiceProviderConfig {
+	config := new(spec.ServiceProviderConfig)
+	config.Patch.Supported = true
+	config.ETag.Supported = true
+	config.Filter.Supported = true
+	config.Filter.MaxResults = 100
+	config.Sort.Supported = true
+	return config
+}
+
+// server returns a test server backed by the services and the database, serving a subset of the SCIM endpoints.
+func (s *ClientTestSuite) server(database db.DB, config *spec.ServiceProviderConfig) *httptest.Server {
+	var (
+		create  = service.CreateService(s.resourceType, database, []filter.ByResource{filter.ByPropertyToByResource(filter.UUIDFilter()), filter.MetaFilter()})
+		get     = service.GetService(database)
+		replace = service.ReplaceService(config, s.resourceType, database, []filter.ByResource{filter.ByPropertyToByResource(filter.ReadOnlyFilter()), filter.MetaFilter()})
+		patch   = service.PatchService(config, database, nil, []filter.ByResource{filter.MetaFilter()})
+		del     = service.DeleteService(config, database)
+		query   = service.QueryService(config, database)
+	)
+
+	list := func(rw http.ResponseWriter, resources ...scimjson.Serializable) {
+		_ = handlerutil.WriteSearchResultToResponse(rw, &service.QueryResponse{
+			TotalResults: len(resources),
+			StartIndex:   1,
+			ItemsPerPage: len(resources),
+			Resources:    resources,
+		})
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.serverLock.Lock()
+		defer s.serverLock.Unlock()
+
+		id := strings.TrimPrefix(r.URL.Path, "/Users/")
+		switch {
+		case r.URL.Path == "/ServiceProviderConfig":
+			_ = json.NewEncoder(rw).Encode(config)
+		case r.URL.Path == "/Schemas":
+			list(rw, scimjson.SchemaToSerializable(s.userSchema))
+		case r.URL.Path == "/ResourceTypes":
+			list(rw, scimjson.ResourceTypeToSerializable(s.resourceType))
+		case r.URL.Path == "/Bulk":
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:BulkResponse"],"Operations":[{"method":"POST","bulkId":"qwerty","location":"/Users/foo","status":"201"}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/Users":
+			cr, closer := handlerutil.CreateRequest(r)
+			defer closer()
+			resp, err := create.Do(r.Context(), cr)
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			rw.WriteHeader(http.StatusCreated)
+			_ = handlerutil.WriteResourceToResponse(rw, resp.Resource)
+		case r.Method == http.MethodGet && r.URL.Path == "/Users":
+			qr, err := handlerutil.QueryRequestFromGet(r)
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			resp, err := query.Do(r.Context(), qr)
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			_ = handlerutil.WriteSearchResultToResponse(rw, resp)
+		case r.Method == http.MethodGet:
+			resp, err := get.Do(r.Context(), &service.GetRequest{ResourceID: id})
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			_ = handlerutil.WriteResourceToResponse(rw, resp.Resource)
+		case r.Method == http.MethodPut:
+			rr, closer := handlerutil.ReplaceRequest(r)
+			defer closer()
+			resp, err := replace.Do(r.Context(), rr(id))
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			if !resp.Replaced {
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_ = handlerutil.WriteResourceToResponse(rw, resp.Resource)
+		case r.Method == http.MethodPatch:
+			pr, closer := handlerutil.PatchRequest(r)
+			defer closer()
+			resp, err := patch.Do(r.Context(), pr(id))
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			if !resp.Patched {
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_ = handlerutil.WriteResourceToResponse(rw, resp.Resource)
+		case r.Method == http.MethodDelete:
+			if _, err := del.Do(r.Context(), handlerutil.DeleteRequest(r)(id)); err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func (s *ClientTestSuite) resourceOf(data interface{}) *prop.Resource {
+	r := prop.NewResource(s.resourceType)
+	require.False(s.T(), r.Navigator().Replace(data).HasError())
+	return r
+}
+
+func (s *ClientTestSuite) SetupSuite() {
+	for _, each := range []struct {
+		filepath  string
+		structure interface{}
+		post      func(parsed interface{})
+	}{
+		{
+			filepath:  "../../../public/schemas/core_schema.json",
+			structure: new(spec.Schema),
+			post: func(parsed interface{}) {
+				spec.Schemas().Register(parsed.(*spec.Schema))
+			},
+		},
+		{
+			filepath:  "../../../public/schemas/user_schema.json",
+			structure: new(spec.Schema),
+			post: func(parsed interface{}) {
+				s.userSchema = parsed.(*spec.Schema)
+				spec.Schemas().Register(s.userSchema)
+			},
+		},
+		{
+			filepath:  "../../../public/resource_types/user_resource_type.json",
+			structure: new(spec.ResourceType),
+			post: func(parsed interface{}) {
+				s.resourceType = parsed.(*spec.ResourceType)
+			},
+		},
+	} {
+		f, err := os.Open(each.filepath)
+		require.Nil(s.T(), err)
+
+		raw, err := ioutil.ReadAll(f)
+		require.Nil(s.T(), err)
+
+		err = json.Unmarshal(raw, each.structure)
+		require.Nil(s.T(), err)
+
+		if each.post != nil {
+			each.post(each.structure)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// This package implements a typed client to SCIM service providers.
+//
+// The client bootstraps itself from the /ServiceProviderConfig, /Schemas and /ResourceTypes endpoints of the service
+// provider, so that resources can be exchanged as *prop.Resource, just like on the server side. The schemas discovered
+// are registered with a registry owned by the client, or the one set with Registry, unless a schema with the same id
+// was already registered, in which case the local definition, which may carry additional annotations, takes
+// precedence.
+//
+// Errors reported by the service provider are mapped back to the *spec.Error prototypes, so that callers can inspect
+// them with errors.Is, i.e. errors.Is(err, spec.ErrNotFound).
+package client
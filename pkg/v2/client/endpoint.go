@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	scimjson "github.com/imulab/go-scim/pkg/v2/json"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Endpoint operates on the resources of a single resource type at the service provider.
+type Endpoint struct {
+	client       *Client
+	resourceType *spec.ResourceType
+}
+
+// ListResponse is the result of a query, with resources parsed according to the resource type.
+type ListResponse struct {
+	TotalResults int
+	StartIndex   int
+	ItemsPerPage int
+	Resources    []*prop.Resource
+}
+
+// ResourceType returns the resource type this endpoint operates on.
+func (e *Endpoint) ResourceType() *spec.ResourceType {
+	return e.resourceType
+}
+
+// Create creates the resource at the service provider and returns the resource as created. Read only attributes
+// on the resource are not sent.
+func (e *Endpoint) Create(ctx context.Context, resource *prop.Resource) (*prop.Resource, error) {
+	body, err := scimjson.Serialize(resource, scimjson.Request())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.do(ctx, &request{
+		method: http.MethodPost,
+		path:   e.resourceType.Endpoint(),
+		body:   body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e.parseResource(resp.body)
+}
+
+// Get returns the resource by its id. Projection is optional.
+func (e *Endpoint) Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error) {
+	resp, err := e.client.do(ctx, &request{
+		method: http.MethodGet,
+		path:   e.resourcePath(id),
+		query:  projectionQuery(url.Values{}, projection),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e.parseResource(resp.body)
+}
+
+// Replace replaces the resource at the service provider by the resource's id and returns the replaced resource. When
+// the service provider supports ETag, meta.version of the resource is sent as If-Match, so that replacing a stale
+// version fails with spec.ErrConflict. If the service provider reports no change, nil is returned.
+func (e *Endpoint) Replace(ctx context.Context, resource *prop.Resource) (*prop.Resource, error) {
+	id := resource.IdOrEmpty()
+	if len(id) == 0 {
+		return nil, fmt.Errorf("%w: resource has no id", spec.ErrInvalidValue)
+	}
+
+	ifMatch, err := e.ifMatch(resource.MetaVersionOrEmpty())
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := scimjson.Serialize(resource, scimjson.Request())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.do(ctx, &request{
+		method:  http.MethodPut,
+		path:    e.resourcePath(id),
+		body:    body,
+		ifMatch: ifMatch,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.status == http.StatusNoContent {
+		return nil, nil
+	}
+	return e.parseResource(resp.body)
+}
+
+// Patch modifies the resource by its id with the patch operations and returns the patched resource. Version is
+// optional and sent as If-Match when the service provider supports ETag. If the service provider reports no change,
+// nil is returned.
+func (e *Endpoint) Patch(ctx context.Context, id string, version string, operations []service.PatchOperation) (*prop.Resource, error) {
+	if err := e.client.bootstrapped(); err != nil {
+		return nil, err
+	}
+	if !e.client.config.Patch.Supported {
+		return nil, fmt.Errorf("%w: patch is not supported by the service provider", spec.ErrInvalidSyntax)
+	}
+	ifMatch, err := e.ifMatch(version)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(service.PatchPayload{
+		Schemas:    []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		Operations: operations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.do(ctx, &request{
+		method:  http.MethodPatch,
+		path:    e.resourcePath(id),
+		body:    body,
+		ifMatch: ifMatch,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.status == http.StatusNoContent {
+		return nil, nil
+	}
+	return e.parseResource(resp.body)
+}
+
+// Delete deletes the resource by its id. Version is optional and sent as If-Match when the service provider supports ETag.
+func (e *Endpoint) Delete(ctx context.Context, id string, version string) error {
+	ifMatch, err := e.ifMatch(version)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.do(ctx, &request{
+		method:  http.MethodDelete,
+		path:    e.resourcePath(id),
+		ifMatch: ifMatch,
+	})
+	return err
+}
+
+// Query returns a single page of resources matching the query request. A zero count is not sent, leaving the page size
+// to the service provider.
+func (e *Endpoint) Query(ctx context.Context, req *service.QueryRequest) (*ListResponse, error) {
+	query := url.Values{}
+	if req != nil {
+		if len(req.Filter) > 0 {
+			query.Set("filter", req.Filter)
+		}
+		if req.Sort != nil && len(req.Sort.By) > 0 {
+			query.Set("sortBy", req.Sort.By)
+			if req.Sort.Order != crud.SortDefault {
+				query.Set("sortOrder", string(req.Sort.Order))
+			}
+		}
+		if req.Pagination != nil {
+			if req.Pagination.StartIndex > 0 {
+				query.Set("startIndex", strconv.Itoa(req.Pagination.StartIndex))
+			}
+			if req.Pagination.Count > 0 {
+				query.Set("count", strconv.Itoa(req.Pagination.Count))
+			}
+		}
+		query = projectionQuery(query, req.Projection)
+	}
+
+	var list listResponse
+	resp, err := e.client.do(ctx, &request{
+		method: http.MethodGet,
+		path:   e.resourceType.Endpoint(),
+		query:  query,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resp.body, &list); err != nil {
+		return nil, fmt.Errorf("%w: malformed list response", spec.ErrInternal)
+	}
+
+	result := &ListResponse{
+		TotalResults: list.TotalResults,
+		StartIndex:   list.StartIndex,
+		ItemsPerPage: list.ItemsPerPage,
+		Resources:    make([]*prop.Resource, 0, len(list.Resources)),
+	}
+	for _, raw := range list.Resources {
+		resource, err := e.parseResource(raw)
+		if err != nil {
+			return nil, err
+		}
+		result.Resources = append(result.Resources, resource)
+	}
+	return result, nil
+}
+
+// Iterate queries the resources page by page with the given page size, and invokes the callback on each resource.
+// Pagination in the request is ignored. Iteration stops at the first error returned by the callback.
+func (e *Endpoint) Iterate(ctx context.Context, req *service.QueryRequest, pageSize int, callback func(resource *prop.Resource) error) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("%w: page size must be positive", spec.ErrInvalidValue)
+	}
+
+	paged := service.QueryRequest{}
+	if req != nil {
+		paged = *req
+	}
+
+	for startIndex, seen := 1, 0; ; {
+		paged.Pagination = &crud.Pagination{StartIndex: startIndex, Count: pageSize}
+		page, err := e.Query(ctx, &paged)
+		if err != nil {
+			return err
+		}
+
+		for _, resource := range page.Resources {
+			if err := callback(resource); err != nil {
+				return err
+			}
+		}
+
+		seen += len(page.Resources)
+		if len(page.Resources) == 0 || seen >= page.TotalResults {
+			return nil
+		}
+		startIndex += len(page.Resources)
+	}
+}
+
+func (e *Endpoint) resourcePath(id string) string {
+	return e.resourceType.Endpoint() + "/" + url.PathEscape(id)
+}
+
+// ifMatch returns the version to send as If-Match, or empty if the service provider does not support ETag.
+func (e *Endpoint) ifMatch(version string) (string, error) {
+	if err := e.client.bootstrapped(); err != nil {
+		return "", err
+	}
+	if !e.client.config.ETag.Supported {
+		return "", nil
+	}
+	return version, nil
+}
+
+func (e *Endpoint) parseResource(raw []byte) (*prop.Resource, error) {
+	resource := prop.NewResource(e.resourceType)
+	if err := scimjson.Deserialize(raw, resource); err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+func projectionQuery(query url.Values, projection *crud.Projection) url.Values {
+	if projection == nil {
+		return query
+	}
+	if len(projection.Attributes) > 0 {
+		query.Set("attributes", strings.Join(projection.Attributes, ","))
+	}
+	if len(projection.ExcludedAttributes) > 0 {
+		query.Set("excludedAttributes", strings.Join(projection.ExcludedAttributes, ","))
+	}
+	return query
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// parseSchema parses the schema as returned by the /Schemas endpoint. Because the public representation of the schema
+// does not carry the id, path and index of the attributes, they are derived from the schema id and the attribute's
+// position, in the same form as the schema definitions of this project. The normalized schema is checked by the linter
+// of the client before it is parsed, so that invalid definitions are reported as errors.
+func (c *Client) parseSchema(raw []byte) (*spec.Schema, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: malformed schema", spec.ErrInternal)
+	}
+
+	id, _ := data["id"].(string)
+	if len(id) == 0 {
+		return nil, fmt.Errorf("%w: schema has no id", spec.ErrInternal)
+	}
+
+	attributes, _ := data["attributes"].([]interface{})
+	normalizeAttributes(id, "", attributes)
+
+	normalized, err := json.Marshal(map[string]interface{}{
+		"id":          id,
+		"name":        data["name"],
+		"description": data["description"],
+		"attributes":  attributes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, each := range c.linter.Lint(normalized) {
+		if !each.Warning {
+			problems = append(problems, each.String())
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: invalid schema '%s' from service provider: %s", spec.ErrInternal, id, strings.Join(problems, "; "))
+	}
+
+	schema := new(spec.Schema)
+	if err := json.Unmarshal(normalized, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// normalizeAttributes assigns id, _path and _index to the attributes recursively.
+func normalizeAttributes(schemaId string, parentPath string, attributes []interface{}) {
+	for i, each := range attributes {
+		attr, ok := each.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := attr["name"].(string)
+		path := name
+		if len(parentPath) > 0 {
+			path = parentPath + "." + name
+		}
+
+		attr["id"] = schemaId + ":" + path
+		attr["_path"] = path
+		attr["_index"] = i
+
+		if subAttributes, ok := attr["subAttributes"].([]interface{}); ok {
+			normalizeAttributes(schemaId, path, subAttributes)
+		}
+	}
+}
+
+// registerCoreSchema registers the core schema with the registry, which describes the attributes common to all
+// resources and is not served by the /Schemas endpoint, unless it is already registered.
+func registerCoreSchema(registry *spec.SchemaRegistry) {
+	if _, ok := registry.Get(spec.CoreSchemaId); ok {
+		return
+	}
+
+	schema := new(spec.Schema)
+	if err := json.Unmarshal([]byte(coreSchema), schema); err != nil {
+		panic(err)
+	}
+	registry.Register(schema)
+}
+
+// the core schema, as defined in RFC7643 Section 3.1
+const coreSchema = `
+{
+  "id": "core",
+  "name": "Core",
+  "description": "Shared attributes for all SCIM resources",
+  "attributes": [
+    {"id": "schemas", "name": "schemas", "type": "reference", "multiValued": true, "required": true, "caseExact": true, "returned": "always", "_index": 0, "_path": "schemas", "_annotations": {"@AutoCompact": {}}},
+    {"id": "id", "name": "id", "type": "string", "caseExact": true, "returned": "always", "mutability": "readOnly", "uniqueness": "global", "_index": 1, "_path": "id"},
+    {"id": "externalId", "name": "externalId", "type": "string", "_index": 2, "_path": "externalId"},
+    {
+      "id": "meta", "name": "meta", "type": "complex", "mutability": "readOnly", "_index": 3, "_path": "meta",
+      "subAttributes": [
+        {"id": "meta.resourceType", "name": "resourceType", "type": "string", "caseExact": true, "mutability": "readOnly", "_index": 0, "_path": "meta.resourceType"},
+        {"id": "meta.created", "name": "created", "type": "dateTime", "mutability": "readOnly", "_index": 1, "_path": "meta.created"},
+        {"id": "meta.lastModified", "name": "lastModified", "type": "dateTime", "mutability": "readOnly", "_index": 2, "_path": "meta.lastModified"},
+        {"id": "meta.location", "name": "location", "type": "reference", "caseExact": true, "mutability": "readOnly", "_index": 3, "_path": "meta.location"},
+        {"id": "meta.version", "name": "version", "type": "string", "caseExact": true, "mutability": "readOnly", "_index": 4, "_path": "meta.version"}
+      ]
+    }
+  ]
+}
+`
@@ -0,0 +1,344 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+const (
+	// number of goroutines pushing the changes dispatched by the service decorators
+	dispatchWorkers = 4
+	// number of changes waiting to be pushed by each goroutine, before further changes are dropped
+	dispatchCapacity = 256
+)
+
+// ErrDispatchDropped is reported to the OnError callback for the changes dropped by the service decorators, because
+// too many changes are waiting to be pushed, or the connector is closed. Dropped changes reach the remote with the
+// next Reconcile.
+var ErrDispatchDropped = errors.New("change is dropped")
+
+// New returns a new Connector that pushes local changes to the remote according to the mapping, and keeps track of
+// remote ids in ids. By default, each remote call is attempted 3 times, with an initial interval of 1 second that
+// doubles after each failure, and references to other resources are translated with ids only.
+func New(remote *Remote, mapping *Mapping, ids IDMap) *Connector {
+	c := &Connector{
+		remote:     remote,
+		mapping:    mapping,
+		ids:        ids,
+		references: []IDMap{ids},
+		attempts:   3,
+		interval:   time.Second,
+		onError:    func(_ string, _ error) {},
+		locks:      map[string]*resourceLock{},
+	}
+	for i := range c.jobs {
+		c.jobs[i] = make(chan func(), dispatchCapacity)
+	}
+	return c
+}
+
+// Connector pushes the changes of local resources to the remote. Changes to the same resource are pushed one at a
+// time, while changes to different resources proceed concurrently.
+type Connector struct {
+	remote     *Remote
+	mapping    *Mapping
+	ids        IDMap
+	references []IDMap
+	attempts   int
+	interval   time.Duration
+	onError    func(localID string, err error)
+	locksMu    sync.Mutex
+	locks      map[string]*resourceLock // locks of the resources being pushed, by local id
+	jobsMu     sync.RWMutex
+	jobs       [dispatchWorkers]chan func()
+	closed     bool
+	startOnce  sync.Once
+}
+
+type resourceLock struct {
+	sync.Mutex
+	holders int // number of callers holding or waiting for the lock
+}
+
+// Retry sets the number of attempts for each remote call, and the initial interval between the attempts, which
+// doubles after each failure. Only transient errors are retried, that is, network errors and 5xx responses.
+func (c *Connector) Retry(attempts int, interval time.Duration) *Connector {
+	if attempts < 1 {
+		attempts = 1
+	}
+	c.attempts = attempts
+	c.interval = interval
+	return c
+}
+
+// ReferencesTo makes the connector translate the references to the resources pushed by the other connectors, in
+// addition to its own, i.e. the members of groups, which are users or groups. See Mapping for the translation.
+func (c *Connector) ReferencesTo(others ...*Connector) *Connector {
+	for _, other := range others {
+		if other != nil && other != c {
+			c.references = append(c.references, other.ids)
+		}
+	}
+	return c
+}
+
+// OnError sets the callback to invoke when a change dispatched asynchronously by the service decorators failed to be
+// pushed to the remote.
+func (c *Connector) OnError(callback func(localID string, err error)) *Connector {
+	c.onError = callback
+	return c
+}
+
+// Created pushes the created resource to the remote. If the resource is already known to the remote, it is replaced
+// instead.
+func (c *Connector) Created(ctx context.Context, resource *prop.Resource) error {
+	defer c.lock(resource.IdOrEmpty())()
+	return c.upsert(ctx, resource)
+}
+
+// Updated pushes the change between ref (the before state) and resource (the after state) to the remote. If the remote
+// supports PATCH, only the mapped attributes that changed are sent; otherwise, the remote resource is replaced. If the
+// resource is not yet known to the remote, it is created instead.
+func (c *Connector) Updated(ctx context.Context, ref *prop.Resource, resource *prop.Resource) error {
+	defer c.lock(resource.IdOrEmpty())()
+
+	remoteID, ok, err := c.ids.Get(ctx, resource.IdOrEmpty())
+	if err != nil {
+		return err
+	}
+	if !ok || ref == nil {
+		return c.upsert(ctx, resource)
+	}
+
+	spc, err := c.remote.ServiceProviderConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !spc.Patch.Supported {
+		return c.upsert(ctx, resource)
+	}
+
+	operations, err := c.diff(ctx, ref, resource)
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+
+	return c.retry(ctx, func() error {
+		return c.remote.Patch(ctx, c.mapping.Endpoint, remoteID, operations)
+	})
+}
+
+// Deleted deletes the remote counterpart of the resource, if it is known to the remote.
+func (c *Connector) Deleted(ctx context.Context, resource *prop.Resource) error {
+	defer c.lock(resource.IdOrEmpty())()
+	return c.delete(ctx, resource.IdOrEmpty())
+}
+
+// upsert replaces the remote resource if it is known, or creates it otherwise. A remote resource that has gone missing
+// is created again.
+func (c *Connector) upsert(ctx context.Context, resource *prop.Resource) error {
+	payload, err := c.mapping.apply(func(path string) (interface{}, error) {
+		return c.remoteValue(ctx, resource, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	localID := resource.IdOrEmpty()
+	remoteID, ok, err := c.ids.Get(ctx, localID)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		err = c.retry(ctx, func() error {
+			return c.remote.Replace(ctx, c.mapping.Endpoint, remoteID, payload)
+		})
+		if !errors.Is(err, spec.ErrNotFound) {
+			return err
+		}
+	}
+
+	err = c.retry(ctx, func() error {
+		remoteID, err = c.remote.Create(ctx, c.mapping.Endpoint, payload)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return c.ids.Put(ctx, localID, remoteID)
+}
+
+// delete deletes the remote resource mapped to the local id, and removes the mapping. A remote resource that has
+// already gone missing is not treated as an error.
+func (c *Connector) delete(ctx context.Context, localID string) error {
+	remoteID, ok, err := c.ids.Get(ctx, localID)
+	if err != nil || !ok {
+		return err
+	}
+
+	err = c.retry(ctx, func() error {
+		return c.remote.Delete(ctx, c.mapping.Endpoint, remoteID)
+	})
+	if err != nil && !errors.Is(err, spec.ErrNotFound) {
+		return err
+	}
+	return c.ids.Delete(ctx, localID)
+}
+
+// diff returns the patch operations that brings the remote representation of ref to that of resource.
+func (c *Connector) diff(ctx context.Context, ref *prop.Resource, resource *prop.Resource) ([]service.PatchOperation, error) {
+	operations := make([]service.PatchOperation, 0)
+	for _, each := range c.mapping.Attributes {
+		before, err := c.remoteValue(ctx, ref, each.Local)
+		if err != nil {
+			return nil, err
+		}
+		after, err := c.remoteValue(ctx, resource, each.Local)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case reflect.DeepEqual(before, after):
+			continue
+		case after == nil:
+			operations = append(operations, service.PatchOperation{Op: "remove", Path: each.Remote})
+		default:
+			raw, err := json.Marshal(after)
+			if err != nil {
+				return nil, err
+			}
+			operations = append(operations, service.PatchOperation{Op: "replace", Path: each.Remote, Value: raw})
+		}
+	}
+	return operations, nil
+}
+
+// remoteValue returns the value at the local path in the resource, with references translated to remote ids.
+func (c *Connector) remoteValue(ctx context.Context, resource *prop.Resource, path string) (interface{}, error) {
+	value, attr, err := localValue(resource, path)
+	if err != nil || value == nil || !isReference(attr) {
+		return value, err
+	}
+	return translateReferences(value, func(localID string) (string, bool, error) {
+		for _, ids := range c.references {
+			if remoteID, ok, err := ids.Get(ctx, localID); err != nil || ok {
+				return remoteID, ok, err
+			}
+		}
+		return "", false, nil
+	})
+}
+
+// lock locks the resource of the local id, and returns the function to unlock it.
+func (c *Connector) lock(localID string) func() {
+	c.locksMu.Lock()
+	l, ok := c.locks[localID]
+	if !ok {
+		l = new(resourceLock)
+		c.locks[localID] = l
+	}
+	l.holders++
+	c.locksMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		c.locksMu.Lock()
+		if l.holders--; l.holders == 0 {
+			delete(c.locks, localID)
+		}
+		c.locksMu.Unlock()
+	}
+}
+
+// retry invokes f until it succeeds, returns a non-transient error, or the attempts are exhausted.
+func (c *Connector) retry(ctx context.Context, f func() error) (err error) {
+	interval := c.interval
+	for i := 0; i < c.attempts; i++ {
+		if err = f(); err == nil || !isTransient(err) {
+			return
+		}
+		if i == c.attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+			interval *= 2
+		}
+	}
+	return
+}
+
+// isTransient returns true if the error is not a SCIM error reported with a 4xx status.
+func isTransient(err error) bool {
+	var scimErr *spec.Error
+	if errors.As(err, &scimErr) {
+		return scimErr.Status >= 500
+	}
+	return true
+}
+
+// dispatch runs the job asynchronously, without blocking. Jobs of the same resource are run one by one in the order
+// they were dispatched, so that changes to the resource reach the remote in order. Jobs are dropped, and reported with
+// ErrDispatchDropped, when too many jobs are waiting, or the connector is closed.
+func (c *Connector) dispatch(localID string, job func(ctx context.Context) error) {
+	c.startOnce.Do(func() {
+		for _, jobs := range c.jobs {
+			go func(jobs chan func()) {
+				for each := range jobs {
+					each()
+				}
+			}(jobs)
+		}
+	})
+
+	c.jobsMu.RLock()
+	defer c.jobsMu.RUnlock()
+	if c.closed {
+		c.onError(localID, fmt.Errorf("%w: connector is closed", ErrDispatchDropped))
+		return
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(localID))
+	select {
+	case c.jobs[hash.Sum32()%dispatchWorkers] <- func() {
+		if err := job(context.Background()); err != nil {
+			c.onError(localID, err)
+		}
+	}:
+	default:
+		c.onError(localID, fmt.Errorf("%w: too many changes are waiting to be pushed", ErrDispatchDropped))
+	}
+}
+
+// Close stops the processing of the asynchronously dispatched changes. Changes dispatched by the service decorators
+// after Close are dropped.
+func (c *Connector) Close() {
+	c.jobsMu.Lock()
+	defer c.jobsMu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for _, jobs := range c.jobs {
+		close(jobs)
+	}
+}
@@ -0,0 +1,559 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/handlerutil"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConnector(t *testing.T) {
+	s := new(ConnectorTestSuite)
+	suite.Run(t, s)
+}
+
+type ConnectorTestSuite struct {
+	suite.Suite
+	resourceType      *spec.ResourceType
+	groupResourceType *spec.ResourceType
+	mapping           *Mapping
+	remoteLock        sync.Mutex
+}
+
+func (s *ConnectorTestSuite) TestMappingApply() {
+	tests := []struct {
+		name     string
+		mapping  *Mapping
+		resource map[string]interface{}
+		expect   func(t *testing.T, payload map[string]interface{}, err error)
+	}{
+		{
+			name:    "default",
+			mapping: s.mapping,
+			resource: map[string]interface{}{
+				"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"id":       "foo",
+				"userName": "foo",
+				"name": map[string]interface{}{
+					"givenName": "Foo",
+				},
+				"emails": []interface{}{
+					map[string]interface{}{"value": "foo@bar.com"},
+				},
+			},
+			expect: func(t *testing.T, payload map[string]interface{}, err error) {
+				assert.Nil(t, err)
+				raw, _ := json.Marshal(payload)
+				assert.JSONEq(t, `
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"externalId": "foo",
+	"userName": "foo",
+	"name": {"givenName": "Foo"},
+	"emails": [{"value": "foo@bar.com"}]
+}
+`, string(raw))
+			},
+		},
+		{
+			name: "filter in path",
+			mapping: &Mapping{
+				Endpoint:   "/Users",
+				Attributes: []AttributeMapping{{Local: `emails[type eq "work"].value`, Remote: "email"}},
+			},
+			resource: map[string]interface{}{
+				"schemas": []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"id":      "foo",
+			},
+			expect: func(t *testing.T, payload map[string]interface{}, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidPath))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			payload, err := test.mapping.Apply(s.resourceOf(t, test.resource))
+			test.expect(t, payload, err)
+		})
+	}
+}
+
+func (s *ConnectorTestSuite) TestConnectedServices() {
+	var (
+		remoteDB    = db.Memory()
+		server      = s.remoteServer(remoteDB, true)
+		localDB     = db.Memory()
+		ids         = MemoryIDMap()
+		localConfig = new(spec.ServiceProviderConfig)
+	)
+	defer server.Close()
+	localConfig.Patch.Supported = true
+
+	connector := New(NewRemote(server.URL, nil), s.mapping, ids).
+		OnError(func(localID string, err error) {
+			s.T().Errorf("failed to push %s: %s", localID, err.Error())
+		})
+	defer connector.Close()
+
+	create := ConnectedCreate(service.CreateService(s.resourceType, localDB, []filter.ByResource{
+		filter.ByPropertyToByResource(filter.UUIDFilter()),
+	}), connector)
+	patch := ConnectedPatch(service.PatchService(localConfig, localDB, nil, []filter.ByResource{
+		filter.MetaFilter(),
+	}), connector)
+	del := ConnectedDelete(service.DeleteService(localConfig, localDB), connector)
+
+	remoteUserName := func() string {
+		s.remoteLock.Lock()
+		defer s.remoteLock.Unlock()
+		resources, _ := remoteDB.Query(context.Background(), "id pr", nil, nil, nil)
+		if len(resources) != 1 {
+			return ""
+		}
+		p, _ := resources[0].RootProperty().ChildAtIndex("userName")
+		if p.IsUnassigned() {
+			return ""
+		}
+		return p.Raw().(string)
+	}
+
+	createResp, err := create.Do(context.Background(), &service.CreateRequest{
+		PayloadSource: strings.NewReader(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"foo","timezone":"Asia/Shanghai"}`),
+	})
+	require.Nil(s.T(), err)
+	localID := createResp.Resource.IdOrEmpty()
+	assert.Eventually(s.T(), func() bool { return remoteUserName() == "foo" }, 5*time.Second, 10*time.Millisecond)
+
+	remoteID, ok, err := ids.Get(context.Background(), localID)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+	assert.NotEqual(s.T(), localID, remoteID)
+
+	_, err = patch.Do(context.Background(), &service.PatchRequest{
+		ResourceID: localID,
+		PayloadSource: strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [{"op": "replace", "path": "userName", "value": "bar"}]
+}`),
+	})
+	require.Nil(s.T(), err)
+	assert.Eventually(s.T(), func() bool { return remoteUserName() == "bar" }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = del.Do(context.Background(), &service.DeleteRequest{ResourceID: localID})
+	require.Nil(s.T(), err)
+	assert.Eventually(s.T(), func() bool {
+		s.remoteLock.Lock()
+		defer s.remoteLock.Unlock()
+		n, _ := remoteDB.Count(context.Background(), "id pr")
+		return n == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *ConnectorTestSuite) TestUpdatedWithoutPatchSupport() {
+	var (
+		remoteDB = db.Memory()
+		server   = s.remoteServer(remoteDB, false)
+		ids      = MemoryIDMap()
+	)
+	defer server.Close()
+
+	connector := New(NewRemote(server.URL, nil), s.mapping, ids)
+	before := s.resourceOf(s.T(), map[string]interface{}{
+		"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"id":       "foo",
+		"userName": "foo",
+	})
+	after := s.resourceOf(s.T(), map[string]interface{}{
+		"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"id":       "foo",
+		"userName": "bar",
+	})
+
+	require.Nil(s.T(), connector.Created(context.Background(), before))
+	require.Nil(s.T(), connector.Updated(context.Background(), before, after))
+
+	n, err := remoteDB.Count(context.Background(), `userName eq "bar"`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, n)
+}
+
+func (s *ConnectorTestSuite) TestReconcile() {
+	var (
+		remoteDB = db.Memory()
+		server   = s.remoteServer(remoteDB, true)
+		localDB  = db.Memory()
+		ids      = MemoryIDMap()
+	)
+	defer server.Close()
+
+	for _, data := range []map[string]interface{}{
+		{"schemas": []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"}, "id": "l1", "userName": "foo"},
+		{"schemas": []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"}, "id": "l2", "userName": "bar"},
+	} {
+		require.Nil(s.T(), localDB.Insert(context.Background(), s.resourceOf(s.T(), data)))
+	}
+
+	// r3 is the remote counterpart of a local resource that no longer exists
+	require.Nil(s.T(), remoteDB.Insert(context.Background(), s.resourceOf(s.T(), map[string]interface{}{
+		"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"id":       "r3",
+		"userName": "baz",
+	})))
+	require.Nil(s.T(), ids.Put(context.Background(), "l3", "r3"))
+
+	report, err := New(NewRemote(server.URL, nil), s.mapping, ids).Reconcile(context.Background(), localDB)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 2, report.Upserted)
+	assert.Equal(s.T(), 1, report.Deleted)
+	assert.Len(s.T(), report.Failed, 0)
+
+	for _, userName := range []string{"foo", "bar"} {
+		n, _ := remoteDB.Count(context.Background(), `userName eq "`+userName+`"`)
+		assert.Equal(s.T(), 1, n)
+	}
+	_, err = remoteDB.Get(context.Background(), "r3", nil)
+	assert.True(s.T(), errors.Is(err, spec.ErrNotFound))
+
+	_, ok, _ := ids.Get(context.Background(), "l3")
+	assert.False(s.T(), ok)
+}
+
+func (s *ConnectorTestSuite) TestRetry() {
+	tests := []struct {
+		name        string
+		status      int
+		expectCalls int32
+		expectErr   func(t *testing.T, err error)
+	}{
+		{
+			name:        "transient error is retried",
+			status:      http.StatusServiceUnavailable,
+			expectCalls: 3,
+			expectErr: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:        "client error is not retried",
+			status:      http.StatusConflict,
+			expectCalls: 1,
+			expectErr: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) < 3 {
+					rw.WriteHeader(test.status)
+					return
+				}
+				rw.WriteHeader(http.StatusCreated)
+				_, _ = rw.Write([]byte(`{"id":"r1"}`))
+			}))
+			defer server.Close()
+
+			connector := New(NewRemote(server.URL, nil), s.mapping, MemoryIDMap()).Retry(3, time.Millisecond)
+			err := connector.Created(context.Background(), s.resourceOf(t, map[string]interface{}{
+				"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"id":       "l1",
+				"userName": "foo",
+			}))
+			test.expectErr(t, err)
+			assert.Equal(t, test.expectCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func (s *ConnectorTestSuite) TestReferences() {
+	var (
+		payloads = make(chan map[string]interface{}, 2)
+		server   = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				_, _ = rw.Write([]byte(`{"patch":{"supported":true}}`))
+			case http.MethodPost:
+				payload := map[string]interface{}{}
+				_ = json.NewDecoder(r.Body).Decode(&payload)
+				payloads <- payload
+				rw.WriteHeader(http.StatusCreated)
+				_, _ = rw.Write([]byte(`{"id":"rg1"}`))
+			case http.MethodPatch:
+				payload := map[string]interface{}{}
+				_ = json.NewDecoder(r.Body).Decode(&payload)
+				payloads <- payload
+				rw.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		userIDs  = MemoryIDMap()
+		groupIDs = MemoryIDMap()
+		remote   = NewRemote(server.URL, nil)
+	)
+	defer server.Close()
+
+	require.Nil(s.T(), userIDs.Put(context.Background(), "u1", "ru1"))
+	require.Nil(s.T(), groupIDs.Put(context.Background(), "g2", "rg2"))
+	users := New(remote, s.mapping, userIDs)
+	groups := New(remote, &Mapping{
+		Endpoint:   "/Groups",
+		Schemas:    []string{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+		Attributes: []AttributeMapping{{Local: "displayName", Remote: "displayName"}, {Local: "members", Remote: "members"}},
+	}, groupIDs).ReferencesTo(users)
+
+	group := func(members ...interface{}) *prop.Resource {
+		r := prop.NewResource(s.groupResourceType)
+		require.False(s.T(), r.Navigator().Replace(map[string]interface{}{
+			"schemas":     []interface{}{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+			"id":          "g1",
+			"displayName": "g1",
+			"members":     members,
+		}).HasError())
+		return r
+	}
+	before := group(
+		map[string]interface{}{"value": "u1", "$ref": "https://local/Users/u1", "display": "u1"},
+		map[string]interface{}{"value": "g2", "$ref": "https://local/Groups/g2"},
+		map[string]interface{}{"value": "u2"},
+	)
+	after := group(map[string]interface{}{"value": "g2"})
+
+	// local ids are translated to remote ids, and unknown members are left out
+	require.Nil(s.T(), groups.Created(context.Background(), before))
+	raw, _ := json.Marshal(<-payloads)
+	assert.JSONEq(s.T(), `
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+	"displayName": "g1",
+	"members": [{"value": "ru1", "display": "u1"}, {"value": "rg2"}]
+}`, string(raw))
+
+	require.Nil(s.T(), groups.Updated(context.Background(), before, after))
+	raw, _ = json.Marshal(<-payloads)
+	assert.JSONEq(s.T(), `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [{"op": "replace", "path": "members", "value": [{"value": "rg2"}]}]
+}`, string(raw))
+}
+
+func (s *ConnectorTestSuite) TestConcurrency() {
+	var (
+		release = make(chan struct{})
+		server  = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			payload := map[string]interface{}{}
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			if payload["userName"] == "slow" {
+				<-release
+			}
+			rw.WriteHeader(http.StatusCreated)
+			_, _ = rw.Write([]byte(`{"id":"r-` + payload["userName"].(string) + `"}`))
+		}))
+		connector = New(NewRemote(server.URL, nil), s.mapping, MemoryIDMap())
+		user      = func(id string) *prop.Resource {
+			return s.resourceOf(s.T(), map[string]interface{}{
+				"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"id":       id,
+				"userName": id,
+			})
+		}
+	)
+	defer server.Close()
+
+	slow := make(chan error)
+	go func() {
+		slow <- connector.Created(context.Background(), user("slow"))
+	}()
+
+	// a slow remote call does not hold up the changes to other resources
+	assert.Nil(s.T(), connector.Created(context.Background(), user("fast")))
+	close(release)
+	assert.Nil(s.T(), <-slow)
+}
+
+func (s *ConnectorTestSuite) TestDispatch() {
+	var (
+		errs      = make(chan error, 3)
+		connector = New(nil, s.mapping, MemoryIDMap()).OnError(func(_ string, err error) {
+			errs <- err
+		})
+		release = make(chan struct{})
+	)
+
+	// the worker of the resource is held up, until its queue is full
+	for i := 0; i <= dispatchCapacity; i++ {
+		connector.dispatch("foo", func(_ context.Context) error {
+			<-release
+			return nil
+		})
+	}
+	connector.dispatch("foo", func(_ context.Context) error { return nil })
+	assert.True(s.T(), errors.Is(<-errs, ErrDispatchDropped))
+	close(release)
+
+	connector.Close()
+	connector.dispatch("foo", func(_ context.Context) error { return nil })
+	assert.True(s.T(), errors.Is(<-errs, ErrDispatchDropped))
+}
+
+// remoteServer returns a SCIM server for the User resource type, backed by the database.
+// Requests are served one at a time, holding the lock, so that the test can inspect the database safely.
+func (s *ConnectorTestSuite) remoteServer(database db.DB, patchSupported bool) *httptest.Server {
+	config := new(spec.ServiceProviderConfig)
+	config.Patch.Supported = patchSupported
+
+	var (
+		create  = service.CreateService(s.resourceType, database, []filter.ByResource{filter.ByPropertyToByResource(filter.UUIDFilter())})
+		replace = service.ReplaceService(config, s.resourceType, database, []filter.ByResource{filter.ByPropertyToByResource(filter.ReadOnlyFilter()), filter.MetaFilter()})
+		patch   = service.PatchService(config, database, nil, []filter.ByResource{filter.MetaFilter()})
+		del     = service.DeleteService(config, database)
+	)
+
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.remoteLock.Lock()
+		defer s.remoteLock.Unlock()
+
+		id := strings.TrimPrefix(r.URL.Path, "/Users/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/ServiceProviderConfig":
+			_ = json.NewEncoder(rw).Encode(config)
+		case r.Method == http.MethodPost && r.URL.Path == "/Users":
+			cr, closer := handlerutil.CreateRequest(r)
+			defer closer()
+			resp, err := create.Do(r.Context(), cr)
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			rw.WriteHeader(http.StatusCreated)
+			_ = handlerutil.WriteResourceToResponse(rw, resp.Resource)
+		case r.Method == http.MethodPut:
+			rr, closer := handlerutil.ReplaceRequest(r)
+			defer closer()
+			resp, err := replace.Do(r.Context(), rr(id))
+			if err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			if !resp.Replaced {
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_ = handlerutil.WriteResourceToResponse(rw, resp.Resource)
+		case r.Method == http.MethodPatch:
+			pr, closer := handlerutil.PatchRequest(r)
+			defer closer()
+			if _, err := patch.Do(r.Context(), pr(id)); err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			if _, err := del.Do(r.Context(), handlerutil.DeleteRequest(r)(id)); err != nil {
+				_ = handlerutil.WriteError(rw, err)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func (s *ConnectorTestSuite) resourceOf(t *testing.T, data interface{}) *prop.Resource {
+	r := prop.NewResource(s.resourceType)
+	require.False(t, r.Navigator().Replace(data).HasError())
+	return r
+}
+
+func (s *ConnectorTestSuite) SetupSuite() {
+	for _, each := range []struct {
+		filepath  string
+		structure interface{}
+		post      func(parsed interface{})
+	}{
+		{
+			filepath:  "../../../public/schemas/core_schema.json",
+			structure: new(spec.Schema),
+			post: func(parsed interface{}) {
+				spec.Schemas().Register(parsed.(*spec.Schema))
+			},
+		},
+		{
+			filepath:  "../../../public/schemas/user_schema.json",
+			structure: new(spec.Schema),
+			post: func(parsed interface{}) {
+				spec.Schemas().Register(parsed.(*spec.Schema))
+			},
+		},
+		{
+			filepath:  "../../../public/schemas/group_schema.json",
+			structure: new(spec.Schema),
+			post: func(parsed interface{}) {
+				spec.Schemas().Register(parsed.(*spec.Schema))
+			},
+		},
+		{
+			filepath:  "../../../public/resource_types/user_resource_type.json",
+			structure: new(spec.ResourceType),
+			post: func(parsed interface{}) {
+				s.resourceType = parsed.(*spec.ResourceType)
+			},
+		},
+		{
+			filepath:  "../../../public/resource_types/group_resource_type.json",
+			structure: new(spec.ResourceType),
+			post: func(parsed interface{}) {
+				s.groupResourceType = parsed.(*spec.ResourceType)
+			},
+		},
+	} {
+		f, err := os.Open(each.filepath)
+		require.Nil(s.T(), err)
+
+		raw, err := ioutil.ReadAll(f)
+		require.Nil(s.T(), err)
+
+		err = json.Unmarshal(raw, each.structure)
+		require.Nil(s.T(), err)
+
+		if each.post != nil {
+			each.post(each.structure)
+		}
+	}
+
+	mapping, err := ParseMapping(strings.NewReader(`
+{
+	"endpoint": "/Users",
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"attributes": [
+		{"local": "id", "remote": "externalId"},
+		{"local": "userName", "remote": "userName"},
+		{"local": "name.givenName", "remote": "name.givenName"},
+		{"local": "emails", "remote": "emails"}
+	]
+}`))
+	require.Nil(s.T(), err)
+	s.mapping = mapping
+}
@@ -0,0 +1,10 @@
+// This package implements an outbound provisioning connector, which pushes the changes of local resources to a remote
+// SCIM service provider.
+//
+// The Connector translates the local resource into the remote representation using a Mapping, and calls the remote
+// SCIM endpoint through a Remote client. The ids of the resources created at the remote are kept in an IDMap, so that
+// subsequent changes can be addressed to them. Local changes are captured by decorating the services in the service
+// package with ConnectedCreate, ConnectedReplace, ConnectedPatch and ConnectedDelete. Because changes may be lost
+// when the remote is unavailable, or dropped rather than holding up requests when the remote falls behind, Reconcile and
+// Run provides the means to periodically bring the remote up to date with the local database.
+package connector
@@ -0,0 +1,66 @@
+package connector
+
+import (
+	"context"
+	"sync"
+)
+
+// IDMap keeps track of the correspondence between the id of the local resource and the id of the remote resource.
+type IDMap interface {
+	// Get returns the remote id mapped to the local id. If no mapping exists, ok is false.
+	Get(ctx context.Context, localID string) (remoteID string, ok bool, err error)
+	// Put maps the local id to the remote id, overwriting any existing mapping.
+	Put(ctx context.Context, localID string, remoteID string) error
+	// Delete removes the mapping of the local id, if any.
+	Delete(ctx context.Context, localID string) error
+	// ForEach invokes the callback for each mapping. Iteration stops at the first error returned by callback.
+	ForEach(ctx context.Context, callback func(localID string, remoteID string) error) error
+}
+
+// MemoryIDMap returns an in-memory implementation of IDMap. The mappings are lost when the process exits, hence a
+// Reconcile is necessary to rebuild them, which in turn requires the remote to tolerate re-creation.
+func MemoryIDMap() IDMap {
+	return &memoryIDMap{ids: map[string]string{}}
+}
+
+type memoryIDMap struct {
+	sync.RWMutex
+	ids map[string]string
+}
+
+func (m *memoryIDMap) Get(_ context.Context, localID string) (string, bool, error) {
+	m.RLock()
+	defer m.RUnlock()
+	remoteID, ok := m.ids[localID]
+	return remoteID, ok, nil
+}
+
+func (m *memoryIDMap) Put(_ context.Context, localID string, remoteID string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.ids[localID] = remoteID
+	return nil
+}
+
+func (m *memoryIDMap) Delete(_ context.Context, localID string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.ids, localID)
+	return nil
+}
+
+func (m *memoryIDMap) ForEach(_ context.Context, callback func(localID string, remoteID string) error) error {
+	m.RLock()
+	snapshot := make(map[string]string, len(m.ids))
+	for k, v := range m.ids {
+		snapshot[k] = v
+	}
+	m.RUnlock()
+
+	for k, v := range snapshot {
+		if err := callback(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
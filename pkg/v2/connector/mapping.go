@@ -0,0 +1,218 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+type (
+	// Mapping describes how a local resource is represented at the remote.
+	Mapping struct {
+		Endpoint   string             `json:"endpoint"`   // remote resource type endpoint, i.e. /Users
+		Schemas    []string           `json:"schemas"`    // schemas of the remote resource
+		Attributes []AttributeMapping `json:"attributes"` // attribute mapping rules
+	}
+	// AttributeMapping maps the value at the local path to the remote path. The local path is a SCIM path that does not
+	// contain filters or traverse through multiValued attributes (i.e. name.givenName, emails). Extension attributes
+	// can be addressed by the schema URN prefix, given the resource type is registered with crud.Register. The remote
+	// path is in the same form, and denotes where the value is placed in the remote resource.
+	//
+	// Local attributes referencing other resources, that is, complex attributes with a "value" sub attribute and a
+	// "$ref" sub attribute referencing resource types (i.e. members of groups), are translated by the Connector: the
+	// value is replaced by the id of the referenced resource at the remote, "$ref" is left out as it locates the local
+	// resource, and references to resources unknown to the remote are left out until they are pushed.
+	AttributeMapping struct {
+		Local  string `json:"local"`
+		Remote string `json:"remote"`
+	}
+)
+
+// ParseMapping parses the JSON representation of the Mapping from the reader.
+func ParseMapping(reader io.Reader) (*Mapping, error) {
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Mapping)
+	if err := json.Unmarshal(raw, m); err != nil {
+		return nil, fmt.Errorf("%w: malformed mapping", spec.ErrInvalidSyntax)
+	}
+	if len(m.Endpoint) == 0 {
+		return nil, fmt.Errorf("%w: mapping has no endpoint", spec.ErrInvalidValue)
+	}
+	return m, nil
+}
+
+// Apply returns the remote representation of the resource. Local attributes that are unassigned are left out.
+func (m *Mapping) Apply(resource *prop.Resource) (map[string]interface{}, error) {
+	return m.apply(func(path string) (interface{}, error) {
+		value, _, err := localValue(resource, path)
+		return value, err
+	})
+}
+
+// apply returns the remote representation of the values returned by valueOf for the local paths.
+func (m *Mapping) apply(valueOf func(path string) (interface{}, error)) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"schemas": m.Schemas,
+	}
+	for _, each := range m.Attributes {
+		value, err := valueOf(each.Local)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		setRemoteValue(payload, each.Remote, value)
+	}
+	return payload, nil
+}
+
+// localValue returns the compacted raw value of the property at path in the resource, or nil if it is unassigned,
+// along with the attribute of the property.
+func localValue(resource *prop.Resource, path string) (interface{}, *spec.Attribute, error) {
+	head, err := expr.CompilePathWith(resource.ResourceType().Registry(), path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nav := resource.Navigator()
+	for cursor := head; cursor != nil; cursor = cursor.Next() {
+		if cursor.IsRootOfFilter() {
+			return nil, nil, fmt.Errorf("%w: filter is not supported in mapping path '%s'", spec.ErrInvalidPath, path)
+		}
+		if nav.Current().Attribute().MultiValued() {
+			return nil, nil, fmt.Errorf("%w: mapping path '%s' traverses multiValued attribute", spec.ErrInvalidPath, path)
+		}
+		if nav.Dot(cursor.Token()).HasError() {
+			return nil, nil, nav.Error()
+		}
+	}
+
+	if nav.Current().IsUnassigned() {
+		return nil, nav.Current().Attribute(), nil
+	}
+	return compact(nav.Current().Raw()), nav.Current().Attribute(), nil
+}
+
+// isReference returns true if the attribute references other resources: a complex attribute with a "value" sub
+// attribute, and a "$ref" sub attribute whose referenceTypes are not limited to external and uri.
+func isReference(attr *spec.Attribute) bool {
+	if attr.Type() != spec.TypeComplex || attr.SubAttributeForName("value") == nil {
+		return false
+	}
+	ref := attr.SubAttributeForName("$ref")
+	if ref == nil || ref.Type() != spec.TypeReference {
+		return false
+	}
+	return !ref.ExistsReferenceType(func(_ string) bool { return true }) || ref.ExistsReferenceType(func(referenceType string) bool {
+		return referenceType != "external" && referenceType != "uri"
+	})
+}
+
+// translateReferences returns the compacted raw value of a reference attribute (see isReference), with the local ids
+// in "value" replaced by the remote ids returned by resolve, and "$ref" left out. References that cannot be resolved
+// are left out.
+func translateReferences(value interface{}, resolve func(localID string) (string, bool, error)) (interface{}, error) {
+	translate := func(element interface{}) (interface{}, error) {
+		reference, ok := element.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		localID, _ := reference["value"].(string)
+		if len(localID) == 0 {
+			return nil, nil
+		}
+		remoteID, ok, err := resolve(localID)
+		if err != nil || !ok {
+			return nil, err
+		}
+		translated := map[string]interface{}{}
+		for k, v := range reference {
+			if k != "$ref" {
+				translated[k] = v
+			}
+		}
+		translated["value"] = remoteID
+		return translated, nil
+	}
+
+	elements, multiValued := value.([]interface{})
+	if !multiValued {
+		return translate(value)
+	}
+	translated := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		t, err := translate(element)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			translated = append(translated, t)
+		}
+	}
+	return compact(translated), nil
+}
+
+// setRemoteValue places the value at the remote path within the payload, creating intermediate objects as necessary.
+func setRemoteValue(payload map[string]interface{}, path string, value interface{}) {
+	segments := remoteSegments(path)
+	cursor := payload
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := cursor[segment].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			cursor[segment] = next
+		}
+		cursor = next
+	}
+	cursor[segments[len(segments)-1]] = value
+}
+
+// remoteSegments splits the remote path into segments, keeping the schema URN prefix, if any, as the first segment.
+func remoteSegments(path string) []string {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		if i := strings.LastIndex(path, ":"); i > 0 {
+			return append([]string{path[:i]}, strings.Split(path[i+1:], ".")...)
+		}
+	}
+	return strings.Split(path, ".")
+}
+
+// compact removes nil values from the raw property value, and returns nil if nothing is left.
+func compact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, each := range v {
+			if c := compact(each); c != nil {
+				m[k] = c
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, 0, len(v))
+		for _, each := range v {
+			if c := compact(each); c != nil {
+				a = append(a, c)
+			}
+		}
+		if len(a) == 0 {
+			return nil
+		}
+		return a
+	default:
+		return value
+	}
+}
@@ -0,0 +1,85 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/db"
+)
+
+// Report summarizes the outcome of a reconciliation.
+type Report struct {
+	Upserted int              // number of local resources pushed to the remote
+	Deleted  int              // number of remote resources deleted because their local counterpart no longer exists
+	Failed   map[string]error // errors keyed by the local id of the resource that failed to reconcile
+}
+
+// Reconcile brings the remote up to date with all resources in the local database: every local resource is replaced,
+// or created if unknown, at the remote; every remote resource whose local counterpart no longer exists is deleted.
+// Failure to reconcile individual resources does not stop the process, but is recorded in the report. An error is only
+// returned when the process cannot proceed, for instance, when the local database cannot be queried.
+func (c *Connector) Reconcile(ctx context.Context, database db.DB) (*Report, error) {
+	resources, err := database.Query(ctx, "id pr", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Failed: map[string]error{}}
+	exists := map[string]struct{}{}
+
+	for _, resource := range resources {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		default:
+		}
+
+		exists[resource.IdOrEmpty()] = struct{}{}
+		unlock := c.lock(resource.IdOrEmpty())
+		err := c.upsert(ctx, resource)
+		unlock()
+		if err != nil {
+			report.Failed[resource.IdOrEmpty()] = err
+		} else {
+			report.Upserted++
+		}
+	}
+
+	orphans := make([]string, 0)
+	if err := c.ids.ForEach(ctx, func(localID string, _ string) error {
+		if _, ok := exists[localID]; !ok {
+			orphans = append(orphans, localID)
+		}
+		return nil
+	}); err != nil {
+		return report, err
+	}
+
+	for _, localID := range orphans {
+		unlock := c.lock(localID)
+		err := c.delete(ctx, localID)
+		unlock()
+		if err != nil {
+			report.Failed[localID] = err
+		} else {
+			report.Deleted++
+		}
+	}
+
+	return report, nil
+}
+
+// Run reconciles at every interval until the context is cancelled. Each report, or error, is passed to the callback.
+func (c *Connector) Run(ctx context.Context, database db.DB, interval time.Duration, callback func(report *Report, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			callback(c.Reconcile(ctx, database))
+		}
+	}
+}
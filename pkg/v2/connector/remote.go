@@ -0,0 +1,134 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/imulab/go-scim/pkg/v2/handlerutil"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// NewRemote returns a new Remote client to the SCIM service provider at baseURL. If client is nil, http.DefaultClient
+// is used.
+func NewRemote(baseURL string, client *http.Client) *Remote {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Remote{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		client:    client,
+		authorize: func(_ *http.Request) {},
+	}
+}
+
+// Remote is a minimal client to a remote SCIM service provider. Resources are exchanged in their JSON object form,
+// because the remote resource types are not necessarily known to this server.
+type Remote struct {
+	baseURL   string
+	client    *http.Client
+	authorize func(r *http.Request)
+	spcOnce   sync.Once
+	spc       *spec.ServiceProviderConfig
+	spcErr    error
+}
+
+// BearerToken makes the client authorize its requests with the bearer token.
+func (r *Remote) BearerToken(token string) *Remote {
+	r.authorize = func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// ServiceProviderConfig returns the service provider config of the remote. The result is fetched once and cached.
+func (r *Remote) ServiceProviderConfig(ctx context.Context) (*spec.ServiceProviderConfig, error) {
+	r.spcOnce.Do(func() {
+		spc := new(spec.ServiceProviderConfig)
+		if err := r.do(ctx, http.MethodGet, "/ServiceProviderConfig", nil, spc); err != nil {
+			r.spcErr = err
+			return
+		}
+		r.spc = spc
+	})
+	return r.spc, r.spcErr
+}
+
+// Create creates the resource at the remote endpoint, and returns the id assigned by the remote.
+func (r *Remote) Create(ctx context.Context, endpoint string, payload map[string]interface{}) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, endpoint, payload, &created); err != nil {
+		return "", err
+	}
+	if len(created.ID) == 0 {
+		return "", fmt.Errorf("%w: remote did not return an id", spec.ErrInternal)
+	}
+	return created.ID, nil
+}
+
+// Replace replaces the resource identified by id at the remote endpoint.
+func (r *Remote) Replace(ctx context.Context, endpoint string, id string, payload map[string]interface{}) error {
+	return r.do(ctx, http.MethodPut, endpoint+"/"+id, payload, nil)
+}
+
+// Patch modifies the resource identified by id at the remote endpoint.
+func (r *Remote) Patch(ctx context.Context, endpoint string, id string, operations []service.PatchOperation) error {
+	return r.do(ctx, http.MethodPatch, endpoint+"/"+id, &service.PatchPayload{
+		Schemas:    []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		Operations: operations,
+	}, nil)
+}
+
+// Delete deletes the resource identified by id at the remote endpoint.
+func (r *Remote) Delete(ctx context.Context, endpoint string, id string) error {
+	return r.do(ctx, http.MethodDelete, endpoint+"/"+id, nil, nil)
+}
+
+// do sends the request with the JSON encoded body, and decodes the response into out, if not nil. Non-2xx response is
+// returned as an error that wraps *spec.Error.
+func (r *Remote) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, r.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", spec.ApplicationScimJson)
+	if body != nil {
+		req.Header.Set("Content-Type", spec.ApplicationScimJson)
+	}
+	r.authorize(req)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return handlerutil.ReadError(resp)
+	}
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%w: malformed response from remote", spec.ErrInternal)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"context"
+
+	"github.com/imulab/go-scim/pkg/v2/service"
+)
+
+// ConnectedCreate returns a service.Create that pushes each created resource to the remote asynchronously. As with other
+// decorators in this package, the resource is cloned before being dispatched, so that the asynchronous job does not
+// observe further changes to it.
+func ConnectedCreate(svc service.Create, connector *Connector) service.Create {
+	return &connectedCreate{service: svc, connector: connector}
+}
+
+type connectedCreate struct {
+	service   service.Create
+	connector *Connector
+}
+
+func (s *connectedCreate) Do(ctx context.Context, req *service.CreateRequest) (resp *service.CreateResponse, err error) {
+	resp, err = s.service.Do(ctx, req)
+	if err != nil {
+		return
+	}
+
+	resource := resp.Resource.Clone()
+	s.connector.dispatch(resource.IdOrEmpty(), func(ctx context.Context) error {
+		return s.connector.Created(ctx, resource)
+	})
+	return
+}
+
+// ConnectedReplace returns a service.Replace that pushes each replaced resource to the remote asynchronously.
+func ConnectedReplace(svc service.Replace, connector *Connector) service.Replace {
+	return &connectedReplace{service: svc, connector: connector}
+}
+
+type connectedReplace struct {
+	service   service.Replace
+	connector *Connector
+}
+
+func (s *connectedReplace) Do(ctx context.Context, req *service.ReplaceRequest) (resp *service.ReplaceResponse, err error) {
+	resp, err = s.service.Do(ctx, req)
+	if err != nil || !resp.Replaced {
+		return
+	}
+
+	ref, resource := resp.Ref.Clone(), resp.Resource.Clone()
+	s.connector.dispatch(resource.IdOrEmpty(), func(ctx context.Context) error {
+		return s.connector.Updated(ctx, ref, resource)
+	})
+	return
+}
+
+// ConnectedPatch returns a service.Patch that pushes each patched resource to the remote asynchronously.
+func ConnectedPatch(svc service.Patch, connector *Connector) service.Patch {
+	return &connectedPatch{service: svc, connector: connector}
+}
+
+type connectedPatch struct {
+	service   service.Patch
+	connector *Connector
+}
+
+func (s *connectedPatch) Do(ctx context.Context, req *service.PatchRequest) (resp *service.PatchResponse, err error) {
+	resp, err = s.service.Do(ctx, req)
+	if err != nil || !resp.Patched {
+		return
+	}
+
+	ref, resource := resp.Ref.Clone(), resp.Resource.Clone()
+	s.connector.dispatch(resource.IdOrEmpty(), func(ctx context.Context) error {
+		return s.connector.Updated(ctx, ref, resource)
+	})
+	return
+}
+
+// ConnectedDelete returns a service.Delete that deletes the remote counterpart of each deleted resource asynchronously.
+func ConnectedDelete(svc service.Delete, connector *Connector) service.Delete {
+	return &connectedDelete{service: svc, connector: connector}
+}
+
+type connectedDelete struct {
+	service   service.Delete
+	connector *Connector
+}
+
+func (s *connectedDelete) Do(ctx context.Context, req *service.DeleteRequest) (resp *service.DeleteResponse, err error) {
+	resp, err = s.service.Do(ctx, req)
+	if err != nil {
+		return
+	}
+
+	deleted := resp.Deleted.Clone()
+	s.connector.dispatch(deleted.IdOrEmpty(), func(ctx context.Context) error {
+		return s.connector.Deleted(ctx, deleted)
+	})
+	return
+}
@@ -15,7 +15,7 @@ func Add(resource *prop.Resource, path string, value interface{}) error {
 		return resource.Navigator().Add(value).Error()
 	}
 
-	head, err := expr.CompilePath(path)
+	head, err := expr.CompilePathWith(resource.ResourceType().Registry(), path)
 	if err != nil {
 		return err
 	}
@@ -33,7 +33,7 @@ func Replace(resource *prop.Resource, path string, value interface{}) error {
 		return resource.Navigator().Replace(value).Error()
 	}
 
-	head, err := expr.CompilePath(path)
+	head, err := expr.CompilePathWith(resource.ResourceType().Registry(), path)
 	if err != nil {
 		return err
 	}
@@ -49,7 +49,7 @@ func Delete(resource *prop.Resource, path string) error {
 		return fmt.Errorf("%w: path must be specified for delete operation", spec.ErrInvalidPath)
 	}
 
-	head, err := expr.CompilePath(path)
+	head, err := expr.CompilePathWith(resource.ResourceType().Registry(), path)
 	if err != nil {
 		return err
 	}
@@ -359,6 +359,34 @@ func (s *CrudTestSuite) TestDelete() {
 				}, r.Navigator().Dot("emails").Current().Raw())
 			},
 		},
+		{
+			name: "delete multiValued property element followed by other elements",
+			getResource: func(t *testing.T) *prop.Resource {
+				r := prop.NewResource(s.resourceType)
+				assert.False(t, r.Navigator().Dot("emails").Add([]interface{}{
+					map[string]interface{}{
+						"value": "foo",
+					},
+					map[string]interface{}{
+						"value": "bar",
+					},
+					map[string]interface{}{
+						"value": "foo",
+					},
+				}).HasError())
+				return r
+			},
+			path: `emails[value eq "foo"]`,
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{
+						"value":   "bar",
+						"primary": nil,
+					},
+				}, r.Navigator().Dot("emails").Current().Raw())
+			},
+		},
 		{
 			name: "delete multiValued property element field with filter",
 			getResource: func(t *testing.T) *prop.Resource {
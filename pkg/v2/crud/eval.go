@@ -12,7 +12,7 @@ import (
 
 // Evaluate the resource with the given SCIM filter and return the boolean result or an error.
 func Evaluate(resource *prop.Resource, filter string) (bool, error) {
-	cf, err := expr.CompileFilter(filter)
+	cf, err := expr.CompileFilterWith(resource.ResourceType().Registry(), filter)
 	if err != nil {
 		return false, err
 	}
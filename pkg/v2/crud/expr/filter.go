@@ -19,14 +19,23 @@ import (
 //	                     /  \
 //	                primary true
 //
+// URN prefixes are recognized from the default schema registry. Use CompileFilterWith to recognize them from a different
+// registry.
 func CompileFilter(filter string) (*Expression, error) {
+	return CompileFilterWith(spec.Schemas(), filter)
+}
+
+// CompileFilterWith compiles the given SCIM filter like CompileFilter, but recognizes URN prefixes registered in the
+// given schema registry.
+func CompileFilterWith(registry *spec.SchemaRegistry, filter string) (*Expression, error) {
 	compiler := &filterCompiler{
-		scan:    &filterScanner{},
-		data:    append(copyOf(filter), 0, 0),
-		off:     0,
-		op:      scanFilterSkipSpace,
-		opStack: make([]*Expression, 0),
-		rsStack: make([]*Expression, 0),
+		registry: registry,
+		scan:     &filterScanner{},
+		data:     append(copyOf(filter), 0, 0),
+		off:      0,
+		op:       scanFilterSkipSpace,
+		opStack:  make([]*Expression, 0),
+		rsStack:  make([]*Expression, 0),
 	}
 	compiler.scan.init()
 
@@ -149,7 +158,9 @@ var (
 
 // Compiler that utilizes filterScanner to convert a string based filter query to tree.
 type filterCompiler struct {
-	scan *filterScanner
+	// registry to compile paths with
+	registry *spec.SchemaRegistry
+	scan     *filterScanner
 	// raw filter in bytes, appended by termination bytes (byte 0)
 	data []byte
 	// index to the next byte to be read in data
@@ -212,7 +223,7 @@ func (c *filterCompiler) pushBuildResult(step *Expression) error {
 
 	// Path: re-compile and push
 	if step.IsPath() {
-		head, err := CompilePath(step.token)
+		head, err := CompilePathWith(c.registry, step.token)
 		if err != nil {
 			return fmt.Errorf("%w: invalid path in filter", spec.ErrInvalidFilter)
 		} else if head.ContainsFilter() {
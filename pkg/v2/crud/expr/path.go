@@ -21,12 +21,21 @@ import (
 //	            /  \
 //	         value  "foo@bar.com"
 //
+// URN prefixes are recognized from the default schema registry. Use CompilePathWith to recognize them from a different
+// registry.
 func CompilePath(path string) (*Expression, error) {
+	return CompilePathWith(spec.Schemas(), path)
+}
+
+// CompilePathWith compiles the given SCIM path expression like CompilePath, but recognizes URN prefixes registered
+// in the given schema registry.
+func CompilePathWith(registry *spec.SchemaRegistry, path string) (*Expression, error) {
 	compiler := &pathCompiler{
-		scan: &pathScanner{},
-		data: append(copyOf(path), 0, 0),
-		off:  0,
-		op:   scanPathContinue,
+		registry: registry,
+		scan:     &pathScanner{urns: registry.URNs()},
+		data:     append(copyOf(path), 0, 0),
+		off:      0,
+		op:       scanPathContinue,
 	}
 	compiler.scan.init()
 
@@ -50,7 +59,9 @@ func CompilePath(path string) (*Expression, error) {
 // Compiler that utilizes pathScanner to convert a string based path query to a linked list of steps, each representing
 // a unit in the path.
 type pathCompiler struct {
-	scan *pathScanner
+	// registry to compile nested filters with
+	registry *spec.SchemaRegistry
+	scan     *pathScanner
 	// raw data of the path query
 	data []byte
 	// index for the next byte to be read
@@ -117,7 +128,7 @@ func (c *pathCompiler) scanFilter() (*Expression, error) {
 	end := c.skipWhile(scanPathContinue)
 	switch c.op {
 	case scanPathEndFilter, scanPathEnd:
-		root, err := CompileFilter(string(c.data[start:end]))
+		root, err := CompileFilterWith(c.registry, string(c.data[start:end]))
 		if err != nil {
 			return nil, err
 		}
@@ -199,10 +210,15 @@ type pathScanner struct {
 	// number of bytes that has been scanned. This is assisting data that helps formulating
 	// error information.
 	bytes int64
+	// trie of recognized URN prefixes, defaults to that of the default schema registry
+	urns *spec.URNs
 }
 
 // Initialize value of this scanner.
 func (ps *pathScanner) init() {
+	if ps.urns == nil {
+		ps.urns = spec.Schemas().URNs()
+	}
 	ps.step = ps.stateBeginStep
 	ps.err = nil
 	ps.bytes = 0
@@ -217,7 +233,7 @@ func (ps *pathScanner) stateBeginStep(scan *pathScanner, c byte) int {
 		return ps.error(c, "invalid character for the first alphabet of SCIM attribute name.")
 	}
 
-	match, ok := urnsCache.nextTrie(c)
+	match, ok := ps.urns.Next(c)
 	if ok {
 		ps.step = ps.stateTryNamespaceStep(match)
 	} else {
@@ -230,9 +246,9 @@ func (ps *pathScanner) stateBeginStep(scan *pathScanner, c byte) int {
 // Intermediate state in which we are in a step, but is trying to see if the current step is a reserved namespace.
 // If the current character is still a match in the dictionary trie, the state is maintained; otherwise, attempt
 // to downgrade the step state to an ordinary step state (stateInStep).
-func (ps *pathScanner) stateTryNamespaceStep(root *urns) func(scan *pathScanner, c byte) int {
+func (ps *pathScanner) stateTryNamespaceStep(root *spec.URNs) func(scan *pathScanner, c byte) int {
 	return func(scan *pathScanner, c byte) int {
-		match, ok := root.nextTrie(c)
+		match, ok := root.Next(c)
 		if ok {
 			scan.step = ps.stateTryNamespaceStep(match)
 			return scanPathContinue
@@ -243,7 +259,7 @@ func (ps *pathScanner) stateTryNamespaceStep(root *urns) func(scan *pathScanner,
 			return scanPathContinue
 		}
 
-		if c == ':' && root.isWord() {
+		if c == ':' && root.IsWord() {
 			scan.step = ps.stateBeginStep
 			return scanPathEndStep
 		}
@@ -1,6 +1,7 @@
 package expr
 
 import (
+	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"testing"
@@ -252,3 +253,23 @@ func (s *PathTestSuite) TestPathScanner() {
 		})
 	}
 }
+
+func (s *PathTestSuite) TestCompileWithRegistry() {
+	registry := spec.NewSchemaRegistry()
+	registry.RegisterURN("urn:imulab:scim:2.0:Tenant")
+
+	head, err := CompilePathWith(registry, "urn:imulab:scim:2.0:Tenant:name")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "urn:imulab:scim:2.0:Tenant", head.Token())
+	assert.Equal(s.T(), "name", head.Next().Token())
+	assert.Nil(s.T(), head.Next().Next())
+
+	root, err := CompileFilterWith(registry, `urn:imulab:scim:2.0:Tenant:name eq "foo"`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "urn:imulab:scim:2.0:Tenant", root.Left().Token())
+	assert.Equal(s.T(), "name", root.Left().Next().Token())
+
+	// not registered with the default registry
+	_, err = CompilePath("urn:imulab:scim:2.0:Tenant:name")
+	assert.NotNil(s.T(), err)
+}
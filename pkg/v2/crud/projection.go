@@ -0,0 +1,159 @@
+package crud
+
+import (
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// ResolvedProjection is a Projection expanded to the attributes of a resource type, and sanitized according to the
+// returned property of each attribute, for databases to fetch no more than what is returned. At most one of Included
+// and Excluded is not empty, and both are empty when the complete resource shall be fetched.
+type ResolvedProjection struct {
+	// Attributes to fetch, along with all their sub attributes.
+	Included []*spec.Attribute
+	// Attributes not to fetch, along with all their sub attributes.
+	Excluded []*spec.Attribute
+}
+
+// ResolveProjection resolves the projection against the resource type, which the attribute paths of the projection
+// must be valid for. When projection is nil, the resolved projection is empty, as databases are expected to return
+// the complete resource when no projection is requested.
+//
+// When the projection has attributes, the resolved projection includes them, along with the "id" attribute and the
+// attributes whose returned property is "always", minus the attributes whose returned property is "never". For
+// instance, given the User resource type,
+//
+//	attributes=name
+//
+// resolves to include "schemas", "id" and "name".
+//
+// Otherwise, the resolved projection excludes the excluded attributes, along with the attributes whose returned property
+// is "never" or "request", but never the "id" attribute, nor the attributes whose returned property is "always". For
+// instance, given the User resource type,
+//
+//	excludedAttributes=emails
+//
+// resolves to exclude "password" and "emails".
+//
+// Since databases may not be able to exclude a sub attribute of an included attribute, nor to include a sub attribute
+// of an excluded attribute, such attributes are replaced by their sub attributes instead. For instance, excluding a
+// complex attribute with a sub attribute whose returned property is "always" resolves to exclude its other sub
+// attributes.
+func ResolveProjection(resourceType *spec.ResourceType, projection *Projection) (*ResolvedProjection, error) {
+	resolved := new(ResolvedProjection)
+	if projection == nil || (len(projection.Attributes) == 0 && len(projection.ExcludedAttributes) == 0) {
+		return resolved, nil
+	}
+
+	superAttr := resourceType.SuperAttribute(true)
+	if len(projection.Attributes) > 0 {
+		requested, err := projectedAttributes(resourceType, superAttr, projection.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		_ = superAttr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+			resolved.Included = append(resolved.Included, included(subAttr, requested, false)...)
+			return nil
+		})
+	} else {
+		requested, err := projectedAttributes(resourceType, superAttr, projection.ExcludedAttributes)
+		if err != nil {
+			return nil, err
+		}
+		_ = superAttr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+			resolved.Excluded = append(resolved.Excluded, excluded(subAttr, requested, false)...)
+			return nil
+		})
+	}
+
+	return resolved, nil
+}
+
+// Returns the attributes to include within the attribute, which is requested if any of its ancestors is.
+func included(attr *spec.Attribute, requested map[*spec.Attribute]struct{}, ancestorRequested bool) []*spec.Attribute {
+	if attr.Returned() == spec.ReturnedNever {
+		return nil
+	}
+
+	_, ok := requested[attr]
+	isRequested := ancestorRequested || ok || alwaysReturned(attr)
+	if isRequested && !hasSubAttribute(attr, func(subAttr *spec.Attribute) bool {
+		return subAttr.Returned() == spec.ReturnedNever
+	}) {
+		return []*spec.Attribute{attr}
+	}
+
+	var result []*spec.Attribute
+	_ = attr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+		result = append(result, included(subAttr, requested, isRequested)...)
+		return nil
+	})
+	return result
+}
+
+// Returns the attributes to exclude within the attribute, which is excluded if any of its ancestors is.
+func excluded(attr *spec.Attribute, requested map[*spec.Attribute]struct{}, ancestorExcluded bool) []*spec.Attribute {
+	if alwaysReturned(attr) {
+		return nil
+	}
+
+	_, ok := requested[attr]
+	isExcluded := ancestorExcluded || ok || attr.Returned() == spec.ReturnedNever || attr.Returned() == spec.ReturnedRequest
+	if isExcluded && !hasSubAttribute(attr, alwaysReturned) {
+		return []*spec.Attribute{attr}
+	}
+
+	var result []*spec.Attribute
+	_ = attr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+		result = append(result, excluded(subAttr, requested, isExcluded)...)
+		return nil
+	})
+	return result
+}
+
+// Returns whether the attribute is always returned: the "id" attribute, and the attributes whose returned property
+// is "always".
+func alwaysReturned(attr *spec.Attribute) bool {
+	return attr.Returned() == spec.ReturnedAlways || attr.ID() == "id"
+}
+
+// Returns whether any descendant of the attribute satisfies the criteria.
+func hasSubAttribute(attr *spec.Attribute, criteria func(subAttr *spec.Attribute) bool) bool {
+	found := false
+	_ = attr.ForEachSubAttribute(func(subAttr *spec.Attribute) error {
+		if criteria(subAttr) || hasSubAttribute(subAttr, criteria) {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// Returns the set of attributes at the paths.
+func projectedAttributes(resourceType *spec.ResourceType, superAttr *spec.Attribute, paths []string) (map[*spec.Attribute]struct{}, error) {
+	attributes := make(map[*spec.Attribute]struct{})
+	for _, path := range paths {
+		head, err := expr.CompilePathWith(resourceType.Registry(), path)
+		if err != nil {
+			return nil, err
+		}
+		if head.ContainsFilter() {
+			return nil, fmt.Errorf("%w: projected attribute '%s' cannot contain filter", spec.ErrInvalidPath, path)
+		}
+
+		cursor := head
+		if cursor.Token() == resourceType.Schema().ID() {
+			cursor = cursor.Next()
+		}
+		attr := superAttr
+		for ; attr != nil && cursor != nil; cursor = cursor.Next() {
+			attr = attr.SubAttributeForName(cursor.Token())
+		}
+		if attr == nil || attr == superAttr {
+			return nil, fmt.Errorf("%w: no attribute for projected path '%s'", spec.ErrInvalidPath, path)
+		}
+		attributes[attr] = struct{}{}
+	}
+	return attributes, nil
+}
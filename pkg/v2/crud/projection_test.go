@@ -0,0 +1,214 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+func TestResolveProjection(t *testing.T) {
+	s := new(ResolveProjectionTestSuite)
+	suite.Run(t, s)
+}
+
+type ResolveProjectionTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+}
+
+func (s *ResolveProjectionTestSuite) TestResolveProjection() {
+	tests := []struct {
+		name       string
+		projection *Projection
+		expect     func(t *testing.T, resolved *ResolvedProjection, err error)
+	}{
+		{
+			name:       "no projection",
+			projection: nil,
+			expect: func(t *testing.T, resolved *ResolvedProjection, err error) {
+				assert.Nil(t, err)
+				assert.Empty(t, resolved.Included)
+				assert.Empty(t, resolved.Excluded)
+			},
+		},
+		{
+			name:       "attributes add id and returned always",
+			projection: &Projection{Attributes: []string{"userName"}},
+			expect: func(t *testing.T, resolved *ResolvedProjection, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"id", "userName", "emails.type"}, s.paths(resolved.Included))
+				assert.Empty(t, resolved.Excluded)
+			},
+		},
+		{
+			name:       "attributes strip returned never",
+			projection: &Projection{Attributes: []string{"password", "name", "emails"}},
+			expect: func(t *testing.T, resolved *ResolvedProjection, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"id", "name.givenName", "name.familyName", "emails"}, s.paths(resolved.Included))
+			},
+		},
+		{
+			name:       "attributes with schema urn",
+			projection: &Projection{Attributes: []string{"projection:nickName"}},
+			expect: func(t *testing.T, resolved *ResolvedProjection, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"id", "nickName", "emails.type"}, s.paths(resolved.Included))
+			},
+		},
+		{
+			name:       "excluded attributes add returned never and request",
+			projection: &Projection{ExcludedAttributes: []string{"userName", "id"}},
+			expect: func(t *testing.T, resolved *ResolvedProjection, err error) {
+				assert.Nil(t, err)
+				assert.Empty(t, resolved.Included)
+				assert.Equal(t, []string{"userName", "password", "nickName", "name.secret"}, s.paths(resolved.Excluded))
+			},
+		},
+		{
+			name:       "excluded attributes keep returned always",
+			projection: &Projection{ExcludedAttributes: []string{"emails"}},
+			expect: func(t *testing.T, resolved *ResolvedProjection, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"password", "nickName", "name.secret", "emails.value"}, s.paths(resolved.Excluded))
+			},
+		},
+		{
+			name:       "invalid path",
+			projection: &Projection{Attributes: []string{"foo"}},
+			expect: func(t *testing.T, resolved *ResolvedProjection, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidPath))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			resolved, err := ResolveProjection(s.resourceType, test.projection)
+			test.expect(t, resolved, err)
+		})
+	}
+}
+
+func (s *ResolveProjectionTestSuite) paths(attributes []*spec.Attribute) []string {
+	var paths []string
+	for _, attr := range attributes {
+		paths = append(paths, attr.Path())
+	}
+	return paths
+}
+
+func (s *ResolveProjectionTestSuite) SetupSuite() {
+	core := new(spec.Schema)
+	require.Nil(s.T(), json.Unmarshal([]byte(testCoreSchema), core))
+	spec.Schemas().Register(core)
+
+	schema := new(spec.Schema)
+	require.Nil(s.T(), json.Unmarshal([]byte(testProjectionSchema), schema))
+	spec.Schemas().Register(schema)
+
+	s.resourceType = new(spec.ResourceType)
+	require.Nil(s.T(), json.Unmarshal([]byte(testProjectionResourceType), s.resourceType))
+	Register(s.resourceType)
+}
+
+const (
+	testProjectionSchema = `
+{
+  "id": "projection",
+  "name": "projection",
+  "attributes": [
+    {
+      "id": "projection:userName",
+      "name": "userName",
+      "type": "string",
+      "_index": 100,
+      "_path": "userName"
+    },
+    {
+      "id": "projection:password",
+      "name": "password",
+      "type": "string",
+      "returned": "never",
+      "_index": 101,
+      "_path": "password"
+    },
+    {
+      "id": "projection:nickName",
+      "name": "nickName",
+      "type": "string",
+      "returned": "request",
+      "_index": 102,
+      "_path": "nickName"
+    },
+    {
+      "id": "projection:name",
+      "name": "name",
+      "type": "complex",
+      "_index": 103,
+      "_path": "name",
+      "subAttributes": [
+        {
+          "id": "projection:name.givenName",
+          "name": "givenName",
+          "type": "string",
+          "_index": 0,
+          "_path": "name.givenName"
+        },
+        {
+          "id": "projection:name.familyName",
+          "name": "familyName",
+          "type": "string",
+          "_index": 1,
+          "_path": "name.familyName"
+        },
+        {
+          "id": "projection:name.secret",
+          "name": "secret",
+          "type": "string",
+          "returned": "never",
+          "_index": 2,
+          "_path": "name.secret"
+        }
+      ]
+    },
+    {
+      "id": "projection:emails",
+      "name": "emails",
+      "type": "complex",
+      "multiValued": true,
+      "_index": 104,
+      "_path": "emails",
+      "subAttributes": [
+        {
+          "id": "projection:emails.value",
+          "name": "value",
+          "type": "string",
+          "_index": 0,
+          "_path": "emails.value"
+        },
+        {
+          "id": "projection:emails.type",
+          "name": "type",
+          "type": "string",
+          "returned": "always",
+          "_index": 1,
+          "_path": "emails.type"
+        }
+      ]
+    }
+  ]
+}
+`
+	testProjectionResourceType = `
+{
+  "id": "Projection",
+  "name": "Projection",
+  "schema": "projection"
+}
+`
+)
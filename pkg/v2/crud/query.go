@@ -42,7 +42,7 @@ func (s Sort) Sort(resources []*prop.Resource) error {
 		return nil
 	}
 
-	head, err := expr.CompilePath(s.By)
+	head, err := expr.CompilePathWith(resources[0].ResourceType().Registry(), s.By)
 	if err != nil {
 		return err
 	}
@@ -1,15 +1,14 @@
 package crud
 
-import (
-	"github.com/imulab/go-scim/pkg/v2/crud/expr"
-	"github.com/imulab/go-scim/pkg/v2/spec"
-)
+import "github.com/imulab/go-scim/pkg/v2/spec"
 
-// Register calls expr.RegisterURN for the main schema ids and all schema extension ids in the resource type.
+// Register registers the main schema ids and all schema extension ids in the resource type as URN prefixes with the
+// schema registry of the resource type.
 func Register(resourceType *spec.ResourceType) {
-	expr.RegisterURN(resourceType.Schema().ID())
+	registry := resourceType.Registry()
+	registry.RegisterURN(resourceType.Schema().ID())
 	_ = resourceType.ForEachExtension(func(extension *spec.Schema, required bool) error {
-		expr.RegisterURN(extension.ID())
+		registry.RegisterURN(extension.ID())
 		return nil
 	})
 }
@@ -62,40 +62,65 @@ func (t traverser) traverseNext(query *expr.Expression) error {
 func (t traverser) traverseSelectedElements(query *expr.Expression) error {
 	selector := t.elementStrategy(t.nav.Current())
 
-	return t.nav.Current().ForEachChild(func(index int, child prop.Property) error {
-		if !selector(index, child) { // skip elements not satisfied by strategy
-			return nil
+	var selected []prop.Property
+	_ = t.nav.Current().ForEachChild(func(index int, child prop.Property) error {
+		if selector(index, child) { // skip elements not satisfied by strategy
+			selected = append(selected, child)
 		}
+		return nil
+	})
 
-		t.nav.At(index)
-		if err := t.nav.Error(); err != nil {
-			return err
-		}
-		defer t.nav.Retract()
-
+	return t.traverseElements(selected, func() error {
 		return t.traverse(query)
 	})
 }
 
 func (t traverser) traverseQualifiedElements(filter *expr.Expression) error {
-	return t.nav.ForEachChild(func(index int, child prop.Property) error {
-		t.nav.At(index)
-		if err := t.nav.Error(); err != nil {
-			return err
-		}
-		defer t.nav.Retract()
-
-		r, err := evaluator{base: t.nav.Current(), filter: filter}.evaluate()
+	var qualified []prop.Property
+	if err := t.nav.ForEachChild(func(_ int, child prop.Property) error {
+		r, err := evaluator{base: child, filter: filter}.evaluate()
 		if err != nil {
 			return err
-		} else if !r {
-			return nil
+		} else if r {
+			qualified = append(qualified, child)
 		}
+		return nil
+	}); err != nil {
+		return err
+	}
 
+	return t.traverseElements(qualified, func() error {
 		return t.traverse(filter.Next())
 	})
 }
 
+// traverseElements focuses on each of the elements of the current multiValued property and invokes the callback. The
+// elements are collected before the callback modifies the property: deleting an element, or the last sub property of
+// an element which is then compacted, shifts the index of the following elements. Elements no longer in the property
+// are skipped.
+func (t traverser) traverseElements(elements []prop.Property, callback func() error) error {
+	for _, element := range elements {
+		isElement := func(child prop.Property) bool {
+			return child == element
+		}
+		if t.nav.Current().FindChild(isElement) == nil {
+			continue
+		}
+
+		t.nav.Where(isElement)
+		if err := t.nav.Error(); err != nil {
+			return err
+		}
+		if err := func() error {
+			defer t.nav.Retract()
+			return callback()
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type elementStrategy func(multiValuedComplex prop.Property) func(index int, child prop.Property) bool
 
 var (
@@ -13,6 +13,9 @@ import (
 // it does allow for concurrent access through the use of RWMutex, it does not support high throughput usage.
 // Hence, it is only intended for testing and showcasing purposes. This implementation also ignores all the field projection
 // parameters that it always returned the full resource regardless of the request to include or exclude attributes.
+//
+// Unique constraints (see UniqueAttributes) are checked against all stored resources while holding the write lock,
+// hence concurrent requests cannot both save the same unique value.
 func Memory() DB {
 	db := memoryDB{
 		RWMutex: sync.RWMutex{},
@@ -32,18 +35,25 @@ func (m *memoryDB) Insert(_ context.Context, resource *prop.Resource) error {
 		return fmt.Errorf("%w: empty id", spec.ErrInternal)
 	}
 
+	m.Lock()
+	defer m.Unlock()
+
 	if _, ok := m.db[id]; ok {
 		return fmt.Errorf("%w: id exists", spec.ErrInvalidValue)
 	}
+	if err := m.checkUnique(resource); err != nil {
+		return err
+	}
 
-	m.Lock()
-	defer m.Unlock()
 	m.db[id] = resource
 
 	return nil
 }
 
 func (m *memoryDB) Get(_ context.Context, id string, _ *crud.Projection) (*prop.Resource, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	r, ok := m.db[id]
 	if !ok {
 		return nil, fmt.Errorf("%w: resource not found by id", spec.ErrNotFound)
@@ -52,6 +62,9 @@ func (m *memoryDB) Get(_ context.Context, id string, _ *crud.Projection) (*prop.
 }
 
 func (m *memoryDB) Count(_ context.Context, filter string) (int, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	if len(filter) == 0 {
 		return len(m.db), nil
 	}
@@ -67,6 +80,9 @@ func (m *memoryDB) Count(_ context.Context, filter string) (int, error) {
 }
 
 func (m *memoryDB) Replace(_ context.Context, ref *prop.Resource, replacement *prop.Resource) error {
+	m.Lock()
+	defer m.Unlock()
+
 	id := ref.IdOrEmpty()
 	_, ok := m.db[id]
 	if !ok {
@@ -77,17 +93,26 @@ func (m *memoryDB) Replace(_ context.Context, ref *prop.Resource, replacement *p
 	if len(version) > 0 && m.db[id].MetaVersionOrEmpty() != version {
 		return spec.ErrConflict
 	}
+	if err := m.checkUnique(replacement); err != nil {
+		return err
+	}
 
 	m.db[id] = replacement
 	return nil
 }
 
 func (m *memoryDB) Delete(_ context.Context, resource *prop.Resource) error {
+	m.Lock()
+	defer m.Unlock()
+
 	delete(m.db, resource.IdOrEmpty())
 	return nil
 }
 
 func (m *memoryDB) Query(_ context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, _ *crud.Projection) ([]*prop.Resource, error) {
+	m.RLock()
+	defer m.RUnlock()
+
 	var candidates = make([]*prop.Resource, 0)
 	for _, r := range m.db {
 		if ok, _ := crud.Evaluate(r, filter); ok {
@@ -118,3 +143,27 @@ func (m *memoryDB) Query(_ context.Context, filter string, sort *crud.Sort, pagi
 
 	return candidates, nil
 }
+
+// checkUnique returns UniquenessError if any unique property of the resource has the same value as that of another
+// stored resource. Caller must hold the write lock.
+func (m *memoryDB) checkUnique(resource *prop.Resource) error {
+	unique := UniqueAttributes(resource.ResourceType())
+	keys := uniqueKeys(resource, unique)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	id := resource.IdOrEmpty()
+	for otherId, other := range m.db {
+		if otherId == id {
+			continue
+		}
+		otherKeys := uniqueKeys(other, unique)
+		for _, attr := range unique {
+			if key, ok := keys[attr.ID()]; ok && key == otherKeys[attr.ID()] {
+				return UniquenessError(attr)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"sync"
+	"testing"
+)
+
+func TestMemoryDB(t *testing.T) {
+	s := new(MemoryDBTestSuite)
+	suite.Run(t, s)
+}
+
+type MemoryDBTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+}
+
+func (s *MemoryDBTestSuite) TestUniqueAttributes() {
+	var paths []string
+	for _, attr := range UniqueAttributes(s.resourceType) {
+		paths = append(paths, attr.Path())
+	}
+	assert.Equal(s.T(), []string{"id", "userName", "badge", "urn:imulab:scim:2.0:Badge:serial"}, paths)
+}
+
+func (s *MemoryDBTestSuite) TestInsert() {
+	tests := []struct {
+		name   string
+		exists []*prop.Resource
+		insert *prop.Resource
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name:   "unique values are inserted",
+			exists: []*prop.Resource{s.newAccount("1", "imulab", "B-1", "S-1", "a@example.com")},
+			insert: s.newAccount("2", "david", "B-2", "S-2", "a@example.com"),
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "unassigned unique values do not conflict",
+			exists: []*prop.Resource{s.newAccount("1", "imulab", "", "", "")},
+			insert: s.newAccount("2", "david", "", "", ""),
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "duplicate value of caseExact=false attribute is rejected regardless of case",
+			exists: []*prop.Resource{s.newAccount("1", "imulab", "B-1", "", "")},
+			insert: s.newAccount("2", "IMULAB", "B-2", "", ""),
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, spec.ErrUniqueness))
+				assert.Contains(t, err.Error(), "'userName'")
+			},
+		},
+		{
+			name:   "values of caseExact=true attribute differing in case are unique",
+			exists: []*prop.Resource{s.newAccount("1", "imulab", "B-1", "", "")},
+			insert: s.newAccount("2", "david", "b-1", "", ""),
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "duplicate value of caseExact=true attribute is rejected",
+			exists: []*prop.Resource{s.newAccount("1", "imulab", "B-1", "", "")},
+			insert: s.newAccount("2", "david", "B-1", "", ""),
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, spec.ErrUniqueness))
+				assert.Contains(t, err.Error(), "'badge'")
+			},
+		},
+		{
+			name:   "duplicate value of extension attribute is rejected",
+			exists: []*prop.Resource{s.newAccount("1", "imulab", "", "S-1", "")},
+			insert: s.newAccount("2", "david", "", "S-1", ""),
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, spec.ErrUniqueness))
+				assert.Contains(t, err.Error(), "'urn:imulab:scim:2.0:Badge:serial'")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			database := Memory()
+			for _, r := range test.exists {
+				require.Nil(t, database.Insert(context.Background(), r))
+			}
+			test.expect(t, database.Insert(context.Background(), test.insert))
+		})
+	}
+}
+
+func (s *MemoryDBTestSuite) TestReplace() {
+	var (
+		ctx      = context.Background()
+		database = Memory()
+	)
+	require.Nil(s.T(), database.Insert(ctx, s.newAccount("1", "imulab", "B-1", "", "")))
+	require.Nil(s.T(), database.Insert(ctx, s.newAccount("2", "david", "B-2", "", "")))
+
+	ref, err := database.Get(ctx, "2", nil)
+	require.Nil(s.T(), err)
+
+	// keeping its own unique value does not conflict with itself
+	assert.Nil(s.T(), database.Replace(ctx, ref, s.newAccount("2", "David", "B-2", "", "")))
+
+	err = database.Replace(ctx, ref, s.newAccount("2", "Imulab", "B-2", "", ""))
+	assert.True(s.T(), errors.Is(err, spec.ErrUniqueness))
+
+	stored, err := database.Get(ctx, "2", nil)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "David", stored.Navigator().Dot("userName").Current().Raw())
+}
+
+func (s *MemoryDBTestSuite) TestConcurrentInsert() {
+	var (
+		ctx      = context.Background()
+		database = Memory()
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		inserted = 0
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := database.Insert(ctx, s.newAccount(fmt.Sprintf("%d", i), "imulab", "", "", ""))
+			if err == nil {
+				lock.Lock()
+				inserted++
+				lock.Unlock()
+				return
+			}
+			assert.True(s.T(), errors.Is(err, spec.ErrUniqueness))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(s.T(), 1, inserted)
+	n, err := database.Count(ctx, "userName eq \"imulab\"")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, n)
+}
+
+func (s *MemoryDBTestSuite) newAccount(id, userName, badge, serial, email string) *prop.Resource {
+	r := prop.NewResource(s.resourceType)
+	nav := r.Navigator()
+	for path, value := range map[string]interface{}{
+		"id":       id,
+		"userName": userName,
+		"badge":    badge,
+		"emails":   email,
+	} {
+		if value == "" {
+			continue
+		}
+		if path == "emails" {
+			value = []interface{}{map[string]interface{}{"value": value}}
+		}
+		require.Nil(s.T(), nav.Dot(path).Replace(value).Error())
+		nav.Retract()
+	}
+	if serial != "" {
+		require.Nil(s.T(), nav.Dot("urn:imulab:scim:2.0:Badge").Dot("serial").Replace(serial).Error())
+		nav.Retract()
+		nav.Retract()
+	}
+	return r
+}
+
+func (s *MemoryDBTestSuite) SetupSuite() {
+	registry := spec.NewSchemaRegistry()
+	for _, raw := range []string{
+		s.mustRead("../../../public/schemas/core_schema.json"),
+		accountSchema,
+		badgeSchema,
+	} {
+		schema := new(spec.Schema)
+		require.Nil(s.T(), schema.UnmarshalJSON([]byte(raw)))
+		registry.Register(schema)
+	}
+
+	resourceType, err := registry.ParseResourceType([]byte(`{
+		"id": "Account",
+		"name": "Account",
+		"endpoint": "/Accounts",
+		"schema": "urn:imulab:scim:2.0:Account",
+		"schemaExtensions": [
+			{"schema": "urn:imulab:scim:2.0:Badge"}
+		]
+	}`))
+	require.Nil(s.T(), err)
+	s.resourceType = resourceType
+}
+
+func (s *MemoryDBTestSuite) mustRead(path string) string {
+	raw, err := ioutil.ReadFile(path)
+	require.Nil(s.T(), err)
+	return string(raw)
+}
+
+const accountSchema = `{
+  "id": "urn:imulab:scim:2.0:Account",
+  "name": "Account",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:2.0:Account:userName",
+      "name": "userName",
+      "type": "string",
+      "uniqueness": "server",
+      "_index": 100,
+      "_path": "userName"
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Account:badge",
+      "name": "badge",
+      "type": "string",
+      "caseExact": true,
+      "uniqueness": "global",
+      "_index": 101,
+      "_path": "badge"
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Account:emails",
+      "name": "emails",
+      "type": "complex",
+      "multiValued": true,
+      "_index": 102,
+      "_path": "emails",
+      "subAttributes": [
+        {
+          "id": "urn:imulab:scim:2.0:Account:emails.value",
+          "name": "value",
+          "type": "string",
+          "uniqueness": "server",
+          "_index": 0,
+          "_path": "emails.value"
+        }
+      ]
+    }
+  ]
+}`
+
+const badgeSchema = `{
+  "id": "urn:imulab:scim:2.0:Badge",
+  "name": "Badge",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:2.0:Badge:serial",
+      "name": "serial",
+      "type": "string",
+      "uniqueness": "server",
+      "_index": 0,
+      "_path": "urn:imulab:scim:2.0:Badge:serial"
+    }
+  ]
+}`
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"strings"
+)
+
+// UniqueAttributes returns the attributes of the resource type whose values must be unique among all resources in the
+// database: the singular simple attributes whose uniqueness is server or global, and which are not nested inside a
+// multiValued attribute. DB implementations shall enforce these constraints on Insert and Replace, so that concurrent
+// requests cannot bypass them, and report violations with UniquenessError.
+//
+// Global uniqueness is enforced only within the database, as the database has no knowledge of other resource types.
+func UniqueAttributes(resourceType *spec.ResourceType) []*spec.Attribute {
+	unique := make([]*spec.Attribute, 0)
+	var collect func(attr *spec.Attribute)
+	collect = func(attr *spec.Attribute) {
+		if attr.MultiValued() {
+			return
+		}
+		if attr.Type() == spec.TypeComplex {
+			_ = attr.ForEachSubAttribute(func(subAttribute *spec.Attribute) error {
+				collect(subAttribute)
+				return nil
+			})
+			return
+		}
+		if attr.Uniqueness() == spec.UniquenessServer || attr.Uniqueness() == spec.UniquenessGlobal {
+			unique = append(unique, attr)
+		}
+	}
+	collect(resourceType.SuperAttribute(true))
+	return unique
+}
+
+// UniquenessError returns a spec.ErrUniqueness error reporting the attribute whose value violates the uniqueness
+// constraint.
+func UniquenessError(attr *spec.Attribute) error {
+	return fmt.Errorf("%w: value of '%s' is not unique", spec.ErrUniqueness, attr.Path())
+}
+
+// uniqueKeys returns the keys of the assigned unique properties in the resource, indexed by attribute id. Values of
+// string attributes that are not caseExact are lower cased, so that they are compared case insensitively.
+func uniqueKeys(resource *prop.Resource, unique []*spec.Attribute) map[string]interface{} {
+	keys := make(map[string]interface{})
+	if len(unique) == 0 {
+		return keys
+	}
+
+	ids := make(map[string]struct{}, len(unique))
+	for _, attr := range unique {
+		ids[attr.ID()] = struct{}{}
+	}
+
+	_ = resource.Visit(&uniqueKeyVisitor{ids: ids, keys: keys})
+	return keys
+}
+
+type uniqueKeyVisitor struct {
+	ids  map[string]struct{}
+	keys map[string]interface{}
+}
+
+func (v *uniqueKeyVisitor) ShouldVisit(property prop.Property) bool {
+	return !property.Attribute().MultiValued()
+}
+
+func (v *uniqueKeyVisitor) Visit(property prop.Property) error {
+	attr := property.Attribute()
+	if _, ok := v.ids[attr.ID()]; !ok || property.IsUnassigned() {
+		return nil
+	}
+	if s, ok := property.Raw().(string); ok && attr.Type() == spec.TypeString && !attr.CaseExact() {
+		v.keys[attr.ID()] = strings.ToLower(s)
+	} else {
+		v.keys[attr.ID()] = property.Raw()
+	}
+	return nil
+}
+
+func (v *uniqueKeyVisitor) BeginChildren(_ prop.Property) {}
+
+func (v *uniqueKeyVisitor) EndChildren(_ prop.Property) {}
@@ -0,0 +1,183 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Encrypted values have the format
+//
+//	enc1:<blind index>:<key id>:<base64 encoded nonce and cipher text>
+//
+// where the blind index is empty if the attribute does not enable it. The blind index comes first, so that values
+// with the same blind index share a prefix regardless of the key they were encrypted with.
+const (
+	prefix    = "enc1:"
+	separator = ":"
+)
+
+// NewCipher returns a Cipher that encrypts and decrypts values with the keys of the provider.
+func NewCipher(keys KeyProvider) *Cipher {
+	return &Cipher{keys: keys}
+}
+
+// Cipher encrypts and decrypts the values of attributes annotated with @Encrypted using AES-GCM. The attribute path is
+// authenticated along with the value, so that encrypted values cannot be moved between attributes.
+type Cipher struct {
+	keys KeyProvider
+}
+
+// Encrypt encrypts the plaintext value of the attribute with the current key. If the attribute enables blind index,
+// the blind index of the value is included in the encrypted value.
+func (c *Cipher) Encrypt(ctx context.Context, attr *spec.Attribute, value string) (string, error) {
+	key, err := c.keys.CurrentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var index string
+	if BlindIndexed(attr) {
+		if index, err = c.BlindIndex(ctx, attr, value); err != nil {
+			return "", err
+		}
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("%w: failed to generate nonce", spec.ErrInternal)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), additionalData(index, key.ID, attr))
+
+	return prefix + index + separator + key.ID + separator + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of the value of the attribute encrypted by Encrypt.
+func (c *Cipher) Decrypt(ctx context.Context, attr *spec.Attribute, value string) (string, error) {
+	index, keyId, payload, ok := parse(value)
+	if !ok {
+		return "", fmt.Errorf("%w: value of '%s' is not encrypted", spec.ErrInternal, attr.Path())
+	}
+
+	key, err := c.keys.Key(ctx, keyId)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%w: encrypted value of '%s' is malformed", spec.ErrInternal, attr.Path())
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData(index, keyId, attr))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to decrypt value of '%s'", spec.ErrInternal, attr.Path())
+	}
+
+	return string(plaintext), nil
+}
+
+// BlindIndex returns the deterministic blind index of the plaintext value of the attribute. Values that are equal
+// according to the caseExact setting of the attribute have the same blind index.
+func (c *Cipher) BlindIndex(ctx context.Context, attr *spec.Attribute, value string) (string, error) {
+	secret, err := c.keys.IndexKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if !attr.CaseExact() {
+		value = strings.ToLower(value)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(attr.Path()))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// IsCurrent returns true if the encrypted value was encrypted with the current key.
+func (c *Cipher) IsCurrent(ctx context.Context, value string) (bool, error) {
+	_, keyId, _, ok := parse(value)
+	if !ok {
+		return false, nil
+	}
+	key, err := c.keys.CurrentKey(ctx)
+	if err != nil {
+		return false, err
+	}
+	return key.ID == keyId, nil
+}
+
+// IsEncrypted returns true if the value has the format of values encrypted by a Cipher.
+func IsEncrypted(value string) bool {
+	_, _, _, ok := parse(value)
+	return ok
+}
+
+// BlindIndexed returns true if the attribute is annotated with @Encrypted and enables blind index.
+func BlindIndexed(attr *spec.Attribute) bool {
+	params, ok := attr.Annotation(annotation.Encrypted)
+	if !ok {
+		return false
+	}
+	blindIndex, _ := params["blindIndex"].(bool)
+	return blindIndex && attr.Type() != spec.TypeBinary
+}
+
+// Encrypts returns true if any attribute of the resource type is annotated with @Encrypted.
+func Encrypts(resourceType *spec.ResourceType) bool {
+	found := false
+	resourceType.SuperAttribute(true).DFS(func(attr *spec.Attribute) {
+		if _, ok := attr.Annotation(annotation.Encrypted); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+func parse(value string) (index string, keyId string, payload string, ok bool) {
+	if !strings.HasPrefix(value, prefix) {
+		return
+	}
+	parts := strings.SplitN(value[len(prefix):], separator, 3)
+	if len(parts) != 3 || len(parts[1]) == 0 || len(parts[2]) == 0 {
+		return
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+func newAEAD(key *Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid encryption key '%s'", spec.ErrInternal, key.ID)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid encryption key '%s'", spec.ErrInternal, key.ID)
+	}
+	return aead, nil
+}
+
+func additionalData(index string, keyId string, attr *spec.Attribute) []byte {
+	return []byte(prefix + index + separator + keyId + separator + attr.Path())
+}
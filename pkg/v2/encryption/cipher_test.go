@@ -0,0 +1,289 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCipher(t *testing.T) {
+	s := new(CipherTestSuite)
+	suite.Run(t, s)
+}
+
+type CipherTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+}
+
+func (s *CipherTestSuite) SetupSuite() {
+	s.resourceType = mustPatientResourceType(s.T())
+}
+
+func (s *CipherTestSuite) TestEncrypt() {
+	var (
+		ctx            = context.Background()
+		cipher         = NewCipher(mustKeyFile(s.T(), "k1", "k1"))
+		employeeNumber = s.attribute("employeeNumber")
+		notes          = s.attribute("notes")
+	)
+
+	encrypted, err := cipher.Encrypt(ctx, employeeNumber, "E-1001")
+	require.Nil(s.T(), err)
+	assert.True(s.T(), IsEncrypted(encrypted))
+	assert.NotContains(s.T(), encrypted, "E-1001")
+
+	// same plaintext encrypts differently, but shares the blind index
+	again, err := cipher.Encrypt(ctx, employeeNumber, "e-1001")
+	require.Nil(s.T(), err)
+	assert.NotEqual(s.T(), encrypted, again)
+	index, err := cipher.BlindIndex(ctx, employeeNumber, "E-1001")
+	require.Nil(s.T(), err)
+	assert.True(s.T(), strings.HasPrefix(encrypted, prefix+index+separator))
+	assert.True(s.T(), strings.HasPrefix(again, prefix+index+separator))
+
+	decrypted, err := cipher.Decrypt(ctx, employeeNumber, encrypted)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "E-1001", decrypted)
+
+	// no blind index without the parameter
+	unindexed, err := cipher.Encrypt(ctx, notes, "allergic to penicillin")
+	require.Nil(s.T(), err)
+	assert.True(s.T(), strings.HasPrefix(unindexed, prefix+separator+"k1"+separator))
+
+	// encrypted values are bound to their attribute
+	_, err = cipher.Decrypt(ctx, notes, encrypted)
+	assert.True(s.T(), s.isInternal(err))
+
+	// encrypted values are authenticated
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if tampered == encrypted {
+		tampered = encrypted[:len(encrypted)-2] + "BB"
+	}
+	_, err = cipher.Decrypt(ctx, employeeNumber, tampered)
+	assert.True(s.T(), s.isInternal(err))
+}
+
+func (s *CipherTestSuite) TestRotation() {
+	var (
+		ctx            = context.Background()
+		employeeNumber = s.attribute("employeeNumber")
+	)
+
+	oldCipher := NewCipher(mustKeyFile(s.T(), "k1", "k1"))
+	newCipher := NewCipher(mustKeyFile(s.T(), "k2", "k1", "k2"))
+
+	encrypted, err := oldCipher.Encrypt(ctx, employeeNumber, "E-1001")
+	require.Nil(s.T(), err)
+
+	current, err := newCipher.IsCurrent(ctx, encrypted)
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), current)
+
+	decrypted, err := newCipher.Decrypt(ctx, employeeNumber, encrypted)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "E-1001", decrypted)
+
+	reencrypted, err := newCipher.Encrypt(ctx, employeeNumber, decrypted)
+	require.Nil(s.T(), err)
+	current, err = newCipher.IsCurrent(ctx, reencrypted)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), current)
+
+	// the blind index survives rotation
+	assert.Equal(s.T(), strings.SplitN(encrypted, separator, 3)[1], strings.SplitN(reencrypted, separator, 3)[1])
+
+	// keys removed from the key file can no longer decrypt
+	_, err = NewCipher(mustKeyFile(s.T(), "k2", "k2")).Decrypt(ctx, employeeNumber, encrypted)
+	assert.True(s.T(), s.isInternal(err))
+}
+
+func (s *CipherTestSuite) TestLocalKeyFile() {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	for _, test := range []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{
+			name:    "valid",
+			content: `{"current":"k1","keys":{"k1":"` + key + `"},"indexKey":"` + key + `"}`,
+			valid:   true,
+		},
+		{
+			name:    "unknown current key",
+			content: `{"current":"k2","keys":{"k1":"` + key + `"},"indexKey":"` + key + `"}`,
+		},
+		{
+			name:    "short key",
+			content: `{"current":"k1","keys":{"k1":"AAAA"},"indexKey":"` + key + `"}`,
+		},
+		{
+			name:    "invalid key id",
+			content: `{"current":"k:1","keys":{"k:1":"` + key + `"},"indexKey":"` + key + `"}`,
+		},
+		{
+			name:    "missing index key",
+			content: `{"current":"k1","keys":{"k1":"` + key + `"}}`,
+		},
+	} {
+		s.T().Run(test.name, func(t *testing.T) {
+			path := mustTempFile(t, test.content)
+			defer os.Remove(path)
+
+			_, err := LocalKeyFile(path)
+			if test.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func (s *CipherTestSuite) attribute(name string) *spec.Attribute {
+	return s.resourceType.SuperAttribute(true).SubAttributeForName(name)
+}
+
+func (s *CipherTestSuite) isInternal(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), spec.ErrInternal.Error())
+}
+
+// mustKeyFile writes a key file with the key ids, where the secret of each key is derived from its id, and returns
+// the provider of the keys with the current key.
+func mustKeyFile(t *testing.T, current string, ids ...string) KeyProvider {
+	secret := func(seed string) string {
+		b := make([]byte, 32)
+		copy(b, seed)
+		return base64.StdEncoding.EncodeToString(b)
+	}
+
+	var keys []string
+	for _, id := range ids {
+		keys = append(keys, `"`+id+`":"`+secret(id)+`"`)
+	}
+	content := `{"current":"` + current + `","keys":{` + strings.Join(keys, ",") + `},"indexKey":"` + secret("index") + `"}`
+
+	path := mustTempFile(t, content)
+	defer os.Remove(path)
+
+	provider, err := LocalKeyFile(path)
+	require.Nil(t, err)
+	return provider
+}
+
+func mustTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "keys*.json")
+	require.Nil(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.Nil(t, err)
+	return f.Name()
+}
+
+// mustPatientResourceType returns a resource type with encrypted attributes, in a registry of its own.
+func mustPatientResourceType(t *testing.T) *spec.ResourceType {
+	registry := spec.NewSchemaRegistry()
+	for _, raw := range []string{
+		mustRead(t, "../../../public/schemas/core_schema.json"),
+		patientSchema,
+	} {
+		schema := new(spec.Schema)
+		require.Nil(t, schema.UnmarshalJSON([]byte(raw)))
+		registry.Register(schema)
+	}
+
+	resourceType, err := registry.ParseResourceType([]byte(`{
+		"id": "Patient",
+		"name": "Patient",
+		"endpoint": "/Patients",
+		"schema": "urn:imulab:scim:2.0:Patient"
+	}`))
+	require.Nil(t, err)
+	return resourceType
+}
+
+func mustRead(t *testing.T, path string) string {
+	raw, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	return string(raw)
+}
+
+const patientSchema = `{
+  "id": "urn:imulab:scim:2.0:Patient",
+  "name": "Patient",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:2.0:Patient:userName",
+      "name": "userName",
+      "type": "string",
+      "_index": 100,
+      "_path": "userName"
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Patient:employeeNumber",
+      "name": "employeeNumber",
+      "type": "string",
+      "_index": 101,
+      "_path": "employeeNumber",
+      "_annotations": {
+        "@Encrypted": {"blindIndex": true}
+      }
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Patient:notes",
+      "name": "notes",
+      "type": "string",
+      "_index": 102,
+      "_path": "notes",
+      "_annotations": {
+        "@Encrypted": {}
+      }
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Patient:certificate",
+      "name": "certificate",
+      "type": "binary",
+      "_index": 103,
+      "_path": "certificate",
+      "_annotations": {
+        "@Encrypted": {}
+      }
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Patient:phoneNumbers",
+      "name": "phoneNumbers",
+      "type": "complex",
+      "multiValued": true,
+      "_index": 104,
+      "_path": "phoneNumbers",
+      "subAttributes": [
+        {
+          "id": "urn:imulab:scim:2.0:Patient:phoneNumbers.value",
+          "name": "value",
+          "type": "string",
+          "_index": 0,
+          "_path": "phoneNumbers.value",
+          "_annotations": {
+            "@Encrypted": {"blindIndex": true}
+          }
+        },
+        {
+          "id": "urn:imulab:scim:2.0:Patient:phoneNumbers.type",
+          "name": "type",
+          "type": "string",
+          "_index": 1,
+          "_path": "phoneNumbers.type"
+        }
+      ]
+    }
+  ]
+}`
@@ -0,0 +1,104 @@
+package encryption
+
+import (
+	"context"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// DB returns a db.DB that stores the resources of the resource type in the database with the properties annotated with
+// @Encrypted encrypted by the cipher. Resources are encrypted before Insert and Replace, and decrypted after Get and
+// Query; the resources of the callers are never modified, so they remain in plaintext. Filters of Count and Query are
+// rewritten by Cipher.RewriteFilter, and sorting by encrypted attributes is rejected.
+func DB(database db.DB, resourceType *spec.ResourceType, cipher *Cipher) db.DB {
+	return &encryptedDB{
+		database:     database,
+		resourceType: resourceType,
+		cipher:       cipher,
+		encrypt:      filter.ByPropertyToByResource(EncryptFilter(cipher)),
+		decrypt:      filter.ByPropertyToByResource(DecryptFilter(cipher)),
+	}
+}
+
+type encryptedDB struct {
+	database     db.DB
+	resourceType *spec.ResourceType
+	cipher       *Cipher
+	encrypt      filter.ByResource
+	decrypt      filter.ByResource
+}
+
+func (d *encryptedDB) Insert(ctx context.Context, resource *prop.Resource) error {
+	encrypted := resource.Clone()
+	if err := d.encrypt.Filter(ctx, encrypted); err != nil {
+		return err
+	}
+	return d.database.Insert(ctx, encrypted)
+}
+
+func (d *encryptedDB) Count(ctx context.Context, filter string) (int, error) {
+	filter, err := d.cipher.RewriteFilter(ctx, d.resourceType, filter)
+	if err != nil {
+		return 0, err
+	}
+	return d.database.Count(ctx, filter)
+}
+
+func (d *encryptedDB) Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error) {
+	resource, err := d.database.Get(ctx, id, projection)
+	if err != nil {
+		return nil, err
+	}
+	return d.decrypted(ctx, resource)
+}
+
+func (d *encryptedDB) Replace(ctx context.Context, ref *prop.Resource, replacement *prop.Resource) error {
+	encrypted := replacement.Clone()
+	if err := d.encrypt.Filter(ctx, encrypted); err != nil {
+		return err
+	}
+	return d.database.Replace(ctx, ref, encrypted)
+}
+
+func (d *encryptedDB) Delete(ctx context.Context, resource *prop.Resource) error {
+	return d.database.Delete(ctx, resource)
+}
+
+func (d *encryptedDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error) {
+	if err := CheckSort(d.resourceType, sort); err != nil {
+		return nil, err
+	}
+
+	filter, err := d.cipher.RewriteFilter(ctx, d.resourceType, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := d.database.Query(ctx, filter, sort, pagination, projection)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]*prop.Resource, 0, len(resources))
+	for _, resource := range resources {
+		r, err := d.decrypted(ctx, resource)
+		if err != nil {
+			return nil, err
+		}
+		decrypted = append(decrypted, r)
+	}
+	return decrypted, nil
+}
+
+// decrypted returns a decrypted copy of the stored resource, as the database may hand out the instance it stores.
+func (d *encryptedDB) decrypted(ctx context.Context, resource *prop.Resource) (*prop.Resource, error) {
+	decrypted := resource.Clone()
+	if err := d.decrypt.Filter(ctx, decrypted); err != nil {
+		return nil, err
+	}
+	return decrypted, nil
+}
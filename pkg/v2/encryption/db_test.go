@@ -0,0 +1,266 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestEncryptedDB(t *testing.T) {
+	s := new(EncryptedDBTestSuite)
+	suite.Run(t, s)
+}
+
+type EncryptedDBTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+}
+
+func (s *EncryptedDBTestSuite) SetupSuite() {
+	s.resourceType = mustPatientResourceType(s.T())
+}
+
+func (s *EncryptedDBTestSuite) TestInsertAndGet() {
+	var (
+		ctx       = context.Background()
+		raw       = db.Memory()
+		database  = DB(raw, s.resourceType, NewCipher(mustKeyFile(s.T(), "k1", "k1")))
+		resource  = s.newPatient("1", "E-1001", "555-0100")
+		plaintext = resource.Clone()
+	)
+
+	require.Nil(s.T(), database.Insert(ctx, resource))
+	assert.Equal(s.T(), plaintext.Hash(), resource.Hash(), "caller resource must not be modified")
+
+	stored, err := raw.Get(ctx, "1", nil)
+	require.Nil(s.T(), err)
+	for _, path := range []string{"employeeNumber", "notes", "phoneNumbers.value"} {
+		value := s.valueAt(stored, path)
+		assert.True(s.T(), IsEncrypted(value), path)
+	}
+	certificate, err := base64.StdEncoding.DecodeString(s.valueAt(stored, "certificate"))
+	require.Nil(s.T(), err)
+	assert.True(s.T(), IsEncrypted(string(certificate)))
+	assert.Equal(s.T(), "imulab", s.valueAt(stored, "userName"))
+
+	for _, read := range []func() *prop.Resource{
+		func() *prop.Resource {
+			r, err := database.Get(ctx, "1", nil)
+			require.Nil(s.T(), err)
+			return r
+		},
+		func() *prop.Resource {
+			r, err := database.Query(ctx, "id pr", nil, nil, nil)
+			require.Nil(s.T(), err)
+			require.Len(s.T(), r, 1)
+			return r[0]
+		},
+	} {
+		r := read()
+		assert.Equal(s.T(), plaintext.Hash(), r.Hash())
+		assert.Equal(s.T(), "E-1001", s.valueAt(r, "employeeNumber"))
+		assert.Equal(s.T(), base64.StdEncoding.EncodeToString([]byte("certificate")), s.valueAt(r, "certificate"))
+	}
+
+	// stored resource must not be decrypted in place
+	stored, _ = raw.Get(ctx, "1", nil)
+	assert.True(s.T(), IsEncrypted(s.valueAt(stored, "employeeNumber")))
+}
+
+func (s *EncryptedDBTestSuite) TestInsertEncryptedLookingValue() {
+	var (
+		ctx      = context.Background()
+		raw      = db.Memory()
+		cipher   = NewCipher(mustKeyFile(s.T(), "k1", "k1"))
+		database = DB(raw, s.resourceType, cipher)
+	)
+
+	// a value that looks encrypted with the current key is still plaintext from the caller
+	forged, err := cipher.Encrypt(ctx, s.resourceType.SuperAttribute(true).SubAttributeForName("employeeNumber"), "E-1001")
+	require.Nil(s.T(), err)
+	require.Nil(s.T(), database.Insert(ctx, s.newPatient("1", forged, "555-0100")))
+
+	stored, err := raw.Get(ctx, "1", nil)
+	require.Nil(s.T(), err)
+	assert.NotEqual(s.T(), forged, s.valueAt(stored, "employeeNumber"))
+
+	r, err := database.Get(ctx, "1", nil)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), forged, s.valueAt(r, "employeeNumber"))
+
+	n, err := database.Count(ctx, `employeeNumber eq "E-1001"`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, n)
+}
+
+func (s *EncryptedDBTestSuite) TestFilter() {
+	var (
+		ctx      = context.Background()
+		database = DB(db.Memory(), s.resourceType, NewCipher(mustKeyFile(s.T(), "k1", "k1")))
+	)
+	require.Nil(s.T(), database.Insert(ctx, s.newPatient("1", "E-1001", "555-0100")))
+	require.Nil(s.T(), database.Insert(ctx, s.newPatient("2", "E-1002", "555-0200")))
+
+	tests := []struct {
+		name   string
+		filter string
+		sort   *crud.Sort
+		expect func(t *testing.T, ids []string, err error)
+	}{
+		{
+			name:   "eq on blind index",
+			filter: `employeeNumber eq "e-1002"`,
+			expect: func(t *testing.T, ids []string, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"2"}, ids)
+			},
+		},
+		{
+			name:   "eq on blind index of multiValued",
+			filter: `phoneNumbers.value eq "555-0100" and not (userName eq "foo")`,
+			expect: func(t *testing.T, ids []string, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"1"}, ids)
+			},
+		},
+		{
+			name:   "pr",
+			filter: `notes pr or employeeNumber eq "E-1003"`,
+			expect: func(t *testing.T, ids []string, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, ids, 2)
+			},
+		},
+		{
+			name:   "eq without blind index",
+			filter: `notes eq "confidential"`,
+			expect: func(t *testing.T, ids []string, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "sw",
+			filter: `employeeNumber sw "E-"`,
+			expect: func(t *testing.T, ids []string, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "sort",
+			filter: `id pr`,
+			sort:   &crud.Sort{By: "employeeNumber"},
+			expect: func(t *testing.T, ids []string, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			resources, err := database.Query(ctx, test.filter, test.sort, nil, nil)
+			var ids []string
+			for _, r := range resources {
+				ids = append(ids, r.IdOrEmpty())
+			}
+			test.expect(t, ids, err)
+		})
+	}
+
+	n, err := database.Count(ctx, `employeeNumber eq "E-1001"`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, n)
+}
+
+func (s *EncryptedDBTestSuite) TestRewriteFilter() {
+	cipher := NewCipher(mustKeyFile(s.T(), "k1", "k1"))
+	index, err := cipher.BlindIndex(context.Background(), s.resourceType.SuperAttribute(true).SubAttributeForName("employeeNumber"), "E-1001")
+	require.Nil(s.T(), err)
+
+	for filter, expect := range map[string]string{
+		`userName eq "imulab"`:                                `userName eq "imulab"`,
+		`userName eq "imulab" and employeeNumber eq "E-1001"`: `(userName eq "imulab") and (employeeNumber sw "enc1:` + index + `:")`,
+		`not (employeeNumber eq "E-1001") or meta.version pr`: `(not (employeeNumber sw "enc1:` + index + `:")) or (meta.version pr)`,
+	} {
+		actual, err := cipher.RewriteFilter(context.Background(), s.resourceType, filter)
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), expect, actual)
+	}
+}
+
+func (s *EncryptedDBTestSuite) TestRotate() {
+	var (
+		ctx       = context.Background()
+		raw       = db.Memory()
+		oldCipher = NewCipher(mustKeyFile(s.T(), "k1", "k1"))
+		newCipher = NewCipher(mustKeyFile(s.T(), "k2", "k1", "k2"))
+	)
+
+	// one resource stored before encryption was enabled, two encrypted with the old key, queried one at a time
+	require.Nil(s.T(), raw.Insert(ctx, s.newPatient("1", "E-1001", "555-0100")))
+	require.Nil(s.T(), DB(raw, s.resourceType, oldCipher).Insert(ctx, s.newPatient("2", "E-1002", "555-0200")))
+	require.Nil(s.T(), DB(raw, s.resourceType, oldCipher).Insert(ctx, s.newPatient("3", "E-1003", "555-0300")))
+
+	defer func(n int) { rotationBatchSize = n }(rotationBatchSize)
+	rotationBatchSize = 1
+
+	report, err := Rotate(ctx, raw, newCipher)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 3, report.Rotated)
+	assert.Len(s.T(), report.Failed, 0)
+
+	for _, id := range []string{"1", "2", "3"} {
+		stored, err := raw.Get(ctx, id, nil)
+		require.Nil(s.T(), err)
+		current, err := newCipher.IsCurrent(ctx, s.valueAt(stored, "phoneNumbers.value"))
+		assert.Nil(s.T(), err)
+		assert.True(s.T(), current)
+	}
+
+	n, err := DB(raw, s.resourceType, newCipher).Count(ctx, `employeeNumber eq "E-1001"`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, n)
+
+	// nothing left to rotate
+	report, err = Rotate(ctx, raw, newCipher)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, report.Rotated)
+}
+
+func (s *EncryptedDBTestSuite) newPatient(id string, employeeNumber string, phoneNumber string) *prop.Resource {
+	resource := prop.NewResource(s.resourceType)
+	_, err := resource.RootProperty().Replace(map[string]interface{}{
+		"schemas":        []interface{}{"urn:imulab:scim:2.0:Patient"},
+		"id":             id,
+		"meta":           map[string]interface{}{"version": "W/\"1\""},
+		"userName":       "imulab",
+		"employeeNumber": employeeNumber,
+		"notes":          "confidential",
+		"certificate":    base64.StdEncoding.EncodeToString([]byte("certificate")),
+		"phoneNumbers": []interface{}{
+			map[string]interface{}{"value": phoneNumber, "type": "work"},
+		},
+	})
+	require.Nil(s.T(), err)
+	return resource
+}
+
+func (s *EncryptedDBTestSuite) valueAt(resource *prop.Resource, path string) string {
+	nav := resource.Navigator()
+	for _, name := range strings.Split(path, ".") {
+		nav.Dot(name)
+		if nav.Current().Attribute().MultiValued() {
+			nav.At(0)
+		}
+	}
+	require.False(s.T(), nav.HasError())
+	return nav.Current().Raw().(string)
+}
@@ -0,0 +1,12 @@
+// This package encrypts the values of properties annotated with @Encrypted at rest.
+//
+// Values are encrypted with AES-GCM using keys from a KeyProvider. A Cipher encrypts values with the current key of
+// the provider, and decrypts values with the key they were encrypted with, so keys can be rotated without downtime.
+// When an attribute enables blind index, a deterministic HMAC of the value is stored along with the encrypted value,
+// so that the attribute can still be filtered with the eq operator.
+//
+// The filters returned by EncryptFilter and DecryptFilter are filter.ByProperty implementations that process the
+// annotated properties. DB wraps a db.DB so that resources are encrypted before they are written, and decrypted after
+// they are read, leaving the resources of the callers in plaintext. Rotate re-encrypts the stored resources whose
+// values were encrypted with a key other than the current one.
+package encryption
@@ -0,0 +1,127 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// EncryptFilter returns a ByProperty filter that encrypts the values of singular string, reference or binary properties
+// whose attribute is annotated with @Encrypted with the current key of the cipher. Values are always treated as
+// plaintext, even if they look encrypted, as they come from API callers. Binary properties hold the base64 encoded
+// bytes of the encrypted value. The value replacement is strictly local.
+func EncryptFilter(cipher *Cipher) filter.ByProperty {
+	return encryptPropertyFilter{cipher: cipher}
+}
+
+// DecryptFilter returns a ByProperty filter that decrypts the values of the properties encrypted by EncryptFilter.
+// Values that are not encrypted are left as they are, so that resources stored before their attributes were annotated
+// with @Encrypted remain readable. The value replacement is strictly local.
+func DecryptFilter(cipher *Cipher) filter.ByProperty {
+	return decryptPropertyFilter{cipher: cipher}
+}
+
+type encryptPropertyFilter struct {
+	cipher *Cipher
+}
+
+func (f encryptPropertyFilter) Supports(attribute *spec.Attribute) bool {
+	return supports(attribute)
+}
+
+func (f encryptPropertyFilter) Filter(ctx context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+
+	property := nav.Current()
+	if property.IsUnassigned() {
+		return nil
+	}
+
+	encrypted, err := f.cipher.Encrypt(ctx, property.Attribute(), property.Raw().(string))
+	if err != nil {
+		return err
+	}
+	return replaceStoredValue(property, encrypted)
+}
+
+func (f encryptPropertyFilter) FilterRef(ctx context.Context, resourceType *spec.ResourceType, nav prop.Navigator, _ prop.Navigator) error {
+	// Encrypted values are not comparable to the reference, because every encryption uses a different nonce.
+	return f.Filter(ctx, resourceType, nav)
+}
+
+type decryptPropertyFilter struct {
+	cipher *Cipher
+}
+
+func (f decryptPropertyFilter) Supports(attribute *spec.Attribute) bool {
+	return supports(attribute)
+}
+
+func (f decryptPropertyFilter) Filter(ctx context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+
+	property := nav.Current()
+	if property.IsUnassigned() {
+		return nil
+	}
+
+	value := storedValue(property)
+	if !IsEncrypted(value) {
+		return nil
+	}
+
+	plaintext, err := f.cipher.Decrypt(ctx, property.Attribute(), value)
+	if err != nil {
+		return err
+	}
+	return replaceStoredValue(property, plaintext)
+}
+
+func (f decryptPropertyFilter) FilterRef(ctx context.Context, resourceType *spec.ResourceType, nav prop.Navigator, _ prop.Navigator) error {
+	return f.Filter(ctx, resourceType, nav)
+}
+
+func supports(attribute *spec.Attribute) bool {
+	if _, ok := attribute.Annotation(annotation.Encrypted); !ok {
+		return false
+	}
+	if attribute.MultiValued() {
+		return false
+	}
+	switch attribute.Type() {
+	case spec.TypeString, spec.TypeReference, spec.TypeBinary:
+		return true
+	default:
+		return false
+	}
+}
+
+// storedValue returns the value of the assigned property as it is encrypted or decrypted: the string value of string
+// and reference properties, and the bytes of binary properties as a string. For binary properties, this is either the
+// encrypted value, or the base64 encoded plaintext.
+func storedValue(property prop.Property) string {
+	raw := property.Raw().(string)
+	if property.Attribute().Type() == spec.TypeBinary {
+		if b, err := base64.StdEncoding.DecodeString(raw); err == nil && IsEncrypted(string(b)) {
+			return string(b)
+		}
+	}
+	return raw
+}
+
+// replaceStoredValue is the reverse of storedValue.
+func replaceStoredValue(property prop.Property, value string) error {
+	if property.Attribute().Type() == spec.TypeBinary && IsEncrypted(value) {
+		value = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	_, err := property.Replace(value)
+	return err
+}
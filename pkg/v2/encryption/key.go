@@ -0,0 +1,118 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Key is a secret to encrypt values with.
+type Key struct {
+	// ID identifies the key in the encrypted values. It may contain letters, digits, underscores and hyphens.
+	ID string
+	// Secret is the 256-bit AES key.
+	Secret []byte
+}
+
+// KeyProvider provides the keys to encrypt and decrypt values.
+type KeyProvider interface {
+	// CurrentKey returns the key to encrypt new values with.
+	CurrentKey(ctx context.Context) (*Key, error)
+	// Key returns the key by its id, to decrypt values encrypted with it. Keys replaced as the current key must remain
+	// available until no values are encrypted with them anymore.
+	Key(ctx context.Context, id string) (*Key, error)
+	// IndexKey returns the secret to compute blind indexes with. Unlike the encryption keys, the index key cannot be
+	// rotated, because existing blind indexes would no longer match the filters.
+	IndexKey(ctx context.Context) ([]byte, error)
+}
+
+var keyIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// LocalKeyFile returns a KeyProvider that serves the keys defined in the JSON key file at the path. The file is read
+// once. Its format is:
+//
+//	{
+//		"current": "2020-02",
+//		"keys": {
+//			"2020-02": "<base64 encoded 32 bytes>",
+//			"2019-08": "<base64 encoded 32 bytes>"
+//		},
+//		"indexKey": "<base64 encoded 32 bytes>"
+//	}
+//
+// To rotate keys, add a new key, make it current, and keep the previous keys until Rotate has re-encrypted all values.
+func LocalKeyFile(path string) (KeyProvider, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read key file: %s", spec.ErrInternal, err.Error())
+	}
+
+	var file struct {
+		Current  string            `json:"current"`
+		Keys     map[string]string `json:"keys"`
+		IndexKey string            `json:"indexKey"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("%w: invalid key file: %s", spec.ErrInternal, err.Error())
+	}
+
+	p := &localKeys{keys: map[string]*Key{}}
+	for id, encoded := range file.Keys {
+		if !keyIdPattern.MatchString(id) {
+			return nil, fmt.Errorf("%w: invalid key id '%s' in key file", spec.ErrInternal, id)
+		}
+		secret, err := decodeSecret(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key '%s' in key file %s", spec.ErrInternal, id, err.Error())
+		}
+		p.keys[id] = &Key{ID: id, Secret: secret}
+	}
+
+	var ok bool
+	if p.current, ok = p.keys[file.Current]; !ok {
+		return nil, fmt.Errorf("%w: current key '%s' is not defined in key file", spec.ErrInternal, file.Current)
+	}
+	if p.index, err = decodeSecret(file.IndexKey); err != nil {
+		return nil, fmt.Errorf("%w: index key in key file %s", spec.ErrInternal, err.Error())
+	}
+
+	return p, nil
+}
+
+func decodeSecret(encoded string) ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("is not base64 encoded")
+	}
+	if len(secret) != 32 {
+		return nil, fmt.Errorf("must be 32 bytes long")
+	}
+	return secret, nil
+}
+
+type localKeys struct {
+	current *Key
+	keys    map[string]*Key
+	index   []byte
+}
+
+func (p *localKeys) CurrentKey(_ context.Context) (*Key, error) {
+	return p.current, nil
+}
+
+func (p *localKeys) Key(_ context.Context, id string) (*Key, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown encryption key '%s'", spec.ErrInternal, id)
+	}
+	return key, nil
+}
+
+func (p *localKeys) IndexKey(_ context.Context) ([]byte, error) {
+	return p.index, nil
+}
@@ -0,0 +1,170 @@
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// RewriteFilter returns the SCIM filter to run against the stored resources of the resource type in place of the given
+// filter. Encrypted values can only be matched through their blind index, hence eq predicates on attributes enabling
+// blind index are rewritten to sw predicates on the prefix of encrypted values with the blind index. Predicates with
+// other operators on encrypted attributes are rejected with spec.ErrInvalidFilter, save for pr. Filters not involving
+// encrypted attributes are returned as they are.
+func (c *Cipher) RewriteFilter(ctx context.Context, resourceType *spec.ResourceType, filter string) (string, error) {
+	if len(filter) == 0 {
+		return filter, nil
+	}
+
+	root, err := expr.CompileFilterWith(resourceType.Registry(), filter)
+	if err != nil {
+		return "", err
+	}
+
+	r := rewriter{ctx: ctx, cipher: c, superAttr: resourceType.SuperAttribute(true)}
+	if !r.involvesEncryption(root) {
+		return filter, nil
+	}
+
+	var sb strings.Builder
+	if err := r.write(&sb, root); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// CheckSort returns spec.ErrInvalidValue if the sort is by an encrypted attribute of the resource type.
+func CheckSort(resourceType *spec.ResourceType, sort *crud.Sort) error {
+	if sort == nil || len(sort.By) == 0 {
+		return nil
+	}
+
+	head, err := expr.CompilePathWith(resourceType.Registry(), sort.By)
+	if err != nil {
+		return err
+	}
+
+	if attr := resolve(resourceType.SuperAttribute(true), head); attr != nil && encrypted(attr) {
+		return fmt.Errorf("%w: cannot sort by encrypted attribute '%s'", spec.ErrInvalidValue, attr.Path())
+	}
+	return nil
+}
+
+type rewriter struct {
+	ctx       context.Context
+	cipher    *Cipher
+	superAttr *spec.Attribute
+}
+
+func (r *rewriter) involvesEncryption(root *expr.Expression) bool {
+	switch strings.ToLower(root.Token()) {
+	case expr.And, expr.Or:
+		return r.involvesEncryption(root.Left()) || r.involvesEncryption(root.Right())
+	case expr.Not:
+		return r.involvesEncryption(root.Left())
+	default:
+		attr := resolve(r.superAttr, root.Left())
+		return attr != nil && encrypted(attr)
+	}
+}
+
+func (r *rewriter) write(sb *strings.Builder, root *expr.Expression) error {
+	switch strings.ToLower(root.Token()) {
+	case expr.And, expr.Or:
+		sb.WriteByte('(')
+		if err := r.write(sb, root.Left()); err != nil {
+			return err
+		}
+		sb.WriteString(") " + root.Token() + " (")
+		if err := r.write(sb, root.Right()); err != nil {
+			return err
+		}
+		sb.WriteByte(')')
+		return nil
+	case expr.Not:
+		sb.WriteString("not (")
+		if err := r.write(sb, root.Left()); err != nil {
+			return err
+		}
+		sb.WriteByte(')')
+		return nil
+	default:
+		return r.writeRelational(sb, root.Left(), root, root.Right())
+	}
+}
+
+func (r *rewriter) writeRelational(sb *strings.Builder, path *expr.Expression, op *expr.Expression, value *expr.Expression) error {
+	writePath(sb, path)
+
+	attr := resolve(r.superAttr, path)
+	if attr == nil || !encrypted(attr) {
+		sb.WriteString(" " + op.Token())
+		if value != nil {
+			sb.WriteString(" " + value.Token())
+		}
+		return nil
+	}
+
+	switch strings.ToLower(op.Token()) {
+	case expr.Pr:
+		sb.WriteString(" " + expr.Pr)
+		return nil
+	case expr.Eq:
+		if !BlindIndexed(attr) {
+			return fmt.Errorf("%w: encrypted attribute '%s' does not enable blind index", spec.ErrInvalidFilter, attr.Path())
+		}
+		var plaintext string
+		if err := json.Unmarshal([]byte(value.Token()), &plaintext); err != nil {
+			return fmt.Errorf("%w: encrypted attribute '%s' can only be compared to strings", spec.ErrInvalidFilter, attr.Path())
+		}
+		index, err := r.cipher.BlindIndex(r.ctx, attr, plaintext)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(" " + expr.Sw + " " + strconv.Quote(prefix+index+separator))
+		return nil
+	default:
+		return fmt.Errorf("%w: encrypted attribute '%s' can only be filtered with eq", spec.ErrInvalidFilter, attr.Path())
+	}
+}
+
+// writePath writes the path segments. Segments following a schema URN are separated by a colon, and the others by a dot.
+func writePath(sb *strings.Builder, path *expr.Expression) {
+	for cursor := path; cursor != nil; cursor = cursor.Next() {
+		sb.WriteString(cursor.Token())
+		if cursor.Next() == nil {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(cursor.Token()), "urn:") {
+			sb.WriteString(":")
+		} else {
+			sb.WriteString(".")
+		}
+	}
+}
+
+func encrypted(attr *spec.Attribute) bool {
+	_, ok := attr.Annotation(annotation.Encrypted)
+	return ok
+}
+
+// resolve returns the attribute at the path, or nil if the path is invalid.
+func resolve(superAttr *spec.Attribute, path *expr.Expression) *spec.Attribute {
+	cursor := superAttr
+	for ; path != nil; path = path.Next() {
+		if cursor.MultiValued() {
+			cursor = cursor.DeriveElementAttribute()
+		}
+		if cursor = cursor.SubAttributeForName(path.Token()); cursor == nil {
+			return nil
+		}
+	}
+	return cursor
+}
@@ -0,0 +1,166 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Report summarizes the outcome of a key rotation.
+type Report struct {
+	Rotated int              // number of resources re-encrypted with the current key
+	Failed  map[string]error // errors keyed by the id of the resource that failed to be re-encrypted
+}
+
+// Number of resources queried at a time by Rotate.
+var rotationBatchSize = 100
+
+// Rotate re-encrypts the resources stored in the database, whose encrypted properties were encrypted with a key other
+// than the current key of the cipher, or are still in plaintext. The database is the one storing the encrypted
+// resources, not the one returned by DB. Resources are queried in batches, in the order of their ids. Resources are
+// replaced with the same version, so rotation is invisible to API callers, and loses to concurrent modifications:
+// those resources are recorded as failed in the report, and are encrypted with the current key by the modification
+// anyway. An error is only returned when the process cannot proceed, for instance, when the database cannot be queried.
+func Rotate(ctx context.Context, database db.DB, cipher *Cipher) (*Report, error) {
+	var (
+		report  = &Report{Failed: map[string]error{}}
+		encrypt = filter.ByPropertyToByResource(rotateFilter{cipher: cipher})
+		cursor  string
+	)
+
+	for {
+		resources, err := database.Query(ctx, rotationFilter(cursor), &crud.Sort{By: "id", Order: crud.SortAsc},
+			&crud.Pagination{StartIndex: 1, Count: rotationBatchSize}, nil)
+		if err != nil {
+			return report, err
+		}
+
+		for _, resource := range resources {
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			default:
+			}
+
+			cursor = resource.IdOrEmpty()
+			if stale, err := isStale(ctx, resource, cipher); err != nil {
+				report.Failed[resource.IdOrEmpty()] = err
+				continue
+			} else if !stale {
+				continue
+			}
+
+			replacement := resource.Clone()
+			if err := encrypt.Filter(ctx, replacement); err != nil {
+				report.Failed[resource.IdOrEmpty()] = err
+				continue
+			}
+			if err := database.Replace(ctx, resource, replacement); err != nil {
+				report.Failed[resource.IdOrEmpty()] = err
+				continue
+			}
+			report.Rotated++
+		}
+
+		if len(resources) < rotationBatchSize {
+			return report, nil
+		}
+	}
+}
+
+// rotationFilter returns the filter for the resources after the cursor.
+func rotationFilter(cursor string) string {
+	if len(cursor) == 0 {
+		return "id pr"
+	}
+	return fmt.Sprintf("id gt %s", strconv.Quote(cursor))
+}
+
+// isStale returns true if any encrypted property of the resource is in plaintext, or encrypted with a key other than
+// the current key.
+func isStale(ctx context.Context, resource *prop.Resource, cipher *Cipher) (bool, error) {
+	check := staleCheck{cipher: cipher}
+	if err := filter.Visit(ctx, resource, &check); err != nil {
+		return false, err
+	}
+	return check.stale, nil
+}
+
+type staleCheck struct {
+	cipher *Cipher
+	stale  bool
+}
+
+func (c *staleCheck) Supports(attribute *spec.Attribute) bool {
+	return supports(attribute)
+}
+
+func (c *staleCheck) Filter(ctx context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+	if c.stale || nav.Current().IsUnassigned() {
+		return nil
+	}
+
+	current, err := c.cipher.IsCurrent(ctx, storedValue(nav.Current()))
+	if err != nil {
+		return err
+	}
+	c.stale = !current
+	return nil
+}
+
+func (c *staleCheck) FilterRef(ctx context.Context, resourceType *spec.ResourceType, nav prop.Navigator, _ prop.Navigator) error {
+	return c.Filter(ctx, resourceType, nav)
+}
+
+// rotateFilter encrypts the stored values in plaintext with the current key of the cipher, and re-encrypts the values
+// encrypted with another key. Unlike EncryptFilter, it trusts stored values that look encrypted, and leaves the values
+// encrypted with the current key as they are.
+type rotateFilter struct {
+	cipher *Cipher
+}
+
+func (f rotateFilter) Supports(attribute *spec.Attribute) bool {
+	return supports(attribute)
+}
+
+func (f rotateFilter) Filter(ctx context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+
+	property := nav.Current()
+	if property.IsUnassigned() {
+		return nil
+	}
+
+	value := storedValue(property)
+	if IsEncrypted(value) {
+		if current, err := f.cipher.IsCurrent(ctx, value); err != nil || current {
+			return err
+		}
+		plaintext, err := f.cipher.Decrypt(ctx, property.Attribute(), value)
+		if err != nil {
+			return err
+		}
+		value = plaintext
+	}
+
+	encrypted, err := f.cipher.Encrypt(ctx, property.Attribute(), value)
+	if err != nil {
+		return err
+	}
+	return replaceStoredValue(property, encrypted)
+}
+
+func (f rotateFilter) FilterRef(ctx context.Context, resourceType *spec.ResourceType, nav prop.Navigator, _ prop.Navigator) error {
+	return f.Filter(ctx, resourceType, nav)
+}
@@ -0,0 +1,11 @@
+// This package implements SCIM provisioning events, delivered as Security Event Tokens (SET).
+//
+// A Security Event Token (RFC8417) is a JWT whose "events" claim carries one or more event payloads keyed by the event
+// URI. The SCIM events draft defines a set of provisioning event URIs (i.e. prov:create:full, prov:patch:full,
+// prov:delete) that describe the outcome of the SCIM operations.
+//
+// The Publisher turns the outcome of the services in the service package into signed SETs and delivers them through a
+// Transport. The most convenient way to use the Publisher is to decorate the existing services with PublishingCreate,
+// PublishingReplace, PublishingPatch and PublishingDelete. The Receiver does the opposite: it verifies incoming SETs and
+// applies the carried changes through the existing services.
+package event
@@ -0,0 +1,54 @@
+package event
+
+// Error prototypes, as registered in the "Security Event Token Delivery Error Codes" registry (RFC8935).
+var (
+	// The request body cannot be parsed as a SET, or the event payload within the SET does not conform to the event's
+	// definition.
+	ErrInvalidRequest = &Error{Type: "invalid_request"}
+
+	// One or more keys used to encrypt or sign the SET is invalid or otherwise unacceptable to the SET recipient.
+	ErrInvalidKey = &Error{Type: "invalid_key"}
+
+	// The SET issuer is invalid for the SET recipient.
+	ErrInvalidIssuer = &Error{Type: "invalid_issuer"}
+
+	// The SET audience does not correspond to the SET recipient.
+	ErrInvalidAudience = &Error{Type: "invalid_audience"}
+
+	// The SET recipient could not authenticate the SET transmitter.
+	ErrAuthenticationFailed = &Error{Type: "authentication_failed"}
+
+	// The SET transmitter is not authorized to transmit the SET to the SET recipient.
+	ErrAccessDenied = &Error{Type: "access_denied"}
+)
+
+// A SET delivery error. To create an error, use the error prototypes (i.e. ErrInvalidRequest) and wrap it with
+// fmt.Errorf("%w: additional detail", err).
+type Error struct {
+	Type string
+}
+
+func (e Error) Error() string {
+	return e.Type
+}
+
+// errorByType returns the error prototype that matches the error code, or ErrInvalidRequest if none matches.
+func errorByType(code string) *Error {
+	for _, each := range []*Error{
+		ErrInvalidRequest,
+		ErrInvalidKey,
+		ErrInvalidIssuer,
+		ErrInvalidAudience,
+		ErrAuthenticationFailed,
+		ErrAccessDenied,
+	} {
+		if each.Type == code {
+			return each
+		}
+	}
+	return ErrInvalidRequest
+}
+
+var (
+	_ error = (*Error)(nil)
+)
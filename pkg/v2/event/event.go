@@ -0,0 +1,90 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SCIM provisioning event URIs
+const (
+	// A resource was created, the event payload contains the full representation of the created resource.
+	ProvCreateFull = "urn:ietf:params:SCIM:event:prov:create:full"
+	// A resource was created, the event payload contains the names of the attributes that were set.
+	ProvCreateNotice = "urn:ietf:params:SCIM:event:prov:create:notice"
+	// A resource was patched, the event payload contains the SCIM PATCH request body.
+	ProvPatchFull = "urn:ietf:params:SCIM:event:prov:patch:full"
+	// A resource was patched, the event payload contains the names of the attributes that were modified.
+	ProvPatchNotice = "urn:ietf:params:SCIM:event:prov:patch:notice"
+	// A resource was replaced, the event payload contains the full representation of the replaced resource.
+	ProvPutFull = "urn:ietf:params:SCIM:event:prov:put:full"
+	// A resource was replaced, the event payload contains the names of the attributes that were set.
+	ProvPutNotice = "urn:ietf:params:SCIM:event:prov:put:notice"
+	// A resource was deleted.
+	ProvDelete = "urn:ietf:params:SCIM:event:prov:delete"
+	// A resource was activated.
+	ProvActivate = "urn:ietf:params:SCIM:event:prov:activate"
+	// A resource was deactivated.
+	ProvDeactivate = "urn:ietf:params:SCIM:event:prov:deactivate"
+)
+
+// Subject identifier format for SCIM resources, as used in the "sub_id" claim.
+const SubjectFormatScim = "scim"
+
+type (
+	// SecurityEventToken is the claim set of a Security Event Token (RFC8417) carrying SCIM provisioning events.
+	SecurityEventToken struct {
+		Issuer        string              `json:"iss"`
+		IssuedAt      int64               `json:"iat"`
+		Expiration    int64               `json:"exp,omitempty"`
+		ID            string              `json:"jti"`
+		Audience      []string            `json:"aud,omitempty"`
+		TransactionID string              `json:"txn,omitempty"`
+		Subject       *SubjectID          `json:"sub_id,omitempty"`
+		Events        map[string]*Payload `json:"events"`
+	}
+	// SubjectID identifies the SCIM resource that is the subject of the events.
+	SubjectID struct {
+		Format     string `json:"format"`
+		URI        string `json:"uri"`
+		ExternalID string `json:"externalId,omitempty"`
+	}
+	// Payload is the content of a single SCIM provisioning event.
+	Payload struct {
+		Attributes []string        `json:"attributes,omitempty"` // names of the affected attributes, used by notice events
+		Data       json.RawMessage `json:"data,omitempty"`       // resource or patch request body, used by full events
+		Version    string          `json:"version,omitempty"`    // the version (meta.version) of the resource after change
+	}
+)
+
+// ResourceID returns the id of the subject resource, which is the last segment of the subject URI. If the subject is
+// not present, or is not in the SCIM format, an ErrInvalidRequest error is returned.
+func (t *SecurityEventToken) ResourceID() (string, error) {
+	_, id, err := t.splitSubject()
+	return id, err
+}
+
+// Endpoint returns the resource type endpoint (i.e. /Users) of the subject resource. If the subject is not present, or
+// is not in the SCIM format, an ErrInvalidRequest error is returned.
+func (t *SecurityEventToken) Endpoint() (string, error) {
+	endpoint, _, err := t.splitSubject()
+	return endpoint, err
+}
+
+func (t *SecurityEventToken) splitSubject() (endpoint string, id string, err error) {
+	if t.Subject == nil || t.Subject.Format != SubjectFormatScim {
+		err = fmt.Errorf("%w: missing scim subject", ErrInvalidRequest)
+		return
+	}
+	i := strings.LastIndex(t.Subject.URI, "/")
+	if i <= 0 || i == len(t.Subject.URI)-1 {
+		err = fmt.Errorf("%w: malformed subject uri '%s'", ErrInvalidRequest, t.Subject.URI)
+		return
+	}
+	endpoint, id = t.Subject.URI[:i], t.Subject.URI[i+1:]
+	// The uri may be absolute, in which case only the last path segment before the id is the endpoint.
+	if j := strings.LastIndex(endpoint, "/"); j > 0 {
+		endpoint = endpoint[j:]
+	}
+	return
+}
@@ -0,0 +1,547 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent(t *testing.T) {
+	s := new(EventTestSuite)
+	suite.Run(t, s)
+}
+
+type EventTestSuite struct {
+	suite.Suite
+	resourceType *spec.ResourceType
+}
+
+func (s *EventTestSuite) TestEncodeDecode() {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(s.T(), err)
+
+	newToken := func() *SecurityEventToken {
+		return &SecurityEventToken{
+			Issuer:   "https://idp.example.com",
+			IssuedAt: time.Now().Unix(),
+			ID:       "4d3559ec67504aaba65d40b0363faad8",
+			Subject:  &SubjectID{Format: SubjectFormatScim, URI: "/Users/44f6142df96bd6ab61e7521d9"},
+			Events:   map[string]*Payload{ProvDelete: {}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		signer   Signer
+		verifier Verifier
+		tamper   func(compact string) string
+		expect   func(t *testing.T, token *SecurityEventToken, err error)
+	}{
+		{
+			name:     "HS256",
+			signer:   HS256Signer([]byte("s3cr3t")),
+			verifier: HS256Verifier([]byte("s3cr3t")),
+			expect: func(t *testing.T, token *SecurityEventToken, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "https://idp.example.com", token.Issuer)
+				id, err := token.ResourceID()
+				assert.Nil(t, err)
+				assert.Equal(t, "44f6142df96bd6ab61e7521d9", id)
+				endpoint, err := token.Endpoint()
+				assert.Nil(t, err)
+				assert.Equal(t, "/Users", endpoint)
+				_, ok := token.Events[ProvDelete]
+				assert.True(t, ok)
+			},
+		},
+		{
+			name:     "RS256",
+			signer:   RS256Signer(rsaKey),
+			verifier: RS256Verifier(&rsaKey.PublicKey),
+			expect: func(t *testing.T, token *SecurityEventToken, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:     "wrong key",
+			signer:   HS256Signer([]byte("s3cr3t")),
+			verifier: HS256Verifier([]byte("other")),
+			expect: func(t *testing.T, token *SecurityEventToken, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidKey))
+			},
+		},
+		{
+			name:     "algorithm mismatch",
+			signer:   HS256Signer([]byte("s3cr3t")),
+			verifier: RS256Verifier(&rsaKey.PublicKey),
+			expect: func(t *testing.T, token *SecurityEventToken, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidKey))
+			},
+		},
+		{
+			name:     "tampered claims",
+			signer:   HS256Signer([]byte("s3cr3t")),
+			verifier: HS256Verifier([]byte("s3cr3t")),
+			tamper: func(compact string) string {
+				parts := strings.Split(compact, ".")
+				parts[1] = parts[1][1:]
+				return strings.Join(parts, ".")
+			},
+			expect: func(t *testing.T, token *SecurityEventToken, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:     "malformed",
+			signer:   HS256Signer([]byte("s3cr3t")),
+			verifier: HS256Verifier([]byte("s3cr3t")),
+			tamper: func(compact string) string {
+				return "foobar"
+			},
+			expect: func(t *testing.T, token *SecurityEventToken, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			compact, err := Encode(newToken(), test.signer)
+			require.Nil(t, err)
+			if test.tamper != nil {
+				compact = test.tamper(compact)
+			}
+			token, err := Decode(compact, test.verifier)
+			test.expect(t, token, err)
+		})
+	}
+}
+
+func (s *EventTestSuite) TestPublishingServices() {
+	var (
+		key      = []byte("s3cr3t")
+		database = db.Memory()
+		tokens   = make(chan string, 1)
+	)
+
+	publisher := NewPublisher("https://idp.example.com", HS256Signer(key), transportFunc(func(_ context.Context, token string) error {
+		tokens <- token
+		return nil
+	})).Audience("https://rp.example.com")
+
+	create := PublishingCreate(service.CreateService(s.resourceType, database, []filter.ByResource{
+		filter.ByPropertyToByResource(filter.UUIDFilter()),
+	}), publisher)
+	config := new(spec.ServiceProviderConfig)
+	config.Patch.Supported = true
+
+	patch := PublishingPatch(service.PatchService(config, database, nil, []filter.ByResource{
+		filter.MetaFilter(),
+	}), publisher)
+	del := PublishingDelete(service.DeleteService(config, database), publisher)
+
+	receive := func(t *testing.T) *SecurityEventToken {
+		select {
+		case compact := <-tokens:
+			token, err := Decode(compact, HS256Verifier(key))
+			require.Nil(t, err)
+			return token
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no event published")
+			return nil
+		}
+	}
+
+	createResp, err := create.Do(context.Background(), &service.CreateRequest{
+		PayloadSource: strings.NewReader(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"imulab"}`),
+	})
+	require.Nil(s.T(), err)
+	id := createResp.Resource.IdOrEmpty()
+
+	token := receive(s.T())
+	assert.Equal(s.T(), "https://idp.example.com", token.Issuer)
+	assert.Equal(s.T(), []string{"https://rp.example.com"}, token.Audience)
+	assert.Equal(s.T(), "/Users/"+id, token.Subject.URI)
+	require.Contains(s.T(), token.Events, ProvCreateFull)
+	assert.Contains(s.T(), string(token.Events[ProvCreateFull].Data), `"userName":"imulab"`)
+
+	patchBody := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"userName","value":"foobar"}]}`
+	_, err = patch.Do(context.Background(), &service.PatchRequest{
+		ResourceID:    id,
+		PayloadSource: strings.NewReader(patchBody),
+	})
+	require.Nil(s.T(), err)
+
+	token = receive(s.T())
+	require.Contains(s.T(), token.Events, ProvPatchFull)
+	assert.JSONEq(s.T(), patchBody, string(token.Events[ProvPatchFull].Data))
+
+	_, err = del.Do(context.Background(), &service.DeleteRequest{ResourceID: id})
+	require.Nil(s.T(), err)
+
+	token = receive(s.T())
+	assert.Contains(s.T(), token.Events, ProvDelete)
+
+	// changes applying received events are not published again
+	_, err = create.Do(withReceived(context.Background()), &service.CreateRequest{
+		PayloadSource: strings.NewReader(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"received"}`),
+	})
+	require.Nil(s.T(), err)
+	select {
+	case <-tokens:
+		assert.Fail(s.T(), "received change was published")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *EventTestSuite) TestPublishInOrder() {
+	var (
+		key       = []byte("s3cr3t")
+		delivered = make([]string, 0)
+		dropped   = 0
+	)
+
+	publisher := NewPublisher("https://idp.example.com", HS256Signer(key), transportFunc(func(_ context.Context, compact string) error {
+		time.Sleep(10 * time.Millisecond)
+		token, err := Decode(compact, HS256Verifier(key))
+		require.Nil(s.T(), err)
+		delivered = append(delivered, token.ID)
+		return nil
+	})).OnError(func(_ *SecurityEventToken, err error) {
+		if errors.Is(err, ErrPublishDropped) {
+			dropped++
+		}
+	})
+
+	expect := []string{"1", "2", "3", "4", "5"}
+	for _, id := range expect {
+		publisher.publishAsync(&SecurityEventToken{ID: id, Events: map[string]*Payload{ProvDelete: {}}})
+	}
+
+	// close waits for the queued events to be delivered, and drops further events
+	publisher.Close()
+	assert.Equal(s.T(), expect, delivered)
+	publisher.publishAsync(&SecurityEventToken{ID: "6", Events: map[string]*Payload{ProvDelete: {}}})
+	assert.Equal(s.T(), 1, dropped)
+	assert.Len(s.T(), delivered, 5)
+}
+
+func (s *EventTestSuite) TestReceiverReplay() {
+	var (
+		key     = []byte("s3cr3t")
+		applied []string
+	)
+	record := func(uri string) func() {
+		return func() { applied = append(applied, uri) }
+	}
+	receiver := NewReceiver(HS256Verifier(key), "", "").Register("/Users", &Target{
+		Create:  recordingCreate(record(ProvCreateFull)),
+		Replace: recordingReplace(record(ProvPutFull)),
+		Delete:  recordingDelete(record(ProvDelete)),
+	})
+	receiver.now = func() time.Time { return time.Unix(1600000000, 0) }
+
+	encode := func(t *testing.T, token *SecurityEventToken) string {
+		if token.Subject == nil {
+			token.Subject = &SubjectID{Format: SubjectFormatScim, URI: "/Users/foo"}
+		}
+		compact, err := Encode(token, HS256Signer(key))
+		require.Nil(t, err)
+		return compact
+	}
+
+	tests := []struct {
+		name   string
+		token  *SecurityEventToken
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name: "events are applied in order",
+			token: &SecurityEventToken{ID: "1", IssuedAt: 1600000000, Events: map[string]*Payload{
+				ProvDelete:     {},
+				ProvPutFull:    {Data: json.RawMessage(`{}`)},
+				ProvCreateFull: {Data: json.RawMessage(`{}`)},
+			}},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{ProvCreateFull, ProvPutFull, ProvDelete}, applied)
+			},
+		},
+		{
+			name:  "replay",
+			token: &SecurityEventToken{ID: "1", IssuedAt: 1600000000, Events: map[string]*Payload{ProvDelete: {}}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+				assert.Empty(t, applied)
+			},
+		},
+		{
+			name:  "missing jti",
+			token: &SecurityEventToken{IssuedAt: 1600000000, Events: map[string]*Payload{ProvDelete: {}}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+			},
+		},
+		{
+			name:  "too old",
+			token: &SecurityEventToken{ID: "2", IssuedAt: 1600000000 - 3600, Events: map[string]*Payload{ProvDelete: {}}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+			},
+		},
+		{
+			name:  "expired",
+			token: &SecurityEventToken{ID: "3", IssuedAt: 1600000000, Expiration: 1600000000 - 1, Events: map[string]*Payload{ProvDelete: {}}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+			},
+		},
+		{
+			name: "failed events",
+			token: &SecurityEventToken{ID: "4", IssuedAt: 1600000000, Events: map[string]*Payload{
+				ProvCreateFull: {Data: json.RawMessage(`{}`)},
+				ProvPatchFull:  {Data: json.RawMessage(`{}`)},
+			}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+				assert.Equal(t, []string{ProvCreateFull}, applied)
+			},
+		},
+		{
+			name: "failed events can be delivered again, skipping the events applied",
+			token: &SecurityEventToken{ID: "4", IssuedAt: 1600000000, Events: map[string]*Payload{
+				ProvCreateFull: {Data: json.RawMessage(`{}`)},
+				ProvPatchFull:  {Data: json.RawMessage(`{}`)},
+			}},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+				assert.Empty(t, applied)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			applied = nil
+			_, err := receiver.Receive(context.Background(), encode(t, test.token))
+			test.expect(t, err)
+		})
+	}
+
+	forged, err := Encode(&SecurityEventToken{
+		ID:       "5",
+		IssuedAt: time.Now().Unix(),
+		Subject:  &SubjectID{Format: SubjectFormatScim, URI: "/Users/foo"},
+		Events:   map[string]*Payload{ProvDelete: {}},
+	}, HS256Signer(nil))
+	require.Nil(s.T(), err)
+	_, err = NewReceiver(HS256Verifier(nil), "", "").Receive(context.Background(), forged)
+	assert.True(s.T(), errors.Is(err, ErrInvalidKey), "empty secret shall not verify")
+}
+
+type (
+	recordingCreate  func()
+	recordingReplace func()
+	recordingDelete  func()
+)
+
+func (f recordingCreate) Do(ctx context.Context, _ *service.CreateRequest) (*service.CreateResponse, error) {
+	if !Received(ctx) {
+		return nil, errors.New("not received")
+	}
+	f()
+	return &service.CreateResponse{}, nil
+}
+
+func (f recordingReplace) Do(ctx context.Context, _ *service.ReplaceRequest) (*service.ReplaceResponse, error) {
+	f()
+	return &service.ReplaceResponse{}, nil
+}
+
+func (f recordingDelete) Do(ctx context.Context, _ *service.DeleteRequest) (*service.DeleteResponse, error) {
+	f()
+	return &service.DeleteResponse{}, nil
+}
+
+func (s *EventTestSuite) TestHTTPPushToReceiver() {
+	var (
+		key      = []byte("s3cr3t")
+		database = db.Memory()
+	)
+
+	require.Nil(s.T(), database.Insert(context.Background(), s.resourceOf(s.T(), map[string]interface{}{
+		"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"id":       "foo",
+		"userName": "foo",
+	})))
+
+	receiver := NewReceiver(HS256Verifier(key), "https://idp.example.com", "https://rp.example.com").
+		Register("/Users", &Target{
+			Create: service.CreateService(s.resourceType, database, []filter.ByResource{
+				filter.ByPropertyToByResource(filter.UUIDFilter()),
+				filter.MetaFilter(),
+			}),
+			Delete: service.DeleteService(&spec.ServiceProviderConfig{}, database),
+		})
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		if _, err := receiver.Receive(r.Context(), string(raw)); err != nil {
+			_ = WriteDeliveryError(rw, err)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		issuer string
+		token  *SecurityEventToken
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name:   "create",
+			issuer: "https://idp.example.com",
+			token: &SecurityEventToken{
+				Subject: &SubjectID{Format: SubjectFormatScim, URI: "/Users/bar"},
+				Events: map[string]*Payload{ProvCreateFull: {
+					Data: json.RawMessage(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"bar"}`),
+				}},
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+				n, err := database.Count(context.Background(), `userName eq "bar"`)
+				assert.Nil(t, err)
+				assert.Equal(t, 1, n)
+			},
+		},
+		{
+			name:   "delete",
+			issuer: "https://idp.example.com",
+			token: &SecurityEventToken{
+				Subject: &SubjectID{Format: SubjectFormatScim, URI: "https://idp.example.com/scim/Users/foo"},
+				Events:  map[string]*Payload{ProvDelete: {}},
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+				_, err = database.Get(context.Background(), "foo", nil)
+				assert.True(t, errors.Is(err, spec.ErrNotFound))
+			},
+		},
+		{
+			name:   "notice is acknowledged",
+			issuer: "https://idp.example.com",
+			token: &SecurityEventToken{
+				Subject: &SubjectID{Format: SubjectFormatScim, URI: "/Users/foo"},
+				Events:  map[string]*Payload{ProvPutNotice: {Attributes: []string{"userName"}}},
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "unknown issuer",
+			issuer: "https://evil.example.com",
+			token: &SecurityEventToken{
+				Subject: &SubjectID{Format: SubjectFormatScim, URI: "/Users/foo"},
+				Events:  map[string]*Payload{ProvDelete: {}},
+			},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidIssuer))
+			},
+		},
+		{
+			name:   "unknown endpoint",
+			issuer: "https://idp.example.com",
+			token: &SecurityEventToken{
+				Subject: &SubjectID{Format: SubjectFormatScim, URI: "/Devices/foo"},
+				Events:  map[string]*Payload{ProvDelete: {}},
+			},
+			expect: func(t *testing.T, err error) {
+				assert.True(t, errors.Is(err, ErrInvalidRequest))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			publisher := NewPublisher(test.issuer, HS256Signer(key), HTTPPush(server.URL, nil)).
+				Audience("https://rp.example.com")
+			err := publisher.Publish(context.Background(), test.token)
+			test.expect(t, err)
+		})
+	}
+}
+
+func (s *EventTestSuite) resourceOf(t *testing.T, data interface{}) *prop.Resource {
+	r := prop.NewResource(s.resourceType)
+	require.False(t, r.Navigator().Replace(data).HasError())
+	return r
+}
+
+type transportFunc func(ctx context.Context, token string) error
+
+func (f transportFunc) Deliver(ctx context.Context, token string) error {
+	return f(ctx, token)
+}
+
+func (s *EventTestSuite) SetupSuite() {
+	for _, each := range []struct {
+		filepath  string
+		structure interface{}
+		post      func(parsed interface{})
+	}{
+		{
+			filepath:  "../../../public/schemas/core_schema.json",
+			structure: new(spec.Schema),
+			post: func(parsed interface{}) {
+				spec.Schemas().Register(parsed.(*spec.Schema))
+			},
+		},
+		{
+			filepath:  "../../../public/schemas/user_schema.json",
+			structure: new(spec.Schema),
+			post: func(parsed interface{}) {
+				spec.Schemas().Register(parsed.(*spec.Schema))
+			},
+		},
+		{
+			filepath:  "../../../public/resource_types/user_resource_type.json",
+			structure: new(spec.ResourceType),
+			post: func(parsed interface{}) {
+				s.resourceType = parsed.(*spec.ResourceType)
+			},
+		},
+	} {
+		f, err := os.Open(each.filepath)
+		require.Nil(s.T(), err)
+
+		raw, err := ioutil.ReadAll(f)
+		require.Nil(s.T(), err)
+
+		err = json.Unmarshal(raw, each.structure)
+		require.Nil(s.T(), err)
+
+		if each.post != nil {
+			each.post(each.structure)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package event
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Media type of a SET, used as the "typ" header and the Content-Type of push delivery (RFC8417, RFC8935).
+const MediaTypeSecEventJwt = "application/secevent+jwt"
+
+const typSecEventJwt = "secevent+jwt"
+
+type (
+	// Signer produces the JWS signature of a SET.
+	Signer interface {
+		// Algorithm returns the JWS "alg" header value.
+		Algorithm() string
+		// Sign returns the signature of the JWS signing input.
+		Sign(signingInput []byte) ([]byte, error)
+	}
+	// Verifier verifies the JWS signature of a SET.
+	Verifier interface {
+		// Algorithm returns the JWS "alg" header value that this verifier accepts.
+		Algorithm() string
+		// Verify returns a non-nil error if the signature does not match the JWS signing input.
+		Verify(signingInput []byte, signature []byte) error
+	}
+)
+
+// HS256Signer returns a Signer that uses HMAC SHA-256 with the shared secret key.
+func HS256Signer(key []byte) Signer {
+	return &hmacSha256{key: key}
+}
+
+// HS256Verifier returns a Verifier that uses HMAC SHA-256 with the shared secret key. Since anyone can sign with an
+// empty key, the verifier rejects all signatures if the key is empty.
+func HS256Verifier(key []byte) Verifier {
+	return &hmacSha256{key: key}
+}
+
+type hmacSha256 struct {
+	key []byte
+}
+
+func (h *hmacSha256) Algorithm() string {
+	return "HS256"
+}
+
+func (h *hmacSha256) Sign(signingInput []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write(signingInput)
+	return mac.Sum(nil), nil
+}
+
+func (h *hmacSha256) Verify(signingInput []byte, signature []byte) error {
+	if len(h.key) == 0 {
+		return fmt.Errorf("%w: empty secret", ErrInvalidKey)
+	}
+	expected, _ := h.Sign(signingInput)
+	if !hmac.Equal(expected, signature) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidKey)
+	}
+	return nil
+}
+
+// RS256Signer returns a Signer that uses RSASSA-PKCS1-v1_5 SHA-256 with the private key.
+func RS256Signer(key *rsa.PrivateKey) Signer {
+	return &rsaSha256Signer{key: key}
+}
+
+// RS256Verifier returns a Verifier that uses RSASSA-PKCS1-v1_5 SHA-256 with the public key.
+func RS256Verifier(key *rsa.PublicKey) Verifier {
+	return &rsaSha256Verifier{key: key}
+}
+
+type rsaSha256Signer struct {
+	key *rsa.PrivateKey
+}
+
+func (r *rsaSha256Signer) Algorithm() string {
+	return "RS256"
+}
+
+func (r *rsaSha256Signer) Sign(signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	return rsa.SignPKCS1v15(rand.Reader, r.key, crypto.SHA256, digest[:])
+}
+
+type rsaSha256Verifier struct {
+	key *rsa.PublicKey
+}
+
+func (r *rsaSha256Verifier) Algorithm() string {
+	return "RS256"
+}
+
+func (r *rsaSha256Verifier) Verify(signingInput []byte, signature []byte) error {
+	digest := sha256.Sum256(signingInput)
+	if err := rsa.VerifyPKCS1v15(r.key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	return nil
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Encode signs the token with the signer and returns its JWS compact serialization.
+func Encode(token *SecurityEventToken, signer Signer) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: signer.Algorithm(), Type: typSecEventJwt})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+
+	signature, err := signer.Sign(buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(signature))
+	return buf.String(), nil
+}
+
+// Decode verifies the JWS compact serialization with the verifier and returns the parsed token. Any error returned
+// wraps ErrInvalidRequest when the input is malformed, or ErrInvalidKey when the signature cannot be verified.
+func Decode(compact string, verifier Verifier) (*SecurityEventToken, error) {
+	parts := strings.Split(strings.TrimSpace(compact), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed jwt", ErrInvalidRequest)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != verifier.Algorithm() {
+		return nil, fmt.Errorf("%w: unexpected algorithm '%s'", ErrInvalidKey, header.Algorithm)
+	}
+	if len(header.Type) > 0 && header.Type != typSecEventJwt && header.Type != MediaTypeSecEventJwt {
+		return nil, fmt.Errorf("%w: unexpected type '%s'", ErrInvalidRequest, header.Type)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidRequest)
+	}
+	if err := verifier.Verify([]byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	token := new(SecurityEventToken)
+	if err := decodeSegment(parts[1], token); err != nil {
+		return nil, err
+	}
+	if len(token.Events) == 0 {
+		return nil, fmt.Errorf("%w: no events", ErrInvalidRequest)
+	}
+
+	return token, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed jwt segment", ErrInvalidRequest)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w: malformed jwt segment", ErrInvalidRequest)
+	}
+	return nil
+}
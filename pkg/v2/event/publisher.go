@@ -0,0 +1,305 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	scimjson "github.com/imulab/go-scim/pkg/v2/json"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	uuid "github.com/satori/go.uuid"
+)
+
+// number of SETs waiting to be delivered by the service decorators, before further SETs are dropped
+const publishCapacity = 256
+
+// ErrPublishDropped is reported to the OnError callback for the SETs dropped by the service decorators, because too
+// many SETs are waiting to be delivered, or the publisher is closed.
+var ErrPublishDropped = errors.New("event is dropped")
+
+// NewPublisher returns a new Publisher that issues SETs as issuer, signs them with signer and delivers them through
+// transport. By default, the publisher emits full events and discards delivery errors.
+func NewPublisher(issuer string, signer Signer, transport Transport) *Publisher {
+	return &Publisher{
+		issuer:    issuer,
+		signer:    signer,
+		transport: transport,
+		onError:   func(_ *SecurityEventToken, _ error) {},
+		queue:     make(chan *SecurityEventToken, publishCapacity),
+		done:      make(chan struct{}),
+	}
+}
+
+// Publisher signs and delivers SCIM provisioning events. SETs published by the service decorators are delivered one
+// at a time, in the order they were published, so that the receiver applies the changes to a resource in order.
+type Publisher struct {
+	issuer    string
+	audience  []string
+	signer    Signer
+	transport Transport
+	notice    bool
+	onError   func(token *SecurityEventToken, err error)
+	queueMu   sync.RWMutex
+	queue     chan *SecurityEventToken
+	closed    bool
+	startOnce sync.Once
+	done      chan struct{}
+}
+
+// Audience sets the "aud" claim of the SETs issued by this publisher.
+func (p *Publisher) Audience(audience ...string) *Publisher {
+	p.audience = audience
+	return p
+}
+
+// Notice makes the publisher emit notice events, which carries the names of the affected attributes, instead of the
+// full events, which carries the resource or the patch request body.
+func (p *Publisher) Notice() *Publisher {
+	p.notice = true
+	return p
+}
+
+// OnError sets the callback to invoke when a SET published asynchronously by the service decorators failed to be
+// signed or delivered.
+func (p *Publisher) OnError(callback func(token *SecurityEventToken, err error)) *Publisher {
+	p.onError = callback
+	return p
+}
+
+// Publish fills in the "iss", "iat", "jti" and "aud" claims if they are not already set, signs the token and delivers
+// it through the transport.
+func (p *Publisher) Publish(ctx context.Context, token *SecurityEventToken) error {
+	if len(token.Issuer) == 0 {
+		token.Issuer = p.issuer
+	}
+	if token.IssuedAt == 0 {
+		token.IssuedAt = time.Now().Unix()
+	}
+	if len(token.ID) == 0 {
+		token.ID = uuid.NewV4().String()
+	}
+	if len(token.Audience) == 0 {
+		token.Audience = p.audience
+	}
+
+	compact, err := Encode(token, p.signer)
+	if err != nil {
+		return err
+	}
+
+	return p.transport.Deliver(ctx, compact)
+}
+
+// publishAsync queues the token to be published by the delivering go routine without blocking, reporting any error to
+// the onError callback. Tokens are dropped, and reported with ErrPublishDropped, when too many tokens are waiting, or
+// the publisher is closed.
+func (p *Publisher) publishAsync(token *SecurityEventToken) {
+	p.start()
+
+	p.queueMu.RLock()
+	defer p.queueMu.RUnlock()
+	if p.closed {
+		p.onError(token, fmt.Errorf("%w: publisher is closed", ErrPublishDropped))
+		return
+	}
+
+	select {
+	case p.queue <- token:
+	default:
+		p.onError(token, fmt.Errorf("%w: too many events are waiting to be delivered", ErrPublishDropped))
+	}
+}
+
+// start starts the go routine delivering the queued tokens, if not already started.
+func (p *Publisher) start() {
+	p.startOnce.Do(func() {
+		go func() {
+			defer close(p.done)
+			for token := range p.queue {
+				if err := p.Publish(context.Background(), token); err != nil {
+					p.onError(token, err)
+				}
+			}
+		}()
+	})
+}
+
+// Close stops accepting SETs from the service decorators, and waits for the queued SETs to be delivered. SETs published
+// by the service decorators after Close are dropped.
+func (p *Publisher) Close() {
+	p.start()
+
+	p.queueMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.queueMu.Unlock()
+
+	<-p.done
+}
+
+// newToken returns a token with the resource as subject and a single event.
+func (p *Publisher) newToken(resource *prop.Resource, uri string, payload *Payload) *SecurityEventToken {
+	return &SecurityEventToken{
+		Subject: &SubjectID{
+			Format: SubjectFormatScim,
+			URI:    resource.ResourceType().Endpoint() + "/" + resource.IdOrEmpty(),
+		},
+		Events: map[string]*Payload{uri: payload},
+	}
+}
+
+// resourceEvent returns the token for a created or replaced resource.
+func (p *Publisher) resourceEvent(resource *prop.Resource, fullURI string, noticeURI string) (*SecurityEventToken, error) {
+	payload := &Payload{Version: resource.MetaVersionOrEmpty()}
+	if p.notice {
+		payload.Attributes = assignedAttributes(resource)
+		return p.newToken(resource, noticeURI, payload), nil
+	}
+
+	raw, err := scimjson.Serialize(resource)
+	if err != nil {
+		return nil, err
+	}
+	payload.Data = raw
+	return p.newToken(resource, fullURI, payload), nil
+}
+
+// patchEvent returns the token for a patched resource.
+func (p *Publisher) patchEvent(resource *prop.Resource, patchBody []byte) (*SecurityEventToken, error) {
+	payload := &Payload{Version: resource.MetaVersionOrEmpty()}
+	if !p.notice {
+		payload.Data = patchBody
+		return p.newToken(resource, ProvPatchFull, payload), nil
+	}
+
+	var patch service.PatchPayload
+	if err := json.Unmarshal(patchBody, &patch); err != nil {
+		return nil, err
+	}
+	for _, op := range patch.Operations {
+		payload.Attributes = append(payload.Attributes, op.Path)
+	}
+	return p.newToken(resource, ProvPatchNotice, payload), nil
+}
+
+// assignedAttributes returns the names of the top level attributes in the resource that were assigned.
+func assignedAttributes(resource *prop.Resource) []string {
+	names := make([]string, 0)
+	_ = resource.RootProperty().ForEachChild(func(_ int, child prop.Property) error {
+		if !child.IsUnassigned() {
+			names = append(names, child.Attribute().Name())
+		}
+		return nil
+	})
+	return names
+}
+
+// PublishingCreate returns a service.Create that publishes a create event after each successful creation, except for
+// creations applying received events (see Received).
+func PublishingCreate(svc service.Create, publisher *Publisher) service.Create {
+	return &publishingCreate{service: svc, publisher: publisher}
+}
+
+type publishingCreate struct {
+	service   service.Create
+	publisher *Publisher
+}
+
+func (s *publishingCreate) Do(ctx context.Context, req *service.CreateRequest) (resp *service.CreateResponse, err error) {
+	resp, err = s.service.Do(ctx, req)
+	if err != nil || Received(ctx) {
+		return
+	}
+
+	if token, tokenErr := s.publisher.resourceEvent(resp.Resource, ProvCreateFull, ProvCreateNotice); tokenErr != nil {
+		s.publisher.onError(nil, tokenErr)
+	} else {
+		s.publisher.publishAsync(token)
+	}
+	return
+}
+
+// PublishingReplace returns a service.Replace that publishes a put event after each successful replacement, except for
+// replacements applying received events (see Received).
+func PublishingReplace(svc service.Replace, publisher *Publisher) service.Replace {
+	return &publishingReplace{service: svc, publisher: publisher}
+}
+
+type publishingReplace struct {
+	service   service.Replace
+	publisher *Publisher
+}
+
+func (s *publishingReplace) Do(ctx context.Context, req *service.ReplaceRequest) (resp *service.ReplaceResponse, err error) {
+	resp, err = s.service.Do(ctx, req)
+	if err != nil || !resp.Replaced || Received(ctx) {
+		return
+	}
+
+	if token, tokenErr := s.publisher.resourceEvent(resp.Resource, ProvPutFull, ProvPutNotice); tokenErr != nil {
+		s.publisher.onError(nil, tokenErr)
+	} else {
+		s.publisher.publishAsync(token)
+	}
+	return
+}
+
+// PublishingPatch returns a service.Patch that publishes a patch event after each successful patch, except for patches
+// applying received events (see Received). The patch request body is captured as it is read by the underlying service.
+func PublishingPatch(svc service.Patch, publisher *Publisher) service.Patch {
+	return &publishingPatch{service: svc, publisher: publisher}
+}
+
+type publishingPatch struct {
+	service   service.Patch
+	publisher *Publisher
+}
+
+func (s *publishingPatch) Do(ctx context.Context, req *service.PatchRequest) (resp *service.PatchResponse, err error) {
+	var (
+		body   bytes.Buffer
+		teeReq = *req
+	)
+	teeReq.PayloadSource = io.TeeReader(req.PayloadSource, &body)
+
+	resp, err = s.service.Do(ctx, &teeReq)
+	if err != nil || !resp.Patched || Received(ctx) {
+		return
+	}
+
+	if token, tokenErr := s.publisher.patchEvent(resp.Resource, body.Bytes()); tokenErr != nil {
+		s.publisher.onError(nil, tokenErr)
+	} else {
+		s.publisher.publishAsync(token)
+	}
+	return
+}
+
+// PublishingDelete returns a service.Delete that publishes a delete event after each successful deletion, except for
+// deletions applying received events (see Received).
+func PublishingDelete(svc service.Delete, publisher *Publisher) service.Delete {
+	return &publishingDelete{service: svc, publisher: publisher}
+}
+
+type publishingDelete struct {
+	service   service.Delete
+	publisher *Publisher
+}
+
+func (s *publishingDelete) Do(ctx context.Context, req *service.DeleteRequest) (resp *service.DeleteResponse, err error) {
+	resp, err = s.service.Do(ctx, req)
+	if err != nil || Received(ctx) {
+		return
+	}
+
+	s.publisher.publishAsync(s.publisher.newToken(resp.Deleted, ProvDelete, &Payload{}))
+	return
+}
@@ -0,0 +1,321 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/service"
+)
+
+const (
+	// Default maximum age of received SETs, see Receiver.MaxAge
+	defaultMaxAge = 5 * time.Minute
+	// Tolerated clock skew for SETs issued in the future
+	clockSkew = 30 * time.Second
+	// Default maximum number of SET ids remembered to detect replays, see Receiver.MaxTokens
+	defaultMaxTokens = 100000
+)
+
+// NewReceiver returns a new Receiver that verifies incoming SETs with verifier. If issuer is not empty, the "iss" claim
+// must match it; if audience is not empty, the "aud" claim must contain it.
+func NewReceiver(verifier Verifier, issuer string, audience string) *Receiver {
+	return &Receiver{
+		verifier:  verifier,
+		issuer:    issuer,
+		audience:  audience,
+		targets:   map[string]*Target{},
+		maxAge:    defaultMaxAge,
+		maxTokens: defaultMaxTokens,
+		seen:      map[string]*receipt{},
+		now:       time.Now,
+	}
+}
+
+// Receiver verifies incoming SETs and applies the carried provisioning events through the services of the target
+// resource type.
+//
+// To defeat replays, SETs must carry a "jti" claim, and an "iat" claim no older than the maximum age. The ids of the
+// SETs received within the maximum age are remembered, and SETs with an id already seen are rejected. SETs with an
+// "exp" claim in the past are rejected as well. SETs whose events failed to be applied may be delivered again, in which
+// case the events already applied are skipped.
+type Receiver struct {
+	verifier  Verifier
+	issuer    string
+	audience  string
+	targets   map[string]*Target
+	maxAge    time.Duration
+	maxTokens int
+	seenLock  sync.Mutex
+	seen      map[string]*receipt // by SET id
+	now       func() time.Time
+}
+
+// receipt records the reception of a SET.
+type receipt struct {
+	forgetAt  time.Time
+	applied   map[string]struct{} // URIs of the events applied
+	receiving bool                // events are being applied
+	failed    bool                // some event failed to be applied, so the SET may be delivered again
+}
+
+// MaxAge sets the maximum age of received SETs, according to their "iat" claim. Defaults to 5 minutes.
+func (r *Receiver) MaxAge(maxAge time.Duration) *Receiver {
+	r.maxAge = maxAge
+	return r
+}
+
+// MaxTokens sets the maximum number of SET ids remembered to detect replays. When the limit is reached, SETs are
+// rejected until older ids exceed the maximum age. Defaults to 100000.
+func (r *Receiver) MaxTokens(maxTokens int) *Receiver {
+	r.maxTokens = maxTokens
+	return r
+}
+
+// Target is the set of services that the events regarding a resource type are applied through. Services that are left
+// nil causes the corresponding events to be rejected.
+type Target struct {
+	Create  service.Create
+	Replace service.Replace
+	Patch   service.Patch
+	Delete  service.Delete
+}
+
+// Register registers the target services for events whose subject resides under the resource type endpoint (i.e. /Users).
+func (r *Receiver) Register(endpoint string, target *Target) *Receiver {
+	r.targets[endpoint] = target
+	return r
+}
+
+// Receive verifies the SET in JWS compact serialization and applies the events. The full events are applied as follows:
+// prov:create:full creates the resource from the data; prov:put:full replaces the subject resource with the data;
+// prov:patch:full patches the subject resource with the data as patch request body; prov:delete deletes the subject
+// resource. Other events, including all notice events, carry no data to apply and are acknowledged without action.
+//
+// Note that the created resource is assigned an id by the create service, and does not necessarily keep the id in the
+// subject.
+func (r *Receiver) Receive(ctx context.Context, compact string) (*SecurityEventToken, error) {
+	token, err := Decode(compact, r.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.issuer) > 0 && token.Issuer != r.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer '%s'", ErrInvalidIssuer, token.Issuer)
+	}
+	if len(r.audience) > 0 && !r.hasAudience(token) {
+		return nil, fmt.Errorf("%w: expected audience '%s'", ErrInvalidAudience, r.audience)
+	}
+
+	endpoint, err := token.Endpoint()
+	if err != nil {
+		return nil, err
+	}
+	target, ok := r.targets[endpoint]
+	if !ok {
+		return nil, fmt.Errorf("%w: no resource type at '%s'", ErrInvalidRequest, endpoint)
+	}
+
+	rec, err := r.remember(token)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = withReceived(ctx)
+	for _, uri := range sortedEvents(token) {
+		if r.isApplied(rec, uri) {
+			continue
+		}
+		if err := r.apply(ctx, target, token, uri, token.Events[uri]); err != nil {
+			// the transmitter may deliver the SET again after a failure
+			r.finish(rec, true)
+			return nil, err
+		}
+		r.markApplied(rec, uri)
+	}
+
+	r.finish(rec, false)
+	return token, nil
+}
+
+// remember checks the "jti", "iat" and "exp" claims of the token, and records its id, so that it cannot be replayed.
+// SETs that failed before are accepted again, and their receipt is returned to skip the events already applied.
+func (r *Receiver) remember(token *SecurityEventToken) (*receipt, error) {
+	if len(token.ID) == 0 {
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidRequest)
+	}
+
+	now := r.now()
+	issuedAt := time.Unix(token.IssuedAt, 0)
+	if issuedAt.Before(now.Add(-r.maxAge)) || issuedAt.After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: iat is outside of the accepted window", ErrInvalidRequest)
+	}
+	if token.Expiration > 0 && time.Unix(token.Expiration, 0).Before(now) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidRequest)
+	}
+
+	r.seenLock.Lock()
+	defer r.seenLock.Unlock()
+
+	if rec, ok := r.seen[token.ID]; ok {
+		if rec.receiving || !rec.failed {
+			return nil, fmt.Errorf("%w: jti '%s' was already received", ErrInvalidRequest, token.ID)
+		}
+		rec.receiving = true
+		return rec, nil
+	}
+	if len(r.seen) >= r.maxTokens {
+		for id, rec := range r.seen {
+			if rec.forgetAt.Before(now) {
+				delete(r.seen, id)
+			}
+		}
+		if len(r.seen) >= r.maxTokens {
+			return nil, fmt.Errorf("%w: too many events received, try again later", ErrInvalidRequest)
+		}
+	}
+	// tokens issued before now-maxAge are rejected by their "iat" claim, so they need not be remembered any further
+	rec := &receipt{
+		forgetAt:  issuedAt.Add(r.maxAge + clockSkew),
+		applied:   map[string]struct{}{},
+		receiving: true,
+	}
+	r.seen[token.ID] = rec
+	return rec, nil
+}
+
+// isApplied returns true if the event was applied by an earlier delivery of the SET.
+func (r *Receiver) isApplied(rec *receipt, uri string) bool {
+	r.seenLock.Lock()
+	defer r.seenLock.Unlock()
+	_, ok := rec.applied[uri]
+	return ok
+}
+
+// markApplied records that the event was applied, so that it is skipped if the SET is delivered again.
+func (r *Receiver) markApplied(rec *receipt, uri string) {
+	r.seenLock.Lock()
+	rec.applied[uri] = struct{}{}
+	r.seenLock.Unlock()
+}
+
+// finish ends the reception of the SET. If failed, the SET can be delivered again.
+func (r *Receiver) finish(rec *receipt, failed bool) {
+	r.seenLock.Lock()
+	rec.receiving = false
+	rec.failed = failed
+	r.seenLock.Unlock()
+}
+
+// Order in which the events of a single SET are applied, events not listed are applied last.
+var eventOrder = map[string]int{
+	ProvCreateFull:   0,
+	ProvCreateNotice: 0,
+	ProvPutFull:      1,
+	ProvPutNotice:    1,
+	ProvPatchFull:    2,
+	ProvPatchNotice:  2,
+	ProvActivate:     3,
+	ProvDeactivate:   3,
+	ProvDelete:       4,
+}
+
+// sortedEvents returns the event URIs of the token in the order they are applied: creation, replacement, patch,
+// (de)activation, then deletion; events of the same kind, and unknown events, are sorted by URI.
+func sortedEvents(token *SecurityEventToken) []string {
+	uris := make([]string, 0, len(token.Events))
+	for uri := range token.Events {
+		uris = append(uris, uri)
+	}
+	rank := func(uri string) int {
+		if i, ok := eventOrder[uri]; ok {
+			return i
+		}
+		return len(eventOrder)
+	}
+	sort.Slice(uris, func(i, j int) bool {
+		if rank(uris[i]) != rank(uris[j]) {
+			return rank(uris[i]) < rank(uris[j])
+		}
+		return uris[i] < uris[j]
+	})
+	return uris
+}
+
+type receivedKey struct{}
+
+// withReceived marks the context as applying received events.
+func withReceived(ctx context.Context) context.Context {
+	return context.WithValue(ctx, receivedKey{}, true)
+}
+
+// Received returns true if the context is that of applying events received by a Receiver. The publishing services
+// (i.e. PublishingCreate) do not publish the changes made in such contexts, so that peers publishing to each other do
+// not echo events back and forth.
+func Received(ctx context.Context) bool {
+	received, _ := ctx.Value(receivedKey{}).(bool)
+	return received
+}
+
+func (r *Receiver) hasAudience(token *SecurityEventToken) bool {
+	for _, aud := range token.Audience {
+		if aud == r.audience {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Receiver) apply(ctx context.Context, target *Target, token *SecurityEventToken, uri string, payload *Payload) error {
+	if payload == nil {
+		payload = &Payload{}
+	}
+
+	switch uri {
+	case ProvCreateFull, ProvPutFull, ProvPatchFull, ProvDelete:
+	default:
+		return nil
+	}
+
+	id, err := token.ResourceID()
+	if err != nil {
+		return err
+	}
+
+	switch uri {
+	case ProvCreateFull:
+		if target.Create == nil || len(payload.Data) == 0 {
+			return fmt.Errorf("%w: cannot apply '%s'", ErrInvalidRequest, uri)
+		}
+		_, err = target.Create.Do(ctx, &service.CreateRequest{
+			PayloadSource: bytes.NewReader(payload.Data),
+		})
+	case ProvPutFull:
+		if target.Replace == nil || len(payload.Data) == 0 {
+			return fmt.Errorf("%w: cannot apply '%s'", ErrInvalidRequest, uri)
+		}
+		_, err = target.Replace.Do(ctx, &service.ReplaceRequest{
+			ResourceID:    id,
+			PayloadSource: bytes.NewReader(payload.Data),
+		})
+	case ProvPatchFull:
+		if target.Patch == nil || len(payload.Data) == 0 {
+			return fmt.Errorf("%w: cannot apply '%s'", ErrInvalidRequest, uri)
+		}
+		_, err = target.Patch.Do(ctx, &service.PatchRequest{
+			ResourceID:    id,
+			PayloadSource: bytes.NewReader(payload.Data),
+		})
+	case ProvDelete:
+		if target.Delete == nil {
+			return fmt.Errorf("%w: cannot apply '%s'", ErrInvalidRequest, uri)
+		}
+		_, err = target.Delete.Do(ctx, &service.DeleteRequest{
+			ResourceID: id,
+		})
+	}
+	return err
+}
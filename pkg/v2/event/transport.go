@@ -0,0 +1,91 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Transport delivers encoded SETs to the receiver.
+type Transport interface {
+	// Deliver sends the SET in JWS compact serialization to the receiver. Delivery errors reported by the receiver should
+	// be returned as one of the error prototypes in this package (i.e. ErrInvalidKey).
+	Deliver(ctx context.Context, token string) error
+}
+
+// HTTPPush returns a Transport that delivers SETs to the endpoint using push-based delivery over HTTP (RFC8935). If
+// client is nil, a client with a timeout of 10 seconds is used, so that a slow receiver cannot hold up the delivery of
+// further SETs.
+func HTTPPush(endpoint string, client *http.Client) Transport {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &httpPush{endpoint: endpoint, client: client}
+}
+
+type httpPush struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (t *httpPush) Deliver(ctx context.Context, token string) error {
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, strings.NewReader(token))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", MediaTypeSecEventJwt)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		return nil
+	case resp.StatusCode == http.StatusBadRequest:
+		var body deliveryErrorBody
+		raw, _ := ioutil.ReadAll(resp.Body)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			return fmt.Errorf("%w: receiver responded 400", ErrInvalidRequest)
+		}
+		return fmt.Errorf("%w: %s", errorByType(body.Err), body.Description)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%w: receiver responded 401", ErrAuthenticationFailed)
+	case resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: receiver responded 403", ErrAccessDenied)
+	default:
+		return fmt.Errorf("unexpected response status %d from receiver", resp.StatusCode)
+	}
+}
+
+// deliveryErrorBody is the JSON error response body of push-based delivery (RFC8935).
+type deliveryErrorBody struct {
+	Err         string `json:"err"`
+	Description string `json:"description"`
+}
+
+// WriteDeliveryError writes the delivery error response (RFC8935) to http.ResponseWriter. Errors that do not wrap
+// any of the error prototypes in this package are reported as ErrInvalidRequest.
+func WriteDeliveryError(rw http.ResponseWriter, err error) error {
+	body := deliveryErrorBody{
+		Err:         ErrInvalidRequest.Type,
+		Description: err.Error(),
+	}
+	var eventErr *Error
+	if errors.As(err, &eventErr) {
+		body.Err = eventErr.Type
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusBadRequest)
+	return json.NewEncoder(rw).Encode(body)
+}
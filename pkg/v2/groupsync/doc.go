@@ -3,4 +3,11 @@
 // The "groups" attribute of the User resource is a readOnly attribute, which shall be updated according to the change
 // of "members" in Group resources. This package provides mere utilities that may be helpful, it does not assume a
 // certain way to resolve this issue.
+//
+// Changes of membership can be turned into jobs (see JobsFor) and transported through a Queue to a Handler, which
+// refreshes the affected users. WorkerPool is an in process Queue; other transports, such as message brokers, can be
+// plugged in by implementing Queue.
+//
+// MembershipGraph caches the containment graph of groups, to resolve direct and indirect memberships without querying
+// the database for each level of nested groups, and to report membership cycles.
 package groupsync
@@ -0,0 +1,439 @@
+package groupsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// projection of the group attributes kept in the MembershipGraph
+var graphProjection = &crud.Projection{
+	Attributes: []string{"id", "meta.location", "displayName", "members.value"},
+}
+
+// NewMembershipGraph returns a new MembershipGraph of the groups stored in groupDB. The graph is loaded with a single
+// query on first use, and never expires by default.
+func NewMembershipGraph(groupDB db.DB) *MembershipGraph {
+	return &MembershipGraph{groupDB: groupDB}
+}
+
+// MembershipGraph caches the containment graph of groups, to resolve the direct and indirect memberships of a member,
+// or the transitive members of a group, without querying the database level by level. Cyclic memberships are
+// tolerated, each group being visited once, and reported by Cycles.
+//
+// The graph is kept up to date incrementally by Apply, with the Diff of each change to a group: the membership is
+// updated at once, and the other attributes of the changed group are fetched again on next use. As changes made by
+// other processes cannot be applied, the graph can also be set to reload after a maximum age.
+//
+// The graph is loaded, and stale groups fetched, without holding the lock on the graph: one caller loads while the
+// others wait, and changes applied in the meantime are fetched again once loaded.
+type MembershipGraph struct {
+	groupDB db.DB
+	maxAge  time.Duration
+	load    sync.Mutex   // held while loading the graph or fetching stale groups
+	mu      sync.RWMutex // held while reading or updating the graph
+	loaded  time.Time
+	resets  int                            // number of resets, to discard loads started before a reset
+	groups  map[string]*groupNode          // groups by id
+	parents map[string]map[string]struct{} // ids of the groups directly containing each member, by member id
+	stale   map[string]struct{}            // ids of the groups to fetch again
+	changed map[string]struct{}            // ids of the groups changed during a load, nil if not loading
+}
+
+type groupNode struct {
+	id       string
+	display  string
+	location string
+	members  map[string]struct{}
+}
+
+// MaxAge sets the duration after which the graph is loaded again, 0 for never.
+func (g *MembershipGraph) MaxAge(maxAge time.Duration) *MembershipGraph {
+	g.maxAge = maxAge
+	return g
+}
+
+// Apply updates the graph with the diff of the members of the group, after the group was created, replaced or
+// deleted. It has no effect before the graph is loaded.
+func (g *MembershipGraph) Apply(groupID string, diff *Diff) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.changed != nil {
+		g.changed[groupID] = struct{}{}
+	}
+	if g.loaded.IsZero() {
+		return
+	}
+
+	node, ok := g.groups[groupID]
+	if !ok {
+		node = &groupNode{id: groupID, members: map[string]struct{}{}}
+		g.groups[groupID] = node
+	}
+	diff.ForEachLeft(func(id string) {
+		g.unlink(node, id)
+	})
+	diff.ForEachJoined(func(id string) {
+		g.link(node, id)
+	})
+	g.stale[groupID] = struct{}{}
+}
+
+// Invalidate marks the group to be fetched again on next use.
+func (g *MembershipGraph) Invalidate(groupID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.changed != nil {
+		g.changed[groupID] = struct{}{}
+	}
+	if !g.loaded.IsZero() {
+		g.stale[groupID] = struct{}{}
+	}
+}
+
+// Reset drops the graph, to be loaded again on next use.
+func (g *MembershipGraph) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.loaded = time.Time{}
+	g.resets++
+}
+
+// GroupsOf returns the groups the member belongs to, directly or through nested groups. Direct memberships come
+// first, and each group is returned once, sorted by id. A group does not belong to itself, even in a cycle.
+func (g *MembershipGraph) GroupsOf(ctx context.Context, memberID string) ([]*Membership, error) {
+	if err := g.ensure(ctx); err != nil {
+		return nil, err
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var (
+		direct   []*Membership
+		indirect []*Membership
+		visited  = map[string]struct{}{memberID: {}}
+		current  = []string{memberID}
+	)
+	for level := 0; len(current) > 0; level++ {
+		var next []string
+		for _, id := range current {
+			for parentID := range g.parents[id] {
+				if _, ok := visited[parentID]; ok {
+					continue
+				}
+				visited[parentID] = struct{}{}
+				next = append(next, parentID)
+
+				node := g.groups[parentID]
+				m := &Membership{GroupID: node.id, Display: node.display, Ref: node.location}
+				if level == 0 {
+					m.Type = "direct"
+					direct = append(direct, m)
+				} else {
+					m.Type = "indirect"
+					indirect = append(indirect, m)
+				}
+			}
+		}
+		current = next
+	}
+
+	for _, list := range [][]*Membership{direct, indirect} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].GroupID < list[j].GroupID
+		})
+	}
+	return append(direct, indirect...), nil
+}
+
+// MembersOf returns the ids of the effective members of the group, sorted: its members and the members of its nested
+// groups, which are expanded rather than returned. Returns spec.ErrNotFound if the group does not exist.
+func (g *MembershipGraph) MembersOf(ctx context.Context, groupID string) ([]string, error) {
+	if err := g.ensure(ctx); err != nil {
+		return nil, err
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if _, ok := g.groups[groupID]; !ok {
+		return nil, fmt.Errorf("%w: group '%s' is not found", spec.ErrNotFound, groupID)
+	}
+
+	var (
+		members []string
+		visited = map[string]struct{}{groupID: {}}
+		queue   = []string{groupID}
+	)
+	for len(queue) > 0 {
+		node := g.groups[queue[0]]
+		queue = queue[1:]
+		for id := range node.members {
+			if _, ok := visited[id]; ok {
+				continue
+			}
+			visited[id] = struct{}{}
+			if _, isGroup := g.groups[id]; isGroup {
+				queue = append(queue, id)
+			} else {
+				members = append(members, id)
+			}
+		}
+	}
+
+	sort.Strings(members)
+	return members, nil
+}
+
+// Cycles returns the ids of the groups of each membership cycle, where groups are members of each other, directly or
+// through nested groups. Ids are sorted within each cycle, and cycles are sorted by their first id.
+func (g *MembershipGraph) Cycles(ctx context.Context) ([][]string, error) {
+	if err := g.ensure(ctx); err != nil {
+		return nil, err
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	// Tarjan's strongly connected components
+	var (
+		cycles  [][]string
+		index   = map[string]int{}
+		low     = map[string]int{}
+		onStack = map[string]bool{}
+		stack   []string
+		visit   func(id string)
+	)
+	visit = func(id string) {
+		index[id] = len(index)
+		low[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+
+		selfLoop := false
+		for memberID := range g.groups[id].members {
+			if _, isGroup := g.groups[memberID]; !isGroup {
+				continue
+			}
+			if memberID == id {
+				selfLoop = true
+			}
+			if _, visited := index[memberID]; !visited {
+				visit(memberID)
+				if low[memberID] < low[id] {
+					low[id] = low[memberID]
+				}
+			} else if onStack[memberID] && index[memberID] < low[id] {
+				low[id] = index[memberID]
+			}
+		}
+
+		if low[id] == index[id] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			if len(component) > 1 || selfLoop {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(g.groups))
+	for id := range g.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, visited := index[id]; !visited {
+			visit(id)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles, nil
+}
+
+// ensure loads the graph if not loaded or expired, and fetches the stale groups. The database is queried without
+// holding the lock on the graph.
+func (g *MembershipGraph) ensure(ctx context.Context) error {
+	g.mu.RLock()
+	fresh := !g.expired() && len(g.stale) == 0
+	g.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	g.load.Lock()
+	defer g.load.Unlock()
+
+	g.mu.Lock()
+	if g.expired() {
+		resets := g.resets
+		g.changed = map[string]struct{}{}
+		g.mu.Unlock()
+		return g.reload(ctx, resets)
+	}
+	stale := make([]string, 0, len(g.stale))
+	for id := range g.stale {
+		stale = append(stale, id)
+	}
+	// groups changed from now on are stale again, to be fetched on next use
+	g.stale = map[string]struct{}{}
+	g.mu.Unlock()
+
+	fetched := map[string]*prop.Resource{}
+	for _, id := range stale {
+		group, err := g.groupDB.Get(ctx, id, graphProjection)
+		if err != nil && !errors.Is(err, spec.ErrNotFound) {
+			g.mu.Lock()
+			for _, id := range stale {
+				g.stale[id] = struct{}{}
+			}
+			g.mu.Unlock()
+			return err
+		}
+		fetched[id] = group
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, id := range stale {
+		if group := fetched[id]; group != nil {
+			g.put(group)
+		} else {
+			g.remove(id)
+		}
+	}
+	return nil
+}
+
+// expired returns true if the graph is not loaded or older than its maximum age. Caller must hold the lock.
+func (g *MembershipGraph) expired() bool {
+	return g.loaded.IsZero() || (g.maxAge > 0 && time.Since(g.loaded) > g.maxAge)
+}
+
+// reload queries all groups, and replaces the graph with them. Groups changed during the query are marked stale.
+func (g *MembershipGraph) reload(ctx context.Context, resets int) error {
+	groups, err := g.groupDB.Query(ctx, "id pr", nil, nil, graphProjection)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	changed := g.changed
+	g.changed = nil
+	if err != nil {
+		return err
+	}
+
+	g.groups = map[string]*groupNode{}
+	g.parents = map[string]map[string]struct{}{}
+	g.stale = changed
+	for _, group := range groups {
+		g.put(group)
+	}
+	if g.resets == resets {
+		g.loaded = time.Now()
+	}
+	return nil
+}
+
+// put adds or replaces the group in the graph.
+func (g *MembershipGraph) put(group *prop.Resource) {
+	g.remove(group.IdOrEmpty())
+
+	node := &groupNode{
+		id:       group.IdOrEmpty(),
+		location: group.MetaLocationOrEmpty(),
+		members:  map[string]struct{}{},
+	}
+	if display, _ := group.Navigator().Dot("displayName").Current().Raw().(string); len(display) > 0 {
+		node.display = display
+	}
+	g.groups[node.id] = node
+	for _, id := range memberIds(group) {
+		g.link(node, id)
+	}
+}
+
+// remove removes the group and its memberships from the graph. Memberships of the group in other groups are kept, as
+// the other groups still list it.
+func (g *MembershipGraph) remove(groupID string) {
+	node, ok := g.groups[groupID]
+	if !ok {
+		return
+	}
+	for id := range node.members {
+		g.unlink(node, id)
+	}
+	delete(g.groups, groupID)
+}
+
+func (g *MembershipGraph) link(node *groupNode, memberID string) {
+	node.members[memberID] = struct{}{}
+	if g.parents[memberID] == nil {
+		g.parents[memberID] = map[string]struct{}{}
+	}
+	g.parents[memberID][node.id] = struct{}{}
+}
+
+func (g *MembershipGraph) unlink(node *groupNode, memberID string) {
+	delete(node.members, memberID)
+	delete(g.parents[memberID], node.id)
+	if len(g.parents[memberID]) == 0 {
+		delete(g.parents, memberID)
+	}
+}
+
+// GraphDB returns a db.DB that stores group resources in the database, and applies their membership changes to the
+// graph after each successful Insert, Replace and Delete.
+func GraphDB(database db.DB, graph *MembershipGraph) db.DB {
+	return &graphDB{DB: database, graph: graph}
+}
+
+type graphDB struct {
+	db.DB
+	graph *MembershipGraph
+}
+
+func (d *graphDB) Insert(ctx context.Context, resource *prop.Resource) error {
+	if err := d.DB.Insert(ctx, resource); err != nil {
+		return err
+	}
+	d.graph.Apply(resource.IdOrEmpty(), Compare(nil, resource))
+	return nil
+}
+
+func (d *graphDB) Replace(ctx context.Context, ref *prop.Resource, replacement *prop.Resource) error {
+	if err := d.DB.Replace(ctx, ref, replacement); err != nil {
+		return err
+	}
+	d.graph.Apply(ref.IdOrEmpty(), Compare(ref, replacement))
+	return nil
+}
+
+func (d *graphDB) Delete(ctx context.Context, resource *prop.Resource) error {
+	if err := d.DB.Delete(ctx, resource); err != nil {
+		return err
+	}
+	d.graph.Apply(resource.IdOrEmpty(), Compare(resource, nil))
+	return nil
+}
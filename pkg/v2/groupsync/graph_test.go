@@ -0,0 +1,255 @@
+package groupsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingDB counts the queries and gets on the database.
+type countingDB struct {
+	db.DB
+	queries int
+	gets    int
+}
+
+func (d *countingDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error) {
+	d.queries++
+	return d.DB.Query(ctx, filter, sort, pagination, projection)
+}
+
+func (d *countingDB) Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error) {
+	d.gets++
+	return d.DB.Get(ctx, id, projection)
+}
+
+// blockingDB blocks the first query on the database until released.
+type blockingDB struct {
+	*countingDB
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error) {
+	if d.queries == 0 {
+		close(d.started)
+		<-d.release
+	}
+	return d.countingDB.Query(ctx, filter, sort, pagination, projection)
+}
+
+func (s *SyncServiceTestSuite) TestMembershipGraph() {
+	newResource := func(t *testing.T, resourceType *spec.ResourceType, data map[string]interface{}) *prop.Resource {
+		r := prop.NewResource(resourceType)
+		require.False(t, r.Navigator().Replace(data).HasError())
+		return r
+	}
+	newGroup := func(t *testing.T, id string, members ...string) *prop.Resource {
+		var list []interface{}
+		for _, member := range members {
+			list = append(list, map[string]interface{}{"value": member})
+		}
+		return newResource(t, s.groupResourceType, map[string]interface{}{
+			"schemas":     []interface{}{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+			"id":          id,
+			"displayName": id,
+			"members":     list,
+		})
+	}
+	// setup returns g1 and g2 members of each other, g3 member of itself, and g4 containing g2
+	setup := func(t *testing.T) (*countingDB, *MembershipGraph) {
+		groupDB := &countingDB{DB: db.Memory()}
+		for _, group := range []*prop.Resource{
+			newGroup(t, "g1", "u1", "g2"),
+			newGroup(t, "g2", "u2", "g1"),
+			newGroup(t, "g3", "u3", "g3"),
+			newGroup(t, "g4", "g2"),
+		} {
+			require.Nil(t, groupDB.Insert(context.Background(), group))
+		}
+		return groupDB, NewMembershipGraph(groupDB)
+	}
+	groupsOf := func(t *testing.T, graph *MembershipGraph, id string) []string {
+		memberships, err := graph.GroupsOf(context.Background(), id)
+		require.Nil(t, err)
+		var groups []string
+		for _, m := range memberships {
+			groups = append(groups, m.GroupID+"/"+m.Type)
+		}
+		return groups
+	}
+
+	s.T().Run("resolve", func(t *testing.T) {
+		groupDB, graph := setup(t)
+
+		assert.Equal(t, []string{"g1/direct", "g2/indirect", "g4/indirect"}, groupsOf(t, graph, "u1"))
+		assert.Equal(t, []string{"g2/direct", "g1/indirect", "g4/indirect"}, groupsOf(t, graph, "u2"))
+		assert.Equal(t, []string{"g3/direct"}, groupsOf(t, graph, "u3"))
+		assert.Equal(t, []string{"g1/direct", "g4/direct"}, groupsOf(t, graph, "g2"))
+		assert.Empty(t, groupsOf(t, graph, "foo"))
+
+		members, err := graph.MembersOf(context.Background(), "g4")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"u1", "u2"}, members)
+
+		_, err = graph.MembersOf(context.Background(), "foo")
+		assert.True(t, errors.Is(err, spec.ErrNotFound))
+
+		cycles, err := graph.Cycles(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, [][]string{{"g1", "g2"}, {"g3"}}, cycles)
+
+		assert.Equal(t, 1, groupDB.queries)
+		assert.Equal(t, 0, groupDB.gets)
+	})
+
+	s.T().Run("apply", func(t *testing.T) {
+		groupDB, graph := setup(t)
+		database := GraphDB(groupDB, graph)
+		groupsOf(t, graph, "u1")
+
+		ref, err := groupDB.Get(context.Background(), "g1", nil)
+		require.Nil(t, err)
+		require.Nil(t, database.Replace(context.Background(), ref, newGroup(t, "g1", "u4", "g2")))
+		require.Nil(t, database.Insert(context.Background(), newGroup(t, "g5", "u1")))
+		ref, err = groupDB.Get(context.Background(), "g4", nil)
+		require.Nil(t, err)
+		require.Nil(t, database.Delete(context.Background(), ref))
+
+		assert.Equal(t, []string{"g5/direct"}, groupsOf(t, graph, "u1"))
+		assert.Equal(t, []string{"g2/direct", "g1/indirect"}, groupsOf(t, graph, "u2"))
+		assert.Equal(t, []string{"g1/direct", "g2/indirect"}, groupsOf(t, graph, "u4"))
+		_, err = graph.MembersOf(context.Background(), "g4")
+		assert.True(t, errors.Is(err, spec.ErrNotFound))
+
+		memberships, err := graph.GroupsOf(context.Background(), "u1")
+		require.Nil(t, err)
+		assert.Equal(t, "g5", memberships[0].Display)
+
+		assert.Equal(t, 1, groupDB.queries)
+		assert.Equal(t, 2+3, groupDB.gets)
+	})
+
+	s.T().Run("load", func(t *testing.T) {
+		groupDB, _ := setup(t)
+		blocking := &blockingDB{countingDB: groupDB, started: make(chan struct{}), release: make(chan struct{})}
+		graph := NewMembershipGraph(blocking)
+
+		done := make(chan []string)
+		go func() {
+			done <- groupsOf(t, graph, "u1")
+		}()
+		<-blocking.started
+
+		// groups changed while the graph loads are fetched again once loaded
+		applied := make(chan struct{})
+		go func() {
+			graph.Invalidate("g1")
+			close(applied)
+		}()
+		select {
+		case <-applied:
+		case <-time.After(time.Second):
+			t.Fatal("invalidate is blocked by the load of the graph")
+		}
+		close(blocking.release)
+
+		assert.Equal(t, []string{"g1/direct", "g2/indirect", "g4/indirect"}, <-done)
+		assert.Equal(t, []string{"g1/direct", "g2/indirect", "g4/indirect"}, groupsOf(t, graph, "u1"))
+		assert.Equal(t, 1, groupDB.queries)
+		assert.Equal(t, 1, groupDB.gets)
+	})
+
+	s.T().Run("sync", func(t *testing.T) {
+		groupDB, graph := setup(t)
+		user := newResource(t, s.userResourceType, map[string]interface{}{
+			"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+			"id":       "u1",
+			"userName": "u1",
+		})
+		withoutGraph := user.Clone()
+
+		require.Nil(t, NewSyncService(groupDB).UseGraph(graph).SyncGroupPropertyForUser(context.Background(), user))
+		require.Nil(t, NewSyncService(groupDB).SyncGroupPropertyForUser(context.Background(), withoutGraph))
+
+		// each group is listed once, with the same element as without the graph
+		expect := memberships(withoutGraph)
+		delete(expect, "g1/indirect")
+		assert.Len(t, expect, 3)
+		assert.Equal(t, expect, memberships(user))
+	})
+
+	s.T().Run("effective members", func(t *testing.T) {
+		groupDB, graph := setup(t)
+		userDB := db.Memory()
+		for _, id := range []string{"u1", "u2", "u3"} {
+			require.Nil(t, userDB.Insert(context.Background(), newResource(t, s.userResourceType, map[string]interface{}{
+				"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"id":       id,
+				"userName": id,
+			})))
+		}
+		require.Nil(t, groupDB.Insert(context.Background(), newGroup(t, "g5")))
+
+		ctx := context.Background()
+		config := new(spec.ServiceProviderConfig)
+		config.Filter.Supported = true
+		config.Sort.Supported = true
+		query := func(groupID string, filter string) *service.QueryResponse {
+			resp, err := service.QueryService(config, EffectiveMembersDB(userDB, graph, groupID)).
+				Do(ctx, &service.QueryRequest{Filter: filter, Sort: &crud.Sort{By: "id"}})
+			require.Nil(t, err)
+			return resp
+		}
+		idsOf := func(resp *service.QueryResponse) []string {
+			var ids []string
+			for _, r := range resp.Resources {
+				ids = append(ids, r.(*prop.Resource).IdOrEmpty())
+			}
+			return ids
+		}
+
+		resp := query("g4", "")
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Equal(t, []string{"u1", "u2"}, idsOf(resp))
+
+		resp = query("g4", `userName eq "u2"`)
+		assert.Equal(t, 1, resp.TotalResults)
+		assert.Equal(t, []string{"u2"}, idsOf(resp))
+
+		resp = query("g5", "")
+		assert.Equal(t, 0, resp.TotalResults)
+		assert.Empty(t, resp.Resources)
+
+		// the filter cannot escape the restriction to the members
+		_, err := service.QueryService(config, EffectiveMembersDB(userDB, graph, "g4")).
+			Do(ctx, &service.QueryRequest{Filter: "id pr) or (id pr"})
+		assert.True(t, errors.Is(err, spec.ErrInvalidFilter))
+
+		// members are queried in batches, merged, sorted and paginated
+		defer func(n int) { memberBatchSize = n }(memberBatchSize)
+		memberBatchSize = 1
+		resp = query("g4", "")
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Equal(t, []string{"u1", "u2"}, idsOf(resp))
+		resp, err = service.QueryService(config, EffectiveMembersDB(userDB, graph, "g4")).
+			Do(ctx, &service.QueryRequest{Sort: &crud.Sort{By: "id", Order: crud.SortDesc}, Pagination: &crud.Pagination{StartIndex: 2, Count: 5}})
+		require.Nil(t, err)
+		assert.Equal(t, 2, resp.TotalResults)
+		assert.Equal(t, []string{"u1"}, idsOf(resp))
+
+		_, err = EffectiveMembersDB(userDB, graph, "g3").Get(ctx, "u1", nil)
+		assert.True(t, errors.Is(err, spec.ErrNotFound))
+		_, err = EffectiveMembersDB(userDB, graph, "g3").Get(ctx, "u3", nil)
+		assert.Nil(t, err)
+	})
+}
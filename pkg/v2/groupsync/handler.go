@@ -0,0 +1,148 @@
+package groupsync
+
+import (
+	"context"
+	"errors"
+
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// NewJobHandler returns a Handler that refreshes the "groups" property of the user members of the jobs, stored in
+// userDB, according to the groups stored in groupDB. Group members are expanded into their nested members, visiting
+// each group once, so that cyclic memberships do not loop. Members that are neither users nor groups, possibly
+// because they have been deleted since, are skipped.
+//
+// Refreshed users are saved with a new version only if their "groups" property changed, hence jobs can safely be
+// handled more than once.
+func NewJobHandler(userDB db.DB, groupDB db.DB) Handler {
+	return &jobHandler{
+		userDB:      userDB,
+		groupDB:     groupDB,
+		syncService: NewSyncService(groupDB),
+		metaFilter:  filter.MetaFilter(),
+	}
+}
+
+// NewGraphJobHandler returns a Handler like NewJobHandler, which resolves the memberships of users, and expands group
+// members, with the graph instead of querying the groups level by level. As the group of each job may have been
+// changed by another process, it is fetched again by the graph before the job is handled.
+func NewGraphJobHandler(userDB db.DB, graph *MembershipGraph) Handler {
+	return &jobHandler{
+		userDB:      userDB,
+		graph:       graph,
+		syncService: NewSyncService(graph.groupDB).UseGraph(graph),
+		metaFilter:  filter.MetaFilter(),
+	}
+}
+
+type jobHandler struct {
+	userDB      db.DB
+	groupDB     db.DB
+	graph       *MembershipGraph
+	syncService *SyncService
+	metaFilter  filter.ByResource
+}
+
+func (h *jobHandler) Handle(ctx context.Context, job *Job) error {
+	var (
+		members = []string{job.MemberID}
+		visited = map[string]struct{}{}
+	)
+	if h.graph != nil {
+		h.graph.Invalidate(job.GroupID)
+	}
+
+	for len(members) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		id := members[0]
+		members = members[1:]
+		if _, ok := visited[id]; ok {
+			continue
+		}
+		visited[id] = struct{}{}
+
+		user, err := h.userDB.Get(ctx, id, nil)
+		if err == nil {
+			if err := h.syncUser(ctx, user); err != nil {
+				return err
+			}
+			continue
+		} else if !errors.Is(err, spec.ErrNotFound) {
+			return err
+		}
+
+		expanded, err := h.expand(ctx, id)
+		if err == nil {
+			members = append(members, expanded...)
+			continue
+		} else if !errors.Is(err, spec.ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expand returns the ids of the members of the group, or the effective members with the graph. Returns
+// spec.ErrNotFound if the group does not exist.
+func (h *jobHandler) expand(ctx context.Context, groupID string) ([]string, error) {
+	if h.graph != nil {
+		return h.graph.MembersOf(ctx, groupID)
+	}
+	group, err := h.groupDB.Get(ctx, groupID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return memberIds(group), nil
+}
+
+func (h *jobHandler) syncUser(ctx context.Context, ref *prop.Resource) error {
+	user, changed, err := refreshUser(ctx, h.syncService, ref)
+	if err != nil || !changed {
+		return err
+	}
+	return saveUser(ctx, h.userDB, h.metaFilter, ref, user)
+}
+
+// refreshUser returns a copy of the user with its "groups" property refreshed, as databases may hand out the
+// instances they store, and whether the property changed.
+func refreshUser(ctx context.Context, syncService *SyncService, ref *prop.Resource) (*prop.Resource, bool, error) {
+	user := ref.Clone()
+	if err := syncService.SyncGroupPropertyForUser(ctx, user); err != nil {
+		return nil, false, err
+	}
+	return user, user.Hash() != ref.Hash(), nil
+}
+
+// saveUser saves the refreshed user with a new version.
+func saveUser(ctx context.Context, userDB db.DB, metaFilter filter.ByResource, ref *prop.Resource, user *prop.Resource) error {
+	if err := metaFilter.FilterRef(ctx, user, ref); err != nil {
+		return err
+	}
+	return userDB.Replace(ctx, ref, user)
+}
+
+// memberIds returns the ids of the members of the group.
+func memberIds(group *prop.Resource) []string {
+	var ids []string
+	members, _ := group.RootProperty().ChildAtIndex(fieldMembers)
+	if members == nil {
+		return nil
+	}
+	_ = members.ForEachChild(func(index int, child prop.Property) error {
+		value, _ := child.ChildAtIndex(fieldValue)
+		if value != nil && !value.IsUnassigned() {
+			ids = append(ids, value.Raw().(string))
+		}
+		return nil
+	})
+	return ids
+}
@@ -0,0 +1,151 @@
+package groupsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *SyncServiceTestSuite) TestJobHandler() {
+	newResource := func(t *testing.T, resourceType *spec.ResourceType, data map[string]interface{}) *prop.Resource {
+		r := prop.NewResource(resourceType)
+		require.False(t, r.Navigator().Replace(data).HasError())
+		return r
+	}
+	member := func(id string) map[string]interface{} {
+		return map[string]interface{}{"value": id}
+	}
+	// groupsOf returns the groups of the user, in the form of "value/type"
+	groupsOf := func(t *testing.T, userDB db.DB, id string) []string {
+		user, err := userDB.Get(context.Background(), id, nil)
+		require.Nil(t, err)
+		var groups []string
+		_ = user.Navigator().Dot("groups").Current().ForEachChild(func(_ int, child prop.Property) error {
+			value, _ := child.ChildAtIndex("value")
+			typ, _ := child.ChildAtIndex("type")
+			groups = append(groups, value.Raw().(string)+"/"+typ.Raw().(string))
+			return nil
+		})
+		return groups
+	}
+
+	// displaysOf returns the display names of the groups of the user
+	displaysOf := func(t *testing.T, userDB db.DB, id string) []string {
+		user, err := userDB.Get(context.Background(), id, nil)
+		require.Nil(t, err)
+		var displays []string
+		_ = user.Navigator().Dot("groups").Current().ForEachChild(func(_ int, child prop.Property) error {
+			display, _ := child.ChildAtIndex("display")
+			if s, ok := display.Raw().(string); ok {
+				displays = append(displays, s)
+			}
+			return nil
+		})
+		return displays
+	}
+
+	tests := []struct {
+		name   string
+		job    *Job
+		expect func(t *testing.T, userDB db.DB, err error)
+	}{
+		{
+			name: "user member",
+			job:  &Job{GroupID: "g1", MemberID: "u1", Trial: 1},
+			expect: func(t *testing.T, userDB db.DB, err error) {
+				assert.Nil(t, err)
+				assert.Subset(t, groupsOf(t, userDB, "u1"), []string{"g1/direct", "g2/indirect"})
+				assert.Empty(t, groupsOf(t, userDB, "u2"))
+			},
+		},
+		{
+			name: "group member with cyclic membership",
+			job:  &Job{GroupID: "g1", MemberID: "g2", Trial: 1},
+			expect: func(t *testing.T, userDB db.DB, err error) {
+				assert.Nil(t, err)
+				assert.Subset(t, groupsOf(t, userDB, "u1"), []string{"g1/direct", "g2/indirect"})
+				assert.Subset(t, groupsOf(t, userDB, "u2"), []string{"g2/direct", "g1/indirect"})
+			},
+		},
+		{
+			name: "versioned user",
+			job:  &Job{GroupID: "g1", MemberID: "u1", Trial: 1},
+			expect: func(t *testing.T, userDB db.DB, err error) {
+				assert.Nil(t, err)
+				assert.Subset(t, groupsOf(t, userDB, "u1"), []string{"g1/direct", "g2/indirect"})
+				user, err := userDB.Get(context.Background(), "u1", nil)
+				require.Nil(t, err)
+				assert.NotEqual(t, `W/"1"`, user.MetaVersionOrEmpty())
+			},
+		},
+		{
+			name: "deleted member",
+			job:  &Job{GroupID: "g1", MemberID: "foo", Trial: 1},
+			expect: func(t *testing.T, userDB db.DB, err error) {
+				assert.Nil(t, err)
+				assert.Empty(t, groupsOf(t, userDB, "u1"))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for _, withGraph := range []bool{false, true} {
+			name := test.name
+			if withGraph {
+				name += " with graph"
+			}
+			s.T().Run(name, func(t *testing.T) {
+				userDB, groupDB := db.Memory(), db.Memory()
+				for _, id := range []string{"u1", "u2"} {
+					require.Nil(t, userDB.Insert(context.Background(), newResource(t, s.userResourceType, map[string]interface{}{
+						"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+						"id":       id,
+						"userName": id,
+						"meta": map[string]interface{}{
+							"version": `W/"1"`,
+						},
+					})))
+				}
+				for _, g := range []struct {
+					id      string
+					members []interface{}
+				}{
+					{id: "g1", members: []interface{}{member("u1"), member("g2")}},
+					{id: "g2", members: []interface{}{member("u2"), member("g1")}},
+				} {
+					require.Nil(t, groupDB.Insert(context.Background(), newResource(t, s.groupResourceType, map[string]interface{}{
+						"schemas":     []interface{}{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+						"id":          g.id,
+						"displayName": g.id,
+						"members":     g.members,
+					})))
+				}
+
+				handler := NewJobHandler(userDB, groupDB)
+				if withGraph {
+					graph := NewMembershipGraph(groupDB)
+					_, err := graph.Cycles(context.Background())
+					require.Nil(t, err)
+					// the group of the job is changed by another process after the graph is loaded
+					ref, err := groupDB.Get(context.Background(), test.job.GroupID, nil)
+					require.Nil(t, err)
+					replacement := ref.Clone()
+					require.False(t, replacement.Navigator().Dot("displayName").Replace("changed").HasError())
+					require.Nil(t, groupDB.Replace(context.Background(), ref, replacement))
+					handler = NewGraphJobHandler(userDB, graph)
+				}
+
+				err := handler.Handle(context.Background(), test.job)
+				test.expect(t, userDB, err)
+				if withGraph && len(groupsOf(t, userDB, "u1")) > 0 {
+					assert.Contains(t, displaysOf(t, userDB, "u1"), "changed")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package groupsync
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Maximum number of ids restricting a single query of the effective members, see EffectiveMembersDB.
+var memberBatchSize = 100
+
+// EffectiveMembersDB returns a read only db.DB of the users stored in userDB that are effective members of the group,
+// directly or through nested groups, as resolved by the graph. Combined with service.QueryService, it extends the
+// query of users to the effective members of a group, with filters, sorting and pagination: the filter, which must be
+// valid on its own, is restricted to the ids of the effective members, and handed to userDB. Large groups are queried
+// in batches of ids, whose results are merged, sorted and paginated in memory.
+func EffectiveMembersDB(userDB db.DB, graph *MembershipGraph, groupID string) db.DB {
+	return &effectiveMembersDB{userDB: userDB, graph: graph, groupID: groupID}
+}
+
+type effectiveMembersDB struct {
+	userDB  db.DB
+	graph   *MembershipGraph
+	groupID string
+}
+
+func (d *effectiveMembersDB) Count(ctx context.Context, filter string) (int, error) {
+	batches, err := d.restrict(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, restricted := range batches {
+		n, err := d.userDB.Count(ctx, restricted)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (d *effectiveMembersDB) Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error) {
+	members, err := d.graph.MembersOf(ctx, d.groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		if member == id {
+			return d.userDB.Get(ctx, id, projection)
+		}
+	}
+	return nil, fmt.Errorf("%w: user '%s' is not an effective member of group '%s'", spec.ErrNotFound, id, d.groupID)
+}
+
+func (d *effectiveMembersDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error) {
+	batches, err := d.restrict(ctx, filter)
+	if err != nil || len(batches) == 0 {
+		return []*prop.Resource{}, err
+	}
+	if len(batches) == 1 {
+		return d.userDB.Query(ctx, batches[0], sort, pagination, projection)
+	}
+
+	// each batch returns no more than the end of the page, which is then taken from the merged results
+	var batchPagination *crud.Pagination
+	if pagination != nil {
+		batchPagination = &crud.Pagination{StartIndex: 1, Count: pageStart(pagination) + pagination.Count}
+	}
+	results := make([]*prop.Resource, 0)
+	for _, restricted := range batches {
+		resources, err := d.userDB.Query(ctx, restricted, sort, batchPagination, projection)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, resources...)
+	}
+	if sort != nil {
+		if err := sort.Sort(results); err != nil {
+			return nil, err
+		}
+	}
+
+	if pagination != nil {
+		lb, ub := pageStart(pagination), pageStart(pagination)+pagination.Count
+		if lb > len(results) {
+			lb = len(results)
+		}
+		if ub > len(results) {
+			ub = len(results)
+		}
+		results = results[lb:ub]
+	}
+	return results, nil
+}
+
+// pageStart returns the 0-based index of the first resource of the page.
+func pageStart(pagination *crud.Pagination) int {
+	if pagination.StartIndex < 1 {
+		return 0
+	}
+	return pagination.StartIndex - 1
+}
+
+func (d *effectiveMembersDB) Insert(_ context.Context, _ *prop.Resource) error {
+	return d.readOnly()
+}
+
+func (d *effectiveMembersDB) Replace(_ context.Context, _ *prop.Resource, _ *prop.Resource) error {
+	return d.readOnly()
+}
+
+func (d *effectiveMembersDB) Delete(_ context.Context, _ *prop.Resource) error {
+	return d.readOnly()
+}
+
+func (d *effectiveMembersDB) readOnly() error {
+	return fmt.Errorf("%w: effective members of group '%s' are read only", spec.ErrMutability, d.groupID)
+}
+
+// restrict returns the filter restricted to each batch of effective members of the group, or none if the group has
+// no members. The filter is compiled on its own first, so that it cannot escape the restriction, i.e. with unbalanced
+// parenthesis.
+func (d *effectiveMembersDB) restrict(ctx context.Context, filter string) ([]string, error) {
+	if len(filter) > 0 {
+		if _, err := expr.CompileFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+
+	members, err := d.graph.MembersOf(ctx, d.groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var batches []string
+	for len(members) > 0 {
+		n := memberBatchSize
+		if n > len(members) {
+			n = len(members)
+		}
+		restricted := "(" + anyID(members[:n]) + ")"
+		if len(filter) > 0 {
+			restricted += " and (" + filter + ")"
+		}
+		batches = append(batches, restricted)
+		members = members[n:]
+	}
+	return batches, nil
+}
+
+// anyID returns the filter matching any of the ids. Terms are grouped in a balanced tree, rather than chained, to
+// keep the compiled filter shallow for databases limiting its depth.
+func anyID(ids []string) string {
+	if len(ids) == 1 {
+		return fmt.Sprintf("id eq %s", strconv.Quote(ids[0]))
+	}
+	half := len(ids) / 2
+	return "(" + anyID(ids[:half]) + ") or (" + anyID(ids[half:]) + ")"
+}
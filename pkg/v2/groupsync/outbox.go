@@ -0,0 +1,177 @@
+package groupsync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Outbox stores the jobs for the membership changes of groups, until they are relayed to a Queue. Storing the outbox
+// in the same database as the groups keeps the jobs as durable as the changes themselves.
+//
+// Entries are put before the change is saved, and confirmed after, so that jobs are not relayed before the change is
+// visible. Entries left unconfirmed, as the process exited in between, are relayed once they are old enough.
+type Outbox interface {
+	// Put records the entries, unconfirmed.
+	Put(ctx context.Context, entries []*OutboxEntry) error
+	// Confirm marks the entries with the ids as confirmed.
+	Confirm(ctx context.Context, ids ...string) error
+	// Pending returns at most limit entries, oldest first, which are confirmed or were created before the time.
+	Pending(ctx context.Context, limit int, createdBefore time.Time) ([]*OutboxEntry, error)
+	// Remove deletes the entries with the ids.
+	Remove(ctx context.Context, ids ...string) error
+}
+
+// OutboxEntry is a job stored in the Outbox.
+type OutboxEntry struct {
+	ID        string
+	Job       *Job
+	Created   time.Time
+	Confirmed bool
+}
+
+// NewOutboxEntries returns new unconfirmed entries for the jobs, created now.
+func NewOutboxEntries(jobs []*Job) []*OutboxEntry {
+	now := time.Now()
+	entries := make([]*OutboxEntry, 0, len(jobs))
+	for _, job := range jobs {
+		entries = append(entries, &OutboxEntry{
+			ID:      uuid.NewV4().String(),
+			Job:     job,
+			Created: now,
+		})
+	}
+	return entries
+}
+
+// MemoryOutbox returns an Outbox kept in memory, for in memory databases.
+func MemoryOutbox() Outbox {
+	return &memoryOutbox{entries: map[string]*OutboxEntry{}}
+}
+
+type memoryOutbox struct {
+	sync.Mutex
+	entries map[string]*OutboxEntry
+	seq     []string
+}
+
+func (o *memoryOutbox) Put(_ context.Context, entries []*OutboxEntry) error {
+	o.Lock()
+	defer o.Unlock()
+	for _, entry := range entries {
+		clone := *entry
+		o.entries[entry.ID] = &clone
+		o.seq = append(o.seq, entry.ID)
+	}
+	return nil
+}
+
+func (o *memoryOutbox) Confirm(_ context.Context, ids ...string) error {
+	o.Lock()
+	defer o.Unlock()
+	for _, id := range ids {
+		if entry, ok := o.entries[id]; ok {
+			entry.Confirmed = true
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) Pending(_ context.Context, limit int, createdBefore time.Time) ([]*OutboxEntry, error) {
+	o.Lock()
+	defer o.Unlock()
+	var entries []*OutboxEntry
+	for _, id := range o.seq {
+		if len(entries) == limit {
+			break
+		}
+		if entry := o.entries[id]; entry.Confirmed || entry.Created.Before(createdBefore) {
+			clone := *entry
+			entries = append(entries, &clone)
+		}
+	}
+	return entries, nil
+}
+
+func (o *memoryOutbox) Remove(_ context.Context, ids ...string) error {
+	o.Lock()
+	defer o.Unlock()
+	for _, id := range ids {
+		delete(o.entries, id)
+	}
+	seq := o.seq[:0]
+	for _, id := range o.seq {
+		if _, ok := o.entries[id]; ok {
+			seq = append(seq, id)
+		}
+	}
+	o.seq = seq
+	return nil
+}
+
+// OutboxDB returns a db.DB that stores group resources in the database, and puts the jobs for their membership changes
+// in the outbox before each Insert, Replace and Delete. As jobs only refresh users to the latest state of the groups,
+// a job put for a change that eventually failed is harmless, whereas a change is never saved without its jobs, even
+// when the process exits before confirming or relaying them. The relay, if not nil, is notified after each change with
+// jobs.
+func OutboxDB(database db.DB, outbox Outbox, relay *Relay) db.DB {
+	return &outboxDB{DB: database, outbox: outbox, relay: relay}
+}
+
+type outboxDB struct {
+	db.DB
+	outbox Outbox
+	relay  *Relay
+}
+
+func (d *outboxDB) Insert(ctx context.Context, resource *prop.Resource) error {
+	return d.change(ctx, resource.IdOrEmpty(), Compare(nil, resource), func() error {
+		return d.DB.Insert(ctx, resource)
+	})
+}
+
+func (d *outboxDB) Replace(ctx context.Context, ref *prop.Resource, replacement *prop.Resource) error {
+	return d.change(ctx, ref.IdOrEmpty(), Compare(ref, replacement), func() error {
+		return d.DB.Replace(ctx, ref, replacement)
+	})
+}
+
+func (d *outboxDB) Delete(ctx context.Context, resource *prop.Resource) error {
+	return d.change(ctx, resource.IdOrEmpty(), Compare(resource, nil), func() error {
+		return d.DB.Delete(ctx, resource)
+	})
+}
+
+// change puts the jobs for the diff in the outbox, saves the change, and then confirms the jobs, or removes them if
+// the change failed. Failures to confirm or remove the jobs are not reported, as the change is already saved or
+// failed, and the jobs are relayed later anyway.
+func (d *outboxDB) change(ctx context.Context, groupID string, diff *Diff, save func() error) error {
+	jobs := JobsFor(groupID, diff)
+	if len(jobs) == 0 {
+		return save()
+	}
+
+	entries := NewOutboxEntries(jobs)
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	if err := d.outbox.Put(ctx, entries); err != nil {
+		return err
+	}
+
+	if err := save(); err != nil {
+		_ = d.outbox.Remove(ctx, ids...)
+		return err
+	}
+
+	_ = d.outbox.Confirm(ctx, ids...)
+	if d.relay != nil {
+		d.relay.Notify()
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package groupsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryOutbox(t *testing.T) {
+	outbox := MemoryOutbox()
+	entries := NewOutboxEntries([]*Job{
+		{GroupID: "g1", MemberID: "u1", Trial: 1},
+		{GroupID: "g1", MemberID: "u2", Trial: 1},
+		{GroupID: "g1", MemberID: "u3", Trial: 1},
+	})
+	require.Nil(t, outbox.Put(context.Background(), entries))
+
+	pending, err := outbox.Pending(context.Background(), 10, entries[0].Created)
+	assert.Nil(t, err)
+	assert.Len(t, pending, 0)
+
+	require.Nil(t, outbox.Confirm(context.Background(), entries[2].ID, entries[1].ID))
+	pending, err = outbox.Pending(context.Background(), 1, entries[0].Created)
+	assert.Nil(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "u2", pending[0].Job.MemberID)
+	}
+
+	pending, err = outbox.Pending(context.Background(), 10, entries[0].Created.Add(time.Second))
+	assert.Nil(t, err)
+	assert.Len(t, pending, 3)
+
+	require.Nil(t, outbox.Remove(context.Background(), entries[0].ID, entries[1].ID))
+	pending, err = outbox.Pending(context.Background(), 10, entries[0].Created.Add(time.Second))
+	assert.Nil(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "u3", pending[0].Job.MemberID)
+	}
+}
+
+func (s *SyncServiceTestSuite) TestOutboxDB() {
+	newGroup := func(t *testing.T, id string, members ...string) *prop.Resource {
+		var data []interface{}
+		for _, member := range members {
+			data = append(data, map[string]interface{}{"value": member})
+		}
+		r := prop.NewResource(s.groupResourceType)
+		require.False(t, r.Navigator().Replace(map[string]interface{}{
+			"schemas":     []interface{}{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+			"id":          id,
+			"displayName": id,
+			"members":     data,
+		}).HasError())
+		return r
+	}
+	pendingMembers := func(t *testing.T, outbox Outbox) []string {
+		pending, err := outbox.Pending(context.Background(), 10, time.Time{})
+		require.Nil(t, err)
+		var members []string
+		for _, entry := range pending {
+			members = append(members, entry.Job.MemberID)
+		}
+		return members
+	}
+
+	s.T().Run("jobs are confirmed after changes", func(t *testing.T) {
+		outbox := MemoryOutbox()
+		relay := NewRelay(outbox, nil)
+		database := OutboxDB(db.Memory(), outbox, relay)
+
+		g1 := newGroup(t, "g1", "u1", "u2")
+		require.Nil(t, database.Insert(context.Background(), g1))
+		assert.Equal(t, []string{"u1", "u2"}, pendingMembers(t, outbox))
+		assert.Len(t, relay.notify, 1)
+
+		g1Replaced := newGroup(t, "g1", "u2", "u3")
+		require.Nil(t, database.Replace(context.Background(), g1, g1Replaced))
+		assert.Equal(t, []string{"u1", "u2", "u1", "u3"}, pendingMembers(t, outbox))
+
+		require.Nil(t, database.Delete(context.Background(), g1Replaced))
+		assert.Equal(t, []string{"u1", "u2", "u1", "u3", "u2", "u3"}, pendingMembers(t, outbox))
+	})
+
+	s.T().Run("jobs are removed after failed changes", func(t *testing.T) {
+		outbox := MemoryOutbox()
+		database := OutboxDB(db.Memory(), outbox, nil)
+
+		require.Nil(t, database.Insert(context.Background(), newGroup(t, "g1", "u1")))
+		assert.NotNil(t, database.Insert(context.Background(), newGroup(t, "g1", "u2")))
+		assert.Equal(t, []string{"u1"}, pendingMembers(t, outbox))
+
+		pending, err := outbox.Pending(context.Background(), 10, time.Now().Add(time.Second))
+		assert.Nil(t, err)
+		assert.Len(t, pending, 1)
+	})
+}
+
+func TestRelay(t *testing.T) {
+	outbox := MemoryOutbox()
+	entries := NewOutboxEntries([]*Job{
+		{GroupID: "g1", MemberID: "u1", Trial: 1},
+		{GroupID: "g1", MemberID: "u2", Trial: 1},
+		{GroupID: "g1", MemberID: "u3", Trial: 1},
+	})
+	require.Nil(t, outbox.Put(context.Background(), entries))
+	require.Nil(t, outbox.Confirm(context.Background(), entries[0].ID, entries[1].ID, entries[2].ID))
+
+	var submitted []string
+	queue := &queueFunc{submit: func(job *Job) error {
+		if job.MemberID == "u2" && len(submitted) == 1 {
+			submitted = append(submitted, "failed")
+			return errors.New("failed")
+		}
+		submitted = append(submitted, job.MemberID)
+		return nil
+	}}
+	relay := NewRelay(outbox, queue)
+
+	n, err := relay.Relay(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = relay.Relay(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"u1", "failed", "u2", "u3"}, submitted)
+
+	pending, err := outbox.Pending(context.Background(), 10, time.Now().Add(time.Second))
+	assert.Nil(t, err)
+	assert.Len(t, pending, 0)
+}
+
+// queueFunc is a Queue submitting jobs with a function.
+type queueFunc struct {
+	submit func(job *Job) error
+}
+
+func (q *queueFunc) Submit(_ context.Context, job *Job) error {
+	return q.submit(job)
+}
+
+func (q *queueFunc) Consume(_ context.Context, _ Handler) (<-chan struct{}, error) {
+	return nil, errors.New("not supported")
+}
@@ -0,0 +1,143 @@
+package groupsync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// NewWorkerPool returns a new WorkerPool handling jobs with the number of concurrent workers, which is at least one.
+// By default, each job is tried 3 times, with an initial interval of 1 second between the trials, which doubles after
+// each failure up to 1 minute.
+func NewWorkerPool(workers int) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &WorkerPool{
+		workers:     workers,
+		trialLimit:  3,
+		interval:    time.Second,
+		maxInterval: time.Minute,
+		onError:     func(_ *Job, _ error) {},
+		busy:        map[string]struct{}{},
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// WorkerPool is an in process Queue, for applications without message brokers. Jobs are kept in memory until they are
+// handled, and are lost when the process exits. Jobs of the same member are never handled concurrently, so that the
+// refreshes of a user do not overwrite each other.
+type WorkerPool struct {
+	workers     int
+	trialLimit  int
+	interval    time.Duration
+	maxInterval time.Duration
+	onError     func(job *Job, err error)
+	mu          sync.Mutex
+	cond        *sync.Cond
+	pending     []*Job
+	busy        map[string]struct{}
+	consuming   bool
+	stopped     bool
+}
+
+// Retry sets the number of times each job is tried (0 for unlimited), and the initial interval before a failed job is
+// tried again, which doubles after each failure up to maxInterval.
+func (p *WorkerPool) Retry(trialLimit int, interval time.Duration, maxInterval time.Duration) *WorkerPool {
+	p.trialLimit = trialLimit
+	p.interval = interval
+	p.maxInterval = maxInterval
+	return p
+}
+
+// OnError sets the callback to invoke when a job is dropped after it failed to be handled within the trial limit.
+func (p *WorkerPool) OnError(callback func(job *Job, err error)) *WorkerPool {
+	p.onError = callback
+	return p
+}
+
+func (p *WorkerPool) Submit(_ context.Context, job *Job) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clone := *job
+	p.pending = append(p.pending, &clone)
+	p.cond.Broadcast()
+	return nil
+}
+
+func (p *WorkerPool) Consume(ctx context.Context, handler Handler) (<-chan struct{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.consuming {
+		return nil, errors.New("worker pool is already consumed")
+	}
+	p.consuming = true
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := p.next(); job != nil; job = p.next() {
+				p.done(ctx, job, handler.Handle(ctx, job))
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		p.stopped = true
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}()
+
+	safeExit := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(safeExit)
+	}()
+	return safeExit, nil
+}
+
+// next blocks until a pending job, whose member is not being handled by other workers, is available, and returns it;
+// or returns nil when the pool is stopped.
+func (p *WorkerPool) next() *Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for !p.stopped {
+		for i, job := range p.pending {
+			if _, ok := p.busy[job.MemberID]; ok {
+				continue
+			}
+			p.pending = append(p.pending[:i], p.pending[i+1:]...)
+			p.busy[job.MemberID] = struct{}{}
+			return job
+		}
+		p.cond.Wait()
+	}
+	return nil
+}
+
+// done releases the member of the handled job, and schedules the job to be tried again if it failed within the trial
+// limit.
+func (p *WorkerPool) done(ctx context.Context, job *Job, err error) {
+	p.mu.Lock()
+	delete(p.busy, job.MemberID)
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	if err == nil {
+		return
+	}
+	job.Retry()
+	if job.ExceededTrialLimit(p.trialLimit) {
+		p.onError(job, err)
+		return
+	}
+	time.AfterFunc(Backoff(job.Trial, p.interval, p.maxInterval), func() {
+		_ = p.Submit(ctx, job)
+	})
+}
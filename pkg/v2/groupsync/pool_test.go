@@ -0,0 +1,121 @@
+package groupsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Run("bounded concurrency and one job per member at a time", func(t *testing.T) {
+		var (
+			mu        sync.Mutex
+			running   = 0
+			maxRun    = 0
+			members   = map[string]bool{}
+			handled   = 0
+			conflicts = 0
+		)
+		pool := NewWorkerPool(3)
+		for i := 0; i < 30; i++ {
+			require.Nil(t, pool.Submit(context.Background(), &Job{GroupID: "g", MemberID: fmt.Sprintf("m%d", i%5), Trial: 1}))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		safeExit, err := pool.Consume(ctx, HandlerFunc(func(_ context.Context, job *Job) error {
+			mu.Lock()
+			running++
+			if running > maxRun {
+				maxRun = running
+			}
+			if members[job.MemberID] {
+				conflicts++
+			}
+			members[job.MemberID] = true
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			members[job.MemberID] = false
+			handled++
+			mu.Unlock()
+			return nil
+		}))
+		require.Nil(t, err)
+
+		waitUntil(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return handled == 30
+		})
+		cancel()
+		<-safeExit
+
+		assert.LessOrEqual(t, maxRun, 3)
+		assert.Equal(t, 0, conflicts)
+	})
+
+	t.Run("retry within trial limit", func(t *testing.T) {
+		var (
+			mu     sync.Mutex
+			trials []int
+			failed *Job
+		)
+		pool := NewWorkerPool(1).Retry(3, time.Millisecond, 2*time.Millisecond).OnError(func(job *Job, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = job
+		})
+		require.Nil(t, pool.Submit(context.Background(), &Job{GroupID: "g", MemberID: "u", Trial: 1}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err := pool.Consume(ctx, HandlerFunc(func(_ context.Context, job *Job) error {
+			mu.Lock()
+			defer mu.Unlock()
+			trials = append(trials, job.Trial)
+			return errors.New("failed")
+		}))
+		require.Nil(t, err)
+
+		waitUntil(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return failed != nil
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []int{1, 2, 3}, trials)
+		assert.Equal(t, 4, failed.Trial)
+	})
+
+	t.Run("consume once", func(t *testing.T) {
+		pool := NewWorkerPool(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		safeExit, err := pool.Consume(ctx, HandlerFunc(func(_ context.Context, _ *Job) error { return nil }))
+		require.Nil(t, err)
+		_, err = pool.Consume(ctx, HandlerFunc(func(_ context.Context, _ *Job) error { return nil }))
+		assert.NotNil(t, err)
+		cancel()
+		<-safeExit
+	})
+}
+
+// waitUntil polls the condition until it holds, or fails the test after a second.
+func waitUntil(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			require.FailNow(t, "condition never satisfied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
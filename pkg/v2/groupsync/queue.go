@@ -0,0 +1,84 @@
+package groupsync
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Job asks to refresh the "groups" property of the member of a group, after the member joined or left the group. When
+// the member is itself a group, the "groups" property of its nested user members is refreshed instead.
+type Job struct {
+	GroupID  string `json:"group_id"`
+	MemberID string `json:"member_id"`
+	Trial    int    `json:"trial"`
+}
+
+// Retry increments Trial by one
+func (j *Job) Retry() {
+	j.Trial++
+}
+
+// Fields returns the structure fields in a map, for easy logging.
+func (j *Job) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"groupId":  j.GroupID,
+		"memberId": j.MemberID,
+		"trial":    j.Trial,
+	}
+}
+
+// ExceededTrialLimit returns true if Trial is greater than limit, given limit is positive.
+func (j *Job) ExceededTrialLimit(limit int) bool {
+	return limit > 0 && j.Trial > limit
+}
+
+// JobsFor returns the jobs for the members that joined or left the group according to the diff, sorted by member id.
+func JobsFor(groupID string, diff *Diff) []*Job {
+	jobs := make([]*Job, 0, diff.CountLeft()+diff.CountJoined())
+	for _, each := range []func(callback func(id string)){diff.ForEachLeft, diff.ForEachJoined} {
+		each(func(id string) {
+			jobs = append(jobs, &Job{GroupID: groupID, MemberID: id, Trial: 1})
+		})
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].MemberID < jobs[j].MemberID
+	})
+	return jobs
+}
+
+// Backoff returns the delay before the trial of a job: the interval doubles after each failed trial, up to maxInterval.
+func Backoff(trial int, interval time.Duration, maxInterval time.Duration) time.Duration {
+	delay := interval
+	for i := 2; i < trial && delay < maxInterval; i++ {
+		delay *= 2
+	}
+	if delay > maxInterval {
+		delay = maxInterval
+	}
+	return delay
+}
+
+// Handler processes the jobs consumed from a Queue.
+type Handler interface {
+	// Handle processes the job. A non-nil error asks the queue to retry the job later.
+	Handle(ctx context.Context, job *Job) error
+}
+
+// HandlerFunc is an adapter to allow the use of ordinary functions as Handler.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+func (f HandlerFunc) Handle(ctx context.Context, job *Job) error {
+	return f(ctx, job)
+}
+
+// Queue transports jobs from the services changing group memberships to the handler refreshing user resources. The
+// transport may be in process, or through message brokers.
+type Queue interface {
+	// Submit enqueues the job, to be handled by the consumer of the queue. It returns only once the job is safely
+	// enqueued, as the job may be discarded by the caller then, i.e. removed from the Outbox by the Relay.
+	Submit(ctx context.Context, job *Job) error
+	// Consume starts to handle the enqueued jobs with the handler, until ctx is cancelled. Jobs failed to be handled are
+	// retried within the trial limit of the queue. The returned channel is closed once the consumption has stopped.
+	Consume(ctx context.Context, handler Handler) (<-chan struct{}, error)
+}
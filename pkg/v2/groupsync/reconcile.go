@@ -0,0 +1,240 @@
+package groupsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+)
+
+// ReconcileReport summarizes the outcome of a reconciliation.
+type ReconcileReport struct {
+	Checked int              // number of users checked
+	Fixes   []*Fix           // users whose "groups" property was fixed, or would be in dry run
+	Failed  map[string]error // errors keyed by the id of the user that failed to be checked or fixed
+	Cursor  string           // id of the last user checked, to resume from
+	Cycles  [][]string       // ids of the groups of each membership cycle
+}
+
+// Fix is the change to the "groups" property of a user.
+type Fix struct {
+	UserID  string        `json:"userId"`
+	Added   []*Membership `json:"added,omitempty"`
+	Removed []*Membership `json:"removed,omitempty"`
+	Updated []*Membership `json:"updated,omitempty"`
+}
+
+// Membership is an element of the "groups" property of a user.
+type Membership struct {
+	GroupID string `json:"groupId"`
+	Type    string `json:"type"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// NewReconciler returns a new Reconciler refreshing users stored in userDB according to the groups stored in groupDB.
+// By default, users are checked in batches of 100, without rate limit.
+func NewReconciler(userDB db.DB, groupDB db.DB) *Reconciler {
+	graph := NewMembershipGraph(groupDB)
+	return &Reconciler{
+		userDB:      userDB,
+		graph:       graph,
+		syncService: NewSyncService(groupDB).UseGraph(graph),
+		metaFilter:  filter.MetaFilter(),
+		batchSize:   100,
+		onBatch:     func(_ *ReconcileReport) {},
+	}
+}
+
+// Reconciler recomputes the "groups" property of every user, including indirect memberships, to fix the drift left by
+// lost group sync jobs, or by groups modified directly in the database. Only users whose "groups" property changed
+// are saved.
+//
+// Groups are loaded once into a MembershipGraph, so users are checked without querying groups; changes made to groups
+// during the reconciliation are fixed by the group sync jobs they produce.
+type Reconciler struct {
+	userDB      db.DB
+	graph       *MembershipGraph
+	syncService *SyncService
+	metaFilter  filter.ByResource
+	batchSize   int
+	rate        float64
+	dryRun      bool
+	cursor      string
+	onBatch     func(report *ReconcileReport)
+}
+
+// BatchSize sets the number of users queried at a time.
+func (r *Reconciler) BatchSize(batchSize int) *Reconciler {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	r.batchSize = batchSize
+	return r
+}
+
+// RateLimit sets the maximum number of users checked per second, 0 for unlimited.
+func (r *Reconciler) RateLimit(usersPerSecond float64) *Reconciler {
+	r.rate = usersPerSecond
+	return r
+}
+
+// DryRun sets whether to only report the fixes, without saving them.
+func (r *Reconciler) DryRun(dryRun bool) *Reconciler {
+	r.dryRun = dryRun
+	return r
+}
+
+// Resume sets the cursor of a previous reconciliation to resume from. Users are checked in the order of their ids, so
+// only users whose id is greater than the cursor are checked.
+func (r *Reconciler) Resume(cursor string) *Reconciler {
+	r.cursor = cursor
+	return r
+}
+
+// OnBatch sets the callback to invoke with the report so far after each batch, i.e. to save the cursor.
+func (r *Reconciler) OnBatch(callback func(report *ReconcileReport)) *Reconciler {
+	r.onBatch = callback
+	return r
+}
+
+// Reconcile checks the users in batches, and returns the report. When the context is cancelled, the report so far is
+// returned along with the error, and its cursor can be used to resume. An error is only returned when the process
+// cannot proceed, for instance, when the database cannot be queried; users failed to be checked or fixed are recorded
+// in the report.
+func (r *Reconciler) Reconcile(ctx context.Context) (*ReconcileReport, error) {
+	report := &ReconcileReport{Failed: map[string]error{}, Cursor: r.cursor}
+
+	cycles, err := r.graph.Cycles(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Cycles = cycles
+
+	var throttle <-chan time.Time
+	if r.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	for {
+		users, err := r.userDB.Query(ctx, r.filter(report.Cursor), &crud.Sort{By: "id", Order: crud.SortAsc},
+			&crud.Pagination{StartIndex: 1, Count: r.batchSize}, nil)
+		if err != nil {
+			return report, err
+		}
+		if len(users) == 0 {
+			return report, nil
+		}
+
+		for _, user := range users {
+			if throttle != nil {
+				select {
+				case <-ctx.Done():
+					return report, ctx.Err()
+				case <-throttle:
+				}
+			} else {
+				select {
+				case <-ctx.Done():
+					return report, ctx.Err()
+				default:
+				}
+			}
+
+			if fix, err := r.reconcile(ctx, user); err != nil {
+				report.Failed[user.IdOrEmpty()] = err
+			} else if fix != nil {
+				report.Fixes = append(report.Fixes, fix)
+			}
+			report.Checked++
+			report.Cursor = user.IdOrEmpty()
+		}
+
+		r.onBatch(report)
+		if len(users) < r.batchSize {
+			return report, nil
+		}
+	}
+}
+
+// filter returns the filter for the users after the cursor.
+func (r *Reconciler) filter(cursor string) string {
+	if len(cursor) == 0 {
+		return "id pr"
+	}
+	return fmt.Sprintf("id gt %s", strconv.Quote(cursor))
+}
+
+// reconcile refreshes the user, and returns the fix, or nil if the user is up to date.
+func (r *Reconciler) reconcile(ctx context.Context, ref *prop.Resource) (*Fix, error) {
+	user, changed, err := refreshUser(ctx, r.syncService, ref)
+	if err != nil || !changed {
+		return nil, err
+	}
+
+	fix := &Fix{UserID: ref.IdOrEmpty()}
+	before, after := memberships(ref), memberships(user)
+	for k, m := range after {
+		if b, ok := before[k]; !ok {
+			fix.Added = append(fix.Added, m)
+		} else if *b != *m {
+			fix.Updated = append(fix.Updated, m)
+		}
+	}
+	for k, m := range before {
+		if _, ok := after[k]; !ok {
+			fix.Removed = append(fix.Removed, m)
+		}
+	}
+	for _, list := range [][]*Membership{fix.Added, fix.Removed, fix.Updated} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].GroupID == list[j].GroupID {
+				return list[i].Type < list[j].Type
+			}
+			return list[i].GroupID < list[j].GroupID
+		})
+	}
+
+	if r.dryRun {
+		return fix, nil
+	}
+	if err := saveUser(ctx, r.userDB, r.metaFilter, ref, user); err != nil {
+		return nil, err
+	}
+	return fix, nil
+}
+
+// memberships returns the elements of the "groups" property of the user, keyed by group id and type.
+func memberships(user *prop.Resource) map[string]*Membership {
+	result := map[string]*Membership{}
+	groups, _ := user.RootProperty().ChildAtIndex("groups")
+	if groups == nil {
+		return result
+	}
+	_ = groups.ForEachChild(func(_ int, child prop.Property) error {
+		m := new(Membership)
+		if value, _ := child.ChildAtIndex(fieldValue); value != nil && !value.IsUnassigned() {
+			m.GroupID, _ = value.Raw().(string)
+		}
+		if typ, _ := child.ChildAtIndex("type"); typ != nil && !typ.IsUnassigned() {
+			m.Type, _ = typ.Raw().(string)
+		}
+		if display, _ := child.ChildAtIndex("display"); display != nil && !display.IsUnassigned() {
+			m.Display, _ = display.Raw().(string)
+		}
+		if ref, _ := child.ChildAtIndex("$ref"); ref != nil && !ref.IsUnassigned() {
+			m.Ref, _ = ref.Raw().(string)
+		}
+		result[m.GroupID+"/"+m.Type] = m
+		return nil
+	})
+	return result
+}
@@ -0,0 +1,137 @@
+package groupsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *SyncServiceTestSuite) TestReconciler() {
+	newResource := func(t *testing.T, resourceType *spec.ResourceType, data map[string]interface{}) *prop.Resource {
+		r := prop.NewResource(resourceType)
+		require.False(t, r.Navigator().Replace(data).HasError())
+		return r
+	}
+	// setup returns u1 and u2 in g1, and u3 in the deleted group g2
+	setup := func(t *testing.T) (userDB db.DB, groupDB db.DB) {
+		userDB, groupDB = db.Memory(), db.Memory()
+		for _, u := range []struct {
+			id     string
+			groups []interface{}
+		}{
+			{id: "u1", groups: []interface{}{}},
+			{id: "u2", groups: []interface{}{map[string]interface{}{"value": "g1", "type": "direct", "display": "g1", "$ref": "/Groups/g1"}}},
+			{id: "u3", groups: []interface{}{map[string]interface{}{"value": "g2", "type": "direct"}}},
+		} {
+			require.Nil(t, userDB.Insert(context.Background(), newResource(t, s.userResourceType, map[string]interface{}{
+				"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"id":       u.id,
+				"userName": u.id,
+				"groups":   u.groups,
+				"meta":     map[string]interface{}{"version": "W/\"1\""},
+			})))
+		}
+		require.Nil(t, groupDB.Insert(context.Background(), newResource(t, s.groupResourceType, map[string]interface{}{
+			"schemas":     []interface{}{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+			"id":          "g1",
+			"displayName": "g1",
+			"meta":        map[string]interface{}{"location": "/Groups/g1"},
+			"members":     []interface{}{map[string]interface{}{"value": "u1"}, map[string]interface{}{"value": "u2"}},
+		})))
+		return
+	}
+	versionOf := func(t *testing.T, userDB db.DB, id string) string {
+		user, err := userDB.Get(context.Background(), id, nil)
+		require.Nil(t, err)
+		return user.MetaVersionOrEmpty()
+	}
+
+	tests := []struct {
+		name      string
+		reconcile func(r *Reconciler) *Reconciler
+		expect    func(t *testing.T, userDB db.DB, report *ReconcileReport, err error)
+	}{
+		{
+			name: "fix",
+			reconcile: func(r *Reconciler) *Reconciler {
+				return r
+			},
+			expect: func(t *testing.T, userDB db.DB, report *ReconcileReport, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 3, report.Checked)
+				assert.Empty(t, report.Failed)
+				assert.Equal(t, "u3", report.Cursor)
+				assert.Equal(t, []*Fix{
+					{UserID: "u1", Added: []*Membership{{GroupID: "g1", Type: "direct", Display: "g1", Ref: "/Groups/g1"}}},
+					{UserID: "u3", Removed: []*Membership{{GroupID: "g2", Type: "direct"}}},
+				}, report.Fixes)
+				assert.NotEqual(t, "W/\"1\"", versionOf(t, userDB, "u1"))
+				assert.Equal(t, "W/\"1\"", versionOf(t, userDB, "u2"))
+				assert.NotEqual(t, "W/\"1\"", versionOf(t, userDB, "u3"))
+			},
+		},
+		{
+			name: "dry run",
+			reconcile: func(r *Reconciler) *Reconciler {
+				return r.DryRun(true)
+			},
+			expect: func(t *testing.T, userDB db.DB, report *ReconcileReport, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, report.Fixes, 2)
+				for _, id := range []string{"u1", "u2", "u3"} {
+					assert.Equal(t, "W/\"1\"", versionOf(t, userDB, id))
+				}
+			},
+		},
+		{
+			name: "resume in batches",
+			reconcile: func(r *Reconciler) *Reconciler {
+				return r.Resume("u1").BatchSize(1).RateLimit(1000).OnBatch(func(report *ReconcileReport) {
+					report.Failed[report.Cursor+"@batch"] = nil
+				})
+			},
+			expect: func(t *testing.T, userDB db.DB, report *ReconcileReport, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 2, report.Checked)
+				assert.Contains(t, report.Failed, "u2@batch")
+				assert.Contains(t, report.Failed, "u3@batch")
+				if assert.Len(t, report.Fixes, 1) {
+					assert.Equal(t, "u3", report.Fixes[0].UserID)
+				}
+				assert.Equal(t, "W/\"1\"", versionOf(t, userDB, "u1"))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			userDB, groupDB := setup(t)
+			report, err := test.reconcile(NewReconciler(userDB, groupDB)).Reconcile(context.Background())
+			test.expect(t, userDB, report, err)
+		})
+	}
+
+	s.T().Run("again", func(t *testing.T) {
+		userDB, groupDB := setup(t)
+		_, err := NewReconciler(userDB, groupDB).Reconcile(context.Background())
+		require.Nil(t, err)
+		report, err := NewReconciler(userDB, groupDB).Reconcile(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 3, report.Checked)
+		assert.Empty(t, report.Fixes)
+	})
+
+	s.T().Run("cancelled", func(t *testing.T) {
+		userDB, groupDB := setup(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		report, err := NewReconciler(userDB, groupDB).Reconcile(ctx)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 0, report.Checked)
+	})
+}
@@ -0,0 +1,84 @@
+package groupsync
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// number of outbox entries relayed at a time
+	relayBatchSize = 100
+	// age of unconfirmed outbox entries to relay, long enough for any change to be saved
+	unconfirmedGrace = time.Minute
+)
+
+// NewRelay returns a Relay submitting the jobs stored in the outbox to the queue.
+func NewRelay(outbox Outbox, queue Queue) *Relay {
+	return &Relay{
+		outbox: outbox,
+		queue:  queue,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Relay moves jobs from an Outbox to a Queue. Entries are removed from the outbox only after their jobs are submitted,
+// so jobs are submitted at least once; relays running in several processes over the same outbox may submit a job
+// more than once.
+type Relay struct {
+	outbox Outbox
+	queue  Queue
+	notify chan struct{}
+}
+
+// Notify wakes the running relay up to relay the jobs just stored in the outbox, without waiting for the interval.
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Relay submits all jobs pending in the outbox to the queue, and returns the number of jobs submitted. Unconfirmed jobs
+// are submitted only after a minute. It stops at the first job failed to be submitted, which is left in the outbox.
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	n := 0
+	for {
+		entries, err := r.outbox.Pending(ctx, relayBatchSize, time.Now().Add(-unconfirmedGrace))
+		if err != nil {
+			return n, err
+		}
+		if len(entries) == 0 {
+			return n, nil
+		}
+
+		for _, entry := range entries {
+			if err := r.queue.Submit(ctx, entry.Job); err != nil {
+				return n, err
+			}
+			if err := r.outbox.Remove(ctx, entry.ID); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+}
+
+// Run relays the pending jobs whenever notified, and every interval, until the context is cancelled. The callback is
+// invoked after each relay that submitted jobs or failed.
+func (r *Relay) Run(ctx context.Context, interval time.Duration, callback func(n int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := r.Relay(ctx); n > 0 || err != nil {
+			callback(n, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.notify:
+		case <-ticker.C:
+		}
+	}
+}
@@ -18,6 +18,14 @@ func NewSyncService(groupDB db.DB) *SyncService {
 // SyncService synchronizes the user resource's "groups" property.
 type SyncService struct {
 	groupDB db.DB
+	graph   *MembershipGraph
+}
+
+// UseGraph sets the graph to resolve the groups of users with, instead of querying the group database for each level
+// of nested groups. With the graph, each group is listed once in the "groups" property, even in membership cycles.
+func (s *SyncService) UseGraph(graph *MembershipGraph) *SyncService {
+	s.graph = graph
+	return s
 }
 
 // SyncGroupPropertyForUser updates the user's "groups" property, according to the latest state in Group resources. This
@@ -37,6 +45,10 @@ func (s *SyncService) SyncGroupPropertyForUser(ctx context.Context, user *prop.R
 		return groupNav.Error()
 	}
 
+	if s.graph != nil {
+		return s.syncWithGraph(ctx, user.IdOrEmpty(), groupNav)
+	}
+
 	// task definition and queue
 	type task struct {
 		member string
@@ -99,6 +111,37 @@ func (s *SyncService) SyncGroupPropertyForUser(ctx context.Context, user *prop.R
 	return nil
 }
 
+// syncWithGraph appends the groups of the user resolved by the graph to the cleared "groups" property.
+func (s *SyncService) syncWithGraph(ctx context.Context, userID string, groupNav prop.Navigator) error {
+	memberships, err := s.graph.GroupsOf(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, m := range memberships {
+		index := groupNav.Current().(interface {
+			AppendElement() int
+		}).AppendElement()
+		if groupNav.At(index); groupNav.HasError() {
+			return groupNav.Error()
+		}
+
+		data := map[string]interface{}{
+			"value": m.GroupID,
+			"$ref":  m.Ref,
+			"type":  m.Type,
+		}
+		if len(m.Display) > 0 {
+			data["display"] = m.Display
+		}
+		err := groupNav.Replace(data).Error()
+		groupNav.Retract()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *SyncService) formulateGroupElementData(group *prop.Resource, direct bool) map[string]interface{} {
 	data := map[string]interface{}{
 		"value":   group.IdOrEmpty(),
@@ -3,11 +3,14 @@ package handlerutil
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	scimjson "github.com/imulab/go-scim/pkg/v2/json"
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/service"
 	"github.com/imulab/go-scim/pkg/v2/spec"
+	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
 // WriteResourceToResponse writes the given resource to http.ResponseWriter, respecting the attributes or excludedAttributes
@@ -17,11 +20,6 @@ import (
 // resource's meta.version field, if any. This method does not set response status, which should be set before calling
 // this method.
 func WriteResourceToResponse(rw http.ResponseWriter, resource *prop.Resource, options ...scimjson.Options) error {
-	raw, jsonErr := scimjson.Serialize(resource, options...)
-	if jsonErr != nil {
-		return jsonErr
-	}
-
 	rw.Header().Set("Content-Type", spec.ApplicationScimJson)
 	if location := resource.MetaLocationOrEmpty(); len(location) > 0 {
 		rw.Header().Set("Location", location)
@@ -30,33 +28,30 @@ func WriteResourceToResponse(rw http.ResponseWriter, resource *prop.Resource, op
 		rw.Header().Set("ETag", version)
 	}
 
-	_, writeErr := rw.Write(raw)
-	return writeErr
+	if err := scimjson.SerializeTo(rw, resource, options...); err != nil {
+		rw.Header().Del("Location")
+		rw.Header().Del("ETag")
+		return err
+	}
+	return nil
 }
 
 // WriteSearchResultToResponse writes the search result to http.ResponseWrite, respecting the attribute or excludedAttributes
 // specified through options. Any error during the process will be returned.
+// The list response is streamed to the http.ResponseWriter one resource at a time. If the first resource cannot be
+// serialized, nothing is written; a failure on any later resource leaves the response body incomplete.
 // This method also sets Content-Type header to application/scim+json. This method does not set response status, which should
 // be set before calling this method.
 func WriteSearchResultToResponse(rw http.ResponseWriter, searchResult *service.QueryResponse, options ...scimjson.Options) error {
-	render := SearchResultRendering{
-		Schemas:      []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"},
-		TotalResults: searchResult.TotalResults,
-		StartIndex:   searchResult.StartIndex,
-		ItemsPerPage: searchResult.ItemsPerPage,
-		Resources:    []json.RawMessage{},
-	}
+	rw.Header().Set("Content-Type", spec.ApplicationScimJson)
 
+	enc := scimjson.NewListEncoder(rw, searchResult.TotalResults, searchResult.StartIndex, searchResult.ItemsPerPage, options...)
 	for _, resource := range searchResult.Resources {
-		raw, err := scimjson.Serialize(resource, options...)
-		if err != nil {
+		if err := enc.Encode(resource); err != nil {
 			return err
 		}
-		render.Resources = append(render.Resources, raw)
 	}
-
-	rw.Header().Set("Content-Type", spec.ApplicationScimJson)
-	return json.NewEncoder(rw).Encode(render)
+	return enc.Close()
 }
 
 // WriteError writes the error to the http.ResponseWriter. Any error during the process will be returned.
@@ -95,6 +90,68 @@ func WriteError(rw http.ResponseWriter, err error) error {
 	return writeErr
 }
 
+// ReadError reads the SCIM error message from the body of the http.Response, and returns an error that wraps the
+// matching *spec.Error prototype with the message's detail. The prototype is matched by scimType first, and falls back
+// to match by HTTP status. If no prototype matches, spec.ErrInternal is used instead. This method is the inverse of
+// WriteError and is intended for clients to recover the error reported by a SCIM service provider. It does not close
+// the response body.
+func ReadError(resp *http.Response) error {
+	var errMsg struct {
+		Status   interface{} `json:"status"`
+		ScimType string      `json:"scimType"`
+		Detail   string      `json:"detail"`
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err == nil && len(raw) > 0 {
+		_ = json.Unmarshal(raw, &errMsg)
+	}
+
+	var cause *spec.Error
+	for _, proto := range errorPrototypes {
+		if len(errMsg.ScimType) > 0 && proto.Type == errMsg.ScimType {
+			cause = proto
+			break
+		}
+	}
+	if cause == nil && resp.StatusCode == http.StatusConflict {
+		// RFC7644 Section 3.12 suggests 409 for uniqueness violations
+		cause = spec.ErrUniqueness
+	}
+	if cause == nil {
+		for _, proto := range errorPrototypes {
+			if proto.Status == resp.StatusCode {
+				cause = proto
+				break
+			}
+		}
+	}
+	if cause == nil {
+		cause = spec.ErrInternal
+	}
+
+	detail := strings.TrimPrefix(errMsg.Detail, cause.Type+": ")
+	if len(detail) == 0 {
+		detail = fmt.Sprintf("service provider responded %d", resp.StatusCode)
+	}
+	return fmt.Errorf("%w: %s", cause, detail)
+}
+
+// prototypes to be matched by ReadError, in the order of preference when matched by HTTP status.
+var errorPrototypes = []*spec.Error{
+	spec.ErrInvalidSyntax,
+	spec.ErrInvalidFilter,
+	spec.ErrTooMany,
+	spec.ErrUniqueness,
+	spec.ErrMutability,
+	spec.ErrInvalidPath,
+	spec.ErrNoTarget,
+	spec.ErrInvalidValue,
+	spec.ErrSensitive,
+	spec.ErrNotFound,
+	spec.ErrConflict,
+	spec.ErrInternal,
+}
+
 // SearchResultRendering is the JSON rendering structure for search results. This is very similar to
 // service.QueryResponse except that resources are pre-rendered to adapt for objects serialized using
 // scim json mechanism or go's json mechanism.
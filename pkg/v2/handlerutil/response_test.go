@@ -57,3 +57,56 @@ func TestWriteError(t *testing.T) {
 		})
 	}
 }
+
+func TestReadError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name:   "scim error by type",
+			status: 400,
+			body:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"uniqueness","detail":"userName is taken"}`,
+			expect: func(t *testing.T, err error) {
+				assert.Equal(t, spec.ErrUniqueness, errors.Unwrap(err))
+				assert.Equal(t, "uniqueness: userName is taken", err.Error())
+			},
+		},
+		{
+			name:   "written by WriteError",
+			status: 400,
+			body:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":400,"scimType":"invalidValue","detail":"invalidValue: valid is invalid"}`,
+			expect: func(t *testing.T, err error) {
+				assert.Equal(t, spec.ErrInvalidValue, errors.Unwrap(err))
+				assert.Equal(t, "invalidValue: valid is invalid", err.Error())
+			},
+		},
+		{
+			name:   "error by status",
+			status: 404,
+			body:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"404","detail":"Resource 2819c223 not found"}`,
+			expect: func(t *testing.T, err error) {
+				assert.Equal(t, spec.ErrNotFound, errors.Unwrap(err))
+			},
+		},
+		{
+			name:   "non scim error",
+			status: 502,
+			body:   `Bad Gateway`,
+			expect: func(t *testing.T, err error) {
+				assert.Equal(t, spec.ErrInternal, errors.Unwrap(err))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			rw.WriteHeader(test.status)
+			_, _ = rw.WriteString(test.body)
+			test.expect(t, ReadError(rw.Result()))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Delete returns a service.Delete that applies the onDelete policies of the references to a resource of the resource
+// type, stored in the database, before deleting it with svc. The registered resource types are searched for references
+// to the resource: if any reference is restricted, the deletion fails with spec.ErrConflict and nothing is changed;
+// otherwise, the references to remove are removed by patching the referring resources with the registered patch
+// services, so the changes go through the pipelines of the referring resource types.
+//
+// References of a resource to itself are left to be deleted with it. When the resource does not exist, or does not
+// meet the pre condition of the request, no policy is applied and svc reports the error.
+func Delete(config *spec.ServiceProviderConfig, resourceType *spec.ResourceType, database db.DB, registry *Registry, svc service.Delete) service.Delete {
+	return &deleteService{
+		config:       config,
+		resourceType: resourceType,
+		database:     database,
+		registry:     registry,
+		delete:       svc,
+	}
+}
+
+type deleteService struct {
+	config       *spec.ServiceProviderConfig
+	resourceType *spec.ResourceType
+	database     db.DB
+	registry     *Registry
+	delete       service.Delete
+}
+
+// reference is a referring attribute of a registered resource type, through which resources may refer to the deleted
+// resource.
+type reference struct {
+	target    *target
+	referring *referringAttribute
+	id        string
+}
+
+// filter returns the filter matching the resources referring to the deleted resource.
+func (r *reference) filter() string {
+	return fmt.Sprintf("%s.value eq %s", r.referring.path, strconv.Quote(r.id))
+}
+
+// isSelf returns true if the referring resource is the deleted resource.
+func (r *reference) isSelf(resourceType *spec.ResourceType, id string) bool {
+	return r.target.resourceType.Name() == resourceType.Name() && id == r.id
+}
+
+func (s *deleteService) Do(ctx context.Context, req *service.DeleteRequest) (*service.DeleteResponse, error) {
+	resource, err := s.database.Get(ctx, req.ResourceID, nil)
+	if err != nil {
+		return s.delete.Do(ctx, req)
+	}
+	if s.config.ETag.Supported && req.MatchCriteria != nil && !req.MatchCriteria(resource) {
+		return s.delete.Do(ctx, req)
+	}
+
+	var removals []*reference
+	for _, t := range s.registry.all() {
+		for _, referring := range referringAttributes(t.resourceType, s.resourceType.Name()) {
+			ref := &reference{target: t, referring: referring, id: req.ResourceID}
+			switch OnDelete(referring.attr) {
+			case OnDeleteRestrict:
+				if err := s.restrict(ctx, ref); err != nil {
+					return nil, err
+				}
+			case OnDeleteRemove:
+				removals = append(removals, ref)
+			}
+		}
+	}
+
+	for _, ref := range removals {
+		if err := s.remove(ctx, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.delete.Do(ctx, req)
+}
+
+func (s *deleteService) restrict(ctx context.Context, ref *reference) error {
+	referrers, err := ref.target.database.Query(ctx, ref.filter(), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	for _, referrer := range referrers {
+		if !ref.isSelf(s.resourceType, referrer.IdOrEmpty()) {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: resource is referred to by '%s' of %d %s resource(s)",
+			spec.ErrConflict, ref.referring.path, n, ref.target.resourceType.Name())
+	}
+	return nil
+}
+
+func (s *deleteService) remove(ctx context.Context, ref *reference) error {
+	referrers, err := ref.target.database.Query(ctx, ref.filter(), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	path := ref.referring.path
+	if ref.referring.attr.MultiValued() {
+		path = fmt.Sprintf("%s[value eq %s]", path, strconv.Quote(ref.id))
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"schemas":    []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []map[string]string{{"op": "remove", "path": path}},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, referrer := range referrers {
+		if ref.isSelf(s.resourceType, referrer.IdOrEmpty()) {
+			continue
+		}
+		if _, err := ref.target.patch.Do(ctx, &service.PatchRequest{
+			ResourceID:    referrer.IdOrEmpty(),
+			PayloadSource: strings.NewReader(string(payload)),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
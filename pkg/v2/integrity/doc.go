@@ -0,0 +1,12 @@
+// This package keeps references between resources consistent.
+//
+// Complex attributes holding the id of another resource in their "value" sub attribute, and whose "$ref" sub attribute
+// names resource types in referenceTypes, refer to resources, like the members of groups, or the manager of the
+// enterprise user extension. The resource types that can be referenced are registered in a Registry, along with their
+// database and patch service.
+//
+// ReferenceFilter is a filter.ByProperty implementation that checks that referenced resources exist and are of an
+// allowed type when resources are written, and fills in the "$ref" and "display" sub properties from them. Delete
+// wraps a service.Delete so that the references to the deleted resource are removed, or block the deletion, according
+// to the "onDelete" parameter of the @Reference annotation on the referring attribute.
+package integrity
@@ -0,0 +1,137 @@
+package integrity
+
+import (
+	"context"
+
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// ReferenceFilter returns a ByProperty filter that checks the references held by complex properties whose attribute
+// refers to resources (see ReferenceTypes). The resource referred to by the "value" sub property must exist among the
+// registered resource types named in referenceTypes, or the one named by the "type" sub property, when the attribute
+// has one whose canonicalValues are resource type names. The "$ref" sub property is then set to the location of the
+// referenced resource, the "display" or "displayName" sub property to its displayName, and an unassigned "type" sub
+// property to the name of its resource type.
+//
+// On replace and patch, references left unchanged are not checked again, so that resources keep their references to
+// deleted resources whose references are ignored; their missing sub properties are copied from the reference
+// property instead. Read only properties are maintained by the server, and are not checked.
+func ReferenceFilter(registry *Registry) filter.ByProperty {
+	return referencePropertyFilter{registry: registry}
+}
+
+type referencePropertyFilter struct {
+	registry *Registry
+}
+
+func (f referencePropertyFilter) Supports(attribute *spec.Attribute) bool {
+	return !attribute.MultiValued() &&
+		attribute.Mutability() != spec.MutabilityReadOnly &&
+		len(ReferenceTypes(attribute)) > 0
+}
+
+func (f referencePropertyFilter) Filter(ctx context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+	return f.resolve(ctx, nav)
+}
+
+func (f referencePropertyFilter) FilterRef(ctx context.Context, _ *spec.ResourceType, nav prop.Navigator, refNav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+
+	if refNav != nil && !filter.IsOutOfSync(refNav.Current()) {
+		if value, ref := childOf(nav.Current(), "value"), childOf(refNav.Current(), "value"); value != nil && ref != nil &&
+			!value.IsUnassigned() && value.Raw() == ref.Raw() {
+			for _, name := range []string{"$ref", "display", "displayName", "type"} {
+				if child := childOf(refNav.Current(), name); child != nil && !child.IsUnassigned() {
+					if err := fillChild(nav, name, child.Raw(), false); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+	}
+
+	return f.resolve(ctx, nav)
+}
+
+func (f referencePropertyFilter) resolve(ctx context.Context, nav prop.Navigator) error {
+	value := childOf(nav.Current(), "value")
+	if value == nil || value.IsUnassigned() {
+		return nil
+	}
+
+	attr := nav.Current().Attribute()
+	var referenceType string
+	if typ := childOf(nav.Current(), "type"); typ != nil && !typ.IsUnassigned() && isResourceTypeAttribute(attr) {
+		referenceType, _ = typ.Raw().(string)
+	}
+
+	target, err := f.registry.resolve(ctx, attr, value.Raw().(string), referenceType)
+	if err != nil {
+		return err
+	}
+
+	if location := target.MetaLocationOrEmpty(); len(location) > 0 {
+		if err := fillChild(nav, "$ref", location, true); err != nil {
+			return err
+		}
+	}
+	if displayName := childOf(target.RootProperty(), "displayName"); displayName != nil && !displayName.IsUnassigned() {
+		for _, name := range []string{"display", "displayName"} {
+			if err := fillChild(nav, name, displayName.Raw(), true); err != nil {
+				return err
+			}
+		}
+	}
+	if isResourceTypeAttribute(attr) {
+		if err := fillChild(nav, "type", target.ResourceType().Name(), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isResourceTypeAttribute returns true if the "type" sub attribute of the reference attribute holds the names of the
+// referenced resource types.
+func isResourceTypeAttribute(attr *spec.Attribute) bool {
+	typeAttr := attr.SubAttributeForName("type")
+	if typeAttr == nil || typeAttr.Type() != spec.TypeString {
+		return false
+	}
+	return typeAttr.ExistsCanonicalValue(func(canonicalValue string) bool {
+		return containsString(ReferenceTypes(attr), canonicalValue)
+	})
+}
+
+// childOf returns the named child of the complex property, or nil if it does not exist.
+func childOf(property prop.Property, name string) prop.Property {
+	if property.Attribute().SubAttributeForName(name) == nil {
+		return nil
+	}
+	child, err := property.ChildAtIndex(name)
+	if err != nil {
+		return nil
+	}
+	return child
+}
+
+// fillChild replaces the value of the named child of the current property, if it exists, and if it is unassigned
+// unless overwrite is true.
+func fillChild(nav prop.Navigator, name string, value interface{}, overwrite bool) error {
+	child := childOf(nav.Current(), name)
+	if child == nil || (!overwrite && !child.IsUnassigned()) || child.Raw() == value {
+		return nil
+	}
+	if nav.Dot(name).HasError() {
+		return nav.Error()
+	}
+	defer nav.Retract()
+	return nav.Replace(value).Error()
+}
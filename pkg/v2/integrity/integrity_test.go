@@ -0,0 +1,340 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/service/filter"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestIntegrity(t *testing.T) {
+	s := new(IntegrityTestSuite)
+	suite.Run(t, s)
+}
+
+type IntegrityTestSuite struct {
+	suite.Suite
+	userResourceType  *spec.ResourceType
+	groupResourceType *spec.ResourceType
+	config            *spec.ServiceProviderConfig
+}
+
+func (s *IntegrityTestSuite) TestReferenceTypes() {
+	members := s.groupResourceType.SuperAttribute(true).SubAttributeForName("members")
+	require.NotNil(s.T(), members)
+	assert.Equal(s.T(), []string{"User", "Group"}, ReferenceTypes(members))
+	assert.Equal(s.T(), OnDeleteRemove, OnDelete(members))
+
+	photos := s.userResourceType.SuperAttribute(true).SubAttributeForName("photos")
+	require.NotNil(s.T(), photos)
+	assert.Empty(s.T(), ReferenceTypes(photos))
+
+	var paths []string
+	for _, each := range referringAttributes(s.userResourceType, "User") {
+		paths = append(paths, each.path)
+	}
+	assert.Equal(s.T(), []string{"urn:imulab:scim:2.0:Reporting:manager", "urn:imulab:scim:2.0:Reporting:mentor"}, paths)
+}
+
+func (s *IntegrityTestSuite) TestReferenceFilter() {
+	tests := []struct {
+		name   string
+		member string
+		expect func(t *testing.T, group *prop.Resource, err error)
+	}{
+		{
+			name:   "member user is resolved",
+			member: "u1",
+			expect: func(t *testing.T, group *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "https://example.com/Users/u1", valueAt(group, "members", "$ref"))
+				assert.Equal(t, "David", valueAt(group, "members", "display"))
+			},
+		},
+		{
+			name:   "member group is resolved",
+			member: "g1",
+			expect: func(t *testing.T, group *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "https://example.com/Groups/g1", valueAt(group, "members", "$ref"))
+				assert.Equal(t, "Engineering", valueAt(group, "members", "display"))
+			},
+		},
+		{
+			name:   "non-existing member is rejected",
+			member: "foo",
+			expect: func(t *testing.T, _ *prop.Resource, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+				assert.Contains(t, err.Error(), "'members.value'")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			registry, users, groups := s.newRegistry()
+			require.Nil(t, users.Insert(context.Background(), s.newUser("u1", "david", "David")))
+			require.Nil(t, groups.Insert(context.Background(), s.newGroup("g1", "Engineering")))
+
+			group := s.newGroup("g2", "Everyone", test.member)
+			err := filter.Visit(context.Background(), group, ReferenceFilter(registry))
+			test.expect(t, group, err)
+		})
+	}
+}
+
+func (s *IntegrityTestSuite) TestReferenceFilterWithRef() {
+	ctx := context.Background()
+	registry, users, _ := s.newRegistry()
+	require.Nil(s.T(), users.Insert(ctx, s.newUser("u1", "david", "David")))
+
+	ref := s.newGroup("g1", "Everyone", "deleted")
+	require.Nil(s.T(), ref.Navigator().Dot("members").At(0).Dot("$ref").Replace("https://example.com/Users/deleted").Error())
+
+	// the unchanged member whose user was deleted is kept, and its $ref is copied from the reference, while the new
+	// member is resolved
+	group := s.newGroup("g1", "Everyone", "deleted", "u1")
+	assert.Nil(s.T(), filter.VisitWithRef(ctx, group, ref, ReferenceFilter(registry)))
+	assert.Equal(s.T(), "https://example.com/Users/deleted", group.Navigator().Dot("members").At(0).Dot("$ref").Current().Raw())
+	assert.Equal(s.T(), "https://example.com/Users/u1", group.Navigator().Dot("members").At(1).Dot("$ref").Current().Raw())
+
+	// new members are checked
+	group = s.newGroup("g1", "Everyone", "deleted", "foo")
+	err := filter.VisitWithRef(ctx, group, ref, ReferenceFilter(registry))
+	assert.True(s.T(), errors.Is(err, spec.ErrInvalidValue))
+}
+
+func (s *IntegrityTestSuite) TestDelete() {
+	ctx := context.Background()
+	registry, users, groups := s.newRegistry()
+	require.Nil(s.T(), users.Insert(ctx, s.newUser("u1", "david", "David")))
+	require.Nil(s.T(), users.Insert(ctx, s.newUser("u2", "imulab", "Weinan", "manager", "u1")))
+	require.Nil(s.T(), users.Insert(ctx, s.newUser("u3", "alice", "Alice", "manager", "u1")))
+	require.Nil(s.T(), groups.Insert(ctx, s.newGroup("g1", "Engineering", "u1", "u2")))
+	require.Nil(s.T(), groups.Insert(ctx, s.newGroup("g2", "Everyone", "u1", "g1")))
+
+	deleteUser := Delete(s.config, s.userResourceType, users, registry, service.DeleteService(s.config, users))
+	resp, err := deleteUser.Do(ctx, &service.DeleteRequest{ResourceID: "u1"})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "u1", resp.Deleted.IdOrEmpty())
+
+	for id, members := range map[string][]string{"g1": {"u2"}, "g2": {"g1"}} {
+		group, err := groups.Get(ctx, id, nil)
+		require.Nil(s.T(), err)
+		var values []string
+		_ = group.Navigator().Dot("members").ForEachChild(func(_ int, child prop.Property) error {
+			values = append(values, childOf(child, "value").Raw().(string))
+			return nil
+		})
+		assert.Equal(s.T(), members, values, id)
+	}
+	for _, id := range []string{"u2", "u3"} {
+		user, err := users.Get(ctx, id, nil)
+		require.Nil(s.T(), err)
+		assert.True(s.T(), user.Navigator().Dot("urn:imulab:scim:2.0:Reporting").Dot("manager").Current().IsUnassigned(), id)
+	}
+}
+
+func (s *IntegrityTestSuite) TestDeleteRestricted() {
+	ctx := context.Background()
+	registry, users, groups := s.newRegistry()
+	require.Nil(s.T(), users.Insert(ctx, s.newUser("u1", "david", "David")))
+	require.Nil(s.T(), users.Insert(ctx, s.newUser("u2", "imulab", "Weinan", "mentor", "u1")))
+	require.Nil(s.T(), groups.Insert(ctx, s.newGroup("g1", "Engineering", "u1")))
+
+	deleteUser := Delete(s.config, s.userResourceType, users, registry, service.DeleteService(s.config, users))
+	_, err := deleteUser.Do(ctx, &service.DeleteRequest{ResourceID: "u1"})
+	assert.True(s.T(), errors.Is(err, spec.ErrConflict))
+	assert.Contains(s.T(), err.Error(), "'urn:imulab:scim:2.0:Reporting:mentor'")
+
+	// nothing is changed
+	_, err = users.Get(ctx, "u1", nil)
+	assert.Nil(s.T(), err)
+	group, err := groups.Get(ctx, "g1", nil)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, group.Navigator().Dot("members").Current().CountChildren())
+
+	// a resource referring to itself does not restrict its own deletion
+	require.Nil(s.T(), users.Insert(ctx, s.newUser("u3", "alice", "Alice", "mentor", "u3")))
+	_, err = deleteUser.Do(ctx, &service.DeleteRequest{ResourceID: "u3"})
+	assert.Nil(s.T(), err)
+}
+
+func (s *IntegrityTestSuite) newRegistry() (*Registry, db.DB, db.DB) {
+	var (
+		registry = NewRegistry()
+		users    = db.Memory()
+		groups   = db.Memory()
+	)
+	registry.Register(s.userResourceType, users, service.PatchService(s.config, users, nil, nil))
+	registry.Register(s.groupResourceType, groups, service.PatchService(s.config, groups, nil, nil))
+	return registry, users, groups
+}
+
+// newUser returns a user, with the id of the manager or mentor given as pairs of the attribute name and the id.
+func (s *IntegrityTestSuite) newUser(id, userName, displayName string, references ...string) *prop.Resource {
+	r := prop.NewResource(s.userResourceType)
+	nav := r.Navigator()
+	require.Nil(s.T(), nav.Replace(map[string]interface{}{
+		"schemas":     []interface{}{s.userResourceType.Schema().ID()},
+		"id":          id,
+		"userName":    userName,
+		"displayName": displayName,
+		"meta": map[string]interface{}{
+			"location": "https://example.com/Users/" + id,
+		},
+	}).Error())
+	for i := 0; i+1 < len(references); i += 2 {
+		require.Nil(s.T(), nav.Dot("urn:imulab:scim:2.0:Reporting").Dot(references[i]).Replace(map[string]interface{}{
+			"value": references[i+1],
+		}).Error())
+		nav.Retract()
+		nav.Retract()
+	}
+	return r
+}
+
+func (s *IntegrityTestSuite) newGroup(id, displayName string, members ...string) *prop.Resource {
+	r := prop.NewResource(s.groupResourceType)
+	var values []interface{}
+	for _, member := range members {
+		values = append(values, map[string]interface{}{"value": member})
+	}
+	require.Nil(s.T(), r.Navigator().Replace(map[string]interface{}{
+		"schemas":     []interface{}{s.groupResourceType.Schema().ID()},
+		"id":          id,
+		"displayName": displayName,
+		"members":     values,
+		"meta": map[string]interface{}{
+			"location": "https://example.com/Groups/" + id,
+		},
+	}).Error())
+	return r
+}
+
+func valueAt(resource *prop.Resource, path ...string) interface{} {
+	nav := resource.Navigator()
+	for _, each := range path {
+		nav.Dot(each)
+		if nav.Current().Attribute().MultiValued() {
+			nav.At(0)
+		}
+	}
+	return nav.Current().Raw()
+}
+
+func (s *IntegrityTestSuite) SetupSuite() {
+	registry := spec.NewSchemaRegistry()
+	for _, raw := range []string{
+		s.mustRead("../../../public/schemas/core_schema.json"),
+		s.mustRead("../../../public/schemas/user_schema.json"),
+		s.mustRead("../../../public/schemas/group_schema.json"),
+		reportingSchema,
+	} {
+		schema := new(spec.Schema)
+		require.Nil(s.T(), schema.UnmarshalJSON([]byte(raw)))
+		registry.Register(schema)
+	}
+
+	var err error
+	s.userResourceType, err = registry.ParseResourceType([]byte(`{
+		"id": "User",
+		"name": "User",
+		"endpoint": "/Users",
+		"schema": "urn:ietf:params:scim:schemas:core:2.0:User",
+		"schemaExtensions": [
+			{"schema": "urn:imulab:scim:2.0:Reporting"}
+		]
+	}`))
+	require.Nil(s.T(), err)
+	s.groupResourceType, err = registry.ParseResourceType([]byte(s.mustRead("../../../public/resource_types/group_resource_type.json")))
+	require.Nil(s.T(), err)
+
+	crud.Register(s.userResourceType)
+	crud.Register(s.groupResourceType)
+
+	s.config = new(spec.ServiceProviderConfig)
+	s.config.Patch.Supported = true
+}
+
+func (s *IntegrityTestSuite) mustRead(path string) string {
+	raw, err := ioutil.ReadFile(path)
+	require.Nil(s.T(), err)
+	return strings.TrimSpace(string(raw))
+}
+
+const reportingSchema = `{
+  "id": "urn:imulab:scim:2.0:Reporting",
+  "name": "Reporting",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:2.0:Reporting:manager",
+      "name": "manager",
+      "type": "complex",
+      "_index": 0,
+      "_path": "manager",
+      "subAttributes": [
+        {
+          "id": "urn:imulab:scim:2.0:Reporting:manager.value",
+          "name": "value",
+          "type": "string",
+          "_index": 0,
+          "_path": "manager.value"
+        },
+        {
+          "id": "urn:imulab:scim:2.0:Reporting:manager.$ref",
+          "name": "$ref",
+          "type": "reference",
+          "referenceTypes": ["User"],
+          "_index": 1,
+          "_path": "manager.$ref"
+        },
+        {
+          "id": "urn:imulab:scim:2.0:Reporting:manager.displayName",
+          "name": "displayName",
+          "type": "string",
+          "_index": 2,
+          "_path": "manager.displayName"
+        }
+      ]
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Reporting:mentor",
+      "name": "mentor",
+      "type": "complex",
+      "_index": 1,
+      "_path": "mentor",
+      "_annotations": {
+        "@Reference": {"onDelete": "restrict"}
+      },
+      "subAttributes": [
+        {
+          "id": "urn:imulab:scim:2.0:Reporting:mentor.value",
+          "name": "value",
+          "type": "string",
+          "_index": 0,
+          "_path": "mentor.value"
+        },
+        {
+          "id": "urn:imulab:scim:2.0:Reporting:mentor.$ref",
+          "name": "$ref",
+          "type": "reference",
+          "referenceTypes": ["User"],
+          "_index": 1,
+          "_path": "mentor.$ref"
+        }
+      ]
+    }
+  ]
+}`
@@ -0,0 +1,198 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/service"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Policies applied to references when the referenced resource is deleted, chosen by the "onDelete" parameter of
+// @Reference.
+const (
+	// Remove the element from the multiValued property, or unassign the singular property.
+	OnDeleteRemove = "remove"
+	// Refuse to delete the referenced resource.
+	OnDeleteRestrict = "restrict"
+	// Leave the reference as it is.
+	OnDeleteIgnore = "ignore"
+)
+
+// ReferenceTypes returns the names of the resource types the complex attribute refers to, or nil if it does not refer
+// to resources. An attribute refers to resources when it has a "value" sub attribute holding the id of the referenced
+// resource, and a "$ref" sub attribute of reference type whose referenceTypes name resource types, as opposed to
+// "external" and "uri".
+func ReferenceTypes(attr *spec.Attribute) []string {
+	if attr.Type() != spec.TypeComplex || attr.SubAttributeForName("value") == nil {
+		return nil
+	}
+	refAttr := attr.SubAttributeForName("$ref")
+	if refAttr == nil || refAttr.Type() != spec.TypeReference {
+		return nil
+	}
+
+	var names []string
+	refAttr.ForEachReferenceTypes(func(referenceType string) {
+		if referenceType != "external" && referenceType != "uri" {
+			names = append(names, referenceType)
+		}
+	})
+	return names
+}
+
+// OnDelete returns the policy applied to the references of the attribute when the referenced resource is deleted.
+func OnDelete(attr *spec.Attribute) string {
+	params, _ := attr.Annotation(annotation.Reference)
+	switch policy, _ := params["onDelete"].(string); policy {
+	case OnDeleteRestrict, OnDeleteIgnore:
+		return policy
+	default:
+		return OnDeleteRemove
+	}
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{targets: map[string]*target{}}
+}
+
+// Registry holds the resource types that can be referenced, by their names, along with the database they are stored
+// in and the service to patch them with when the resources they refer to are deleted. It is safe for concurrent use.
+type Registry struct {
+	sync.RWMutex
+	targets map[string]*target
+}
+
+type target struct {
+	resourceType *spec.ResourceType
+	database     db.DB
+	patch        service.Patch
+}
+
+// Register the resource type, replacing any previous resource type of the same name.
+func (r *Registry) Register(resourceType *spec.ResourceType, database db.DB, patch service.Patch) {
+	r.Lock()
+	defer r.Unlock()
+	r.targets[resourceType.Name()] = &target{
+		resourceType: resourceType,
+		database:     database,
+		patch:        patch,
+	}
+}
+
+func (r *Registry) get(name string) (*target, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	t, ok := r.targets[name]
+	return t, ok
+}
+
+// all returns the registered resource types, sorted by name.
+func (r *Registry) all() []*target {
+	r.RLock()
+	defer r.RUnlock()
+	targets := make([]*target, 0, len(r.targets))
+	for _, t := range r.targets {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].resourceType.Name() < targets[j].resourceType.Name()
+	})
+	return targets
+}
+
+// resolve returns the resource referred to by the id, among the resource types the attribute refers to, or those
+// named by referenceType if it is not empty. Unregistered resource types are skipped.
+func (r *Registry) resolve(ctx context.Context, attr *spec.Attribute, id string, referenceType string) (*prop.Resource, error) {
+	valuePath := attr.SubAttributeForName("value").Path()
+
+	names := ReferenceTypes(attr)
+	if len(referenceType) > 0 {
+		if !containsString(names, referenceType) {
+			return nil, fmt.Errorf("%w: '%s' cannot refer to %s resources", spec.ErrInvalidValue, valuePath, referenceType)
+		}
+		names = []string{referenceType}
+	}
+
+	for _, name := range names {
+		t, ok := r.get(name)
+		if !ok {
+			continue
+		}
+		resource, err := t.database.Get(ctx, id, nil)
+		if err == nil {
+			return resource, nil
+		}
+		if !errors.Is(err, spec.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("%w: '%s' refers to non-existing resource '%s'", spec.ErrInvalidValue, valuePath, id)
+}
+
+// referringAttribute is an attribute of a resource type referring to another resource type.
+type referringAttribute struct {
+	attr *spec.Attribute
+	// path of the attribute, prefixed with the schema id for extension attributes
+	path string
+}
+
+// referringAttributes returns the attributes of the resource type which refer to resources of the named resource type,
+// not nested inside a multiValued attribute. Read only attributes are maintained by the server, and are left out.
+func referringAttributes(resourceType *spec.ResourceType, name string) []*referringAttribute {
+	var (
+		result  []*referringAttribute
+		collect func(attr *spec.Attribute, schemaId string)
+	)
+	collect = func(attr *spec.Attribute, schemaId string) {
+		if attr.Mutability() == spec.MutabilityReadOnly {
+			return
+		}
+		if containsString(ReferenceTypes(attr), name) {
+			path := attr.Path()
+			if len(schemaId) > 0 && !strings.HasPrefix(path, schemaId) {
+				path = schemaId + ":" + path
+			}
+			result = append(result, &referringAttribute{attr: attr, path: path})
+			return
+		}
+		if attr.MultiValued() || attr.Type() != spec.TypeComplex {
+			return
+		}
+		_ = attr.ForEachSubAttribute(func(subAttribute *spec.Attribute) error {
+			collect(subAttribute, schemaId)
+			return nil
+		})
+	}
+
+	_ = resourceType.SuperAttribute(true).ForEachSubAttribute(func(attr *spec.Attribute) error {
+		if _, ok := attr.Annotation(annotation.SchemaExtensionRoot); ok {
+			_ = attr.ForEachSubAttribute(func(subAttribute *spec.Attribute) error {
+				collect(subAttribute, attr.ID())
+				return nil
+			})
+		} else {
+			collect(attr, "")
+		}
+		return nil
+	})
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, each := range values {
+		if each == value {
+			return true
+		}
+	}
+	return false
+}
@@ -5,26 +5,32 @@ import (
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
 )
 
 // Deserialize is the entry point of JSON deserialization. Unmarshal the JSON input bytes into a pre-prepared unassigned
-// structure of Resource.
-func Deserialize(json []byte, resource *prop.Resource) error {
+// structure of Resource. By default, deserialization is strict: attributes unknown to the resource type, and values not
+// matching the attribute characteristics, fail the deserialization. Use Lenient and Coerce options to tolerate them.
+func Deserialize(json []byte, resource *prop.Resource, options ...DeserializeOptions) error {
 	if err := checkValid(json, &scanner{}); err != nil {
 		return err
 	}
 
 	state := &deserializeState{
-		data:      json,
-		off:       0,
-		opCode:    scanContinue,
-		scan:      scanner{},
-		navigator: resource.Navigator(),
+		data:         json,
+		off:          0,
+		opCode:       scanContinue,
+		scan:         scanner{},
+		navigator:    resource.Navigator(),
+		resourceType: resource.ResourceType(),
 	}
 	state.scan.reset()
+	for _, opt := range options {
+		opt.applyDeserialize(state)
+	}
 
 	// skip the first few spaces
 	state.scanWhile(scanSkipSpace)
@@ -37,7 +43,8 @@ func Deserialize(json []byte, resource *prop.Resource) error {
 //
 // The allowElementForArray option is provided to allow JSON array element values be provided for a multiValued property
 // so that it will be de-serialized as its element. The result will be a multiValued property containing a single element.
-func DeserializeProperty(json []byte, property prop.Property, allowElementForArray bool) error {
+// The CoerceSingleValue rule has the same effect, but reports a Warning.
+func DeserializeProperty(json []byte, property prop.Property, allowElementForArray bool, options ...DeserializeOptions) error {
 	state := &deserializeState{
 		data:      json,
 		off:       0,
@@ -46,6 +53,9 @@ func DeserializeProperty(json []byte, property prop.Property, allowElementForArr
 		navigator: prop.Navigate(property),
 	}
 	state.scan.reset()
+	for _, opt := range options {
+		opt.applyDeserialize(state)
+	}
 
 	// Since this function is intended for bytes from json.RawMessage, it is not possible for it to precede with
 	// spaces. Hence, simply use scanNext to read in the first byte, then use stateBeginValue to forcibly set the
@@ -65,7 +75,10 @@ func DeserializeProperty(json []byte, property prop.Property, allowElementForArr
 		// to be provided as a value for the multiValue property itself. If this feature is enabled,
 		// we will parse the value as the multiValued element and add it to the multiValued container.
 		if !allowElementForArray {
-			return state.errInvalidSyntax("expects JSON array")
+			if !state.coerces(CoerceSingleValue) {
+				return state.errInvalidSyntax("expects JSON array")
+			}
+			state.warn("single value accepted as array")
 		}
 
 		if mv, ok := state.navigator.Current().(interface {
@@ -82,6 +95,7 @@ func DeserializeProperty(json []byte, property prop.Property, allowElementForArr
 			if state.navigator.Error() != nil {
 				return state.navigator.Error()
 			}
+			state.path = append(state.path, "[0]")
 			return state.parseSingleValuedProperty()
 		}
 	}
@@ -95,18 +109,66 @@ func DeserializeProperty(json []byte, property prop.Property, allowElementForArr
 // As a side note, all parseXXX methods of this object shall maintain one courtesy: after done parsing the part of the
 // data of interest to the method, consume as much empty spaces or separators (i.e. scanObjectValue, scanArrayValue) as
 // possible so that the next parseXXX method invoked will not have to skip spaces as its first task.
+//
+// The path records the JSON path segments of the current value, so that problems can be reported at their location.
 type deserializeState struct {
-	data      []byte
-	off       int // next read offset in data
-	opCode    int // last read result
-	scan      scanner
-	navigator prop.Navigator
+	data         []byte
+	off          int // next read offset in data
+	opCode       int // last read result
+	scan         scanner
+	navigator    prop.Navigator
+	resourceType *spec.ResourceType // nil when deserializing a property
+	path         []string
+	lenient      bool
+	coerce       Coercion
+	report       func(warning *Warning)
 }
 
 func (d *deserializeState) errInvalidSyntax(msg string, args ...interface{}) error {
 	return fmt.Errorf("%w: %s (pos:%d)", spec.ErrInvalidSyntax, fmt.Sprintf(msg, args...), d.off)
 }
 
+func (d *deserializeState) coerces(rule Coercion) bool {
+	return d.coerce&rule != 0
+}
+
+// warn reports a problem with the current value, if a report function is registered.
+func (d *deserializeState) warn(msg string, args ...interface{}) {
+	if d.report != nil {
+		d.report(&Warning{Path: d.jsonPath(), Message: fmt.Sprintf(msg, args...)})
+	}
+}
+
+// jsonPath returns the JSON path of the current value, i.e. $.emails[0].value. Names that are not identifiers, like
+// schema URNs, are quoted in brackets.
+func (d *deserializeState) jsonPath() string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, segment := range d.path {
+		switch {
+		case strings.HasPrefix(segment, "["):
+			sb.WriteString(segment)
+		case isIdentifier(segment):
+			sb.WriteString(".")
+			sb.WriteString(segment)
+		default:
+			sb.WriteString("['")
+			sb.WriteString(segment)
+			sb.WriteString("']")
+		}
+	}
+	return sb.String()
+}
+
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || r == '$' || (i > 0 && (unicode.IsDigit(r) || r == '-'))) {
+			return false
+		}
+	}
+	return len(name) > 0
+}
+
 // Parses the attribute/field name in a JSON object. This method expects a quoted string and skips through
 // as much empty spaces and colon (appears as scanObjectKey) after it as possible.
 func (d *deserializeState) parseFieldName() (string, error) {
@@ -149,33 +211,42 @@ func (d *deserializeState) parseComplexProperty(allowNull bool) error {
 kvs:
 	for d.opCode != scanEndObject {
 		// Focus on the property that corresponds to the field name
-		var (
-			p   prop.Property
-			err error
-		)
-		{
-			attrName, err := d.parseFieldName()
-			if err != nil {
+		var p prop.Property
+		attrName, err := d.parseFieldName()
+		if err != nil {
+			return err
+		}
+		d.path = append(d.path, attrName)
+
+		if _, err := d.navigator.Current().ChildAtIndex(attrName); err != nil {
+			// Unknown attribute: drop it with its value in lenient mode, or fail otherwise.
+			if !d.lenient {
+				return d.errInvalidSyntax("unknown attribute at %s", d.jsonPath())
+			}
+			d.warn("unknown attribute dropped")
+			if err := d.skipValue(); err != nil {
 				return err
 			}
+		} else {
 			p = d.navigator.Dot(attrName).Current()
 			if d.navigator.Error() != nil {
 				return d.navigator.Error()
 			}
-		}
 
-		// Parse field value
-		if p.Attribute().MultiValued() {
-			err = d.parseMultiValuedProperty()
-		} else {
-			err = d.parseSingleValuedProperty()
-		}
-		if err != nil {
-			return err
-		}
+			// Parse field value
+			if p.Attribute().MultiValued() {
+				err = d.parseMultiValuedProperty()
+			} else {
+				err = d.parseSingleValuedProperty()
+			}
+			if err != nil {
+				return err
+			}
 
-		// Exit focus on the field value property
-		d.navigator.Retract()
+			// Exit focus on the field value property
+			d.navigator.Retract()
+		}
+		d.path = d.path[:len(d.path)-1]
 
 		// Fast forward to the next field name/value pair, or exit the loop.
 	fastForward:
@@ -224,12 +295,16 @@ func (d *deserializeState) parseSingleValuedProperty() error {
 // Parses a JSON array. This method expects '[' (appears as scanBeginArray) to be the current byte, or the literal
 // null.
 func (d *deserializeState) parseMultiValuedProperty() error {
-	// Expect '[' or null.
+	// Expect '[' or null, or a single value to coerce into an array.
 	if d.opCode != scanBeginArray {
+		isNull := d.opCode == scanBeginLiteral && d.data[d.off-1] == 'n'
+		if !isNull && d.coerces(CoerceSingleValue) && (d.opCode == scanBeginLiteral || d.opCode == scanBeginObject) {
+			return d.parseSingleValueAsArray()
+		}
 		if d.opCode == scanBeginLiteral {
 			return d.parseNull()
 		}
-		return d.errInvalidSyntax("expects JSON array")
+		return d.errInvalidSyntax("expects JSON array at %s", d.jsonPath())
 	}
 
 	// Skip any spaces between '[' and the potential first element
@@ -239,31 +314,12 @@ func (d *deserializeState) parseMultiValuedProperty() error {
 	}
 
 elements:
-	for d.opCode != scanEndArray {
-		// Create the place-holding element prototype and focus on it
-		if mv, ok := d.navigator.Current().(interface {
-			AppendElement() int
-		}); !ok {
-			return d.errInvalidSyntax("non-multiValued property at json array")
-		} else {
-			i := mv.AppendElement()
-			if i < 0 {
-				return fmt.Errorf("%w: failed to create property to host json array element", spec.ErrInternal)
-			}
-			d.navigator.At(i)
-			if d.navigator.Error() != nil {
-				return d.navigator.Error()
-			}
-		}
-
-		// Parse the focused element property
-		err := d.parseSingleValuedProperty()
-		if err != nil {
+	for n := 0; d.opCode != scanEndArray; n++ {
+		d.path = append(d.path, "["+strconv.Itoa(n)+"]")
+		if err := d.parseElement(); err != nil {
 			return err
 		}
-
-		// Exit the focus
-		d.navigator.Retract()
+		d.path = d.path[:len(d.path)-1]
 
 		// Fast forward to the next element, or exit the loop.
 	fastForward:
@@ -288,6 +344,81 @@ elements:
 	return nil
 }
 
+// Parses a single JSON value as the only element of the currently focused multiValued property.
+func (d *deserializeState) parseSingleValueAsArray() error {
+	d.warn("single value accepted as array")
+	d.path = append(d.path, "[0]")
+	if err := d.parseElement(); err != nil {
+		return err
+	}
+	d.path = d.path[:len(d.path)-1]
+	return nil
+}
+
+// Appends an element to the currently focused multiValued property, and parses the current JSON value into it.
+func (d *deserializeState) parseElement() error {
+	// Create the place-holding element prototype and focus on it
+	if mv, ok := d.navigator.Current().(interface {
+		AppendElement() int
+	}); !ok {
+		return d.errInvalidSyntax("non-multiValued property at json array")
+	} else {
+		i := mv.AppendElement()
+		if i < 0 {
+			return fmt.Errorf("%w: failed to create property to host json array element", spec.ErrInternal)
+		}
+		d.navigator.At(i)
+		if d.navigator.Error() != nil {
+			return d.navigator.Error()
+		}
+	}
+
+	// Parse the focused element property
+	if err := d.parseSingleValuedProperty(); err != nil {
+		return err
+	}
+
+	// Exit the focus
+	d.navigator.Retract()
+	return nil
+}
+
+// Skips the current JSON value, including all nested values of objects and arrays.
+func (d *deserializeState) skipValue() error {
+	switch d.opCode {
+	case scanBeginLiteral:
+		d.scanWhile(scanContinue)
+		return nil
+	case scanBeginObject, scanBeginArray:
+		for depth := 0; ; d.scanNext() {
+			switch d.opCode {
+			case scanBeginObject, scanBeginArray:
+				depth++
+			case scanEndObject, scanEndArray:
+				depth--
+			case scanEnd, scanError:
+				return d.errInvalidSyntax("unexpected end of JSON input")
+			}
+			if depth == 0 {
+				d.scanNext()
+				return nil
+			}
+		}
+	default:
+		return d.errInvalidSyntax("expects property value")
+	}
+}
+
+// Unquotes the string literal between start and end to coerce it into a non-string value, or returns false if the
+// rule is not enabled or the literal is not a string.
+func (d *deserializeState) coerceString(rule Coercion, start int, end int) (string, bool) {
+	if !d.coerces(rule) || d.data[start] != '"' {
+		return "", false
+	}
+	v, ok := unquote(d.data[start:end])
+	return v, ok
+}
+
 // Parses a JSON string. This method expects a double quoted literal and the null literal.
 func (d *deserializeState) parseStringProperty() error {
 	p := d.navigator.Current()
@@ -325,6 +456,14 @@ func (d *deserializeState) parseStringProperty() error {
 		return d.errInvalidSyntax("failed to unquote json string for '%s'", p.Attribute().Path())
 	}
 
+	// elements of the top level "schemas" attribute
+	if d.coerces(CoerceSchemaCase) && d.resourceType != nil && len(d.path) == 2 && p.Attribute().Path() == "schemas" {
+		if schemaId := d.schemaIdFor(v); len(schemaId) > 0 && schemaId != v {
+			d.warn("schema '%s' accepted as '%s'", v, schemaId)
+			v = schemaId
+		}
+	}
+
 	if _, err := d.navigator.Current().Replace(v); err != nil {
 		return err
 	}
@@ -357,9 +496,15 @@ func (d *deserializeState) parseIntegerProperty() error {
 		return nil
 	}
 
-	val, err := strconv.ParseInt(string(d.data[start:end]), 10, 64)
+	literal := string(d.data[start:end])
+	if v, ok := d.coerceString(CoerceNumberString, start, end); ok {
+		d.warn("string accepted as integer")
+		literal = strings.TrimSpace(v)
+	}
+
+	val, err := strconv.ParseInt(literal, 10, 64)
 	if err != nil {
-		return d.errInvalidSyntax("expects integer value")
+		return d.errInvalidSyntax("expects integer value at %s", d.jsonPath())
 	}
 
 	if _, err := d.navigator.Current().Replace(val); err != nil {
@@ -375,7 +520,7 @@ func (d *deserializeState) parseBooleanProperty() error {
 
 	// check property type
 	if p.Attribute().MultiValued() || p.Attribute().Type() != spec.TypeBoolean {
-		return d.errInvalidSyntax("expects decimal property for '%s'", p.Attribute().Path())
+		return d.errInvalidSyntax("expects boolean property for '%s'", p.Attribute().Path())
 	}
 
 	// should start with literal
@@ -394,16 +539,20 @@ func (d *deserializeState) parseBooleanProperty() error {
 		return nil
 	}
 
+	var val bool
 	if d.isTrue(start, end) {
-		if _, err := d.navigator.Current().Replace(true); err != nil {
-			return err
-		}
+		val = true
 	} else if d.isFalse(start, end) {
-		if _, err := d.navigator.Current().Replace(true); err != nil {
-			return err
-		}
+		val = false
+	} else if v, ok := d.coerceString(CoerceBooleanString, start, end); ok && (strings.EqualFold(v, "true") || strings.EqualFold(v, "false")) {
+		d.warn("string accepted as boolean")
+		val = strings.EqualFold(v, "true")
 	} else {
-		return d.errInvalidSyntax("expects boolean value")
+		return d.errInvalidSyntax("expects boolean value at %s", d.jsonPath())
+	}
+
+	if _, err := d.navigator.Current().Replace(val); err != nil {
+		return err
 	}
 
 	return nil
@@ -434,9 +583,15 @@ func (d *deserializeState) parseDecimalProperty() error {
 		return nil
 	}
 
-	val, err := strconv.ParseFloat(string(d.data[start:end]), 64)
+	literal := string(d.data[start:end])
+	if v, ok := d.coerceString(CoerceNumberString, start, end); ok {
+		d.warn("string accepted as decimal")
+		literal = strings.TrimSpace(v)
+	}
+
+	val, err := strconv.ParseFloat(literal, 64)
 	if err != nil {
-		return d.errInvalidSyntax("expects decimal value")
+		return d.errInvalidSyntax("expects decimal value at %s", d.jsonPath())
 	}
 
 	if _, err := d.navigator.Current().Replace(val); err != nil {
@@ -468,6 +623,22 @@ func (d *deserializeState) parseNull() error {
 	return nil
 }
 
+// Returns the id of the main schema or a schema extension of the resource type that matches the URN in any case, or
+// empty if none matches.
+func (d *deserializeState) schemaIdFor(urn string) string {
+	if strings.EqualFold(d.resourceType.Schema().ID(), urn) {
+		return d.resourceType.Schema().ID()
+	}
+	var schemaId string
+	_ = d.resourceType.ForEachExtension(func(extension *spec.Schema, _ bool) error {
+		if strings.EqualFold(extension.ID(), urn) {
+			schemaId = extension.ID()
+		}
+		return nil
+	})
+	return schemaId
+}
+
 func (d *deserializeState) isNull(start, end int) bool {
 	return end-start == 4 &&
 		d.data[start] == 'n' &&
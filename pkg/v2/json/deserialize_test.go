@@ -2,6 +2,7 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/stretchr/testify/assert"
@@ -222,6 +223,101 @@ func (s *JsonDeserializeTestSuite) TestDeserializeResource() {
 	}
 }
 
+func (s *JsonDeserializeTestSuite) TestDeserializeOptions() {
+	tests := []struct {
+		name     string
+		json     string
+		options  []DeserializeOptions
+		expect   func(t *testing.T, resource *prop.Resource, err error)
+		warnings []string
+	}{
+		{
+			name: "strict mode rejects unknown attributes",
+			json: `{"userName":"imulab","emails":[{"value":"foo@bar.com","label":"work"}]}`,
+			expect: func(t *testing.T, resource *prop.Resource, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidSyntax))
+				assert.Contains(t, err.Error(), "unknown attribute at $.emails[0].label")
+			},
+		},
+		{
+			name:    "lenient mode drops unknown attributes",
+			json:    `{"userName":"imulab","urn:foo:Bar":{"nested":[{"a":1},"}"]},"emails":[{"label":"work","value":"foo@bar.com"}],"active":false}`,
+			options: []DeserializeOptions{Lenient()},
+			expect: func(t *testing.T, resource *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "imulab", resource.Navigator().Dot("userName").Current().Raw())
+				assert.Equal(t, "foo@bar.com", resource.Navigator().Dot("emails").At(0).Dot("value").Current().Raw())
+				assert.Equal(t, false, resource.Navigator().Dot("active").Current().Raw())
+			},
+			warnings: []string{
+				"$['urn:foo:Bar']: unknown attribute dropped",
+				"$.emails[0].label: unknown attribute dropped",
+			},
+		},
+		{
+			name:    "strict mode after lenient mode",
+			json:    `{"nickName":"imulab","label":"work"}`,
+			options: []DeserializeOptions{Lenient(), Strict()},
+			expect: func(t *testing.T, resource *prop.Resource, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidSyntax))
+			},
+		},
+		{
+			name: "values are not coerced by default",
+			json: `{"active":"True"}`,
+			expect: func(t *testing.T, resource *prop.Resource, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidSyntax))
+				assert.Contains(t, err.Error(), "$.active")
+			},
+		},
+		{
+			name: "coercions",
+			json: `{
+				"schemas":["URN:IETF:PARAMS:SCIM:SCHEMAS:CORE:2.0:USER"],
+				"active":"True",
+				"emails":{"value":"foo@bar.com","primary":"false"},
+				"phoneNumbers":[{"value":"123","primary":true}]
+			}`,
+			options: []DeserializeOptions{Coerce(CoerceBooleanString, CoerceSingleValue, CoerceSchemaCase)},
+			expect: func(t *testing.T, resource *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"}, resource.Navigator().Dot("schemas").Current().Raw())
+				assert.Equal(t, true, resource.Navigator().Dot("active").Current().Raw())
+				assert.Equal(t, "foo@bar.com", resource.Navigator().Dot("emails").At(0).Dot("value").Current().Raw())
+				assert.Equal(t, false, resource.Navigator().Dot("emails").At(0).Dot("primary").Current().Raw())
+			},
+			warnings: []string{
+				"$.schemas[0]: schema 'URN:IETF:PARAMS:SCIM:SCHEMAS:CORE:2.0:USER' accepted as 'urn:ietf:params:scim:schemas:core:2.0:User'",
+				"$.active: string accepted as boolean",
+				"$.emails: single value accepted as array",
+				"$.emails[0].primary: string accepted as boolean",
+			},
+		},
+		{
+			name:    "coercion rejects invalid values",
+			json:    `{"active":"yes"}`,
+			options: []DeserializeOptions{Coerce(CoerceBooleanString)},
+			expect: func(t *testing.T, resource *prop.Resource, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidSyntax))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			var warnings []string
+			options := append(test.options, ReportWarnings(func(warning *Warning) {
+				warnings = append(warnings, warning.String())
+			}))
+
+			resource := prop.NewResource(s.resourceType)
+			err := Deserialize([]byte(test.json), resource, options...)
+			test.expect(t, resource, err)
+			assert.Equal(t, test.warnings, warnings)
+		})
+	}
+}
+
 func (s *JsonDeserializeTestSuite) TestDeserializeProperty() {
 	tests := []struct {
 		name   string
@@ -0,0 +1,121 @@
+package json
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// listResponseSchema is the schema of the SCIM list response message.
+const listResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+
+// NewListEncoder returns a ListEncoder that writes a SCIM list response with the given pagination figures to the writer.
+// The options apply to every resource encoded.
+func NewListEncoder(w io.Writer, totalResults, startIndex, itemsPerPage int, options ...Options) *ListEncoder {
+	return &ListEncoder{
+		w:            w,
+		options:      options,
+		totalResults: totalResults,
+		startIndex:   startIndex,
+		itemsPerPage: itemsPerPage,
+	}
+}
+
+// ListEncoder writes a SCIM list response to an io.Writer in one pass: the envelope and each resource are serialized
+// directly to the writer as they are encoded, without rendering the resources to intermediate byte slices first.
+//
+// The output is identical to encoding the envelope using encoding/json with pre-rendered resources: the envelope
+// fields come first, the "Resources" field is omitted when no resource was encoded, and the document ends with a
+// newline.
+//
+// Resources must be encoded in order with Encode, and the response finished with Close. Because the envelope is
+// written along with the first resource, nothing is written if the first resource fails to serialize; however, the
+// failure of any later resource leaves the response incomplete. Errors are sticky: once an error has occurred, Encode
+// and Close return it without writing.
+type ListEncoder struct {
+	w            io.Writer
+	options      []Options
+	totalResults int
+	startIndex   int
+	itemsPerPage int
+	count        int
+	closed       bool
+	err          error
+}
+
+// Encode serializes the resource as the next element of the "Resources" field.
+func (e *ListEncoder) Encode(serializable Serializable) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.closed {
+		return errors.New("list encoder is already closed")
+	}
+
+	s := acquireSerializer()
+	defer releaseSerializer(s)
+
+	// The separator, and the envelope in case of the first resource, are written into the
+	// serializer buffer after the resource, so that nothing is written when serialization fails.
+	if err := s.serialize(serializable, e.options); err != nil {
+		e.err = err
+		return err
+	}
+	resource := s.Len()
+	if e.count == 0 {
+		e.appendEnvelope(s)
+		_, _ = s.WriteString(`,"Resources":[`)
+	} else {
+		_ = s.WriteByte(',')
+	}
+
+	raw := s.Bytes()
+	if _, err := e.w.Write(raw[resource:]); err != nil {
+		e.err = err
+		return err
+	}
+	if _, err := e.w.Write(raw[:resource]); err != nil {
+		e.err = err
+		return err
+	}
+
+	e.count++
+	return nil
+}
+
+// Close finishes the list response. Close must be called once all resources are encoded, even if there are none.
+func (e *ListEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	s := acquireSerializer()
+	defer releaseSerializer(s)
+
+	if e.count == 0 {
+		e.appendEnvelope(s)
+		_, _ = s.WriteString("}\n")
+	} else {
+		_, _ = s.WriteString("]}\n")
+	}
+
+	if _, err := s.WriteTo(e.w); err != nil {
+		e.err = err
+		return err
+	}
+	return nil
+}
+
+// appendEnvelope appends the list response fields, without the resources and the closing brace.
+func (e *ListEncoder) appendEnvelope(s *serializer) {
+	_, _ = s.WriteString(`{"schemas":["` + listResponseSchema + `"],"totalResults":`)
+	_, _ = s.Write(strconv.AppendInt(s.scratch[:0], int64(e.totalResults), 10))
+	_, _ = s.WriteString(`,"startIndex":`)
+	_, _ = s.Write(strconv.AppendInt(s.scratch[:0], int64(e.startIndex), 10))
+	_, _ = s.WriteString(`,"itemsPerPage":`)
+	_, _ = s.Write(strconv.AppendInt(s.scratch[:0], int64(e.itemsPerPage), 10))
+}
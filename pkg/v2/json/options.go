@@ -16,6 +16,14 @@ func Exclude(attributes ...string) Options {
 	return exclude{attributes: attributes}
 }
 
+// Request returns Options to serialize the resource as the payload of a request to the service provider, instead of as
+// a response. In this mode, the SCIM rules for return-ability do not apply: all assigned properties are serialized,
+// including writeOnly ones (i.e. password), except the readOnly ones which the service provider would ignore anyway.
+// This option cannot be combined with Include or Exclude.
+func Request() Options {
+	return request{}
+}
+
 // JSON serialization options.
 type Options interface {
 	apply(s *serializer, serializable Serializable)
@@ -56,3 +64,87 @@ func (e exclude) apply(s *serializer, serializable Serializable) {
 		}
 	}
 }
+
+type request struct{}
+
+func (r request) apply(s *serializer, _ Serializable) {
+	s.request = true
+}
+
+// Strict returns DeserializeOptions to reject attributes unknown to the resource type with spec.ErrInvalidSyntax,
+// reporting the JSON path of the attribute. This is the default behaviour, and reverts a previous Lenient option.
+func Strict() DeserializeOptions {
+	return lenient(false)
+}
+
+// Lenient returns DeserializeOptions to drop attributes unknown to the resource type, together with their values,
+// instead of failing. Each dropped attribute is reported as a Warning to the function registered with ReportWarnings.
+func Lenient() DeserializeOptions {
+	return lenient(true)
+}
+
+// Coerce returns DeserializeOptions to accept JSON values that do not match the attribute characteristics, but are
+// known to be sent by some identity providers, by converting them according to the rules. Each conversion is reported
+// as a Warning to the function registered with ReportWarnings.
+func Coerce(rules ...Coercion) DeserializeOptions {
+	c := coerce(0)
+	for _, each := range rules {
+		c |= coerce(each)
+	}
+	return c
+}
+
+// ReportWarnings returns DeserializeOptions to report problems tolerated by Lenient and Coerce to the function.
+func ReportWarnings(report func(warning *Warning)) DeserializeOptions {
+	return reportWarnings(report)
+}
+
+// Coercion is a rule to convert JSON values that do not match the attribute characteristics.
+type Coercion int
+
+const (
+	// CoerceBooleanString accepts the strings "true" and "false", in any case, for boolean attributes.
+	CoerceBooleanString Coercion = 1 << iota
+	// CoerceNumberString accepts strings containing a number for integer and decimal attributes.
+	CoerceNumberString
+	// CoerceSingleValue accepts a single value for a multiValued attribute, as an array containing the value.
+	CoerceSingleValue
+	// CoerceSchemaCase accepts schema URNs in the "schemas" attribute in any case, replacing them with the schema id of
+	// the resource type or its extension that they match.
+	CoerceSchemaCase
+)
+
+// Warning is a problem in the JSON input tolerated by deserialization.
+type Warning struct {
+	// JSON path of the value with the problem, i.e. $.emails[0].value
+	Path string
+	// Description of the problem
+	Message string
+}
+
+func (w *Warning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+// JSON deserialization options.
+type DeserializeOptions interface {
+	applyDeserialize(d *deserializeState)
+}
+
+type lenient bool
+
+func (l lenient) applyDeserialize(d *deserializeState) {
+	d.lenient = bool(l)
+}
+
+type coerce Coercion
+
+func (c coerce) applyDeserialize(d *deserializeState) {
+	d.coerce |= Coercion(c)
+}
+
+type reportWarnings func(warning *Warning)
+
+func (r reportWarnings) applyDeserialize(d *deserializeState) {
+	d.report = r
+}
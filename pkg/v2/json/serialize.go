@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/spec"
+	"io"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode/utf8"
 )
 
@@ -22,26 +24,52 @@ type Serializable interface {
 // Serialize the given resource to JSON bytes. The serialization process subjects to the request attributes and
 // excludedAttributes from options, and the SCIM return-ability rules.
 func Serialize(serializable Serializable, options ...Options) ([]byte, error) {
-	s := serializer{
-		Buffer:   bytes.Buffer{},
-		includes: []string{},
-		excludes: []string{},
-		stack:    []*frame{},
-		scratch:  [64]byte{},
-	}
-	for _, opt := range options {
-		opt.apply(&s, serializable)
-	}
+	s := acquireSerializer()
+	defer releaseSerializer(s)
 
-	if len(s.includes) > 0 && len(s.excludes) > 0 {
-		return nil, fmt.Errorf("%w: attributes and excludedAttributes are mutually exclusive", spec.ErrInvalidValue)
+	if err := s.serialize(serializable, options); err != nil {
+		return nil, err
 	}
 
-	if err := serializable.Visit(&s); err != nil {
-		return nil, err
+	// The buffer goes back to the pool, hence the copy.
+	return append([]byte(nil), s.Bytes()...), nil
+}
+
+// SerializeTo serializes the given resource like Serialize, but writes the JSON bytes to the writer instead of
+// returning them. The serializer state is taken from a pool and reused across calls, so that no intermediate byte
+// slices are allocated. The resource is serialized in full before writing, so nothing is written upon error.
+func SerializeTo(w io.Writer, serializable Serializable, options ...Options) error {
+	s := acquireSerializer()
+	defer releaseSerializer(s)
+
+	if err := s.serialize(serializable, options); err != nil {
+		return err
 	}
 
-	return s.Bytes(), nil
+	_, err := s.WriteTo(w)
+	return err
+}
+
+// Buffers grown beyond this capacity are not returned to the pool, so that a single large resource does not pin
+// memory for the lifetime of the process.
+const maxPooledBufferSize = 64 << 10
+
+var serializers = sync.Pool{
+	New: func() interface{} {
+		return new(serializer)
+	},
+}
+
+func acquireSerializer() *serializer {
+	return serializers.Get().(*serializer)
+}
+
+func releaseSerializer(s *serializer) {
+	if s.Cap() > maxPooledBufferSize {
+		return
+	}
+	s.reset()
+	serializers.Put(s)
 }
 
 const (
@@ -64,14 +92,46 @@ type (
 		bytes.Buffer
 		includes []string
 		excludes []string
-		stack    []*frame
+		stack    []frame
 		scratch  [64]byte
+		request  bool
 	}
 )
 
+// serialize resets the state and appends the JSON representation of serializable to the buffer.
+func (s *serializer) serialize(serializable Serializable, options []Options) error {
+	s.reset()
+	for _, opt := range options {
+		opt.apply(s, serializable)
+	}
+
+	if len(s.includes) > 0 && len(s.excludes) > 0 {
+		return fmt.Errorf("%w: attributes and excludedAttributes are mutually exclusive", spec.ErrInvalidValue)
+	}
+	if s.request && (len(s.includes) > 0 || len(s.excludes) > 0) {
+		return fmt.Errorf("%w: request serialization cannot include or exclude attributes", spec.ErrInvalidValue)
+	}
+
+	return serializable.Visit(s)
+}
+
+// reset clears the state for reuse, while retaining the allocated capacities.
+func (s *serializer) reset() {
+	s.Reset()
+	s.includes = s.includes[:0]
+	s.excludes = s.excludes[:0]
+	s.stack = s.stack[:0]
+	s.request = false
+}
+
 func (s *serializer) ShouldVisit(property prop.Property) bool {
 	attr := property.Attribute()
 
+	// Request payload carries all assigned properties, except readOnly ones.
+	if s.request {
+		return attr.Mutability() != spec.MutabilityReadOnly && !property.IsUnassigned()
+	}
+
 	// Write only properties are never returned. It is usually coupled
 	// with returned=never, but we will check it to make sure.
 	if attr.Mutability() == spec.MutabilityWriteOnly {
@@ -311,7 +371,7 @@ func (s *serializer) appendBoolean(value bool) {
 }
 
 func (s *serializer) push(c container) {
-	s.stack = append(s.stack, &frame{
+	s.stack = append(s.stack, frame{
 		container: c,
 		index:     0,
 	})
@@ -328,5 +388,5 @@ func (s *serializer) current() *frame {
 	if len(s.stack) == 0 {
 		panic("stack is empty")
 	}
-	return s.stack[len(s.stack)-1]
+	return &s.stack[len(s.stack)-1]
 }
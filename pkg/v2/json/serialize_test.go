@@ -1,12 +1,14 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/spec"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"io"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -188,6 +190,30 @@ func (s *JsonSerializeTestSuite) TestSerialize() {
 				assert.JSONEq(t, expect, string(raw))
 			},
 		},
+		{
+			name: "request payload",
+			getResource: func(t *testing.T) *prop.Resource {
+				r := prop.NewResource(s.resourceType)
+				_, err := r.RootProperty().Replace(s.resourceData)
+				assert.Nil(t, err)
+				assert.False(t, r.Navigator().Dot("password").Replace("s3cret").HasError())
+				return r
+			},
+			options: []Options{
+				Request(),
+			},
+			expect: func(t *testing.T, raw []byte, err error) {
+				assert.Nil(t, err)
+				var payload map[string]interface{}
+				assert.Nil(t, json.Unmarshal(raw, &payload))
+				assert.Equal(t, "s3cret", payload["password"])
+				assert.Equal(t, "imulab", payload["userName"])
+				assert.Contains(t, payload, "schemas")
+				assert.NotContains(t, payload, "id")
+				assert.NotContains(t, payload, "meta")
+				assert.NotContains(t, payload, "groups")
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -199,7 +225,116 @@ func (s *JsonSerializeTestSuite) TestSerialize() {
 	}
 }
 
+func (s *JsonSerializeTestSuite) TestSerializeTo() {
+	resource := s.newResource(s.T())
+
+	expect, err := Serialize(resource, Exclude("emails"))
+	require.Nil(s.T(), err)
+
+	// repeat to exercise reuse of pooled serializer states
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		assert.Nil(s.T(), SerializeTo(&buf, resource, Exclude("emails")))
+		assert.Equal(s.T(), string(expect), buf.String())
+	}
+
+	var buf bytes.Buffer
+	assert.NotNil(s.T(), SerializeTo(&buf, resource, Include("userName"), Exclude("emails")))
+	assert.Equal(s.T(), 0, buf.Len())
+}
+
+func (s *JsonSerializeTestSuite) TestListEncoder() {
+	tests := []struct {
+		name      string
+		resources int
+		options   []Options
+		expect    func(t *testing.T, raw []byte, err error)
+	}{
+		{
+			name:      "empty list",
+			resources: 0,
+			expect: func(t *testing.T, raw []byte, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],"totalResults":0,"startIndex":1,"itemsPerPage":0}`+"\n", string(raw))
+			},
+		},
+		{
+			name:      "list with projection",
+			resources: 2,
+			options:   []Options{Include("userName")},
+			expect: func(t *testing.T, raw []byte, err error) {
+				assert.Nil(t, err)
+				var payload struct {
+					TotalResults int                      `json:"totalResults"`
+					Resources    []map[string]interface{} `json:"Resources"`
+				}
+				assert.Nil(t, json.Unmarshal(raw, &payload))
+				assert.Equal(t, 2, payload.TotalResults)
+				if assert.Len(t, payload.Resources, 2) {
+					assert.Equal(t, "imulab", payload.Resources[1]["userName"])
+					assert.NotContains(t, payload.Resources[1], "emails")
+				}
+			},
+		},
+		{
+			name:      "error on first resource",
+			resources: 2,
+			options:   []Options{Include("userName"), Exclude("emails")},
+			expect: func(t *testing.T, raw []byte, err error) {
+				assert.NotNil(t, err)
+				assert.Len(t, raw, 0)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			var (
+				buf bytes.Buffer
+				err error
+			)
+			enc := NewListEncoder(&buf, test.resources, 1, test.resources, test.options...)
+			for i := 0; i < test.resources && err == nil; i++ {
+				err = enc.Encode(s.newResource(t))
+			}
+			if err == nil {
+				err = enc.Close()
+			}
+			test.expect(t, buf.Bytes(), err)
+		})
+	}
+}
+
+func (s *JsonSerializeTestSuite) TestListEncoderCompatibility() {
+	// The list encoder must produce the same bytes as encoding/json with pre-rendered resources.
+	resources := []*prop.Resource{s.newResource(s.T()), s.newResource(s.T())}
+
+	var expect bytes.Buffer
+	require.Nil(s.T(), encodeListWithRawMessages(&expect, resources))
+
+	var actual bytes.Buffer
+	enc := NewListEncoder(&actual, len(resources), 1, len(resources))
+	for _, resource := range resources {
+		require.Nil(s.T(), enc.Encode(resource))
+	}
+	require.Nil(s.T(), enc.Close())
+
+	assert.Equal(s.T(), expect.String(), actual.String())
+}
+
+func (s *JsonSerializeTestSuite) newResource(t *testing.T) *prop.Resource {
+	r := prop.NewResource(s.resourceType)
+	_, err := r.RootProperty().Replace(s.resourceData)
+	require.Nil(t, err)
+	return r
+}
+
 func (s *JsonSerializeTestSuite) SetupSuite() {
+	s.resourceType, s.resourceData = loadSerializeTestData(s.T())
+}
+
+// loadSerializeTestData registers the user schemas, and returns the user resource type with a fully populated user.
+func loadSerializeTestData(t testing.TB) (resourceType *spec.ResourceType, resourceData interface{}) {
 	for _, each := range []struct {
 		filepath  string
 		structure interface{}
@@ -223,25 +358,25 @@ func (s *JsonSerializeTestSuite) SetupSuite() {
 			filepath:  "../../../public/resource_types/user_resource_type.json",
 			structure: new(spec.ResourceType),
 			post: func(parsed interface{}) {
-				s.resourceType = parsed.(*spec.ResourceType)
+				resourceType = parsed.(*spec.ResourceType)
 			},
 		},
 	} {
 		f, err := os.Open(each.filepath)
-		require.Nil(s.T(), err)
+		require.Nil(t, err)
 
 		raw, err := ioutil.ReadAll(f)
-		require.Nil(s.T(), err)
+		require.Nil(t, err)
 
 		err = json.Unmarshal(raw, each.structure)
-		require.Nil(s.T(), err)
+		require.Nil(t, err)
 
 		if each.post != nil {
 			each.post(each.structure)
 		}
 	}
 
-	s.resourceData = map[string]interface{}{
+	resourceData = map[string]interface{}{
 		"schemas": []interface{}{
 			"urn:ietf:params:scim:schemas:core:2.0:User",
 		},
@@ -294,4 +429,97 @@ func (s *JsonSerializeTestSuite) SetupSuite() {
 			},
 		},
 	}
+	return
+}
+
+// encodeListWithRawMessages renders the list response by serializing each resource to bytes first, and then
+// encoding the envelope with encoding/json. This was the way list responses were written before ListEncoder.
+func encodeListWithRawMessages(w io.Writer, resources []*prop.Resource) error {
+	render := struct {
+		Schemas      []string          `json:"schemas"`
+		TotalResults int               `json:"totalResults"`
+		StartIndex   int               `json:"startIndex"`
+		ItemsPerPage int               `json:"itemsPerPage"`
+		Resources    []json.RawMessage `json:"Resources,omitempty"`
+	}{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+	}
+	for _, resource := range resources {
+		raw, err := Serialize(resource)
+		if err != nil {
+			return err
+		}
+		render.Resources = append(render.Resources, raw)
+	}
+	return json.NewEncoder(w).Encode(render)
+}
+
+func benchmarkResources(b *testing.B, n int) []*prop.Resource {
+	resourceType, resourceData := loadSerializeTestData(b)
+	resources := make([]*prop.Resource, 0, n)
+	for i := 0; i < n; i++ {
+		r := prop.NewResource(resourceType)
+		if _, err := r.RootProperty().Replace(resourceData); err != nil {
+			b.Fatal(err)
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	resource := benchmarkResources(b, 1)[0]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		raw, err := Serialize(resource)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := ioutil.Discard.Write(raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSerializeTo(b *testing.B) {
+	resource := benchmarkResources(b, 1)[0]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := SerializeTo(ioutil.Discard, resource); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListWithRawMessages(b *testing.B) {
+	resources := benchmarkResources(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := encodeListWithRawMessages(ioutil.Discard, resources); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListEncoder(b *testing.B) {
+	resources := benchmarkResources(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		enc := NewListEncoder(ioutil.Discard, len(resources), 1, len(resources))
+		for _, resource := range resources {
+			if err := enc.Encode(resource); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
@@ -0,0 +1,13 @@
+// This package describes SCIM resource types as machine readable contracts.
+//
+// JSONSchema renders a resource type, together with the core attributes and its schema extensions, as a JSON Schema
+// (draft 2020-12) document. Attribute characteristics map to JSON Schema keywords: type and multiValued to "type" and
+// "items", required to "required", mutability and returned to "readOnly" and "writeOnly", and canonicalValues to "enum"
+// when enforced by @Enum, or "examples" otherwise.
+//
+// Document renders an OpenAPI 3.1 document for the SCIM endpoints of a set of resource types, which embeds the JSON
+// Schema of each resource type as a component, together with the ListResponse, PatchOp and Error message envelopes
+// defined in RFC7644.
+//
+// Both functions return generic maps which are ready to be marshaled with encoding/json.
+package openapi
@@ -0,0 +1,122 @@
+package openapi
+
+import (
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// JSONSchemaDialect is the URI of the JSON Schema dialect generated by this package.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns the JSON Schema (draft 2020-12) document describing resources of the resource type. The document
+// includes the core attributes, the attributes of the main schema, and one object property per schema extension,
+// keyed by the extension's schema id.
+func JSONSchema(resourceType *spec.ResourceType) map[string]interface{} {
+	doc := resourceSchema(resourceType)
+	doc["$schema"] = JSONSchemaDialect
+	doc["$id"] = resourceType.Schema().ID()
+	return doc
+}
+
+// resourceSchema returns the JSON Schema of the resource type without the dialect and id keywords, so that it can be
+// embedded in other documents.
+func resourceSchema(resourceType *spec.ResourceType) map[string]interface{} {
+	doc := attributeSchema(resourceType.SuperAttribute(true))
+	doc["title"] = resourceType.Name()
+	if len(resourceType.Description()) > 0 {
+		doc["description"] = resourceType.Description()
+	}
+	return doc
+}
+
+// attributeSchema returns the JSON Schema describing the values of the attribute.
+func attributeSchema(attr *spec.Attribute) map[string]interface{} {
+	if attr.MultiValued() {
+		s := map[string]interface{}{
+			"type":  "array",
+			"items": singularSchema(attr),
+		}
+		if attr.Required() {
+			// empty multiValued attributes are unassigned
+			s["minItems"] = 1
+		}
+		decorate(s, attr)
+		return s
+	}
+
+	s := singularSchema(attr)
+	decorate(s, attr)
+	return s
+}
+
+// singularSchema returns the JSON Schema describing a single value of the attribute, or an element of a multiValued
+// attribute.
+func singularSchema(attr *spec.Attribute) map[string]interface{} {
+	s := map[string]interface{}{}
+
+	switch attr.Type() {
+	case spec.TypeString:
+		s["type"] = "string"
+	case spec.TypeInteger:
+		s["type"] = "integer"
+	case spec.TypeDecimal:
+		s["type"] = "number"
+	case spec.TypeBoolean:
+		s["type"] = "boolean"
+	case spec.TypeDateTime:
+		s["type"] = "string"
+		s["format"] = "date-time"
+	case spec.TypeReference:
+		s["type"] = "string"
+		s["format"] = "uri-reference"
+	case spec.TypeBinary:
+		s["type"] = "string"
+		s["contentEncoding"] = "base64"
+	case spec.TypeComplex:
+		properties := map[string]interface{}{}
+		var required []string
+		_ = attr.ForEachSubAttribute(func(subAttribute *spec.Attribute) error {
+			properties[subAttribute.Name()] = attributeSchema(subAttribute)
+			if subAttribute.Required() {
+				required = append(required, subAttribute.Name())
+			}
+			return nil
+		})
+		s["type"] = "object"
+		s["properties"] = properties
+		if len(required) > 0 {
+			s["required"] = required
+		}
+	}
+
+	if attr.CountCanonicalValues() > 0 {
+		var values []interface{}
+		attr.ForEachCanonicalValues(func(canonicalValue string) {
+			values = append(values, canonicalValue)
+		})
+		if _, ok := attr.Annotation(annotation.Enum); ok {
+			s["enum"] = values
+		} else {
+			s["examples"] = values
+		}
+	}
+
+	return s
+}
+
+// decorate adds the annotation keywords derived from the attribute's description, mutability and returned
+// characteristics to the schema.
+func decorate(s map[string]interface{}, attr *spec.Attribute) {
+	if len(attr.Description()) > 0 {
+		s["description"] = attr.Description()
+	}
+	switch attr.Mutability() {
+	case spec.MutabilityReadOnly:
+		s["readOnly"] = true
+	case spec.MutabilityWriteOnly:
+		s["writeOnly"] = true
+	}
+	if attr.Returned() == spec.ReturnedNever {
+		s["writeOnly"] = true
+	}
+}
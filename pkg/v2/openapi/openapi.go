@@ -0,0 +1,295 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification of the generated documents. Version 3.1 is the first to
+// adopt JSON Schema draft 2020-12 for its schema objects.
+const OpenAPIVersion = "3.1.0"
+
+// Message schemas defined in RFC7644.
+const (
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Info is the metadata of the API described by an OpenAPI document.
+type Info struct {
+	// Title of the API
+	Title string
+	// Version of the API, as opposed to the version of the OpenAPI specification.
+	Version string
+	// Optional description of the API
+	Description string
+	// Optional URLs of the servers hosting the API, against which the paths are resolved.
+	Servers []string
+}
+
+// Document returns the OpenAPI 3.1 document describing the SCIM endpoints for the resource types, which are the
+// endpoints served by the api command: create, get, replace, patch, delete and search for each resource type, together
+// with /ServiceProviderConfig, /Schemas and /ResourceTypes.
+//
+// The JSON Schema of each resource type is a component named by the resource type id, and is referenced by the
+// operations of the resource type. The generic ListResponse, PatchOp and Error envelopes are components as well, and
+// search responses of each resource type refine ListResponse with the component named by the resource type id followed
+// by "ListResponse".
+func Document(info Info, resourceTypes ...*spec.ResourceType) map[string]interface{} {
+	schemas := map[string]interface{}{
+		"ListResponse": listResponseSchema(),
+		"PatchOp":      patchOpSchema(),
+		"Error":        errorSchema(),
+	}
+	paths := map[string]interface{}{
+		"/ServiceProviderConfig": map[string]interface{}{
+			"get": operation("getServiceProviderConfig", "Get the service provider configuration", "Discovery",
+				nil, okResponse(http.StatusOK, "Service provider configuration", map[string]interface{}{"type": "object"})),
+		},
+		"/Schemas": map[string]interface{}{
+			"get": operation("listSchemas", "List the schemas", "Discovery",
+				nil, okResponse(http.StatusOK, "All schemas", schemaRef("ListResponse"))),
+		},
+		"/Schemas/{id}": map[string]interface{}{
+			"parameters": []interface{}{parameterRef("id")},
+			"get": operation("getSchema", "Get the schema by id", "Discovery",
+				nil, okResponse(http.StatusOK, "The schema", map[string]interface{}{"type": "object"})),
+		},
+		"/ResourceTypes": map[string]interface{}{
+			"get": operation("listResourceTypes", "List the resource types", "Discovery",
+				nil, okResponse(http.StatusOK, "All resource types", schemaRef("ListResponse"))),
+		},
+		"/ResourceTypes/{id}": map[string]interface{}{
+			"parameters": []interface{}{parameterRef("id")},
+			"get": operation("getResourceType", "Get the resource type by id", "Discovery",
+				nil, okResponse(http.StatusOK, "The resource type", map[string]interface{}{"type": "object"})),
+		},
+	}
+	var tags []interface{}
+
+	for _, resourceType := range resourceTypes {
+		name := resourceType.ID()
+		resource := schemaRef(name)
+		schemas[name] = resourceSchema(resourceType)
+		schemas[name+"ListResponse"] = map[string]interface{}{
+			"allOf": []interface{}{
+				schemaRef("ListResponse"),
+				map[string]interface{}{
+					"properties": map[string]interface{}{
+						"Resources": map[string]interface{}{"type": "array", "items": resource},
+					},
+				},
+			},
+		}
+
+		tag := map[string]interface{}{"name": name}
+		if len(resourceType.Description()) > 0 {
+			tag["description"] = resourceType.Description()
+		}
+		tags = append(tags, tag)
+
+		paths[resourceType.Endpoint()] = map[string]interface{}{
+			"get": operation("search"+name, "Search "+name+" resources", name,
+				[]interface{}{parameterRef("filter"), parameterRef("sortBy"), parameterRef("sortOrder"), parameterRef("startIndex"), parameterRef("count"),
+					parameterRef("attributes"), parameterRef("excludedAttributes")},
+				okResponse(http.StatusOK, "Matching "+name+" resources", schemaRef(name+"ListResponse"))),
+			"post": withBody(operation("create"+name, "Create a "+name+" resource", name,
+				[]interface{}{parameterRef("attributes"), parameterRef("excludedAttributes")},
+				okResponse(http.StatusCreated, "The created "+name+" resource", resource)), resource),
+		}
+		paths[resourceType.Endpoint()+"/{id}"] = map[string]interface{}{
+			"parameters": []interface{}{parameterRef("id")},
+			"get": operation("get"+name, "Get the "+name+" resource by id", name,
+				[]interface{}{parameterRef("attributes"), parameterRef("excludedAttributes"), parameterRef("If-None-Match")},
+				okResponse(http.StatusOK, "The "+name+" resource", resource)),
+			"put": withBody(operation("replace"+name, "Replace the "+name+" resource", name,
+				[]interface{}{parameterRef("attributes"), parameterRef("excludedAttributes"), parameterRef("If-Match")},
+				okResponse(http.StatusOK, "The replaced "+name+" resource", resource)), resource),
+			"patch": withBody(operation("patch"+name, "Modify the "+name+" resource", name,
+				[]interface{}{parameterRef("attributes"), parameterRef("excludedAttributes"), parameterRef("If-Match")},
+				okResponse(http.StatusOK, "The modified "+name+" resource", resource)), schemaRef("PatchOp")),
+			"delete": operation("delete"+name, "Delete the "+name+" resource", name,
+				[]interface{}{parameterRef("If-Match")},
+				map[string]interface{}{"204": map[string]interface{}{"description": "The " + name + " resource is deleted"}}),
+		}
+	}
+
+	infoObject := map[string]interface{}{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if len(info.Description) > 0 {
+		infoObject["description"] = info.Description
+	}
+
+	doc := map[string]interface{}{
+		"openapi":           OpenAPIVersion,
+		"jsonSchemaDialect": JSONSchemaDialect,
+		"info":              infoObject,
+		"paths":             paths,
+		"components": map[string]interface{}{
+			"schemas":    schemas,
+			"parameters": parameters(),
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "SCIM error message",
+					"content":     scimContent(schemaRef("Error")),
+				},
+			},
+		},
+	}
+	if len(tags) > 0 {
+		doc["tags"] = append([]interface{}{map[string]interface{}{"name": "Discovery"}}, tags...)
+	}
+	if len(info.Servers) > 0 {
+		var servers []interface{}
+		for _, each := range info.Servers {
+			servers = append(servers, map[string]interface{}{"url": each})
+		}
+		doc["servers"] = servers
+	}
+	return doc
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func parameterRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/parameters/" + name}
+}
+
+func operation(id string, summary string, tag string, parameters []interface{}, responses map[string]interface{}) map[string]interface{} {
+	responses["default"] = map[string]interface{}{"$ref": "#/components/responses/Error"}
+	op := map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+		"tags":        []interface{}{tag},
+		"responses":   responses,
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	return op
+}
+
+func withBody(op map[string]interface{}, schema map[string]interface{}) map[string]interface{} {
+	op["requestBody"] = map[string]interface{}{
+		"required": true,
+		"content":  scimContent(schema),
+	}
+	return op
+}
+
+func okResponse(status int, description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		strconv.Itoa(status): map[string]interface{}{
+			"description": description,
+			"content":     scimContent(schema),
+		},
+	}
+}
+
+func scimContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		spec.ApplicationScimJson: map[string]interface{}{"schema": schema},
+	}
+}
+
+func parameters() map[string]interface{} {
+	query := func(name string, description string, schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": description,
+			"schema":      schema,
+		}
+	}
+	header := func(name string, description string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":        name,
+			"in":          "header",
+			"description": description,
+			"schema":      map[string]interface{}{"type": "string"},
+		}
+	}
+	str := map[string]interface{}{"type": "string"}
+	return map[string]interface{}{
+		"id": map[string]interface{}{
+			"name":     "id",
+			"in":       "path",
+			"required": true,
+			"schema":   str,
+		},
+		"filter":             query("filter", "SCIM filter expression to match resources", str),
+		"sortBy":             query("sortBy", "Attribute path to sort resources by", str),
+		"sortOrder":          query("sortOrder", "Order to sort resources in", map[string]interface{}{"type": "string", "enum": []interface{}{"ascending", "descending"}}),
+		"startIndex":         query("startIndex", "1-based index of the first resource to return", map[string]interface{}{"type": "integer", "minimum": 1}),
+		"count":              query("count", "Maximum number of resources to return", map[string]interface{}{"type": "integer", "minimum": 0}),
+		"attributes":         query("attributes", "Comma separated attribute paths to return in addition to those always returned", str),
+		"excludedAttributes": query("excludedAttributes", "Comma separated attribute paths to exclude from those returned by default", str),
+		"If-Match":           header("If-Match", "Only proceed if the resource version matches one of the comma separated versions, or *"),
+		"If-None-Match":      header("If-None-Match", "Only proceed if the resource version matches none of the comma separated versions"),
+	}
+}
+
+func messageSchemas(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "array",
+		"items":    map[string]interface{}{"type": "string"},
+		"contains": map[string]interface{}{"const": id},
+	}
+}
+
+func listResponseSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"schemas":      messageSchemas(ListResponseSchema),
+			"totalResults": map[string]interface{}{"type": "integer"},
+			"startIndex":   map[string]interface{}{"type": "integer"},
+			"itemsPerPage": map[string]interface{}{"type": "integer"},
+			"Resources":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+		},
+		"required": []interface{}{"schemas", "totalResults"},
+	}
+}
+
+func patchOpSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"schemas": messageSchemas(PatchOpSchema),
+			"Operations": map[string]interface{}{
+				"type":     "array",
+				"minItems": 1,
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"op":    map[string]interface{}{"type": "string", "description": "One of add, remove or replace, case insensitive"},
+						"path":  map[string]interface{}{"type": "string"},
+						"value": map[string]interface{}{},
+					},
+					"required": []interface{}{"op"},
+				},
+			},
+		},
+		"required": []interface{}{"schemas", "Operations"},
+	}
+}
+
+func errorSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"schemas":  messageSchemas(ErrorSchema),
+			"status":   map[string]interface{}{"type": "integer"},
+			"scimType": map[string]interface{}{"type": "string"},
+			"detail":   map[string]interface{}{"type": "string"},
+		},
+		"required": []interface{}{"schemas", "status"},
+	}
+}
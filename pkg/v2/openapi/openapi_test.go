@@ -0,0 +1,169 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	s := new(OpenAPITestSuite)
+	suite.Run(t, s)
+}
+
+type OpenAPITestSuite struct {
+	suite.Suite
+	resourceTypes []*spec.ResourceType
+}
+
+const testExtension = `{
+	"id": "urn:imulab:scim:schemas:extension:test:2.0:User",
+	"name": "TestUser",
+	"attributes": [
+		{
+			"id": "urn:imulab:scim:schemas:extension:test:2.0:User:clearance",
+			"name": "clearance",
+			"type": "string",
+			"required": true,
+			"canonicalValues": ["public", "secret"],
+			"_index": 0,
+			"_path": "clearance",
+			"_annotations": {
+				"@Enum": {}
+			}
+		}
+	]
+}`
+
+func (s *OpenAPITestSuite) SetupSuite() {
+	for _, path := range []string{
+		"../../../public/schemas/core_schema.json",
+		"../../../public/schemas/user_schema.json",
+		"../../../public/schemas/group_schema.json",
+	} {
+		s.register(s.read(path))
+	}
+	s.register([]byte(testExtension))
+
+	user := new(spec.ResourceType)
+	s.Require().Nil(json.Unmarshal([]byte(`{
+		"id": "User",
+		"name": "User",
+		"description": "User Account",
+		"endpoint": "/Users",
+		"schema": "urn:ietf:params:scim:schemas:core:2.0:User",
+		"schemaExtensions": [{"schema": "urn:imulab:scim:schemas:extension:test:2.0:User", "required": true}]
+	}`), user))
+
+	group := new(spec.ResourceType)
+	s.Require().Nil(json.Unmarshal(s.read("../../../public/resource_types/group_resource_type.json"), group))
+
+	s.resourceTypes = []*spec.ResourceType{user, group}
+}
+
+func (s *OpenAPITestSuite) read(path string) []byte {
+	raw, err := ioutil.ReadFile(path)
+	s.Require().Nil(err)
+	return raw
+}
+
+func (s *OpenAPITestSuite) register(raw []byte) {
+	schema := new(spec.Schema)
+	s.Require().Nil(json.Unmarshal(raw, schema))
+	spec.Schemas().Register(schema)
+}
+
+// navigate returns the value at the slash separated path in the generic JSON document.
+func navigate(doc interface{}, path string) interface{} {
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = m[strings.Replace(segment, "~1", "/", -1)]
+	}
+	return doc
+}
+
+// generic round trips the value through encoding/json, so that it only consists of generic JSON values.
+func (s *OpenAPITestSuite) generic(v interface{}) interface{} {
+	raw, err := json.Marshal(v)
+	s.Require().Nil(err)
+	var doc interface{}
+	s.Require().Nil(json.Unmarshal(raw, &doc))
+	return doc
+}
+
+func (s *OpenAPITestSuite) TestJSONSchema() {
+	doc := s.generic(JSONSchema(s.resourceTypes[0]))
+
+	tests := []struct {
+		path   string
+		expect interface{}
+	}{
+		{path: "$schema", expect: JSONSchemaDialect},
+		{path: "$id", expect: "urn:ietf:params:scim:schemas:core:2.0:User"},
+		{path: "title", expect: "User"},
+		{path: "type", expect: "object"},
+		{path: "required", expect: []interface{}{"schemas", "userName", "emails", "urn:imulab:scim:schemas:extension:test:2.0:User"}},
+		{path: "properties/id/readOnly", expect: true},
+		{path: "properties/userName/type", expect: "string"},
+		{path: "properties/active/type", expect: "boolean"},
+		{path: "properties/meta/properties/created/format", expect: "date-time"},
+		{path: "properties/password/writeOnly", expect: true},
+		{path: "properties/emails/type", expect: "array"},
+		{path: "properties/emails/minItems", expect: float64(1)},
+		{path: "properties/emails/items/type", expect: "object"},
+		{path: "properties/emails/items/properties/type/examples", expect: []interface{}{"work", "home", "other"}},
+		{path: "properties/urn:imulab:scim:schemas:extension:test:2.0:User/type", expect: "object"},
+		{path: "properties/urn:imulab:scim:schemas:extension:test:2.0:User/required", expect: []interface{}{"clearance"}},
+		{path: "properties/urn:imulab:scim:schemas:extension:test:2.0:User/properties/clearance/enum", expect: []interface{}{"public", "secret"}},
+	}
+	for _, test := range tests {
+		assert.Equal(s.T(), test.expect, navigate(doc, test.path), test.path)
+	}
+}
+
+func (s *OpenAPITestSuite) TestDocument() {
+	doc := s.generic(Document(Info{Title: "SCIM", Version: "2.0", Servers: []string{"/acme"}}, s.resourceTypes...))
+
+	assert.Equal(s.T(), OpenAPIVersion, navigate(doc, "openapi"))
+	assert.Equal(s.T(), "/acme", navigate(doc, "servers").([]interface{})[0].(map[string]interface{})["url"])
+
+	for _, path := range []string{
+		"paths/~1Users/get", "paths/~1Users/post",
+		"paths/~1Users~1{id}/get", "paths/~1Users~1{id}/put", "paths/~1Users~1{id}/patch", "paths/~1Users~1{id}/delete",
+		"paths/~1Groups/get", "paths/~1Groups~1{id}/patch",
+		"paths/~1ServiceProviderConfig/get", "paths/~1Schemas~1{id}/get", "paths/~1ResourceTypes/get",
+		"components/schemas/User", "components/schemas/UserListResponse", "components/schemas/Group",
+		"components/schemas/ListResponse", "components/schemas/PatchOp", "components/schemas/Error",
+	} {
+		assert.NotNil(s.T(), navigate(doc, path), path)
+	}
+	assert.Nil(s.T(), navigate(doc, "components/schemas/User/$schema"))
+	assert.Equal(s.T(), "#/components/schemas/PatchOp",
+		navigate(doc, "paths/~1Users~1{id}/patch/requestBody/content/application~1scim+json/schema/$ref"))
+
+	// every reference resolves within the document
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if r, ok := t["$ref"].(string); ok {
+				assert.NotNil(s.T(), navigate(doc, strings.TrimPrefix(r, "#/")), r)
+			}
+			for _, each := range t {
+				walk(each)
+			}
+		case []interface{}:
+			for _, each := range t {
+				walk(each)
+			}
+		}
+	}
+	walk(doc)
+}
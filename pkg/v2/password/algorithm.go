@@ -0,0 +1,255 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// newArgon2id returns a Hasher of the argon2id algorithm. Parameters are "memory" in KiB (default 65536),
+// "iterations" (default 3), "parallelism" (default 4), "saltLength" (default 16) and "keyLength" (default 32).
+func newArgon2id(params map[string]interface{}) (Hasher, error) {
+	h := argon2idHasher{}
+	for _, p := range []struct {
+		name         string
+		target       *int
+		defaultValue int
+		max          int
+	}{
+		{name: "memory", target: &h.memory, defaultValue: 64 * 1024, max: 1<<31 - 1},
+		{name: "iterations", target: &h.iterations, defaultValue: 3, max: 1<<31 - 1},
+		{name: "parallelism", target: &h.parallelism, defaultValue: 4, max: 1<<8 - 1},
+		{name: "saltLength", target: &h.saltLength, defaultValue: 16, max: 1024},
+		{name: "keyLength", target: &h.keyLength, defaultValue: 32, max: 1024},
+	} {
+		v, err := intParam(params, p.name, p.defaultValue, 1, p.max)
+		if err != nil {
+			return nil, fmt.Errorf("%w of %s", err, Argon2id)
+		}
+		*p.target = v
+	}
+	return h, nil
+}
+
+type argon2idHasher struct {
+	memory      int
+	iterations  int
+	parallelism int
+	saltLength  int
+	keyLength   int
+}
+
+func (h argon2idHasher) Hash(secret []byte) (string, error) {
+	salt, err := randomSalt(h.saltLength)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(secret, salt, uint32(h.iterations), uint32(h.memory), uint8(h.parallelism), uint32(h.keyLength))
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2id, argon2.Version, h.memory, h.iterations, h.parallelism,
+		encode(salt), encode(key)), nil
+}
+
+func (h argon2idHasher) Verify(secret []byte, hash string) (bool, error) {
+	decoded, salt, key, err := h.decode(hash)
+	if err != nil {
+		return false, err
+	}
+	actual := argon2.IDKey(secret, salt, uint32(decoded.iterations), uint32(decoded.memory), uint8(decoded.parallelism), uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func (h argon2idHasher) Outdated(hash string) bool {
+	decoded, _, _, err := h.decode(hash)
+	return err != nil || decoded != h
+}
+
+// decode parses the argon2id hash into the hasher that produced it, the salt and the key.
+func (h argon2idHasher) decode(hash string) (argon2idHasher, []byte, []byte, error) {
+	var (
+		decoded argon2idHasher
+		version int
+		parts   = strings.Split(hash, "$")
+	)
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return decoded, nil, nil, errMalformed(Argon2id)
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return decoded, nil, nil, errMalformed(Argon2id)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.memory, &decoded.iterations, &decoded.parallelism); err != nil {
+		return decoded, nil, nil, errMalformed(Argon2id)
+	}
+	salt, saltErr := decode(parts[4])
+	key, keyErr := decode(parts[5])
+	if saltErr != nil || keyErr != nil || len(salt) == 0 || len(key) == 0 ||
+		decoded.memory < 1 || decoded.iterations < 1 || decoded.parallelism < 1 || decoded.parallelism > 1<<8-1 {
+		return decoded, nil, nil, errMalformed(Argon2id)
+	}
+	decoded.saltLength, decoded.keyLength = len(salt), len(key)
+	return decoded, salt, key, nil
+}
+
+// newScrypt returns a Hasher of the scrypt algorithm. Parameters are "logN", the base 2 logarithm of the CPU/memory
+// cost (default 15), "blockSize" (default 8), "parallelism" (default 1), "saltLength" (default 16) and "keyLength"
+// (default 32).
+func newScrypt(params map[string]interface{}) (Hasher, error) {
+	h := scryptHasher{}
+	for _, p := range []struct {
+		name         string
+		target       *int
+		defaultValue int
+		max          int
+	}{
+		{name: "logN", target: &h.logN, defaultValue: 15, max: 30},
+		{name: "blockSize", target: &h.blockSize, defaultValue: 8, max: 1 << 10},
+		{name: "parallelism", target: &h.parallelism, defaultValue: 1, max: 1 << 10},
+		{name: "saltLength", target: &h.saltLength, defaultValue: 16, max: 1024},
+		{name: "keyLength", target: &h.keyLength, defaultValue: 32, max: 1024},
+	} {
+		v, err := intParam(params, p.name, p.defaultValue, 1, p.max)
+		if err != nil {
+			return nil, fmt.Errorf("%w of %s", err, Scrypt)
+		}
+		*p.target = v
+	}
+	return h, nil
+}
+
+type scryptHasher struct {
+	logN        int
+	blockSize   int
+	parallelism int
+	saltLength  int
+	keyLength   int
+}
+
+func (h scryptHasher) Hash(secret []byte) (string, error) {
+	salt, err := randomSalt(h.saltLength)
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key(secret, salt, 1<<uint(h.logN), h.blockSize, h.parallelism, h.keyLength)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to perform %s: %s", spec.ErrInternal, Scrypt, err.Error())
+	}
+	return fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s", Scrypt, h.logN, h.blockSize, h.parallelism, encode(salt), encode(key)), nil
+}
+
+func (h scryptHasher) Verify(secret []byte, hash string) (bool, error) {
+	decoded, salt, key, err := h.decode(hash)
+	if err != nil {
+		return false, err
+	}
+	actual, err := scrypt.Key(secret, salt, 1<<uint(decoded.logN), decoded.blockSize, decoded.parallelism, len(key))
+	if err != nil {
+		return false, errMalformed(Scrypt)
+	}
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func (h scryptHasher) Outdated(hash string) bool {
+	decoded, _, _, err := h.decode(hash)
+	return err != nil || decoded != h
+}
+
+// decode parses the scrypt hash into the hasher that produced it, the salt and the key.
+func (h scryptHasher) decode(hash string) (scryptHasher, []byte, []byte, error) {
+	var (
+		decoded scryptHasher
+		parts   = strings.Split(hash, "$")
+	)
+	if len(parts) != 5 || parts[1] != Scrypt {
+		return decoded, nil, nil, errMalformed(Scrypt)
+	}
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &decoded.logN, &decoded.blockSize, &decoded.parallelism); err != nil {
+		return decoded, nil, nil, errMalformed(Scrypt)
+	}
+	salt, saltErr := decode(parts[3])
+	key, keyErr := decode(parts[4])
+	if saltErr != nil || keyErr != nil || len(salt) == 0 || len(key) == 0 || decoded.logN < 1 || decoded.logN > 30 {
+		return decoded, nil, nil, errMalformed(Scrypt)
+	}
+	decoded.saltLength, decoded.keyLength = len(salt), len(key)
+	return decoded, salt, key, nil
+}
+
+// newBCrypt returns a Hasher of the bcrypt algorithm. The only parameter is "cost" (default 10). Hashes of bcrypt are
+// in the modular crypt format instead of the PHC string format.
+func newBCrypt(params map[string]interface{}) (Hasher, error) {
+	cost, err := intParam(params, "cost", 10, bcrypt.MinCost, bcrypt.MaxCost)
+	if err != nil {
+		return nil, fmt.Errorf("%w of %s", err, BCrypt)
+	}
+	return bCryptHasher{cost: cost}, nil
+}
+
+type bCryptHasher struct {
+	cost int
+}
+
+func (h bCryptHasher) Hash(secret []byte) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword(secret, h.cost)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to perform %s: %s", spec.ErrInternal, BCrypt, err.Error())
+	}
+	return string(hashed), nil
+}
+
+func (h bCryptHasher) Verify(secret []byte, hash string) (bool, error) {
+	switch err := bcrypt.CompareHashAndPassword([]byte(hash), secret); {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, errMalformed(BCrypt)
+	}
+}
+
+func (h bCryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+// intParam returns the integer parameter of the name, or the default value if absent. Annotation parameters are
+// parsed from JSON, hence numbers are float64.
+func intParam(params map[string]interface{}, name string, defaultValue int, min int, max int) (int, error) {
+	v, ok := params[name]
+	if !ok {
+		return defaultValue, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f != float64(int(f)) || int(f) < min || int(f) > max {
+		return 0, fmt.Errorf("%w: parameter %s must be an integer between %d and %d", spec.ErrInternal, name, min, max)
+	}
+	return int(f), nil
+}
+
+func randomSalt(length int) ([]byte, error) {
+	salt := make([]byte, length)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("%w: failed to generate salt", spec.ErrInternal)
+	}
+	return salt, nil
+}
+
+// encode and decode use the unpadded standard base64 encoding of the PHC string format.
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func errMalformed(algorithm string) error {
+	return fmt.Errorf("%w: malformed %s password hash", spec.ErrInternal, algorithm)
+}
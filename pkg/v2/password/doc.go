@@ -0,0 +1,18 @@
+// This package hashes and verifies passwords stored in attributes annotated with @PasswordHash.
+//
+// Hashes are stored in the PHC string format, for instance
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<base64 salt>$<base64 hash>
+//
+// save for bcrypt, whose modular crypt format predates it. The algorithm producing the hash is identified by the
+// leading identifier, so hashes produced by any registered algorithm can be verified, regardless of the algorithm the
+// attribute is currently configured with. Verify and Authenticate rehash passwords whose hash was produced by a
+// different algorithm, or with different parameters, upon successful verification.
+//
+// The SCIM API does not authenticate users, nor verify the current password when it is changed: Verify, VerifyProperty
+// and Authenticate are helpers for the applications that do, for instance a login service sharing the user database.
+// Stored hashes are only migrated to the configured algorithm as such applications call Authenticate, or as passwords
+// are changed through the API, which hashes them with the configured algorithm.
+//
+// The argon2id, scrypt and bcrypt algorithms are built in. Additional algorithms can be registered with Register.
+package password
@@ -0,0 +1,149 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// Names of the built in algorithms.
+const (
+	Argon2id = "argon2id"
+	Scrypt   = "scrypt"
+	BCrypt   = "bcrypt"
+)
+
+// DefaultAlgorithm is the algorithm of attributes whose @PasswordHash annotation does not specify the "algorithm"
+// parameter.
+const DefaultAlgorithm = Argon2id
+
+// Hasher hashes passwords with an algorithm and a set of parameters.
+type Hasher interface {
+	// Hash returns the hash of the password with a random salt, in the PHC string format.
+	Hash(secret []byte) (string, error)
+	// Verify returns true if the password matches the hash. The hash must have been produced by the algorithm of the
+	// hasher, but may have been produced with different parameters.
+	Verify(secret []byte, hash string) (bool, error)
+	// Outdated returns true if the hash was produced by another algorithm, or with parameters other than those of the
+	// hasher, so the password should be rehashed.
+	Outdated(hash string) bool
+}
+
+// Algorithm returns a Hasher with the parameters of the @PasswordHash annotation. Parameters that are not specified
+// take default values recommended for the algorithm.
+type Algorithm func(params map[string]interface{}) (Hasher, error)
+
+var (
+	registryLock sync.RWMutex
+	algorithms   = map[string]Algorithm{}
+	identifiers  = map[string]string{}
+)
+
+func init() {
+	Register(Argon2id, newArgon2id)
+	Register(Scrypt, newScrypt)
+	Register(BCrypt, newBCrypt, "2a", "2b", "2y")
+}
+
+// Register registers the algorithm with the name, which is also the identifier of the algorithm in PHC strings, unless
+// identifiers are specified. Registering an algorithm with an existing name replaces it.
+func Register(name string, algorithm Algorithm, ids ...string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if len(ids) == 0 {
+		ids = []string{name}
+	}
+	algorithms[name] = algorithm
+	for _, id := range ids {
+		identifiers[id] = name
+	}
+}
+
+// New returns the Hasher of the registered algorithm with the parameters.
+func New(name string, params map[string]interface{}) (Hasher, error) {
+	registryLock.RLock()
+	algorithm, ok := algorithms[name]
+	registryLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown password hash algorithm '%s'", spec.ErrInternal, name)
+	}
+	return algorithm(params)
+}
+
+// ForAttribute returns the Hasher configured by the @PasswordHash annotation of the attribute. The "algorithm"
+// parameter names the algorithm, and defaults to DefaultAlgorithm; other parameters are passed to the algorithm.
+func ForAttribute(attr *spec.Attribute) (Hasher, error) {
+	params, ok := attr.Annotation(annotation.PasswordHash)
+	if !ok {
+		return nil, fmt.Errorf("%w: attribute '%s' is not annotated with %s", spec.ErrInternal, attr.Path(), annotation.PasswordHash)
+	}
+
+	name := DefaultAlgorithm
+	if v, ok := params["algorithm"]; ok {
+		if s, ok := v.(string); ok {
+			name = s
+		} else {
+			return nil, fmt.Errorf("%w: parameter algorithm of %s on '%s' must be a string", spec.ErrInternal, annotation.PasswordHash, attr.Path())
+		}
+	}
+
+	hasher, err := New(name, params)
+	if err != nil {
+		return nil, fmt.Errorf("%w (attribute '%s')", err, attr.Path())
+	}
+	return hasher, nil
+}
+
+// Identify returns the name of the registered algorithm that produced the hash.
+func Identify(hash string) (string, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	name, ok := identifiers[identifier(hash)]
+	return name, ok
+}
+
+// Verify returns true if the password matches the hash, which may have been produced by any registered algorithm. If
+// the password matches, but the hasher considers the hash outdated, the password is rehashed by the hasher and the new
+// hash is returned, to be stored in place of the old one.
+func Verify(hasher Hasher, secret []byte, hash string) (ok bool, rehash string, err error) {
+	name, known := Identify(hash)
+	if !known {
+		return false, "", fmt.Errorf("%w: hash of unknown password hash algorithm", spec.ErrInternal)
+	}
+
+	verifier, err := New(name, nil)
+	if err != nil {
+		return false, "", err
+	}
+
+	ok, err = verifier.Verify(secret, hash)
+	if err != nil || !ok {
+		return false, "", err
+	}
+
+	if hasher.Outdated(hash) {
+		rehash, err = hasher.Hash(secret)
+		if err != nil {
+			return true, "", err
+		}
+	}
+	return true, rehash, nil
+}
+
+// identifier returns the algorithm identifier of the hash, which is the first $ delimited segment.
+func identifier(hash string) string {
+	if !strings.HasPrefix(hash, "$") {
+		return ""
+	}
+	parts := strings.SplitN(hash[1:], "$", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[0]
+}
@@ -0,0 +1,278 @@
+package password
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestPassword(t *testing.T) {
+	s := new(PasswordTestSuite)
+	suite.Run(t, s)
+}
+
+type PasswordTestSuite struct {
+	suite.Suite
+}
+
+// cheap parameters, so tests run fast
+var (
+	argon2idParams = map[string]interface{}{"memory": float64(64), "iterations": float64(1), "parallelism": float64(1)}
+	scryptParams   = map[string]interface{}{"logN": float64(4)}
+	bCryptParams   = map[string]interface{}{"cost": float64(4)}
+)
+
+func (s *PasswordTestSuite) TestAlgorithms() {
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		prefix   string
+		stronger map[string]interface{}
+	}{
+		{
+			name:     Argon2id,
+			params:   argon2idParams,
+			prefix:   "$argon2id$v=19$m=64,t=1,p=1$",
+			stronger: map[string]interface{}{"memory": float64(128), "iterations": float64(1), "parallelism": float64(1)},
+		},
+		{
+			name:     Scrypt,
+			params:   scryptParams,
+			prefix:   "$scrypt$ln=4,r=8,p=1$",
+			stronger: map[string]interface{}{"logN": float64(5)},
+		},
+		{
+			name:     BCrypt,
+			params:   bCryptParams,
+			prefix:   "$2a$04$",
+			stronger: map[string]interface{}{"cost": float64(5)},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			hasher, err := New(test.name, test.params)
+			require.Nil(t, err)
+
+			hash, err := hasher.Hash([]byte("s3cret"))
+			require.Nil(t, err)
+			assert.True(t, strings.HasPrefix(hash, test.prefix), hash)
+
+			again, err := hasher.Hash([]byte("s3cret"))
+			require.Nil(t, err)
+			assert.NotEqual(t, hash, again, "hashes must be salted")
+
+			name, ok := Identify(hash)
+			assert.True(t, ok)
+			assert.Equal(t, test.name, name)
+
+			ok, err = hasher.Verify([]byte("s3cret"), hash)
+			assert.Nil(t, err)
+			assert.True(t, ok)
+
+			ok, err = hasher.Verify([]byte("wrong"), hash)
+			assert.Nil(t, err)
+			assert.False(t, ok)
+
+			_, err = hasher.Verify([]byte("s3cret"), hash[:len(test.prefix)])
+			assert.NotNil(t, err)
+
+			assert.False(t, hasher.Outdated(hash))
+			stronger, err := New(test.name, test.stronger)
+			require.Nil(t, err)
+			assert.True(t, stronger.Outdated(hash))
+
+			// parameters are read from the hash when verifying
+			ok, err = stronger.Verify([]byte("s3cret"), hash)
+			assert.Nil(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func (s *PasswordTestSuite) TestNew() {
+	_, err := New("md5", nil)
+	assert.NotNil(s.T(), err)
+
+	_, err = New(Argon2id, map[string]interface{}{"parallelism": float64(1000)})
+	assert.NotNil(s.T(), err)
+
+	_, err = New(BCrypt, map[string]interface{}{"cost": "10"})
+	assert.NotNil(s.T(), err)
+}
+
+func (s *PasswordTestSuite) TestVerify() {
+	argon2id, err := New(Argon2id, argon2idParams)
+	require.Nil(s.T(), err)
+	bCrypt, err := New(BCrypt, bCryptParams)
+	require.Nil(s.T(), err)
+
+	legacy, err := bCrypt.Hash([]byte("s3cret"))
+	require.Nil(s.T(), err)
+
+	// hashes of other algorithms are verified, and migrated
+	ok, rehash, err := Verify(argon2id, []byte("s3cret"), legacy)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+	assert.True(s.T(), strings.HasPrefix(rehash, "$argon2id$"))
+	ok, _, err = Verify(argon2id, []byte("s3cret"), rehash)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+
+	// up to date hashes are not rehashed
+	ok, rehash, err = Verify(argon2id, []byte("s3cret"), rehash)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+	assert.Empty(s.T(), rehash)
+
+	// wrong passwords are not rehashed
+	ok, rehash, err = Verify(argon2id, []byte("wrong"), legacy)
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), ok)
+	assert.Empty(s.T(), rehash)
+
+	_, _, err = Verify(argon2id, []byte("s3cret"), "s3cret")
+	assert.NotNil(s.T(), err)
+}
+
+func (s *PasswordTestSuite) TestRegister() {
+	Register("plain", func(params map[string]interface{}) (Hasher, error) {
+		return plainHasher{}, nil
+	})
+
+	hasher, err := New("plain", nil)
+	require.Nil(s.T(), err)
+	argon2id, err := New(Argon2id, argon2idParams)
+	require.Nil(s.T(), err)
+
+	ok, rehash, err := Verify(argon2id, []byte("s3cret"), "$plain$s3cret")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+	assert.True(s.T(), strings.HasPrefix(rehash, "$argon2id$"))
+
+	ok, rehash, err = Verify(hasher, []byte("s3cret"), "$plain$s3cret")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+	assert.Empty(s.T(), rehash)
+}
+
+func (s *PasswordTestSuite) TestAuthenticate() {
+	var (
+		ctx          = context.Background()
+		resourceType = mustAccountResourceType(s.T())
+		database     = db.Memory()
+	)
+
+	legacy, err := New(BCrypt, bCryptParams)
+	require.Nil(s.T(), err)
+	hash, err := legacy.Hash([]byte("s3cret"))
+	require.Nil(s.T(), err)
+
+	resource := prop.NewResource(resourceType)
+	_, err = resource.RootProperty().Replace(map[string]interface{}{
+		"schemas":  []interface{}{"urn:imulab:scim:2.0:Account"},
+		"id":       "1",
+		"meta":     map[string]interface{}{"version": "W/\"1\""},
+		"userName": "imulab",
+		"password": hash,
+	})
+	require.Nil(s.T(), err)
+	require.Nil(s.T(), database.Insert(ctx, resource))
+
+	password := func() string {
+		r, err := database.Get(ctx, "1", nil)
+		require.Nil(s.T(), err)
+		return r.Navigator().Dot("password").Current().Raw().(string)
+	}
+
+	ok, err := Authenticate(ctx, database, resource, "password", []byte("wrong"))
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), ok)
+	assert.Equal(s.T(), hash, password())
+
+	ok, err = Authenticate(ctx, database, resource, "urn:imulab:scim:2.0:Account:password", []byte("s3cret"))
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+	assert.True(s.T(), strings.HasPrefix(password(), "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	stored, err := database.Get(ctx, "1", nil)
+	require.Nil(s.T(), err)
+	ok, err = Authenticate(ctx, database, stored, "password", []byte("s3cret"))
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), ok)
+
+	_, err = Authenticate(ctx, database, stored, "userName", []byte("imulab"))
+	assert.NotNil(s.T(), err)
+}
+
+type plainHasher struct{}
+
+func (h plainHasher) Hash(secret []byte) (string, error) {
+	return "$plain$" + string(secret), nil
+}
+
+func (h plainHasher) Verify(secret []byte, hash string) (bool, error) {
+	return hash == "$plain$"+string(secret), nil
+}
+
+func (h plainHasher) Outdated(hash string) bool {
+	return !strings.HasPrefix(hash, "$plain$")
+}
+
+// mustAccountResourceType returns a resource type with a password attribute, in a registry of its own.
+func mustAccountResourceType(t *testing.T) *spec.ResourceType {
+	registry := spec.NewSchemaRegistry()
+	core, err := ioutil.ReadFile("../../../public/schemas/core_schema.json")
+	require.Nil(t, err)
+	for _, raw := range [][]byte{core, []byte(accountSchema)} {
+		schema := new(spec.Schema)
+		require.Nil(t, json.Unmarshal(raw, schema))
+		registry.Register(schema)
+	}
+
+	resourceType, err := registry.ParseResourceType([]byte(`{
+		"id": "Account",
+		"name": "Account",
+		"endpoint": "/Accounts",
+		"schema": "urn:imulab:scim:2.0:Account"
+	}`))
+	require.Nil(t, err)
+	crud.Register(resourceType)
+	return resourceType
+}
+
+const accountSchema = `{
+  "id": "urn:imulab:scim:2.0:Account",
+  "name": "Account",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:2.0:Account:userName",
+      "name": "userName",
+      "type": "string",
+      "_index": 100,
+      "_path": "userName"
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Account:password",
+      "name": "password",
+      "type": "string",
+      "mutability": "writeOnly",
+      "returned": "never",
+      "_index": 101,
+      "_path": "password",
+      "_annotations": {
+        "@PasswordHash": {"algorithm": "argon2id", "memory": 64, "iterations": 1, "parallelism": 1}
+      }
+    }
+  ]
+}`
@@ -0,0 +1,145 @@
+package password
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
+	"github.com/imulab/go-scim/pkg/v2/db"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// HashProperty replaces the value of the string or binary property, annotated with @PasswordHash, with its hash. The
+// hash of binary properties is base64 encoded, like the original value. Unassigned properties are left untouched.
+func HashProperty(property prop.Property) error {
+	if property.IsUnassigned() {
+		return nil
+	}
+
+	hasher, err := ForAttribute(property.Attribute())
+	if err != nil {
+		return err
+	}
+
+	secret, err := propertyBytes(property)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hasher.Hash(secret)
+	if err != nil {
+		return err
+	}
+
+	_, err = property.Replace(propertyValue(property.Attribute(), hash))
+	return err
+}
+
+// VerifyProperty verifies the password against the hash stored in the string or binary property annotated with
+// @PasswordHash. If the password matches and the hash is outdated, the password is rehashed with the algorithm and
+// parameters of the annotation, and the new property value is returned as rehash. Unassigned properties match no
+// password.
+func VerifyProperty(property prop.Property, secret []byte) (ok bool, rehash string, err error) {
+	if property.IsUnassigned() {
+		return false, "", nil
+	}
+
+	hasher, err := ForAttribute(property.Attribute())
+	if err != nil {
+		return false, "", err
+	}
+
+	hash, err := propertyBytes(property)
+	if err != nil {
+		return false, "", err
+	}
+
+	ok, rehash, err = Verify(hasher, secret, string(hash))
+	if err != nil || !ok || len(rehash) == 0 {
+		return ok, "", err
+	}
+	return true, propertyValue(property.Attribute(), rehash), nil
+}
+
+// Authenticate verifies the password against the hash stored in the singular property at the path of the resource,
+// which is read from the database. If the password matches and the hash is outdated, a copy of the resource with the
+// rehashed password replaces the resource in the database. The replacement keeps the version of the resource, as
+// rehashing is invisible to API callers, and loses to concurrent modifications. Failing to store the rehash does not
+// fail the authentication: ok is true and the error is returned for the caller to report; the password is rehashed
+// again at the next successful authentication.
+func Authenticate(ctx context.Context, database db.DB, resource *prop.Resource, path string, secret []byte) (ok bool, err error) {
+	nav, err := navigate(resource, path)
+	if err != nil {
+		return false, err
+	}
+
+	ok, rehash, err := VerifyProperty(nav.Current(), secret)
+	if err != nil || !ok || len(rehash) == 0 {
+		return ok, err
+	}
+
+	replacement := resource.Clone()
+	if nav, err = navigate(replacement, path); err != nil {
+		return true, err
+	}
+	if _, err = nav.Current().Replace(rehash); err != nil {
+		return true, err
+	}
+	return true, database.Replace(ctx, resource, replacement)
+}
+
+// navigate focuses on the singular property at the path, which does not contain filters.
+func navigate(resource *prop.Resource, path string) (prop.Navigator, error) {
+	head, err := expr.CompilePathWith(resource.ResourceType().Registry(), path)
+	if err != nil {
+		return nil, err
+	}
+	if head.ContainsFilter() {
+		return nil, fmt.Errorf("%w: password path '%s' cannot contain filter", spec.ErrInvalidPath, path)
+	}
+	if head.Token() == resource.ResourceType().Schema().ID() {
+		head = head.Next()
+	}
+
+	nav := resource.Navigator()
+	for cursor := head; cursor != nil; cursor = cursor.Next() {
+		nav.Dot(cursor.Token())
+	}
+	if nav.HasError() {
+		return nil, nav.Error()
+	}
+
+	attr := nav.Current().Attribute()
+	if _, ok := attr.Annotation(annotation.PasswordHash); !ok || attr.MultiValued() {
+		return nil, fmt.Errorf("%w: '%s' is not a singular attribute annotated with %s", spec.ErrInvalidPath, path, annotation.PasswordHash)
+	}
+	return nav, nil
+}
+
+// propertyBytes returns the bytes of the string property, or the decoded bytes of the binary property.
+func propertyBytes(property prop.Property) ([]byte, error) {
+	attr := property.Attribute()
+	switch attr.Type() {
+	case spec.TypeString:
+		return []byte(property.Raw().(string)), nil
+	case spec.TypeBinary:
+		b, err := base64.StdEncoding.DecodeString(property.Raw().(string))
+		if err != nil {
+			return nil, fmt.Errorf("%w: value of '%s' is not base64 encoded", spec.ErrInvalidValue, attr.Path())
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("%w: %s is only supported on string or binary attributes", spec.ErrInternal, annotation.PasswordHash)
+	}
+}
+
+// propertyValue returns the hash as the value of a property of the attribute.
+func propertyValue(attr *spec.Attribute, hash string) string {
+	if attr.Type() == spec.TypeBinary {
+		return base64.StdEncoding.EncodeToString([]byte(hash))
+	}
+	return hash
+}
@@ -12,12 +12,14 @@ import (
 	"io/ioutil"
 )
 
-// Create returns a create resource service.
-func CreateService(resourceType *spec.ResourceType, database db.DB, filters []filter.ByResource) Create {
+// Create returns a create resource service. The options configure the deserialization of all payloads, and may be
+// extended for each request.
+func CreateService(resourceType *spec.ResourceType, database db.DB, filters []filter.ByResource, options ...json.DeserializeOptions) Create {
 	return &createService{
 		resourceType: resourceType,
 		filters:      filters,
 		database:     database,
+		options:      options,
 	}
 }
 
@@ -28,11 +30,13 @@ type (
 	}
 	// Create resource request
 	CreateRequest struct {
-		PayloadSource io.Reader // reader source to read resource payload from
+		PayloadSource      io.Reader                 // reader source to read resource payload from
+		DeserializeOptions []json.DeserializeOptions // options applied after those of the service, i.e. for the client
 	}
 	// Create resource response
 	CreateResponse struct {
-		Resource *prop.Resource // the created resource
+		Resource *prop.Resource  // the created resource
+		Warnings []*json.Warning // problems in the payload tolerated by deserialization
 	}
 )
 
@@ -40,10 +44,11 @@ type createService struct {
 	resourceType *spec.ResourceType
 	filters      []filter.ByResource
 	database     db.DB
+	options      []json.DeserializeOptions
 }
 
 func (s *createService) Do(ctx context.Context, req *CreateRequest) (resp *CreateResponse, err error) {
-	resource, err := s.parseResource(req)
+	resource, warnings, err := s.parseResource(req)
 	if err != nil {
 		return
 	}
@@ -58,24 +63,36 @@ func (s *createService) Do(ctx context.Context, req *CreateRequest) (resp *Creat
 		return
 	}
 
-	resp = &CreateResponse{Resource: resource}
+	resp = &CreateResponse{Resource: resource, Warnings: warnings}
 	return
 }
 
-func (s *createService) parseResource(req *CreateRequest) (*prop.Resource, error) {
+func (s *createService) parseResource(req *CreateRequest) (*prop.Resource, []*json.Warning, error) {
 	if req == nil || req.PayloadSource == nil {
-		return nil, fmt.Errorf("%w: no payload for create service", spec.ErrInternal)
+		return nil, nil, fmt.Errorf("%w: no payload for create service", spec.ErrInternal)
 	}
 
 	raw, err := ioutil.ReadAll(req.PayloadSource)
 	if err != nil {
-		return nil, fmt.Errorf("%w: failed to read request body", spec.ErrInternal)
+		return nil, nil, fmt.Errorf("%w: failed to read request body", spec.ErrInternal)
 	}
 
+	var warnings []*json.Warning
 	resource := prop.NewResource(s.resourceType)
-	if err := json.Deserialize(raw, resource); err != nil {
-		return nil, err
+	if err := json.Deserialize(raw, resource, deserializeOptions(&warnings, s.options, req.DeserializeOptions)...); err != nil {
+		return nil, nil, err
 	}
 
-	return resource, nil
+	return resource, warnings, nil
+}
+
+// deserializeOptions returns the options of the service followed by those of the request, and an option to collect
+// warnings, which takes precedence over any ReportWarnings option from the request.
+func deserializeOptions(warnings *[]*json.Warning, service []json.DeserializeOptions, request []json.DeserializeOptions) []json.DeserializeOptions {
+	options := make([]json.DeserializeOptions, 0, len(service)+len(request)+1)
+	options = append(options, service...)
+	options = append(options, request...)
+	return append(options, json.ReportWarnings(func(warning *json.Warning) {
+		*warnings = append(*warnings, warning)
+	}))
 }
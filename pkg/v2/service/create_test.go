@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/imulab/go-scim/pkg/v2/db"
+	scimjson "github.com/imulab/go-scim/pkg/v2/json"
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/service/filter"
 	"github.com/imulab/go-scim/pkg/v2/spec"
@@ -156,6 +157,41 @@ func (s *CreateServiceTestSuite) TestDo() {
 				assert.NotEqual(t, "foobar", resp.Resource.Navigator().Dot("id").Current().Raw())
 			},
 		},
+		{
+			name:  "unknown attributes are rejected by default",
+			setup: defaultSetup,
+			getRequest: func() *CreateRequest {
+				return &CreateRequest{
+					PayloadSource: strings.NewReader(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"foo","nickname2":"f"}`),
+				}
+			},
+			expect: func(t *testing.T, resp *CreateResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, spec.ErrInvalidSyntax, errors.Unwrap(err))
+			},
+		},
+		{
+			name: "deserialization options of the service and the client",
+			setup: func(t *testing.T) Create {
+				return CreateService(s.resourceType, db.Memory(), []filter.ByResource{
+					filter.ByPropertyToByResource(filter.UUIDFilter()),
+				}, scimjson.Coerce(scimjson.CoerceBooleanString))
+			},
+			getRequest: func() *CreateRequest {
+				return &CreateRequest{
+					PayloadSource:      strings.NewReader(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"foo","active":"True","nickname2":"f"}`),
+					DeserializeOptions: []scimjson.DeserializeOptions{scimjson.Lenient()},
+				}
+			},
+			expect: func(t *testing.T, resp *CreateResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, true, resp.Resource.Navigator().Dot("active").Current().Raw())
+				if assert.Len(t, resp.Warnings, 2) {
+					assert.Equal(t, "$.active: string accepted as boolean", resp.Warnings[0].String())
+					assert.Equal(t, "$.nickname2: unknown attribute dropped", resp.Warnings[1].String())
+				}
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/crud/expr"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"strings"
+)
+
+// ComputedFilter returns a ByProperty filter that derives the values of string properties annotated with @Computed,
+// from the "template" annotation parameter, on every create, replace and patch. Paths in curly braces are replaced by
+// the values of the properties, resolved from the root of the resource; the primary, or first, element is chosen for
+// multiValued properties, and unassigned properties are replaced by empty strings. Consecutive white spaces in the
+// result are collapsed, and an empty result unassigns the property. Any value supplied by the client is overwritten.
+//
+// Because properties are visited in the order of attributes, this filter should run in a traversal of its own, after
+// filters changing the values the template refers to. The changed value will trigger event propagation.
+func ComputedFilter() ByProperty {
+	return computedPropertyFilter{}
+}
+
+type computedPropertyFilter struct{}
+
+func (f computedPropertyFilter) Supports(attribute *spec.Attribute) bool {
+	params, ok := attribute.Annotation(annotation.Computed)
+	if !ok {
+		return false
+	}
+	if _, ok := params["template"].(string); !ok {
+		return false
+	}
+	return !attribute.MultiValued() && attribute.Type() == spec.TypeString
+}
+
+func (f computedPropertyFilter) Filter(_ context.Context, resourceType *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+	return f.compute(resourceType, nav)
+}
+
+func (f computedPropertyFilter) FilterRef(_ context.Context, resourceType *spec.ResourceType, nav prop.Navigator, _ prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+	return f.compute(resourceType, nav)
+}
+
+func (f computedPropertyFilter) compute(resourceType *spec.ResourceType, nav prop.Navigator) error {
+	attr := nav.Current().Attribute()
+	params, _ := attr.Annotation(annotation.Computed)
+	template := params["template"].(string)
+
+	var sb strings.Builder
+	for len(template) > 0 {
+		open := strings.IndexByte(template, '{')
+		if open < 0 {
+			sb.WriteString(template)
+			break
+		}
+		end := strings.IndexByte(template[open:], '}')
+		if end < 0 {
+			return fmt.Errorf("%w: unclosed path in template of '%s'", spec.ErrInternal, attr.Path())
+		}
+
+		sb.WriteString(template[:open])
+		value, err := templateValue(resourceType, nav.Source(), template[open+1:open+end])
+		if err != nil {
+			return fmt.Errorf("%w (template of '%s')", err, attr.Path())
+		}
+		sb.WriteString(value)
+		template = template[open+end+1:]
+	}
+
+	computed := strings.Join(strings.Fields(sb.String()), " ")
+	if len(computed) == 0 {
+		return nav.Delete().Error()
+	}
+	if computed == nav.Current().Raw() {
+		return nil
+	}
+	return nav.Replace(computed).Error()
+}
+
+// templateValue returns the string form of the value of the simple property at the path, or an empty string if it is
+// unassigned.
+func templateValue(resourceType *spec.ResourceType, root prop.Property, path string) (string, error) {
+	head, err := expr.CompilePathWith(resourceType.Registry(), strings.TrimSpace(path))
+	if err != nil {
+		return "", err
+	}
+	if head.ContainsFilter() {
+		return "", fmt.Errorf("%w: template path '%s' cannot contain filter", spec.ErrInvalidPath, path)
+	}
+	if head.Token() == resourceType.Schema().ID() {
+		head = head.Next()
+	}
+
+	nav := prop.Navigate(root)
+	for cursor := head; cursor != nil; cursor = cursor.Next() {
+		if nav.Current().Attribute().MultiValued() {
+			if !primaryOrFirst(nav) {
+				return "", nil
+			}
+		}
+		if nav.Dot(cursor.Token()).HasError() {
+			return "", nav.Error()
+		}
+	}
+	if nav.Current().Attribute().MultiValued() && !primaryOrFirst(nav) {
+		return "", nil
+	}
+
+	switch {
+	case nav.Current().Attribute().Type() == spec.TypeComplex:
+		return "", fmt.Errorf("%w: template path '%s' refers to complex attribute", spec.ErrInvalidPath, path)
+	case nav.Current().IsUnassigned():
+		return "", nil
+	default:
+		return fmt.Sprintf("%v", nav.Current().Raw()), nil
+	}
+}
+
+// primaryOrFirst focuses on the element of the multiValued property whose primary sub property is true, or the first
+// element if none is primary. It returns false if there are no elements.
+func primaryOrFirst(nav prop.Navigator) bool {
+	if nav.Current().CountChildren() == 0 {
+		return false
+	}
+
+	primaryAttr := nav.Current().Attribute().FindSubAttribute(func(subAttr *spec.Attribute) bool {
+		_, ok := subAttr.Annotation(annotation.Primary)
+		return ok && subAttr.Type() == spec.TypeBoolean
+	})
+	if primaryAttr != nil {
+		isPrimary := func(child prop.Property) bool {
+			p, err := child.ChildAtIndex(primaryAttr.Name())
+			return err == nil && p != nil && p.Raw() == true
+		}
+		if nav.Current().FindChild(isPrimary) != nil {
+			return !nav.Where(isPrimary).HasError()
+		}
+	}
+
+	return !nav.At(0).HasError()
+}
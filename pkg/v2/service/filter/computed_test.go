@@ -0,0 +1,174 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/v2/crud"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+func TestComputedFilter(t *testing.T) {
+	registry := spec.NewSchemaRegistry()
+	core, err := ioutil.ReadFile("../../../../public/schemas/core_schema.json")
+	require.Nil(t, err)
+	for _, raw := range [][]byte{core, []byte(computedTestSchema)} {
+		schema := new(spec.Schema)
+		require.Nil(t, json.Unmarshal(raw, schema))
+		registry.Register(schema)
+	}
+	resourceType, err := registry.ParseResourceType([]byte(`{
+		"id": "Contact",
+		"name": "Contact",
+		"endpoint": "/Contacts",
+		"schema": "urn:imulab:scim:2.0:Contact"
+	}`))
+	require.Nil(t, err)
+	crud.Register(resourceType)
+
+	tests := []struct {
+		name         string
+		data         map[string]interface{}
+		hasReference bool
+		expect       func(t *testing.T, r *prop.Resource, err error)
+	}{
+		{
+			name: "computed from sub attributes and the primary element",
+			data: map[string]interface{}{
+				"displayName": "client supplied",
+				"name":        map[string]interface{}{"givenName": "David", "familyName": "Q"},
+				"emails": []interface{}{
+					map[string]interface{}{"value": "david@work.com"},
+					map[string]interface{}{"value": "david@home.com", "primary": true},
+				},
+			},
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "David Q", r.Navigator().Dot("displayName").Current().Raw())
+				assert.Equal(t, "David Q <david@home.com>", r.Navigator().Dot("label").Current().Raw())
+			},
+		},
+		{
+			name: "unassigned values are empty",
+			data: map[string]interface{}{
+				"name": map[string]interface{}{"familyName": "Q"},
+				"emails": []interface{}{
+					map[string]interface{}{"value": "david@work.com"},
+				},
+			},
+			hasReference: true,
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "Q", r.Navigator().Dot("displayName").Current().Raw())
+				assert.Equal(t, "Q <david@work.com>", r.Navigator().Dot("label").Current().Raw())
+			},
+		},
+		{
+			name: "empty result unassigns",
+			data: map[string]interface{}{
+				"displayName": "client supplied",
+			},
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.True(t, r.Navigator().Dot("displayName").Current().IsUnassigned())
+				assert.Equal(t, "<>", r.Navigator().Dot("label").Current().Raw())
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resource := prop.NewResource(resourceType)
+			_, err := resource.RootProperty().Replace(test.data)
+			require.Nil(t, err)
+
+			if test.hasReference {
+				err = VisitWithRef(context.Background(), resource, resource.Clone(), ComputedFilter())
+			} else {
+				err = Visit(context.Background(), resource, ComputedFilter())
+			}
+			test.expect(t, resource, err)
+		})
+	}
+}
+
+const computedTestSchema = `{
+  "id": "urn:imulab:scim:2.0:Contact",
+  "name": "Contact",
+  "attributes": [
+    {
+      "id": "urn:imulab:scim:2.0:Contact:displayName",
+      "name": "displayName",
+      "type": "string",
+      "_index": 100,
+      "_path": "displayName",
+      "_annotations": {
+        "@Computed": {"template": "{name.givenName} {urn:imulab:scim:2.0:Contact:name.familyName}"}
+      }
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Contact:label",
+      "name": "label",
+      "type": "string",
+      "_index": 101,
+      "_path": "label",
+      "_annotations": {
+        "@Computed": {"template": "{displayName} <{emails.value}>"}
+      }
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Contact:name",
+      "name": "name",
+      "type": "complex",
+      "_index": 102,
+      "_path": "name",
+      "subAttributes": [
+        {
+          "id": "urn:imulab:scim:2.0:Contact:name.givenName",
+          "name": "givenName",
+          "type": "string",
+          "_index": 0,
+          "_path": "name.givenName"
+        },
+        {
+          "id": "urn:imulab:scim:2.0:Contact:name.familyName",
+          "name": "familyName",
+          "type": "string",
+          "_index": 1,
+          "_path": "name.familyName"
+        }
+      ]
+    },
+    {
+      "id": "urn:imulab:scim:2.0:Contact:emails",
+      "name": "emails",
+      "type": "complex",
+      "multiValued": true,
+      "_index": 103,
+      "_path": "emails",
+      "subAttributes": [
+        {
+          "id": "urn:imulab:scim:2.0:Contact:emails.value",
+          "name": "value",
+          "type": "string",
+          "_index": 0,
+          "_path": "emails.value"
+        },
+        {
+          "id": "urn:imulab:scim:2.0:Contact:emails.primary",
+          "name": "primary",
+          "type": "boolean",
+          "_index": 1,
+          "_path": "emails.primary",
+          "_annotations": {
+            "@Primary": {}
+          }
+        }
+      ]
+    }
+  ]
+}`
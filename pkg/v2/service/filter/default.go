@@ -0,0 +1,70 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// DefaultFilter returns a ByProperty filter that supplies default values to properties annotated with @Default. The
+// value of the "value" annotation parameter replaces the property value when the property is unassigned on creation.
+// Properties are left untouched on replace and patch, so clients can still unassign them. The generated value will
+// trigger event propagation.
+func DefaultFilter() ByProperty {
+	return defaultPropertyFilter{}
+}
+
+type defaultPropertyFilter struct{}
+
+func (f defaultPropertyFilter) Supports(attribute *spec.Attribute) bool {
+	params, ok := attribute.Annotation(annotation.Default)
+	if !ok {
+		return false
+	}
+	_, ok = params["value"]
+	return ok && attribute.Type() != spec.TypeComplex
+}
+
+func (f defaultPropertyFilter) Filter(_ context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+
+	if !nav.Current().IsUnassigned() {
+		return nil
+	}
+
+	attr := nav.Current().Attribute()
+	params, _ := attr.Annotation(annotation.Default)
+	if err := nav.Replace(integersOf(attr, params["value"])).Error(); err != nil {
+		return fmt.Errorf("%w: invalid default value for '%s'", spec.ErrInternal, attr.Path())
+	}
+	return nil
+}
+
+func (f defaultPropertyFilter) FilterRef(_ context.Context, _ *spec.ResourceType, _ prop.Navigator, _ prop.Navigator) error {
+	return nil
+}
+
+// integersOf converts the numbers in the annotation parameter to int64 for integer attributes, as annotation
+// parameters are parsed from JSON where numbers are float64.
+func integersOf(attr *spec.Attribute, value interface{}) interface{} {
+	if attr.Type() != spec.TypeInteger {
+		return value
+	}
+	switch v := value.(type) {
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+	case []interface{}:
+		converted := make([]interface{}, 0, len(v))
+		for _, each := range v {
+			converted = append(converted, integersOf(attr, each))
+		}
+		return converted
+	}
+	return value
+}
@@ -0,0 +1,134 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDefaultFilter(t *testing.T) {
+	attributeOf := func(raw string) *spec.Attribute {
+		attr := new(spec.Attribute)
+		require.Nil(t, json.Unmarshal([]byte(raw), attr))
+		return attr
+	}
+	var (
+		locale = attributeOf(`{"id":"locale","name":"locale","type":"string","_annotations":{"@Default":{"value":"en-US"}}}`)
+		level  = attributeOf(`{"id":"level","name":"level","type":"integer","_annotations":{"@Default":{"value":1}}}`)
+		roles  = attributeOf(`{"id":"roles","name":"roles","type":"string","multiValued":true,"_annotations":{"@Default":{"value":["reader"]}}}`)
+		broken = attributeOf(`{"id":"active","name":"active","type":"boolean","_annotations":{"@Default":{"value":"yes"}}}`)
+	)
+
+	tests := []struct {
+		name         string
+		getProperty  func() prop.Property
+		getReference func() prop.Property
+		expect       func(t *testing.T, p prop.Property, err error)
+	}{
+		{
+			name: "unassigned property gets default",
+			getProperty: func() prop.Property {
+				return prop.NewProperty(locale)
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "en-US", p.Raw())
+			},
+		},
+		{
+			name: "unassigned integer property gets default",
+			getProperty: func() prop.Property {
+				return prop.NewProperty(level)
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, int64(1), p.Raw())
+			},
+		},
+		{
+			name: "unassigned multiValued property gets default",
+			getProperty: func() prop.Property {
+				return prop.NewProperty(roles)
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{"reader"}, p.Raw())
+			},
+		},
+		{
+			name: "assigned property keeps value",
+			getProperty: func() prop.Property {
+				p := prop.NewProperty(locale)
+				_, err := p.Replace("fr-FR")
+				assert.Nil(t, err)
+				return p
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "fr-FR", p.Raw())
+			},
+		},
+		{
+			name: "unassigned property with reference is left unassigned",
+			getProperty: func() prop.Property {
+				return prop.NewProperty(locale)
+			},
+			getReference: func() prop.Property {
+				return prop.NewProperty(locale)
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.True(t, p.IsUnassigned())
+			},
+		},
+		{
+			name: "incompatible default is an error",
+			getProperty: func() prop.Property {
+				return prop.NewProperty(broken)
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := DefaultFilter()
+
+			property := test.getProperty()
+			reference := test.getReference()
+			assert.True(t, filter.Supports(property.Attribute()))
+
+			var err error
+			if reference == nil {
+				err = filter.Filter(context.Background(),
+					nil, prop.Navigate(property))
+			} else {
+				err = filter.FilterRef(context.Background(),
+					nil, prop.Navigate(property), prop.Navigate(reference))
+			}
+
+			test.expect(t, property, err)
+		})
+	}
+}
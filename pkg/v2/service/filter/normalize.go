@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"strings"
+)
+
+// NormalizeFilter returns a ByProperty filter that normalizes the values of string properties annotated with
+// @Normalize. The boolean annotation parameters "nfc", "trim", "lowercase" and "e164" enable the Unicode normalization
+// form C, the removal of leading and trailing white spaces, the conversion to lower case and the formatting of phone
+// numbers in E.164 respectively, which are applied in this order. Phone numbers that cannot be formatted in E.164 are
+// rejected as invalid values. The changed value will trigger event propagation.
+func NormalizeFilter() ByProperty {
+	return normalizePropertyFilter{}
+}
+
+type normalizePropertyFilter struct{}
+
+func (f normalizePropertyFilter) Supports(attribute *spec.Attribute) bool {
+	if _, ok := attribute.Annotation(annotation.Normalize); !ok {
+		return false
+	}
+	return !attribute.MultiValued() && attribute.Type() == spec.TypeString
+}
+
+func (f normalizePropertyFilter) Filter(_ context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+	return f.normalize(nav)
+}
+
+func (f normalizePropertyFilter) FilterRef(_ context.Context, _ *spec.ResourceType, nav prop.Navigator, _ prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+	return f.normalize(nav)
+}
+
+func (f normalizePropertyFilter) normalize(nav prop.Navigator) error {
+	if nav.Current().IsUnassigned() {
+		return nil
+	}
+
+	attr := nav.Current().Attribute()
+	params, _ := attr.Annotation(annotation.Normalize)
+	enabled := func(step string) bool {
+		b, ok := params[step].(bool)
+		return ok && b
+	}
+
+	value := nav.Current().Raw().(string)
+	if enabled("nfc") {
+		value = norm.NFC.String(value)
+	}
+	if enabled("trim") {
+		value = strings.TrimSpace(value)
+	}
+	if enabled("lowercase") {
+		value = strings.ToLower(value)
+	}
+	if enabled("e164") {
+		countryCode, _ := params["countryCode"].(string)
+		formatted, ok := formatE164(value, countryCode)
+		if !ok {
+			return fmt.Errorf("%w: value of '%s' is not a valid phone number", spec.ErrInvalidValue, attr.Path())
+		}
+		value = formatted
+	}
+
+	if value == nav.Current().Raw() {
+		return nil
+	}
+	return nav.Replace(value).Error()
+}
+
+var (
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "")
+	e164Pattern     = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+)
+
+// formatE164 formats the phone number in E.164. Separators are removed, and the international call prefix 00 is
+// replaced with +. Numbers without the international prefix are national numbers: their trunk prefix 0 is removed and
+// they are prefixed with the country code, which must be present.
+func formatE164(number string, countryCode string) (string, bool) {
+	number = phoneSeparators.Replace(number)
+	switch {
+	case strings.HasPrefix(number, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = "+" + number[2:]
+	case len(countryCode) > 0:
+		number = "+" + strings.TrimPrefix(countryCode, "+") + strings.TrimPrefix(number, "0")
+	default:
+		return "", false
+	}
+	return number, e164Pattern.MatchString(number)
+}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalizeFilter(t *testing.T) {
+	attributeOf := func(raw string) *spec.Attribute {
+		attr := new(spec.Attribute)
+		require.Nil(t, json.Unmarshal([]byte(raw), attr))
+		return attr
+	}
+	var (
+		userName = attributeOf(`{"id":"userName","name":"userName","type":"string","_annotations":{"@Normalize":{"nfc":true,"trim":true,"lowercase":true}}}`)
+		phone    = attributeOf(`{"id":"phone","name":"phone","type":"string","_annotations":{"@Normalize":{"trim":true,"e164":true,"countryCode":"44"}}}`)
+		fax      = attributeOf(`{"id":"fax","name":"fax","type":"string","_annotations":{"@Normalize":{"e164":true}}}`)
+	)
+
+	tests := []struct {
+		name   string
+		attr   *spec.Attribute
+		value  interface{}
+		expect func(t *testing.T, p prop.Property, err error)
+	}{
+		{
+			name:  "unassigned property is left unassigned",
+			attr:  userName,
+			value: nil,
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.True(t, p.IsUnassigned())
+			},
+		},
+		{
+			name:  "nfc, trim and lowercase",
+			attr:  userName,
+			value: "  José@Example.com ",
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "josé@example.com", p.Raw())
+			},
+		},
+		{
+			name:  "international phone number",
+			attr:  phone,
+			value: " +1 (415) 555-0100 ",
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "+14155550100", p.Raw())
+			},
+		},
+		{
+			name:  "international call prefix",
+			attr:  phone,
+			value: "0049 30 1234567",
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "+49301234567", p.Raw())
+			},
+		},
+		{
+			name:  "national phone number with country code",
+			attr:  phone,
+			value: "020 7946 0018",
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "+442079460018", p.Raw())
+			},
+		},
+		{
+			name:  "national phone number without country code",
+			attr:  fax,
+			value: "020 7946 0018",
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+			},
+		},
+		{
+			name:  "invalid phone number",
+			attr:  phone,
+			value: "+1 call me",
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.True(t, errors.Is(err, spec.ErrInvalidValue))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := NormalizeFilter()
+
+			property := prop.NewProperty(test.attr)
+			_, err := property.Replace(test.value)
+			require.Nil(t, err)
+			assert.True(t, filter.Supports(property.Attribute()))
+
+			err = filter.Filter(context.Background(), nil, prop.Navigate(property))
+			test.expect(t, property, err)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package filter
+
+import (
+	"context"
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"github.com/imulab/go-scim/pkg/v2/password"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+)
+
+// PasswordHashFilter returns a ByProperty filter that hashes data for string or binary properties whose attribute is
+// annotated with @PasswordHash, using the algorithm and parameters of the annotation. If the property is unassigned
+// or has the same value with the reference property, the filter does nothing. Otherwise, it will replace the property
+// value with the hash in the PHC string format. For binary properties specifically, the hash is base64 encoded before
+// replacing the original base64 encoded bytes. See package password for verifying passwords against the hash.
+func PasswordHashFilter() ByProperty {
+	return passwordHashPropertyFilter{}
+}
+
+type passwordHashPropertyFilter struct{}
+
+func (f passwordHashPropertyFilter) Supports(attribute *spec.Attribute) bool {
+	if _, ok := attribute.Annotation(annotation.PasswordHash); !ok {
+		return false
+	}
+	return !attribute.MultiValued() && (attribute.Type() == spec.TypeString || attribute.Type() == spec.TypeBinary)
+}
+
+func (f passwordHashPropertyFilter) Filter(_ context.Context, _ *spec.ResourceType, nav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+	return password.HashProperty(nav.Current())
+}
+
+func (f passwordHashPropertyFilter) FilterRef(_ context.Context, _ *spec.ResourceType, nav prop.Navigator, refNav prop.Navigator) error {
+	if nav.HasError() {
+		return nav.Error()
+	}
+
+	if refNav != nil && nav.Current().Raw() == refNav.Current().Raw() {
+		// property value is the same as the reference value, which is the
+		// hash stored in database. Hashing it again would lose the password.
+		return nil
+	}
+
+	return password.HashProperty(nav.Current())
+}
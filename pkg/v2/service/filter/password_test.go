@@ -0,0 +1,168 @@
+package filter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/v2/password"
+	"github.com/imulab/go-scim/pkg/v2/prop"
+	"github.com/imulab/go-scim/pkg/v2/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHashFilter(t *testing.T) {
+	attr := new(spec.Attribute)
+	require.Nil(t, json.Unmarshal([]byte(`
+{
+  "id": "password",
+  "name": "password",
+  "type": "string",
+  "_annotations": {
+    "@PasswordHash": {
+      "algorithm": "argon2id",
+      "memory": 64,
+      "iterations": 1,
+      "parallelism": 1
+	}
+  }
+}
+`), attr))
+	binaryAttr := new(spec.Attribute)
+	require.Nil(t, json.Unmarshal([]byte(`
+{
+  "id": "pin",
+  "name": "pin",
+  "type": "binary",
+  "_annotations": {
+    "@PasswordHash": {
+      "algorithm": "scrypt",
+      "logN": 4
+	}
+  }
+}
+`), binaryAttr))
+
+	tests := []struct {
+		name         string
+		getProperty  func() prop.Property
+		getReference func() prop.Property
+		expect       func(t *testing.T, p prop.Property, err error)
+	}{
+		{
+			name: "unassigned property does not hash",
+			getProperty: func() prop.Property {
+				return prop.NewProperty(attr)
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.True(t, p.IsUnassigned())
+			},
+		},
+		{
+			name: "assigned property is hashed",
+			getProperty: func() prop.Property {
+				p := prop.NewProperty(attr)
+				_, err := p.Replace("s3cret")
+				assert.Nil(t, err)
+				return p
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.True(t, strings.HasPrefix(p.Raw().(string), "$argon2id$v=19$m=64,t=1,p=1$"))
+				ok, rehash, err := password.VerifyProperty(p, []byte("s3cret"))
+				assert.Nil(t, err)
+				assert.True(t, ok)
+				assert.Empty(t, rehash)
+			},
+		},
+		{
+			name: "assigned binary property is hashed and base64 encoded",
+			getProperty: func() prop.Property {
+				p := prop.NewProperty(binaryAttr)
+				_, err := p.Replace(base64.StdEncoding.EncodeToString([]byte("1234")))
+				assert.Nil(t, err)
+				return p
+			},
+			getReference: func() prop.Property {
+				return nil
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				hash, err := base64.StdEncoding.DecodeString(p.Raw().(string))
+				assert.Nil(t, err)
+				assert.True(t, strings.HasPrefix(string(hash), "$scrypt$ln=4,r=8,p=1$"))
+				ok, _, err := password.VerifyProperty(p, []byte("1234"))
+				assert.Nil(t, err)
+				assert.True(t, ok)
+			},
+		},
+		{
+			name: "same value as reference does not hash",
+			getProperty: func() prop.Property {
+				p := prop.NewProperty(attr)
+				_, err := p.Replace("pretending_to_have_been_hashed")
+				assert.Nil(t, err)
+				return p
+			},
+			getReference: func() prop.Property {
+				p := prop.NewProperty(attr)
+				_, err := p.Replace("pretending_to_have_been_hashed")
+				assert.Nil(t, err)
+				return p
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "pretending_to_have_been_hashed", p.Raw())
+			},
+		},
+		{
+			name: "different value as reference gets hashed",
+			getProperty: func() prop.Property {
+				p := prop.NewProperty(attr)
+				_, err := p.Replace("new_s3cret")
+				assert.Nil(t, err)
+				return p
+			},
+			getReference: func() prop.Property {
+				p := prop.NewProperty(attr)
+				_, err := p.Replace("pretending_to_have_been_hashed")
+				assert.Nil(t, err)
+				return p
+			},
+			expect: func(t *testing.T, p prop.Property, err error) {
+				assert.Nil(t, err)
+				assert.True(t, strings.HasPrefix(p.Raw().(string), "$argon2id$"))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := PasswordHashFilter()
+
+			property := test.getProperty()
+			reference := test.getReference()
+			assert.True(t, filter.Supports(property.Attribute()))
+
+			var err error
+			if reference == nil {
+				err = filter.Filter(context.Background(),
+					nil, prop.Navigate(property))
+			} else {
+				err = filter.FilterRef(context.Background(),
+					nil, prop.Navigate(property), prop.Navigate(reference))
+			}
+
+			test.expect(t, property, err)
+		})
+	}
+}
@@ -27,7 +27,9 @@ import (
 // The uniqueness check fails when the property value already exists in the database. It formulates the query
 // (id ne <id>) and (<path> eq <value>), where <id> is the resource id, <path> is the unique attribute path, and
 // <value> is the property value. The database returns the number of records matching this filter. If the count is
-// greater than 0, the check fails. Note this check only handles the uniqueness=server case.
+// greater than 0, the check fails with spec.ErrUniqueness. Note this check only handles the uniqueness=server case.
+// As the check and the subsequent write are not atomic, it only provides an early error to the client: the database
+// is responsible for the actual enforcement (see db.UniqueAttributes).
 //
 // Error is returned to caller if any of these check fails.
 func ValidationFilter(database db.DB) ByProperty {
@@ -170,7 +172,7 @@ func (f *validationPropertyFilter) validateUniqueness(ctx context.Context, nav p
 	if err != nil {
 		return err
 	} else if n > 0 {
-		return fmt.Errorf("%w: value of '%s' is not unique", spec.ErrInvalidValue, property.Attribute().Path())
+		return db.UniquenessError(property.Attribute())
 	}
 
 	return nil
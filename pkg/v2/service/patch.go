@@ -19,17 +19,20 @@ import (
 
 // PatchService returns a patch resource service. preFilters will run after resource fetched from database and before
 // resource is patched. postFilters will run after resource has been patched and before resource is saved back to database.
+// The options configure the deserialization of the operation values of all payloads, and may be extended for each request.
 func PatchService(
 	config *spec.ServiceProviderConfig,
 	database db.DB,
 	preFilters []filter.ByResource,
 	postFilters []filter.ByResource,
+	options ...scimjson.DeserializeOptions,
 ) Patch {
 	return &patchService{
 		preFilters:  preFilters,
 		postFilters: postFilters,
 		database:    database,
 		config:      config,
+		options:     options,
 	}
 }
 
@@ -54,12 +57,15 @@ type (
 		ResourceID    string                             // id of the resource to patch
 		MatchCriteria func(resource *prop.Resource) bool // extra criteria to meet for the resource to be patched
 		PayloadSource io.Reader                          // source to read the patch payload from
+		// options applied after those of the service, i.e. for the client
+		DeserializeOptions []scimjson.DeserializeOptions
 	}
 	// Patch resource response
 	PatchResponse struct {
-		Patched  bool           // true if the resource was patched; false if the resource was not patched but there was no error
-		Ref      *prop.Resource // reference resource (the before state)
-		Resource *prop.Resource // patched resource (the after state)
+		Patched  bool                // true if the resource was patched; false if the resource was not patched but there was no error
+		Ref      *prop.Resource      // reference resource (the before state)
+		Resource *prop.Resource      // patched resource (the after state)
+		Warnings []*scimjson.Warning // problems in the operation values tolerated by deserialization
 	}
 )
 
@@ -68,6 +74,7 @@ type patchService struct {
 	postFilters []filter.ByResource
 	database    db.DB
 	config      *spec.ServiceProviderConfig
+	options     []scimjson.DeserializeOptions
 }
 
 func (s *patchService) Do(ctx context.Context, req *PatchRequest) (resp *PatchResponse, err error) {
@@ -106,16 +113,18 @@ func (s *patchService) Do(ctx context.Context, req *PatchRequest) (resp *PatchRe
 		}
 	}
 
+	var warnings []*scimjson.Warning
+	options := deserializeOptions(&warnings, s.options, req.DeserializeOptions)
 	for _, patchOp := range patch.Operations {
 		switch strings.ToLower(patchOp.Op) {
 		case "add":
-			if valueToAdd, err := patchOp.ParseValue(resource); err != nil {
+			if valueToAdd, err := patchOp.ParseValue(resource, options...); err != nil {
 				return nil, err
 			} else if err := crud.Add(resource, patchOp.Path, valueToAdd); err != nil {
 				return nil, err
 			}
 		case "replace":
-			if valueToReplace, err := patchOp.ParseValue(resource); err != nil {
+			if valueToReplace, err := patchOp.ParseValue(resource, options...); err != nil {
 				return nil, err
 			} else if err := crud.Replace(resource, patchOp.Path, valueToReplace); err != nil {
 				return nil, err
@@ -139,8 +148,9 @@ func (s *patchService) Do(ctx context.Context, req *PatchRequest) (resp *PatchRe
 	)
 	if newVersion == oldVersion {
 		resp = &PatchResponse{
-			Patched: false,
-			Ref:     ref,
+			Patched:  false,
+			Ref:      ref,
+			Warnings: warnings,
 		}
 		return
 	}
@@ -153,6 +163,7 @@ func (s *patchService) Do(ctx context.Context, req *PatchRequest) (resp *PatchRe
 		Patched:  true,
 		Resource: resource,
 		Ref:      ref,
+		Warnings: warnings,
 	}
 	return
 }
@@ -211,14 +222,16 @@ func (p *PatchPayload) Validate() error {
 	return nil
 }
 
-func (o *PatchOperation) ParseValue(resource *prop.Resource) (interface{}, error) {
+// ParseValue deserializes the value of the operation into the raw value of the property at the path of the operation,
+// according to the options.
+func (o *PatchOperation) ParseValue(resource *prop.Resource, options ...scimjson.DeserializeOptions) (interface{}, error) {
 	var (
 		head *expr.Expression
 		err  error
 	)
 	{
 		if len(o.Path) > 0 {
-			head, err = expr.CompilePath(o.Path)
+			head, err = expr.CompilePathWith(resource.ResourceType().Registry(), o.Path)
 			if err != nil {
 				return nil, err
 			}
@@ -234,7 +247,7 @@ func (o *PatchOperation) ParseValue(resource *prop.Resource) (interface{}, error
 	}
 
 	p := prop.NewProperty(attr)
-	if err := scimjson.DeserializeProperty(o.Value, p, o.Op == "add"); err != nil {
+	if err := scimjson.DeserializeProperty(o.Value, p, o.Op == "add", options...); err != nil {
 		return nil, err
 	}
 
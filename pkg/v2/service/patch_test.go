@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/imulab/go-scim/pkg/v2/db"
+	scimjson "github.com/imulab/go-scim/pkg/v2/json"
 	"github.com/imulab/go-scim/pkg/v2/prop"
 	"github.com/imulab/go-scim/pkg/v2/service/filter"
 	"github.com/imulab/go-scim/pkg/v2/spec"
@@ -206,6 +207,50 @@ func (s *PatchServiceTestSuite) TestDo() {
 				assert.Equal(t, "work", resp.Resource.Navigator().Dot("emails").At(0).Dot("type").Current().Raw())
 			},
 		},
+		{
+			name: "patch with deserialization options",
+			setup: func(t *testing.T) Patch {
+				database := db.Memory()
+				err := database.Insert(context.TODO(), s.resourceOf(t, map[string]interface{}{
+					"schemas":  []interface{}{"urn:ietf:params:scim:schemas:core:2.0:User"},
+					"id":       "foo",
+					"userName": "foo",
+				}))
+				require.Nil(t, err)
+				return PatchService(s.config, database, nil, []filter.ByResource{filter.MetaFilter()},
+					scimjson.Coerce(scimjson.CoerceBooleanString, scimjson.CoerceSingleValue))
+			},
+			getRequest: func() *PatchRequest {
+				return &PatchRequest{
+					ResourceID: "foo",
+					PayloadSource: strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [
+		{
+			"op": "replace",
+			"path": "active",
+			"value": "False"
+		},
+		{
+			"op": "replace",
+			"path": "emails",
+			"value": {"value": "foo@bar.com", "label": "home"}
+		}
+	]
+}
+`),
+					DeserializeOptions: []scimjson.DeserializeOptions{scimjson.Lenient()},
+				}
+			},
+			expect: func(t *testing.T, resp *PatchResponse, err error) {
+				assert.Nil(t, err)
+				assert.True(t, resp.Patched)
+				assert.Equal(t, false, resp.Resource.Navigator().Dot("active").Current().Raw())
+				assert.Equal(t, "foo@bar.com", resp.Resource.Navigator().Dot("emails").At(0).Dot("value").Current().Raw())
+				assert.Len(t, resp.Warnings, 3)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -51,7 +51,7 @@ func (s *queryService) Do(ctx context.Context, req *QueryRequest) (resp *QueryRe
 		return
 	}
 
-	if err = req.ValidateAndDefault(); err != nil {
+	if err = req.ValidateAndDefaultWith(spec.RegistryFrom(ctx)); err != nil {
 		return
 	}
 
@@ -105,11 +105,19 @@ func (s *queryService) checkSupport(request *QueryRequest) error {
 	return nil
 }
 
+// ValidateAndDefault validates the request and fills in default values, recognizing URN prefixes from the default
+// schema registry.
 func (q *QueryRequest) ValidateAndDefault() error {
+	return q.ValidateAndDefaultWith(spec.Schemas())
+}
+
+// ValidateAndDefaultWith validates the request and fills in default values, recognizing URN prefixes from the given
+// schema registry.
+func (q *QueryRequest) ValidateAndDefaultWith(registry *spec.SchemaRegistry) error {
 	if len(q.Filter) == 0 {
 		q.Filter = "id pr"
 	} else {
-		if _, err := expr.CompileFilter(q.Filter); err != nil {
+		if _, err := expr.CompileFilterWith(registry, q.Filter); err != nil {
 			return err
 		}
 	}
@@ -122,7 +130,7 @@ func (q *QueryRequest) ValidateAndDefault() error {
 		if len(q.Sort.By) == 0 {
 			q.Sort.By = "id"
 		} else {
-			if _, err := expr.CompilePath(q.Sort.By); err != nil {
+			if _, err := expr.CompilePathWith(registry, q.Sort.By); err != nil {
 				return err
 			}
 		}
@@ -138,14 +146,14 @@ func (q *QueryRequest) ValidateAndDefault() error {
 		}
 		if len(q.Projection.Attributes) > 0 {
 			for _, p := range q.Projection.Attributes {
-				if _, err := expr.CompilePath(p); err != nil {
+				if _, err := expr.CompilePathWith(registry, p); err != nil {
 					return err
 				}
 			}
 		}
 		if len(q.Projection.ExcludedAttributes) > 0 {
 			for _, p := range q.Projection.ExcludedAttributes {
-				if _, err := expr.CompilePath(p); err != nil {
+				if _, err := expr.CompilePathWith(registry, p); err != nil {
 					return err
 				}
 			}
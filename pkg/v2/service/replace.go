@@ -12,18 +12,21 @@ import (
 	"io/ioutil"
 )
 
-// ReplaceService returns a replace service.
+// ReplaceService returns a replace service. The options configure the deserialization of all payloads, and may be
+// extended for each request.
 func ReplaceService(
 	config *spec.ServiceProviderConfig,
 	resourceType *spec.ResourceType,
 	database db.DB,
 	filters []filter.ByResource,
+	options ...json.DeserializeOptions,
 ) Replace {
 	return &replaceService{
 		resourceType: resourceType,
 		filters:      filters,
 		database:     database,
 		config:       config,
+		options:      options,
 	}
 }
 
@@ -37,12 +40,15 @@ type (
 		ResourceID    string                             // id of the resource to be replaced
 		PayloadSource io.Reader                          // source to read replacement payload from
 		MatchCriteria func(resource *prop.Resource) bool // extra criteria to meet in order to be replaced
+		// options applied after those of the service, i.e. for the client
+		DeserializeOptions []json.DeserializeOptions
 	}
 	// Replace resource response
 	ReplaceResponse struct {
-		Replaced bool           // true if resource was replaced; false if resource was not replaced, but has no error
-		Ref      *prop.Resource // reference resource (before state)
-		Resource *prop.Resource // replaced resource (after state)
+		Replaced bool            // true if resource was replaced; false if resource was not replaced, but has no error
+		Ref      *prop.Resource  // reference resource (before state)
+		Resource *prop.Resource  // replaced resource (after state)
+		Warnings []*json.Warning // problems in the payload tolerated by deserialization
 	}
 )
 
@@ -51,6 +57,7 @@ type replaceService struct {
 	filters      []filter.ByResource
 	database     db.DB
 	config       *spec.ServiceProviderConfig
+	options      []json.DeserializeOptions
 }
 
 func (s *replaceService) Do(ctx context.Context, req *ReplaceRequest) (resp *ReplaceResponse, err error) {
@@ -66,7 +73,7 @@ func (s *replaceService) Do(ctx context.Context, req *ReplaceRequest) (resp *Rep
 		}
 	}
 
-	replacement, err := s.parseResource(req)
+	replacement, warnings, err := s.parseResource(req)
 	if err != nil {
 		return
 	}
@@ -85,6 +92,7 @@ func (s *replaceService) Do(ctx context.Context, req *ReplaceRequest) (resp *Rep
 		resp = &ReplaceResponse{
 			Replaced: false,
 			Ref:      ref,
+			Warnings: warnings,
 		}
 		return
 	}
@@ -97,24 +105,26 @@ func (s *replaceService) Do(ctx context.Context, req *ReplaceRequest) (resp *Rep
 		Replaced: true,
 		Resource: replacement,
 		Ref:      ref,
+		Warnings: warnings,
 	}
 	return
 }
 
-func (s *replaceService) parseResource(req *ReplaceRequest) (*prop.Resource, error) {
+func (s *replaceService) parseResource(req *ReplaceRequest) (*prop.Resource, []*json.Warning, error) {
 	if req == nil || req.PayloadSource == nil {
-		return nil, fmt.Errorf("%w: no payload for replace service", spec.ErrInternal)
+		return nil, nil, fmt.Errorf("%w: no payload for replace service", spec.ErrInternal)
 	}
 
 	raw, err := ioutil.ReadAll(req.PayloadSource)
 	if err != nil {
-		return nil, fmt.Errorf("%w: failed to read request body", spec.ErrInternal)
+		return nil, nil, fmt.Errorf("%w: failed to read request body", spec.ErrInternal)
 	}
 
+	var warnings []*json.Warning
 	resource := prop.NewResource(s.resourceType)
-	if err := json.Deserialize(raw, resource); err != nil {
-		return nil, err
+	if err := json.Deserialize(raw, resource, deserializeOptions(&warnings, s.options, req.DeserializeOptions)...); err != nil {
+		return nil, nil, err
 	}
 
-	return resource, nil
+	return resource, warnings, nil
 }
@@ -0,0 +1,21 @@
+package spec
+
+import "context"
+
+type registryKey struct{}
+
+// WithRegistry returns a copy of the context that carries the schema registry. Components that work on SCIM paths and
+// filters without a resource type at hand (i.e. validating a query request) use this registry to recognize URN prefixes.
+func WithRegistry(ctx context.Context, registry *SchemaRegistry) context.Context {
+	return context.WithValue(ctx, registryKey{}, registry)
+}
+
+// RegistryFrom returns the schema registry carried by the context, or the default registry if none was set.
+func RegistryFrom(ctx context.Context) *SchemaRegistry {
+	if ctx != nil {
+		if registry, ok := ctx.Value(registryKey{}).(*SchemaRegistry); ok && registry != nil {
+			return registry
+		}
+	}
+	return Schemas()
+}
@@ -0,0 +1,169 @@
+package spec
+
+import (
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"strings"
+)
+
+// SchemaChange is a change between two versions of a schema.
+type SchemaChange struct {
+	// Path of the changed attribute, or empty if the change is to the schema itself.
+	Path string
+	// Description of the change.
+	Message string
+	// True if resources or clients working with the old version may fail with the new version.
+	Breaking bool
+}
+
+func (c *SchemaChange) String() string {
+	if c.Breaking {
+		return "breaking: " + c.Message
+	}
+	return "compatible: " + c.Message
+}
+
+// DiffSchemas returns the changes from the old version of a schema to the new version, ordered by the attributes of
+// the old version followed by new attributes. Changes are breaking if they change the schema id; remove an attribute;
+// change its type, multiValued or caseExact characteristics; make a new or existing attribute required; tighten
+// mutability so that an attribute can no longer be written or read in ways it could before; tighten uniqueness; or
+// remove a canonical value enforced by @Enum. Other changes, such as new optional attributes, relaxed characteristics,
+// and changes to descriptions and returned, are compatible.
+func DiffSchemas(old *Schema, new *Schema) []*SchemaChange {
+	var changes []*SchemaChange
+	if old.ID() != new.ID() {
+		changes = append(changes, &SchemaChange{
+			Message:  fmt.Sprintf("schema id changed from '%s' to '%s'", old.ID(), new.ID()),
+			Breaking: true,
+		})
+	}
+	if old.Description() != new.Description() {
+		changes = append(changes, &SchemaChange{Message: "schema description changed"})
+	}
+	return append(changes, diffAttributes("", old.attributes, new.attributes)...)
+}
+
+func diffAttributes(parentPath string, old []*Attribute, new []*Attribute) []*SchemaChange {
+	var changes []*SchemaChange
+
+	for _, oldAttr := range old {
+		path := joinLintPath(parentPath, oldAttr.Name())
+		change := func(breaking bool, format string, args ...interface{}) {
+			changes = append(changes, &SchemaChange{Path: path, Message: fmt.Sprintf(format, args...), Breaking: breaking})
+		}
+
+		newAttr := findAttribute(new, oldAttr.Name())
+		if newAttr == nil {
+			change(true, "attribute '%s' was removed", path)
+			continue
+		}
+
+		if oldAttr.Type() != newAttr.Type() {
+			change(true, "type of attribute '%s' changed from %s to %s", path, oldAttr.Type().String(), newAttr.Type().String())
+			continue
+		}
+		if oldAttr.MultiValued() != newAttr.MultiValued() {
+			change(true, "multiValued of attribute '%s' changed to %t", path, newAttr.MultiValued())
+		}
+		if oldAttr.CaseExact() != newAttr.CaseExact() {
+			change(true, "caseExact of attribute '%s' changed to %t", path, newAttr.CaseExact())
+		}
+		if oldAttr.Required() != newAttr.Required() {
+			if newAttr.Required() {
+				change(true, "attribute '%s' became required", path)
+			} else {
+				change(false, "attribute '%s' is no longer required", path)
+			}
+		}
+		if oldAttr.Mutability() != newAttr.Mutability() {
+			if oldAttr.Mutability().capabilities()&^newAttr.Mutability().capabilities() != 0 {
+				change(true, "mutability of attribute '%s' tightened from %s to %s", path, oldAttr.Mutability().String(), newAttr.Mutability().String())
+			} else {
+				change(false, "mutability of attribute '%s' relaxed from %s to %s", path, oldAttr.Mutability().String(), newAttr.Mutability().String())
+			}
+		}
+		if oldAttr.Uniqueness() != newAttr.Uniqueness() {
+			if newAttr.Uniqueness() > oldAttr.Uniqueness() {
+				change(true, "uniqueness of attribute '%s' tightened from %s to %s", path, oldAttr.Uniqueness().String(), newAttr.Uniqueness().String())
+			} else {
+				change(false, "uniqueness of attribute '%s' relaxed from %s to %s", path, oldAttr.Uniqueness().String(), newAttr.Uniqueness().String())
+			}
+		}
+		if oldAttr.Returned() != newAttr.Returned() {
+			change(false, "returned of attribute '%s' changed from %s to %s", path, oldAttr.Returned().String(), newAttr.Returned().String())
+		}
+		if oldAttr.Description() != newAttr.Description() {
+			change(false, "description of attribute '%s' changed", path)
+		}
+
+		_, enforced := newAttr.Annotation(annotation.Enum)
+		for _, value := range oldAttr.canonicalValues {
+			if !containsCanonicalValue(newAttr, value) {
+				change(enforced, "canonical value '%s' of attribute '%s' was removed", value, path)
+			}
+		}
+		for _, value := range newAttr.canonicalValues {
+			if !containsCanonicalValue(oldAttr, value) {
+				change(false, "canonical value '%s' of attribute '%s' was added", value, path)
+			}
+		}
+
+		if oldAttr.Type() == TypeComplex {
+			changes = append(changes, diffAttributes(path, oldAttr.subAttributes, newAttr.subAttributes)...)
+		}
+	}
+
+	for _, newAttr := range new {
+		if findAttribute(old, newAttr.Name()) != nil {
+			continue
+		}
+		path := joinLintPath(parentPath, newAttr.Name())
+		if newAttr.Required() {
+			changes = append(changes, &SchemaChange{Path: path, Message: fmt.Sprintf("new attribute '%s' is required", path), Breaking: true})
+		} else {
+			changes = append(changes, &SchemaChange{Path: path, Message: fmt.Sprintf("attribute '%s' was added", path)})
+		}
+	}
+
+	return changes
+}
+
+// Capabilities granted to clients by mutability, as bit flags.
+const (
+	capabilityRead = 1 << iota
+	capabilityCreate
+	capabilityUpdate
+)
+
+func (m Mutability) capabilities() int {
+	switch m {
+	case MutabilityReadWrite:
+		return capabilityRead | capabilityCreate | capabilityUpdate
+	case MutabilityImmutable:
+		return capabilityRead | capabilityCreate
+	case MutabilityWriteOnly:
+		return capabilityCreate | capabilityUpdate
+	case MutabilityReadOnly:
+		return capabilityRead
+	default:
+		return 0
+	}
+}
+
+func findAttribute(attributes []*Attribute, name string) *Attribute {
+	for _, each := range attributes {
+		if each.GoesBy(name) {
+			return each
+		}
+	}
+	return nil
+}
+
+func containsCanonicalValue(attr *Attribute, value string) bool {
+	return attr.ExistsCanonicalValue(func(canonicalValue string) bool {
+		if attr.CaseExact() {
+			return canonicalValue == value
+		}
+		return strings.EqualFold(canonicalValue, value)
+	})
+}
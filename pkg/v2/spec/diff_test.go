@@ -0,0 +1,124 @@
+package spec
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	s := new(DiffSchemasTestSuite)
+	suite.Run(t, s)
+}
+
+type DiffSchemasTestSuite struct {
+	suite.Suite
+}
+
+func (s *DiffSchemasTestSuite) TestDiffSchemas() {
+	tests := []struct {
+		name   string
+		old    []byte
+		new    []byte
+		expect []string
+	}{
+		{
+			name:   "identical",
+			old:    lintSchemaOf(lintAttributeOf("serialNumber", `"type":"string"`)),
+			new:    lintSchemaOf(lintAttributeOf("serialNumber", `"type":"string"`)),
+			expect: nil,
+		},
+		{
+			name: "added attributes",
+			old:  lintSchemaOf(lintAttributeOf("serialNumber", `"type":"string"`)),
+			new: lintSchemaOf(
+				lintAttributeOf("serialNumber", `"type":"string"`),
+				lintAttributeOf("model", `"type":"string"`),
+				lintAttributeOf("vendor", `"type":"string","required":true`),
+			),
+			expect: []string{
+				"compatible: attribute 'model' was added",
+				"breaking: new attribute 'vendor' is required",
+			},
+		},
+		{
+			name: "changed characteristics",
+			old: lintSchemaOf(
+				lintAttributeOf("serialNumber", `"type":"string","mutability":"readWrite","uniqueness":"none"`),
+				lintAttributeOf("model", `"type":"string","required":true,"returned":"default"`),
+				lintAttributeOf("ports", `"type":"integer"`),
+				lintAttributeOf("vendor", `"type":"string"`),
+			),
+			new: lintSchemaOf(
+				lintAttributeOf("serialNumber", `"type":"string","mutability":"immutable","uniqueness":"server"`),
+				lintAttributeOf("model", `"type":"string","multiValued":true,"returned":"always"`),
+				lintAttributeOf("ports", `"type":"string"`),
+			),
+			expect: []string{
+				"breaking: mutability of attribute 'serialNumber' tightened from readWrite to immutable",
+				"breaking: uniqueness of attribute 'serialNumber' tightened from none to server",
+				"breaking: multiValued of attribute 'model' changed to true",
+				"compatible: attribute 'model' is no longer required",
+				"compatible: returned of attribute 'model' changed from default to always",
+				"breaking: type of attribute 'ports' changed from integer to string",
+				"breaking: attribute 'vendor' was removed",
+			},
+		},
+		{
+			name: "relaxed mutability",
+			old:  lintSchemaOf(lintAttributeOf("serialNumber", `"type":"string","mutability":"immutable","uniqueness":"global"`)),
+			new:  lintSchemaOf(lintAttributeOf("serialNumber", `"type":"string","mutability":"readWrite","uniqueness":"server"`)),
+			expect: []string{
+				"compatible: mutability of attribute 'serialNumber' relaxed from immutable to readWrite",
+				"compatible: uniqueness of attribute 'serialNumber' relaxed from global to server",
+			},
+		},
+		{
+			name: "canonical values",
+			old: lintSchemaOf(
+				lintAttributeOf("status", `"type":"string","canonicalValues":["active","retired"],"_annotations":{"@Enum":{}}`),
+				lintAttributeOf("kind", `"type":"string","canonicalValues":["laptop","phone"]`),
+			),
+			new: lintSchemaOf(
+				lintAttributeOf("status", `"type":"string","canonicalValues":["active","Lost"],"_annotations":{"@Enum":{}}`),
+				lintAttributeOf("kind", `"type":"string","canonicalValues":["laptop"]`),
+			),
+			expect: []string{
+				"breaking: canonical value 'retired' of attribute 'status' was removed",
+				"compatible: canonical value 'Lost' of attribute 'status' was added",
+				"compatible: canonical value 'phone' of attribute 'kind' was removed",
+			},
+		},
+		{
+			name: "sub attributes",
+			old: lintSchemaOf(lintAttributeOf("owner", `"type":"complex","subAttributes":[`+
+				lintAttributeOf("owner.value", `"type":"string"`)+`,`+lintAttributeOf("owner.display", `"type":"string"`)+`]`)),
+			new: lintSchemaOf(lintAttributeOf("owner", `"type":"complex","subAttributes":[`+
+				lintAttributeOf("owner.value", `"type":"string","caseExact":true,"description":"id of the owner"`)+`]`)),
+			expect: []string{
+				"breaking: caseExact of attribute 'owner.value' changed to true",
+				"compatible: description of attribute 'owner.value' changed",
+				"breaking: attribute 'owner.display' was removed",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			old, new := new(Schema), new(Schema)
+			if err := json.Unmarshal(test.old, old); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(test.new, new); err != nil {
+				t.Fatal(err)
+			}
+
+			var actual []string
+			for _, each := range DiffSchemas(old, new) {
+				actual = append(actual, each.String())
+			}
+			assert.Equal(t, test.expect, actual)
+		})
+	}
+}
@@ -0,0 +1,736 @@
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/annotation"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LintProblem is a problem found in the JSON definition of a schema.
+type LintProblem struct {
+	// Path of the attribute with the problem, or empty if the problem is with the schema itself.
+	Path string
+	// Description of the problem.
+	Message string
+	// True if the problem does not prevent the schema from being used, but is likely a mistake. Otherwise, the problem
+	// causes failures or unexpected behaviour when the schema is used.
+	Warning bool
+}
+
+func (p *LintProblem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	if len(p.Path) == 0 {
+		return fmt.Sprintf("%s: %s", level, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", level, p.Path, p.Message)
+}
+
+// AnnotationRule checks the applicability and the parameters of an annotation on the attribute. The parent is the
+// attribute containing the attribute, or nil for top level attributes. The rule returns the problems found, whose Path
+// is filled by the Linter.
+type AnnotationRule func(attr *Attribute, parent *Attribute, params map[string]interface{}) []*LintProblem
+
+// NewLinter returns a Linter that knows about the annotations defined in the annotation package. Annotations defined
+// elsewhere must be made known with Rule, or they are reported as unknown.
+func NewLinter() *Linter {
+	l := &Linter{rules: map[string]AnnotationRule{}}
+	l.Rule(annotation.Primary, lintPrimary)
+	l.Rule(annotation.ExclusivePrimary, lintExclusivePrimary)
+	l.Rule(annotation.Root, lintDerivedOnly)
+	l.Rule(annotation.SyncSchema, lintDerivedOnly)
+	l.Rule(annotation.SchemaExtensionRoot, lintDerivedOnly)
+	l.Rule(annotation.StateSummary, lintStateSummary)
+	l.Rule(annotation.AutoCompact, lintAutoCompact)
+	l.Rule(annotation.Identity, lintIdentity)
+	l.Rule(annotation.ElementAnnotations, l.lintElementAnnotations)
+	l.Rule(annotation.UUID, lintUUID)
+	l.Rule(annotation.BCrypt, lintBCrypt)
+	l.Rule(annotation.PasswordHash, lintPasswordHash)
+	l.Rule(annotation.ReadOnly, lintReadOnly)
+	l.Rule(annotation.Enum, lintEnum)
+	l.Rule(annotation.Encrypted, lintEncrypted)
+	l.Rule(annotation.Default, lintDefault)
+	l.Rule(annotation.Normalize, lintNormalize)
+	l.Rule(annotation.Computed, lintComputed)
+	l.Rule(annotation.Reference, lintReference)
+	return l
+}
+
+// Linter checks JSON definitions of schemas for problems that would otherwise surface as panics when parsing the schema
+// or resource types using it, or as unexpected behaviour at runtime. It checks the characteristics of attributes,
+// the naming and nesting of attributes, the applicability and parameters of annotations, referenceTypes, and
+// canonicalValues against @Enum.
+type Linter struct {
+	rules map[string]AnnotationRule
+}
+
+// Rule registers the rule for the annotation, replacing any previous one. A nil rule accepts the annotation on any
+// attribute.
+func (l *Linter) Rule(annotation string, rule AnnotationRule) *Linter {
+	if rule == nil {
+		rule = func(_ *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem { return nil }
+	}
+	l.rules[annotation] = rule
+	return l
+}
+
+// Lint returns all problems found in the JSON definition of a schema, sorted by the attribute path. The schema can
+// be safely parsed if there are no problems other than warnings.
+func (l *Linter) Lint(raw []byte) []*LintProblem {
+	var ls lintSchema
+	if err := json.Unmarshal(raw, &ls); err != nil {
+		return []*LintProblem{lintError("malformed schema definition: %s", err.Error())}
+	}
+
+	problems := ls.lint()
+	for _, each := range problems {
+		if !each.Warning {
+			// parsing the schema would panic or produce a broken schema, skip checks on the parsed schema.
+			return sortProblems(problems)
+		}
+	}
+
+	schema := new(Schema)
+	if err := json.Unmarshal(raw, schema); err != nil {
+		return append(problems, lintError("malformed schema definition: %s", err.Error()))
+	}
+	_ = schema.ForEachAttribute(func(attr *Attribute) error {
+		problems = append(problems, l.lintAttribute(attr, nil)...)
+		return nil
+	})
+
+	return sortProblems(problems)
+}
+
+// LintSchema returns all problems found in the JSON definition of a schema by a Linter created by NewLinter.
+func LintSchema(raw []byte) []*LintProblem {
+	return NewLinter().Lint(raw)
+}
+
+func (l *Linter) lintAttribute(attr *Attribute, parent *Attribute) []*LintProblem {
+	var problems []*LintProblem
+
+	names := make([]string, 0, len(attr.annotations))
+	for name := range attr.annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rule, ok := l.rules[name]
+		if !ok {
+			problems = append(problems, &LintProblem{Path: attr.Path(), Message: fmt.Sprintf("unknown annotation %s", name)})
+			continue
+		}
+		for _, each := range rule(attr, parent, attr.annotations[name]) {
+			each.Path = attr.Path()
+			if !strings.HasPrefix(each.Message, "@") {
+				each.Message = name + " " + each.Message
+			}
+			problems = append(problems, each)
+		}
+	}
+
+	problems = append(problems, lintCanonicalValues(attr)...)
+
+	_ = attr.ForEachSubAttribute(func(subAttr *Attribute) error {
+		problems = append(problems, l.lintAttribute(subAttr, attr)...)
+		return nil
+	})
+
+	return problems
+}
+
+func (l *Linter) lintElementAnnotations(attr *Attribute, parent *Attribute, params map[string]interface{}) []*LintProblem {
+	if !attr.MultiValued() {
+		return []*LintProblem{lintError("requires a multiValued attribute")}
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []*LintProblem
+	for _, name := range names {
+		if _, ok := params[name].(map[string]interface{}); !ok {
+			problems = append(problems, lintError("parameter %s must be an object of annotation parameters", name))
+			continue
+		}
+		if _, ok := l.rules[name]; !ok {
+			problems = append(problems, lintError("has unknown annotation %s", name))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	// check the annotations against the derived element attribute they will be assigned to
+	element := attr.DeriveElementAttribute()
+	for _, name := range names {
+		for _, each := range l.rules[name](element, parent, element.annotations[name]) {
+			each.Message = fmt.Sprintf("%s on elements %s", name, each.Message)
+			problems = append(problems, each)
+		}
+	}
+	return problems
+}
+
+func lintPrimary(attr *Attribute, parent *Attribute, _ map[string]interface{}) []*LintProblem {
+	if attr.Type() != TypeBoolean || attr.MultiValued() {
+		return []*LintProblem{lintError("requires a singular boolean attribute, but found %s", describe(attr))}
+	}
+	if parent == nil || parent.Type() != TypeComplex || !parent.MultiValued() {
+		return []*LintProblem{lintError("requires a sub attribute of a multiValued complex attribute")}
+	}
+	return nil
+}
+
+func lintExclusivePrimary(attr *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem {
+	if attr.Type() != TypeComplex || !attr.MultiValued() {
+		return []*LintProblem{lintError("requires a multiValued complex attribute, but found %s", describe(attr))}
+	}
+	if attr.FindSubAttribute(func(subAttr *Attribute) bool {
+		_, ok := subAttr.Annotation(annotation.Primary)
+		return ok
+	}) == nil {
+		return []*LintProblem{lintWarning("has no effect without a sub attribute annotated with @Primary")}
+	}
+	return nil
+}
+
+func lintDerivedOnly(_ *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem {
+	return []*LintProblem{lintError("is reserved for attributes derived from resource types")}
+}
+
+func lintStateSummary(attr *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem {
+	if attr.Type() != TypeComplex || attr.MultiValued() {
+		return []*LintProblem{lintError("requires a singular complex attribute, but found %s", describe(attr))}
+	}
+	return nil
+}
+
+func lintAutoCompact(attr *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem {
+	if !attr.MultiValued() {
+		return []*LintProblem{lintError("requires a multiValued attribute")}
+	}
+	return nil
+}
+
+func lintIdentity(_ *Attribute, parent *Attribute, _ map[string]interface{}) []*LintProblem {
+	if parent == nil || parent.Type() != TypeComplex {
+		return []*LintProblem{lintError("requires a sub attribute of a complex attribute")}
+	}
+	return nil
+}
+
+func lintUUID(attr *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem {
+	if attr.Type() != TypeString || attr.MultiValued() {
+		return []*LintProblem{lintError("requires a singular string attribute, but found %s", describe(attr))}
+	}
+	return nil
+}
+
+func lintBCrypt(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if (attr.Type() != TypeString && attr.Type() != TypeBinary) || attr.MultiValued() {
+		problems = append(problems, lintError("requires a singular string or binary attribute, but found %s", describe(attr)))
+	}
+	for name, value := range params {
+		if name != "cost" {
+			problems = append(problems, lintWarning("has unknown parameter %s", name))
+			continue
+		}
+		// bcrypt.MinCost and bcrypt.MaxCost
+		if cost, ok := value.(float64); !ok || cost != float64(int(cost)) || cost < 4 || cost > 31 {
+			problems = append(problems, lintError("parameter cost must be an integer between 4 and 31"))
+		}
+	}
+	return problems
+}
+
+// passwordHashParams are the parameters of the algorithms built in package password, with their ranges. Algorithms
+// registered elsewhere are not checked.
+var passwordHashParams = map[string]map[string][2]int{
+	"argon2id": {"memory": {1, 1<<31 - 1}, "iterations": {1, 1<<31 - 1}, "parallelism": {1, 255}, "saltLength": {1, 1024}, "keyLength": {1, 1024}},
+	"scrypt":   {"logN": {1, 30}, "blockSize": {1, 1024}, "parallelism": {1, 1024}, "saltLength": {1, 1024}, "keyLength": {1, 1024}},
+	"bcrypt":   {"cost": {4, 31}},
+}
+
+func lintPasswordHash(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if (attr.Type() != TypeString && attr.Type() != TypeBinary) || attr.MultiValued() {
+		problems = append(problems, lintError("requires a singular string or binary attribute, but found %s", describe(attr)))
+	}
+	if _, ok := attr.Annotation(annotation.BCrypt); ok {
+		problems = append(problems, lintError("cannot be combined with %s", annotation.BCrypt))
+	}
+
+	algorithm := "argon2id"
+	if value, ok := params["algorithm"]; ok {
+		if s, ok := value.(string); ok {
+			algorithm = s
+		} else {
+			return append(problems, lintError("parameter algorithm must be a string"))
+		}
+	}
+	known, ok := passwordHashParams[algorithm]
+	if !ok {
+		return append(problems, lintWarning("uses algorithm %s which is not built in, make sure it is registered", algorithm))
+	}
+	for _, name := range sortedParams(params) {
+		value := params[name]
+		if name == "algorithm" {
+			continue
+		}
+		bounds, ok := known[name]
+		if !ok {
+			problems = append(problems, lintWarning("has unknown parameter %s for algorithm %s", name, algorithm))
+			continue
+		}
+		if n, ok := value.(float64); !ok || n != float64(int(n)) || int(n) < bounds[0] || int(n) > bounds[1] {
+			problems = append(problems, lintError("parameter %s must be an integer between %d and %d", name, bounds[0], bounds[1]))
+		}
+	}
+	return problems
+}
+
+func lintReadOnly(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if attr.Mutability() != MutabilityReadOnly {
+		problems = append(problems, lintError("has no effect on a %s attribute, mutability must be readOnly", attr.Mutability().String()))
+	}
+	for name, value := range params {
+		if name != "reset" && name != "copy" {
+			problems = append(problems, lintWarning("has unknown parameter %s", name))
+			continue
+		}
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, lintError("parameter %s must be a boolean", name))
+		}
+	}
+	return problems
+}
+
+func lintEnum(attr *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if attr.Type() != TypeString && attr.Type() != TypeReference {
+		problems = append(problems, lintError("requires a string or reference attribute, but found %s", describe(attr)))
+	}
+	if attr.CountCanonicalValues() == 0 {
+		problems = append(problems, lintError("requires canonicalValues"))
+	}
+	return problems
+}
+
+func lintEncrypted(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	switch {
+	case attr.MultiValued():
+		problems = append(problems, lintError("requires a singular string, reference or binary attribute, but found %s", describe(attr)))
+	case attr.Type() != TypeString && attr.Type() != TypeReference && attr.Type() != TypeBinary:
+		problems = append(problems, lintError("requires a singular string, reference or binary attribute, but found %s", describe(attr)))
+	}
+	blindIndex := false
+	for name, value := range params {
+		if name != "blindIndex" {
+			problems = append(problems, lintWarning("has unknown parameter %s", name))
+			continue
+		}
+		if b, ok := value.(bool); !ok {
+			problems = append(problems, lintError("parameter blindIndex must be a boolean"))
+		} else {
+			blindIndex = b
+		}
+	}
+	if blindIndex && attr.Type() == TypeBinary {
+		problems = append(problems, lintError("parameter blindIndex is not available to binary attributes"))
+	}
+	if attr.Uniqueness() != UniquenessNone {
+		// Encrypted values never equal, so the database cannot enforce uniqueness on them.
+		problems = append(problems, lintError("cannot be combined with %s uniqueness", attr.Uniqueness().String()))
+	}
+	return problems
+}
+
+func lintDefault(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if attr.Type() == TypeComplex {
+		problems = append(problems, lintError("requires a simple attribute, but found %s", describe(attr)))
+	}
+	for name := range params {
+		if name != "value" {
+			problems = append(problems, lintWarning("has unknown parameter %s", name))
+		}
+	}
+	value, ok := params["value"]
+	if !ok {
+		return append(problems, lintError("requires parameter value"))
+	}
+	if attr.Type() == TypeComplex {
+		return problems
+	}
+
+	values := []interface{}{value}
+	if attr.MultiValued() {
+		if array, ok := value.([]interface{}); ok {
+			values = array
+		} else {
+			return append(problems, lintError("parameter value must be an array for a multiValued attribute"))
+		}
+	}
+	for _, each := range values {
+		var compatible bool
+		switch attr.Type() {
+		case TypeString, TypeReference, TypeDateTime, TypeBinary:
+			_, compatible = each.(string)
+		case TypeInteger:
+			n, ok := each.(float64)
+			compatible = ok && n == float64(int64(n))
+		case TypeDecimal:
+			_, compatible = each.(float64)
+		case TypeBoolean:
+			_, compatible = each.(bool)
+		}
+		if !compatible {
+			problems = append(problems, lintError("parameter value is incompatible with %s", describe(attr)))
+			break
+		}
+	}
+	return problems
+}
+
+func lintNormalize(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if attr.Type() != TypeString || attr.MultiValued() {
+		problems = append(problems, lintError("requires a singular string attribute, but found %s", describe(attr)))
+	}
+	e164 := false
+	for _, name := range sortedParams(params) {
+		value := params[name]
+		switch name {
+		case "nfc", "trim", "lowercase", "e164":
+			if b, ok := value.(bool); !ok {
+				problems = append(problems, lintError("parameter %s must be a boolean", name))
+			} else if name == "e164" {
+				e164 = b
+			}
+		case "countryCode":
+			if s, ok := value.(string); !ok || !countryCodePattern.MatchString(s) {
+				problems = append(problems, lintError("parameter countryCode must be a string of 1 to 3 digits"))
+			}
+		default:
+			problems = append(problems, lintWarning("has unknown parameter %s", name))
+		}
+	}
+	if _, ok := params["countryCode"]; ok && !e164 {
+		problems = append(problems, lintWarning("has no effect with parameter countryCode unless e164 is true"))
+	}
+	if b, _ := params["lowercase"].(bool); b && attr.CaseExact() {
+		problems = append(problems, lintWarning("lowercases values of a caseExact attribute"))
+	}
+	return problems
+}
+
+var countryCodePattern = regexp.MustCompile(`^\+?[1-9][0-9]{0,2}$`)
+
+func lintComputed(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if attr.Type() != TypeString || attr.MultiValued() {
+		problems = append(problems, lintError("requires a singular string attribute, but found %s", describe(attr)))
+	}
+	for name := range params {
+		if name != "template" {
+			problems = append(problems, lintWarning("has unknown parameter %s", name))
+		}
+	}
+	template, ok := params["template"].(string)
+	if !ok {
+		return append(problems, lintError("requires string parameter template"))
+	}
+	for len(template) > 0 {
+		open, end := strings.IndexByte(template, '{'), strings.IndexByte(template, '}')
+		if open < 0 && end < 0 {
+			break
+		}
+		if open < 0 || end < open {
+			return append(problems, lintError("parameter template has unbalanced curly braces"))
+		}
+		if len(strings.TrimSpace(template[open+1:end])) == 0 {
+			return append(problems, lintError("parameter template has empty path"))
+		}
+		if strings.IndexByte(template[open+1:end], '{') >= 0 {
+			return append(problems, lintError("parameter template has unbalanced curly braces"))
+		}
+		template = template[end+1:]
+	}
+	return problems
+}
+
+func lintReference(attr *Attribute, _ *Attribute, params map[string]interface{}) []*LintProblem {
+	var problems []*LintProblem
+	if attr.Type() != TypeComplex || attr.SubAttributeForName("value") == nil {
+		problems = append(problems, lintError("requires a complex attribute with a value sub attribute, but found %s", describe(attr)))
+	} else if refAttr := attr.SubAttributeForName("$ref"); refAttr == nil || refAttr.Type() != TypeReference ||
+		!refAttr.ExistsReferenceType(func(referenceType string) bool {
+			return referenceType != "external" && referenceType != "uri"
+		}) {
+		problems = append(problems, lintError("requires a $ref sub attribute whose referenceTypes name resource types"))
+	}
+	if attr.Mutability() == MutabilityReadOnly {
+		problems = append(problems, lintWarning("has no effect on a readOnly attribute, which is maintained by the server"))
+	}
+	for _, name := range sortedParams(params) {
+		if name != "onDelete" {
+			problems = append(problems, lintWarning("has unknown parameter %s", name))
+			continue
+		}
+		switch params[name] {
+		case "remove", "restrict", "ignore":
+		default:
+			problems = append(problems, lintError("parameter onDelete must be one of remove, restrict and ignore"))
+		}
+	}
+	return problems
+}
+
+func lintCanonicalValues(attr *Attribute) []*LintProblem {
+	if attr.CountCanonicalValues() == 0 {
+		return nil
+	}
+
+	var problems []*LintProblem
+	switch attr.Type() {
+	case TypeComplex, TypeBoolean, TypeBinary:
+		problems = append(problems, &LintProblem{
+			Path:    attr.Path(),
+			Message: fmt.Sprintf("canonicalValues are not applicable to %s attribute", attr.Type().String()),
+		})
+	}
+
+	seen := map[string]bool{}
+	attr.ForEachCanonicalValues(func(canonicalValue string) {
+		key := canonicalValue
+		if !attr.CaseExact() {
+			key = strings.ToLower(key)
+		}
+		if seen[key] {
+			problems = append(problems, &LintProblem{
+				Path:    attr.Path(),
+				Message: fmt.Sprintf("duplicate canonical value '%s'", canonicalValue),
+			})
+		}
+		seen[key] = true
+	})
+
+	return problems
+}
+
+// describe describes the type and plurality of the attribute in problem messages.
+func describe(attr *Attribute) string {
+	if attr.MultiValued() {
+		return "multiValued " + attr.Type().String()
+	}
+	return "singular " + attr.Type().String()
+}
+
+func lintError(format string, args ...interface{}) *LintProblem {
+	return &LintProblem{Message: fmt.Sprintf(format, args...)}
+}
+
+func lintWarning(format string, args ...interface{}) *LintProblem {
+	return &LintProblem{Message: fmt.Sprintf(format, args...), Warning: true}
+}
+
+// sortedParams returns the names of the annotation parameters in order, so problems are reported in a stable order.
+func sortedParams(params map[string]interface{}) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortProblems(problems []*LintProblem) []*LintProblem {
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems
+}
+
+// lintSchema and lintAttribute mirror the JSON definition of schema and attribute, but hold the characteristics as
+// plain strings and annotations as raw JSON, so they can be checked before parsing panics on them.
+type lintSchema struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	Attributes []*lintAttribute `json:"attributes"`
+}
+
+type lintAttribute struct {
+	ID              string                     `json:"id"`
+	Name            string                     `json:"name"`
+	Type            string                     `json:"type"`
+	SubAttributes   []*lintAttribute           `json:"subAttributes"`
+	CanonicalValues []string                   `json:"canonicalValues"`
+	MultiValued     bool                       `json:"multiValued"`
+	Mutability      string                     `json:"mutability"`
+	Returned        string                     `json:"returned"`
+	Uniqueness      string                     `json:"uniqueness"`
+	ReferenceTypes  []string                   `json:"referenceTypes"`
+	Path            string                     `json:"_path"`
+	Annotations     map[string]json.RawMessage `json:"_annotations"`
+}
+
+func (s *lintSchema) lint() []*LintProblem {
+	var problems []*LintProblem
+	if len(s.ID) == 0 {
+		problems = append(problems, lintError("schema requires id"))
+	}
+	if len(s.Name) == 0 {
+		problems = append(problems, lintWarning("schema requires name"))
+	}
+	return append(problems, lintSiblings(s.ID, "", s.Attributes)...)
+}
+
+func lintSiblings(schemaId string, parentPath string, attributes []*lintAttribute) []*LintProblem {
+	var (
+		problems []*LintProblem
+		names    = map[string]bool{}
+	)
+	for i, each := range attributes {
+		if each == nil {
+			field := "attributes"
+			if len(parentPath) > 0 {
+				field = "subAttributes"
+			}
+			problems = append(problems, &LintProblem{Path: parentPath, Message: fmt.Sprintf("%s[%d] must be an attribute definition, but found null", field, i)})
+			continue
+		}
+		problems = append(problems, each.lint(schemaId, parentPath)...)
+		if len(each.Name) > 0 {
+			if names[strings.ToLower(each.Name)] {
+				problems = append(problems, &LintProblem{Path: joinLintPath(parentPath, each.Name), Message: "duplicate attribute name"})
+			}
+			names[strings.ToLower(each.Name)] = true
+		}
+	}
+	return problems
+}
+
+func (a *lintAttribute) lint(schemaId string, parentPath string) []*LintProblem {
+	var (
+		path     = joinLintPath(parentPath, a.Name)
+		problems []*LintProblem
+		report   = func(p *LintProblem) {
+			p.Path = path
+			problems = append(problems, p)
+		}
+	)
+
+	if len(a.Name) == 0 {
+		report(lintError("attribute requires name"))
+		return problems
+	}
+	if !isAttributeName(a.Name) {
+		report(lintError("attribute name must start with a letter, followed by letters, digits, '-' or '_'"))
+	}
+	if len(a.ID) == 0 {
+		report(lintError("attribute requires id"))
+	} else if len(schemaId) > 0 && schemaId != CoreSchemaId && a.ID != schemaId+":"+path {
+		report(lintWarning("id is expected to be '%s:%s'", schemaId, path))
+	}
+	if a.Path != path {
+		report(lintError("_path must be '%s'", path))
+	}
+
+	for _, each := range []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{name: "type", value: a.Type, allowed: []string{"string", "integer", "decimal", "boolean", "dateTime", "reference", "binary", "complex"}},
+		{name: "mutability", value: a.Mutability, allowed: []string{"readWrite", "readOnly", "writeOnly", "immutable"}},
+		{name: "returned", value: a.Returned, allowed: []string{"default", "always", "never", "request"}},
+		{name: "uniqueness", value: a.Uniqueness, allowed: []string{"none", "server", "global"}},
+	} {
+		if len(each.value) > 0 && !containsString(each.allowed, each.value) {
+			report(lintError("invalid %s '%s', must be one of %s", each.name, each.value, strings.Join(each.allowed, ", ")))
+		}
+	}
+
+	switch {
+	case a.Type == "complex" && len(a.SubAttributes) == 0:
+		report(lintError("complex attribute requires subAttributes"))
+	case a.Type != "complex" && len(a.SubAttributes) > 0:
+		report(lintError("subAttributes are only applicable to complex attribute"))
+	case a.Type == "complex" && len(parentPath) > 0:
+		report(lintError("complex attribute cannot be a sub attribute of another complex attribute"))
+	}
+
+	if a.Type != "reference" && len(a.ReferenceTypes) > 0 {
+		report(lintError("referenceTypes are only applicable to reference attribute"))
+	}
+	for _, referenceType := range a.ReferenceTypes {
+		switch {
+		case referenceType == "external", referenceType == "uri":
+		case strings.EqualFold(referenceType, "external"), strings.EqualFold(referenceType, "uri"):
+			report(lintError("invalid referenceType '%s', must be '%s'", referenceType, strings.ToLower(referenceType)))
+		case len(referenceType) == 0, referenceType == "$ref", !isAttributeName(referenceType):
+			report(lintError("invalid referenceType '%s', must be external, uri or the name of a resource type", referenceType))
+		}
+	}
+
+	names := make([]string, 0, len(a.Annotations))
+	for name := range a.Annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var params map[string]interface{}
+		if err := json.Unmarshal(a.Annotations[name], &params); err != nil {
+			report(lintError("parameters of annotation %s must be an object", name))
+		}
+	}
+
+	return append(problems, lintSiblings(schemaId, path, a.SubAttributes)...)
+}
+
+// isAttributeName checks the name against ATTRNAME defined in RFC7643 section 2.1, plus the "$ref" sub attribute.
+func isAttributeName(name string) bool {
+	if name == "$ref" {
+		return true
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '_'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func joinLintPath(parentPath string, name string) string {
+	if len(parentPath) == 0 {
+		return name
+	}
+	return parentPath + "." + name
+}
+
+func containsString(values []string, value string) bool {
+	for _, each := range values {
+		if each == value {
+			return true
+		}
+	}
+	return false
+}
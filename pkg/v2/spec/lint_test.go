@@ -0,0 +1,263 @@
+package spec
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	s := new(LintTestSuite)
+	suite.Run(t, s)
+}
+
+type LintTestSuite struct {
+	suite.Suite
+}
+
+const testLintSchemaId = "urn:imulab:scim:schemas:core:2.0:Device"
+
+// lintSchemaOf wraps the attributes in a schema definition.
+func lintSchemaOf(attributes ...string) []byte {
+	return []byte(fmt.Sprintf(`{"id":"%s","name":"Device","attributes":[%s]}`, testLintSchemaId, strings.Join(attributes, ",")))
+}
+
+// lintAttributeOf returns the definition of an attribute with a conventional id and path, and the additional fields.
+func lintAttributeOf(path string, fields string) string {
+	name := path[strings.LastIndex(path, ".")+1:]
+	return fmt.Sprintf(`{"id":"%s:%s","name":"%s","_path":"%s",%s}`, testLintSchemaId, path, name, path, fields)
+}
+
+func (s *LintTestSuite) TestPublicSchemas() {
+	files, err := filepath.Glob("../../../public/schemas/*.json")
+	s.Require().Nil(err)
+	s.Require().NotEmpty(files)
+
+	for _, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		s.Require().Nil(err)
+		assert.Empty(s.T(), LintSchema(raw), file)
+	}
+}
+
+func (s *LintTestSuite) TestLint() {
+	tests := []struct {
+		name   string
+		schema []byte
+		expect []string
+	}{
+		{
+			name:   "malformed",
+			schema: []byte(`{"id":`),
+			expect: []string{"error: malformed schema definition: unexpected end of JSON input"},
+		},
+		{
+			name: "invalid characteristics",
+			schema: lintSchemaOf(
+				lintAttributeOf("serialNumber", `"type":"text","mutability":"readwrite"`),
+			),
+			expect: []string{
+				"error: serialNumber: invalid type 'text', must be one of string, integer, decimal, boolean, dateTime, reference, binary, complex",
+				"error: serialNumber: invalid mutability 'readwrite', must be one of readWrite, readOnly, writeOnly, immutable",
+			},
+		},
+		{
+			name: "naming and nesting",
+			schema: lintSchemaOf(
+				lintAttributeOf("owner", `"type":"complex","subAttributes":[`+
+					lintAttributeOf("owner.name", `"type":"complex","subAttributes":[`+lintAttributeOf("owner.name.first", `"type":"string"`)+`]`)+`]`),
+				lintAttributeOf("1st", `"type":"string"`),
+				`{"id":"x","name":"model","_path":"model","type":"string","referenceTypes":["external"]}`,
+				`{"id":"x","name":"Model","_path":"modelName","type":"string"}`,
+				lintAttributeOf("tags", `"type":"complex"`),
+			),
+			expect: []string{
+				"error: 1st: attribute name must start with a letter, followed by letters, digits, '-' or '_'",
+				"warning: Model: id is expected to be 'urn:imulab:scim:schemas:core:2.0:Device:Model'",
+				"error: Model: _path must be 'Model'",
+				"error: Model: duplicate attribute name",
+				"warning: model: id is expected to be 'urn:imulab:scim:schemas:core:2.0:Device:model'",
+				"error: model: referenceTypes are only applicable to reference attribute",
+				"error: owner.name: complex attribute cannot be a sub attribute of another complex attribute",
+				"error: tags: complex attribute requires subAttributes",
+			},
+		},
+		{
+			name: "null attributes",
+			schema: lintSchemaOf(
+				`null`,
+				lintAttributeOf("owner", `"type":"complex","subAttributes":[null,`+lintAttributeOf("owner.name", `"type":"string"`)+`]`),
+			),
+			expect: []string{
+				"error: attributes[0] must be an attribute definition, but found null",
+				"error: owner: subAttributes[0] must be an attribute definition, but found null",
+			},
+		},
+		{
+			name: "reference types",
+			schema: lintSchemaOf(
+				lintAttributeOf("homepage", `"type":"reference","referenceTypes":["URI","external"]`),
+				lintAttributeOf("owner", `"type":"reference","referenceTypes":["User","","Some Type"]`),
+			),
+			expect: []string{
+				"error: homepage: invalid referenceType 'URI', must be 'uri'",
+				"error: owner: invalid referenceType '', must be external, uri or the name of a resource type",
+				"error: owner: invalid referenceType 'Some Type', must be external, uri or the name of a resource type",
+			},
+		},
+		{
+			name: "annotations",
+			schema: lintSchemaOf(
+				lintAttributeOf("active", `"type":"boolean","_annotations":{"@Primary":{},"@Audited":{}}`),
+				lintAttributeOf("serialNumber", `"type":"string","multiValued":true,"_annotations":{"@ExclusivePrimary":{},"@UUID":{}}`),
+				lintAttributeOf("pin", `"type":"integer","_annotations":{"@BCrypt":{"cost":40}}`),
+				lintAttributeOf("version", `"type":"string","_annotations":{"@ReadOnly":{"reset":"yes"},"@Root":{}}`),
+				lintAttributeOf("status", `"type":"string","_annotations":{"@Enum":{}}`),
+				lintAttributeOf("ports", `"type":"complex","multiValued":true,"_annotations":{"@ElementAnnotations":{"@AutoCompact":{}}},"subAttributes":[`+
+					lintAttributeOf("ports.number", `"type":"integer","_annotations":{"@Identity":{}}`)+`]`),
+			),
+			expect: []string{
+				"error: active: unknown annotation @Audited",
+				"error: active: @Primary requires a sub attribute of a multiValued complex attribute",
+				"error: pin: @BCrypt requires a singular string or binary attribute, but found singular integer",
+				"error: pin: @BCrypt parameter cost must be an integer between 4 and 31",
+				"error: ports: @AutoCompact on elements requires a multiValued attribute",
+				"error: serialNumber: @ExclusivePrimary requires a multiValued complex attribute, but found multiValued string",
+				"error: serialNumber: @UUID requires a singular string attribute, but found multiValued string",
+				"error: status: @Enum requires canonicalValues",
+				"error: version: @ReadOnly has no effect on a readWrite attribute, mutability must be readOnly",
+				"error: version: @ReadOnly parameter reset must be a boolean",
+				"error: version: @Root is reserved for attributes derived from resource types",
+			},
+		},
+		{
+			name: "canonical values",
+			schema: lintSchemaOf(
+				lintAttributeOf("status", `"type":"string","canonicalValues":["active","Active"],"_annotations":{"@Enum":{}}`),
+				lintAttributeOf("enabled", `"type":"boolean","canonicalValues":["true"]`),
+			),
+			expect: []string{
+				"error: enabled: canonicalValues are not applicable to boolean attribute",
+				"error: status: duplicate canonical value 'Active'",
+			},
+		},
+		{
+			name: "encrypted",
+			schema: lintSchemaOf(
+				lintAttributeOf("employeeNumber", `"type":"string","uniqueness":"server","_annotations":{"@Encrypted":{}}`),
+				lintAttributeOf("certificate", `"type":"binary","_annotations":{"@Encrypted":{"blindIndex":true}}`),
+				lintAttributeOf("level", `"type":"integer","_annotations":{"@Encrypted":{"blindIndex":"yes"}}`),
+			),
+			expect: []string{
+				"error: certificate: @Encrypted parameter blindIndex is not available to binary attributes",
+				"error: employeeNumber: @Encrypted cannot be combined with server uniqueness",
+				"error: level: @Encrypted requires a singular string, reference or binary attribute, but found singular integer",
+				"error: level: @Encrypted parameter blindIndex must be a boolean",
+			},
+		},
+		{
+			name: "password hash",
+			schema: lintSchemaOf(
+				lintAttributeOf("password", `"type":"string","_annotations":{"@PasswordHash":{"algorithm":"scrypt","logN":40,"cost":10},"@BCrypt":{}}`),
+				lintAttributeOf("pin", `"type":"integer","multiValued":true,"_annotations":{"@PasswordHash":{"algorithm":1}}`),
+				lintAttributeOf("secret", `"type":"binary","_annotations":{"@PasswordHash":{"algorithm":"pbkdf2"}}`),
+			),
+			expect: []string{
+				"error: password: @PasswordHash cannot be combined with @BCrypt",
+				"warning: password: @PasswordHash has unknown parameter cost for algorithm scrypt",
+				"error: password: @PasswordHash parameter logN must be an integer between 1 and 30",
+				"error: pin: @PasswordHash requires a singular string or binary attribute, but found multiValued integer",
+				"error: pin: @PasswordHash parameter algorithm must be a string",
+				"warning: secret: @PasswordHash uses algorithm pbkdf2 which is not built in, make sure it is registered",
+			},
+		},
+		{
+			name: "data shaping",
+			schema: lintSchemaOf(
+				lintAttributeOf("locale", `"type":"string","_annotations":{"@Default":{"value":1}}`),
+				lintAttributeOf("roles", `"type":"string","multiValued":true,"_annotations":{"@Default":{"value":"reader"}}`),
+				lintAttributeOf("level", `"type":"integer","_annotations":{"@Default":{}}`),
+				lintAttributeOf("phone", `"type":"string","caseExact":true,"_annotations":{"@Normalize":{"lowercase":true,"e164":"yes","countryCode":"1234"}}`),
+				lintAttributeOf("tags", `"type":"string","multiValued":true,"_annotations":{"@Normalize":{"trim":true}}`),
+				lintAttributeOf("displayName", `"type":"string","_annotations":{"@Computed":{"template":"{name.givenName} {}"}}`),
+				lintAttributeOf("label", `"type":"integer","_annotations":{"@Computed":{"template":"{name.givenName"}}`),
+			),
+			expect: []string{
+				"error: displayName: @Computed parameter template has empty path",
+				"error: label: @Computed requires a singular string attribute, but found singular integer",
+				"error: label: @Computed parameter template has unbalanced curly braces",
+				"error: level: @Default requires parameter value",
+				"error: locale: @Default parameter value is incompatible with singular string",
+				"error: phone: @Normalize parameter countryCode must be a string of 1 to 3 digits",
+				"error: phone: @Normalize parameter e164 must be a boolean",
+				"warning: phone: @Normalize has no effect with parameter countryCode unless e164 is true",
+				"warning: phone: @Normalize lowercases values of a caseExact attribute",
+				"error: roles: @Default parameter value must be an array for a multiValued attribute",
+				"error: tags: @Normalize requires a singular string attribute, but found multiValued string",
+			},
+		},
+		{
+			name: "references",
+			schema: lintSchemaOf(
+				lintAttributeOf("manager", `"type":"complex","_annotations":{"@Reference":{"onDelete":"cascade","cascade":true}},"subAttributes":[`+
+					lintAttributeOf("manager.value", `"type":"string"`)+`,`+
+					lintAttributeOf("manager.$ref", `"type":"reference","referenceTypes":["external"]`)+`]`),
+				lintAttributeOf("owner", `"type":"string","mutability":"readOnly","_annotations":{"@Reference":{}}`),
+			),
+			expect: []string{
+				"error: manager: @Reference requires a $ref sub attribute whose referenceTypes name resource types",
+				"warning: manager: @Reference has unknown parameter cascade",
+				"error: manager: @Reference parameter onDelete must be one of remove, restrict and ignore",
+				"error: owner: @Reference requires a complex attribute with a value sub attribute, but found singular string",
+				"warning: owner: @Reference has no effect on a readOnly attribute, which is maintained by the server",
+			},
+		},
+		{
+			name: "known annotations",
+			schema: lintSchemaOf(
+				lintAttributeOf("emails", `"type":"complex","multiValued":true,"_annotations":{"@ExclusivePrimary":{},"@AutoCompact":{},"@ElementAnnotations":{"@StateSummary":{}}},"subAttributes":[`+
+					lintAttributeOf("emails.value", `"type":"string","_annotations":{"@Identity":{}}`)+`,`+
+					lintAttributeOf("emails.primary", `"type":"boolean","_annotations":{"@Primary":{}}`)+`]`),
+				lintAttributeOf("password", `"type":"string","mutability":"writeOnly","_annotations":{"@BCrypt":{"cost":12}}`),
+				lintAttributeOf("employeeNumber", `"type":"string","_annotations":{"@Encrypted":{"blindIndex":true}}`),
+				lintAttributeOf("pin", `"type":"string","mutability":"writeOnly","_annotations":{"@PasswordHash":{"algorithm":"argon2id","memory":19456,"iterations":2,"parallelism":1}}`),
+				lintAttributeOf("locale", `"type":"string","_annotations":{"@Default":{"value":"en-US"},"@Normalize":{"trim":true}}`),
+				lintAttributeOf("phone", `"type":"string","_annotations":{"@Normalize":{"e164":true,"countryCode":"+1"}}`),
+				lintAttributeOf("level", `"type":"integer","multiValued":true,"_annotations":{"@Default":{"value":[1,2]}}`),
+				lintAttributeOf("label", `"type":"string","_annotations":{"@Computed":{"template":"{locale} ({phone})"}}`),
+				lintAttributeOf("manager", `"type":"complex","_annotations":{"@Reference":{"onDelete":"restrict"}},"subAttributes":[`+
+					lintAttributeOf("manager.value", `"type":"string"`)+`,`+
+					lintAttributeOf("manager.$ref", `"type":"reference","referenceTypes":["User"]`)+`]`),
+			),
+			expect: nil,
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			var actual []string
+			for _, each := range LintSchema(test.schema) {
+				actual = append(actual, each.String())
+			}
+			assert.Equal(t, test.expect, actual)
+		})
+	}
+}
+
+func (s *LintTestSuite) TestRule() {
+	schema := lintSchemaOf(lintAttributeOf("serialNumber", `"type":"string","_annotations":{"@MongoIndex":{}}`))
+
+	problems := NewLinter().Lint(schema)
+	assert.Len(s.T(), problems, 1)
+
+	assert.Empty(s.T(), NewLinter().Rule("@MongoIndex", nil).Lint(schema))
+
+	problems = NewLinter().Rule("@MongoIndex", func(attr *Attribute, _ *Attribute, _ map[string]interface{}) []*LintProblem {
+		return []*LintProblem{{Message: "requires uniqueness", Warning: true}}
+	}).Lint(schema)
+	assert.Equal(s.T(), "warning: serialNumber: @MongoIndex requires uniqueness", problems[0].String())
+}
@@ -44,6 +44,7 @@ type ResourceType struct {
 	schema      *Schema
 	extensions  []*Schema
 	required    map[string]bool // schema id to boolean to indicate whether schema extension is required
+	registry    *SchemaRegistry // registry the schemas were resolved from, nil means the default registry
 }
 
 // Return the id of the resource type
@@ -70,6 +71,15 @@ func (t *ResourceType) Schema() *Schema {
 	return t.schema
 }
 
+// Registry returns the schema registry that this resource type was parsed against. Resource types parsed using
+// SchemaRegistry.ParseResourceType return that registry; all others return the default registry, see Schemas.
+func (t *ResourceType) Registry() *SchemaRegistry {
+	if t.registry == nil {
+		return Schemas()
+	}
+	return t.registry
+}
+
 // ForEachExtension iterates through all schema extensions and invoke the callback.
 func (t *ResourceType) ForEachExtension(callback func(extension *Schema, required bool) error) error {
 	for _, ext := range t.extensions {
@@ -131,11 +141,11 @@ func (t *ResourceType) convertFromAdapter(p *internal.ResourceTypeJsonAdapter) {
 	t.name = p.Name
 	t.description = p.Description
 	t.endpoint = p.Endpoint
-	t.schema = Schemas().mustGet(p.Schema)
+	t.schema = t.Registry().mustGet(p.Schema)
 	t.extensions = []*Schema{}
 	t.required = map[string]bool{}
 	for _, ext := range p.Extensions {
-		t.extensions = append(t.extensions, Schemas().mustGet(ext.Schema))
+		t.extensions = append(t.extensions, t.Registry().mustGet(ext.Schema))
 		t.required[ext.Schema] = ext.Required
 	}
 }
@@ -155,7 +165,7 @@ func (t *ResourceType) SuperAttribute(includeCore bool) *Attribute {
 	}
 
 	if includeCore {
-		super.subAttributes = append(super.subAttributes, Schemas().mustGet(CoreSchemaId).attributes...)
+		super.subAttributes = append(super.subAttributes, t.Registry().mustGet(CoreSchemaId).attributes...)
 		super.annotations[annotation.SyncSchema] = map[string]interface{}{}
 	}
 
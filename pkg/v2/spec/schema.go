@@ -2,6 +2,8 @@ package spec
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/v2/spec/internal"
 	"sync"
 )
 
@@ -91,29 +93,65 @@ type schemaJsonAdapter struct {
 }
 
 var (
-	schemaReg          *schemaRegistry
+	schemaReg          *SchemaRegistry
 	schemaRegistryOnce sync.Once
 )
 
-type schemaRegistry struct {
-	db map[string]*Schema
+// SchemaRegistry holds schemas by their id, as well as the URN prefixes recognized when compiling SCIM paths. Most
+// applications use the single default registry returned by Schemas. Applications serving multiple tenants with different
+// schemas can create one registry per tenant using NewSchemaRegistry, and parse resource types against it using
+// ParseResourceType, so that resource types, and everything derived from them, stay isolated from other tenants.
+//
+// SchemaRegistry is safe for concurrent use.
+type SchemaRegistry struct {
+	sync.RWMutex
+	db   map[string]*Schema
+	urns *URNs
+}
+
+// NewSchemaRegistry returns a new and empty schema registry. Note that the core schema needs to be registered before
+// any resource type is parsed against it.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		db:   map[string]*Schema{},
+		urns: &URNs{},
+	}
 }
 
 // Register relates the schema with its id in the registry. This method does not check existence of the id and may
 // overwrite existing schemas if abused.
-func (r *schemaRegistry) Register(schema *Schema) {
+func (r *SchemaRegistry) Register(schema *Schema) {
+	r.Lock()
+	defer r.Unlock()
 	r.db[schema.id] = schema
 }
 
+// Unregister removes the schema by the schemaId from the registry. Resource types parsed earlier still hold the removed
+// schema, hence it is up to the caller to make sure the schema is no longer in use.
+func (r *SchemaRegistry) Unregister(schemaId string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.db, schemaId)
+}
+
 // Get returns the schema that is related to a schemaId, or nil, along with a boolean indicating if the schema exists.
-func (r *schemaRegistry) Get(schemaId string) (schema *Schema, ok bool) {
+func (r *SchemaRegistry) Get(schemaId string) (schema *Schema, ok bool) {
+	r.RLock()
+	defer r.RUnlock()
 	schema, ok = r.db[schemaId]
 	return
 }
 
 // ForEachSchema invokes the callback function on each registered schema.
-func (r *schemaRegistry) ForEachSchema(callback func(schema *Schema) error) error {
+func (r *SchemaRegistry) ForEachSchema(callback func(schema *Schema) error) error {
+	r.RLock()
+	schemas := make([]*Schema, 0, len(r.db))
 	for _, schema := range r.db {
+		schemas = append(schemas, schema)
+	}
+	r.RUnlock()
+
+	for _, schema := range schemas {
 		if err := callback(schema); err != nil {
 			return err
 		}
@@ -121,7 +159,42 @@ func (r *schemaRegistry) ForEachSchema(callback func(schema *Schema) error) erro
 	return nil
 }
 
-func (r *schemaRegistry) mustGet(schemaId string) *Schema {
+// RegisterURN saves the given urn into the registry, so it could be recognized as a namespace when compiling SCIM paths.
+func (r *SchemaRegistry) RegisterURN(urn string) {
+	r.Lock()
+	defer r.Unlock()
+	r.urns = r.urns.insert(r.urns, urn, 0)
+}
+
+// URNs returns the trie of all URNs registered so far.
+func (r *SchemaRegistry) URNs() *URNs {
+	r.RLock()
+	defer r.RUnlock()
+	return r.urns
+}
+
+// ParseResourceType parses the JSON representation of a resource type, resolving its main schema and schema extensions
+// from this registry. The returned resource type remembers this registry; see ResourceType.Registry.
+func (r *SchemaRegistry) ParseResourceType(raw []byte) (*ResourceType, error) {
+	var adapter internal.ResourceTypeJsonAdapter
+	if err := json.Unmarshal(raw, &adapter); err != nil {
+		return nil, err
+	}
+	ids := []string{adapter.Schema}
+	for _, ext := range adapter.Extensions {
+		ids = append(ids, ext.Schema)
+	}
+	for _, id := range ids {
+		if _, ok := r.Get(id); !ok {
+			return nil, fmt.Errorf("%w: schema '%s' is not registered", ErrNotFound, id)
+		}
+	}
+	t := &ResourceType{registry: r}
+	t.convertFromAdapter(&adapter)
+	return t, nil
+}
+
+func (r *SchemaRegistry) mustGet(schemaId string) *Schema {
 	schema, ok := r.Get(schemaId)
 	if !ok {
 		panic("schema " + schemaId + " was not registered")
@@ -129,10 +202,11 @@ func (r *schemaRegistry) mustGet(schemaId string) *Schema {
 	return schema
 }
 
-// Schemas return the schema registry that holds all registered schemas. Use Get and Register to operate the registry.
-func Schemas() *schemaRegistry {
+// Schemas return the default schema registry that holds all registered schemas. Use Get and Register to operate the
+// registry.
+func Schemas() *SchemaRegistry {
 	schemaRegistryOnce.Do(func() {
-		schemaReg = &schemaRegistry{db: map[string]*Schema{}}
+		schemaReg = NewSchemaRegistry()
 	})
 	return schemaReg
 }
@@ -2,6 +2,7 @@ package spec
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"testing"
@@ -80,3 +81,30 @@ func (s *SchemaTestSuite) TestUnmarshal() {
 	assert.Equal(s.T(), "User", schema.Name())
 	assert.Len(s.T(), schema.attributes, 1)
 }
+
+func (s *SchemaTestSuite) TestRegistry() {
+	registry := NewSchemaRegistry()
+	registry.Register(&Schema{id: CoreSchemaId})
+	registry.Register(&Schema{id: "urn:imulab:scim:2.0:Tenant"})
+
+	_, ok := registry.Get("urn:imulab:scim:2.0:Tenant")
+	assert.True(s.T(), ok)
+	_, ok = Schemas().Get("urn:imulab:scim:2.0:Tenant")
+	assert.False(s.T(), ok)
+
+	rt, err := registry.ParseResourceType([]byte(`{"id":"Tenant","name":"Tenant","endpoint":"/Tenants","schema":"urn:imulab:scim:2.0:Tenant"}`))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), registry, rt.Registry())
+	assert.Equal(s.T(), "urn:imulab:scim:2.0:Tenant", rt.Schema().ID())
+	assert.NotNil(s.T(), rt.SuperAttribute(true))
+
+	_, err = registry.ParseResourceType([]byte(`{"id":"User","name":"User","endpoint":"/Users","schema":"urn:ietf:params:scim:schemas:core:2.0:User"}`))
+	assert.True(s.T(), errors.Is(err, ErrNotFound))
+
+	before := registry.URNs()
+	registry.RegisterURN("urn:imulab:scim:2.0:Tenant")
+	_, ok = before.Next('u')
+	assert.False(s.T(), ok)
+	_, ok = registry.URNs().Next('U')
+	assert.True(s.T(), ok)
+}
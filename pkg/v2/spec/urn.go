@@ -0,0 +1,62 @@
+package spec
+
+// URNs is a trie of registered URN prefixes. It is used by the path compiler to decide where to treat a dot as a path
+// separator and where to treat it as just part of the URN namespace (i.e. the "2.0" in "urn:ietf:params:scim:schemas:core:2.0:User").
+//
+// URNs is immutable: registering a new URN in SchemaRegistry produces a new trie that shares the unchanged nodes with
+// the previous one. Hence, a trie obtained from SchemaRegistry.URNs can be walked without locking.
+type URNs struct {
+	// true if a word's trie path ends at this node
+	w    bool
+	next map[byte]*URNs
+}
+
+// IsWord returns true if a registered URN ends at this node of the trie.
+func (t *URNs) IsWord() bool {
+	return t != nil && t.w
+}
+
+// Next returns the sub trie matching the given character case insensitively, and a boolean indicating whether such
+// sub trie exists.
+func (t *URNs) Next(c byte) (*URNs, bool) {
+	if t == nil || len(t.next) == 0 {
+		return nil, false
+	}
+	next, ok := t.next[toLowerCaseByte(c)]
+	return next, ok
+}
+
+// insert returns a copy of x with the word inserted. Nodes along the path of the word are copied, while others are
+// shared with x.
+func (t *URNs) insert(x *URNs, word string, d int) *URNs {
+	y := &URNs{}
+	if x != nil {
+		y.w = x.w
+		if len(x.next) > 0 {
+			y.next = make(map[byte]*URNs, len(x.next)+1)
+			for k, v := range x.next {
+				y.next[k] = v
+			}
+		}
+	}
+
+	if d == len(word) {
+		y.w = true
+		return y
+	}
+
+	if y.next == nil {
+		y.next = make(map[byte]*URNs)
+	}
+
+	b := toLowerCaseByte(word[d])
+	y.next[b] = t.insert(y.next[b], word, d+1)
+	return y
+}
+
+func toLowerCaseByte(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return 'a' + (c - 'A')
+	}
+	return c
+}